@@ -0,0 +1,328 @@
+// Package client wraps the generated LlmService client with the parts every consumer needs:
+// assembling stream deltas, reading the done and failed chunks, extracting usage and timing, and
+// turning a failed stream into a typed error.
+//
+//	c, err := client.Dial("localhost:8787")
+//	if err != nil { ... }
+//	defer c.Close()
+//	res, err := c.Stream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hi"}, func(d client.Delta) error {
+//		fmt.Print(d.Text)
+//		return nil
+//	})
+package client
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Client calls an LlmService.
+type Client struct {
+	rpc  llmv1.LlmServiceClient
+	conn *grpc.ClientConn // set by Dial, closed by Close
+}
+
+// New wraps an existing generated client.
+func New(rpc llmv1.LlmServiceClient) *Client {
+	return &Client{rpc: rpc}
+}
+
+// Dial connects to the LlmService at target. Without options the connection is plaintext; pass
+// grpc.WithTransportCredentials (and any other dial options) to change that.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpc: llmv1.NewLlmServiceClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection opened by Dial. It does nothing for a Client made with New.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Usage is the token usage of a completion.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Result is a completion. TTFT and Latency are measured by the client from the start of the call:
+// TTFT to the first delta (the whole call for Complete), Latency to the end.
+//
+// Text and FinishReason are those of the first choice; Choices holds every choice of a stream (more
+// than one when the request asked for n > 1), and Usage totals them with the prompt counted once.
+type Result struct {
+	ID           string
+	Text         string
+	FinishReason string // events.FinishStop (FinishLength or FinishTimeout when MAX_REQUEST_DURATION_MS cut it), or events.FinishError on a failed stream
+	Usage        Usage
+	Choices      []Choice // by index; empty for Complete
+	TTFT         time.Duration
+	Latency      time.Duration
+}
+
+// Choice is one choice of a streamed completion, assembled from the deltas and done chunk carrying
+// its index.
+type Choice struct {
+	Index        int
+	Text         string
+	FinishReason string // as Result.FinishReason, for this choice
+	Usage        Usage
+}
+
+// Delta is one piece of streamed text; Index counts the deltas of a stream from 0 and Choice is the
+// index of the choice it belongs to.
+type Delta struct {
+	Text   string
+	Index  int
+	Choice int
+}
+
+// Error is a failed completion: its gRPC status and whether the simulator injected it on purpose
+// (ERROR_RATE) rather than failing for real. status.Code and status.Convert work on it.
+type Error struct {
+	Code     codes.Code
+	Message  string
+	Injected bool
+}
+
+func (e *Error) Error() string {
+	if e.Injected {
+		return fmt.Sprintf("llm: %s (injected): %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("llm: %s: %s", e.Code, e.Message)
+}
+
+// GRPCStatus returns the status of the error.
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+var (
+	// ErrNoDone is returned when a stream ends cleanly without a done chunk.
+	ErrNoDone = errors.New("llm: stream ended without a done chunk")
+	// ErrDigest is returned when the assembled text doesn't match the done chunk's digest.
+	ErrDigest = errors.New("llm: assembled text does not match the done chunk digest")
+)
+
+// Complete makes a unary call.
+func (c *Client) Complete(ctx context.Context, req *llmv1.ChatCompletionRequest) (*Result, error) {
+	start := time.Now()
+	resp, err := c.rpc.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, convertError(ctx, err, nil)
+	}
+	d := time.Since(start)
+	return &Result{
+		ID:           resp.GetId(),
+		Text:         resp.GetOutputText(),
+		FinishReason: resp.GetFinishReason(),
+		Usage: Usage{
+			PromptTokens:     int(resp.GetPromptTokens()),
+			CompletionTokens: int(resp.GetCompletionTokens()),
+			TotalTokens:      int(resp.GetTotalTokens()),
+		},
+		TTFT:    d,
+		Latency: d,
+	}, nil
+}
+
+// Stream makes a streaming call, passing each delta to fn as it arrives, and returns the assembled
+// result once the stream ends. Chunk types don't matter, so every EVENT_NAMING works. Each choice is
+// assembled and checked against its done chunk's digest on its own.
+//
+// When the stream fails, the error is an *Error (or ctx's error once ctx is done) and the result
+// holds the text received so far with FinishReason events.FinishError. The failed chunk the
+// simulator sends before the error status is folded into that error, not returned as a delta.
+// When fn returns an error, the stream is canceled and that error is returned with the partial
+// result.
+func (c *Client) Stream(ctx context.Context, req *llmv1.ChatCompletionRequest, fn func(Delta) error) (*Result, error) {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	res := &Result{}
+	st, err := c.rpc.ChatCompletionStream(ctx, req)
+	if err != nil {
+		return res.fail(start), convertError(ctx, err, nil)
+	}
+
+	var (
+		choices streamChoices
+		deltas  int
+		failure *llmv1.ChatCompletionChunkResponse
+	)
+	for {
+		chunk, err := st.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			choices.assemble(res)
+			return res.fail(start), convertError(ctx, err, failure)
+		}
+		if res.ID == "" {
+			res.ID = chunk.GetId()
+		}
+		switch events.FinishReason(chunk.GetFinishReason()) {
+		case events.FinishStop, events.FinishLength, events.FinishTimeout:
+			choices.get(chunk.GetIndex()).done = chunk
+		case events.FinishError:
+			failure = chunk
+		default:
+			if chunk.GetText() == "" {
+				continue // e.g. the message_stop chunk under EVENT_NAMING=anthropic
+			}
+			if deltas == 0 {
+				res.TTFT = time.Since(start)
+			}
+			ch := choices.get(chunk.GetIndex())
+			ch.text = append(ch.text, chunk.GetText()...)
+			if fn != nil {
+				if err := fn(Delta{Text: chunk.GetText(), Index: deltas, Choice: int(chunk.GetIndex())}); err != nil {
+					choices.assemble(res)
+					return res.fail(start), err
+				}
+			}
+			deltas++
+		}
+	}
+
+	choices.assemble(res)
+	res.Latency = time.Since(start)
+	if failure != nil {
+		// The status normally follows the failed chunk; a stream that ends cleanly after one still failed.
+		res.FinishReason = string(events.FinishError)
+		return res, failedChunkError(failure)
+	}
+	if len(choices) == 0 {
+		return res.fail(start), ErrNoDone
+	}
+	for _, ch := range choices {
+		if ch.done == nil {
+			return res.fail(start), ErrNoDone
+		}
+	}
+	res.FinishReason = res.Choices[0].FinishReason
+	for _, ch := range choices {
+		if want := ch.done.GetOutputSha256(); want != "" {
+			sum := sha256.Sum256(ch.text)
+			if hex.EncodeToString(sum[:]) != want {
+				return res, ErrDigest
+			}
+		}
+	}
+	return res, nil
+}
+
+// streamChoice is a choice of a stream being assembled.
+type streamChoice struct {
+	index int32
+	text  []byte
+	done  *llmv1.ChatCompletionChunkResponse
+}
+
+// streamChoices are the choices of a stream in the order they first appeared.
+type streamChoices []*streamChoice
+
+// get returns the choice with index, adding it on first sight.
+func (cs *streamChoices) get(index int32) *streamChoice {
+	for _, ch := range *cs {
+		if ch.index == index {
+			return ch
+		}
+	}
+	ch := &streamChoice{index: index}
+	*cs = append(*cs, ch)
+	return ch
+}
+
+// assemble sets the Choices, Text, FinishReason and Usage of r from cs. A choice without a done
+// chunk has no usage and FinishReason events.FinishError.
+func (cs streamChoices) assemble(r *Result) {
+	sorted := slices.SortedFunc(slices.Values(cs), func(a, b *streamChoice) int { return cmp.Compare(a.index, b.index) })
+	r.Choices = make([]Choice, 0, len(sorted))
+	r.Usage = Usage{}
+	for _, ch := range sorted {
+		c := Choice{Index: int(ch.index), Text: string(ch.text), FinishReason: string(events.FinishError)}
+		if d := ch.done; d != nil {
+			c.FinishReason = d.GetFinishReason()
+			c.Usage = Usage{
+				PromptTokens:     int(d.GetPromptTokens()),
+				CompletionTokens: int(d.GetCompletionTokens()),
+				TotalTokens:      int(d.GetTotalTokens()),
+			}
+			r.Usage.PromptTokens = c.Usage.PromptTokens
+			r.Usage.CompletionTokens += c.Usage.CompletionTokens
+		}
+		r.Choices = append(r.Choices, c)
+	}
+	r.Usage.TotalTokens = r.Usage.PromptTokens + r.Usage.CompletionTokens
+	if len(r.Choices) > 0 {
+		r.Text = r.Choices[0].Text
+	}
+}
+
+// fail marks r as a failed result.
+func (r *Result) fail(start time.Time) *Result {
+	r.FinishReason = string(events.FinishError)
+	r.Latency = time.Since(start)
+	return r
+}
+
+// convertError turns the error of a call into ctx's error once ctx is done, or an *Error. The
+// failed chunk, when one came before the status, tells whether the error was injected.
+func convertError(ctx context.Context, err error, failed *llmv1.ChatCompletionChunkResponse) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	st := status.Convert(err)
+	e := &Error{Code: st.Code(), Message: st.Message(), Injected: isInjected(st)}
+	if failed != nil && failed.GetInjected() {
+		e.Injected = true
+	}
+	return e
+}
+
+func failedChunkError(c *llmv1.ChatCompletionChunkResponse) *Error {
+	code := codes.Unknown
+	for cc := codes.OK; cc <= codes.Unauthenticated; cc++ {
+		if cc.String() == c.GetErrorCode() {
+			code = cc
+		}
+	}
+	return &Error{Code: code, Message: c.GetErrorMessage(), Injected: c.GetInjected()}
+}
+
+// isInjected reports whether st carries the ErrorInfo marker the simulator sets on injected errors.
+func isInjected(st *status.Status) bool {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == "llm-simulator" && info.GetReason() == "INJECTED" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/client"
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/simulatortest"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newClient(t *testing.T, cfg simulatortest.Config) *client.Client {
+	t.Helper()
+	return client.New(simulatortest.NewClient(t, cfg))
+}
+
+func TestComplete(t *testing.T) {
+	c := newClient(t, simulatortest.DefaultConfig())
+
+	res, err := c.Complete(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if res.Text == "" || res.FinishReason != string(events.FinishStop) || !strings.HasPrefix(res.ID, "chatcmpl_") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Usage.CompletionTokens == 0 || res.Usage.TotalTokens != res.Usage.PromptTokens+res.Usage.CompletionTokens {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestStream(t *testing.T) {
+	for _, naming := range events.Namings {
+		t.Run(string(naming), func(t *testing.T) {
+			cfg := simulatortest.DefaultConfig()
+			cfg.EventNaming = string(naming)
+			cfg.TTFTMinMs, cfg.TTFTMaxMs = simulatortest.Int(5), simulatortest.Int(5)
+			c := newClient(t, cfg)
+
+			var deltas []client.Delta
+			res, err := c.Stream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 32}, func(d client.Delta) error {
+				deltas = append(deltas, d)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("stream failed: %v", err)
+			}
+			var text strings.Builder
+			for i, d := range deltas {
+				if d.Index != i {
+					t.Fatalf("delta %d has index %d", i, d.Index)
+				}
+				text.WriteString(d.Text)
+			}
+			if len(deltas) < 2 || res.Text != text.String() || res.FinishReason != string(events.FinishStop) {
+				t.Fatalf("unexpected result %+v from %d deltas", res, len(deltas))
+			}
+			if res.Usage.CompletionTokens == 0 || res.ID == "" {
+				t.Fatalf("done chunk not read: %+v", res)
+			}
+			if res.TTFT < 5*time.Millisecond || res.Latency < res.TTFT {
+				t.Fatalf("unexpected timing: ttft=%v latency=%v", res.TTFT, res.Latency)
+			}
+		})
+	}
+}
+
+func TestStreamInjectedError(t *testing.T) {
+	for _, emit := range []bool{true, false} {
+		cfg := simulatortest.DefaultConfig()
+		cfg.ErrorRate, cfg.ErrorMode = 1, "429"
+		cfg.EmitFailedChunk = simulatortest.Bool(emit)
+		c := newClient(t, cfg)
+
+		called := false
+		res, err := c.Stream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello"}, func(client.Delta) error {
+			called = true
+			return nil
+		})
+		var e *client.Error
+		if !errors.As(err, &e) || e.Code != codes.ResourceExhausted || !e.Injected || status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("emit=%t: expected an injected ResourceExhausted error, got %v", emit, err)
+		}
+		if called || res.Text != "" || res.FinishReason != string(events.FinishError) {
+			t.Fatalf("emit=%t: the failed chunk leaked into the result: %+v", emit, res)
+		}
+	}
+
+	_, err := newClient(t, func() simulatortest.Config {
+		cfg := simulatortest.DefaultConfig()
+		cfg.ErrorRate, cfg.ErrorMode = 1, "500"
+		return cfg
+	}()).Complete(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello"})
+	var e *client.Error
+	if !errors.As(err, &e) || e.Code != codes.Internal || !e.Injected {
+		t.Fatalf("expected an injected Internal error from Complete, got %v", err)
+	}
+}
+
+// TestStreamMidStreamFailure runs out of simulated KV cache after a few deltas: the result keeps
+// the text received so far and the error is not marked injected.
+func TestStreamMidStreamFailure(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.MemBytesPerToken, cfg.MemLimitBytes = 1000, 20000
+	c := newClient(t, cfg)
+
+	res, err := c.Stream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 128}, nil)
+	var e *client.Error
+	if !errors.As(err, &e) || e.Code != codes.ResourceExhausted || e.Injected {
+		t.Fatalf("expected a genuine ResourceExhausted error, got %v", err)
+	}
+	if res.Text == "" || res.FinishReason != string(events.FinishError) || res.Usage.CompletionTokens != 0 {
+		t.Fatalf("expected the partial text of a failed stream, got %+v", res)
+	}
+}
+
+func TestStreamCallbackError(t *testing.T) {
+	c := newClient(t, simulatortest.DefaultConfig())
+
+	stop := errors.New("enough")
+	n := 0
+	res, err := c.Stream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 64}, func(client.Delta) error {
+		if n++; n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || n != 2 || res.Text == "" {
+		t.Fatalf("expected the callback error after 2 deltas, got %v after %d (%q)", err, n, res.Text)
+	}
+}
+
+func TestStreamContextCanceled(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.TTFTMinMs, cfg.TTFTMaxMs = simulatortest.Int(500), simulatortest.Int(500)
+	c := newClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, err := c.Stream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hello"}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || res.FinishReason != string(events.FinishError) {
+		t.Fatalf("expected the context error, got %v (%+v)", err, res)
+	}
+}
+
+// TestStreamChoices streams n=2: each choice is assembled and digest-checked on its own.
+func TestStreamChoices(t *testing.T) {
+	c := newClient(t, simulatortest.DefaultConfig())
+
+	texts := map[int]string{}
+	res, err := c.Stream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 32, N: 2}, func(d client.Delta) error {
+		texts[d.Choice] += d.Text
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if len(res.Choices) != 2 {
+		t.Fatalf("expected 2 choices, got %+v", res.Choices)
+	}
+	completion := 0
+	for i, ch := range res.Choices {
+		if ch.Index != i || ch.Text == "" || ch.Text != texts[i] || ch.FinishReason != string(events.FinishStop) {
+			t.Fatalf("unexpected choice %d: %+v (deltas %q)", i, ch, texts[i])
+		}
+		if ch.Usage.CompletionTokens == 0 || ch.Usage.PromptTokens != res.Usage.PromptTokens {
+			t.Fatalf("unexpected usage of choice %d: %+v", i, ch.Usage)
+		}
+		completion += ch.Usage.CompletionTokens
+	}
+	if res.Text != res.Choices[0].Text || res.Usage.CompletionTokens != completion || res.Usage.TotalTokens != res.Usage.PromptTokens+completion {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/bench"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// runBench implements `llm-simulator bench`: it drives a running simulator (or any LlmService) over
+// plaintext gRPC, prints the summary table and returns the exit code, non-zero when the error rate
+// exceeds --max-error-rate.
+func runBench(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("target", "localhost:8787", "gRPC address of the LlmService")
+	concurrency := fs.Int("concurrency", 10, "requests in flight at once")
+	duration := fs.Duration("duration", 10*time.Second, "how long to keep starting requests")
+	streamMode := fs.Bool("stream", false, "call ChatCompletionStream (the default)")
+	unaryMode := fs.Bool("unary", false, "call ChatCompletion instead of ChatCompletionStream")
+	maxTokens := fs.Int("max-tokens", 128, "max_tokens of every request")
+	model := fs.String("model", "", "model of every request")
+	promptFile := fs.String("prompt-file", "", "prompt corpus, one prompt per line, used round-robin")
+	apiKey := fs.String("api-key", "", "API key sent as a bearer token")
+	jsonOut := fs.String("json", "", "also write the report as JSON to this file (- for stdout)")
+	maxErrorRate := fs.Float64("max-error-rate", 1, "exit non-zero when the error rate exceeds this share")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *streamMode && *unaryMode {
+		fmt.Fprintln(stderr, "bench: --stream and --unary are mutually exclusive")
+		return 2
+	}
+	if *concurrency <= 0 || *duration <= 0 {
+		fmt.Fprintln(stderr, "bench: --concurrency and --duration must be positive")
+		return 2
+	}
+
+	opts := bench.Options{
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Stream:      !*unaryMode,
+		MaxTokens:   *maxTokens,
+		Model:       *model,
+		APIKey:      *apiKey,
+	}
+	if *promptFile != "" {
+		prompts, err := bench.LoadPrompts(*promptFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "bench: %v\n", err)
+			return 2
+		}
+		opts.Prompts = prompts
+	}
+
+	conn, err := grpc.NewClient(*target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 2
+	}
+	defer conn.Close()
+
+	// Ctrl-C stops starting requests and cancels the ones in flight; the report covers what ran.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	rep := bench.Run(ctx, llmv1.NewLlmServiceClient(conn), opts)
+
+	if err := rep.WriteTable(stdout); err != nil {
+		fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+	if *jsonOut != "" {
+		if err := writeBenchJSON(*jsonOut, stdout, rep); err != nil {
+			fmt.Fprintf(stderr, "bench: failed to write JSON: %v\n", err)
+			return 1
+		}
+	}
+	if rep.Requests == 0 {
+		fmt.Fprintln(stderr, "bench: no requests completed")
+		return 1
+	}
+	if rep.ErrorRate > *maxErrorRate {
+		fmt.Fprintf(stderr, "bench: error rate %.4f exceeds --max-error-rate %.4f\n", rep.ErrorRate, *maxErrorRate)
+		return 1
+	}
+	return 0
+}
+
+func writeBenchJSON(path string, stdout io.Writer, rep bench.Report) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
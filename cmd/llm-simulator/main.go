@@ -1,29 +1,71 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/yungtweek/llm-simulator/internal/logger"
 
 	"github.com/yungtweek/llm-simulator/internal/config"
 	"github.com/yungtweek/llm-simulator/internal/grpc"
+	"github.com/yungtweek/llm-simulator/internal/stats"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	_ = godotenv.Load()
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	printConfig := flag.Bool("print-config", false, "print the resolved config (.env, environment, preset) as JSON and exit")
+	validate := flag.Bool("validate", false, "like -print-config, then check the config and exit non-zero listing every violation")
+	flag.Parse()
+
+	start := time.Now()
+	env := loadEnvFile()
 
 	cfg := config.LoadConfig()
-	config.ApplyPresetOverrides(&cfg)
+	if *printConfig || *validate {
+		config.ApplyPresetOverrides(&cfg)
+		cfg.RefuseEchoPrompt()
+		os.Exit(checkConfig(os.Stdout, os.Stderr, cfg, *validate))
+	}
 
-	logger.Init(cfg.Profile)
+	if err := logger.Init(logger.Options{Env: cfg.Profile, Level: cfg.LogLevel, Format: cfg.LogFormat, TimestampFormat: cfg.LogTimestampFormat, Output: cfg.LogOutput}); err != nil {
+		fmt.Fprintf(os.Stderr, "llm-simulator: cannot set up logging: %v\n", err)
+		os.Exit(1)
+	}
 	defer logger.Sync()
 
-	addr := fmt.Sprintf(":%d", cfg.Port)
+	// After logger.Init, so the preset and the fields it changed show up in the startup log.
+	config.ApplyPresetOverrides(&cfg)
+	cfg.RefuseEchoPrompt()
+
+	// Refuse to start on config mistakes instead of letting the handlers silently clamp them.
+	if errs := config.Validate(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Log.Errorw("[llm-simulator] invalid config", "err", err)
+		}
+		logger.Log.Fatalw("[llm-simulator] refusing to start with an invalid config", "violations", len(errs))
+	}
+
+	addr := cfg.GRPCAddr
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", cfg.Port)
+	}
 	logger.Log.Infow(
 		"starting gRPC server",
 		"addr", addr,
@@ -34,29 +76,345 @@ func main() {
 		"ttftMinMs", cfg.TTFTMinMs,
 		"ttftMaxMs", cfg.TTFTMaxMs,
 		"tokensPerSec", cfg.TokensPerSec,
+		"rejectShortDeadlines", cfg.RejectShortDeadlines,
+		"maxRequestDurationMs", cfg.MaxRequestDurationMs,
 		"errorRate", cfg.ErrorRate,
 		"errorMode", cfg.ErrorMode,
+		"httpResetRate", cfg.HTTPResetRate,
 		"chunkSize", cfg.ChunkSize,
 		"streamDelayMinMs", cfg.StreamDelayMinMs,
 		"streamDelayMaxMs", cfg.StreamDelayMaxMs,
 		"debugOutputChars", cfg.DebugOutputChars,
 		"maxOutputChars", cfg.MaxOutputChars,
 		"strictTokenMode", cfg.StrictTokenMode,
+		"httpEnabled", cfg.HTTPEnabled,
+		"httpPort", cfg.HTTPPort,
+		"singlePort", cfg.SinglePort,
+		"accessLog", cfg.AccessLog,
+		"recordFile", cfg.RecordFile,
+		"replayFile", cfg.ReplayFile,
+		"scenarioFile", cfg.ScenarioFile,
+		"faultSchedule", cfg.FaultSchedule,
+		"tenantProfiles", cfg.TenantProfiles != "",
+		"azureCompat", cfg.AzureCompat,
+		"grpcWeb", cfg.GRPCWebEnabled,
+		"grpcCompression", cfg.GRPCCompression,
+		"grpcMaxRecvMB", cfg.GRPCMaxRecvMB,
+		"grpcMaxSendMB", cfg.GRPCMaxSendMB,
+		"grpcMaxConcurrentStreams", cfg.GRPCMaxConcurrentStreams,
+		"grpcReflection", config.Or(cfg.GRPCReflection, true),
+		"keepaliveTimeMs", cfg.KeepaliveTimeMs,
+		"keepaliveMaxAgeMs", cfg.KeepaliveMaxAgeMs,
+		"connMaxAgeS", cfg.ConnMaxAgeS,
+		"forcedRestartIntervalS", cfg.ForcedRestartIntervalS,
+		"authEnabled", len(cfg.APIKeys) > 0,
+		"keyRPM", cfg.KeyRPM,
+		"keyTPM", cfg.KeyTPM,
+		"peerRPM", cfg.PeerRPM,
+		"shutdownGraceMs", cfg.ShutdownGraceMs,
+		"pprof", cfg.PprofEnabled,
+		"tls", cfg.TLSCertFile != "",
+		"mtls", cfg.TLSClientCAFile != "",
 	)
 
 	svc := grpc.NewMockLlmService(cfg)
-	srv := grpc.NewGRPCServer(addr, svc)
+	var rec *grpc.Recorder
+	if cfg.RecordFile != "" {
+		var err error
+		if rec, err = grpc.NewRecorder(cfg.RecordFile, cfg.RecordBuffer); err != nil {
+			logger.Log.Fatalw("[llm-simulator] cannot open RECORD_FILE", "path", cfg.RecordFile, "err", err)
+		}
+		svc.SetRecorder(rec)
+	}
+	if cfg.ReplayFile != "" {
+		replay, err := grpc.LoadReplay(cfg.ReplayFile)
+		if err != nil {
+			logger.Log.Fatalw("[llm-simulator] cannot load REPLAY_FILE", "path", cfg.ReplayFile, "err", err)
+		}
+		logger.Log.Infow("[llm-simulator] replaying traces", "path", cfg.ReplayFile, "traces", replay.Len(), "select", cfg.ReplaySelect)
+		svc.SetReplayer(replay)
+	}
+	var scenario *grpc.Scenario
+	if cfg.ScenarioFile != "" {
+		var err error
+		if scenario, err = grpc.LoadScenario(cfg.ScenarioFile, cfg); err != nil {
+			logger.Log.Fatalw("[llm-simulator] cannot load SCENARIO_FILE", "path", cfg.ScenarioFile, "err", err)
+		}
+	}
+	var faults *grpc.FaultSchedule
+	if cfg.FaultSchedule != "" {
+		var err error
+		if faults, err = grpc.LoadFaultSchedule(cfg.FaultSchedule); err != nil {
+			logger.Log.Fatalw("[llm-simulator] cannot load FAULT_SCHEDULE", "path", cfg.FaultSchedule, "err", err)
+		}
+	}
+	if cfg.TenantProfiles != "" {
+		tenants, err := grpc.LoadTenants(cfg)
+		if err != nil {
+			logger.Log.Fatalw("[llm-simulator] cannot load TENANT_PROFILES", "err", err)
+		}
+		logger.Log.Infow("[llm-simulator] tenant profiles", "tenants", tenants.Names(), "unknown", cfg.TenantUnknown)
+		svc.SetTenants(tenants)
+	}
+	limits := grpc.NewLimits(cfg)
+	listeners := 1
+	if cfg.HTTPEnabled && !cfg.SinglePort {
+		listeners++
+	}
+	ready := grpc.NewReadiness(listeners)
+	opts, err := grpc.ServerOptions(cfg, limits, ready)
+	if err != nil {
+		logger.Log.Fatalw("[llm-simulator] invalid gRPC server options", "err", err)
+	}
+	srv := grpc.NewGRPCServer(addr, svc, opts...)
+	srv.SetReadiness(ready)
+	svc.SetReadiness(ready)
+	srv.RegisterAdmin(svc)
+	srv.SetUnixSocketMode(cfg.UnixSocketMode)
+	srv.SetForcedRestartInterval(time.Duration(cfg.ForcedRestartIntervalS) * time.Second)
+
+	var grpcWeb http.Handler
+	if cfg.GRPCWebEnabled {
+		grpcWeb = srv.GRPCWebHandler()
+	}
+
+	// In SINGLE_PORT mode one HTTP server on the gRPC address serves both protocols and srv is never
+	// Run; otherwise gRPC and the optional HTTP endpoints listen separately.
+	var httpSrv *grpc.HTTPServer
+	switch {
+	case cfg.SinglePort:
+		if cfg.TLSCertFile != "" {
+			logger.Log.Fatalw("[llm-simulator] SINGLE_PORT does not support TLS_CERT_FILE")
+		}
+		if cfg.ForcedRestartIntervalS > 0 {
+			logger.Log.Warnw("[llm-simulator] FORCED_RESTART_INTERVAL_S is ignored in SINGLE_PORT mode")
+		}
+		httpSrv = grpc.NewSinglePortServer(addr, srv, grpc.NewLiveHTTPMux(svc, grpcWeb, limits, ready))
+	case cfg.HTTPEnabled:
+		httpAddr := cfg.HTTPAddr
+		if httpAddr == "" {
+			httpAddr = fmt.Sprintf(":%d", cfg.HTTPPort)
+		}
+		httpSrv = grpc.NewHTTPServer(httpAddr, grpc.NewLiveHTTPMux(svc, grpcWeb, limits, ready))
+	}
+	if httpSrv != nil {
+		httpSrv.SetReadiness(ready)
+		httpSrv.SetUnixSocketMode(cfg.UnixSocketMode)
+		if err := httpSrv.Listen(); err != nil {
+			logger.Log.Fatalw("[llm-simulator] http listen error", "err", err)
+		}
+		logger.Log.Infow("[llm-simulator] http listening", "addr", httpSrv.Addr().String(), "singlePort", cfg.SinglePort)
+	}
+	// The admin listener is independent of HTTP_ENABLED and stays out of readiness.
+	var adminSrv *grpc.HTTPServer
+	if cfg.PprofEnabled || cfg.LogLevelEndpoint {
+		adminSrv = grpc.NewHTTPServer(cfg.AdminAddr, grpc.NewAdminMux(cfg))
+		if err := adminSrv.Listen(); err != nil {
+			logger.Log.Fatalw("[llm-simulator] admin listen error", "err", err)
+		}
+		logger.Log.Infow("[llm-simulator] admin listening", "addr", adminSrv.Addr().String(), "pprof", cfg.PprofEnabled, "logLevelEndpoint", cfg.LogLevelEndpoint)
+	}
+	if !cfg.SinglePort {
+		if err := srv.Listen(); err != nil {
+			logger.Log.Fatalw("[llm-simulator] grpc listen error", "err", err)
+		}
+		logger.Log.Infow("[llm-simulator] grpc listening", "addr", srv.Addr().String())
+	}
 
-	// Handle SIGINT/SIGTERM for a clean shutdown in local dev / docker.
+	handleStatsSignals(svc.Config(), start)
+	stopStatsLog := logStatsIntervals(time.Duration(cfg.StatsLogIntervalS) * time.Second)
+	handleReloadSignal(env, svc.Config())
+	if scenario != nil {
+		go scenario.Run(context.Background(), svc.Config(), cfg.ScenarioLoop)
+	}
+	if faults != nil {
+		grpc.SetFaultSchedule(faults)
+	}
+
+	// Handle SIGINT/SIGTERM for a clean shutdown in local dev / docker: stop advertising readiness,
+	// let in-flight streams drain for SHUTDOWN_GRACE_MS, then stop forcibly.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-sigCh
-		logger.Log.Info("[llm-simulator] shutting down...")
-		srv.GracefulStop()
+		logger.Log.Infow("[llm-simulator] shutting down...", "graceMs", cfg.ShutdownGraceMs)
+		ready.Drain()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownGraceMs)*time.Millisecond)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		if httpSrv != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = httpSrv.Shutdown(ctx)
+			}()
+		}
+		if !cfg.SinglePort {
+			_ = srv.Shutdown(ctx)
+		}
+		wg.Wait()
+		stopStatsLog()
+		// Requests are done (or cut off): write out what they recorded.
+		if rec != nil {
+			_ = rec.Close()
+		}
+		if adminSrv != nil {
+			_ = adminSrv.Shutdown(ctx)
+		}
+	}()
+
+	if adminSrv != nil {
+		go func() {
+			if err := adminSrv.Run(); err != nil {
+				logger.Log.Fatalw("[llm-simulator] admin server error", "err", err)
+			}
+		}()
+	}
+	if cfg.SinglePort {
+		if err := httpSrv.Run(); err != nil {
+			logger.Log.Fatalw("[llm-simulator] server error", "err", err)
+		}
+	} else {
+		if httpSrv != nil {
+			go func() {
+				if err := httpSrv.Run(); err != nil {
+					logger.Log.Fatalw("[llm-simulator] http server error", "err", err)
+				}
+			}()
+		}
+		if err := srv.Run(); err != nil {
+			logger.Log.Fatalw("[llm-simulator] server error", "err", err)
+		}
+	}
+	<-stopped
+	reportSummary(cfg, start)
+}
+
+// handleStatsSignals logs a stats summary on SIGUSR1 and resets the counters on SIGUSR2. os/signal
+// never blocks on delivery (a signal arriving mid-dump is dropped), so logging happens here rather
+// than on the signal path. The dump includes the config currently in effect.
+func handleStatsSignals(live *config.Holder, start time.Time) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+	dumper := stats.NewDumper(start)
+	go func() {
+		for sig := range sigCh {
+			if sig == syscall.SIGUSR2 {
+				stats.Default.Snapshot(true)
+				logger.Log.Infow("[llm-simulator] stats reset")
+				continue
+			}
+			fields := dumper.Fields(stats.Default.Snapshot(false), time.Now())
+			logger.Log.Infow("[llm-simulator] stats", append(fields, "config", live.Load().Redacted())...)
+		}
+	}()
+}
+
+// logStatsIntervals starts logging a summary of every interval (STATS_LOG_INTERVAL_S; 0 disables)
+// and returns a function stopping it, which waits for the reporter to exit.
+func logStatsIntervals(every time.Duration) (stop func()) {
+	if every <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stats.Default.LogIntervals(ctx, every)
 	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkConfig implements -print-config and -validate: it writes cfg as JSON (API keys masked) to
+// stdout and, when validate is set, lists every violation on stderr. It returns the exit code.
+func checkConfig(stdout, stderr io.Writer, cfg config.Config, validate bool) int {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		fmt.Fprintf(stderr, "failed to print config: %v\n", err)
+		return 1
+	}
+	if !validate {
+		return 0
+	}
+	errs := config.Validate(cfg)
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "invalid config: %v\n", err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
 
-	if err := srv.Run(); err != nil {
-		logger.Log.Fatalw("[llm-simulator] server error", "err", err)
+// envFile tracks the variables loaded from .env. Like godotenv.Load, variables already set in the
+// process environment take precedence; reload re-reads the file so edits to it apply.
+type envFile struct {
+	inherited map[string]bool // set before .env was read
+	loaded    map[string]bool // set from .env
+}
+
+func loadEnvFile() *envFile {
+	e := &envFile{inherited: map[string]bool{}, loaded: map[string]bool{}}
+	for _, kv := range os.Environ() {
+		k, _, _ := strings.Cut(kv, "=")
+		e.inherited[k] = true
+	}
+	e.reload()
+	return e
+}
+
+func (e *envFile) reload() {
+	vals, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Log.Warnw("[llm-simulator] failed to read .env", "err", err)
+		return
+	}
+	for k := range e.loaded {
+		if _, ok := vals[k]; !ok {
+			_ = os.Unsetenv(k)
+			delete(e.loaded, k)
+		}
+	}
+	for k, v := range vals {
+		if !e.inherited[k] {
+			_ = os.Setenv(k, v)
+			e.loaded[k] = true
+		}
+	}
+}
+
+// handleReloadSignal re-reads .env and the environment on SIGHUP and swaps the result into live
+// (see config.Holder.ReloadFromEnv); settings that need a restart are kept and logged.
+func handleReloadSignal(env *envFile, live *config.Holder) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			logger.Log.Infow("[llm-simulator] SIGHUP, reloading config")
+			env.reload()
+			_ = live.ReloadFromEnv()
+		}
+	}()
+}
+
+// reportSummary logs the end-of-run summary once streams have drained and writes it to
+// SUMMARY_FILE when set.
+func reportSummary(cfg config.Config, start time.Time) {
+	sum := stats.NewSummary(stats.Default.Snapshot(false), start, time.Now())
+	logger.Log.Infow("[llm-simulator] run summary", sum.Fields()...)
+	if cfg.SummaryFile == "" {
+		return
+	}
+	if err := sum.WriteFile(cfg.SummaryFile); err != nil {
+		logger.Log.Errorw("[llm-simulator] failed to write summary", "path", cfg.SummaryFile, "err", err)
+		return
 	}
+	logger.Log.Infow("[llm-simulator] summary written", "path", cfg.SummaryFile)
 }
@@ -0,0 +1,80 @@
+// Package events defines the chunk types and finish reasons the simulator sets in the Type and
+// FinishReason fields of its gRPC stream chunks (ChatCompletionChunkResponse), for each EVENT_NAMING,
+// so clients can import them instead of declaring the strings again.
+package events
+
+// Naming is an EVENT_NAMING value: the provider whose event names the stream chunks follow.
+type Naming string
+
+const (
+	// OpenAIResponses names chunks like the OpenAI Responses API (the default).
+	OpenAIResponses Naming = "openai-responses"
+	// OpenAIChat names every chunk "chunk", like Chat Completions: the finish reason marks the end.
+	OpenAIChat Naming = "openai-chat"
+	// Anthropic names chunks like the Anthropic Messages API.
+	Anthropic Naming = "anthropic"
+)
+
+// Namings lists every Naming.
+var Namings = []Naming{OpenAIResponses, OpenAIChat, Anthropic}
+
+// Type is the Type of a stream chunk.
+type Type string
+
+const (
+	OutputTextDelta   Type = "output_text.delta"
+	OutputTextDone    Type = "output_text.done"
+	Failed            Type = "failed"
+	Chunk             Type = "chunk"
+	MessageStart      Type = "message_start" // SSE only: the role chunk under Anthropic
+	ContentBlockDelta Type = "content_block_delta"
+	MessageDelta      Type = "message_delta"
+	MessageStop       Type = "message_stop"
+	Error             Type = "error"
+	Ping              Type = "ping" // gRPC keep-alive chunks (GRPC_KEEPALIVE_CHUNK_MS); no text, not counted
+)
+
+// Types lists every Type.
+var Types = []Type{OutputTextDelta, OutputTextDone, Failed, Chunk, MessageStart, ContentBlockDelta, MessageDelta, MessageStop, Error, Ping}
+
+// FinishReason is the FinishReason of a done or failed chunk; delta chunks have none.
+type FinishReason string
+
+const (
+	// FinishStop ends a completed stream (and a unary response).
+	FinishStop FinishReason = "stop"
+	// FinishError marks the failed chunk; the error is in its ErrorCode, ErrorMessage and Injected.
+	FinishError FinishReason = "error"
+	// FinishLength ends a response cut short, by default when MAX_REQUEST_DURATION_MS stops it.
+	FinishLength FinishReason = "length"
+	// FinishTimeout ends a response cut by MAX_REQUEST_DURATION_MS with
+	// MAX_REQUEST_DURATION_FINISH_REASON=timeout.
+	FinishTimeout FinishReason = "timeout"
+)
+
+// FinishReasons lists every FinishReason.
+var FinishReasons = []FinishReason{FinishStop, FinishError, FinishLength, FinishTimeout}
+
+// Set holds the chunk types of one Naming. A stream sends Delta chunks, then the Done chunk with the
+// finish reason and usage, then a Stop chunk when the naming has one. A stream that fails sends the
+// Failed chunk before its error status instead.
+type Set struct {
+	Delta  Type
+	Done   Type
+	Stop   Type // "" when the naming ends with Done
+	Failed Type
+}
+
+var sets = map[Naming]Set{
+	OpenAIResponses: {Delta: OutputTextDelta, Done: OutputTextDone, Failed: Failed},
+	OpenAIChat:      {Delta: Chunk, Done: Chunk, Failed: Chunk},
+	Anthropic:       {Delta: ContentBlockDelta, Done: MessageDelta, Stop: MessageStop, Failed: Error},
+}
+
+// For returns the chunk types of n; unknown and empty namings get the OpenAIResponses ones.
+func For(n Naming) Set {
+	if s, ok := sets[n]; ok {
+		return s
+	}
+	return sets[OpenAIResponses]
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"slices"
+	"testing"
+)
+
+// The wire value of every constant. A map literal with two equal constant keys doesn't compile, so
+// two constants can't share a value; TestExhaustive checks these maps against Types and
+// FinishReasons, so a constant missing from either list fails.
+var (
+	typeValues = map[Type]string{
+		OutputTextDelta:   "output_text.delta",
+		OutputTextDone:    "output_text.done",
+		Failed:            "failed",
+		Chunk:             "chunk",
+		MessageStart:      "message_start",
+		ContentBlockDelta: "content_block_delta",
+		MessageDelta:      "message_delta",
+		MessageStop:       "message_stop",
+		Error:             "error",
+		Ping:              "ping",
+	}
+	finishReasonValues = map[FinishReason]string{
+		FinishStop:    "stop",
+		FinishError:   "error",
+		FinishLength:  "length",
+		FinishTimeout: "timeout",
+	}
+)
+
+func TestExhaustive(t *testing.T) {
+	if len(Types) != len(typeValues) {
+		t.Fatalf("Types lists %d types, %d are defined", len(Types), len(typeValues))
+	}
+	for _, typ := range Types {
+		if v, ok := typeValues[typ]; !ok || string(typ) != v {
+			t.Fatalf("unexpected type %q in Types", typ)
+		}
+	}
+	if len(FinishReasons) != len(finishReasonValues) {
+		t.Fatalf("FinishReasons lists %d reasons, %d are defined", len(FinishReasons), len(finishReasonValues))
+	}
+	for _, r := range FinishReasons {
+		if v, ok := finishReasonValues[r]; !ok || string(r) != v {
+			t.Fatalf("unexpected finish reason %q in FinishReasons", r)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	for _, n := range Namings {
+		s := For(n)
+		for _, typ := range []Type{s.Delta, s.Done, s.Failed} {
+			if !slices.Contains(Types, typ) {
+				t.Fatalf("%s: chunk type %q is not in Types", n, typ)
+			}
+		}
+		if s.Stop != "" && !slices.Contains(Types, s.Stop) {
+			t.Fatalf("%s: stop type %q is not in Types", n, s.Stop)
+		}
+	}
+	if For("") != For(OpenAIResponses) || For("gemini") != For(OpenAIResponses) {
+		t.Fatal("unknown namings should fall back to openai-responses")
+	}
+}
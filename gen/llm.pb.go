@@ -22,13 +22,17 @@ const (
 )
 
 type RequestMeta struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-	TraceId       string                 `protobuf:"bytes,2,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
-	SessionId     string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	UserId        string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	TraceId   string                 `protobuf:"bytes,2,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	SessionId string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	UserId    string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// The caller's own correlation ID, echoed verbatim as client_request_id on every chunk and
+	// response, in the x-client-request-id response header and in the request's log lines. It wins
+	// over x-client-request-id metadata, which does the same.
+	ClientRequestId string `protobuf:"bytes,5,opt,name=client_request_id,json=clientRequestId,proto3" json:"client_request_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *RequestMeta) Reset() {
@@ -89,6 +93,13 @@ func (x *RequestMeta) GetUserId() string {
 	return ""
 }
 
+func (x *RequestMeta) GetClientRequestId() string {
+	if x != nil {
+		return x.ClientRequestId
+	}
+	return ""
+}
+
 type ChatMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Role          string                 `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
@@ -151,10 +162,19 @@ type ChatCompletionRequest struct {
 	UserPrompt   string `protobuf:"bytes,4,opt,name=user_prompt,json=userPrompt,proto3" json:"user_prompt,omitempty"`
 	// Optional context as a list of prior messages
 	Context []*ChatMessage `protobuf:"bytes,5,rep,name=context,proto3" json:"context,omitempty"`
-	// Sampling params (mock can ignore most except max_tokens)
-	Temperature   float64 `protobuf:"fixed64,6,opt,name=temperature,proto3" json:"temperature,omitempty"`
-	MaxTokens     int32   `protobuf:"varint,7,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
-	TopP          float64 `protobuf:"fixed64,8,opt,name=top_p,json=topP,proto3" json:"top_p,omitempty"`
+	// Sampling params (mock can ignore most except max_tokens). STRICT_VALIDATION rejects values out
+	// of range with INVALID_ARGUMENT: temperature [0, 2], top_p [0, 1], penalties [-2, 2].
+	Temperature      float64 `protobuf:"fixed64,6,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	MaxTokens        int32   `protobuf:"varint,7,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	TopP             float64 `protobuf:"fixed64,8,opt,name=top_p,json=topP,proto3" json:"top_p,omitempty"`
+	PresencePenalty  float64 `protobuf:"fixed64,9,opt,name=presence_penalty,json=presencePenalty,proto3" json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64 `protobuf:"fixed64,10,opt,name=frequency_penalty,json=frequencyPenalty,proto3" json:"frequency_penalty,omitempty"`
+	// Number of choices to generate; 0 means 1. ChatCompletionStream interleaves their deltas
+	// (CHOICE_INTERLEAVE), each chunk carrying its choice's index, and ends each choice with its own
+	// done event. ChatCompletion and replayed traces return a single choice.
+	N int32 `protobuf:"varint,11,opt,name=n,proto3" json:"n,omitempty"`
+	// Overrides of the simulator config for this request alone (see MockOverrides).
+	MockOverrides *MockOverrides `protobuf:"bytes,12,opt,name=mock_overrides,json=mockOverrides,proto3" json:"mock_overrides,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -245,6 +265,225 @@ func (x *ChatCompletionRequest) GetTopP() float64 {
 	return 0
 }
 
+func (x *ChatCompletionRequest) GetPresencePenalty() float64 {
+	if x != nil {
+		return x.PresencePenalty
+	}
+	return 0
+}
+
+func (x *ChatCompletionRequest) GetFrequencyPenalty() float64 {
+	if x != nil {
+		return x.FrequencyPenalty
+	}
+	return 0
+}
+
+func (x *ChatCompletionRequest) GetN() int32 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+func (x *ChatCompletionRequest) GetMockOverrides() *MockOverrides {
+	if x != nil {
+		return x.MockOverrides
+	}
+	return nil
+}
+
+// Per-request overrides of the simulator config, the typed form of the x-mock-* metadata and of the
+// "mock" block of the HTTP bodies. Unset fields keep the config value. They take precedence over
+// x-mock-* metadata, which takes precedence over the config and its PRESET. Values out of range fail
+// the request with INVALID_ARGUMENT. Ignored when ALLOW_REQUEST_OVERRIDES is off.
+type MockOverrides struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	BaseDelayMs     *int32                 `protobuf:"varint,1,opt,name=base_delay_ms,json=baseDelayMs,proto3,oneof" json:"base_delay_ms,omitempty"`
+	JitterMs        *int32                 `protobuf:"varint,2,opt,name=jitter_ms,json=jitterMs,proto3,oneof" json:"jitter_ms,omitempty"`
+	TtftMinMs       *int32                 `protobuf:"varint,3,opt,name=ttft_min_ms,json=ttftMinMs,proto3,oneof" json:"ttft_min_ms,omitempty"`
+	TtftMaxMs       *int32                 `protobuf:"varint,4,opt,name=ttft_max_ms,json=ttftMaxMs,proto3,oneof" json:"ttft_max_ms,omitempty"`
+	PerTokenDelayMs *int32                 `protobuf:"varint,5,opt,name=per_token_delay_ms,json=perTokenDelayMs,proto3,oneof" json:"per_token_delay_ms,omitempty"`
+	ErrorRate       *float64               `protobuf:"fixed64,6,opt,name=error_rate,json=errorRate,proto3,oneof" json:"error_rate,omitempty"`
+	ErrorMode       *string                `protobuf:"bytes,7,opt,name=error_mode,json=errorMode,proto3,oneof" json:"error_mode,omitempty"` // mixed|429|500
+	ChunkSize       *int32                 `protobuf:"varint,8,opt,name=chunk_size,json=chunkSize,proto3,oneof" json:"chunk_size,omitempty"`
+	TokensPerSec    *float64               `protobuf:"fixed64,9,opt,name=tokens_per_sec,json=tokensPerSec,proto3,oneof" json:"tokens_per_sec,omitempty"`
+	// Draws the request's random values (output length, delays, error injection) from a source
+	// seeded with seed, so the request behaves the same on every run whatever else the server serves.
+	Seed          *int64 `protobuf:"varint,10,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MockOverrides) Reset() {
+	*x = MockOverrides{}
+	mi := &file_llm_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MockOverrides) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MockOverrides) ProtoMessage() {}
+
+func (x *MockOverrides) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MockOverrides.ProtoReflect.Descriptor instead.
+func (*MockOverrides) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MockOverrides) GetBaseDelayMs() int32 {
+	if x != nil && x.BaseDelayMs != nil {
+		return *x.BaseDelayMs
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetJitterMs() int32 {
+	if x != nil && x.JitterMs != nil {
+		return *x.JitterMs
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetTtftMinMs() int32 {
+	if x != nil && x.TtftMinMs != nil {
+		return *x.TtftMinMs
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetTtftMaxMs() int32 {
+	if x != nil && x.TtftMaxMs != nil {
+		return *x.TtftMaxMs
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetPerTokenDelayMs() int32 {
+	if x != nil && x.PerTokenDelayMs != nil {
+		return *x.PerTokenDelayMs
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetErrorRate() float64 {
+	if x != nil && x.ErrorRate != nil {
+		return *x.ErrorRate
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetErrorMode() string {
+	if x != nil && x.ErrorMode != nil {
+		return *x.ErrorMode
+	}
+	return ""
+}
+
+func (x *MockOverrides) GetChunkSize() int32 {
+	if x != nil && x.ChunkSize != nil {
+		return *x.ChunkSize
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetTokensPerSec() float64 {
+	if x != nil && x.TokensPerSec != nil {
+		return *x.TokensPerSec
+	}
+	return 0
+}
+
+func (x *MockOverrides) GetSeed() int64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+// The sampling params of the request, echoed back so clients can check they were sent
+type Sampling struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Temperature      float64                `protobuf:"fixed64,1,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	TopP             float64                `protobuf:"fixed64,2,opt,name=top_p,json=topP,proto3" json:"top_p,omitempty"`
+	PresencePenalty  float64                `protobuf:"fixed64,3,opt,name=presence_penalty,json=presencePenalty,proto3" json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64                `protobuf:"fixed64,4,opt,name=frequency_penalty,json=frequencyPenalty,proto3" json:"frequency_penalty,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Sampling) Reset() {
+	*x = Sampling{}
+	mi := &file_llm_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sampling) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sampling) ProtoMessage() {}
+
+func (x *Sampling) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sampling.ProtoReflect.Descriptor instead.
+func (*Sampling) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Sampling) GetTemperature() float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *Sampling) GetTopP() float64 {
+	if x != nil {
+		return x.TopP
+	}
+	return 0
+}
+
+func (x *Sampling) GetPresencePenalty() float64 {
+	if x != nil {
+		return x.PresencePenalty
+	}
+	return 0
+}
+
+func (x *Sampling) GetFrequencyPenalty() float64 {
+	if x != nil {
+		return x.FrequencyPenalty
+	}
+	return 0
+}
+
 type ChatCompletionResponse struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
 	OutputText       string                 `protobuf:"bytes,1,opt,name=output_text,json=outputText,proto3" json:"output_text,omitempty"`
@@ -253,13 +492,28 @@ type ChatCompletionResponse struct {
 	CompletionTokens int32                  `protobuf:"varint,4,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
 	TotalTokens      int32                  `protobuf:"varint,5,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
 	LatencyMs        int64                  `protobuf:"varint,6,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	// Measured timing breakdown (what was actually slept, not the configured ranges)
+	QueueMs         int64     `protobuf:"varint,7,opt,name=queue_ms,json=queueMs,proto3" json:"queue_ms,omitempty"`                            // base + jitter delay
+	PromptEvalMs    int64     `protobuf:"varint,8,opt,name=prompt_eval_ms,json=promptEvalMs,proto3" json:"prompt_eval_ms,omitempty"`           // simulated prefill (TTFT draw)
+	TtftMs          int64     `protobuf:"varint,9,opt,name=ttft_ms,json=ttftMs,proto3" json:"ttft_ms,omitempty"`                               // handler start -> first token
+	GenerationMs    int64     `protobuf:"varint,10,opt,name=generation_ms,json=generationMs,proto3" json:"generation_ms,omitempty"`            // first token -> completion
+	SimulatedTtftMs int64     `protobuf:"varint,11,opt,name=simulated_ttft_ms,json=simulatedTtftMs,proto3" json:"simulated_ttft_ms,omitempty"` // queue + prefill as slept, without the handler's own overhead
+	Sampling        *Sampling `protobuf:"bytes,12,opt,name=sampling,proto3" json:"sampling,omitempty"`
+	// Response identity, as on the HTTP endpoints: a generated id, the unix time in seconds, and a
+	// fingerprint of the simulator build and preset (it changes when the simulated behavior does)
+	Id                string `protobuf:"bytes,13,opt,name=id,proto3" json:"id,omitempty"`
+	Created           int64  `protobuf:"varint,14,opt,name=created,proto3" json:"created,omitempty"`
+	SystemFingerprint string `protobuf:"bytes,15,opt,name=system_fingerprint,json=systemFingerprint,proto3" json:"system_fingerprint,omitempty"`
+	// The request's client request ID (see RequestMeta); empty when it set none, or the server's
+	// request ID with CLIENT_REQUEST_ID_FALLBACK
+	ClientRequestId string `protobuf:"bytes,16,opt,name=client_request_id,json=clientRequestId,proto3" json:"client_request_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *ChatCompletionResponse) Reset() {
 	*x = ChatCompletionResponse{}
-	mi := &file_llm_proto_msgTypes[3]
+	mi := &file_llm_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -271,7 +525,7 @@ func (x *ChatCompletionResponse) String() string {
 func (*ChatCompletionResponse) ProtoMessage() {}
 
 func (x *ChatCompletionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_llm_proto_msgTypes[3]
+	mi := &file_llm_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -284,7 +538,7 @@ func (x *ChatCompletionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatCompletionResponse.ProtoReflect.Descriptor instead.
 func (*ChatCompletionResponse) Descriptor() ([]byte, []int) {
-	return file_llm_proto_rawDescGZIP(), []int{3}
+	return file_llm_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ChatCompletionResponse) GetOutputText() string {
@@ -329,6 +583,76 @@ func (x *ChatCompletionResponse) GetLatencyMs() int64 {
 	return 0
 }
 
+func (x *ChatCompletionResponse) GetQueueMs() int64 {
+	if x != nil {
+		return x.QueueMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetPromptEvalMs() int64 {
+	if x != nil {
+		return x.PromptEvalMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetTtftMs() int64 {
+	if x != nil {
+		return x.TtftMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetGenerationMs() int64 {
+	if x != nil {
+		return x.GenerationMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetSimulatedTtftMs() int64 {
+	if x != nil {
+		return x.SimulatedTtftMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetSampling() *Sampling {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *ChatCompletionResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ChatCompletionResponse) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *ChatCompletionResponse) GetSystemFingerprint() string {
+	if x != nil {
+		return x.SystemFingerprint
+	}
+	return ""
+}
+
+func (x *ChatCompletionResponse) GetClientRequestId() string {
+	if x != nil {
+		return x.ClientRequestId
+	}
+	return ""
+}
+
 type ChatCompletionChunkResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Responses-style event type
@@ -336,20 +660,54 @@ type ChatCompletionChunkResponse struct {
 	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
 	// Streaming payload
 	Text string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"` // delta text for *.delta events
-	// Completion metadata (set on done event)
-	FinishReason     string `protobuf:"bytes,3,opt,name=finish_reason,json=finishReason,proto3" json:"finish_reason,omitempty"`
-	Index            int32  `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
-	PromptTokens     int32  `protobuf:"varint,5,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
-	CompletionTokens int32  `protobuf:"varint,6,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
-	TotalTokens      int32  `protobuf:"varint,7,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
-	LatencyMs        int64  `protobuf:"varint,8,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	// Completion metadata (set on done event): "stop", or "error" on the failed event
+	FinishReason string `protobuf:"bytes,3,opt,name=finish_reason,json=finishReason,proto3" json:"finish_reason,omitempty"`
+	Index        int32  `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
+	// Usage and latency, on the done event; on the failed event, what the stream delivered (the
+	// completion tokens of the deltas sent)
+	PromptTokens     int32 `protobuf:"varint,5,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int32 `protobuf:"varint,6,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	TotalTokens      int32 `protobuf:"varint,7,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	LatencyMs        int64 `protobuf:"varint,8,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	// Measured timing breakdown (set on done event)
+	QueueMs      int64 `protobuf:"varint,9,opt,name=queue_ms,json=queueMs,proto3" json:"queue_ms,omitempty"`                   // base + jitter delay
+	PromptEvalMs int64 `protobuf:"varint,10,opt,name=prompt_eval_ms,json=promptEvalMs,proto3" json:"prompt_eval_ms,omitempty"` // simulated prefill (TTFT draw)
+	TtftMs       int64 `protobuf:"varint,11,opt,name=ttft_ms,json=ttftMs,proto3" json:"ttft_ms,omitempty"`                     // handler start -> first delta sent
+	GenerationMs int64 `protobuf:"varint,12,opt,name=generation_ms,json=generationMs,proto3" json:"generation_ms,omitempty"`   // first delta sent -> done
+	// Set on the "failed" event, sent before the error status (EMIT_FAILED_CHUNK)
+	ErrorCode    string `protobuf:"bytes,13,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`          // gRPC code of the error, e.g. "ResourceExhausted"
+	Injected     bool   `protobuf:"varint,14,opt,name=injected,proto3" json:"injected,omitempty"`                            // the error was injected (ERROR_RATE), not a genuine failure
+	ErrorMessage string `protobuf:"bytes,15,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"` // status message of the error (finish_reason carried it before)
+	// Set on the done event: digest of the concatenated delta texts, to verify reassembly
+	OutputSha256 string `protobuf:"bytes,16,opt,name=output_sha256,json=outputSha256,proto3" json:"output_sha256,omitempty"` // hex SHA-256
+	OutputBytes  int64  `protobuf:"varint,17,opt,name=output_bytes,json=outputBytes,proto3" json:"output_bytes,omitempty"`
+	// Server send timestamps (CHUNK_TIMESTAMPS): every event, and the first delta's on the done event
+	EmittedAtUnixMs    int64 `protobuf:"varint,18,opt,name=emitted_at_unix_ms,json=emittedAtUnixMs,proto3" json:"emitted_at_unix_ms,omitempty"`
+	FirstDeltaAtUnixMs int64 `protobuf:"varint,19,opt,name=first_delta_at_unix_ms,json=firstDeltaAtUnixMs,proto3" json:"first_delta_at_unix_ms,omitempty"`
+	// Set on the done event
+	Sampling *Sampling `protobuf:"bytes,20,opt,name=sampling,proto3" json:"sampling,omitempty"`
+	// Response identity, the same on every event of a stream (see ChatCompletionResponse)
+	Id                string `protobuf:"bytes,21,opt,name=id,proto3" json:"id,omitempty"`
+	Created           int64  `protobuf:"varint,22,opt,name=created,proto3" json:"created,omitempty"`
+	SystemFingerprint string `protobuf:"bytes,23,opt,name=system_fingerprint,json=systemFingerprint,proto3" json:"system_fingerprint,omitempty"`
+	// Set on the failed event: what the stream sent before failing
+	ChunksSent int32 `protobuf:"varint,24,opt,name=chunks_sent,json=chunksSent,proto3" json:"chunks_sent,omitempty"` // events sent, deltas included, keep-alives not
+	BytesSent  int64 `protobuf:"varint,25,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`    // delta text bytes
+	TokensSent int32 `protobuf:"varint,26,opt,name=tokens_sent,json=tokensSent,proto3" json:"tokens_sent,omitempty"` // approximate delta tokens
+	// On every event: the request's client request ID (see ChatCompletionResponse)
+	ClientRequestId string `protobuf:"bytes,27,opt,name=client_request_id,json=clientRequestId,proto3" json:"client_request_id,omitempty"`
+	// Deprecated: on the failed event, the error text finish_reason carried before it became "error".
+	// Kept for one release; read error_code and error_message instead.
+	//
+	// Deprecated: Marked as deprecated in llm.proto.
+	LegacyFinishReason string `protobuf:"bytes,28,opt,name=legacy_finish_reason,json=legacyFinishReason,proto3" json:"legacy_finish_reason,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *ChatCompletionChunkResponse) Reset() {
 	*x = ChatCompletionChunkResponse{}
-	mi := &file_llm_proto_msgTypes[4]
+	mi := &file_llm_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -361,7 +719,7 @@ func (x *ChatCompletionChunkResponse) String() string {
 func (*ChatCompletionChunkResponse) ProtoMessage() {}
 
 func (x *ChatCompletionChunkResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_llm_proto_msgTypes[4]
+	mi := &file_llm_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -374,7 +732,7 @@ func (x *ChatCompletionChunkResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatCompletionChunkResponse.ProtoReflect.Descriptor instead.
 func (*ChatCompletionChunkResponse) Descriptor() ([]byte, []int) {
-	return file_llm_proto_rawDescGZIP(), []int{4}
+	return file_llm_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ChatCompletionChunkResponse) GetType() string {
@@ -433,55 +791,1201 @@ func (x *ChatCompletionChunkResponse) GetLatencyMs() int64 {
 	return 0
 }
 
-var File_llm_proto protoreflect.FileDescriptor
+func (x *ChatCompletionChunkResponse) GetQueueMs() int64 {
+	if x != nil {
+		return x.QueueMs
+	}
+	return 0
+}
 
-const file_llm_proto_rawDesc = "" +
-	"\n" +
-	"\tllm.proto\x12\x06llm.v1\"\x7f\n" +
-	"\vRequestMeta\x12\x1d\n" +
-	"\n" +
-	"request_id\x18\x01 \x01(\tR\trequestId\x12\x19\n" +
-	"\btrace_id\x18\x02 \x01(\tR\atraceId\x12\x1d\n" +
-	"\n" +
-	"session_id\x18\x03 \x01(\tR\tsessionId\x12\x17\n" +
-	"\auser_id\x18\x04 \x01(\tR\x06userId\";\n" +
-	"\vChatMessage\x12\x12\n" +
-	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
-	"\acontent\x18\x02 \x01(\tR\acontent\"\xa1\x02\n" +
-	"\x15ChatCompletionRequest\x12'\n" +
-	"\x04meta\x18\x01 \x01(\v2\x13.llm.v1.RequestMetaR\x04meta\x12\x14\n" +
-	"\x05model\x18\x02 \x01(\tR\x05model\x12#\n" +
-	"\rsystem_prompt\x18\x03 \x01(\tR\fsystemPrompt\x12\x1f\n" +
-	"\vuser_prompt\x18\x04 \x01(\tR\n" +
-	"userPrompt\x12-\n" +
-	"\acontext\x18\x05 \x03(\v2\x13.llm.v1.ChatMessageR\acontext\x12 \n" +
-	"\vtemperature\x18\x06 \x01(\x01R\vtemperature\x12\x1d\n" +
-	"\n" +
-	"max_tokens\x18\a \x01(\x05R\tmaxTokens\x12\x13\n" +
-	"\x05top_p\x18\b \x01(\x01R\x04topP\"\xf2\x01\n" +
-	"\x16ChatCompletionResponse\x12\x1f\n" +
-	"\voutput_text\x18\x01 \x01(\tR\n" +
-	"outputText\x12#\n" +
-	"\rfinish_reason\x18\x02 \x01(\tR\ffinishReason\x12#\n" +
-	"\rprompt_tokens\x18\x03 \x01(\x05R\fpromptTokens\x12+\n" +
-	"\x11completion_tokens\x18\x04 \x01(\x05R\x10completionTokens\x12!\n" +
-	"\ftotal_tokens\x18\x05 \x01(\x05R\vtotalTokens\x12\x1d\n" +
-	"\n" +
-	"latency_ms\x18\x06 \x01(\x03R\tlatencyMs\"\x94\x02\n" +
-	"\x1bChatCompletionChunkResponse\x12\x12\n" +
-	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
-	"\x04text\x18\x02 \x01(\tR\x04text\x12#\n" +
-	"\rfinish_reason\x18\x03 \x01(\tR\ffinishReason\x12\x14\n" +
-	"\x05index\x18\x04 \x01(\x05R\x05index\x12#\n" +
-	"\rprompt_tokens\x18\x05 \x01(\x05R\fpromptTokens\x12+\n" +
-	"\x11completion_tokens\x18\x06 \x01(\x05R\x10completionTokens\x12!\n" +
-	"\ftotal_tokens\x18\a \x01(\x05R\vtotalTokens\x12\x1d\n" +
+func (x *ChatCompletionChunkResponse) GetPromptEvalMs() int64 {
+	if x != nil {
+		return x.PromptEvalMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetTtftMs() int64 {
+	if x != nil {
+		return x.TtftMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetGenerationMs() int64 {
+	if x != nil {
+		return x.GenerationMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *ChatCompletionChunkResponse) GetInjected() bool {
+	if x != nil {
+		return x.Injected
+	}
+	return false
+}
+
+func (x *ChatCompletionChunkResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *ChatCompletionChunkResponse) GetOutputSha256() string {
+	if x != nil {
+		return x.OutputSha256
+	}
+	return ""
+}
+
+func (x *ChatCompletionChunkResponse) GetOutputBytes() int64 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetEmittedAtUnixMs() int64 {
+	if x != nil {
+		return x.EmittedAtUnixMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetFirstDeltaAtUnixMs() int64 {
+	if x != nil {
+		return x.FirstDeltaAtUnixMs
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetSampling() *Sampling {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *ChatCompletionChunkResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ChatCompletionChunkResponse) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetSystemFingerprint() string {
+	if x != nil {
+		return x.SystemFingerprint
+	}
+	return ""
+}
+
+func (x *ChatCompletionChunkResponse) GetChunksSent() int32 {
+	if x != nil {
+		return x.ChunksSent
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetBytesSent() int64 {
+	if x != nil {
+		return x.BytesSent
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetTokensSent() int32 {
+	if x != nil {
+		return x.TokensSent
+	}
+	return 0
+}
+
+func (x *ChatCompletionChunkResponse) GetClientRequestId() string {
+	if x != nil {
+		return x.ClientRequestId
+	}
+	return ""
+}
+
+// Deprecated: Marked as deprecated in llm.proto.
+func (x *ChatCompletionChunkResponse) GetLegacyFinishReason() string {
+	if x != nil {
+		return x.LegacyFinishReason
+	}
+	return ""
+}
+
+type GetStatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Zero the counters after taking the snapshot (active_streams is a gauge and is kept).
+	ResetCounters bool `protobuf:"varint,1,opt,name=reset_counters,json=resetCounters,proto3" json:"reset_counters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_llm_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetStatsRequest) GetResetCounters() bool {
+	if x != nil {
+		return x.ResetCounters
+	}
+	return false
+}
+
+type RpcStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      int64                  `protobuf:"varint,1,opt,name=requests,proto3" json:"requests,omitempty"`
+	Successes     int64                  `protobuf:"varint,2,opt,name=successes,proto3" json:"successes,omitempty"`
+	Failures      int64                  `protobuf:"varint,3,opt,name=failures,proto3" json:"failures,omitempty"`
+	Codes         map[string]int64       `protobuf:"bytes,4,rep,name=codes,proto3" json:"codes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // gRPC code name or HTTP status -> count
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RpcStats) Reset() {
+	*x = RpcStats{}
+	mi := &file_llm_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RpcStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RpcStats) ProtoMessage() {}
+
+func (x *RpcStats) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RpcStats.ProtoReflect.Descriptor instead.
+func (*RpcStats) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RpcStats) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *RpcStats) GetSuccesses() int64 {
+	if x != nil {
+		return x.Successes
+	}
+	return 0
+}
+
+func (x *RpcStats) GetFailures() int64 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *RpcStats) GetCodes() map[string]int64 {
+	if x != nil {
+		return x.Codes
+	}
+	return nil
+}
+
+type GetStatsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	SinceMs int64                  `protobuf:"varint,1,opt,name=since_ms,json=sinceMs,proto3" json:"since_ms,omitempty"` // time since start or the last reset
+	// Keyed by gRPC method (e.g. "ChatCompletionStream") or HTTP route (e.g. "POST /v1/chat/completions")
+	Rpcs           map[string]*RpcStats `protobuf:"bytes,2,rep,name=rpcs,proto3" json:"rpcs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	InjectedErrors int64                `protobuf:"varint,3,opt,name=injected_errors,json=injectedErrors,proto3" json:"injected_errors,omitempty"`
+	ActiveStreams  int64                `protobuf:"varint,4,opt,name=active_streams,json=activeStreams,proto3" json:"active_streams,omitempty"`
+	TotalTokens    int64                `protobuf:"varint,5,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"` // completion tokens emitted
+	// Request latency percentiles over the most recent samples
+	P50Ms          float64 `protobuf:"fixed64,6,opt,name=p50_ms,json=p50Ms,proto3" json:"p50_ms,omitempty"`
+	P90Ms          float64 `protobuf:"fixed64,7,opt,name=p90_ms,json=p90Ms,proto3" json:"p90_ms,omitempty"`
+	P99Ms          float64 `protobuf:"fixed64,8,opt,name=p99_ms,json=p99Ms,proto3" json:"p99_ms,omitempty"`
+	LatencySamples int32   `protobuf:"varint,9,opt,name=latency_samples,json=latencySamples,proto3" json:"latency_samples,omitempty"`
+	// Token usage by model (requests beyond the cardinality cap count as "other")
+	Usage                map[string]*ModelUsage `protobuf:"bytes,10,rep,name=usage,proto3" json:"usage,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SimulatedMemoryBytes int64                  `protobuf:"varint,11,opt,name=simulated_memory_bytes,json=simulatedMemoryBytes,proto3" json:"simulated_memory_bytes,omitempty"` // held by streams for MEM_BYTES_PER_TOKEN (gauge)
+	ScenarioPhase        string                 `protobuf:"bytes,12,opt,name=scenario_phase,json=scenarioPhase,proto3" json:"scenario_phase,omitempty"`                         // name of the running SCENARIO_FILE phase; empty without a scenario
+	// FAULT_SCHEDULE state: time since the schedule started and the state of each fault; unset without one
+	FaultUptimeMs int64         `protobuf:"varint,13,opt,name=fault_uptime_ms,json=faultUptimeMs,proto3" json:"fault_uptime_ms,omitempty"`
+	Faults        []*FaultState `protobuf:"bytes,14,rep,name=faults,proto3" json:"faults,omitempty"`
+	// Request counters by TENANT_PROFILES profile: the tenant ID, "default" for unknown tenants run on
+	// the default profile and "unknown" for the ones rejected; empty without tenant profiles
+	Tenants       map[string]*RpcStats `protobuf:"bytes,15,rep,name=tenants,proto3" json:"tenants,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_llm_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetStatsResponse) GetSinceMs() int64 {
+	if x != nil {
+		return x.SinceMs
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetRpcs() map[string]*RpcStats {
+	if x != nil {
+		return x.Rpcs
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetInjectedErrors() int64 {
+	if x != nil {
+		return x.InjectedErrors
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetActiveStreams() int64 {
+	if x != nil {
+		return x.ActiveStreams
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetTotalTokens() int64 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetP50Ms() float64 {
+	if x != nil {
+		return x.P50Ms
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetP90Ms() float64 {
+	if x != nil {
+		return x.P90Ms
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetP99Ms() float64 {
+	if x != nil {
+		return x.P99Ms
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetLatencySamples() int32 {
+	if x != nil {
+		return x.LatencySamples
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetUsage() map[string]*ModelUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetSimulatedMemoryBytes() int64 {
+	if x != nil {
+		return x.SimulatedMemoryBytes
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetScenarioPhase() string {
+	if x != nil {
+		return x.ScenarioPhase
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetFaultUptimeMs() int64 {
+	if x != nil {
+		return x.FaultUptimeMs
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetFaults() []*FaultState {
+	if x != nil {
+		return x.Faults
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTenants() map[string]*RpcStats {
+	if x != nil {
+		return x.Tenants
+	}
+	return nil
+}
+
+type FaultState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FaultType     string                 `protobuf:"bytes,1,opt,name=fault_type,json=faultType,proto3" json:"fault_type,omitempty"`
+	OffsetMs      int64                  `protobuf:"varint,2,opt,name=offset_ms,json=offsetMs,proto3" json:"offset_ms,omitempty"`
+	DurationMs    int64                  `protobuf:"varint,3,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"` // pending|active|done
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultState) Reset() {
+	*x = FaultState{}
+	mi := &file_llm_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultState) ProtoMessage() {}
+
+func (x *FaultState) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultState.ProtoReflect.Descriptor instead.
+func (*FaultState) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FaultState) GetFaultType() string {
+	if x != nil {
+		return x.FaultType
+	}
+	return ""
+}
+
+func (x *FaultState) GetOffsetMs() int64 {
+	if x != nil {
+		return x.OffsetMs
+	}
+	return 0
+}
+
+func (x *FaultState) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *FaultState) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type ModelUsage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Keyed by the first 12 hex digits of the API key's SHA-256 ("anonymous" without a key)
+	Keys          map[string]*KeyUsage `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModelUsage) Reset() {
+	*x = ModelUsage{}
+	mi := &file_llm_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelUsage) ProtoMessage() {}
+
+func (x *ModelUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelUsage.ProtoReflect.Descriptor instead.
+func (*ModelUsage) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ModelUsage) GetKeys() map[string]*KeyUsage {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type KeyUsage struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Requests         int64                  `protobuf:"varint,1,opt,name=requests,proto3" json:"requests,omitempty"`
+	PromptTokens     int64                  `protobuf:"varint,2,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int64                  `protobuf:"varint,3,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *KeyUsage) Reset() {
+	*x = KeyUsage{}
+	mi := &file_llm_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyUsage) ProtoMessage() {}
+
+func (x *KeyUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyUsage.ProtoReflect.Descriptor instead.
+func (*KeyUsage) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *KeyUsage) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *KeyUsage) GetPromptTokens() int64 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *KeyUsage) GetCompletionTokens() int64 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_llm_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{13}
+}
+
+// The settings that can change at runtime (see the matching environment variables). GetConfig sets
+// every field; in UpdateConfig unset fields are left as they are.
+type RuntimeConfig struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	BaseDelayMs      *int32                 `protobuf:"varint,1,opt,name=base_delay_ms,json=baseDelayMs,proto3,oneof" json:"base_delay_ms,omitempty"`
+	JitterMs         *int32                 `protobuf:"varint,2,opt,name=jitter_ms,json=jitterMs,proto3,oneof" json:"jitter_ms,omitempty"`
+	PerTokenDelayMs  *int32                 `protobuf:"varint,3,opt,name=per_token_delay_ms,json=perTokenDelayMs,proto3,oneof" json:"per_token_delay_ms,omitempty"`
+	ErrorRate        *float64               `protobuf:"fixed64,4,opt,name=error_rate,json=errorRate,proto3,oneof" json:"error_rate,omitempty"`
+	ErrorMode        *string                `protobuf:"bytes,5,opt,name=error_mode,json=errorMode,proto3,oneof" json:"error_mode,omitempty"`       // mixed|429|500
+	ErrorTiming      *string                `protobuf:"bytes,6,opt,name=error_timing,json=errorTiming,proto3,oneof" json:"error_timing,omitempty"` // pre|mid|mixed
+	DefaultTokens    *int32                 `protobuf:"varint,7,opt,name=default_tokens,json=defaultTokens,proto3,oneof" json:"default_tokens,omitempty"`
+	ChunkSize        *int32                 `protobuf:"varint,8,opt,name=chunk_size,json=chunkSize,proto3,oneof" json:"chunk_size,omitempty"`
+	StreamDelayMinMs *int32                 `protobuf:"varint,9,opt,name=stream_delay_min_ms,json=streamDelayMinMs,proto3,oneof" json:"stream_delay_min_ms,omitempty"`
+	StreamDelayMaxMs *int32                 `protobuf:"varint,10,opt,name=stream_delay_max_ms,json=streamDelayMaxMs,proto3,oneof" json:"stream_delay_max_ms,omitempty"`
+	EchoPrompt       *bool                  `protobuf:"varint,11,opt,name=echo_prompt,json=echoPrompt,proto3,oneof" json:"echo_prompt,omitempty"`
+	Randomize        *bool                  `protobuf:"varint,12,opt,name=randomize,proto3,oneof" json:"randomize,omitempty"`
+	TtftMinMs        *int32                 `protobuf:"varint,13,opt,name=ttft_min_ms,json=ttftMinMs,proto3,oneof" json:"ttft_min_ms,omitempty"`
+	TtftMaxMs        *int32                 `protobuf:"varint,14,opt,name=ttft_max_ms,json=ttftMaxMs,proto3,oneof" json:"ttft_max_ms,omitempty"`
+	TokensPerSec     *float64               `protobuf:"fixed64,15,opt,name=tokens_per_sec,json=tokensPerSec,proto3,oneof" json:"tokens_per_sec,omitempty"`
+	DebugOutputChars *int32                 `protobuf:"varint,16,opt,name=debug_output_chars,json=debugOutputChars,proto3,oneof" json:"debug_output_chars,omitempty"`
+	MaxOutputChars   *int32                 `protobuf:"varint,17,opt,name=max_output_chars,json=maxOutputChars,proto3,oneof" json:"max_output_chars,omitempty"`
+	StrictTokenMode  *bool                  `protobuf:"varint,18,opt,name=strict_token_mode,json=strictTokenMode,proto3,oneof" json:"strict_token_mode,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RuntimeConfig) Reset() {
+	*x = RuntimeConfig{}
+	mi := &file_llm_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuntimeConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuntimeConfig) ProtoMessage() {}
+
+func (x *RuntimeConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuntimeConfig.ProtoReflect.Descriptor instead.
+func (*RuntimeConfig) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RuntimeConfig) GetBaseDelayMs() int32 {
+	if x != nil && x.BaseDelayMs != nil {
+		return *x.BaseDelayMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetJitterMs() int32 {
+	if x != nil && x.JitterMs != nil {
+		return *x.JitterMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetPerTokenDelayMs() int32 {
+	if x != nil && x.PerTokenDelayMs != nil {
+		return *x.PerTokenDelayMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetErrorRate() float64 {
+	if x != nil && x.ErrorRate != nil {
+		return *x.ErrorRate
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetErrorMode() string {
+	if x != nil && x.ErrorMode != nil {
+		return *x.ErrorMode
+	}
+	return ""
+}
+
+func (x *RuntimeConfig) GetErrorTiming() string {
+	if x != nil && x.ErrorTiming != nil {
+		return *x.ErrorTiming
+	}
+	return ""
+}
+
+func (x *RuntimeConfig) GetDefaultTokens() int32 {
+	if x != nil && x.DefaultTokens != nil {
+		return *x.DefaultTokens
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetChunkSize() int32 {
+	if x != nil && x.ChunkSize != nil {
+		return *x.ChunkSize
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetStreamDelayMinMs() int32 {
+	if x != nil && x.StreamDelayMinMs != nil {
+		return *x.StreamDelayMinMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetStreamDelayMaxMs() int32 {
+	if x != nil && x.StreamDelayMaxMs != nil {
+		return *x.StreamDelayMaxMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetEchoPrompt() bool {
+	if x != nil && x.EchoPrompt != nil {
+		return *x.EchoPrompt
+	}
+	return false
+}
+
+func (x *RuntimeConfig) GetRandomize() bool {
+	if x != nil && x.Randomize != nil {
+		return *x.Randomize
+	}
+	return false
+}
+
+func (x *RuntimeConfig) GetTtftMinMs() int32 {
+	if x != nil && x.TtftMinMs != nil {
+		return *x.TtftMinMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetTtftMaxMs() int32 {
+	if x != nil && x.TtftMaxMs != nil {
+		return *x.TtftMaxMs
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetTokensPerSec() float64 {
+	if x != nil && x.TokensPerSec != nil {
+		return *x.TokensPerSec
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetDebugOutputChars() int32 {
+	if x != nil && x.DebugOutputChars != nil {
+		return *x.DebugOutputChars
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetMaxOutputChars() int32 {
+	if x != nil && x.MaxOutputChars != nil {
+		return *x.MaxOutputChars
+	}
+	return 0
+}
+
+func (x *RuntimeConfig) GetStrictTokenMode() bool {
+	if x != nil && x.StrictTokenMode != nil {
+		return *x.StrictTokenMode
+	}
+	return false
+}
+
+type FailAllRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // UNAVAILABLE (default) or RESOURCE_EXHAUSTED
+	DurationMs    int64                  `protobuf:"varint,2,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailAllRequest) Reset() {
+	*x = FailAllRequest{}
+	mi := &file_llm_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailAllRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailAllRequest) ProtoMessage() {}
+
+func (x *FailAllRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailAllRequest.ProtoReflect.Descriptor instead.
+func (*FailAllRequest) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *FailAllRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *FailAllRequest) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+type PauseAllRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DurationMs    int64                  `protobuf:"varint,1,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseAllRequest) Reset() {
+	*x = PauseAllRequest{}
+	mi := &file_llm_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseAllRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseAllRequest) ProtoMessage() {}
+
+func (x *PauseAllRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseAllRequest.ProtoReflect.Descriptor instead.
+func (*PauseAllRequest) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PauseAllRequest) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+type KillSwitchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`                                     // fail|pause, empty when lifted
+	ExpiresAtMs   int64                  `protobuf:"varint,2,opt,name=expires_at_ms,json=expiresAtMs,proto3" json:"expires_at_ms,omitempty"` // unix milliseconds, 0 when lifted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillSwitchResponse) Reset() {
+	*x = KillSwitchResponse{}
+	mi := &file_llm_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillSwitchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillSwitchResponse) ProtoMessage() {}
+
+func (x *KillSwitchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillSwitchResponse.ProtoReflect.Descriptor instead.
+func (*KillSwitchResponse) Descriptor() ([]byte, []int) {
+	return file_llm_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *KillSwitchResponse) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *KillSwitchResponse) GetExpiresAtMs() int64 {
+	if x != nil {
+		return x.ExpiresAtMs
+	}
+	return 0
+}
+
+var File_llm_proto protoreflect.FileDescriptor
+
+const file_llm_proto_rawDesc = "" +
+	"\n" +
+	"\tllm.proto\x12\x06llm.v1\"\xab\x01\n" +
+	"\vRequestMeta\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x19\n" +
+	"\btrace_id\x18\x02 \x01(\tR\atraceId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId\x12*\n" +
+	"\x11client_request_id\x18\x05 \x01(\tR\x0fclientRequestId\";\n" +
+	"\vChatMessage\x12\x12\n" +
+	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"\xc5\x03\n" +
+	"\x15ChatCompletionRequest\x12'\n" +
+	"\x04meta\x18\x01 \x01(\v2\x13.llm.v1.RequestMetaR\x04meta\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12#\n" +
+	"\rsystem_prompt\x18\x03 \x01(\tR\fsystemPrompt\x12\x1f\n" +
+	"\vuser_prompt\x18\x04 \x01(\tR\n" +
+	"userPrompt\x12-\n" +
+	"\acontext\x18\x05 \x03(\v2\x13.llm.v1.ChatMessageR\acontext\x12 \n" +
+	"\vtemperature\x18\x06 \x01(\x01R\vtemperature\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\a \x01(\x05R\tmaxTokens\x12\x13\n" +
+	"\x05top_p\x18\b \x01(\x01R\x04topP\x12)\n" +
+	"\x10presence_penalty\x18\t \x01(\x01R\x0fpresencePenalty\x12+\n" +
+	"\x11frequency_penalty\x18\n" +
+	" \x01(\x01R\x10frequencyPenalty\x12\f\n" +
+	"\x01n\x18\v \x01(\x05R\x01n\x12<\n" +
+	"\x0emock_overrides\x18\f \x01(\v2\x15.llm.v1.MockOverridesR\rmockOverrides\"\xa6\x04\n" +
+	"\rMockOverrides\x12'\n" +
+	"\rbase_delay_ms\x18\x01 \x01(\x05H\x00R\vbaseDelayMs\x88\x01\x01\x12 \n" +
+	"\tjitter_ms\x18\x02 \x01(\x05H\x01R\bjitterMs\x88\x01\x01\x12#\n" +
+	"\vttft_min_ms\x18\x03 \x01(\x05H\x02R\tttftMinMs\x88\x01\x01\x12#\n" +
+	"\vttft_max_ms\x18\x04 \x01(\x05H\x03R\tttftMaxMs\x88\x01\x01\x120\n" +
+	"\x12per_token_delay_ms\x18\x05 \x01(\x05H\x04R\x0fperTokenDelayMs\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"error_rate\x18\x06 \x01(\x01H\x05R\terrorRate\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"error_mode\x18\a \x01(\tH\x06R\terrorMode\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"chunk_size\x18\b \x01(\x05H\aR\tchunkSize\x88\x01\x01\x12)\n" +
+	"\x0etokens_per_sec\x18\t \x01(\x01H\bR\ftokensPerSec\x88\x01\x01\x12\x17\n" +
+	"\x04seed\x18\n" +
+	" \x01(\x03H\tR\x04seed\x88\x01\x01B\x10\n" +
+	"\x0e_base_delay_msB\f\n" +
+	"\n" +
+	"_jitter_msB\x0e\n" +
+	"\f_ttft_min_msB\x0e\n" +
+	"\f_ttft_max_msB\x15\n" +
+	"\x13_per_token_delay_msB\r\n" +
+	"\v_error_rateB\r\n" +
+	"\v_error_modeB\r\n" +
+	"\v_chunk_sizeB\x11\n" +
+	"\x0f_tokens_per_secB\a\n" +
+	"\x05_seed\"\x99\x01\n" +
+	"\bSampling\x12 \n" +
+	"\vtemperature\x18\x01 \x01(\x01R\vtemperature\x12\x13\n" +
+	"\x05top_p\x18\x02 \x01(\x01R\x04topP\x12)\n" +
+	"\x10presence_penalty\x18\x03 \x01(\x01R\x0fpresencePenalty\x12+\n" +
+	"\x11frequency_penalty\x18\x04 \x01(\x01R\x10frequencyPenalty\"\xd0\x04\n" +
+	"\x16ChatCompletionResponse\x12\x1f\n" +
+	"\voutput_text\x18\x01 \x01(\tR\n" +
+	"outputText\x12#\n" +
+	"\rfinish_reason\x18\x02 \x01(\tR\ffinishReason\x12#\n" +
+	"\rprompt_tokens\x18\x03 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x04 \x01(\x05R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\x05 \x01(\x05R\vtotalTokens\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x06 \x01(\x03R\tlatencyMs\x12\x19\n" +
+	"\bqueue_ms\x18\a \x01(\x03R\aqueueMs\x12$\n" +
+	"\x0eprompt_eval_ms\x18\b \x01(\x03R\fpromptEvalMs\x12\x17\n" +
+	"\attft_ms\x18\t \x01(\x03R\x06ttftMs\x12#\n" +
+	"\rgeneration_ms\x18\n" +
+	" \x01(\x03R\fgenerationMs\x12*\n" +
+	"\x11simulated_ttft_ms\x18\v \x01(\x03R\x0fsimulatedTtftMs\x12,\n" +
+	"\bsampling\x18\f \x01(\v2\x10.llm.v1.SamplingR\bsampling\x12\x0e\n" +
+	"\x02id\x18\r \x01(\tR\x02id\x12\x18\n" +
+	"\acreated\x18\x0e \x01(\x03R\acreated\x12-\n" +
+	"\x12system_fingerprint\x18\x0f \x01(\tR\x11systemFingerprint\x12*\n" +
+	"\x11client_request_id\x18\x10 \x01(\tR\x0fclientRequestId\"\xe6\a\n" +
+	"\x1bChatCompletionChunkResponse\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12#\n" +
+	"\rfinish_reason\x18\x03 \x01(\tR\ffinishReason\x12\x14\n" +
+	"\x05index\x18\x04 \x01(\x05R\x05index\x12#\n" +
+	"\rprompt_tokens\x18\x05 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x06 \x01(\x05R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\a \x01(\x05R\vtotalTokens\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\b \x01(\x03R\tlatencyMs\x12\x19\n" +
+	"\bqueue_ms\x18\t \x01(\x03R\aqueueMs\x12$\n" +
+	"\x0eprompt_eval_ms\x18\n" +
+	" \x01(\x03R\fpromptEvalMs\x12\x17\n" +
+	"\attft_ms\x18\v \x01(\x03R\x06ttftMs\x12#\n" +
+	"\rgeneration_ms\x18\f \x01(\x03R\fgenerationMs\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\r \x01(\tR\terrorCode\x12\x1a\n" +
+	"\binjected\x18\x0e \x01(\bR\binjected\x12#\n" +
+	"\rerror_message\x18\x0f \x01(\tR\ferrorMessage\x12#\n" +
+	"\routput_sha256\x18\x10 \x01(\tR\foutputSha256\x12!\n" +
+	"\foutput_bytes\x18\x11 \x01(\x03R\voutputBytes\x12+\n" +
+	"\x12emitted_at_unix_ms\x18\x12 \x01(\x03R\x0femittedAtUnixMs\x122\n" +
+	"\x16first_delta_at_unix_ms\x18\x13 \x01(\x03R\x12firstDeltaAtUnixMs\x12,\n" +
+	"\bsampling\x18\x14 \x01(\v2\x10.llm.v1.SamplingR\bsampling\x12\x0e\n" +
+	"\x02id\x18\x15 \x01(\tR\x02id\x12\x18\n" +
+	"\acreated\x18\x16 \x01(\x03R\acreated\x12-\n" +
+	"\x12system_fingerprint\x18\x17 \x01(\tR\x11systemFingerprint\x12\x1f\n" +
+	"\vchunks_sent\x18\x18 \x01(\x05R\n" +
+	"chunksSent\x12\x1d\n" +
+	"\n" +
+	"bytes_sent\x18\x19 \x01(\x03R\tbytesSent\x12\x1f\n" +
+	"\vtokens_sent\x18\x1a \x01(\x05R\n" +
+	"tokensSent\x12*\n" +
+	"\x11client_request_id\x18\x1b \x01(\tR\x0fclientRequestId\x124\n" +
+	"\x14legacy_finish_reason\x18\x1c \x01(\tB\x02\x18\x01R\x12legacyFinishReason\"8\n" +
+	"\x0fGetStatsRequest\x12%\n" +
+	"\x0ereset_counters\x18\x01 \x01(\bR\rresetCounters\"\xcd\x01\n" +
+	"\bRpcStats\x12\x1a\n" +
+	"\brequests\x18\x01 \x01(\x03R\brequests\x12\x1c\n" +
+	"\tsuccesses\x18\x02 \x01(\x03R\tsuccesses\x12\x1a\n" +
+	"\bfailures\x18\x03 \x01(\x03R\bfailures\x121\n" +
+	"\x05codes\x18\x04 \x03(\v2\x1b.llm.v1.RpcStats.CodesEntryR\x05codes\x1a8\n" +
+	"\n" +
+	"CodesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xda\x06\n" +
+	"\x10GetStatsResponse\x12\x19\n" +
+	"\bsince_ms\x18\x01 \x01(\x03R\asinceMs\x126\n" +
+	"\x04rpcs\x18\x02 \x03(\v2\".llm.v1.GetStatsResponse.RpcsEntryR\x04rpcs\x12'\n" +
+	"\x0finjected_errors\x18\x03 \x01(\x03R\x0einjectedErrors\x12%\n" +
+	"\x0eactive_streams\x18\x04 \x01(\x03R\ractiveStreams\x12!\n" +
+	"\ftotal_tokens\x18\x05 \x01(\x03R\vtotalTokens\x12\x15\n" +
+	"\x06p50_ms\x18\x06 \x01(\x01R\x05p50Ms\x12\x15\n" +
+	"\x06p90_ms\x18\a \x01(\x01R\x05p90Ms\x12\x15\n" +
+	"\x06p99_ms\x18\b \x01(\x01R\x05p99Ms\x12'\n" +
+	"\x0flatency_samples\x18\t \x01(\x05R\x0elatencySamples\x129\n" +
+	"\x05usage\x18\n" +
+	" \x03(\v2#.llm.v1.GetStatsResponse.UsageEntryR\x05usage\x124\n" +
+	"\x16simulated_memory_bytes\x18\v \x01(\x03R\x14simulatedMemoryBytes\x12%\n" +
+	"\x0escenario_phase\x18\f \x01(\tR\rscenarioPhase\x12&\n" +
+	"\x0ffault_uptime_ms\x18\r \x01(\x03R\rfaultUptimeMs\x12*\n" +
+	"\x06faults\x18\x0e \x03(\v2\x12.llm.v1.FaultStateR\x06faults\x12?\n" +
+	"\atenants\x18\x0f \x03(\v2%.llm.v1.GetStatsResponse.TenantsEntryR\atenants\x1aI\n" +
+	"\tRpcsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12&\n" +
+	"\x05value\x18\x02 \x01(\v2\x10.llm.v1.RpcStatsR\x05value:\x028\x01\x1aL\n" +
+	"\n" +
+	"UsageEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.llm.v1.ModelUsageR\x05value:\x028\x01\x1aL\n" +
+	"\fTenantsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12&\n" +
+	"\x05value\x18\x02 \x01(\v2\x10.llm.v1.RpcStatsR\x05value:\x028\x01\"\x7f\n" +
+	"\n" +
+	"FaultState\x12\x1d\n" +
+	"\n" +
+	"fault_type\x18\x01 \x01(\tR\tfaultType\x12\x1b\n" +
+	"\toffset_ms\x18\x02 \x01(\x03R\boffsetMs\x12\x1f\n" +
+	"\vduration_ms\x18\x03 \x01(\x03R\n" +
+	"durationMs\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\"\x89\x01\n" +
+	"\n" +
+	"ModelUsage\x120\n" +
+	"\x04keys\x18\x01 \x03(\v2\x1c.llm.v1.ModelUsage.KeysEntryR\x04keys\x1aI\n" +
+	"\tKeysEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12&\n" +
+	"\x05value\x18\x02 \x01(\v2\x10.llm.v1.KeyUsageR\x05value:\x028\x01\"x\n" +
+	"\bKeyUsage\x12\x1a\n" +
+	"\brequests\x18\x01 \x01(\x03R\brequests\x12#\n" +
+	"\rprompt_tokens\x18\x02 \x01(\x03R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x03 \x01(\x03R\x10completionTokens\"\x12\n" +
+	"\x10GetConfigRequest\"\xd0\b\n" +
+	"\rRuntimeConfig\x12'\n" +
+	"\rbase_delay_ms\x18\x01 \x01(\x05H\x00R\vbaseDelayMs\x88\x01\x01\x12 \n" +
+	"\tjitter_ms\x18\x02 \x01(\x05H\x01R\bjitterMs\x88\x01\x01\x120\n" +
+	"\x12per_token_delay_ms\x18\x03 \x01(\x05H\x02R\x0fperTokenDelayMs\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"error_rate\x18\x04 \x01(\x01H\x03R\terrorRate\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"error_mode\x18\x05 \x01(\tH\x04R\terrorMode\x88\x01\x01\x12&\n" +
+	"\ferror_timing\x18\x06 \x01(\tH\x05R\verrorTiming\x88\x01\x01\x12*\n" +
+	"\x0edefault_tokens\x18\a \x01(\x05H\x06R\rdefaultTokens\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"chunk_size\x18\b \x01(\x05H\aR\tchunkSize\x88\x01\x01\x122\n" +
+	"\x13stream_delay_min_ms\x18\t \x01(\x05H\bR\x10streamDelayMinMs\x88\x01\x01\x122\n" +
+	"\x13stream_delay_max_ms\x18\n" +
+	" \x01(\x05H\tR\x10streamDelayMaxMs\x88\x01\x01\x12$\n" +
+	"\vecho_prompt\x18\v \x01(\bH\n" +
+	"R\n" +
+	"echoPrompt\x88\x01\x01\x12!\n" +
+	"\trandomize\x18\f \x01(\bH\vR\trandomize\x88\x01\x01\x12#\n" +
+	"\vttft_min_ms\x18\r \x01(\x05H\fR\tttftMinMs\x88\x01\x01\x12#\n" +
+	"\vttft_max_ms\x18\x0e \x01(\x05H\rR\tttftMaxMs\x88\x01\x01\x12)\n" +
+	"\x0etokens_per_sec\x18\x0f \x01(\x01H\x0eR\ftokensPerSec\x88\x01\x01\x121\n" +
+	"\x12debug_output_chars\x18\x10 \x01(\x05H\x0fR\x10debugOutputChars\x88\x01\x01\x12-\n" +
+	"\x10max_output_chars\x18\x11 \x01(\x05H\x10R\x0emaxOutputChars\x88\x01\x01\x12/\n" +
+	"\x11strict_token_mode\x18\x12 \x01(\bH\x11R\x0fstrictTokenMode\x88\x01\x01B\x10\n" +
+	"\x0e_base_delay_msB\f\n" +
+	"\n" +
+	"_jitter_msB\x15\n" +
+	"\x13_per_token_delay_msB\r\n" +
+	"\v_error_rateB\r\n" +
+	"\v_error_modeB\x0f\n" +
+	"\r_error_timingB\x11\n" +
+	"\x0f_default_tokensB\r\n" +
+	"\v_chunk_sizeB\x16\n" +
+	"\x14_stream_delay_min_msB\x16\n" +
+	"\x14_stream_delay_max_msB\x0e\n" +
+	"\f_echo_promptB\f\n" +
 	"\n" +
-	"latency_ms\x18\b \x01(\x03R\tlatencyMs2\xbb\x01\n" +
+	"_randomizeB\x0e\n" +
+	"\f_ttft_min_msB\x0e\n" +
+	"\f_ttft_max_msB\x11\n" +
+	"\x0f_tokens_per_secB\x15\n" +
+	"\x13_debug_output_charsB\x13\n" +
+	"\x11_max_output_charsB\x14\n" +
+	"\x12_strict_token_mode\"E\n" +
+	"\x0eFailAllRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1f\n" +
+	"\vduration_ms\x18\x02 \x01(\x03R\n" +
+	"durationMs\"2\n" +
+	"\x0fPauseAllRequest\x12\x1f\n" +
+	"\vduration_ms\x18\x01 \x01(\x03R\n" +
+	"durationMs\"L\n" +
+	"\x12KillSwitchResponse\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\"\n" +
+	"\rexpires_at_ms\x18\x02 \x01(\x03R\vexpiresAtMs2\xfa\x01\n" +
 	"\n" +
 	"LlmService\x12O\n" +
 	"\x0eChatCompletion\x12\x1d.llm.v1.ChatCompletionRequest\x1a\x1e.llm.v1.ChatCompletionResponse\x12\\\n" +
-	"\x14ChatCompletionStream\x12\x1d.llm.v1.ChatCompletionRequest\x1a#.llm.v1.ChatCompletionChunkResponse0\x01B Z\x1ellm-simulator/gen/llm/v1;llmv1b\x06proto3"
+	"\x14ChatCompletionStream\x12\x1d.llm.v1.ChatCompletionRequest\x1a#.llm.v1.ChatCompletionChunkResponse0\x01\x12=\n" +
+	"\bGetStats\x12\x17.llm.v1.GetStatsRequest\x1a\x18.llm.v1.GetStatsResponse2\x8a\x02\n" +
+	"\fAdminService\x12<\n" +
+	"\tGetConfig\x12\x18.llm.v1.GetConfigRequest\x1a\x15.llm.v1.RuntimeConfig\x12<\n" +
+	"\fUpdateConfig\x12\x15.llm.v1.RuntimeConfig\x1a\x15.llm.v1.RuntimeConfig\x12=\n" +
+	"\aFailAll\x12\x16.llm.v1.FailAllRequest\x1a\x1a.llm.v1.KillSwitchResponse\x12?\n" +
+	"\bPauseAll\x12\x17.llm.v1.PauseAllRequest\x1a\x1a.llm.v1.KillSwitchResponseB Z\x1ellm-simulator/gen/llm/v1;llmv1b\x06proto3"
 
 var (
 	file_llm_proto_rawDescOnce sync.Once
@@ -495,26 +1999,67 @@ func file_llm_proto_rawDescGZIP() []byte {
 	return file_llm_proto_rawDescData
 }
 
-var file_llm_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_llm_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
 var file_llm_proto_goTypes = []any{
 	(*RequestMeta)(nil),                 // 0: llm.v1.RequestMeta
 	(*ChatMessage)(nil),                 // 1: llm.v1.ChatMessage
 	(*ChatCompletionRequest)(nil),       // 2: llm.v1.ChatCompletionRequest
-	(*ChatCompletionResponse)(nil),      // 3: llm.v1.ChatCompletionResponse
-	(*ChatCompletionChunkResponse)(nil), // 4: llm.v1.ChatCompletionChunkResponse
+	(*MockOverrides)(nil),               // 3: llm.v1.MockOverrides
+	(*Sampling)(nil),                    // 4: llm.v1.Sampling
+	(*ChatCompletionResponse)(nil),      // 5: llm.v1.ChatCompletionResponse
+	(*ChatCompletionChunkResponse)(nil), // 6: llm.v1.ChatCompletionChunkResponse
+	(*GetStatsRequest)(nil),             // 7: llm.v1.GetStatsRequest
+	(*RpcStats)(nil),                    // 8: llm.v1.RpcStats
+	(*GetStatsResponse)(nil),            // 9: llm.v1.GetStatsResponse
+	(*FaultState)(nil),                  // 10: llm.v1.FaultState
+	(*ModelUsage)(nil),                  // 11: llm.v1.ModelUsage
+	(*KeyUsage)(nil),                    // 12: llm.v1.KeyUsage
+	(*GetConfigRequest)(nil),            // 13: llm.v1.GetConfigRequest
+	(*RuntimeConfig)(nil),               // 14: llm.v1.RuntimeConfig
+	(*FailAllRequest)(nil),              // 15: llm.v1.FailAllRequest
+	(*PauseAllRequest)(nil),             // 16: llm.v1.PauseAllRequest
+	(*KillSwitchResponse)(nil),          // 17: llm.v1.KillSwitchResponse
+	nil,                                 // 18: llm.v1.RpcStats.CodesEntry
+	nil,                                 // 19: llm.v1.GetStatsResponse.RpcsEntry
+	nil,                                 // 20: llm.v1.GetStatsResponse.UsageEntry
+	nil,                                 // 21: llm.v1.GetStatsResponse.TenantsEntry
+	nil,                                 // 22: llm.v1.ModelUsage.KeysEntry
 }
 var file_llm_proto_depIdxs = []int32{
-	0, // 0: llm.v1.ChatCompletionRequest.meta:type_name -> llm.v1.RequestMeta
-	1, // 1: llm.v1.ChatCompletionRequest.context:type_name -> llm.v1.ChatMessage
-	2, // 2: llm.v1.LlmService.ChatCompletion:input_type -> llm.v1.ChatCompletionRequest
-	2, // 3: llm.v1.LlmService.ChatCompletionStream:input_type -> llm.v1.ChatCompletionRequest
-	3, // 4: llm.v1.LlmService.ChatCompletion:output_type -> llm.v1.ChatCompletionResponse
-	4, // 5: llm.v1.LlmService.ChatCompletionStream:output_type -> llm.v1.ChatCompletionChunkResponse
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: llm.v1.ChatCompletionRequest.meta:type_name -> llm.v1.RequestMeta
+	1,  // 1: llm.v1.ChatCompletionRequest.context:type_name -> llm.v1.ChatMessage
+	3,  // 2: llm.v1.ChatCompletionRequest.mock_overrides:type_name -> llm.v1.MockOverrides
+	4,  // 3: llm.v1.ChatCompletionResponse.sampling:type_name -> llm.v1.Sampling
+	4,  // 4: llm.v1.ChatCompletionChunkResponse.sampling:type_name -> llm.v1.Sampling
+	18, // 5: llm.v1.RpcStats.codes:type_name -> llm.v1.RpcStats.CodesEntry
+	19, // 6: llm.v1.GetStatsResponse.rpcs:type_name -> llm.v1.GetStatsResponse.RpcsEntry
+	20, // 7: llm.v1.GetStatsResponse.usage:type_name -> llm.v1.GetStatsResponse.UsageEntry
+	10, // 8: llm.v1.GetStatsResponse.faults:type_name -> llm.v1.FaultState
+	21, // 9: llm.v1.GetStatsResponse.tenants:type_name -> llm.v1.GetStatsResponse.TenantsEntry
+	22, // 10: llm.v1.ModelUsage.keys:type_name -> llm.v1.ModelUsage.KeysEntry
+	8,  // 11: llm.v1.GetStatsResponse.RpcsEntry.value:type_name -> llm.v1.RpcStats
+	11, // 12: llm.v1.GetStatsResponse.UsageEntry.value:type_name -> llm.v1.ModelUsage
+	8,  // 13: llm.v1.GetStatsResponse.TenantsEntry.value:type_name -> llm.v1.RpcStats
+	12, // 14: llm.v1.ModelUsage.KeysEntry.value:type_name -> llm.v1.KeyUsage
+	2,  // 15: llm.v1.LlmService.ChatCompletion:input_type -> llm.v1.ChatCompletionRequest
+	2,  // 16: llm.v1.LlmService.ChatCompletionStream:input_type -> llm.v1.ChatCompletionRequest
+	7,  // 17: llm.v1.LlmService.GetStats:input_type -> llm.v1.GetStatsRequest
+	13, // 18: llm.v1.AdminService.GetConfig:input_type -> llm.v1.GetConfigRequest
+	14, // 19: llm.v1.AdminService.UpdateConfig:input_type -> llm.v1.RuntimeConfig
+	15, // 20: llm.v1.AdminService.FailAll:input_type -> llm.v1.FailAllRequest
+	16, // 21: llm.v1.AdminService.PauseAll:input_type -> llm.v1.PauseAllRequest
+	5,  // 22: llm.v1.LlmService.ChatCompletion:output_type -> llm.v1.ChatCompletionResponse
+	6,  // 23: llm.v1.LlmService.ChatCompletionStream:output_type -> llm.v1.ChatCompletionChunkResponse
+	9,  // 24: llm.v1.LlmService.GetStats:output_type -> llm.v1.GetStatsResponse
+	14, // 25: llm.v1.AdminService.GetConfig:output_type -> llm.v1.RuntimeConfig
+	14, // 26: llm.v1.AdminService.UpdateConfig:output_type -> llm.v1.RuntimeConfig
+	17, // 27: llm.v1.AdminService.FailAll:output_type -> llm.v1.KillSwitchResponse
+	17, // 28: llm.v1.AdminService.PauseAll:output_type -> llm.v1.KillSwitchResponse
+	22, // [22:29] is the sub-list for method output_type
+	15, // [15:22] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_llm_proto_init() }
@@ -522,15 +2067,17 @@ func file_llm_proto_init() {
 	if File_llm_proto != nil {
 		return
 	}
+	file_llm_proto_msgTypes[3].OneofWrappers = []any{}
+	file_llm_proto_msgTypes[14].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_llm_proto_rawDesc), len(file_llm_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   23,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_llm_proto_goTypes,
 		DependencyIndexes: file_llm_proto_depIdxs,
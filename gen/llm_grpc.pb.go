@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	LlmService_ChatCompletion_FullMethodName       = "/llm.v1.LlmService/ChatCompletion"
 	LlmService_ChatCompletionStream_FullMethodName = "/llm.v1.LlmService/ChatCompletionStream"
+	LlmService_GetStats_FullMethodName             = "/llm.v1.LlmService/GetStats"
 )
 
 // LlmServiceClient is the client API for LlmService service.
@@ -29,6 +30,8 @@ const (
 type LlmServiceClient interface {
 	ChatCompletion(ctx context.Context, in *ChatCompletionRequest, opts ...grpc.CallOption) (*ChatCompletionResponse, error)
 	ChatCompletionStream(ctx context.Context, in *ChatCompletionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatCompletionChunkResponse], error)
+	// Runtime counters for harnesses that cannot scrape /metrics
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type llmServiceClient struct {
@@ -68,12 +71,24 @@ func (c *llmServiceClient) ChatCompletionStream(ctx context.Context, in *ChatCom
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LlmService_ChatCompletionStreamClient = grpc.ServerStreamingClient[ChatCompletionChunkResponse]
 
+func (c *llmServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, LlmService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LlmServiceServer is the server API for LlmService service.
 // All implementations must embed UnimplementedLlmServiceServer
 // for forward compatibility.
 type LlmServiceServer interface {
 	ChatCompletion(context.Context, *ChatCompletionRequest) (*ChatCompletionResponse, error)
 	ChatCompletionStream(*ChatCompletionRequest, grpc.ServerStreamingServer[ChatCompletionChunkResponse]) error
+	// Runtime counters for harnesses that cannot scrape /metrics
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedLlmServiceServer()
 }
 
@@ -90,6 +105,9 @@ func (UnimplementedLlmServiceServer) ChatCompletion(context.Context, *ChatComple
 func (UnimplementedLlmServiceServer) ChatCompletionStream(*ChatCompletionRequest, grpc.ServerStreamingServer[ChatCompletionChunkResponse]) error {
 	return status.Error(codes.Unimplemented, "method ChatCompletionStream not implemented")
 }
+func (UnimplementedLlmServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedLlmServiceServer) mustEmbedUnimplementedLlmServiceServer() {}
 func (UnimplementedLlmServiceServer) testEmbeddedByValue()                    {}
 
@@ -140,6 +158,24 @@ func _LlmService_ChatCompletionStream_Handler(srv interface{}, stream grpc.Serve
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LlmService_ChatCompletionStreamServer = grpc.ServerStreamingServer[ChatCompletionChunkResponse]
 
+func _LlmService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LlmServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LlmService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LlmServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LlmService_ServiceDesc is the grpc.ServiceDesc for LlmService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -151,6 +187,10 @@ var LlmService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ChatCompletion",
 			Handler:    _LlmService_ChatCompletion_Handler,
 		},
+		{
+			MethodName: "GetStats",
+			Handler:    _LlmService_GetStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -161,3 +201,233 @@ var LlmService_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "llm.proto",
 }
+
+const (
+	AdminService_GetConfig_FullMethodName    = "/llm.v1.AdminService/GetConfig"
+	AdminService_UpdateConfig_FullMethodName = "/llm.v1.AdminService/UpdateConfig"
+	AdminService_FailAll_FullMethodName      = "/llm.v1.AdminService/FailAll"
+	AdminService_PauseAll_FullMethodName     = "/llm.v1.AdminService/PauseAll"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Runtime reconfiguration, so long-running benchmarks can switch scenarios without a restart
+type AdminServiceClient interface {
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*RuntimeConfig, error)
+	// Applies the set fields atomically and returns the resulting config. Invalid values (negative
+	// rates or delays, min > max) fail with INVALID_ARGUMENT and leave the config unchanged.
+	UpdateConfig(ctx context.Context, in *RuntimeConfig, opts ...grpc.CallOption) (*RuntimeConfig, error)
+	// Kill switches for chaos drills. FailAll makes every new LlmService request (gRPC and HTTP) fail
+	// right away and PauseAll makes them block until the pause lifts or their deadline expires.
+	// Readiness reports NOT_SERVING while either is active. A duration of 0 lifts the active switch.
+	FailAll(ctx context.Context, in *FailAllRequest, opts ...grpc.CallOption) (*KillSwitchResponse, error)
+	PauseAll(ctx context.Context, in *PauseAllRequest, opts ...grpc.CallOption) (*KillSwitchResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*RuntimeConfig, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RuntimeConfig)
+	err := c.cc.Invoke(ctx, AdminService_GetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) UpdateConfig(ctx context.Context, in *RuntimeConfig, opts ...grpc.CallOption) (*RuntimeConfig, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RuntimeConfig)
+	err := c.cc.Invoke(ctx, AdminService_UpdateConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) FailAll(ctx context.Context, in *FailAllRequest, opts ...grpc.CallOption) (*KillSwitchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KillSwitchResponse)
+	err := c.cc.Invoke(ctx, AdminService_FailAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) PauseAll(ctx context.Context, in *PauseAllRequest, opts ...grpc.CallOption) (*KillSwitchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KillSwitchResponse)
+	err := c.cc.Invoke(ctx, AdminService_PauseAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// Runtime reconfiguration, so long-running benchmarks can switch scenarios without a restart
+type AdminServiceServer interface {
+	GetConfig(context.Context, *GetConfigRequest) (*RuntimeConfig, error)
+	// Applies the set fields atomically and returns the resulting config. Invalid values (negative
+	// rates or delays, min > max) fail with INVALID_ARGUMENT and leave the config unchanged.
+	UpdateConfig(context.Context, *RuntimeConfig) (*RuntimeConfig, error)
+	// Kill switches for chaos drills. FailAll makes every new LlmService request (gRPC and HTTP) fail
+	// right away and PauseAll makes them block until the pause lifts or their deadline expires.
+	// Readiness reports NOT_SERVING while either is active. A duration of 0 lifts the active switch.
+	FailAll(context.Context, *FailAllRequest) (*KillSwitchResponse, error)
+	PauseAll(context.Context, *PauseAllRequest) (*KillSwitchResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) GetConfig(context.Context, *GetConfigRequest) (*RuntimeConfig, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedAdminServiceServer) UpdateConfig(context.Context, *RuntimeConfig) (*RuntimeConfig, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateConfig not implemented")
+}
+func (UnimplementedAdminServiceServer) FailAll(context.Context, *FailAllRequest) (*KillSwitchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FailAll not implemented")
+}
+func (UnimplementedAdminServiceServer) PauseAll(context.Context, *PauseAllRequest) (*KillSwitchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PauseAll not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call panics, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_UpdateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuntimeConfig)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).UpdateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_UpdateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).UpdateConfig(ctx, req.(*RuntimeConfig))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_FailAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FailAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).FailAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_FailAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).FailAll(ctx, req.(*FailAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_PauseAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).PauseAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_PauseAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).PauseAll(ctx, req.(*PauseAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "llm.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _AdminService_GetConfig_Handler,
+		},
+		{
+			MethodName: "UpdateConfig",
+			Handler:    _AdminService_UpdateConfig_Handler,
+		},
+		{
+			MethodName: "FailAll",
+			Handler:    _AdminService_FailAll_Handler,
+		},
+		{
+			MethodName: "PauseAll",
+			Handler:    _AdminService_PauseAll_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "llm.proto",
+}
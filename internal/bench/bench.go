@@ -0,0 +1,236 @@
+// Package bench drives the LlmService gRPC API at a fixed concurrency and reports what the client
+// observed: time to first token, total latency, token throughput and errors. It backs the
+// `llm-simulator bench` subcommand, for calibrating the simulator and smoke-testing deployments.
+package bench
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures a run.
+type Options struct {
+	Concurrency int           // requests in flight at once
+	Duration    time.Duration // how long to keep starting requests
+	Stream      bool          // call ChatCompletionStream instead of ChatCompletion
+	MaxTokens   int
+	Model       string
+	Prompts     []string // user prompts, used round-robin; empty sends "Hello"
+	APIKey      string   // sent as `authorization: Bearer <key>` when set
+}
+
+// Report is the client-side view of a run. Latencies are in milliseconds. For unary calls the
+// time to first token is the whole call.
+type Report struct {
+	Mode             string           `json:"mode"` // "stream" or "unary"
+	Concurrency      int              `json:"concurrency"`
+	DurationMs       int64            `json:"duration_ms"`
+	Requests         int64            `json:"requests"`
+	Errors           int64            `json:"errors"`
+	ErrorRate        float64          `json:"error_rate"`
+	Codes            map[string]int64 `json:"codes"`
+	RequestsPerSec   float64          `json:"requests_per_sec"`
+	TTFT             stats.Latency    `json:"ttft"`
+	Latency          stats.Latency    `json:"latency"`
+	CompletionTokens int64            `json:"completion_tokens"`
+	TokensPerSec     float64          `json:"tokens_per_sec"`        // completion tokens of the whole run per second
+	StreamTPS        stats.Latency    `json:"stream_tokens_per_sec"` // per successful stream, after its first token (the _ms fields hold tokens/s)
+}
+
+// result is what one request observed.
+type result struct {
+	code    string // "OK" or the gRPC code
+	ttft    time.Duration
+	latency time.Duration
+	tokens  int
+}
+
+// LoadPrompts reads a prompt corpus: one prompt per line, blank lines skipped.
+func LoadPrompts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var prompts []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("%s has no prompts", path)
+	}
+	return prompts, nil
+}
+
+// Run keeps opts.Concurrency requests in flight until opts.Duration has passed or ctx is done,
+// waits for the requests still running and reports on all of them.
+func Run(ctx context.Context, client llmv1.LlmServiceClient, opts Options) Report {
+	prompts := opts.Prompts
+	if len(prompts) == 0 {
+		prompts = []string{"Hello"}
+	}
+	if opts.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.APIKey)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var (
+		next    atomic.Int64
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				req := &llmv1.ChatCompletionRequest{
+					Model:      opts.Model,
+					UserPrompt: prompts[int(next.Add(1)-1)%len(prompts)],
+					MaxTokens:  int32(opts.MaxTokens),
+				}
+				// Requests started before the deadline run to completion on ctx.
+				var r result
+				if opts.Stream {
+					r = stream(ctx, client, req)
+				} else {
+					r = unary(ctx, client, req)
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return newReport(opts, results, time.Since(start))
+}
+
+func unary(ctx context.Context, client llmv1.LlmServiceClient, req *llmv1.ChatCompletionRequest) result {
+	t0 := time.Now()
+	resp, err := client.ChatCompletion(ctx, req)
+	d := time.Since(t0)
+	if err != nil {
+		return result{code: status.Code(err).String(), latency: d}
+	}
+	return result{code: "OK", ttft: d, latency: d, tokens: int(resp.GetCompletionTokens())}
+}
+
+func stream(ctx context.Context, client llmv1.LlmServiceClient, req *llmv1.ChatCompletionRequest) result {
+	t0 := time.Now()
+	st, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		return result{code: status.Code(err).String(), latency: time.Since(t0)}
+	}
+	var r result
+	for {
+		chunk, err := st.Recv()
+		if errors.Is(err, io.EOF) {
+			r.code = "OK"
+			break
+		}
+		if err != nil {
+			r.code = status.Code(err).String()
+			break
+		}
+		if r.ttft == 0 && chunk.GetText() != "" && chunk.GetFinishReason() == "" {
+			r.ttft = time.Since(t0)
+		}
+		if reason := chunk.GetFinishReason(); reason != "" && reason != string(events.FinishError) {
+			r.tokens = int(chunk.GetCompletionTokens())
+		}
+	}
+	r.latency = time.Since(t0)
+	return r
+}
+
+func newReport(opts Options, results []result, elapsed time.Duration) Report {
+	rep := Report{
+		Mode:        "unary",
+		Concurrency: max(opts.Concurrency, 1),
+		DurationMs:  elapsed.Milliseconds(),
+		Requests:    int64(len(results)),
+		Codes:       map[string]int64{},
+	}
+	if opts.Stream {
+		rep.Mode = "stream"
+	}
+	var ttft, latency, tps []float64
+	for _, r := range results {
+		rep.Codes[r.code]++
+		if r.code != "OK" {
+			rep.Errors++
+			continue
+		}
+		latency = append(latency, ms(r.latency))
+		if r.ttft > 0 {
+			ttft = append(ttft, ms(r.ttft))
+		}
+		rep.CompletionTokens += int64(r.tokens)
+		if gen := r.latency - r.ttft; opts.Stream && r.ttft > 0 && gen > 0 && r.tokens > 0 {
+			tps = append(tps, float64(r.tokens)/gen.Seconds())
+		}
+	}
+	if rep.Requests > 0 {
+		rep.ErrorRate = float64(rep.Errors) / float64(rep.Requests)
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		rep.RequestsPerSec = float64(rep.Requests) / secs
+		rep.TokensPerSec = float64(rep.CompletionTokens) / secs
+	}
+	rep.TTFT = stats.Percentiles(ttft)
+	rep.Latency = stats.Percentiles(latency)
+	rep.StreamTPS = stats.Percentiles(tps)
+	return rep
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// WriteTable writes the report as a human-readable table.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "mode\t%s\n", r.Mode)
+	fmt.Fprintf(tw, "concurrency\t%d\n", r.Concurrency)
+	fmt.Fprintf(tw, "duration\t%s\n", time.Duration(r.DurationMs)*time.Millisecond)
+	fmt.Fprintf(tw, "requests\t%d (%.1f/s)\n", r.Requests, r.RequestsPerSec)
+	fmt.Fprintf(tw, "errors\t%d (%.2f%%)\n", r.Errors, 100*r.ErrorRate)
+	for _, code := range slices.Sorted(maps.Keys(r.Codes)) {
+		fmt.Fprintf(tw, "  %s\t%d\n", code, r.Codes[code])
+	}
+	fmt.Fprintf(tw, "\tp50\tp90\tp99\n")
+	fmt.Fprintf(tw, "ttft (ms)\t%.1f\t%.1f\t%.1f\n", r.TTFT.P50Ms, r.TTFT.P90Ms, r.TTFT.P99Ms)
+	fmt.Fprintf(tw, "latency (ms)\t%.1f\t%.1f\t%.1f\n", r.Latency.P50Ms, r.Latency.P90Ms, r.Latency.P99Ms)
+	if r.Mode == "stream" {
+		fmt.Fprintf(tw, "stream tokens/s\t%.1f\t%.1f\t%.1f\n", r.StreamTPS.P50Ms, r.StreamTPS.P90Ms, r.StreamTPS.P99Ms)
+	}
+	fmt.Fprintf(tw, "completion tokens\t%d (%.1f/s)\n", r.CompletionTokens, r.TokensPerSec)
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/simulatortest"
+)
+
+func TestRun(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.TTFTMinMs, cfg.TTFTMaxMs = simulatortest.Int(5), simulatortest.Int(5)
+	client := simulatortest.NewClient(t, cfg)
+
+	for _, stream := range []bool{true, false} {
+		rep := Run(context.Background(), client, Options{Concurrency: 4, Duration: 100 * time.Millisecond, Stream: stream, MaxTokens: 16})
+		if rep.Requests == 0 || rep.Errors != 0 || rep.Codes["OK"] != rep.Requests {
+			t.Fatalf("stream=%t: unexpected counts: %+v", stream, rep)
+		}
+		if rep.TTFT.Samples != int(rep.Requests) || rep.TTFT.P50Ms < 5 || rep.Latency.P99Ms < rep.TTFT.P50Ms {
+			t.Fatalf("stream=%t: unexpected timings: ttft=%+v latency=%+v", stream, rep.TTFT, rep.Latency)
+		}
+		if rep.CompletionTokens == 0 || rep.TokensPerSec <= 0 {
+			t.Fatalf("stream=%t: no tokens counted: %+v", stream, rep)
+		}
+		var out bytes.Buffer
+		if err := rep.WriteTable(&out); err != nil || !strings.Contains(out.String(), "ttft (ms)") {
+			t.Fatalf("stream=%t: unexpected table (%v):\n%s", stream, err, out.String())
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.ErrorRate, cfg.ErrorMode = 1, "429"
+	client := simulatortest.NewClient(t, cfg)
+
+	rep := Run(context.Background(), client, Options{Concurrency: 2, Duration: 50 * time.Millisecond, Stream: true})
+	if rep.Requests == 0 || rep.ErrorRate != 1 || rep.Codes["ResourceExhausted"] != rep.Requests {
+		t.Fatalf("expected every request to fail with ResourceExhausted: %+v", rep)
+	}
+}
+
+func TestLoadPrompts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.txt")
+	if err := os.WriteFile(path, []byte("first\n\n  second  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := LoadPrompts(path)
+	if err != nil || !slices.Equal(prompts, []string{"first", "second"}) {
+		t.Fatalf("unexpected prompts %q: %v", prompts, err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	_ = os.WriteFile(empty, []byte("\n\n"), 0o644)
+	if _, err := LoadPrompts(empty); err == nil {
+		t.Fatal("expected an error for a corpus without prompts")
+	}
+}
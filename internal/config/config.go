@@ -4,33 +4,178 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
 )
 
 type Config struct {
-	Port             int
-	Profile          string
-	Preset           string // openai|vllm|hybrid (controls default behavior presets)
-	BaseDelayMs      int
-	JitterMs         int
-	PerTokenDelayMs  int
-	ErrorRate        float64
-	ErrorMode        string // mixed|429|500
-	DefaultTokens    int
-	ChunkSize        int
-	StreamDelayMinMs int
-	StreamDelayMaxMs int
-	EchoPrompt       bool
-	Randomize        bool // enable/disable output-length & stream-shape randomization
+	Port              int
+	GRPCAddr          string      // listen address overriding Port: host:port or unix:///path/to.sock
+	UnixSocketMode    os.FileMode // permissions of unix socket files (gRPC and HTTP)
+	Profile           string
+	Preset            string // openai|vllm|hybrid (controls default behavior presets)
+	BaseDelayMs       int
+	JitterMs          int
+	PerTokenDelayMs   int
+	CPUBurnUsPerToken int // spin the CPU this many microseconds per generated token (0 disables)
+	MemBytesPerToken  int // hold this many bytes per prompt and emitted token for a stream's lifetime (simulated KV cache)
+	MemLimitBytes     int // cap on the simulated memory of all streams; past it streams fail with ResourceExhausted (0 = no cap)
+	ErrorRate         float64
+	ErrorMode         string  // mixed|429|500
+	ErrorTiming       string  // pre|mid|mixed (HTTP streams: fail before headers or after some deltas)
+	HTTPResetRate     float64 // share of HTTP streams (SSE, ND-JSON) whose connection is dropped after some events, with no final event
+	EmitFailedChunk   *bool   // gRPC streams: send a "failed" chunk before the error status (default true)
+	ChunkTimestamps   bool    // stamp each stream chunk with its send time (emitted_at_unix_ms)
+	EventNaming       string  // openai-responses|openai-chat|anthropic: the chunk type names of gRPC streams (see package events)
+	DefaultTokens     int
+	ChunkSize         *int // chars per stream chunk
+	StreamDelayMinMs  *int
+	StreamDelayMaxMs  *int
+	EchoPrompt        bool
+	Randomize         bool // enable/disable output-length & stream-shape randomization
+
+	// Stream delay distribution: uniform draws from [StreamDelayMinMs, StreamDelayMaxMs]; normal and
+	// lognormal center on StreamDelayMeanMs, or on the middle of that window when it is 0.
+	StreamDelayDistribution string  // uniform|normal|lognormal
+	StreamDelayMeanMs       int     // normal mean, lognormal median
+	StreamDelayStddevMs     int     // normal standard deviation; 0 takes a quarter of the window
+	StreamDelayLogSigma     float64 // lognormal shape: the standard deviation of the delay's log
+	StreamDelayCapMs        int     // clamp for every drawn delay so a long tail can't stall a stream (0 = no cap)
 
 	// LLM-like timing
-	TTFTMinMs    int // time-to-first-token min
-	TTFTMaxMs    int // time-to-first-token max
-	TokensPerSec int // streaming speed (approx)
+	TTFTMinMs    *int     // time-to-first-token min
+	TTFTMaxMs    *int     // time-to-first-token max
+	TokensPerSec *float64 // streaming speed (approx), may be fractional; 0 disables pacing
+
+	// Speed curve over the position in the response (scales TokensPerSec)
+	TPSCurve       string  // constant|rampup|decay|sine
+	TPSCurveTokens int     // rampup/decay: tokens to reach the end speed; sine: period in tokens
+	TPSCurveDepth  float64 // fraction of TokensPerSec the curve takes away at its slowest, in [0, 1)
+
+	// Bursty arrival (speculative decoding shape)
+	BurstMode  bool // send chunks in bursts of BurstSize back to back, then wait out the burst's tokens
+	BurstSize  int  // chunks per burst
+	BurstGapMs int  // minimum gap between bursts, even when TokensPerSec would allow a shorter one
+
+	// Multi-choice streams (n > 1)
+	ChoiceInterleave string // round_robin|random|sequential: the order in which the choices' deltas go out
+
+	// Endless streams (gRPC streams that only end when the client cancels)
+	EndlessStream bool // allow a stream to request endless generation with x-endless metadata or an "[[endless]]" prompt directive
+
+	// Per-request overrides (x-mock-* headers and metadata, the HTTP "mock" block, gRPC mock_overrides)
+	AllowRequestOverrides *bool // honor them (default true); off, requests run on the config as is
+
+	// Client request IDs (x-client-request-id, echoed on every chunk and response of the request)
+	ClientRequestIDFallback bool // echo the server's request ID when the client sent none, instead of nothing
+
+	// Request priorities (x-priority: high|normal|low, or an integer above, at or below 0)
+	PriorityHighQueueFactor float64  // scales the queue delay (base + jitter) of high-priority requests; 0 skips the queue
+	PriorityLowQueueFactor  *float64 // scales the queue delay of low-priority requests (default 2)
+
+	// Tenant profiles (x-tenant-id picks a partial config override: preset, runtime settings, RPM limit)
+	TenantProfiles string // JSON object of profiles by tenant ID, or the path of a JSON/YAML file holding one; empty disables
+	TenantUnknown  string // default|reject: run unknown tenants on the "default" profile, or fail them with PermissionDenied / 403
+
+	// Trace replay (gRPC streams follow recorded chunk offsets and sizes instead of the knobs above)
+	ReplayFile   string // JSONL or CSV (.csv) trace file loaded at startup; empty disables
+	ReplaySelect string // round_robin|model: cycle through every trace, or through the traces of the request's model
+
+	RejectShortDeadlines bool // fail requests whose deadline is below BASE_DELAY_MS + TTFT_MIN_MS right away
+	StrictValidation     bool // reject sampling params out of range (temperature, top_p, penalties) with InvalidArgument / 400
+	MaxContextMessages   int  // reject requests with more context messages than this with InvalidArgument / 400; 0 = unlimited
+	MaxMessageChars      int  // reject requests with a message (system, context or user) longer than this many chars; 0 = unlimited
+	GRPCHeadersFirst     bool // gRPC streams: send the response headers before the pre-delay instead of after it
+
+	GRPCKeepaliveChunkMs   int    // gRPC streams: send a keep-alive chunk after this much idle time in a pre-delay, pacing gap or stall; 0 disables
+	GRPCKeepaliveChunkType string // ping|delta: keep-alive chunks of type "ping", or empty-text deltas
+
+	MaxRequestDurationMs           int    // stop generating this long after a request starts and end it with what it has; 0 disables
+	MaxRequestDurationFinishReason string // length|timeout: the finish reason of a response cut by MAX_REQUEST_DURATION_MS
 
 	// Output sizing
-	DebugOutputChars int  // fixed output size for debugging
-	MaxOutputChars   int  // upper bound when using token-based sizing
-	StrictTokenMode  bool // if true, size output based on max_tokens
+	DebugOutputChars int   // fixed output size for debugging
+	MaxOutputChars   *int  // upper bound when using token-based sizing
+	StrictTokenMode  *bool // if true, size output based on max_tokens
+
+	// Prompt token accounting
+	PromptTokenAccounting  string // formatted|content_only: count the prompt as built (role markers included) or each message's content
+	PromptTokensPerMessage int    // content_only: fixed tokens added per message, like the chat format's per-message overhead
+
+	// gRPC transport
+	GRPCCompression  string // gzip|off (accept and mirror gzip, or refuse compression)
+	GzipChunkDelayMs int    // extra per-chunk delay simulating compression CPU cost for gzip clients
+
+	GRPCMaxRecvMB            int // max inbound message size in MB; 0 keeps the gRPC default (4MB)
+	GRPCMaxSendMB            int // max outbound message size in MB; 0 keeps the gRPC default
+	GRPCMaxConcurrentStreams int // max concurrent streams per connection; 0 keeps the gRPC default
+
+	GRPCReflection *bool // register the gRPC reflection service (default true)
+
+	SlowConsumerAbortMs int // fail a stream with ResourceExhausted once a single Send blocks this long on a slow reader; 0 disables
+
+	// gRPC keepalive (0 keeps the gRPC default)
+	KeepaliveMaxIdleMs           int  // close connections idle for this long (GOAWAY)
+	KeepaliveMaxAgeMs            int  // close connections after this age (GOAWAY)
+	KeepaliveMaxAgeGraceMs       int  // grace period for in-flight RPCs after MaxAge
+	KeepaliveTimeMs              int  // ping clients after this much inactivity
+	KeepaliveTimeoutMs           int  // close the connection when a ping is not acked in time
+	KeepaliveMinTimeMs           int  // minimum client ping interval; faster pings get a GOAWAY
+	KeepalivePermitWithoutStream bool // allow client pings when there are no active streams
+
+	// Connection churn (0 disables)
+	ConnMaxAgeS            int // GOAWAY connections after this many seconds (GRPC_KEEPALIVE_MAX_AGE_MS wins when set)
+	ForcedRestartIntervalS int // stop the gRPC server this often, dropping every connection, and listen again
+
+	// Logging
+	LogLevel           string // debug|info|warn|error; empty keeps the profile's level (debug, info for prod)
+	LogFormat          string // json|console; empty keeps the profile's format (console, json for prod)
+	LogTimestampFormat string // iso8601|rfc3339|rfc3339nano|epoch|millis|nanos; empty keeps the format's (iso8601 for console, epoch for json)
+	LogOutput          string // stdout|stderr or a file path to append the log to; empty is stdout
+	LogSampleRate      int    // only 1 in N requests logs its per-phase debug lines (errors, at warn/error, and the access log always log); 0 or 1 logs all
+	RedactPrompts      bool   // record prompts only as their length and a SHA-256 prefix (logs never carry them); refuses ECHO_PROMPT
+	StatsLogIntervalS  int    // log request count, errors and latency/TTFT percentiles of each interval this long; 0 disables
+
+	// Lifecycle
+	ShutdownGraceMs int    // how long shutdown waits for in-flight streams before stopping forcibly
+	AccessLog       bool   // log one structured line per completed RPC
+	SummaryFile     string // write the end-of-run summary as JSON here on graceful shutdown; empty disables
+	RecordFile      string // append one JSON line per completed gRPC chat request here (HTTP requests aren't recorded); empty disables
+	RecordPrompts   bool   // record full prompts instead of a short summary
+	RecordBuffer    int    // records queued for the writer; past it records are dropped
+	ScenarioFile    string // YAML list of timed phases, each overriding runtime settings; empty disables
+	ScenarioLoop    bool   // start the scenario over after its last phase instead of staying in it
+	FaultSchedule   string // YAML list of faults at exact offsets from startup, overriding error injection; empty disables
+
+	// Admin listener (operator endpoints; started only when one of them is enabled)
+	AdminAddr        string // host:port, localhost only by default
+	PprofEnabled     bool   // serve net/http/pprof under /debug/pprof/
+	LogLevelEndpoint bool   // serve GET/PUT /admin/loglevel to read and change the log level at runtime
+
+	// HTTP endpoints (SSE + provider-compatible shims)
+	HTTPEnabled    bool
+	HTTPPort       int
+	HTTPAddr       string // listen address overriding HTTPPort: host:port or unix:///path/to.sock
+	SinglePort     bool   // serve gRPC and the HTTP endpoints together on the gRPC address (h2c)
+	AzureCompat    bool   // register Azure OpenAI deployment routes
+	SSERoleFirst   bool   // send the SSE role chunk right away and put the TTFT delay before the first content delta
+	SSEKeepaliveMs int    // write `: ping` SSE comments after this much idle time; 0 disables
+
+	GRPCWebEnabled     bool     // serve the gRPC service over gRPC-Web on the HTTP port
+	CORSAllowedOrigins []string // browser origins allowed to call the HTTP endpoints ("*" for any); empty disables CORS
+
+	ModerationFlagRate float64 // share of /v1/moderations inputs flagged in a random category ("[[flag:...]]" markers override it)
+
+	// Auth
+	APIKeys []string // accepted API keys; empty disables auth
+	KeyRPM  int      // requests per minute per API key; 0 disables
+	KeyTPM  int      // tokens per minute per API key; 0 disables
+	PeerRPM int      // requests per minute per client IP (no auth needed); 0 disables
+
+	// gRPC TLS
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string // when set, require client certificates signed by this CA (mTLS)
 }
 
 func getEnvInt(k string, def int) int {
@@ -41,6 +186,17 @@ func getEnvInt(k string, def int) int {
 	}
 	return def
 }
+
+// getEnvIntOpt is getEnvInt for an optional field: nil when k is unset or not a number.
+func getEnvIntOpt(k string) *int {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return &n
+		}
+	}
+	return nil
+}
+
 func getEnvFloat(k string, def float64) float64 {
 	if v := os.Getenv(k); v != "" {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
@@ -49,6 +205,26 @@ func getEnvFloat(k string, def float64) float64 {
 	}
 	return def
 }
+
+// getEnvFloatOpt is getEnvFloat for an optional field: nil when k is unset or not a number.
+func getEnvFloatOpt(k string) *float64 {
+	if v := os.Getenv(k); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return &f
+		}
+	}
+	return nil
+}
+
+// getEnvMode parses an octal file mode such as "0660".
+func getEnvMode(k string, def os.FileMode) os.FileMode {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.ParseUint(v, 8, 32); err == nil {
+			return os.FileMode(n)
+		}
+	}
+	return def
+}
 func getEnvStr(k string, def string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
@@ -56,6 +232,16 @@ func getEnvStr(k string, def string) string {
 	return def
 }
 
+func getEnvList(k string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(k), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func getBool(k string, def bool) bool {
 	if v := os.Getenv(k); v != "" {
 		switch strings.ToLower(v) {
@@ -68,31 +254,215 @@ func getBool(k string, def bool) bool {
 	return def
 }
 
+// getBoolOpt is getBool for an optional field: nil when k is unset or not a boolean.
+func getBoolOpt(k string) *bool {
+	switch v := os.Getenv(k); strings.ToLower(v) {
+	case "1", "true", "yes", "y", "on":
+		return Bool(true)
+	case "0", "false", "no", "n", "off":
+		return Bool(false)
+	}
+	return nil
+}
+
+// LoadConfig reads the config from the environment. The knobs a preset can fill are left unset
+// (nil) when their variable is; ApplyPresetOverrides resolves them.
 func LoadConfig() Config {
 	return Config{
-		Port:             getEnvInt("PORT", 8787),
-		Profile:          getEnvStr("PROFILE", "default"),
-		Preset:           strings.ToLower(getEnvStr("PRESET", "openai")),
-		BaseDelayMs:      getEnvInt("BASE_DELAY_MS", 0),
-		JitterMs:         getEnvInt("JITTER_MS", 0),
-		PerTokenDelayMs:  getEnvInt("PER_TOKEN_DELAY_MS", 0),
-		ErrorRate:        getEnvFloat("ERROR_RATE", 0),
-		ErrorMode:        strings.ToLower(getEnvStr("ERROR_MODE", "mixed")),
-		DefaultTokens:    getEnvInt("DEFAULT_TOKENS", 128),
-		ChunkSize:        getEnvInt("CHUNK_SIZE", 12),
-		StreamDelayMinMs: getEnvInt("STREAM_DELAY_MIN_MS", 0),
-		StreamDelayMaxMs: getEnvInt("STREAM_DELAY_MAX_MS", 0),
-		EchoPrompt:       getBool("ECHO_PROMPT", false),
-		Randomize:        getBool("RANDOMIZE", false),
+		Port:              getEnvInt("PORT", 8787),
+		GRPCAddr:          getEnvStr("GRPC_ADDR", ""),
+		UnixSocketMode:    getEnvMode("UNIX_SOCKET_MODE", 0o660),
+		Profile:           getEnvStr("PROFILE", "default"),
+		Preset:            strings.ToLower(getEnvStr("PRESET", "openai")),
+		BaseDelayMs:       getEnvInt("BASE_DELAY_MS", 0),
+		JitterMs:          getEnvInt("JITTER_MS", 0),
+		PerTokenDelayMs:   getEnvInt("PER_TOKEN_DELAY_MS", 0),
+		CPUBurnUsPerToken: getEnvInt("CPU_BURN_US_PER_TOKEN", 0),
+		MemBytesPerToken:  getEnvInt("MEM_BYTES_PER_TOKEN", 0),
+		MemLimitBytes:     getEnvInt("MEM_LIMIT_BYTES", 0),
+		ErrorRate:         getEnvFloat("ERROR_RATE", 0),
+		ErrorMode:         strings.ToLower(getEnvStr("ERROR_MODE", "mixed")),
+		ErrorTiming:       strings.ToLower(getEnvStr("ERROR_TIMING", "pre")),
+		HTTPResetRate:     getEnvFloat("HTTP_RESET_RATE", 0),
+		EmitFailedChunk:   getBoolOpt("EMIT_FAILED_CHUNK"),
+		ChunkTimestamps:   getBool("CHUNK_TIMESTAMPS", false),
+		EventNaming:       strings.ToLower(getEnvStr("EVENT_NAMING", "openai-responses")),
+		DefaultTokens:     getEnvInt("DEFAULT_TOKENS", 128),
+		ChunkSize:         getEnvIntOpt("CHUNK_SIZE"),
+		StreamDelayMinMs:  getEnvIntOpt("STREAM_DELAY_MIN_MS"),
+		StreamDelayMaxMs:  getEnvIntOpt("STREAM_DELAY_MAX_MS"),
+		EchoPrompt:        getBool("ECHO_PROMPT", false),
+		Randomize:         getBool("RANDOMIZE", false),
+
+		// Stream delay distribution
+		StreamDelayDistribution: strings.ToLower(getEnvStr("STREAM_DELAY_DISTRIBUTION", "uniform")),
+		StreamDelayMeanMs:       getEnvInt("STREAM_DELAY_MEAN_MS", 0),
+		StreamDelayStddevMs:     getEnvInt("STREAM_DELAY_STDDEV_MS", 0),
+		StreamDelayLogSigma:     getEnvFloat("STREAM_DELAY_LOG_SIGMA", 1),
+		StreamDelayCapMs:        getEnvInt("STREAM_DELAY_CAP_MS", 1000),
 
 		// LLM-like timing
-		TTFTMinMs:    getEnvInt("TTFT_MIN_MS", 0),
-		TTFTMaxMs:    getEnvInt("TTFT_MAX_MS", 0),
-		TokensPerSec: getEnvInt("TOKENS_PER_SEC", 120),
+		TTFTMinMs:    getEnvIntOpt("TTFT_MIN_MS"),
+		TTFTMaxMs:    getEnvIntOpt("TTFT_MAX_MS"),
+		TokensPerSec: getEnvFloatOpt("TOKENS_PER_SEC"),
+
+		// Speed curve
+		TPSCurve:       strings.ToLower(getEnvStr("TPS_CURVE", "constant")),
+		TPSCurveTokens: getEnvInt("TPS_CURVE_TOKENS", 100),
+		TPSCurveDepth:  getEnvFloat("TPS_CURVE_DEPTH", 0.5),
+
+		// Bursty arrival
+		BurstMode:  getBool("BURST_MODE", false),
+		BurstSize:  getEnvInt("BURST_SIZE", 4),
+		BurstGapMs: getEnvInt("BURST_GAP_MS", 0),
+
+		// Multi-choice streams
+		ChoiceInterleave: strings.ToLower(getEnvStr("CHOICE_INTERLEAVE", "round_robin")),
+
+		// Endless streams
+		EndlessStream: getBool("ENDLESS_STREAM", false),
+
+		// Per-request overrides
+		AllowRequestOverrides: getBoolOpt("ALLOW_REQUEST_OVERRIDES"),
+
+		// Client request IDs
+		ClientRequestIDFallback: getBool("CLIENT_REQUEST_ID_FALLBACK", false),
+
+		// Request priorities
+		PriorityHighQueueFactor: getEnvFloat("PRIORITY_HIGH_QUEUE_FACTOR", 0),
+		PriorityLowQueueFactor:  getEnvFloatOpt("PRIORITY_LOW_QUEUE_FACTOR"),
+
+		// Tenant profiles
+		TenantProfiles: getEnvStr("TENANT_PROFILES", ""),
+		TenantUnknown:  strings.ToLower(getEnvStr("TENANT_UNKNOWN", "default")),
+
+		// Trace replay
+		ReplayFile:   getEnvStr("REPLAY_FILE", ""),
+		ReplaySelect: strings.ToLower(getEnvStr("REPLAY_SELECT", "round_robin")),
+
+		RejectShortDeadlines: getBool("REJECT_SHORT_DEADLINES", false),
+		StrictValidation:     getBool("STRICT_VALIDATION", false),
+		MaxContextMessages:   getEnvInt("MAX_CONTEXT_MESSAGES", 0),
+		MaxMessageChars:      getEnvInt("MAX_MESSAGE_CHARS", 0),
+		GRPCHeadersFirst:     getBool("GRPC_HEADERS_FIRST", false),
+
+		GRPCKeepaliveChunkMs:   getEnvInt("GRPC_KEEPALIVE_CHUNK_MS", 0),
+		GRPCKeepaliveChunkType: strings.ToLower(getEnvStr("GRPC_KEEPALIVE_CHUNK_TYPE", "ping")),
+
+		MaxRequestDurationMs:           getEnvInt("MAX_REQUEST_DURATION_MS", 0),
+		MaxRequestDurationFinishReason: strings.ToLower(getEnvStr("MAX_REQUEST_DURATION_FINISH_REASON", "length")),
 
 		// Output sizing
 		DebugOutputChars: getEnvInt("DEBUG_OUTPUT_CHARS", 0),
-		MaxOutputChars:   getEnvInt("MAX_OUTPUT_CHARS", 16384),
-		StrictTokenMode:  getBool("STRICT_TOKEN_MODE", true),
+		MaxOutputChars:   getEnvIntOpt("MAX_OUTPUT_CHARS"),
+		StrictTokenMode:  getBoolOpt("STRICT_TOKEN_MODE"),
+
+		// Prompt token accounting
+		PromptTokenAccounting:  strings.ToLower(getEnvStr("PROMPT_TOKEN_ACCOUNTING", "formatted")),
+		PromptTokensPerMessage: getEnvInt("PROMPT_TOKENS_PER_MESSAGE", 4),
+
+		// gRPC transport
+		GRPCCompression:  strings.ToLower(getEnvStr("GRPC_COMPRESSION", "gzip")),
+		GzipChunkDelayMs: getEnvInt("GZIP_CHUNK_DELAY_MS", 0),
+
+		GRPCMaxRecvMB:            getEnvInt("GRPC_MAX_RECV_MB", 0),
+		GRPCMaxSendMB:            getEnvInt("GRPC_MAX_SEND_MB", 0),
+		GRPCMaxConcurrentStreams: getEnvInt("GRPC_MAX_CONCURRENT_STREAMS", 0),
+
+		GRPCReflection: getBoolOpt("GRPC_REFLECTION"),
+
+		SlowConsumerAbortMs: getEnvInt("SLOW_CONSUMER_ABORT_MS", 0),
+
+		// gRPC keepalive
+		KeepaliveMaxIdleMs:           getEnvInt("GRPC_KEEPALIVE_MAX_IDLE_MS", 0),
+		KeepaliveMaxAgeMs:            getEnvInt("GRPC_KEEPALIVE_MAX_AGE_MS", 0),
+		KeepaliveMaxAgeGraceMs:       getEnvInt("GRPC_KEEPALIVE_MAX_AGE_GRACE_MS", 0),
+		KeepaliveTimeMs:              getEnvInt("GRPC_KEEPALIVE_TIME_MS", 0),
+		KeepaliveTimeoutMs:           getEnvInt("GRPC_KEEPALIVE_TIMEOUT_MS", 0),
+		KeepaliveMinTimeMs:           getEnvInt("GRPC_KEEPALIVE_MIN_TIME_MS", 0),
+		KeepalivePermitWithoutStream: getBool("GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", false),
+
+		// Connection churn
+		ConnMaxAgeS:            getEnvInt("CONN_MAX_AGE_S", 0),
+		ForcedRestartIntervalS: getEnvInt("FORCED_RESTART_INTERVAL_S", 0),
+
+		// Logging
+		LogLevel:           strings.ToLower(getEnvStr("LOG_LEVEL", "")),
+		LogFormat:          strings.ToLower(getEnvStr("LOG_FORMAT", "")),
+		LogTimestampFormat: strings.ToLower(getEnvStr("LOG_TIMESTAMP_FORMAT", "")),
+		LogOutput:          getEnvStr("LOG_OUTPUT", ""),
+		LogSampleRate:      getEnvInt("LOG_SAMPLE_RATE", 1),
+		RedactPrompts:      getBool("REDACT_PROMPTS", false),
+		StatsLogIntervalS:  getEnvInt("STATS_LOG_INTERVAL_S", 0),
+
+		// Lifecycle
+		ShutdownGraceMs: getEnvInt("SHUTDOWN_GRACE_MS", 15000),
+		AccessLog:       getBool("ACCESS_LOG", true),
+		SummaryFile:     getEnvStr("SUMMARY_FILE", ""),
+		RecordFile:      getEnvStr("RECORD_FILE", ""),
+		RecordPrompts:   getBool("RECORD_PROMPTS", false),
+		RecordBuffer:    getEnvInt("RECORD_BUFFER", 1024),
+		ScenarioFile:    getEnvStr("SCENARIO_FILE", ""),
+		ScenarioLoop:    getBool("SCENARIO_LOOP", false),
+		FaultSchedule:   getEnvStr("FAULT_SCHEDULE", ""),
+
+		// Admin listener
+		AdminAddr:        getEnvStr("ADMIN_ADDR", "127.0.0.1:6060"),
+		PprofEnabled:     getBool("PPROF_ENABLED", false),
+		LogLevelEndpoint: getBool("LOG_LEVEL_ENDPOINT", false),
+
+		// HTTP endpoints
+		HTTPEnabled:        getBool("HTTP_ENABLED", false),
+		HTTPPort:           getEnvInt("HTTP_PORT", 8788),
+		HTTPAddr:           getEnvStr("HTTP_ADDR", ""),
+		SinglePort:         getBool("SINGLE_PORT", false),
+		AzureCompat:        getBool("AZURE_COMPAT", false),
+		SSERoleFirst:       getBool("SSE_ROLE_FIRST", false),
+		SSEKeepaliveMs:     getEnvInt("SSE_KEEPALIVE_MS", 0),
+		GRPCWebEnabled:     getBool("GRPC_WEB", false),
+		CORSAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS"),
+		ModerationFlagRate: getEnvFloat("MODERATION_FLAG_RATE", 0),
+
+		// Auth
+		APIKeys: getEnvList("API_KEYS"),
+		KeyRPM:  getEnvInt("KEY_RPM", 0),
+		KeyTPM:  getEnvInt("KEY_TPM", 0),
+		PeerRPM: getEnvInt("PEER_RPM", 0),
+
+		// gRPC TLS
+		TLSCertFile:     getEnvStr("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnvStr("TLS_KEY_FILE", ""),
+		TLSClientCAFile: getEnvStr("TLS_CLIENT_CA_FILE", ""),
+	}
+}
+
+// Redacted returns a copy of c that is safe to log: API keys are masked.
+func (c Config) Redacted() Config {
+	if len(c.APIKeys) > 0 {
+		masked := make([]string, len(c.APIKeys))
+		for i := range masked {
+			masked[i] = "***"
+		}
+		c.APIKeys = masked
+	}
+	return c
+}
+
+// TenantProfilesFile returns the file TenantProfiles names, "" when it is empty or holds the
+// profiles inline (a JSON object).
+func (c Config) TenantProfilesFile() string {
+	if v := strings.TrimSpace(c.TenantProfiles); v != "" && !strings.HasPrefix(v, "{") {
+		return v
+	}
+	return ""
+}
+
+// RefuseEchoPrompt turns EchoPrompt off, with a warning, when RedactPrompts is set: echoing would
+// copy into responses the prompts REDACT_PROMPTS keeps out of logs and recordings. Runtime updates
+// setting both are rejected by Validate instead.
+func (c *Config) RefuseEchoPrompt() {
+	if c.RedactPrompts && c.EchoPrompt {
+		logger.Log.Warnw("[config] REDACT_PROMPTS is set, ignoring ECHO_PROMPT")
+		c.EchoPrompt = false
 	}
 }
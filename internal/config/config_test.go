@@ -27,10 +27,10 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.ErrorRate != 0 || cfg.ErrorMode != "mixed" {
 		t.Fatalf("unexpected error config: %+v", cfg)
 	}
-	if cfg.DefaultTokens != 128 || cfg.ChunkSize != 12 {
+	if cfg.DefaultTokens != 128 || cfg.ChunkSize != nil {
 		t.Fatalf("unexpected token defaults: %+v", cfg)
 	}
-	if cfg.StreamDelayMinMs != 0 || cfg.StreamDelayMaxMs != 0 {
+	if cfg.StreamDelayMinMs != nil || cfg.StreamDelayMaxMs != nil {
 		t.Fatalf("unexpected stream delay defaults: %+v", cfg)
 	}
 }
@@ -55,10 +55,34 @@ func TestLoadConfigOverrides(t *testing.T) {
 	if cfg.ErrorRate != 0.5 || cfg.ErrorMode != "500" {
 		t.Fatalf("overrides not applied to error config: %+v", cfg)
 	}
-	if cfg.DefaultTokens != 42 || cfg.ChunkSize != 99 {
+	if cfg.DefaultTokens != 42 || Or(cfg.ChunkSize, 0) != 99 {
 		t.Fatalf("overrides not applied to token config: %+v", cfg)
 	}
-	if cfg.StreamDelayMinMs != 5 || cfg.StreamDelayMaxMs != 7 {
+	if Or(cfg.StreamDelayMinMs, 0) != 5 || Or(cfg.StreamDelayMaxMs, 0) != 7 {
 		t.Fatalf("overrides not applied to stream delays: %+v", cfg)
 	}
 }
+
+func TestRedactedMasksAPIKeys(t *testing.T) {
+	cfg := Config{APIKeys: []string{"sk-one", "sk-two"}, ErrorRate: 0.5}
+	r := cfg.Redacted()
+	if len(r.APIKeys) != 2 || r.APIKeys[0] != "***" || r.APIKeys[1] != "***" || r.ErrorRate != 0.5 {
+		t.Fatalf("unexpected redacted config: %+v", r)
+	}
+	if cfg.APIKeys[0] != "sk-one" {
+		t.Fatalf("Redacted modified the original: %+v", cfg.APIKeys)
+	}
+}
+
+func TestRefuseEchoPrompt(t *testing.T) {
+	cfg := Config{RedactPrompts: true, EchoPrompt: true}
+	cfg.RefuseEchoPrompt()
+	if cfg.EchoPrompt || len(Validate(cfg)) != 0 {
+		t.Fatalf("expected ECHO_PROMPT off under REDACT_PROMPTS: %+v", cfg)
+	}
+	cfg = Config{EchoPrompt: true}
+	cfg.RefuseEchoPrompt()
+	if !cfg.EchoPrompt {
+		t.Fatal("ECHO_PROMPT turned off without REDACT_PROMPTS")
+	}
+}
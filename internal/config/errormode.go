@@ -0,0 +1,40 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// ErrorKind is the failure an ERROR_MODE injects.
+type ErrorKind int
+
+const (
+	ErrorKindMixed     ErrorKind = iota // either of the others, drawn per error
+	ErrorKindRateLimit                  // HTTP 429, gRPC ResourceExhausted
+	ErrorKindInternal                   // HTTP 500, gRPC Internal
+)
+
+// ERROR_MODE aliases by the kind they inject. "" and "mixed" are mixed.
+var (
+	rateLimitErrorModes = []string{"429", "resource_exhausted", "rate_limit", "rate limit"}
+	internalErrorModes  = []string{"500", "internal", "server_error"}
+)
+
+// ErrorModes returns every accepted ERROR_MODE value besides "".
+func ErrorModes() []string {
+	return slices.Concat([]string{"mixed"}, rateLimitErrorModes, internalErrorModes)
+}
+
+// ErrorModeKind returns the kind mode injects, ignoring case and surrounding space. Unknown modes
+// are mixed, like the default.
+func ErrorModeKind(mode string) ErrorKind {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch {
+	case slices.Contains(rateLimitErrorModes, mode):
+		return ErrorKindRateLimit
+	case slices.Contains(internalErrorModes, mode):
+		return ErrorKindInternal
+	default:
+		return ErrorKindMixed
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Holder shares the effective Config between the request handlers and the runtime admin surface.
+// Requests Load a snapshot when they start and keep it until they finish, so an update never
+// changes the behavior of a request already in flight.
+type Holder struct {
+	mu      sync.Mutex // serializes Update
+	cur     atomic.Pointer[Config]
+	updated atomic.Bool // set by the first successful Update
+}
+
+// NewHolder returns a holder initialized with cfg.
+func NewHolder(cfg Config) *Holder {
+	h := &Holder{}
+	h.cur.Store(&cfg)
+	return h
+}
+
+// Load returns the current config.
+func (h *Holder) Load() Config {
+	return *h.cur.Load()
+}
+
+// Update applies fn to a copy of the current config and swaps it in when its values pass Validate
+// (file paths are not rechecked). On failure the current config is kept and the
+// violations are returned joined.
+func (h *Holder) Update(fn func(*Config)) (Config, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next := *h.cur.Load()
+	fn(&next)
+	if errs := validateValues(next); len(errs) > 0 {
+		return *h.cur.Load(), errors.Join(errs...)
+	}
+	h.cur.Store(&next)
+	h.updated.Store(true)
+	return next, nil
+}
+
+// Updated reports whether the config was ever changed with Update (the admin surface), as opposed
+// to being loaded or reloaded from the environment.
+func (h *Holder) Updated() bool {
+	return h.updated.Load()
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHolderUpdate(t *testing.T) {
+	h := NewHolder(Config{ErrorRate: 0.1, TTFTMinMs: Int(10), TTFTMaxMs: Int(20)})
+	before := h.Load()
+	if _, err := h.Update(func(c *Config) { c.ErrorRate = -1 }); err == nil || h.Updated() {
+		t.Fatalf("a rejected update marked the holder updated: %v", err)
+	}
+
+	cfg, err := h.Update(func(c *Config) { c.ErrorRate = 0.5 })
+	if err != nil || cfg.ErrorRate != 0.5 || h.Load().ErrorRate != 0.5 || !h.Updated() {
+		t.Fatalf("update not applied: %+v, %v", cfg, err)
+	}
+	if before.ErrorRate != 0.1 {
+		t.Fatalf("earlier snapshot changed: %+v", before)
+	}
+
+	// An invalid update reports every violation and changes nothing.
+	_, err = h.Update(func(c *Config) {
+		c.ErrorRate = -1
+		c.TTFTMinMs = Int(50)
+		c.ChunkSize = Int(100)
+	})
+	if err == nil || !strings.Contains(err.Error(), "ERROR_RATE") || !strings.Contains(err.Error(), "TTFT_MIN_MS") {
+		t.Fatalf("expected ERROR_RATE and TTFT violations, got %v", err)
+	}
+	if c := h.Load(); c.ErrorRate != 0.5 || *c.TTFTMinMs != 10 || c.ChunkSize != nil {
+		t.Fatalf("rejected update leaked into the config: %+v", c)
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+// The knobs a preset can fill (see presetFields) are pointers: nil means unset, so an explicit
+// zero, such as TOKENS_PER_SEC=0 to turn pacing off, is kept instead of being taken for "use the
+// default". Resolution order: explicit value > preset > builtinDefaults (ApplyPresetOverrides).
+// A Config built in code leaves them nil, and the service reads nil as Or(field, zero value).
+
+// Int returns a pointer to v, for setting an optional Config field.
+func Int(v int) *int { return &v }
+
+// Float returns a pointer to v, for setting an optional Config field.
+func Float(v float64) *float64 { return &v }
+
+// Bool returns a pointer to v, for setting an optional Config field.
+func Bool(v bool) *bool { return &v }
+
+// Or returns *p, or def when p is unset. An explicit zero is returned as is.
+func Or[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// fill sets *dst to v when *dst is unset and reports whether *dst was already set. Config values
+// share these pointers, so nothing may write through them.
+func fill[T any](dst **T, v *T) (wasSet bool) {
+	if *dst != nil {
+		return true
+	}
+	*dst = v
+	return false
+}
@@ -0,0 +1,46 @@
+package config
+
+import "testing"
+
+func TestOptionalResolution(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		env    string // TOKENS_PER_SEC; "" leaves it unset
+		want   float64
+	}{
+		{"unset takes the preset", "openai", "", 35},
+		{"explicit zero beats the preset", "openai", "0", 0},
+		{"explicit value beats the preset", "vllm", "12.5", 12.5},
+		{"unset without a preset takes the default", "none", "", 120},
+		{"explicit zero without a preset", "none", "0", 0},
+		{"unparsable counts as unset", "hybrid", "fast", 35},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearPresetEnv(t)
+			t.Setenv("PRESET", tt.preset)
+			t.Setenv("TOKENS_PER_SEC", tt.env)
+
+			cfg := LoadConfig()
+			if (cfg.TokensPerSec != nil) != (tt.env != "" && tt.env != "fast") {
+				t.Fatalf("LoadConfig presence of TOKENS_PER_SEC=%q: got %v", tt.env, cfg.TokensPerSec)
+			}
+			ApplyPresetOverrides(&cfg)
+			if cfg.TokensPerSec == nil || *cfg.TokensPerSec != tt.want {
+				t.Fatalf("expected %v tok/s, got %v", tt.want, Or(cfg.TokensPerSec, -1))
+			}
+		})
+	}
+}
+
+func TestOr(t *testing.T) {
+	var c Config
+	if Or(c.ChunkSize, 12) != 12 || Or(c.StrictTokenMode, true) != true {
+		t.Fatalf("unset fields should resolve to the default")
+	}
+	c.ChunkSize, c.StrictTokenMode = Int(0), Bool(false)
+	if Or(c.ChunkSize, 12) != 0 || Or(c.StrictTokenMode, true) != false {
+		t.Fatalf("explicit zero values should be kept")
+	}
+}
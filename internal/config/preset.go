@@ -1,41 +1,184 @@
 package config
 
-import "github.com/yungtweek/llm-simulator/internal/logger"
+import (
+	"maps"
+	"slices"
 
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+// rawPresets are the PRESET values that apply no overrides: the environment values are used as is,
+// with builtinDefaults for the unset ones.
+var rawPresets = []string{"none", "off"}
+
+// presetValues holds, per PRESET, the values of the settings in presetFields.
+var presetValues = map[string]Config{
+	// OpenAI-like (general): typical TTFT, moderate throughput, smooth streaming
+	"openai": {
+		TTFTMinMs:        Int(120),
+		TTFTMaxMs:        Int(800),
+		TokensPerSec:     Float(35),
+		ChunkSize:        Int(16),
+		StreamDelayMinMs: Int(8),
+		StreamDelayMaxMs: Int(45),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(12288),
+	},
+
+	// vLLM-like: fast TTFT, high throughput, chunky streaming
+	"vllm": {
+		TTFTMinMs:        Int(30),
+		TTFTMaxMs:        Int(200),
+		TokensPerSec:     Float(90),
+		ChunkSize:        Int(48),
+		StreamDelayMinMs: Int(0),
+		StreamDelayMaxMs: Int(15),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(16384),
+	},
+
+	// Hybrid: balanced, most realistic for production chat
+	"hybrid": {
+		TTFTMinMs:        Int(120),
+		TTFTMaxMs:        Int(700),
+		TokensPerSec:     Float(35),
+		ChunkSize:        Int(16),
+		StreamDelayMinMs: Int(8),
+		StreamDelayMaxMs: Int(50),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(12288),
+	},
+
+	// Anthropic Claude (Sonnet class): long TTFT, very even cadence of small deltas. TTFT and
+	// throughput from the Artificial Analysis provider benchmarks (roughly 0.6-1.8 s, 50-80 tok/s).
+	"anthropic": {
+		TTFTMinMs:        Int(600),
+		TTFTMaxMs:        Int(1800),
+		TokensPerSec:     Float(60),
+		ChunkSize:        Int(8),
+		StreamDelayMinMs: Int(10),
+		StreamDelayMaxMs: Int(25),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(16384),
+	},
+
+	// Gemini Flash: very fast TTFT and the highest throughput of the hosted models, streamed in
+	// large chunks. Artificial Analysis lists roughly 0.2-0.5 s TTFT and 200+ tok/s.
+	"gemini-flash": {
+		TTFTMinMs:        Int(200),
+		TTFTMaxMs:        Int(500),
+		TokensPerSec:     Float(200),
+		ChunkSize:        Int(64),
+		StreamDelayMinMs: Int(0),
+		StreamDelayMaxMs: Int(10),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(16384),
+	},
+
+	// llama.cpp server on CPU (7B, Q4_K_M): prompt processing dominates TTFT and generation runs at
+	// 5-15 tok/s per the CPU results in the llama.cpp benchmark discussions; output is flushed in
+	// large, irregular batches.
+	"llamacpp": {
+		TTFTMinMs:        Int(500),
+		TTFTMaxMs:        Int(3000),
+		TokensPerSec:     Float(8),
+		ChunkSize:        Int(64),
+		StreamDelayMinMs: Int(50),
+		StreamDelayMaxMs: Int(250),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(8192),
+	},
+
+	// Azure OpenAI: OpenAI models with the content filter in the stream path, which buffers output
+	// into bigger, burstier chunks and widens TTFT (see "Content streaming" in the Azure OpenAI
+	// content filtering docs).
+	"azure-openai": {
+		TTFTMinMs:        Int(200),
+		TTFTMaxMs:        Int(1200),
+		TokensPerSec:     Float(30),
+		ChunkSize:        Int(32),
+		StreamDelayMinMs: Int(10),
+		StreamDelayMaxMs: Int(80),
+		StrictTokenMode:  Bool(true),
+		MaxOutputChars:   Int(12288),
+	},
+}
+
+// builtinDefaults fills the knobs left unset by both the environment and the preset (PRESET=none).
+var builtinDefaults = Config{
+	TTFTMinMs:        Int(0),
+	TTFTMaxMs:        Int(0),
+	TokensPerSec:     Float(120),
+	ChunkSize:        Int(12),
+	StreamDelayMinMs: Int(0),
+	StreamDelayMaxMs: Int(0),
+	StrictTokenMode:  Bool(true),
+	MaxOutputChars:   Int(16384),
+}
+
+// presetFields are the settings a preset fills, each with the environment variable that sets it
+// explicitly. fill reports whether dst already had a value.
+var presetFields = []struct {
+	env  string
+	fill func(dst *Config, p Config) (wasSet bool)
+}{
+	{"TTFT_MIN_MS", func(d *Config, p Config) bool { return fill(&d.TTFTMinMs, p.TTFTMinMs) }},
+	{"TTFT_MAX_MS", func(d *Config, p Config) bool { return fill(&d.TTFTMaxMs, p.TTFTMaxMs) }},
+	{"TOKENS_PER_SEC", func(d *Config, p Config) bool { return fill(&d.TokensPerSec, p.TokensPerSec) }},
+	{"CHUNK_SIZE", func(d *Config, p Config) bool { return fill(&d.ChunkSize, p.ChunkSize) }},
+	{"STREAM_DELAY_MIN_MS", func(d *Config, p Config) bool { return fill(&d.StreamDelayMinMs, p.StreamDelayMinMs) }},
+	{"STREAM_DELAY_MAX_MS", func(d *Config, p Config) bool { return fill(&d.StreamDelayMaxMs, p.StreamDelayMaxMs) }},
+	{"STRICT_TOKEN_MODE", func(d *Config, p Config) bool { return fill(&d.StrictTokenMode, p.StrictTokenMode) }},
+	{"MAX_OUTPUT_CHARS", func(d *Config, p Config) bool { return fill(&d.MaxOutputChars, p.MaxOutputChars) }},
+}
+
+// ApplyPresetOverrides fills the settings of cfg.Preset (see presetValues) that cfg leaves unset,
+// then the ones still unset from builtinDefaults. Settings given explicitly, zero included, win over
+// the preset. It logs the fields the preset changed and the skipped ones. "none" and "off" apply
+// only the defaults; unknown presets are rejected by Validate and only logged here.
 func ApplyPresetOverrides(cfg *Config) {
-	logger.Log.Infow("[config] apply profile overrides", "profile", cfg.Preset)
-	switch cfg.Preset {
-	case "openai":
-		// OpenAI-like (general): typical TTFT, moderate throughput, smooth streaming
-		cfg.TTFTMinMs = 120
-		cfg.TTFTMaxMs = 800
-		cfg.TokensPerSec = 35
-		cfg.ChunkSize = 16
-		cfg.StreamDelayMinMs = 8
-		cfg.StreamDelayMaxMs = 45
-		cfg.StrictTokenMode = true
-		cfg.MaxOutputChars = 12288
-
-	case "vllm":
-		// vLLM-like: fast TTFT, high throughput, chunky streaming
-		cfg.TTFTMinMs = 30
-		cfg.TTFTMaxMs = 200
-		cfg.TokensPerSec = 90
-		cfg.ChunkSize = 48
-		cfg.StreamDelayMinMs = 0
-		cfg.StreamDelayMaxMs = 15
-		cfg.StrictTokenMode = true
-		cfg.MaxOutputChars = 16384
-
-	case "hybrid":
-		// Hybrid: balanced, most realistic for production chat
-		cfg.TTFTMinMs = 120
-		cfg.TTFTMaxMs = 700
-		cfg.TokensPerSec = 35
-		cfg.ChunkSize = 16
-		cfg.StreamDelayMinMs = 8
-		cfg.StreamDelayMaxMs = 50
-		cfg.StrictTokenMode = true
-		cfg.MaxOutputChars = 12288
+	defer func() {
+		for _, f := range presetFields {
+			f.fill(cfg, builtinDefaults)
+		}
+	}()
+	if slices.Contains(rawPresets, cfg.Preset) {
+		logger.Log.Infow("[config] no preset, using the environment values", "preset", cfg.Preset)
+		return
+	}
+	p, ok := presetValues[cfg.Preset]
+	if !ok {
+		logger.Log.Warnw("[config] unknown preset, no overrides applied", "preset", cfg.Preset, "valid", presetNames())
+		return
+	}
+	before := *cfg
+	var skipped []string
+	for _, f := range presetFields {
+		if f.fill(cfg, p) {
+			skipped = append(skipped, f.env)
+		}
+	}
+	var changed []string
+	for _, c := range Diff(before, *cfg) {
+		changed = append(changed, c.String())
 	}
+	logger.Log.Infow("[config] apply preset", "preset", cfg.Preset, "changed", changed, "skippedExplicitEnv", skipped)
+}
+
+// UsePreset switches c to the preset name, for a request whose config picks its own preset (see
+// TENANT_PROFILES): every setting in presetFields takes the preset's value, or the built-in default
+// for "none" and "off", whatever c had. Unlike ApplyPresetOverrides it logs nothing.
+func UsePreset(c *Config, name string) {
+	c.Preset = name
+	c.TTFTMinMs, c.TTFTMaxMs, c.TokensPerSec, c.ChunkSize = nil, nil, nil, nil
+	c.StreamDelayMinMs, c.StreamDelayMaxMs, c.StrictTokenMode, c.MaxOutputChars = nil, nil, nil, nil
+	for _, f := range presetFields {
+		f.fill(c, presetValues[name])
+		f.fill(c, builtinDefaults)
+	}
+}
+
+// presetNames returns the valid PRESET values, sorted.
+func presetNames() []string {
+	return slices.Sorted(slices.Values(append(slices.Collect(maps.Keys(presetValues)), rawPresets...)))
 }
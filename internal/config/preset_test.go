@@ -0,0 +1,174 @@
+package config
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+// clearPresetEnv unsets every environment variable a preset can be overridden by.
+func clearPresetEnv(t *testing.T) {
+	t.Helper()
+	for _, f := range presetFields {
+		t.Setenv(f.env, "")
+	}
+}
+
+// withDefaults fills the unset knobs of cfg from builtinDefaults only.
+func withDefaults(cfg Config) Config {
+	for _, f := range presetFields {
+		f.fill(&cfg, builtinDefaults)
+	}
+	return cfg
+}
+
+func TestExplicitEnvWinsOverPreset(t *testing.T) {
+	for _, preset := range slices.Sorted(maps.Keys(presetValues)) {
+		t.Run(preset, func(t *testing.T) {
+			clearPresetEnv(t)
+			t.Setenv("PRESET", preset)
+			t.Setenv("TOKENS_PER_SEC", "200")
+			t.Setenv("TTFT_MIN_MS", "5")
+			t.Setenv("STRICT_TOKEN_MODE", "false")
+
+			cfg := LoadConfig()
+			ApplyPresetOverrides(&cfg)
+
+			if *cfg.TokensPerSec != 200 || *cfg.TTFTMinMs != 5 || *cfg.StrictTokenMode {
+				t.Fatalf("explicit env overridden by preset %s: %+v", preset, cfg)
+			}
+			p := presetValues[preset]
+			if *cfg.TTFTMaxMs != *p.TTFTMaxMs || *cfg.ChunkSize != *p.ChunkSize || *cfg.MaxOutputChars != *p.MaxOutputChars ||
+				*cfg.StreamDelayMinMs != *p.StreamDelayMinMs || *cfg.StreamDelayMaxMs != *p.StreamDelayMaxMs {
+				t.Fatalf("preset %s not applied to unset fields: %+v", preset, cfg)
+			}
+		})
+	}
+}
+
+func TestExplicitZeroWinsOverPreset(t *testing.T) {
+	clearPresetEnv(t)
+	t.Setenv("PRESET", "openai")
+	t.Setenv("TOKENS_PER_SEC", "0") // pacing off
+
+	cfg := LoadConfig()
+	ApplyPresetOverrides(&cfg)
+	if *cfg.TokensPerSec != 0 {
+		t.Fatalf("expected TOKENS_PER_SEC=0 to survive the preset, got %v", *cfg.TokensPerSec)
+	}
+}
+
+func TestApplyPresetOverrides(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	prev := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	t.Cleanup(func() { logger.Log = prev })
+
+	clearPresetEnv(t)
+	t.Setenv("TTFT_MIN_MS", "")
+	t.Setenv("TOKENS_PER_SEC", "")
+
+	t.Run("none", func(t *testing.T) {
+		for _, preset := range rawPresets {
+			t.Setenv("PRESET", preset)
+			cfg := LoadConfig()
+			ApplyPresetOverrides(&cfg)
+			if d := Diff(withDefaults(LoadConfig()), cfg); len(d) != 0 {
+				t.Fatalf("PRESET=%s changed %v", preset, d)
+			}
+			if errs := Validate(cfg); len(errs) != 0 {
+				t.Fatalf("PRESET=%s is invalid: %v", preset, errs)
+			}
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		logs.TakeAll()
+		t.Setenv("PRESET", "vllm")
+		t.Setenv("CHUNK_SIZE", "4")
+		cfg := LoadConfig()
+		ApplyPresetOverrides(&cfg)
+		if *cfg.TTFTMaxMs != 200 || *cfg.ChunkSize != 4 {
+			t.Fatalf("vllm not applied around CHUNK_SIZE: %+v", cfg)
+		}
+		entries := logs.FilterMessage("[config] apply preset").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one preset log, got %v", logs.All())
+		}
+		changed := entries[0].ContextMap()["changed"].([]interface{})
+		if !slices.Contains(changed, interface{}("TTFTMaxMs: unset -> 200")) || slices.ContainsFunc(changed, func(c interface{}) bool {
+			return strings.HasPrefix(c.(string), "ChunkSize")
+		}) {
+			t.Fatalf("unexpected changed fields: %v", changed)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("PRESET", "openia")
+		cfg := LoadConfig()
+		ApplyPresetOverrides(&cfg)
+		if d := Diff(withDefaults(LoadConfig()), cfg); len(d) != 0 {
+			t.Fatalf("unknown preset changed %v", d)
+		}
+		errs := Validate(cfg)
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"none"`) || !strings.Contains(errs[0].Error(), `"vllm"`) {
+			t.Fatalf("expected a PRESET violation listing the valid presets, got %v", errs)
+		}
+	})
+}
+
+func TestPresetValues(t *testing.T) {
+	tests := []struct {
+		preset           string
+		ttftMin, ttftMax int
+		tokensPerSec     float64
+		chunkSize        int
+		delayMin         int
+		delayMax         int
+	}{
+		{"openai", 120, 800, 35, 16, 8, 45},
+		{"vllm", 30, 200, 90, 48, 0, 15},
+		{"hybrid", 120, 700, 35, 16, 8, 50},
+		{"anthropic", 600, 1800, 60, 8, 10, 25},
+		{"gemini-flash", 200, 500, 200, 64, 0, 10},
+		{"llamacpp", 500, 3000, 8, 64, 50, 250},
+		{"azure-openai", 200, 1200, 30, 32, 10, 80},
+	}
+	if len(tests) != len(presetValues) {
+		t.Fatalf("%d presets tested, %d defined", len(tests), len(presetValues))
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			clearPresetEnv(t)
+			t.Setenv("PRESET", tt.preset)
+			cfg := LoadConfig()
+			ApplyPresetOverrides(&cfg)
+			if *cfg.TTFTMinMs != tt.ttftMin || *cfg.TTFTMaxMs != tt.ttftMax || *cfg.TokensPerSec != tt.tokensPerSec ||
+				*cfg.ChunkSize != tt.chunkSize || *cfg.StreamDelayMinMs != tt.delayMin || *cfg.StreamDelayMaxMs != tt.delayMax {
+				t.Fatalf("unexpected %s values: %+v", tt.preset, cfg)
+			}
+			if !*cfg.StrictTokenMode || *cfg.MaxOutputChars <= 0 {
+				t.Fatalf("%s: expected strict token mode and an output cap: %+v", tt.preset, cfg)
+			}
+		})
+	}
+}
+
+func TestUsePreset(t *testing.T) {
+	cfg := Config{Preset: "openai", BaseDelayMs: 7, ChunkSize: Int(4)}
+	ApplyPresetOverrides(&cfg)
+	UsePreset(&cfg, "vllm")
+	if cfg.Preset != "vllm" || *cfg.ChunkSize != 48 || *cfg.TTFTMaxMs != 200 || cfg.BaseDelayMs != 7 {
+		t.Fatalf("vllm not applied over the explicit chunk size, or other settings changed: %+v", cfg)
+	}
+	UsePreset(&cfg, "none")
+	if d := Diff(withDefaults(Config{Preset: "none", BaseDelayMs: 7}), cfg); len(d) != 0 {
+		t.Fatalf("PRESET none left %v", d)
+	}
+}
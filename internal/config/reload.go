@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+// runtimeFields are the Config fields read at the start of each request, which a reload can
+// change. Everything else (listeners, TLS, auth, limits, interceptors) is wired up at startup and
+// needs a restart.
+var runtimeFields = map[string]bool{
+	"Preset":                         true,
+	"BaseDelayMs":                    true,
+	"JitterMs":                       true,
+	"PerTokenDelayMs":                true,
+	"CPUBurnUsPerToken":              true,
+	"MemBytesPerToken":               true,
+	"MemLimitBytes":                  true,
+	"ErrorRate":                      true,
+	"ErrorMode":                      true,
+	"ErrorTiming":                    true,
+	"HTTPResetRate":                  true,
+	"EmitFailedChunk":                true,
+	"ChunkTimestamps":                true,
+	"EventNaming":                    true,
+	"DefaultTokens":                  true,
+	"ChunkSize":                      true,
+	"StreamDelayMinMs":               true,
+	"StreamDelayMaxMs":               true,
+	"EchoPrompt":                     true,
+	"Randomize":                      true,
+	"StreamDelayDistribution":        true,
+	"StreamDelayMeanMs":              true,
+	"StreamDelayStddevMs":            true,
+	"StreamDelayLogSigma":            true,
+	"StreamDelayCapMs":               true,
+	"TTFTMinMs":                      true,
+	"TTFTMaxMs":                      true,
+	"TokensPerSec":                   true,
+	"TPSCurve":                       true,
+	"TPSCurveTokens":                 true,
+	"TPSCurveDepth":                  true,
+	"BurstMode":                      true,
+	"BurstSize":                      true,
+	"BurstGapMs":                     true,
+	"ChoiceInterleave":               true,
+	"EndlessStream":                  true,
+	"AllowRequestOverrides":          true,
+	"ClientRequestIDFallback":        true,
+	"PriorityHighQueueFactor":        true,
+	"PriorityLowQueueFactor":         true,
+	"ReplaySelect":                   true,
+	"RejectShortDeadlines":           true,
+	"StrictValidation":               true,
+	"MaxContextMessages":             true,
+	"MaxMessageChars":                true,
+	"GRPCHeadersFirst":               true,
+	"GRPCKeepaliveChunkMs":           true,
+	"GRPCKeepaliveChunkType":         true,
+	"MaxRequestDurationMs":           true,
+	"MaxRequestDurationFinishReason": true,
+	"DebugOutputChars":               true,
+	"MaxOutputChars":                 true,
+	"StrictTokenMode":                true,
+	"PromptTokenAccounting":          true,
+	"PromptTokensPerMessage":         true,
+	"GzipChunkDelayMs":               true,
+	"SSERoleFirst":                   true,
+	"SSEKeepaliveMs":                 true,
+	"ModerationFlagRate":             true,
+	"RecordPrompts":                  true,
+	"LogSampleRate":                  true,
+	"SlowConsumerAbortMs":            true,
+}
+
+// Change is one Config field that differs between two configs.
+type Change struct {
+	Field    string
+	Old, New any
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+}
+
+// Diff lists the fields that differ from a to b, in declaration order. Optional fields are
+// compared and reported by value, "unset" when nil.
+func Diff(a, b Config) []Change {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var changes []Change
+	for i := range va.NumField() {
+		fa, fb := fieldValue(va.Field(i)), fieldValue(vb.Field(i))
+		if !reflect.DeepEqual(fa, fb) {
+			changes = append(changes, Change{Field: va.Type().Field(i).Name, Old: fa, New: fb})
+		}
+	}
+	return changes
+}
+
+// fieldValue returns the value of a Config field, dereferencing optional ones.
+func fieldValue(v reflect.Value) any {
+	if v.Kind() != reflect.Pointer {
+		return v.Interface()
+	}
+	if v.IsNil() {
+		return "unset"
+	}
+	return v.Elem().Interface()
+}
+
+// Reload swaps next in as the current config. Fields that cannot change at runtime keep their
+// current values and are returned in ignored; the applied changes are returned in changed. When
+// the values of next fail Validate nothing changes.
+func (h *Holder) Reload(next Config) (changed []Change, ignored []string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cur := *h.cur.Load()
+	vn, vc := reflect.ValueOf(&next).Elem(), reflect.ValueOf(cur)
+	for _, c := range Diff(cur, next) {
+		if runtimeFields[c.Field] {
+			changed = append(changed, c)
+			continue
+		}
+		ignored = append(ignored, c.Field)
+		vn.FieldByName(c.Field).Set(vc.FieldByName(c.Field))
+	}
+	if errs := validateValues(next); len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	h.cur.Store(&next)
+	return changed, ignored, nil
+}
+
+// ReloadFromEnv re-reads the config from the environment exactly like startup (LoadConfig, then
+// ApplyPresetOverrides) and applies it to h with Reload, logging the outcome. Requests in flight
+// keep the config they started with.
+func (h *Holder) ReloadFromEnv() error {
+	next := LoadConfig()
+	ApplyPresetOverrides(&next)
+	changed, ignored, err := h.Reload(next)
+	if err != nil {
+		logger.Log.Errorw("[config] reload rejected, keeping the current config", "err", err)
+		return err
+	}
+	if len(ignored) > 0 {
+		logger.Log.Warnw("[config] reload ignores settings that need a restart", "fields", ignored)
+	}
+	diff := make([]string, len(changed))
+	for i, c := range changed {
+		diff[i] = c.String()
+	}
+	logger.Log.Infow("[config] reloaded", "changed", diff)
+	return nil
+}
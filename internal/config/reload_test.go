@@ -0,0 +1,67 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+func TestReloadFromEnv(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	prev := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	t.Cleanup(func() { logger.Log = prev })
+
+	t.Setenv("PRESET", "hybrid")
+	t.Setenv("PORT", "9000")
+	t.Setenv("ERROR_RATE", "0")
+	t.Setenv("BASE_DELAY_MS", "20")
+	t.Setenv("ERROR_MODE", "mixed")
+	t.Setenv("TLS_CERT_FILE", "")
+	start := LoadConfig()
+	ApplyPresetOverrides(&start)
+	h := NewHolder(start)
+	inFlight := h.Load()
+
+	t.Setenv("PORT", "9100")
+	t.Setenv("TLS_CERT_FILE", "/tmp/cert.pem")
+	t.Setenv("ERROR_RATE", "0.25")
+	t.Setenv("BASE_DELAY_MS", "40")
+	if err := h.ReloadFromEnv(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	cfg := h.Load()
+	if cfg.ErrorRate != 0.25 || cfg.BaseDelayMs != 40 {
+		t.Fatalf("runtime settings not reloaded: %+v", cfg)
+	}
+	if cfg.Port != 9000 || cfg.TLSCertFile != "" {
+		t.Fatalf("startup-only settings changed: port=%d cert=%q", cfg.Port, cfg.TLSCertFile)
+	}
+	if inFlight.ErrorRate != 0 || inFlight.BaseDelayMs != 20 {
+		t.Fatalf("an earlier snapshot changed: %+v", inFlight)
+	}
+
+	warn := logs.FilterMessage("[config] reload ignores settings that need a restart").All()
+	if len(warn) != 1 || !slices.Equal(warn[0].ContextMap()["fields"].([]interface{}), []interface{}{"Port", "TLSCertFile"}) {
+		t.Fatalf("expected a warning listing Port and TLSCertFile, got %v", warn)
+	}
+	info := logs.FilterMessage("[config] reloaded").All()
+	if len(info) != 1 || !slices.Equal(info[0].ContextMap()["changed"].([]interface{}), []interface{}{"BaseDelayMs: 20 -> 40", "ErrorRate: 0 -> 0.25"}) {
+		t.Fatalf("unexpected diff log: %v", info)
+	}
+
+	// An invalid environment is rejected as a whole.
+	t.Setenv("BASE_DELAY_MS", "60")
+	t.Setenv("ERROR_MODE", "sometimes")
+	if err := h.ReloadFromEnv(); err == nil {
+		t.Fatal("expected the unknown ERROR_MODE to be rejected")
+	}
+	if cfg := h.Load(); cfg.BaseDelayMs != 40 || cfg.ErrorMode != "mixed" {
+		t.Fatalf("rejected reload leaked into the config: %+v", cfg)
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/yungtweek/llm-simulator/events"
+)
+
+// Accepted values of the enumerated settings ("" keeps the default behavior).
+var (
+	errorModes       = append([]string{""}, ErrorModes()...)
+	errorTimings     = []string{"", "pre", "mid", "mixed"}
+	grpcCompressions = []string{"", "gzip", "off"}
+	tpsCurves        = []string{"", "constant", "rampup", "decay", "sine"}
+	delayDists       = []string{"", "uniform", "normal", "lognormal"}
+	tokenAccountings = []string{"", "formatted", "content_only"}
+	replaySelects    = []string{"", "round_robin", "model"}
+	logLevels        = []string{"", "debug", "info", "warn", "error"}
+	logFormats       = []string{"", "json", "console"}
+	logTimestamps    = []string{"", "iso8601", "rfc3339", "rfc3339nano", "epoch", "millis", "nanos"}
+	cappedReasons    = []string{"", "length", "timeout"}
+	choiceOrders     = []string{"", "round_robin", "random", "sequential"}
+	tenantUnknowns   = []string{"", "default", "reject"}
+	keepaliveChunks  = []string{"", "ping", "delta"}
+)
+
+// Validate reports every setting in c that makes no sense: rates outside [0, 1], negative delays,
+// sizes and limits, ports out of range, min/max pairs with min > max, unknown modes and presets,
+// and configured files (TLS, replay, scenario, fault schedule, tenant profiles) that are incomplete
+// or unreadable. The request handlers still clamp such values, but a config that passes Validate
+// never relies on it. main refuses to start with violations.
+func Validate(c Config) []error {
+	return append(validateValues(c), validatePaths(c)...)
+}
+
+// validateValues is Validate without the file checks, for runtime updates.
+func validateValues(c Config) []error {
+	var errs []error
+	if c.ErrorRate < 0 || c.ErrorRate > 1 {
+		errs = append(errs, fmt.Errorf("ERROR_RATE must be within [0, 1], got %v", c.ErrorRate))
+	}
+	if c.RedactPrompts && c.EchoPrompt {
+		errs = append(errs, fmt.Errorf("ECHO_PROMPT can't be enabled with REDACT_PROMPTS: it copies prompts into responses"))
+	}
+	if c.HTTPResetRate < 0 || c.HTTPResetRate > 1 {
+		errs = append(errs, fmt.Errorf("HTTP_RESET_RATE must be within [0, 1], got %v", c.HTTPResetRate))
+	}
+	if c.ModerationFlagRate < 0 || c.ModerationFlagRate > 1 {
+		errs = append(errs, fmt.Errorf("MODERATION_FLAG_RATE must be within [0, 1], got %v", c.ModerationFlagRate))
+	}
+	if tps := Or(c.TokensPerSec, 0); !(tps >= 0) || math.IsInf(tps, 1) {
+		errs = append(errs, fmt.Errorf("TOKENS_PER_SEC must be a finite number >= 0, got %v", tps))
+	}
+	if !(c.StreamDelayLogSigma >= 0) || math.IsInf(c.StreamDelayLogSigma, 1) {
+		errs = append(errs, fmt.Errorf("STREAM_DELAY_LOG_SIGMA must be a finite number >= 0, got %v", c.StreamDelayLogSigma))
+	}
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{
+		{"PRIORITY_HIGH_QUEUE_FACTOR", c.PriorityHighQueueFactor},
+		{"PRIORITY_LOW_QUEUE_FACTOR", Or(c.PriorityLowQueueFactor, 0)},
+	} {
+		if !(f.v >= 0) || math.IsInf(f.v, 1) {
+			errs = append(errs, fmt.Errorf("%s must be a finite number >= 0, got %v", f.name, f.v))
+		}
+	}
+	if !(c.TPSCurveDepth >= 0 && c.TPSCurveDepth < 1) {
+		errs = append(errs, fmt.Errorf("TPS_CURVE_DEPTH must be within [0, 1), got %v", c.TPSCurveDepth))
+	}
+	for _, f := range []struct {
+		name string
+		v    int
+	}{
+		{"BASE_DELAY_MS", c.BaseDelayMs},
+		{"JITTER_MS", c.JitterMs},
+		{"PER_TOKEN_DELAY_MS", c.PerTokenDelayMs},
+		{"TPS_CURVE_TOKENS", c.TPSCurveTokens},
+		{"BURST_SIZE", c.BurstSize},
+		{"BURST_GAP_MS", c.BurstGapMs},
+		{"CPU_BURN_US_PER_TOKEN", c.CPUBurnUsPerToken},
+		{"MEM_BYTES_PER_TOKEN", c.MemBytesPerToken},
+		{"MEM_LIMIT_BYTES", c.MemLimitBytes},
+		{"DEFAULT_TOKENS", c.DefaultTokens},
+		{"CHUNK_SIZE", Or(c.ChunkSize, 0)},
+		{"STREAM_DELAY_MIN_MS", Or(c.StreamDelayMinMs, 0)},
+		{"STREAM_DELAY_MAX_MS", Or(c.StreamDelayMaxMs, 0)},
+		{"STREAM_DELAY_MEAN_MS", c.StreamDelayMeanMs},
+		{"STREAM_DELAY_STDDEV_MS", c.StreamDelayStddevMs},
+		{"STREAM_DELAY_CAP_MS", c.StreamDelayCapMs},
+		{"TTFT_MIN_MS", Or(c.TTFTMinMs, 0)},
+		{"TTFT_MAX_MS", Or(c.TTFTMaxMs, 0)},
+		{"DEBUG_OUTPUT_CHARS", c.DebugOutputChars},
+		{"MAX_OUTPUT_CHARS", Or(c.MaxOutputChars, 0)},
+		{"PROMPT_TOKENS_PER_MESSAGE", c.PromptTokensPerMessage},
+		{"GZIP_CHUNK_DELAY_MS", c.GzipChunkDelayMs},
+		{"GRPC_MAX_RECV_MB", c.GRPCMaxRecvMB},
+		{"GRPC_MAX_SEND_MB", c.GRPCMaxSendMB},
+		{"GRPC_MAX_CONCURRENT_STREAMS", c.GRPCMaxConcurrentStreams},
+		{"SLOW_CONSUMER_ABORT_MS", c.SlowConsumerAbortMs},
+		{"MAX_REQUEST_DURATION_MS", c.MaxRequestDurationMs},
+		{"MAX_CONTEXT_MESSAGES", c.MaxContextMessages},
+		{"MAX_MESSAGE_CHARS", c.MaxMessageChars},
+		{"GRPC_KEEPALIVE_CHUNK_MS", c.GRPCKeepaliveChunkMs},
+		{"GRPC_KEEPALIVE_MAX_IDLE_MS", c.KeepaliveMaxIdleMs},
+		{"GRPC_KEEPALIVE_MAX_AGE_MS", c.KeepaliveMaxAgeMs},
+		{"GRPC_KEEPALIVE_MAX_AGE_GRACE_MS", c.KeepaliveMaxAgeGraceMs},
+		{"GRPC_KEEPALIVE_TIME_MS", c.KeepaliveTimeMs},
+		{"GRPC_KEEPALIVE_TIMEOUT_MS", c.KeepaliveTimeoutMs},
+		{"GRPC_KEEPALIVE_MIN_TIME_MS", c.KeepaliveMinTimeMs},
+		{"CONN_MAX_AGE_S", c.ConnMaxAgeS},
+		{"FORCED_RESTART_INTERVAL_S", c.ForcedRestartIntervalS},
+		{"SHUTDOWN_GRACE_MS", c.ShutdownGraceMs},
+		{"RECORD_BUFFER", c.RecordBuffer},
+		{"LOG_SAMPLE_RATE", c.LogSampleRate},
+		{"STATS_LOG_INTERVAL_S", c.StatsLogIntervalS},
+		{"SSE_KEEPALIVE_MS", c.SSEKeepaliveMs},
+		{"KEY_RPM", c.KeyRPM},
+		{"KEY_TPM", c.KeyTPM},
+		{"PEER_RPM", c.PeerRPM},
+	} {
+		if f.v < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", f.name, f.v))
+		}
+	}
+	for _, p := range []struct {
+		name string
+		v    int
+	}{
+		{"PORT", c.Port},
+		{"HTTP_PORT", c.HTTPPort},
+	} {
+		if p.v < 0 || p.v > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be within [0, 65535], got %d", p.name, p.v))
+		}
+	}
+	for _, e := range []struct {
+		name, v string
+		allowed []string
+	}{
+		{"ERROR_MODE", c.ErrorMode, errorModes},
+		{"ERROR_TIMING", c.ErrorTiming, errorTimings},
+		{"PRESET", c.Preset, append([]string{""}, presetNames()...)},
+		{"GRPC_COMPRESSION", c.GRPCCompression, grpcCompressions},
+		{"TPS_CURVE", c.TPSCurve, tpsCurves},
+		{"STREAM_DELAY_DISTRIBUTION", c.StreamDelayDistribution, delayDists},
+		{"EVENT_NAMING", c.EventNaming, eventNamings()},
+		{"PROMPT_TOKEN_ACCOUNTING", c.PromptTokenAccounting, tokenAccountings},
+		{"REPLAY_SELECT", c.ReplaySelect, replaySelects},
+		{"LOG_LEVEL", c.LogLevel, logLevels},
+		{"LOG_FORMAT", c.LogFormat, logFormats},
+		{"LOG_TIMESTAMP_FORMAT", c.LogTimestampFormat, logTimestamps},
+		{"MAX_REQUEST_DURATION_FINISH_REASON", c.MaxRequestDurationFinishReason, cappedReasons},
+		{"CHOICE_INTERLEAVE", c.ChoiceInterleave, choiceOrders},
+		{"TENANT_UNKNOWN", c.TenantUnknown, tenantUnknowns},
+		{"GRPC_KEEPALIVE_CHUNK_TYPE", c.GRPCKeepaliveChunkType, keepaliveChunks},
+	} {
+		if !slices.Contains(e.allowed, e.v) {
+			errs = append(errs, fmt.Errorf("%s must be one of %q, got %q", e.name, e.allowed[1:], e.v))
+		}
+	}
+	if lo, hi := Or(c.TTFTMinMs, 0), Or(c.TTFTMaxMs, 0); hi > 0 && lo > hi {
+		errs = append(errs, fmt.Errorf("TTFT_MIN_MS (%d) must not exceed TTFT_MAX_MS (%d)", lo, hi))
+	}
+	if lo, hi := Or(c.StreamDelayMinMs, 0), Or(c.StreamDelayMaxMs, 0); hi > 0 && lo > hi {
+		errs = append(errs, fmt.Errorf("STREAM_DELAY_MIN_MS (%d) must not exceed STREAM_DELAY_MAX_MS (%d)", lo, hi))
+	}
+	return errs
+}
+
+// eventNamings returns the accepted EVENT_NAMING values.
+func eventNamings() []string {
+	names := []string{""}
+	for _, n := range events.Namings {
+		names = append(names, string(n))
+	}
+	return names
+}
+
+// validatePaths checks that the configured files can be read.
+func validatePaths(c Config) []error {
+	var errs []error
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE"))
+	}
+	for _, f := range []struct{ name, path string }{
+		{"TLS_CERT_FILE", c.TLSCertFile},
+		{"TLS_KEY_FILE", c.TLSKeyFile},
+		{"TLS_CLIENT_CA_FILE", c.TLSClientCAFile},
+		{"REPLAY_FILE", c.ReplayFile},
+		{"SCENARIO_FILE", c.ScenarioFile},
+		{"FAULT_SCHEDULE", c.FaultSchedule},
+		{"TENANT_PROFILES", c.TenantProfilesFile()},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.ReadFile(f.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not readable: %w", f.name, err))
+		}
+	}
+	return errs
+}
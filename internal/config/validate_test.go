@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(cert, []byte("cert"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"rate above one", Config{ErrorRate: 1.5}, "ERROR_RATE"},
+		{"moderation flag rate above 1", Config{ModerationFlagRate: 2}, "MODERATION_FLAG_RATE"},
+		{"negative reset rate", Config{HTTPResetRate: -0.5}, "HTTP_RESET_RATE"},
+		{"unknown log timestamp format", Config{LogTimestampFormat: "unix"}, "LOG_TIMESTAMP_FORMAT"},
+		{"negative rate", Config{ErrorRate: -0.1}, "ERROR_RATE"},
+		{"negative delay", Config{BaseDelayMs: -1}, "BASE_DELAY_MS"},
+		{"negative jitter", Config{JitterMs: -1}, "JITTER_MS"},
+		{"negative chunk", Config{ChunkSize: Int(-4)}, "CHUNK_SIZE"},
+		{"negative tokens per sec", Config{TokensPerSec: Float(-1)}, "TOKENS_PER_SEC"},
+		{"unknown tps curve", Config{TPSCurve: "linear"}, "TPS_CURVE"},
+		{"tps curve depth of one", Config{TPSCurveDepth: 1}, "TPS_CURVE_DEPTH"},
+		{"negative priority factor", Config{PriorityLowQueueFactor: Float(-1)}, "PRIORITY_LOW_QUEUE_FACTOR"},
+		{"negative burst gap", Config{BurstGapMs: -1}, "BURST_GAP_MS"},
+		{"unknown replay select", Config{ReplaySelect: "random"}, "REPLAY_SELECT"},
+		{"unreadable replay file", Config{ReplayFile: missing}, "REPLAY_FILE is not readable"},
+		{"unreadable scenario file", Config{ScenarioFile: missing}, "SCENARIO_FILE is not readable"},
+		{"unreadable fault schedule", Config{FaultSchedule: missing}, "FAULT_SCHEDULE is not readable"},
+		{"unknown delay distribution", Config{StreamDelayDistribution: "pareto"}, "STREAM_DELAY_DISTRIBUTION"},
+		{"negative log sigma", Config{StreamDelayLogSigma: -1}, "STREAM_DELAY_LOG_SIGMA"},
+		{"negative keepalive", Config{KeepaliveTimeMs: -1}, "GRPC_KEEPALIVE_TIME_MS"},
+		{"negative restart interval", Config{ForcedRestartIntervalS: -5}, "FORCED_RESTART_INTERVAL_S"},
+		{"negative message limit", Config{MaxContextMessages: -1}, "MAX_CONTEXT_MESSAGES"},
+		{"negative key limit", Config{KeyRPM: -1}, "KEY_RPM"},
+		{"negative record buffer", Config{RecordBuffer: -1}, "RECORD_BUFFER"},
+		{"unknown log level", Config{LogLevel: "trace"}, "LOG_LEVEL"},
+		{"unknown log format", Config{LogFormat: "logfmt"}, "LOG_FORMAT"},
+		{"echo with redacted prompts", Config{RedactPrompts: true, EchoPrompt: true}, "REDACT_PROMPTS"},
+		{"negative log sample rate", Config{LogSampleRate: -2}, "LOG_SAMPLE_RATE"},
+		{"negative stats log interval", Config{StatsLogIntervalS: -1}, "STATS_LOG_INTERVAL_S"},
+		{"port out of range", Config{Port: 70000}, "PORT"},
+		{"negative http port", Config{HTTPPort: -1}, "HTTP_PORT"},
+		{"ttft min > max", Config{TTFTMinMs: Int(30), TTFTMaxMs: Int(20)}, "TTFT_MIN_MS"},
+		{"stream delay min > max", Config{StreamDelayMinMs: Int(9), StreamDelayMaxMs: Int(3)}, "STREAM_DELAY_MIN_MS"},
+		{"unknown error mode", Config{ErrorMode: "503"}, "ERROR_MODE"},
+		{"unknown error timing", Config{ErrorTiming: "late"}, "ERROR_TIMING"},
+		{"unknown event naming", Config{EventNaming: "gemini"}, "EVENT_NAMING"},
+		{"unknown token accounting", Config{PromptTokenAccounting: "tiktoken"}, "PROMPT_TOKEN_ACCOUNTING"},
+		{"negative per-message tokens", Config{PromptTokensPerMessage: -4}, "PROMPT_TOKENS_PER_MESSAGE"},
+		{"unknown preset", Config{Preset: "openia"}, "PRESET"},
+		{"unknown compression", Config{GRPCCompression: "zstd"}, "GRPC_COMPRESSION"},
+		{"negative slow consumer abort", Config{SlowConsumerAbortMs: -1}, "SLOW_CONSUMER_ABORT_MS"},
+		{"negative max request duration", Config{MaxRequestDurationMs: -1}, "MAX_REQUEST_DURATION_MS"},
+		{"unknown capped finish reason", Config{MaxRequestDurationFinishReason: "cut"}, "MAX_REQUEST_DURATION_FINISH_REASON"},
+		{"unknown choice interleave", Config{ChoiceInterleave: "zipper"}, "CHOICE_INTERLEAVE"},
+		{"unknown keep-alive chunk type", Config{GRPCKeepaliveChunkType: "comment"}, "GRPC_KEEPALIVE_CHUNK_TYPE"},
+		{"cert without key", Config{TLSCertFile: cert}, "set together"},
+		{"client CA without cert", Config{TLSClientCAFile: cert}, "TLS_CLIENT_CA_FILE requires"},
+		{"unreadable key", Config{TLSCertFile: cert, TLSKeyFile: missing}, "TLS_KEY_FILE is not readable"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := Validate(tc.cfg)
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tc.want) {
+				t.Fatalf("expected one %q violation, got %v", tc.want, errs)
+			}
+		})
+	}
+
+	// Every violation is reported, not just the first.
+	if errs := Validate(Config{ErrorRate: 2, ChunkSize: Int(-1), TTFTMinMs: Int(5), TTFTMaxMs: Int(1)}); len(errs) != 3 {
+		t.Fatalf("expected 3 violations, got %v", errs)
+	}
+
+	// A lone min (max 0) is allowed: it means a fixed TTFT.
+	valid := Config{ErrorRate: 1, ErrorMode: "429", ErrorTiming: "mid", Preset: "vllm", GRPCCompression: "off", TTFTMinMs: Int(50), TokensPerSec: Float(35), Port: 8787, TLSCertFile: cert, TLSKeyFile: cert, TLSClientCAFile: cert}
+	if errs := Validate(valid); len(errs) != 0 {
+		t.Fatalf("expected a valid config, got %v", errs)
+	}
+}
+
+func TestLoadConfigDefaultsAreValid(t *testing.T) {
+	for _, k := range []string{"PORT", "HTTP_PORT", "ERROR_RATE", "TTFT_MIN_MS", "TTFT_MAX_MS", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_CLIENT_CA_FILE"} {
+		t.Setenv(k, "")
+	}
+	for _, preset := range presetNames() {
+		t.Setenv("PRESET", preset)
+		cfg := LoadConfig()
+		ApplyPresetOverrides(&cfg)
+		if errs := Validate(cfg); len(errs) != 0 {
+			t.Fatalf("preset %s: default config is invalid: %v", preset, errs)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package grpc
+
+import (
+	"context"
+	"math/rand/v2"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// rpcStats is filled in while an RPC runs and logged by the access log interceptor when it ends.
+type rpcStats struct {
+	requestID       string
+	clientRequestID string // set by the handler (see setClientRequestID)
+	injected        atomic.Bool
+}
+
+type rpcStatsKey struct{}
+
+// markInjected records that the current RPC failed with an injected error.
+func markInjected(ctx context.Context) {
+	if st, ok := ctx.Value(rpcStatsKey{}).(*rpcStats); ok {
+		st.injected.Store(true)
+	}
+}
+
+// setClientRequestID records the client request ID of the current RPC for its access log line.
+func setClientRequestID(ctx context.Context, id string) {
+	if st, ok := ctx.Value(rpcStatsKey{}).(*rpcStats); ok {
+		st.clientRequestID = id
+	}
+}
+
+// accessLogOptions returns interceptors that log exactly one line per RPC (ACCESS_LOG=true): method,
+// peer, model, request ID, status code, duration, token usage, chunks sent and whether the error was
+// injected. The health service is not logged.
+func accessLogOptions(cfg config.Config) []grpc.ServerOption {
+	if !cfg.AccessLog {
+		return nil
+	}
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(accessLogUnaryInterceptor),
+		WithStreamInterceptors(accessLogStreamInterceptor),
+	}
+}
+
+func accessLogUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	start := time.Now()
+	st := &rpcStats{requestID: grpcRequestID(ctx)}
+	resp, err := handler(context.WithValue(ctx, rpcStatsKey{}, st), req)
+
+	entry := accessEntry{method: info.FullMethod, start: start, stats: st, err: err}
+	if r, ok := req.(*llmv1.ChatCompletionRequest); ok {
+		entry.model = r.GetModel()
+	}
+	if r, ok := resp.(*llmv1.ChatCompletionResponse); ok && err == nil {
+		entry.promptTokens, entry.completionTokens = r.GetPromptTokens(), r.GetCompletionTokens()
+	}
+	entry.log(ctx)
+	return resp, err
+}
+
+func accessLogStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	start := time.Now()
+	st := &rpcStats{requestID: grpcRequestID(ss.Context())}
+	as := &accessLogStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), rpcStatsKey{}, st)}
+	err := handler(srv, as)
+
+	entry := accessEntry{
+		method:           info.FullMethod,
+		start:            start,
+		stats:            st,
+		err:              err,
+		model:            as.model,
+		promptTokens:     as.promptTokens,
+		completionTokens: as.completionTokens,
+		chunks:           as.chunks,
+	}
+	entry.log(ss.Context())
+	return err
+}
+
+// accessLogStream exposes the stats context to the handler and collects the model, the number of
+// content chunks and the usage reported on the done chunk.
+type accessLogStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	model                          string
+	chunks                         int
+	promptTokens, completionTokens int32
+}
+
+func (s *accessLogStream) Context() context.Context { return s.ctx }
+
+func (s *accessLogStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if r, ok := m.(*llmv1.ChatCompletionRequest); ok && err == nil {
+		s.model = r.GetModel()
+	}
+	return err
+}
+
+func (s *accessLogStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if ch, ok := m.(*llmv1.ChatCompletionChunkResponse); ok && err == nil {
+		switch {
+		case isDeltaChunk(ch):
+			s.chunks++
+		case isDoneChunk(ch):
+			s.promptTokens, s.completionTokens = ch.GetPromptTokens(), ch.GetCompletionTokens()
+		}
+	}
+	return err
+}
+
+type accessEntry struct {
+	method                         string
+	model                          string
+	start                          time.Time
+	stats                          *rpcStats
+	err                            error
+	promptTokens, completionTokens int32
+	chunks                         int
+}
+
+func (e accessEntry) log(ctx context.Context) {
+	peerAddr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+	fields := []any{
+		"method", path.Base(e.method),
+		"peer", peerAddr,
+		"model", e.model,
+		"requestId", e.stats.requestID,
+		"code", status.Code(e.err).String(),
+		"durationMs", time.Since(e.start).Milliseconds(),
+		"promptTokens", e.promptTokens,
+		"completionTokens", e.completionTokens,
+		"chunks", e.chunks,
+		"injected", e.stats.injected.Load(),
+	}
+	if id := e.stats.clientRequestID; id != "" {
+		fields = append(fields, "clientRequestId", id)
+	}
+	if e.err != nil {
+		fields = append(fields, "err", e.err)
+	}
+	logger.Log.Infow("[grpc][access]", fields...)
+}
+
+// rpcRequestID returns the request ID of the current RPC: the access log's when it is on, so the
+// response headers and the log line agree, else grpcRequestID.
+func rpcRequestID(ctx context.Context) string {
+	if st, ok := ctx.Value(rpcStatsKey{}).(*rpcStats); ok {
+		return st.requestID
+	}
+	return grpcRequestID(ctx)
+}
+
+// grpcRequestID returns the caller's x-request-id metadata, or a generated ID. Generated IDs do not
+// draw from the shared random source (mock.Seed), so they leave seeded runs unchanged.
+func grpcRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(requestIDKey); len(v) > 0 && v[0] != "" {
+		return v[0]
+	}
+	return newRequestID()
+}
+
+// newRequestID generates a request ID without drawing from the shared random source.
+func newRequestID() string {
+	return "req_" + strconv.FormatUint(rand.Uint64(), 36)
+}
@@ -0,0 +1,284 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// TestMain points logger.Log at testCore once, before any server runs: tests then route it with
+// setTestCore instead of swapping logger.Log under servers that may still be reading it.
+func TestMain(m *testing.M) {
+	logger.Log = zap.New(testCore{}).Sugar()
+	os.Exit(m.Run())
+}
+
+// testLogCore is the core testCore writes to, a nop one unless a test set its own.
+var testLogCore = struct {
+	sync.RWMutex
+	core zapcore.Core
+}{core: zapcore.NewNopCore()}
+
+// setTestCore makes logger.Log write to core for the duration of tb.
+func setTestCore(tb testing.TB, core zapcore.Core) {
+	testLogCore.Lock()
+	prev := testLogCore.core
+	testLogCore.core = core
+	testLogCore.Unlock()
+	tb.Cleanup(func() {
+		testLogCore.Lock()
+		testLogCore.core = prev
+		testLogCore.Unlock()
+	})
+}
+
+// testCore is the core of logger.Log in tests: it passes every entry, with its fields, on to
+// whatever core setTestCore set last.
+type testCore struct{ fields []zapcore.Field }
+
+func (c testCore) current() zapcore.Core {
+	testLogCore.RLock()
+	core := testLogCore.core
+	testLogCore.RUnlock()
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
+	}
+	return core
+}
+
+func (c testCore) Enabled(l zapcore.Level) bool { return c.current().Enabled(l) }
+
+func (c testCore) With(fields []zapcore.Field) zapcore.Core {
+	return testCore{fields: append(slices.Clip(c.fields), fields...)}
+}
+
+func (c testCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(e, ce)
+}
+
+func (c testCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(e, fields)
+}
+
+func (c testCore) Sync() error { return c.current().Sync() }
+
+// observeLogs routes logger.Log to an in-memory core for the duration of the test.
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	return observeLogsAt(t, zapcore.InfoLevel)
+}
+
+// observeLogsAt is observeLogs keeping the entries level enables.
+func observeLogsAt(t *testing.T, level zapcore.LevelEnabler) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(level)
+	setTestCore(t, core)
+	return logs
+}
+
+// accessEntries returns the access log lines for requestID.
+func accessEntries(logs *observer.ObservedLogs, requestID string) []observer.LoggedEntry {
+	var out []observer.LoggedEntry
+	for _, e := range logs.FilterMessage("[grpc][access]").All() {
+		if e.ContextMap()["requestId"] == requestID {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestAccessLogOneLinePerRPC(t *testing.T) {
+	logs := observeLogs(t)
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), AccessLog: true}
+	client := startTestServer(t, cfg)
+	req := &llmv1.ChatCompletionRequest{Model: "m-access", UserPrompt: "log me", MaxTokens: 32}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-unary")
+	resp, err := client.ChatCompletion(ctx, req)
+	if err != nil {
+		t.Fatalf("unary failed: %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-stream")
+	stream, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	deltas := 0
+	for {
+		ch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		if ch.GetType() == string(events.OutputTextDelta) {
+			deltas++
+		}
+	}
+
+	unary := accessEntries(logs, "req-unary")
+	if len(unary) != 1 {
+		t.Fatalf("expected 1 unary access line, got %d", len(unary))
+	}
+	f := unary[0].ContextMap()
+	if f["method"] != "ChatCompletion" || f["model"] != "m-access" || f["code"] != "OK" || f["injected"] != false {
+		t.Fatalf("unexpected unary fields: %v", f)
+	}
+	if f["completionTokens"] != resp.GetCompletionTokens() || f["promptTokens"] != resp.GetPromptTokens() {
+		t.Fatalf("unary tokens %v/%v, want %d/%d", f["promptTokens"], f["completionTokens"], resp.GetPromptTokens(), resp.GetCompletionTokens())
+	}
+
+	streamed := accessEntries(logs, "req-stream")
+	if len(streamed) != 1 {
+		t.Fatalf("expected 1 stream access line, got %d", len(streamed))
+	}
+	f = streamed[0].ContextMap()
+	if f["method"] != "ChatCompletionStream" || f["model"] != "m-access" || f["code"] != "OK" {
+		t.Fatalf("unexpected stream fields: %v", f)
+	}
+	if f["chunks"] != int64(deltas) || f["completionTokens"].(int32) <= 0 {
+		t.Fatalf("stream chunks=%v completionTokens=%v, want %d chunks", f["chunks"], f["completionTokens"], deltas)
+	}
+
+	// Per-chunk handler logs are debug-only.
+	if n := logs.FilterMessage("[grpc][ChatCompletionStream] sending first chunk").Len(); n != 0 {
+		t.Fatalf("expected no per-chunk info logs, got %d", n)
+	}
+}
+
+func TestAccessLogMarksInjectedErrors(t *testing.T) {
+	logs := observeLogs(t)
+	cfg := config.Config{ErrorRate: 1, ErrorMode: "internal", MaxOutputChars: config.Int(64), AccessLog: true}
+	client := startTestServer(t, cfg)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-injected")
+	_, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{Model: "m-access", UserPrompt: "fail"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+
+	entries := accessEntries(logs, "req-injected")
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 access line, got %d", len(entries))
+	}
+	if f := entries[0].ContextMap(); f["code"] != "Internal" || f["injected"] != true {
+		t.Fatalf("unexpected fields: %v", f)
+	}
+}
+
+func TestAccessLogDisabled(t *testing.T) {
+	logs := observeLogs(t)
+	client := startTestServer(t, config.Config{MaxOutputChars: config.Int(64)})
+
+	if _, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "quiet"}); err != nil {
+		t.Fatalf("unary failed: %v", err)
+	}
+	if n := logs.FilterMessage("[grpc][access]").Len(); n != 0 {
+		t.Fatalf("expected no access lines with ACCESS_LOG=false, got %d", n)
+	}
+}
+
+func TestLogSampling(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	cfg := config.Config{ChunkSize: config.Int(16), MaxOutputChars: config.Int(64), AccessLog: true, LogSampleRate: 4}
+	svc := NewMockLlmService(cfg)
+	client := startTestServer(t, cfg)
+
+	// Only 1 in 4 streams logs its phases; the access log has every one.
+	for i := range 8 {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-sampled")
+		stream, err := client.ChatCompletionStream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hello"})
+		if err != nil {
+			t.Fatalf("stream %d failed: %v", i, err)
+		}
+		for {
+			if _, err := stream.Recv(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("stream %d failed: %v", i, err)
+			}
+		}
+	}
+	for _, msg := range []string{"start", "pre_delay", "generated output", "sending first chunk", "sending done chunk", "done"} {
+		if n := logs.FilterMessage("[grpc][ChatCompletionStream] " + msg).Len(); n != 2 {
+			t.Fatalf("expected %q from 2 of 8 streams, got %d", msg, n)
+		}
+	}
+	if n := len(accessEntries(logs, "req-sampled")); n != 8 {
+		t.Fatalf("expected 8 access lines, got %d", n)
+	}
+
+	// Failures log whether their stream was sampled or not.
+	svc.Config().Update(func(c *config.Config) { c.ErrorRate = 1 })
+	for range 4 {
+		_ = svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hello"}, llmtest.NewServerStream(context.Background()))
+	}
+	if n := logs.FilterMessage("[grpc][ChatCompletionStream] error").Len(); n != 4 {
+		t.Fatalf("expected every failed stream to log, got %d", n)
+	}
+}
+
+// TestLogSamplingKeepsErrors checks injected errors log at the default info level, unsampled, while
+// LOG_SAMPLE_RATE drops the per-phase lines of most requests.
+func TestLogSamplingKeepsErrors(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	svc := NewMockLlmService(config.Config{MaxOutputChars: config.Int(64), LogSampleRate: 4, ErrorRate: 1})
+	for range 8 {
+		_, _ = svc.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello"})
+	}
+	for range 8 {
+		_ = svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hello"}, llmtest.NewServerStream(context.Background()))
+	}
+	for _, method := range []string{"ChatCompletion", "ChatCompletionStream"} {
+		if n := logs.FilterMessage("[grpc][" + method + "] start").Len(); n != 2 {
+			t.Fatalf("expected %s start from 2 of 8 requests, got %d", method, n)
+		}
+		injected := logs.FilterMessage("[grpc][" + method + "] injected error").All()
+		if len(injected) != 8 {
+			t.Fatalf("expected every injected %s error to log, got %d", method, len(injected))
+		}
+		for _, e := range injected {
+			if e.Level < zapcore.InfoLevel {
+				t.Fatalf("injected %s error logged at %v, below info", method, e.Level)
+			}
+		}
+	}
+}
+
+// BenchmarkStreamLogging measures a stream's handler with debug logging on, logging every stream
+// and 1 in 100.
+func BenchmarkStreamLogging(b *testing.B) {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	setTestCore(b, zapcore.NewCore(enc, zapcore.AddSync(io.Discard), zapcore.DebugLevel))
+
+	for _, rate := range []int{1, 100} {
+		b.Run(fmt.Sprintf("sample=%d", rate), func(b *testing.B) {
+			svc := NewMockLlmService(config.Config{ChunkSize: config.Int(64), MaxOutputChars: config.Int(256), LogSampleRate: rate})
+			req := &llmv1.ChatCompletionRequest{UserPrompt: "hello"}
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = svc.ChatCompletionStream(req, llmtest.NewServerStream(context.Background()))
+			}
+		})
+	}
+}
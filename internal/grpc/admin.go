@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+// NewAdminMux registers the operator endpoints served on ADMIN_ADDR, which is independent of the
+// HTTP_ENABLED listener and defaults to localhost only. With PPROF_ENABLED the net/http/pprof
+// handlers are mounted under /debug/pprof/, e.g. for
+// `go tool pprof http://localhost:6060/debug/pprof/profile?seconds=10` during a soak. With
+// LOG_LEVEL_ENDPOINT, /admin/loglevel reads (GET) and sets (PUT {"level":"debug"}) the log level
+// without a restart.
+func NewAdminMux(cfg config.Config) http.Handler {
+	mux := http.NewServeMux()
+	if cfg.LogLevelEndpoint {
+		mux.Handle("GET /admin/loglevel", logger.Level)
+		mux.HandleFunc("PUT /admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+			before := logger.Level.Level()
+			logger.Level.ServeHTTP(w, r)
+			if after := logger.Level.Level(); after != before {
+				logger.Log.Warnw("[admin] log level changed", "from", before.String(), "to", after.String())
+			}
+		})
+	}
+	if cfg.PprofEnabled {
+		mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+		mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+func TestAdminMuxPprof(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{"enabled", true, http.StatusOK},
+		{"disabled", false, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(NewAdminMux(config.Config{PprofEnabled: tc.enabled}))
+			defer ts.Close()
+
+			for _, p := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+				resp, err := http.Get(ts.URL + p)
+				if err != nil {
+					t.Fatalf("GET %s failed: %v", p, err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tc.want {
+					t.Fatalf("GET %s: expected %d, got %d", p, tc.want, resp.StatusCode)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminMuxLogLevel(t *testing.T) {
+	prev := logger.Level.Level()
+	t.Cleanup(func() { logger.Level.SetLevel(prev) })
+	logger.Level.SetLevel(zapcore.InfoLevel)
+	logs := observeLogsAt(t, logger.Level)
+
+	ts := httptest.NewServer(NewAdminMux(config.Config{LogLevelEndpoint: true}))
+	defer ts.Close()
+	setLevel := func(body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/admin/loglevel", strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT /admin/loglevel failed: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	debugLines := func() int {
+		logs.TakeAll()
+		logger.Log.Debugw("[test] debug line")
+		return logs.FilterMessage("[test] debug line").Len()
+	}
+
+	if debugLines() != 0 {
+		t.Fatal("debug lines logged at info")
+	}
+	if code, body := setLevel(`{"level":"debug"}`); code != http.StatusOK || !strings.Contains(body, "debug") {
+		t.Fatalf("PUT debug: %d %s", code, body)
+	}
+	if debugLines() != 1 {
+		t.Fatal("debug lines not logged after switching to debug")
+	}
+	if code, _ := setLevel(`{"level":"info"}`); code != http.StatusOK {
+		t.Fatalf("PUT info: %d", code)
+	}
+	if debugLines() != 0 {
+		t.Fatal("debug lines still logged after switching back to info")
+	}
+	if code, _ := setLevel(`{"level":"verbose"}`); code != http.StatusBadRequest || logger.Level.Level() != zapcore.InfoLevel {
+		t.Fatalf("PUT of an unknown level: %d, level %v", code, logger.Level.Level())
+	}
+
+	resp, err := http.Get(ts.URL + "/admin/loglevel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), `"info"`) {
+		t.Fatalf("GET /admin/loglevel: %s", b)
+	}
+
+	// Off by default, like pprof.
+	off := httptest.NewServer(NewAdminMux(config.Config{}))
+	defer off.Close()
+	resp, err = http.Get(off.URL + "/admin/loglevel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 without LOG_LEVEL_ENDPOINT, got %d", resp.StatusCode)
+	}
+}
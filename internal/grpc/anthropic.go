@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
+)
+
+// AnthropicMessagesHandler serves POST /v1/messages in the Anthropic Messages API shape.
+//
+// The request must carry an `anthropic-version` header. With "stream": true the response is an SSE
+// stream of message_start, content_block_start, content_block_delta (text_delta), content_block_stop,
+// message_delta and message_stop events; otherwise a single message object is returned.
+// Generation, pacing and error injection follow the same config knobs as the OpenAI-style SSE path.
+func AnthropicMessagesHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("anthropic-version") == "" {
+			writeAnthropicError(w, http.StatusBadRequest, "anthropic-version header is required")
+			return
+		}
+
+		var req mock.AnthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if len(req.Messages) == 0 {
+			writeAnthropicError(w, http.StatusBadRequest, "messages: at least one message is required")
+			return
+		}
+		if req.Model == "" {
+			req.Model = "mock-anthropic"
+		}
+		maxTokens := req.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultInt(cfg.DefaultTokens, 128)
+		}
+
+		setRequestModel(r.Context(), req.Model)
+		chatReq := anthropicToChatRequest(req)
+		if err := checkMessages(cfg, chatReq); err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, status.Convert(err).Message())
+			return
+		}
+
+		// Error injection (before any headers are written).
+		if code := injectHTTPError(cfg); code != 0 {
+			logger.Log.Infow("[http][AnthropicMessages] injected error", "mode", cfg.ErrorMode, "status", code)
+			e := anthropicError(code, "mock error")
+			e.Error.Injected = true
+			writeJSON(w, code, e)
+			return
+		}
+
+		prompt := buildPromptForTokens(chatReq)
+		content := buildOutput(cfg, prompt, maxTokens)
+		msg := mock.AnthropicMessageResponse{
+			ID:      "msg_mock_" + mock.RandID(),
+			Type:    "message",
+			Role:    "assistant",
+			Content: []mock.AnthropicContentBlock{},
+			Model:   req.Model,
+			Usage: mock.AnthropicUsage{
+				InputTokens:  promptTokens(cfg, chatReq),
+				OutputTokens: mock.ApproxTokens(content),
+			},
+		}
+
+		if !req.Stream {
+			requestService(r.Context(), cfg).simulateUnary(r.Context(), msg.Usage.OutputTokens)
+			if r.Context().Err() != nil {
+				return
+			}
+			stopReason := "end_turn"
+			msg.StopReason = &stopReason
+			msg.Content = append(msg.Content, mock.AnthropicContentBlock{Type: "text", Text: content})
+			writeJSON(w, http.StatusOK, msg)
+			reportUsage(r.Context(), msg.Usage.InputTokens, msg.Usage.OutputTokens)
+			return
+		}
+
+		serveAnthropicSSE(w, r, msg, content, cfg)
+	}
+}
+
+func serveAnthropicSSE(w http.ResponseWriter, r *http.Request, msg mock.AnthropicMessageResponse, content string, cfg config.Config) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAnthropicError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	chunkSize := sseChunkSize(cfg, 0)
+	outputTokens := msg.Usage.OutputTokens
+	bw := bufio.NewWriter(w)
+
+	send := func(event string, v any) bool {
+		if err := writeSSEEvent(bw, event, v); err != nil {
+			return false
+		}
+		if err := bw.Flush(); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// message_start reports the input usage up front; output_tokens is finalized in message_delta.
+	msg.Usage.OutputTokens = 0
+	if !send("message_start", mock.AnthropicMessageStart{Type: "message_start", Message: msg}) {
+		return
+	}
+	blockStart := mock.AnthropicContentBlockStart{
+		Type:         "content_block_start",
+		Index:        0,
+		ContentBlock: mock.AnthropicContentBlock{Type: "text", Text: ""},
+	}
+	if !send("content_block_start", blockStart) {
+		return
+	}
+
+	pace := newStreamPacer(cfg)
+	for i := 0; i < len(content); i += chunkSize {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		part := content[i:end]
+
+		delta := mock.AnthropicContentBlockDelta{Type: "content_block_delta", Index: 0}
+		delta.Delta.Type = "text_delta"
+		delta.Delta.Text = part
+		if !send("content_block_delta", delta) {
+			return
+		}
+
+		pace.wait(r.Context(), part)
+	}
+
+	if !send("content_block_stop", mock.AnthropicContentBlockStop{Type: "content_block_stop", Index: 0}) {
+		return
+	}
+	msgDelta := mock.AnthropicMessageDelta{Type: "message_delta"}
+	msgDelta.Delta.StopReason = "end_turn"
+	msgDelta.Usage.OutputTokens = outputTokens
+	if !send("message_delta", msgDelta) {
+		return
+	}
+	send("message_stop", mock.AnthropicMessageStop{Type: "message_stop"})
+	reportUsage(r.Context(), msg.Usage.InputTokens, outputTokens)
+}
+
+// anthropicToChatRequest maps a Messages API request onto the gRPC request shape so prompt
+// construction (and therefore token accounting) matches buildPromptForTokens exactly.
+// The last user message becomes the user prompt; everything before it is context.
+func anthropicToChatRequest(req mock.AnthropicRequest) *llmv1.ChatCompletionRequest {
+	out := &llmv1.ChatCompletionRequest{
+		Model:        req.Model,
+		SystemPrompt: mock.AnthropicText(req.System),
+		MaxTokens:    int32(req.MaxTokens),
+	}
+	msgs := req.Messages
+	if n := len(msgs); n > 0 && msgs[n-1].Role == "user" {
+		out.UserPrompt = mock.AnthropicText(msgs[n-1].Content)
+		msgs = msgs[:n-1]
+	}
+	for _, m := range msgs {
+		out.Context = append(out.Context, &llmv1.ChatMessage{Role: m.Role, Content: mock.AnthropicText(m.Content)})
+	}
+	return out
+}
+
+// writeAnthropicError writes an Anthropic-style error envelope with an error type derived from the status.
+func writeAnthropicError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, anthropicError(code, message))
+}
+
+func anthropicError(code int, message string) mock.AnthropicError {
+	var e mock.AnthropicError
+	e.Type = "error"
+	e.Error.Type = anthropicErrorType(code)
+	e.Error.Message = message
+	return e
+}
+
+func anthropicErrorType(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
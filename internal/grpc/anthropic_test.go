@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+func TestAnthropicMessagesStreamEventSequence(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(9),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+	}
+
+	body := `{"model":"claude-mock","max_tokens":12,"stream":true,"system":"be brief",` +
+		`"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},` +
+		`{"role":"user","content":[{"type":"text","text":"tell me a joke"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+
+	AnthropicMessagesHandler(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("content type not set for SSE: %q", rr.Header().Get("Content-Type"))
+	}
+
+	events := parseNamedSSE(t, rr.Body.String())
+
+	var names []string
+	var assembled strings.Builder
+	for _, e := range events {
+		names = append(names, e.name)
+
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(e.data), &typed); err != nil {
+			t.Fatalf("bad event payload %q: %v", e.data, err)
+		}
+		if typed.Type != e.name {
+			t.Fatalf("event line %q does not match payload type %q", e.name, typed.Type)
+		}
+
+		if e.name == "content_block_delta" {
+			var d mock.AnthropicContentBlockDelta
+			if err := json.Unmarshal([]byte(e.data), &d); err != nil {
+				t.Fatalf("bad delta: %v", err)
+			}
+			if d.Delta.Type != "text_delta" {
+				t.Fatalf("unexpected delta type: %q", d.Delta.Type)
+			}
+			if len(d.Delta.Text) > *cfg.ChunkSize {
+				t.Fatalf("delta exceeds chunk size: %d", len(d.Delta.Text))
+			}
+			assembled.WriteString(d.Delta.Text)
+		}
+	}
+
+	if len(names) < 6 {
+		t.Fatalf("too few events: %v", names)
+	}
+	wantHead := []string{"message_start", "content_block_start"}
+	wantTail := []string{"content_block_stop", "message_delta", "message_stop"}
+	for i, n := range wantHead {
+		if names[i] != n {
+			t.Fatalf("event %d: expected %q, got %q (%v)", i, n, names[i], names)
+		}
+	}
+	for i, n := range wantTail {
+		if got := names[len(names)-len(wantTail)+i]; got != n {
+			t.Fatalf("tail event %d: expected %q, got %q (%v)", i, n, got, names)
+		}
+	}
+	for _, n := range names[len(wantHead) : len(names)-len(wantTail)] {
+		if n != "content_block_delta" {
+			t.Fatalf("unexpected event between block start/stop: %q", n)
+		}
+	}
+
+	prompt := buildPromptForTokens(anthropicToChatRequest(mock.AnthropicRequest{
+		Model:     "claude-mock",
+		MaxTokens: 12,
+		System:    json.RawMessage(`"be brief"`),
+		Messages: []mock.AnthropicMessage{
+			{Role: "user", Content: json.RawMessage(`"hi"`)},
+			{Role: "assistant", Content: json.RawMessage(`"hello"`)},
+			{Role: "user", Content: json.RawMessage(`"tell me a joke"`)},
+		},
+	}))
+	expected := buildOutput(cfg, prompt, 12)
+	if got := assembled.String(); got != expected {
+		t.Fatalf("reassembled content mismatch: got len=%d expected len=%d", len(got), len(expected))
+	}
+
+	var md mock.AnthropicMessageDelta
+	if err := json.Unmarshal([]byte(events[len(events)-2].data), &md); err != nil {
+		t.Fatalf("bad message_delta: %v", err)
+	}
+	if md.Delta.StopReason != "end_turn" || md.Usage.OutputTokens != mock.ApproxTokens(expected) {
+		t.Fatalf("unexpected message_delta: %+v", md)
+	}
+}
+
+func TestAnthropicMessagesNonStream(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	body := `{"model":"claude-mock","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+
+	AnthropicMessagesHandler(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	var msg mock.AnthropicMessageResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if msg.Type != "message" || msg.Role != "assistant" || len(msg.Content) != 1 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if msg.StopReason == nil || *msg.StopReason != "end_turn" {
+		t.Fatalf("unexpected stop reason: %v", msg.StopReason)
+	}
+	if msg.Usage.OutputTokens != mock.ApproxTokens(msg.Content[0].Text) {
+		t.Fatalf("usage mismatch: %+v", msg.Usage)
+	}
+}
+
+func TestAnthropicMessagesErrors(t *testing.T) {
+	t.Run("missing version header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+		rr := httptest.NewRecorder()
+		AnthropicMessagesHandler(config.Config{}).ServeHTTP(rr, req)
+		assertAnthropicError(t, rr, http.StatusBadRequest, "invalid_request_error")
+	})
+
+	t.Run("injected 429", func(t *testing.T) {
+		cfg := config.Config{ErrorRate: 1, ErrorMode: "429"}
+		body := `{"model":"claude-mock","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		AnthropicMessagesHandler(cfg).ServeHTTP(rr, req)
+		assertAnthropicError(t, rr, http.StatusTooManyRequests, "rate_limit_error")
+	})
+}
+
+func assertAnthropicError(t *testing.T, rr *httptest.ResponseRecorder, code int, errType string) {
+	t.Helper()
+	if rr.Code != code {
+		t.Fatalf("expected status %d, got %d", code, rr.Code)
+	}
+	var e mock.AnthropicError
+	if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+		t.Fatalf("bad error body: %v", err)
+	}
+	if e.Type != "error" || e.Error.Type != errType {
+		t.Fatalf("unexpected error body: %+v", e)
+	}
+}
+
+type namedSSEEvent struct {
+	name string
+	data string
+}
+
+// parseNamedSSE splits an SSE body into (event, data) pairs, skipping comment lines.
+func parseNamedSSE(t *testing.T, body string) []namedSSEEvent {
+	t.Helper()
+
+	var events []namedSSEEvent
+	for _, raw := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var e namedSSEEvent
+		for _, line := range strings.Split(raw, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				e.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		if e.data == "" {
+			continue
+		}
+		if e.name == "" {
+			t.Fatalf("event without name: %q", raw)
+		}
+		events = append(events, e)
+	}
+	return events
+}
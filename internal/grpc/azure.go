@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/status"
+)
+
+// azureAPIVersions lists the api-version values accepted on Azure routes.
+var azureAPIVersions = []string{
+	"2023-05-15",
+	"2024-02-01",
+	"2024-06-01",
+	"2024-10-21",
+	"2024-02-15-preview",
+	"2024-05-01-preview",
+	"2024-08-01-preview",
+	"2024-10-01-preview",
+	"2025-01-01-preview",
+}
+
+// AzureChatCompletionsHandler serves POST /openai/deployments/{deployment}/chat/completions the way
+// Azure OpenAI does: the deployment name is used as the model, the api-version query parameter must be
+// one of azureAPIVersions, and when API keys are configured the `api-key` header must carry one of them.
+// Every response carries an x-ms-request-id header. It is mounted by NewHTTPMux when AZURE_COMPAT is set.
+func AzureChatCompletionsHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-request-id", mock.RandUUID())
+
+		switch v := r.URL.Query().Get("api-version"); {
+		case v == "":
+			writeAzureError(w, http.StatusNotFound, "404", "Resource not found")
+			return
+		case !slices.Contains(azureAPIVersions, v):
+			logger.Log.Infow("[http][Azure] unsupported api-version", "apiVersion", v)
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", "API version not supported")
+			return
+		}
+		if len(cfg.APIKeys) > 0 && !slices.Contains(cfg.APIKeys, r.Header.Get("api-key")) {
+			writeAzureError(w, http.StatusUnauthorized, "401", "Access denied due to invalid subscription key or wrong API endpoint. "+
+				"Make sure to provide a valid key for an active subscription and use a correct regional API endpoint for your resource.")
+			return
+		}
+
+		var req mock.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", "invalid JSON body: "+err.Error())
+			return
+		}
+		model := r.PathValue("deployment")
+		setRequestModel(r.Context(), model)
+		maxTokens := req.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultInt(cfg.DefaultTokens, 128)
+		}
+
+		preq := chatRequestToProto(req)
+		if err := checkSampling(cfg, preq); err != nil {
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", status.Convert(err).Message())
+			return
+		}
+		if err := checkMessages(cfg, preq); err != nil {
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", status.Convert(err).Message())
+			return
+		}
+		prompt := newChatPrompt(cfg, preq)
+		if req.Stream {
+			// The SSE path applies its own (pre or mid-stream) error injection.
+			serveChatCompletionSSE(w, r, model, prompt, maxTokens, cfg, 0, samplingEcho(req.Sampling))
+			return
+		}
+
+		// Error injection (before any headers are written).
+		if code := injectHTTPError(cfg); code != 0 {
+			requestLog(r.Context(), logger.Log).Infow("[http][Azure] injected error", "mode", cfg.ErrorMode, "status", code)
+			e := azureError(strconv.Itoa(code), "mock error")
+			e.Error.Injected = true
+			writeJSON(w, code, e)
+			return
+		}
+
+		content := buildOutput(cfg, prompt.text, maxTokens)
+		pt, ct := prompt.tokens, mock.ApproxTokens(content)
+		requestService(r.Context(), cfg).simulateUnary(r.Context(), ct)
+		if r.Context().Err() != nil {
+			return
+		}
+		resp := buildChatResponse("chatcmpl_mock_"+mock.RandID(), model, content, pt, ct, req.Sampling)
+		resp.ClientRequestID = requestClientID(r.Context())
+		writeJSON(w, http.StatusOK, resp)
+		reportUsage(r.Context(), pt, ct)
+	}
+}
+
+// writeAzureError writes Azure's error envelope: {"error":{"code":...,"message":...}}.
+func writeAzureError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, azureError(code, message))
+}
+
+type azureErrorBody struct {
+	Error struct {
+		Code     string `json:"code"`
+		Message  string `json:"message"`
+		Injected bool   `json:"injected,omitempty"` // simulator extension, see mock.ErrorResponse
+	} `json:"error"`
+}
+
+func azureError(code, message string) azureErrorBody {
+	var e azureErrorBody
+	e.Error.Code = code
+	e.Error.Message = message
+	return e
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+const azureBody = `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"max_tokens":8}`
+
+func serveAzure(cfg config.Config, path, apiKey, body string) *httptest.ResponseRecorder {
+	cfg.AzureCompat = true
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("api-key", apiKey)
+	}
+	rr := httptest.NewRecorder()
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAzureChatCompletions(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128), APIKeys: []string{"k1"}}
+
+	rr := serveAzure(cfg, "/openai/deployments/gpt4o-prod/chat/completions?api-version=2024-06-01", "k1", azureBody)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("x-ms-request-id") == "" {
+		t.Fatalf("missing x-ms-request-id header")
+	}
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if resp.Model != "gpt4o-prod" || resp.Object != "chat.completion" || len(resp.Choices) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.CompletionTokens != mock.ApproxTokens(resp.Choices[0].Message.Content) {
+		t.Fatalf("usage mismatch: %+v", resp.Usage)
+	}
+}
+
+func TestAzureChatCompletionsStream(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	body := strings.Replace(azureBody, `"max_tokens":8`, `"max_tokens":8,"stream":true`, 1)
+	rr := serveAzure(cfg, "/openai/deployments/gpt4o-prod/chat/completions?api-version=2024-10-21", "", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	if chunks[0].Model != "gpt4o-prod" {
+		t.Fatalf("deployment should be used as model, got %q", chunks[0].Model)
+	}
+}
+
+func TestAzureChatCompletionsRejects(t *testing.T) {
+	cfg := config.Config{APIKeys: []string{"k1"}}
+	tests := []struct {
+		name string
+		path string
+		key  string
+		code int
+	}{
+		{name: "missing api-version", path: "/openai/deployments/d/chat/completions", key: "k1", code: http.StatusNotFound},
+		{name: "unknown api-version", path: "/openai/deployments/d/chat/completions?api-version=1999-01-01", key: "k1", code: http.StatusBadRequest},
+		{name: "missing api-key", path: "/openai/deployments/d/chat/completions?api-version=2024-06-01", code: http.StatusUnauthorized},
+		{name: "wrong api-key", path: "/openai/deployments/d/chat/completions?api-version=2024-06-01", key: "nope", code: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serveAzure(cfg, tc.path, tc.key, azureBody)
+			if rr.Code != tc.code {
+				t.Fatalf("expected %d, got %d", tc.code, rr.Code)
+			}
+			if rr.Header().Get("x-ms-request-id") == "" {
+				t.Fatalf("missing x-ms-request-id header on error")
+			}
+			var e struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil || e.Error.Code == "" || e.Error.Message == "" {
+				t.Fatalf("unexpected error body: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAzureRoutesRequireCompatMode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/openai/deployments/d/chat/completions?api-version=2024-06-01", strings.NewReader(azureBody))
+	rr := httptest.NewRecorder()
+	NewHTTPMux(config.Config{}, nil, nil, nil).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without AZURE_COMPAT, got %d", rr.Code)
+	}
+}
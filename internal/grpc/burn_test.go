@@ -0,0 +1,60 @@
+//go:build unix
+
+package grpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// cpuTime returns the user CPU time the process has used so far.
+func cpuTime(t *testing.T) time.Duration {
+	t.Helper()
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		t.Fatalf("getrusage: %v", err)
+	}
+	return time.Duration(ru.Utime.Nano())
+}
+
+// TestCPUBurnPerToken verifies CPU_BURN_US_PER_TOKEN spends CPU time on every path: 64 tokens at
+// 500µs each are 32ms of CPU that a run without the burn doesn't use.
+func TestCPUBurnPerToken(t *testing.T) {
+	paths := map[string]func(cfg config.Config) error{
+		"unary": func(cfg config.Config) error {
+			_, err := NewMockLlmService(cfg).ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "burn", MaxTokens: 64})
+			return err
+		},
+		"stream": func(cfg config.Config) error {
+			return NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "burn", MaxTokens: 64}, llmtest.NewServerStream(context.Background()))
+		},
+		"sse": func(cfg config.Config) error {
+			serveChatCompletionSSE(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "burn"), 64, cfg, 16, nil)
+			return nil
+		},
+	}
+	for name, run := range paths {
+		t.Run(name, func(t *testing.T) {
+			used := func(burnUs int) time.Duration {
+				cfg := config.Config{ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true), CPUBurnUsPerToken: burnUs}
+				before := cpuTime(t)
+				if err := run(cfg); err != nil {
+					t.Fatalf("%s err: %v", name, err)
+				}
+				return cpuTime(t) - before
+			}
+			idle, burn := used(0), used(500)
+			if burn-idle < 20*time.Millisecond {
+				t.Fatalf("expected ~32ms more CPU with the burn, got %v (without: %v)", burn, idle)
+			}
+		})
+	}
+}
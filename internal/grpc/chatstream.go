@@ -0,0 +1,287 @@
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/status"
+)
+
+// chatEventKind is the kind of a chatStreamEvent.
+type chatEventKind int
+
+const (
+	chatEventRole   chatEventKind = iota // the assistant role chunk opening the stream
+	chatEventDelta                       // a content delta
+	chatEventDone                        // the final chunk, with the finish reason
+	chatEventFailed                      // a mid-stream error; nothing follows
+)
+
+// chatStreamEvent is one event of a streamed chat completion, before framing.
+type chatStreamEvent struct {
+	kind  chatEventKind
+	chunk mock.StreamChunk   // role, delta and done events
+	err   mock.ErrorResponse // failed events
+}
+
+// chatStream produces the events of a streamed HTTP chat completion with the pacing, mid-stream
+// error injection and KV cache accounting of the gRPC stream. It knows nothing of the wire format:
+// the caller frames and writes each event (see chatStreamFormat), and its yield reports whether
+// the write went through.
+type chatStream struct {
+	cfg       config.Config
+	pattern   string // the route, labeling the TTFT and chunk gap metrics
+	id, model string
+	created   int64
+	start     time.Time
+	gen       *mock.OutputStream
+	chunkSize int
+	kv        *kvCache
+	errCode   int // status of an injected mid-stream error, 0 for none
+	pt, ct    int // ct drops to what was sent when MAX_REQUEST_DURATION_MS cuts the stream
+	sampling  *mock.Sampling
+	usage     bool        // put the usage on the done chunk
+	debug     *mock.Debug // put on the done chunk when the request asked for it
+	clientID  string      // the client request ID, put on every chunk
+}
+
+// chunk returns an empty chat.completion.chunk of the stream with a single choice.
+func (cs *chatStream) chunk() mock.StreamChunk {
+	ch := mock.StreamChunk{ID: cs.id, Object: "chat.completion.chunk", Created: cs.created, Model: cs.model, ClientRequestID: cs.clientID}
+	ch.Choices = make([]struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content,omitempty"`
+			Role    string `json:"role,omitempty"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	}, 1)
+	ch.EmittedAtUnixMs = emittedAt(cs.cfg)
+	return ch
+}
+
+// events runs the stream, yielding its events until it ends, ctx is done or a write fails.
+func (cs *chatStream) events(ctx context.Context) iter.Seq[chatStreamEvent] {
+	return func(yield func(chatStreamEvent) bool) {
+		// Delay before the first token: queue (base+jitter) then prefill (TTFT), each measured, as on
+		// the gRPC stream. By default the role chunk counts as the first token and waits for it; with
+		// SSE_ROLE_FIRST the role chunk goes out immediately and the delay sits before the first
+		// content delta.
+		svc := requestService(ctx, cs.cfg)
+		svc.debug = cs.debug
+		var queue, prefill time.Duration
+		preDelay := func() bool {
+			queue = sleepMeasured(ctx, time.Duration(svc.queueMs())*time.Millisecond)
+			if ctx.Err() == nil {
+				prefill = sleepMeasured(ctx, time.Duration(svc.ttftMs())*time.Millisecond)
+			}
+			if ctx.Err() != nil && !capped(ctx) {
+				return false
+			}
+			observeTTFT(cs.pattern, queue+prefill)
+			return true
+		}
+		if !cs.cfg.SSERoleFirst && !preDelay() {
+			return
+		}
+
+		role := cs.chunk()
+		role.Choices[0].Delta.Role = "assistant"
+		if !yield(chatStreamEvent{kind: chatEventRole, chunk: role}) {
+			return
+		}
+		if cs.cfg.SSERoleFirst && !preDelay() {
+			return
+		}
+
+		// Mid-stream failure point: after at least one delta. The stream ends with an error event
+		// and no finish chunk.
+		failAfter := -1
+		if cs.errCode != 0 {
+			failAfter = 1 + mock.RandIntn((cs.gen.Len()+cs.chunkSize-1)/cs.chunkSize-1)
+		}
+		var sentBytes int // of the output, delivered
+		fail := func(sent int) {
+			requestLog(ctx, logger.Log).Infow("[http][ChatCompletionSSE] injected mid-stream error", "mode", cs.cfg.ErrorMode, "status", cs.errCode, "afterChunks", sent)
+			e := openAIError(cs.errCode, "mock error")
+			e.Error.Injected = true
+			yield(cs.failed(e, sentBytes))
+		}
+
+		// Content chunks
+		var firstDelta, lastDelta time.Time
+		var firstEmitted int64
+		sent := 0
+		pace := newStreamPacer(cs.cfg)
+		for {
+			if sent == failAfter {
+				fail(sent)
+				return
+			}
+			if ctx.Err() != nil {
+				if !capped(ctx) {
+					return
+				}
+				break
+			}
+
+			part, ok := cs.gen.Next(cs.chunkSize)
+			if !ok {
+				break
+			}
+			ch := cs.chunk()
+			ch.Choices[0].Delta.Content = part
+			if !yield(chatStreamEvent{kind: chatEventDelta, chunk: ch}) {
+				return
+			}
+			now := time.Now()
+			if firstDelta.IsZero() {
+				firstDelta = now
+				firstEmitted = ch.EmittedAtUnixMs
+			} else {
+				metrics.ChunkGap.WithLabelValues(cs.pattern).Observe(now.Sub(lastDelta).Seconds())
+			}
+			lastDelta = now
+			sent++
+			sentBytes += len(part)
+			if err := cs.kv.grow(mock.ApproxTokens(part)); err != nil {
+				yield(cs.failed(openAIError(http.StatusTooManyRequests, status.Convert(err).Message()), sentBytes))
+				return
+			}
+
+			pace.wait(ctx, part)
+		}
+		if failAfter >= 0 && !capped(ctx) {
+			// Single-chunk output: fail after its only delta.
+			fail(sent)
+			return
+		}
+
+		// Done
+		done := cs.chunk()
+		reason := string(events.FinishStop)
+		if capped(ctx) {
+			// Cut by MAX_REQUEST_DURATION_MS: the done chunk ends the stream with what was sent.
+			cs.gen.Truncate()
+			cs.ct = cs.gen.Tokens()
+			reason = string(cappedFinishReason(cs.cfg))
+			if firstDelta.IsZero() { // cut before the first delta
+				firstDelta = time.Now()
+			}
+		}
+		done.Choices[0].FinishReason = &reason
+		done.Timing = &mock.StreamTiming{
+			QueueMs:      queue.Milliseconds(),
+			PromptEvalMs: prefill.Milliseconds(),
+			TTFTMs:       firstDelta.Sub(cs.start).Milliseconds(),
+			GenerationMs: time.Since(firstDelta).Milliseconds(),
+		}
+		done.Output = cs.gen.Digest()
+		done.Sampling = cs.sampling
+		done.Debug = cs.debug
+		done.FirstDeltaAtUnixMs = firstEmitted
+		if cs.usage {
+			done.Usage = &mock.StreamUsage{PromptTokens: cs.pt, CompletionTokens: cs.ct, TotalTokens: cs.pt + cs.ct}
+		}
+		done.EmittedAtUnixMs = emittedAt(cs.cfg)
+		yield(chatStreamEvent{kind: chatEventDone, chunk: done})
+	}
+}
+
+// failed returns the failed event for e, with the usage the stream delivered: the prompt and the
+// first sentBytes of its output.
+func (cs *chatStream) failed(e mock.ErrorResponse, sentBytes int) chatStreamEvent {
+	ct := cs.gen.TokensOf(sentBytes)
+	e.Usage = &mock.StreamUsage{PromptTokens: cs.pt, CompletionTokens: ct, TotalTokens: cs.pt + ct}
+	e.LatencyMs = time.Since(cs.start).Milliseconds()
+	return chatStreamEvent{kind: chatEventFailed, err: e}
+}
+
+// chatStreamFormat frames the events of a streamed chat completion on the wire.
+type chatStreamFormat struct {
+	name        string // for logs
+	contentType string
+	keepalive   bool // write SSE_KEEPALIVE_MS comment pings; only SSE has comments
+	usage       bool // the done chunk carries the usage
+	write       func(bw *bufio.Writer, ev chatStreamEvent) error
+}
+
+// sseFormat frames events as SSE, named after cfg.EventNaming (see sseNames), ending with [DONE].
+func sseFormat(cfg config.Config) chatStreamFormat {
+	names := sseNames(cfg)
+	return chatStreamFormat{
+		name:        "sse",
+		contentType: "text/event-stream",
+		keepalive:   true,
+		write: func(bw *bufio.Writer, ev chatStreamEvent) error {
+			switch ev.kind {
+			case chatEventRole:
+				return writeSSENamed(bw, names.role, ev.chunk)
+			case chatEventDelta:
+				return writeSSENamed(bw, names.delta, ev.chunk)
+			case chatEventFailed:
+				return writeSSENamed(bw, names.failed, ev.err)
+			}
+			if err := writeSSENamed(bw, names.done, ev.chunk); err != nil {
+				return err
+			}
+			if names.stop != "" {
+				if err := writeSSEEvent(bw, string(names.stop), map[string]string{"type": string(names.stop)}); err != nil {
+					return err
+				}
+			}
+			_, err := fmt.Fprint(bw, "data: [DONE]\n\n")
+			return err
+		},
+	}
+}
+
+// ndjsonFormat frames events as newline-delimited JSON: one chunk per line, no [DONE]. The done
+// chunk, with its finish reason and usage, ends the stream; a failure ends it with an error object.
+var ndjsonFormat = chatStreamFormat{
+	name:        "ndjson",
+	contentType: "application/x-ndjson",
+	usage:       true,
+	write: func(bw *bufio.Writer, ev chatStreamEvent) error {
+		if ev.kind == chatEventFailed {
+			return writeNDJSON(bw, ev.err)
+		}
+		return writeNDJSON(bw, ev.chunk)
+	},
+}
+
+// wantsNDJSON reports whether a chat stream request asks for ND-JSON instead of SSE, with
+// ?stream_format=ndjson or an Accept header naming application/x-ndjson.
+func wantsNDJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("stream_format"); f != "" {
+		return strings.EqualFold(f, "ndjson")
+	}
+	for _, v := range r.Header.Values("Accept") {
+		for _, typ := range strings.Split(v, ",") {
+			typ, _, _ = strings.Cut(typ, ";")
+			if strings.EqualFold(strings.TrimSpace(typ), "application/x-ndjson") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chatStreamFormatFor returns the format a chat stream request asks for.
+func chatStreamFormatFor(r *http.Request, cfg config.Config) chatStreamFormat {
+	if wantsNDJSON(r) {
+		return ndjsonFormat
+	}
+	return sseFormat(cfg)
+}
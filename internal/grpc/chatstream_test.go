@@ -0,0 +1,151 @@
+package grpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+// parseNDJSON decodes an ND-JSON stream: one chunk per line, none of the SSE framing.
+func parseNDJSON(t *testing.T, body string) []mock.StreamChunk {
+	t.Helper()
+	if strings.Contains(body, "data:") || strings.Contains(body, "[DONE]") {
+		t.Fatalf("ND-JSON stream carries SSE framing:\n%s", body)
+	}
+	var chunks []mock.StreamChunk
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var ch mock.StreamChunk
+		if err := json.Unmarshal([]byte(line), &ch); err != nil {
+			t.Fatalf("bad ND-JSON line: %v\n%s", err, line)
+		}
+		chunks = append(chunks, ch)
+	}
+	return chunks
+}
+
+func TestStreamNDJSONAlignsWithGrpcOutput(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(7),
+		DefaultTokens:   10,
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+	}
+	prompt := "ndjson prompt"
+	expected := buildOutput(cfg, prompt, 10)
+	expectedChunks := (len(expected) + *cfg.ChunkSize - 1) / *cfg.ChunkSize
+
+	for name, req := range map[string]func() *httptest.ResponseRecorder{
+		"query": func() *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/?prompt=ndjson%20prompt&max_tokens=10&stream_format=ndjson", nil))
+			return rr
+		},
+		"accept": func() *httptest.ResponseRecorder {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(`{"messages":[{"role":"user","content":"ndjson prompt"}],"max_tokens":10,"stream":true}`))
+			r.Header.Set("Accept", "application/json, application/x-ndjson;q=0.9")
+			rr := httptest.NewRecorder()
+			ChatCompletionSSEHandler(cfg).ServeHTTP(rr, r)
+			return rr
+		},
+	} {
+		rr := req()
+		if rr.Code != 200 || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+			t.Fatalf("%s: got %d with content type %q\n%s", name, rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+		chunks := parseNDJSON(t, rr.Body.String())
+
+		if first := chunks[0]; len(first.Choices) != 1 || first.Choices[0].Delta.Role != "assistant" {
+			t.Fatalf("%s: first chunk missing assistant role: %+v", name, first)
+		}
+		last := chunks[len(chunks)-1]
+		if len(last.Choices) != 1 || last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != string(events.FinishStop) {
+			t.Fatalf("%s: final chunk missing finish_reason stop: %+v", name, last)
+		}
+
+		var assembled strings.Builder
+		for i := 1; i < len(chunks)-1; i++ {
+			delta := chunks[i].Choices[0].Delta.Content
+			if delta == "" || len(delta) > *cfg.ChunkSize {
+				t.Fatalf("%s: chunk %d has bad content %q", name, i, delta)
+			}
+			if chunks[i].Usage != nil || chunks[i].Timing != nil {
+				t.Fatalf("%s: chunk %d carries final-chunk fields", name, i)
+			}
+			assembled.WriteString(delta)
+		}
+		if got := assembled.String(); got != expected {
+			t.Fatalf("%s: reassembled content mismatch\nexpected len=%d\ngot len=%d", name, len(expected), len(got))
+		}
+		if gotChunks := len(chunks) - 2; gotChunks != expectedChunks {
+			t.Fatalf("%s: delta chunk count mismatch: got %d, expected %d", name, gotChunks, expectedChunks)
+		}
+
+		sum := sha256.Sum256([]byte(expected))
+		if last.Timing == nil || last.Output == nil || last.Output.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Fatalf("%s: final chunk timing or digest missing: %+v", name, last)
+		}
+		u := last.Usage
+		if u == nil || u.CompletionTokens != mock.ApproxTokens(expected) || u.PromptTokens <= 0 || u.TotalTokens != u.PromptTokens+u.CompletionTokens {
+			t.Fatalf("%s: final chunk usage: %+v", name, u)
+		}
+	}
+}
+
+func TestStreamNDJSONMidStreamError(t *testing.T) {
+	cfg := config.Config{
+		ErrorRate:       1,
+		ErrorMode:       "500",
+		ErrorTiming:     "mid",
+		ChunkSize:       config.Int(4),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(128),
+	}
+	rr := httptest.NewRecorder()
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/?prompt=hi&max_tokens=16&stream_format=ndjson", nil))
+
+	if rr.Code != 200 {
+		t.Fatalf("mid-stream failure must keep status 200, got %d", rr.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	chunks := parseNDJSON(t, strings.Join(lines[:len(lines)-1], "\n"))
+	if len(chunks) < 2 || chunks[len(chunks)-1].Choices[0].Delta.Content == "" {
+		t.Fatalf("expected partial deltas before the error\n%s", rr.Body.String())
+	}
+
+	var e mock.ErrorResponse
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &e); err != nil {
+		t.Fatalf("bad error line: %v\n%s", err, rr.Body.String())
+	}
+	if e.Error.Type != "server_error" || !e.Error.Injected {
+		t.Fatalf("unexpected error line: %+v", e)
+	}
+}
+
+func TestWantsNDJSON(t *testing.T) {
+	for _, tc := range []struct {
+		url, accept string
+		want        bool
+	}{
+		{"/", "", false},
+		{"/", "text/event-stream", false},
+		{"/", "application/x-ndjson", true},
+		{"/", "text/event-stream, Application/X-NDJSON; q=0.5", true},
+		{"/?stream_format=NDJSON", "", true},
+		{"/?stream_format=sse", "application/x-ndjson", false},
+	} {
+		r := httptest.NewRequest("GET", tc.url, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		if got := wantsNDJSON(r); got != tc.want {
+			t.Fatalf("%s Accept %q: got %v, want %v", tc.url, tc.accept, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+// maxChoices bounds the n of a request, which multiplies the work of a stream.
+const maxChoices = 128
+
+// streamChoice is one choice of a ChatCompletionStream: the request's n choices are generated from
+// the same prompt and streamed interleaved, each with its own output and done event.
+type streamChoice struct {
+	index        int32
+	out          *mock.OutputStream
+	first        time.Time // when its first delta was sent
+	firstEmitted int64     // the first delta's EmittedAtUnixMs
+	sent         int       // bytes of its output sent
+	truncated    bool      // cut by MAX_REQUEST_DURATION_MS
+	done         bool      // its done event was sent
+}
+
+// choicesOutput returns the tokens and bytes of the outputs of choices together.
+func choicesOutput(choices []*streamChoice) (tokens, bytes int) {
+	for _, c := range choices {
+		tokens += c.out.Tokens()
+		bytes += c.out.Len()
+	}
+	return tokens, bytes
+}
+
+// choicesSentTokens returns the tokens of the deltas of choices sent so far, each choice's counted
+// as ApproxTokens of its concatenated deltas.
+func choicesSentTokens(choices []*streamChoice) int {
+	n := 0
+	for _, c := range choices {
+		n += c.out.TokensOf(c.sent)
+	}
+	return n
+}
+
+// choiceScheduler picks the choice whose delta goes out next in a stream (CHOICE_INTERLEAVE):
+// round_robin takes the choices with output left in turn, random draws one of them for every delta
+// and sequential finishes each choice before starting the next. Choices of different lengths run out
+// at different times, so their done events are spread over the stream.
+type choiceScheduler struct {
+	mode    string
+	choices []*streamChoice
+	last    int        // index of the choice picked last, -1 before the first
+	rng     *mock.Rand // source of the "random" picks, nil for the shared one
+}
+
+// next returns the next choice to send a delta of, or nil once every output is exhausted.
+func (sc *choiceScheduler) next() *streamChoice {
+	switch sc.mode {
+	case "random":
+		left := 0
+		for _, c := range sc.choices {
+			if c.out.Remaining() > 0 {
+				left++
+			}
+		}
+		if left == 0 {
+			return nil
+		}
+		// A single choice left draws nothing, so one-choice streams keep their seeded draws.
+		k := 0
+		if left > 1 {
+			k = sc.rng.Intn(left)
+		}
+		for _, c := range sc.choices {
+			if c.out.Remaining() == 0 {
+				continue
+			}
+			if k == 0 {
+				return c
+			}
+			k--
+		}
+	case "sequential":
+		for _, c := range sc.choices {
+			if c.out.Remaining() > 0 {
+				return c
+			}
+		}
+	default: // round_robin
+		n := len(sc.choices)
+		for i := 1; i <= n; i++ {
+			k := (sc.last + i) % n
+			if sc.choices[k].out.Remaining() > 0 {
+				sc.last = k
+				return sc.choices[k]
+			}
+		}
+	}
+	return nil
+}
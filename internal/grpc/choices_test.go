@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func TestChoiceInterleave(t *testing.T) {
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "four choices", MaxTokens: 120, N: 4}
+	for _, mode := range []string{"round_robin", "random", "sequential"} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := config.Config{
+				ChunkSize:        config.Int(8),
+				Randomize:        true,
+				StrictTokenMode:  config.Bool(true),
+				ChoiceInterleave: mode,
+			}
+			// Each choice picks its own length before anything else is drawn.
+			prompt := buildPromptForTokens(req)
+			mock.Seed(9)
+			var want []string
+			for range 4 {
+				want = append(want, buildOutput(cfg, prompt, mock.PickTargetTokens(120, len([]rune(prompt)))))
+			}
+
+			mock.Seed(9)
+			fs := llmtest.NewServerStream(context.Background())
+			if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+
+			// Reassemble per index: no delta of a choice may follow its done event.
+			texts := make([]string, 4)
+			done := make([]*llmv1.ChatCompletionChunkResponse, 4)
+			var order []int32 // choice index of every delta
+			for _, c := range fs.Chunks() {
+				switch c.GetType() {
+				case string(events.OutputTextDelta):
+					if done[c.GetIndex()] != nil {
+						t.Fatalf("delta for choice %d after its done event", c.GetIndex())
+					}
+					texts[c.GetIndex()] += c.GetText()
+					order = append(order, c.GetIndex())
+				case string(events.OutputTextDone):
+					done[c.GetIndex()] = c
+				}
+			}
+			var total int
+			for i, text := range texts {
+				if text != want[i] {
+					t.Fatalf("choice %d: reassembled %d bytes, want %d", i, len(text), len(want[i]))
+				}
+				sum := sha256.Sum256([]byte(text))
+				d := done[i]
+				if d == nil || d.GetFinishReason() != string(events.FinishStop) || d.GetOutputSha256() != hex.EncodeToString(sum[:]) || int(d.GetCompletionTokens()) != mock.ApproxTokens(text) {
+					t.Fatalf("choice %d: done event doesn't describe its text: %+v", i, d)
+				}
+				total += int(d.GetCompletionTokens())
+			}
+			if got := fs.Trailer().Get(usageCompletionTokensKey); len(got) != 1 || got[0] != strconv.Itoa(total) {
+				t.Fatalf("usage trailer %v, want the %d tokens of every choice", got, total)
+			}
+
+			switch mode {
+			case "round_robin":
+				if !slices.Equal(order[:4], []int32{0, 1, 2, 3}) {
+					t.Fatalf("round_robin should start with one delta per choice, got %v", order[:4])
+				}
+			case "random":
+				if slices.IsSorted(order) || slices.Equal(order[:4], []int32{0, 1, 2, 3}) {
+					t.Fatalf("random interleave looks scheduled: %v", order)
+				}
+			case "sequential":
+				if !slices.IsSorted(order) {
+					t.Fatalf("sequential should finish each choice before the next: %v", order)
+				}
+			}
+		})
+	}
+}
+
+// TestChoicesSharePacing verifies the choices of a stream share one pacing budget: three choices of
+// 40 tokens at 1000 tok/s take as long as one stream of 120 tokens.
+func TestChoicesSharePacing(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:        config.Int(16),
+		StrictTokenMode:  config.Bool(true),
+		TokensPerSec:     config.Float(1000),
+		ChoiceInterleave: "random",
+	}
+	fs := llmtest.NewServerStream(context.Background())
+	start := time.Now()
+	if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "paced", MaxTokens: 40, N: 3}, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	if took := time.Since(start); took < 110*time.Millisecond || took > 250*time.Millisecond {
+		t.Fatalf("expected ~120ms for 120 tokens at 1000 tok/s, took %v", took)
+	}
+}
+
+func TestChoicesOutOfRange(t *testing.T) {
+	for _, n := range []int32{-1, maxChoices + 1} {
+		err := NewMockLlmService(config.Config{}).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "n", N: n}, llmtest.NewServerStream(context.Background()))
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("n=%d: expected InvalidArgument, got %v", n, err)
+		}
+	}
+}
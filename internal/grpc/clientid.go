@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// clientRequestIDKey is the metadata (or HTTP header) carrying the caller's own correlation ID for a
+// request. It is echoed verbatim as client_request_id on every chunk and response of the request,
+// in the response headers and in its log lines, so clients can check the answer is theirs.
+const clientRequestIDKey = "x-client-request-id"
+
+// grpcClientRequestID returns the client request ID of an RPC: meta.client_request_id, else its
+// x-client-request-id metadata, else requestID (the server's) with CLIENT_REQUEST_ID_FALLBACK, else "".
+func grpcClientRequestID(ctx context.Context, cfg config.Config, req *llmv1.ChatCompletionRequest, requestID string) string {
+	if id := req.GetMeta().GetClientRequestId(); id != "" {
+		return id
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(clientRequestIDKey); len(v) > 0 && v[0] != "" {
+		return v[0]
+	}
+	if cfg.ClientRequestIDFallback {
+		return requestID
+	}
+	return ""
+}
+
+// httpClientRequestID is grpcClientRequestID for an HTTP request: its x-client-request-id header,
+// else its X-Request-Id or a generated ID with CLIENT_REQUEST_ID_FALLBACK, else "".
+func httpClientRequestID(r *http.Request, cfg config.Config) string {
+	if id := r.Header.Get(clientRequestIDKey); id != "" {
+		return id
+	}
+	if !cfg.ClientRequestIDFallback {
+		return ""
+	}
+	if id := r.Header.Get(requestIDKey); id != "" {
+		return id
+	}
+	return newRequestID()
+}
+
+// identify resolves the request ID and the client request ID of an RPC once, so its headers,
+// chunks, record and log lines agree.
+func (s *MockLlmService) identify(ctx context.Context, req *llmv1.ChatCompletionRequest) {
+	s.requestID = rpcRequestID(ctx)
+	s.clientID = grpcClientRequestID(ctx, s.cfg, req, s.requestID)
+	setClientRequestID(ctx, s.clientID)
+}
+
+// withClientRequestID returns l adding id to every line, l itself when id is empty.
+func withClientRequestID(l *zap.SugaredLogger, id string) *zap.SugaredLogger {
+	if id == "" {
+		return l
+	}
+	return l.With("clientRequestId", id)
+}
+
+type clientRequestIDCtxKey struct{}
+
+// withHTTPClientRequestID returns r carrying its client request ID (see httpClientRequestID) and
+// echoes it in the x-client-request-id response header. liveHandler sets it up.
+func withHTTPClientRequestID(w http.ResponseWriter, r *http.Request, cfg config.Config) *http.Request {
+	id := httpClientRequestID(r, cfg)
+	if id == "" {
+		return r
+	}
+	w.Header().Set(clientRequestIDKey, id)
+	return r.WithContext(context.WithValue(r.Context(), clientRequestIDCtxKey{}, id))
+}
+
+// requestClientID returns the client request ID of an HTTP request, "" when it has none.
+func requestClientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientRequestIDCtxKey{}).(string)
+	return id
+}
+
+// requestLog returns l with the client request ID of an HTTP request on every line.
+func requestLog(ctx context.Context, l *zap.SugaredLogger) *zap.SugaredLogger {
+	return withClientRequestID(l, requestClientID(ctx))
+}
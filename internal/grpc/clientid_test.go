@@ -0,0 +1,180 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func clientIDConfig() config.Config {
+	return config.Config{
+		ChunkSize:       config.Int(4),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(128),
+		AccessLog:       true,
+	}
+}
+
+// requireClientIDLogged checks every line logged with a clientRequestId field carries id, and that
+// the start and access lines are among them.
+func requireClientIDLogged(t *testing.T, entries []observer.LoggedEntry, id string, want ...string) {
+	t.Helper()
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if v, ok := e.ContextMap()["clientRequestId"]; ok {
+			if v != id {
+				t.Fatalf("%q logged clientRequestId %v, want %q", e.Message, v, id)
+			}
+			seen[e.Message] = true
+		}
+	}
+	for _, m := range want {
+		if !seen[m] {
+			t.Fatalf("%q was not logged with the client request ID; logged with it: %v", m, seen)
+		}
+	}
+}
+
+func TestClientRequestIDUnary(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	client := startTestServer(t, clientIDConfig())
+
+	var header, trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), clientRequestIDKey, "worker-7:42")
+	resp, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "echo", MaxTokens: 8}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatalf("ChatCompletion err: %v", err)
+	}
+	if resp.GetClientRequestId() != "worker-7:42" {
+		t.Fatalf("response client_request_id %q", resp.GetClientRequestId())
+	}
+	if v := header.Get(clientRequestIDKey); len(v) != 1 || v[0] != "worker-7:42" {
+		t.Fatalf("response header %s = %v", clientRequestIDKey, v)
+	}
+	requireClientIDLogged(t, logs.All(), "worker-7:42", "[grpc][ChatCompletion] start", "[grpc][access]")
+
+	// Without one the field and the header are empty.
+	header = nil
+	resp, err = client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "echo", MaxTokens: 8}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("ChatCompletion err: %v", err)
+	}
+	if resp.GetClientRequestId() != "" || header.Get(clientRequestIDKey) != nil {
+		t.Fatalf("a request without a client request ID got %q, header %v", resp.GetClientRequestId(), header.Get(clientRequestIDKey))
+	}
+}
+
+func TestClientRequestIDStream(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		md   metadata.MD
+		meta *llmv1.RequestMeta
+		want string
+	}{
+		{"metadata", metadata.Pairs(clientRequestIDKey, "md-1"), nil, "md-1"},
+		{"meta field wins", metadata.Pairs(clientRequestIDKey, "md-1"), &llmv1.RequestMeta{ClientRequestId: "proto-1"}, "proto-1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := observeLogsAt(t, zapcore.DebugLevel)
+			fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), tc.md))
+			req := &llmv1.ChatCompletionRequest{UserPrompt: "echo me back", MaxTokens: 16, Meta: tc.meta}
+			if err := NewMockLlmService(clientIDConfig()).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+			for i, c := range fs.Chunks() {
+				if c.GetClientRequestId() != tc.want {
+					t.Fatalf("chunk %d (%s) carries %q, want %q", i, c.GetType(), c.GetClientRequestId(), tc.want)
+				}
+			}
+			if v := fs.Header().Get(clientRequestIDKey); len(v) != 1 || v[0] != tc.want {
+				t.Fatalf("response header %s = %v", clientRequestIDKey, v)
+			}
+			requireClientIDLogged(t, logs.All(), tc.want, "[grpc][ChatCompletionStream] start", "[grpc][ChatCompletionStream] done")
+		})
+	}
+
+	// The failed chunk carries it too.
+	cfg := clientIDConfig()
+	cfg.ErrorRate, cfg.ErrorMode = 1, "429"
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs(clientRequestIDKey, "md-2")))
+	_ = NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8}, fs)
+	for _, c := range fs.Chunks() {
+		if c.GetClientRequestId() != "md-2" {
+			t.Fatalf("%s chunk carries %q", c.GetType(), c.GetClientRequestId())
+		}
+	}
+}
+
+func TestClientRequestIDFallback(t *testing.T) {
+	cfg := clientIDConfig()
+	cfg.ClientRequestIDFallback = true
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDKey, "server-9")))
+	if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8}, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	if c := llmtest.Terminal(fs.Chunks()); c.GetClientRequestId() != "server-9" {
+		t.Fatalf("fallback echoed %q, want the request ID", c.GetClientRequestId())
+	}
+
+	// A generated request ID is the one in the x-request-id header.
+	fs = llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8}, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	id := llmtest.Terminal(fs.Chunks()).GetClientRequestId()
+	if v := fs.Header().Get(requestIDKey); id == "" || len(v) != 1 || v[0] != id {
+		t.Fatalf("fallback echoed %q, x-request-id %v", id, v)
+	}
+}
+
+func TestClientRequestIDHTTP(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	mux := NewLiveHTTPMux(NewMockLlmService(clientIDConfig()), nil, nil, nil)
+
+	// SSE: every chunk and the response header.
+	r := httptest.NewRequest("GET", "/v1/chat/completions?prompt=echo%20me%20back&max_tokens=16", nil)
+	r.Header.Set(clientRequestIDKey, "sse-1")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	if got := rr.Header().Get(clientRequestIDKey); got != "sse-1" {
+		t.Fatalf("response header %s = %q", clientRequestIDKey, got)
+	}
+	for i, ch := range parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks {
+		if ch.ClientRequestID != "sse-1" {
+			t.Fatalf("chunk %d carries %q", i, ch.ClientRequestID)
+		}
+	}
+	requireClientIDLogged(t, logs.All(), "sse-1", "[http][ChatCompletionSSE] start")
+
+	// JSON.
+	r = httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"max_tokens":8,"messages":[{"role":"user","content":"echo"}]}`))
+	r.Header.Set(clientRequestIDKey, "json-1")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	var resp mock.ChatResponse
+	body, _ := io.ReadAll(rr.Body)
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ClientRequestID != "json-1" || rr.Header().Get(clientRequestIDKey) != "json-1" {
+		t.Fatalf("JSON response doesn't echo the client request ID (%v): %s", err, body)
+	}
+
+	// Without one nothing is added.
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/chat/completions?prompt=hi&max_tokens=8", nil))
+	if rr.Header().Get(clientRequestIDKey) != "" || strings.Contains(rr.Body.String(), "client_request_id") {
+		t.Fatalf("a request without a client request ID got one:\n%s", rr.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+)
+
+// compressionOptions implements GRPC_COMPRESSION. gzip is always registered (see the gzip import), so by
+// default the server accepts gzip requests and answers with the compressor the client used. With
+// GRPC_COMPRESSION=off, compressed requests are rejected with Unimplemented and responses are always
+// sent uncompressed.
+func compressionOptions(cfg config.Config) []grpc.ServerOption {
+	if cfg.GRPCCompression != "off" {
+		return nil
+	}
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(noCompressionUnaryInterceptor),
+		WithStreamInterceptors(noCompressionStreamInterceptor),
+	}
+}
+
+func noCompressionUnaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := disableCompression(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func noCompressionStreamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := disableCompression(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func disableCompression(ctx context.Context) error {
+	if c := requestCompressor(ctx); c != "" && c != encoding.Identity {
+		return status.Errorf(codes.Unimplemented, "compression %q is disabled on this server", c)
+	}
+	_ = grpc.SetSendCompressor(ctx, encoding.Identity)
+	return nil
+}
+
+// requestCompressor returns the compressor the client used for the request ("" when uncompressed
+// or when ctx is not a gRPC server context, e.g. in tests).
+func requestCompressor(ctx context.Context) string {
+	if s, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string }); ok {
+		return s.RecvCompress()
+	}
+	return ""
+}
+
+// isGzipRequest reports whether the client sent the request gzip-compressed.
+func isGzipRequest(ctx context.Context) bool {
+	return requestCompressor(ctx) == gzip.Name
+}
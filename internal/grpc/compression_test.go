@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// startTestServer serves cfg's LlmService on a loopback listener and returns a client dialed with opts.
+func startTestServer(t *testing.T, cfg config.Config, opts ...grpc.DialOption) llmv1.LlmServiceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	serverOpts, err := ServerOptions(cfg, NewLimits(cfg), nil)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), NewMockLlmService(cfg), serverOpts...)
+	served := make(chan struct{})
+	go func() {
+		_ = srv.RunWithListener(lis)
+		close(served)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		<-served
+	})
+
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(lis.Addr().String(), opts...)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return llmv1.NewLlmServiceClient(conn)
+}
+
+func TestGzipStreamRoundTrip(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(512), GRPCCompression: "gzip", GzipChunkDelayMs: 5}
+	client := startTestServer(t, cfg, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "compress me", MaxTokens: 32}
+	expected := buildOutput(cfg, buildPromptForTokens(req), 32)
+
+	start := time.Now()
+	stream, err := client.ChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	var assembled strings.Builder
+	chunks := 0
+	for {
+		ch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		if ch.GetType() == string(events.OutputTextDelta) {
+			assembled.WriteString(ch.GetText())
+			chunks++
+		}
+	}
+	if assembled.String() != expected {
+		t.Fatalf("decompressed output mismatch\nexpected %q\ngot      %q", expected, assembled.String())
+	}
+	if min := time.Duration(chunks*cfg.GzipChunkDelayMs) * time.Millisecond; time.Since(start) < min {
+		t.Fatalf("gzip chunk delay not applied: %v < %v", time.Since(start), min)
+	}
+
+	resp, err := client.ChatCompletion(context.Background(), req)
+	if err != nil || resp.GetOutputText() != expected {
+		t.Fatalf("unary gzip round trip failed: %v", err)
+	}
+}
+
+func TestCompressionOff(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), GRPCCompression: "off"}
+	client := startTestServer(t, cfg, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+
+	_, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hi"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented for a gzip request, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"net/http"
+	"slices"
+)
+
+// corsAllowedHeaders covers the auth and content headers used by the OpenAI, Anthropic, Gemini and Azure
+// shims, plus the headers gRPC-Web clients send.
+const corsAllowedHeaders = "Authorization, Content-Type, api-key, x-api-key, x-goog-api-key, anthropic-version, " +
+	"x-grpc-web, x-user-agent, grpc-timeout"
+
+// corsExposedHeaders lets browser clients read response metadata, including gRPC-Web status headers.
+const corsExposedHeaders = "x-ms-request-id, x-client-request-id, grpc-status, grpc-message"
+
+// withCORS wraps h with CORS handling for the given origin allowlist ("*" allows any origin).
+// Allowed origins are echoed back; OPTIONS preflights are answered here and never reach h.
+// Requests from other origins are served without CORS headers (so browsers block them), and their
+// preflights are rejected with 403.
+func withCORS(allowed []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		ok := slices.Contains(allowed, "*") || slices.Contains(allowed, origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !ok {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+func corsConfig() config.Config {
+	return config.Config{
+		StrictTokenMode:    config.Bool(true),
+		MaxOutputChars:     config.Int(64),
+		CORSAllowedOrigins: []string{"http://localhost:3000"},
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rr := httptest.NewRecorder()
+
+	NewHTTPMux(corsConfig(), nil, nil, nil).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("unexpected preflight status: %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Fatalf("unexpected allow-origin: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "POST") {
+		t.Fatalf("unexpected allow-methods: %q", got)
+	}
+	allowHeaders := strings.ToLower(rr.Header().Get("Access-Control-Allow-Headers"))
+	if !strings.Contains(allowHeaders, "authorization") || !strings.Contains(allowHeaders, "content-type") {
+		t.Fatalf("unexpected allow-headers: %q", allowHeaders)
+	}
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	body := `{"max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Origin", "http://localhost:3000")
+	rr := httptest.NewRecorder()
+
+	NewHTTPMux(corsConfig(), nil, nil, nil).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Fatalf("unexpected allow-origin: %q", got)
+	}
+
+	cfg := corsConfig()
+	cfg.CORSAllowedOrigins = []string{"*"}
+	req = httptest.NewRequest(http.MethodGet, "/v1/chat/completions?prompt=hi&max_tokens=4", nil)
+	req.Header.Set("Origin", "http://example.test")
+	rr = httptest.NewRecorder()
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://example.test" {
+		t.Fatalf("wildcard allowlist should echo the origin, got %q", got)
+	}
+}
+
+func TestCORSRejectedOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "http://evil.test")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+
+	NewHTTPMux(corsConfig(), nil, nil, nil).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("unexpected preflight status for rejected origin: %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("rejected origin must not get allow-origin, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/chat/completions?prompt=hi&max_tokens=4", nil)
+	req.Header.Set("Origin", "http://evil.test")
+	rr = httptest.NewRecorder()
+	NewHTTPMux(corsConfig(), nil, nil, nil).ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("rejected origin must not get allow-origin, got %q", got)
+	}
+}
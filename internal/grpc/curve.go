@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"math"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+// curveFactor returns the share of TokensPerSec the TPS_CURVE allows at token pos of a response:
+//
+//	rampup: from 1-depth up to full speed over the first TPS_CURVE_TOKENS tokens, like a decoder
+//	        warming up after prefill
+//	decay:  from full speed down to 1-depth over the first TPS_CURVE_TOKENS tokens, like attention
+//	        slowing down as the context grows; it stays there after
+//	sine:   between full speed and 1-depth, one period every TPS_CURVE_TOKENS tokens (stutter)
+//
+// constant and unknown curves are always at full speed.
+func curveFactor(cfg config.Config, pos float64) float64 {
+	depth := min(max(cfg.TPSCurveDepth, 0), 0.95) // Validate rejects >= 1; keep the speed > 0 anyway
+	span := float64(max(cfg.TPSCurveTokens, 1))
+	switch cfg.TPSCurve {
+	case "rampup":
+		return 1 - depth*(1-min(pos/span, 1))
+	case "decay":
+		return 1 - depth*min(pos/span, 1)
+	case "sine":
+		return 1 - depth*(1-math.Cos(2*math.Pi*pos/span))/2
+	}
+	return 1
+}
+
+// curveDuration is the time to generate the toks tokens after the first from tokens of a response
+// at tps tokens per second scaled by the TPS_CURVE. The speed is taken at the middle of each token,
+// so the duration of a response depends only on its length and the curve, not on its chunking.
+func curveDuration(cfg config.Config, from, toks int, tps float64) time.Duration {
+	if cfg.TPSCurve == "" || cfg.TPSCurve == "constant" {
+		return tokensDuration(toks, tps)
+	}
+	var secs float64
+	for i := from; i < from+toks; i++ {
+		secs += 1 / (tps * curveFactor(cfg, float64(i)+0.5))
+	}
+	return time.Duration(secs * float64(time.Second))
+}
@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// debugKey is the metadata (or HTTP header) a client sets to "true" to get the simulation parameters
+// of its request back (see mock.Debug). HTTP also takes ?debug=1.
+const debugKey = "x-debug"
+
+// debugTrailerKey is the gRPC trailer carrying the mock.Debug JSON of a request sent with x-debug.
+// HTTP responses carry it in their "debug" field instead.
+const debugTrailerKey = "x-sim-debug"
+
+// Override sources of a mock.Debug: what changed the config a request ran with from the one the
+// server loaded.
+const (
+	overrideAdmin   = "admin"   // AdminService UpdateConfig or a scenario step
+	overrideFault   = "fault"   // an active FAULT_SCHEDULE fault
+	overrideHeader  = "header"  // x-mock-* metadata or HTTP headers
+	overrideRequest = "request" // the "mock" block of an HTTP body or the mock_overrides of an RPC
+	overrideReplay  = "replay"  // a REPLAY_FILE trace, which sets the TTFT and chunk sizes
+)
+
+// newDebug returns the debug record of a request that runs on cfg, taken from live.
+func newDebug(cfg config.Config, live *config.Holder) *mock.Debug {
+	d := &mock.Debug{Preset: cfg.Preset, BaseDelayMs: cfg.BaseDelayMs}
+	if live != nil && live.Updated() {
+		d.Overrides = append(d.Overrides, overrideAdmin)
+	}
+	if faultsActive() {
+		d.Overrides = append(d.Overrides, overrideFault)
+	}
+	return d
+}
+
+// grpcDebug returns the debug record of an RPC whose metadata sets x-debug, nil otherwise.
+func (s *MockLlmService) grpcDebug(ctx context.Context) *mock.Debug {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(debugKey); len(v) == 0 || !isTrue(v[0]) {
+		return nil
+	}
+	d := newDebug(s.cfg, s.live)
+	d.Priority, d.Tenant = s.priority.String(), s.tenant
+	return d
+}
+
+// wantsDebug reports whether an HTTP request asks for its simulation parameters, with ?debug=1 or an
+// x-debug header.
+func wantsDebug(r *http.Request) bool {
+	if v := r.URL.Query().Get("debug"); v != "" {
+		return isTrue(v)
+	}
+	return isTrue(r.Header.Get(debugKey))
+}
+
+func isTrue(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+type debugCtxKey struct{}
+
+// withDebug returns r carrying the debug record of a request run on cfg when it asks for one (see
+// wantsDebug). liveHandler sets it up, so the record knows about admin updates.
+func withDebug(r *http.Request, cfg config.Config, live *config.Holder) *http.Request {
+	if !wantsDebug(r) {
+		return r
+	}
+	d := newDebug(cfg, live)
+	d.Priority = requestPriority(r.Context()).String()
+	if p := requestTenant(r.Context()); p != nil {
+		d.Tenant = p.name
+	}
+	return r.WithContext(context.WithValue(r.Context(), debugCtxKey{}, d))
+}
+
+// requestDebug returns the debug record of an HTTP request, nil when it didn't ask for one.
+func requestDebug(ctx context.Context) *mock.Debug {
+	d, _ := ctx.Value(debugCtxKey{}).(*mock.Debug)
+	return d
+}
+
+// recordTokens records the output length of a request: maxTokens asked for, target picked.
+func recordTokens(d *mock.Debug, maxTokens, target int) {
+	if d != nil {
+		d.MaxTokens, d.TargetTokens = maxTokens, target
+	}
+}
+
+// recordChunkSize records the chunk size a stream used.
+func recordChunkSize(d *mock.Debug, chunkSize int) {
+	if d != nil {
+		d.ChunkSize = chunkSize
+	}
+}
+
+// debugTrailer returns the trailer carrying d.
+func debugTrailer(d *mock.Debug) metadata.MD {
+	b, _ := json.Marshal(d)
+	return metadata.Pairs(debugTrailerKey, string(b))
+}
@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// debugConfig draws every parameter mock.Debug reports.
+func debugConfig() config.Config {
+	return config.Config{
+		Preset:          "vllm",
+		BaseDelayMs:     3,
+		JitterMs:        20,
+		TTFTMinMs:       config.Int(5),
+		TTFTMaxMs:       config.Int(30),
+		ChunkSize:       config.Int(12),
+		Randomize:       true,
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(4096),
+	}
+}
+
+func parseDebug(t *testing.T, md metadata.MD) mock.Debug {
+	t.Helper()
+	v := md.Get(debugTrailerKey)
+	if len(v) != 1 {
+		t.Fatalf("expected one %s trailer, got %v", debugTrailerKey, md)
+	}
+	var d mock.Debug
+	if err := json.Unmarshal([]byte(v[0]), &d); err != nil {
+		t.Fatalf("bad debug trailer %q: %v", v[0], err)
+	}
+	return d
+}
+
+func TestDebugTrailerStream(t *testing.T) {
+	cfg := debugConfig()
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "debug me", MaxTokens: 200}
+
+	// The stream draws jitter, TTFT, the output length and the chunk size, in that order.
+	mock.Seed(11)
+	want := mock.Debug{
+		Preset:      "vllm",
+		BaseDelayMs: 3,
+		JitterMs:    mock.RandIntn(21),
+		TTFTMs:      5 + mock.RandIntn(26),
+		MaxTokens:   200,
+	}
+	want.TargetTokens = mock.PickTargetTokens(200, len([]rune(buildPromptForTokens(req))))
+	want.ChunkSize = mock.JitterChunkSize(12)
+
+	mock.Seed(11)
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs(debugKey, "true")))
+	if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	if got := parseDebug(t, fs.Trailer()); !equalDebug(got, want) {
+		t.Fatalf("debug trailer doesn't match the seeded draws:\ngot  %+v\nwant %+v", got, want)
+	}
+	if fs.Trailer().Get(usagePromptTokensKey) == nil {
+		t.Fatalf("the debug trailer replaced the usage trailer: %v", fs.Trailer())
+	}
+
+	fs = llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	if v := fs.Trailer().Get(debugTrailerKey); v != nil {
+		t.Fatalf("a request without x-debug got %v", v)
+	}
+}
+
+func TestDebugTrailerUnary(t *testing.T) {
+	cfg := debugConfig()
+	client := startTestServer(t, cfg)
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "debug me", MaxTokens: 64}
+
+	// The unary call picks the output length before it draws the delays.
+	mock.Seed(5)
+	want := mock.Debug{Preset: "vllm", BaseDelayMs: 3, MaxTokens: 64}
+	want.TargetTokens = mock.PickTargetTokens(64, len([]rune(buildPromptForTokens(req))))
+	want.JitterMs = mock.RandIntn(21)
+	want.TTFTMs = 5 + mock.RandIntn(26)
+
+	mock.Seed(5)
+	var trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), debugKey, "true")
+	if _, err := client.ChatCompletion(ctx, req, grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("ChatCompletion err: %v", err)
+	}
+	if got := parseDebug(t, trailer); !equalDebug(got, want) {
+		t.Fatalf("debug trailer doesn't match the seeded draws:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestDebugHTTP(t *testing.T) {
+	svc := NewMockLlmService(debugConfig())
+	mux := NewLiveHTTPMux(svc, nil, nil, nil)
+
+	// Stream: the chunk ID is drawn between the chunk size and the delays.
+	mock.Seed(3)
+	want := mock.Debug{Preset: "vllm", BaseDelayMs: 3, MaxTokens: 40}
+	want.TargetTokens = mock.PickTargetTokens(40, len("debug me"))
+	want.ChunkSize = mock.JitterChunkSize(12)
+	mock.RandID()
+	want.JitterMs = mock.RandIntn(21)
+	want.TTFTMs = 5 + mock.RandIntn(26)
+
+	mock.Seed(3)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/chat/completions?prompt=debug%20me&max_tokens=40&debug=1", nil))
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	if got := chunks[len(chunks)-1].Debug; got == nil || !equalDebug(*got, want) {
+		t.Fatalf("done chunk debug doesn't match the seeded draws:\ngot  %+v\nwant %+v", got, want)
+	}
+	for _, ch := range chunks[:len(chunks)-1] {
+		if ch.Debug != nil {
+			t.Fatalf("debug set on a chunk before the done chunk: %+v", ch)
+		}
+	}
+
+	// Unary, after an admin update and with a mock block: both sources are reported.
+	if _, err := svc.Config().Update(func(c *config.Config) { c.Randomize = false }); err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"debug me"}],"max_tokens":16,"mock":{"base_delay_ms":1}}`))
+	r.Header.Set(debugKey, "true")
+	mux.ServeHTTP(rr, r)
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Debug == nil {
+		t.Fatalf("expected a debug field: %v\n%s", err, rr.Body.String())
+	}
+	if d := resp.Debug; d.BaseDelayMs != 1 || d.TargetTokens != 16 || !slices.Equal(d.Overrides, []string{overrideAdmin, overrideRequest}) {
+		t.Fatalf("unexpected debug field: %+v", d)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"debug me"}],"max_tokens":16}`)))
+	if strings.Contains(rr.Body.String(), `"debug"`) {
+		t.Fatalf("a request without debug got a debug field:\n%s", rr.Body.String())
+	}
+}
+
+func equalDebug(a, b mock.Debug) bool {
+	return a.Preset == b.Preset && a.BaseDelayMs == b.BaseDelayMs && a.JitterMs == b.JitterMs && a.TTFTMs == b.TTFTMs &&
+		a.ChunkSize == b.ChunkSize && a.MaxTokens == b.MaxTokens && a.TargetTokens == b.TargetTokens && slices.Equal(a.Overrides, b.Overrides)
+}
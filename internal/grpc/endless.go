@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// endlessKey is the request metadata that asks for an endless stream.
+const endlessKey = "x-endless"
+
+// endlessDirective in the user prompt asks for an endless stream, for clients that can't set metadata.
+const endlessDirective = "[[endless]]"
+
+// wantsEndless reports whether a stream should generate until the client cancels: ENDLESS_STREAM is
+// on and the request asks for it with x-endless metadata or the "[[endless]]" directive. Such a
+// stream sends filler deltas at the configured pacing and never a done chunk; it ends with Canceled,
+// when the client cancels or MAX_REQUEST_DURATION_MS cuts it.
+func wantsEndless(ctx context.Context, cfg config.Config, req *llmv1.ChatCompletionRequest) bool {
+	if !cfg.EndlessStream {
+		return false
+	}
+	if strings.Contains(req.GetUserPrompt(), endlessDirective) {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get(endlessKey)
+	return len(v) > 0 && isTrue(v[0])
+}
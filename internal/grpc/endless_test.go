@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func endlessConfig() config.Config {
+	return config.Config{
+		ChunkSize:       config.Int(8),
+		StrictTokenMode: config.Bool(true),
+		TokensPerSec:    config.Float(2000),
+		EndlessStream:   true,
+	}
+}
+
+// TestEndlessStream cancels endless streams after 50 chunks, far more than max_tokens allows, and
+// checks they stopped right away without a done chunk, for both ways of asking for one.
+func TestEndlessStream(t *testing.T) {
+	const cancelAfter = 50
+	for _, tc := range []struct {
+		name string
+		md   metadata.MD
+		req  *llmv1.ChatCompletionRequest
+	}{
+		{"metadata", metadata.Pairs(endlessKey, "true"), &llmv1.ChatCompletionRequest{UserPrompt: "go on", MaxTokens: 8}},
+		{"directive", nil, &llmv1.ChatCompletionRequest{UserPrompt: "go on " + endlessDirective, MaxTokens: 8}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(metadata.NewIncomingContext(context.Background(), tc.md))
+			defer cancel()
+			fs := llmtest.NewServerStream(ctx)
+			var canceledAt time.Time
+			fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+				if len(fs.Chunks()) == cancelAfter {
+					canceledAt = time.Now()
+					cancel()
+				}
+			}
+
+			err := NewMockLlmService(endlessConfig()).ChatCompletionStream(tc.req, fs)
+			if status.Code(err) != codes.Canceled {
+				t.Fatalf("expected Canceled, got %v", err)
+			}
+			if took := time.Since(canceledAt); took > 50*time.Millisecond {
+				t.Fatalf("stream took %v to stop after the cancel", took)
+			}
+			chunks := fs.Chunks()
+			if len(chunks) != cancelAfter {
+				t.Fatalf("sent %d chunks, want %d: nothing after the cancel", len(chunks), cancelAfter)
+			}
+			if c := llmtest.Terminal(chunks); c != nil {
+				t.Fatalf("endless stream sent a terminal chunk: %+v", c)
+			}
+			if text := llmtest.Text(chunks); len(text) != cancelAfter*8 {
+				t.Fatalf("reassembled %d bytes, want %d", len(text), cancelAfter*8)
+			}
+		})
+	}
+}
+
+// TestEndlessStreamMaxDuration checks MAX_REQUEST_DURATION_MS stops an endless stream as canceled,
+// still without a done chunk, and that ENDLESS_STREAM off ignores the request for one.
+func TestEndlessStreamMaxDuration(t *testing.T) {
+	cfg := endlessConfig()
+	cfg.MaxRequestDurationMs = 60
+	req := &llmv1.ChatCompletionRequest{UserPrompt: endlessDirective, MaxTokens: 8}
+
+	fs := llmtest.NewServerStream(context.Background())
+	start := time.Now()
+	err := NewMockLlmService(cfg).ChatCompletionStream(req, fs)
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	if took := time.Since(start); took < 60*time.Millisecond || took > 200*time.Millisecond {
+		t.Fatalf("expected the stream to stop at the 60ms cap, took %v", took)
+	}
+	if len(fs.Chunks()) == 0 || llmtest.Terminal(fs.Chunks()) != nil {
+		t.Fatalf("expected deltas and no terminal chunk, got %d chunks ending %+v", len(fs.Chunks()), llmtest.Terminal(fs.Chunks()))
+	}
+
+	cfg.EndlessStream = false
+	fs = llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	llmtest.RequireDone(t, fs.Chunks(), events.FinishStop)
+}
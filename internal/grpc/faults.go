@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fault types of a FAULT_SCHEDULE.
+const (
+	faultError   = "error"   // inject errors: params.mode (ERROR_MODE, default mixed), params.rate (default 1)
+	faultLatency = "latency" // add params.delay_ms to BASE_DELAY_MS
+	faultStall   = "stall"   // streams send no further chunk until the fault ends
+)
+
+// Fault is one entry of a FAULT_SCHEDULE: fault_type is active from offset after startup for
+// duration. Offsets and durations take Go durations ("90s", "250ms").
+type Fault struct {
+	Offset   time.Duration `yaml:"offset"`
+	Duration time.Duration `yaml:"duration"`
+	Type     string        `yaml:"fault_type"`
+	Params   FaultParams   `yaml:"params"`
+}
+
+// FaultParams are the settings of a Fault; each fault type reads its own.
+type FaultParams struct {
+	Mode    string   `yaml:"mode"`
+	Rate    *float64 `yaml:"rate"`
+	DelayMs int      `yaml:"delay_ms"`
+}
+
+// FaultSchedule is a FAULT_SCHEDULE: failures at exact offsets from startup, for game-day drills.
+// While a fault is active it overrides the probabilistic knobs (an error fault replaces ERROR_RATE
+// and ERROR_MODE) for every request starting then, gRPC and HTTP alike. A YAML list:
+//
+//   - {offset: 120s, duration: 30s, fault_type: error, params: {mode: "429"}}
+//   - {offset: 300s, duration: 10s, fault_type: stall}
+type FaultSchedule struct {
+	faults []Fault
+	start  time.Time // set by SetFaultSchedule
+}
+
+// activeFaults is the schedule installed by SetFaultSchedule, nil without one. It is process-wide
+// like the uptime it is evaluated against.
+var activeFaults atomic.Pointer[FaultSchedule]
+
+// LoadFaultSchedule reads the schedule at path.
+func LoadFaultSchedule(path string) (*FaultSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := parseFaultSchedule(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fs, nil
+}
+
+func parseFaultSchedule(data []byte) (*FaultSchedule, error) {
+	var faults []Fault
+	if err := yaml.Unmarshal(data, &faults); err != nil {
+		return nil, err
+	}
+	if len(faults) == 0 {
+		return nil, errors.New("fault schedule is empty")
+	}
+	for i, f := range faults {
+		if f.Offset < 0 || f.Duration <= 0 {
+			return nil, fmt.Errorf("fault %d: offset must not be negative and duration must be positive", i)
+		}
+		switch f.Type {
+		case faultError:
+			// Checked like the ERROR_RATE and ERROR_MODE they replace.
+			if errs := config.Validate(config.Config{ErrorRate: f.Params.rate(), ErrorMode: f.Params.Mode}); len(errs) > 0 {
+				return nil, fmt.Errorf("fault %d: %w", i, errors.Join(errs...))
+			}
+		case faultLatency:
+			if f.Params.DelayMs <= 0 {
+				return nil, fmt.Errorf("fault %d: latency needs a positive params.delay_ms", i)
+			}
+		case faultStall:
+		default:
+			return nil, fmt.Errorf("fault %d: unknown fault_type %q (want %s, %s or %s)", i, f.Type, faultError, faultLatency, faultStall)
+		}
+	}
+	return &FaultSchedule{faults: faults}, nil
+}
+
+// rate returns the error rate of an error fault: params.rate, or 1 when unset.
+func (p FaultParams) rate() float64 {
+	if p.Rate == nil {
+		return 1
+	}
+	return *p.Rate
+}
+
+// SetFaultSchedule installs fs, with its offsets counted from now (nil removes the schedule). main
+// calls it once the listeners are up.
+func SetFaultSchedule(fs *FaultSchedule) {
+	if fs != nil {
+		fs.start = time.Now()
+		logger.Log.Infow("[faults] schedule started", "faults", len(fs.faults))
+	}
+	activeFaults.Store(fs)
+}
+
+// active returns the faults of fs active at uptime.
+func (fs *FaultSchedule) active(uptime time.Duration) []Fault {
+	var on []Fault
+	for _, f := range fs.faults {
+		if uptime >= f.Offset && uptime < f.Offset+f.Duration {
+			on = append(on, f)
+		}
+	}
+	return on
+}
+
+// applyFaults returns cfg with the faults active now applied, for a request starting now.
+func applyFaults(cfg config.Config) config.Config {
+	fs := activeFaults.Load()
+	if fs == nil {
+		return cfg
+	}
+	for _, f := range fs.active(time.Since(fs.start)) {
+		switch f.Type {
+		case faultError:
+			cfg.ErrorRate, cfg.ErrorMode = f.Params.rate(), f.Params.Mode
+		case faultLatency:
+			cfg.BaseDelayMs += f.Params.DelayMs
+		}
+	}
+	return cfg
+}
+
+// faultsActive reports whether any fault of the schedule is active now.
+func faultsActive() bool {
+	fs := activeFaults.Load()
+	return fs != nil && len(fs.active(time.Since(fs.start))) > 0
+}
+
+// waitStall blocks while a stall fault is active (or until ctx is done) and returns how long.
+func waitStall(ctx context.Context) time.Duration {
+	fs := activeFaults.Load()
+	if fs == nil {
+		return 0
+	}
+	t0 := time.Now()
+	for ctx.Err() == nil {
+		uptime := time.Since(fs.start)
+		var until time.Duration
+		for _, f := range fs.active(uptime) {
+			if f.Type == faultStall {
+				until = max(until, f.Offset+f.Duration)
+			}
+		}
+		if until == 0 {
+			break
+		}
+		sleepWithContext(ctx, until-uptime)
+	}
+	return time.Since(t0)
+}
+
+// FaultState is the state of one fault of the schedule, for GetStats and /stats.
+type FaultState struct {
+	Type       string `json:"fault_type"`
+	OffsetMs   int64  `json:"offset_ms"`
+	DurationMs int64  `json:"duration_ms"`
+	State      string `json:"state"` // pending|active|done
+}
+
+// FaultScheduleState is the schedule at a point in time: its uptime and the state of every fault.
+type FaultScheduleState struct {
+	UptimeMs int64        `json:"uptime_ms"`
+	Faults   []FaultState `json:"faults"`
+}
+
+// faultScheduleState returns the state of the installed schedule, or nil without one.
+func faultScheduleState() *FaultScheduleState {
+	fs := activeFaults.Load()
+	if fs == nil {
+		return nil
+	}
+	uptime := time.Since(fs.start)
+	st := &FaultScheduleState{UptimeMs: uptime.Milliseconds()}
+	for _, f := range fs.faults {
+		state := "pending"
+		switch {
+		case uptime >= f.Offset+f.Duration:
+			state = "done"
+		case uptime >= f.Offset:
+			state = "active"
+		}
+		st.Faults = append(st.Faults, FaultState{Type: f.Type, OffsetMs: f.Offset.Milliseconds(), DurationMs: f.Duration.Milliseconds(), State: state})
+	}
+	return st
+}
@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func startFaults(t *testing.T, doc string) {
+	t.Helper()
+	fs, err := parseFaultSchedule([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetFaultSchedule(fs)
+	t.Cleanup(func() { SetFaultSchedule(nil) })
+}
+
+func faultStates(t *testing.T, svc *MockLlmService) string {
+	t.Helper()
+	resp, err := svc.GetStats(context.Background(), &llmv1.GetStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, f := range resp.GetFaults() {
+		states = append(states, f.GetState())
+	}
+	return strings.Join(states, ",")
+}
+
+func TestFaultScheduleErrorWindow(t *testing.T) {
+	svc := NewMockLlmService(config.Config{})
+	mux := NewLiveHTTPMux(svc, nil, nil, nil)
+	call := func() (codes.Code, int) {
+		_, err := svc.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"hello"}],"max_tokens":8}`)))
+		return status.Code(err), rr.Code
+	}
+
+	startFaults(t, `
+- {offset: 150ms, duration: 200ms, fault_type: error, params: {mode: "429"}}
+- {offset: 10s, duration: 1s, fault_type: stall}
+`)
+	if grpcCode, httpCode := call(); grpcCode != codes.OK || httpCode != http.StatusOK {
+		t.Fatalf("before the window: got %v / %d", grpcCode, httpCode)
+	}
+	if got := faultStates(t, svc); got != "pending,pending" {
+		t.Fatalf("before the window: states %s", got)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if grpcCode, httpCode := call(); grpcCode != codes.ResourceExhausted || httpCode != http.StatusTooManyRequests {
+		t.Fatalf("in the window: got %v / %d", grpcCode, httpCode)
+	}
+	if got := faultStates(t, svc); got != "active,pending" {
+		t.Fatalf("in the window: states %s", got)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if grpcCode, httpCode := call(); grpcCode != codes.OK || httpCode != http.StatusOK {
+		t.Fatalf("after the window: got %v / %d", grpcCode, httpCode)
+	}
+	resp, _ := svc.GetStats(context.Background(), &llmv1.GetStatsRequest{})
+	if got := faultStates(t, svc); got != "done,pending" || resp.GetFaultUptimeMs() < 400 {
+		t.Fatalf("after the window: states %s at %dms", got, resp.GetFaultUptimeMs())
+	}
+}
+
+func TestFaultScheduleStall(t *testing.T) {
+	svc := NewMockLlmService(config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), StreamDelayMinMs: config.Int(10), StreamDelayMaxMs: config.Int(10)})
+	startFaults(t, "- {offset: 50ms, duration: 200ms, fault_type: stall}\n")
+
+	var sentAt []time.Time
+	fs := llmtest.NewServerStream(context.Background())
+	fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) { sentAt = append(sentAt, time.Now()) }
+	start := time.Now()
+	if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 40}, fs); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	var longest time.Duration
+	for i := 1; i < len(sentAt); i++ {
+		longest = max(longest, sentAt[i].Sub(sentAt[i-1]))
+	}
+	if longest < 150*time.Millisecond || time.Since(start) < 250*time.Millisecond {
+		t.Fatalf("expected the stall to hold the stream, longest gap %v", longest)
+	}
+	// The chunks after the stall keep their pace instead of catching up in a burst.
+	if last := sentAt[len(sentAt)-1].Sub(sentAt[len(sentAt)-2]); last < 5*time.Millisecond {
+		t.Fatalf("chunks burst out after the stall: last gap %v", last)
+	}
+}
+
+func TestParseFaultScheduleErrors(t *testing.T) {
+	for _, tc := range []struct{ doc, want string }{
+		{"[]", "empty"},
+		{"- {offset: 1s, fault_type: error}", "duration must be positive"},
+		{"- {offset: 1s, duration: 1s, fault_type: crash}", `unknown fault_type "crash"`},
+		{"- {offset: 1s, duration: 1s, fault_type: error, params: {rate: 2}}", "ERROR_RATE"},
+		{"- {offset: 1s, duration: 1s, fault_type: error, params: {mode: \"503\"}}", "ERROR_MODE"},
+		{"- {offset: 1s, duration: 1s, fault_type: latency}", "delay_ms"},
+	} {
+		if _, err := parseFaultSchedule([]byte(tc.doc)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tc.doc, tc.want, err)
+		}
+	}
+}
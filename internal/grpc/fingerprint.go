@@ -0,0 +1,43 @@
+package grpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+// Version is the simulator build, set at link time (see the Makefile):
+//
+//	-ldflags "-X github.com/yungtweek/llm-simulator/internal/grpc.Version=v1.2.3"
+//
+// When unset, the module version from the build info is used, or "dev".
+var Version string
+
+// buildVersion returns Version, or the version go build recorded for the main module.
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return "dev"
+}
+
+// systemFingerprint identifies the configuration behind a response, like OpenAI's
+// system_fingerprint: a hash of the build and the preset, so it changes when either changes the
+// simulated behavior.
+func systemFingerprint(cfg config.Config) string {
+	sum := sha256.Sum256([]byte(buildVersion() + "/" + cfg.Preset))
+	return "fp_" + hex.EncodeToString(sum[:5])
+}
+
+// responseID generates the id of a gRPC response or stream. Like grpcRequestID, it does not draw from
+// the shared random source (mock.Seed), so it leaves seeded runs unchanged.
+func responseID() string {
+	return "chatcmpl_mock_" + strconv.FormatUint(rand.Uint64(), 36)
+}
@@ -0,0 +1,236 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
+)
+
+// GeminiHandler serves POST /v1beta/models/{model}:generateContent and :streamGenerateContent.
+//
+// The stream variant returns Gemini's REST streaming shape: a single JSON array whose elements
+// (one GeminiResponse per chunk) are written and flushed as they are generated. With ?alt=sse the
+// same chunks are sent as SSE data events instead. Injected errors use Google's error JSON.
+func GeminiHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		model, method, ok := strings.Cut(r.PathValue("modelAction"), ":")
+		if !ok || model == "" || (method != "generateContent" && method != "streamGenerateContent") {
+			writeGeminiError(w, http.StatusNotFound, "unknown method: "+r.PathValue("modelAction"))
+			return
+		}
+
+		var req mock.GeminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGeminiError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if len(req.Contents) == 0 {
+			writeGeminiError(w, http.StatusBadRequest, "contents is not specified")
+			return
+		}
+		maxTokens := req.GenerationConfig.MaxOutputTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultInt(cfg.DefaultTokens, 128)
+		}
+
+		setRequestModel(r.Context(), model)
+		chatReq := geminiToChatRequest(model, req)
+		if err := checkMessages(cfg, chatReq); err != nil {
+			writeGeminiError(w, http.StatusBadRequest, status.Convert(err).Message())
+			return
+		}
+
+		// Error injection (before any headers are written).
+		if code := injectHTTPError(cfg); code != 0 {
+			logger.Log.Infow("[http][Gemini] injected error", "mode", cfg.ErrorMode, "status", code)
+			e := geminiError(code, "mock error")
+			e.Error.Injected = true
+			writeJSON(w, code, e)
+			return
+		}
+
+		prompt := buildPromptForTokens(chatReq)
+		content := buildOutput(cfg, prompt, maxTokens)
+		pt := promptTokens(cfg, chatReq)
+		ct := mock.ApproxTokens(content)
+		usage := &mock.GeminiUsageMetadata{
+			PromptTokenCount:     pt,
+			CandidatesTokenCount: ct,
+			TotalTokenCount:      pt + ct,
+		}
+
+		if method == "generateContent" {
+			requestService(r.Context(), cfg).simulateUnary(r.Context(), ct)
+			if r.Context().Err() != nil {
+				return
+			}
+			resp := geminiChunk(model, content, "STOP")
+			resp.UsageMetadata = usage
+			writeJSON(w, http.StatusOK, resp)
+			reportUsage(r.Context(), usage.PromptTokenCount, usage.CandidatesTokenCount)
+			return
+		}
+
+		serveGeminiStream(w, r, model, content, usage, r.URL.Query().Get("alt") == "sse", cfg)
+	}
+}
+
+func serveGeminiStream(w http.ResponseWriter, r *http.Request, model, content string, usage *mock.GeminiUsageMetadata, sse bool, cfg config.Config) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGeminiError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	chunkSize := sseChunkSize(cfg, 0)
+	bw := bufio.NewWriter(w)
+	wrote := 0
+
+	send := func(v mock.GeminiResponse) bool {
+		var err error
+		switch {
+		case sse:
+			err = writeSSE(bw, v)
+		default:
+			// JSON array framing: "[" before the first element, "," between elements.
+			sep := "["
+			if wrote > 0 {
+				sep = ",\r\n"
+			}
+			var b []byte
+			if b, err = json.Marshal(v); err == nil {
+				_, err = fmt.Fprintf(bw, "%s%s\n", sep, b)
+			}
+		}
+		if err != nil {
+			return false
+		}
+		if err := bw.Flush(); err != nil {
+			return false
+		}
+		flusher.Flush()
+		wrote++
+		return true
+	}
+
+	pace := newStreamPacer(cfg)
+	for i := 0; i < len(content); i += chunkSize {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		part := content[i:end]
+
+		// The final content chunk carries finishReason and usage, as Gemini does.
+		chunk := geminiChunk(model, part, "")
+		if end == len(content) {
+			chunk.Candidates[0].FinishReason = "STOP"
+			chunk.UsageMetadata = usage
+		}
+		if !send(chunk) {
+			return
+		}
+		if end < len(content) {
+			pace.wait(r.Context(), part)
+		}
+	}
+
+	if !sse {
+		if _, err := fmt.Fprint(bw, "]"); err != nil {
+			return
+		}
+		if err := bw.Flush(); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+	reportUsage(r.Context(), usage.PromptTokenCount, usage.CandidatesTokenCount)
+}
+
+func geminiChunk(model, text, finishReason string) mock.GeminiResponse {
+	return mock.GeminiResponse{
+		Candidates: []mock.GeminiCandidate{{
+			Content:      mock.GeminiContent{Role: "model", Parts: []mock.GeminiPart{{Text: text}}},
+			FinishReason: finishReason,
+			Index:        0,
+		}},
+		ModelVersion: model,
+	}
+}
+
+// geminiToChatRequest maps Gemini contents onto the gRPC request shape so prompt construction
+// matches buildPromptForTokens. The "model" role is renamed to "assistant" so the same conversation
+// yields the same prompt (and token counts) regardless of which API shape it arrived in.
+func geminiToChatRequest(model string, req mock.GeminiRequest) *llmv1.ChatCompletionRequest {
+	out := &llmv1.ChatCompletionRequest{
+		Model:     model,
+		MaxTokens: int32(req.GenerationConfig.MaxOutputTokens),
+	}
+	if req.SystemInstruction != nil {
+		out.SystemPrompt = mock.GeminiText(*req.SystemInstruction)
+	}
+	contents := req.Contents
+	if n := len(contents); n > 0 && (contents[n-1].Role == "user" || contents[n-1].Role == "") {
+		out.UserPrompt = mock.GeminiText(contents[n-1])
+		contents = contents[:n-1]
+	}
+	for _, c := range contents {
+		role := c.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		out.Context = append(out.Context, &llmv1.ChatMessage{Role: role, Content: mock.GeminiText(c)})
+	}
+	return out
+}
+
+// writeGeminiError writes a Google API error envelope with the canonical status name for code.
+func writeGeminiError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, geminiError(code, message))
+}
+
+func geminiError(code int, message string) mock.GeminiError {
+	var e mock.GeminiError
+	e.Error.Code = code
+	e.Error.Message = message
+	e.Error.Status = geminiStatus(code)
+	return e
+}
+
+func geminiStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+const geminiBody = `{"systemInstruction":{"parts":[{"text":"be brief"}]},` +
+	`"contents":[{"role":"user","parts":[{"text":"hi"}]},{"role":"model","parts":[{"text":"hello"}]},` +
+	`{"role":"user","parts":[{"text":"tell me a joke"}]}],"generationConfig":{"maxOutputTokens":12}}`
+
+func serveGemini(cfg config.Config, path string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1beta/models/{modelAction}", GeminiHandler(cfg))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(geminiBody)))
+	return rr
+}
+
+func TestGeminiPromptMatchesChatShape(t *testing.T) {
+	var req mock.GeminiRequest
+	if err := json.Unmarshal([]byte(geminiBody), &req); err != nil {
+		t.Fatalf("bad fixture: %v", err)
+	}
+	got := buildPromptForTokens(geminiToChatRequest("gemini-mock", req))
+	want := buildPromptForTokens(&llmv1.ChatCompletionRequest{
+		SystemPrompt: "be brief",
+		UserPrompt:   "tell me a joke",
+		Context: []*llmv1.ChatMessage{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+	})
+	if got != want {
+		t.Fatalf("prompt mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestGeminiStreamGenerateContent(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(9), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(256)}
+
+	rr := serveGemini(cfg, "/v1beta/models/gemini-mock:streamGenerateContent")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	var chunks []mock.GeminiResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &chunks); err != nil {
+		t.Fatalf("stream body is not a JSON array: %v\n%s", err, rr.Body.String())
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	var req mock.GeminiRequest
+	_ = json.Unmarshal([]byte(geminiBody), &req)
+	prompt := buildPromptForTokens(geminiToChatRequest("gemini-mock", req))
+	expected := buildOutput(cfg, prompt, 12)
+
+	var assembled strings.Builder
+	for i, ch := range chunks {
+		if len(ch.Candidates) != 1 || ch.Candidates[0].Content.Role != "model" {
+			t.Fatalf("chunk %d has unexpected candidates: %+v", i, ch)
+		}
+		last := i == len(chunks)-1
+		if got := ch.Candidates[0].FinishReason; (got == "STOP") != last {
+			t.Fatalf("chunk %d has unexpected finishReason %q", i, got)
+		}
+		if (ch.UsageMetadata != nil) != last {
+			t.Fatalf("chunk %d has unexpected usageMetadata presence", i)
+		}
+		assembled.WriteString(mock.GeminiText(ch.Candidates[0].Content))
+	}
+	if assembled.String() != expected {
+		t.Fatalf("reassembled content mismatch: got len=%d expected len=%d", assembled.Len(), len(expected))
+	}
+
+	u := chunks[len(chunks)-1].UsageMetadata
+	pt, ct := mock.ApproxTokens(prompt), mock.ApproxTokens(expected)
+	if u.PromptTokenCount != pt || u.CandidatesTokenCount != ct || u.TotalTokenCount != pt+ct {
+		t.Fatalf("usage mismatch: %+v", u)
+	}
+}
+
+func TestGeminiStreamGenerateContentSSE(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	rr := serveGemini(cfg, "/v1beta/models/gemini-mock:streamGenerateContent?alt=sse")
+	if !strings.Contains(rr.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("content type not set for SSE: %q", rr.Header().Get("Content-Type"))
+	}
+	n := strings.Count(rr.Body.String(), "data: ")
+	if n < 2 {
+		t.Fatalf("expected several SSE data events, got %d", n)
+	}
+}
+
+func TestGeminiGenerateContent(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	rr := serveGemini(cfg, "/v1beta/models/gemini-mock:generateContent")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	var resp mock.GeminiResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if len(resp.Candidates) != 1 || resp.Candidates[0].FinishReason != "STOP" || resp.UsageMetadata == nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.ModelVersion != "gemini-mock" {
+		t.Fatalf("unexpected model version: %q", resp.ModelVersion)
+	}
+}
+
+func TestGeminiErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		path   string
+		code   int
+		status string
+	}{
+		{name: "unknown method", path: "/v1beta/models/gemini-mock:countTokens", code: http.StatusNotFound, status: "NOT_FOUND"},
+		{name: "injected 429", cfg: config.Config{ErrorRate: 1, ErrorMode: "429"}, path: "/v1beta/models/gemini-mock:generateContent", code: http.StatusTooManyRequests, status: "RESOURCE_EXHAUSTED"},
+		{name: "injected 500", cfg: config.Config{ErrorRate: 1, ErrorMode: "500"}, path: "/v1beta/models/gemini-mock:streamGenerateContent", code: http.StatusInternalServerError, status: "INTERNAL"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serveGemini(tc.cfg, tc.path)
+			if rr.Code != tc.code {
+				t.Fatalf("expected %d, got %d", tc.code, rr.Code)
+			}
+			var e mock.GeminiError
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+				t.Fatalf("bad error body: %v", err)
+			}
+			if e.Error.Code != tc.code || e.Error.Status != tc.status {
+				t.Fatalf("unexpected error body: %+v", e)
+			}
+		})
+	}
+}
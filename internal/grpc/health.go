@@ -0,0 +1,241 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Readiness states reported by /readyz and the gRPC health service.
+const (
+	StateStarting = "starting" // not every listener is accepting yet
+	StateReady    = "ready"
+	StateDraining = "draining" // shutdown has started
+	StateDown     = "down"     // an admin kill switch (FailAll/PauseAll) is active
+)
+
+// Readiness is the serving state shared by the HTTP /healthz and /readyz endpoints and the
+// grpc.health.v1 service, so the two can never disagree. It becomes ready once every listener it
+// was created for is accepting, and stops being ready for good when Drain is called; while an admin
+// kill switch is active (SetDown) it is temporarily not ready. It also counts
+// active streams (streaming RPCs and in-flight HTTP completion requests).
+type Readiness struct {
+	start      time.Time
+	grpcActive atomic.Int64 // streaming RPCs
+	httpActive atomic.Int64 // HTTP completion requests
+
+	mu       sync.Mutex
+	pending  int           // listeners not yet accepting
+	draining bool          // set once shutdown has started
+	down     bool          // set while a kill switch is active
+	changed  chan struct{} // closed and replaced on every state change
+}
+
+// NewReadiness returns a Readiness that becomes ready after ListenerReady has been called once for
+// each of the given number of listeners (0 is ready right away).
+func NewReadiness(listeners int) *Readiness {
+	return &Readiness{start: time.Now(), pending: listeners, changed: make(chan struct{})}
+}
+
+// ListenerReady records that one more listener is accepting connections.
+func (r *Readiness) ListenerReady() {
+	r.update(func() {
+		if r.pending > 0 {
+			r.pending--
+		}
+	})
+}
+
+// Drain marks the server as shutting down; it is never ready again afterwards.
+func (r *Readiness) Drain() {
+	r.update(func() { r.draining = true })
+}
+
+// SetDown marks the simulated backend as down (or back up) while an admin kill switch is active.
+func (r *Readiness) SetDown(down bool) {
+	r.update(func() { r.down = down })
+}
+
+func (r *Readiness) update(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := r.stateLocked()
+	fn()
+	if r.stateLocked() != before {
+		close(r.changed)
+		r.changed = make(chan struct{})
+	}
+}
+
+// State returns StateStarting, StateReady, StateDown or StateDraining.
+func (r *Readiness) State() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stateLocked()
+}
+
+func (r *Readiness) stateLocked() string {
+	switch {
+	case r.draining:
+		return StateDraining
+	case r.pending > 0:
+		return StateStarting
+	case r.down:
+		return StateDown
+	default:
+		return StateReady
+	}
+}
+
+// Ready reports whether the server should receive traffic.
+func (r *Readiness) Ready() bool { return r.State() == StateReady }
+
+// ActiveStreams returns the number of streams currently being served.
+func (r *Readiness) ActiveStreams() int64 { return r.grpcActive.Load() + r.httpActive.Load() }
+
+// streamStarted counts a new active stream on n; the returned func must be called when it ends.
+func streamStarted(n *atomic.Int64) func() {
+	n.Add(1)
+	return func() { n.Add(-1) }
+}
+
+// watch returns the current state and a channel closed on the next state change.
+func (r *Readiness) watch() (string, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stateLocked(), r.changed
+}
+
+// HealthStatus is the JSON body of /healthz and /readyz.
+type HealthStatus struct {
+	Status        string `json:"status"`
+	UptimeMs      int64  `json:"uptime_ms"`
+	ActiveStreams int64  `json:"active_streams"`
+	ScenarioPhase string `json:"scenario_phase,omitempty"` // the running SCENARIO_FILE phase
+}
+
+func (r *Readiness) status(state string) HealthStatus {
+	return HealthStatus{
+		Status:        state,
+		UptimeMs:      time.Since(r.start).Milliseconds(),
+		ActiveStreams: r.ActiveStreams(),
+		ScenarioPhase: stats.Default.ScenarioPhase(),
+	}
+}
+
+// ---- HTTP ----
+
+// HealthzHandler serves GET /healthz: always 200 while the process is up.
+func HealthzHandler(r *Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, r.status("ok"))
+	}
+}
+
+// ReadyzHandler serves GET /readyz: 200 once every listener is accepting, 503 while starting, down
+// or draining.
+func ReadyzHandler(r *Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		state := r.State()
+		code := http.StatusOK
+		if state != StateReady {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, r.status(state))
+	}
+}
+
+// track counts h's in-flight requests as active streams.
+func (r *Readiness) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer streamStarted(&r.httpActive)()
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ---- gRPC ----
+
+// readinessOptions returns interceptors rejecting new RPCs with Unavailable once draining and
+// counting streaming RPCs as active streams.
+func readinessOptions(r *Readiness) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if !isHealthMethod(info.FullMethod) && r.State() == StateDraining {
+				return nil, errDraining
+			}
+			return handler(ctx, req)
+		}),
+		WithStreamInterceptors(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if isHealthMethod(info.FullMethod) {
+				return handler(srv, ss)
+			}
+			if r.State() == StateDraining {
+				return errDraining
+			}
+			defer streamStarted(&r.grpcActive)()
+			return handler(srv, ss)
+		}),
+	}
+}
+
+var errDraining = status.Error(codes.Unavailable, "server is shutting down")
+
+// isHealthMethod reports whether fullMethod belongs to the grpc.health.v1 service, which is exempt
+// from auth, limits and stream accounting so probes keep working.
+func isHealthMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// healthServer implements grpc.health.v1 on top of Readiness. The overall ("") and LlmService
+// statuses are both SERVING exactly when /readyz returns 200.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	ready *Readiness
+}
+
+func (h *healthServer) servingStatus(service, state string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if service != "" && service != llmv1.LlmService_ServiceDesc.ServiceName {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if state != StateReady {
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_SERVING, true
+}
+
+func (h *healthServer) Check(_ context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := h.servingStatus(req.GetService(), h.ready.State())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		state, changed := h.ready.watch()
+		if st, _ := h.servingStatus(req.GetService(), state); st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+func getHealth(t *testing.T, h http.Handler, path string) (int, HealthStatus) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	var st HealthStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &st); err != nil {
+		t.Fatalf("bad %s body: %v (%s)", path, err, rr.Body.String())
+	}
+	return rr.Code, st
+}
+
+func TestReadyzTransitions(t *testing.T) {
+	ready := NewReadiness(2)
+	// Auth must not apply to probes.
+	mux := NewHTTPMux(config.Config{APIKeys: []string{"k1"}}, nil, nil, ready)
+
+	if code, st := getHealth(t, mux, "/healthz"); code != http.StatusOK || st.Status != "ok" {
+		t.Fatalf("healthz while starting: %d %+v", code, st)
+	}
+	steps := []struct {
+		name  string
+		apply func()
+		code  int
+		state string
+	}{
+		{name: "no listener", apply: func() {}, code: http.StatusServiceUnavailable, state: StateStarting},
+		{name: "one listener", apply: ready.ListenerReady, code: http.StatusServiceUnavailable, state: StateStarting},
+		{name: "both listeners", apply: ready.ListenerReady, code: http.StatusOK, state: StateReady},
+		{name: "draining", apply: ready.Drain, code: http.StatusServiceUnavailable, state: StateDraining},
+		{name: "listener after drain", apply: ready.ListenerReady, code: http.StatusServiceUnavailable, state: StateDraining},
+	}
+	for _, s := range steps {
+		s.apply()
+		code, st := getHealth(t, mux, "/readyz")
+		if code != s.code || st.Status != s.state {
+			t.Fatalf("%s: got %d %+v, want %d %s", s.name, code, st, s.code, s.state)
+		}
+	}
+	if code, _ := getHealth(t, mux, "/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz must stay 200 while draining, got %d", code)
+	}
+}
+
+// startReadyServer serves cfg's LlmService and the health service backed by ready on a loopback
+// listener and returns the server and an insecure client connection. Cleanup stops the server and
+// waits for RunWithListener to return.
+func startReadyServer(t *testing.T, cfg config.Config, ready *Readiness) (*Server, *grpc.ClientConn) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	opts, err := ServerOptions(cfg, nil, ready)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), NewMockLlmService(cfg), opts...)
+	srv.SetReadiness(ready)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = srv.RunWithListener(lis)
+	}()
+	// The server must be gone, logging included, before the next test starts.
+	t.Cleanup(func() {
+		srv.Stop()
+		<-served
+	})
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+func TestGRPCHealthFollowsReadiness(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), APIKeys: []string{"k1"}}
+	// The gRPC listener reports itself; the second listener is reported by the test.
+	ready := NewReadiness(2)
+
+	_, conn := startReadyServer(t, cfg, ready)
+	client := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("check %q failed: %v", service, err)
+		}
+		return resp.GetStatus()
+	}
+
+	if got := check(""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected NOT_SERVING before listeners are up, got %v", got)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "nope"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for unknown service, got %v", err)
+	}
+
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	expect := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		resp, err := watch.Recv()
+		if err != nil || resp.GetStatus() != want {
+			t.Fatalf("watch: got %v (%v), want %v", resp.GetStatus(), err, want)
+		}
+	}
+	expect(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ready.ListenerReady()
+	expect(healthpb.HealthCheckResponse_SERVING)
+	if got := check("llm.v1.LlmService"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected LlmService SERVING, got %v", got)
+	}
+
+	ready.Drain()
+	expect(healthpb.HealthCheckResponse_NOT_SERVING)
+	if got := check(""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected NOT_SERVING while draining, got %v", got)
+	}
+}
+
+func TestReadinessCountsActiveStreams(t *testing.T) {
+	ready := NewReadiness(0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := ready.track(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+		close(done)
+	}()
+	<-started
+	if _, st := getHealth(t, NewHTTPMux(config.Config{}, nil, nil, ready), "/readyz"); st.ActiveStreams != 1 {
+		t.Fatalf("expected 1 active stream, got %+v", st)
+	}
+	close(release)
+	<-done
+	if n := ready.ActiveStreams(); n != 0 {
+		t.Fatalf("expected 0 active streams after completion, got %d", n)
+	}
+}
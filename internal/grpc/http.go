@@ -0,0 +1,243 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// HTTPServer wraps an http.Server exposing the HTTP-compatible endpoints
+// (OpenAI-style SSE and provider-shaped shims) next to the gRPC server.
+type HTTPServer struct {
+	addr       string
+	httpServer *http.Server
+	ready      *Readiness
+	grpc       *Server     // set in SINGLE_PORT mode (see NewSinglePortServer)
+	sockMode   os.FileMode // unix socket permissions (0 keeps the umask default)
+
+	mu  sync.Mutex
+	lis net.Listener // set by Listen
+}
+
+// NewHTTPServer creates a new HTTP server for the given handler at the given address.
+// Example addr: ":8788" or "unix:///tmp/llm-sim-http.sock". Besides HTTP/1.1 it accepts
+// unencrypted HTTP/2 (h2c with prior knowledge).
+func NewHTTPServer(addr string, handler http.Handler) *HTTPServer {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &HTTPServer{
+		addr: addr,
+		httpServer: &http.Server{
+			Addr:      addr,
+			Handler:   handler,
+			Protocols: &protocols,
+		},
+	}
+}
+
+// NewHTTPMux registers every HTTP endpoint served by the simulator. A non-nil grpcWeb handler
+// (see Server.GRPCWebHandler) is mounted under the LlmService path; it is guarded by the gRPC
+// interceptors. /healthz and /readyz report ready (nil is always ready), /metrics serves the
+// Prometheus metrics and GET /stats the GetStats snapshot; none of them need an API key. POST
+// /stats/reset zeroes the stats and, like the simulated routes, enforces API_KEYS and the limits;
+// it isn't instrumented. Every other route is instrumented, recovers from handler panics, enforces API_KEYS and the per-key limits in
+// limits (nil disables limiting) and counts as an active stream on ready. When
+// CORS_ALLOWED_ORIGINS is set, the whole mux is wrapped with CORS handling.
+func NewHTTPMux(cfg config.Config, grpcWeb http.Handler, limits *Limits, ready *Readiness) http.Handler {
+	return NewLiveHTTPMux(NewMockLlmService(cfg), grpcWeb, limits, ready)
+}
+
+// NewLiveHTTPMux is NewHTTPMux with the simulated endpoints following the runtime state of svc:
+// they read svc.Config at the start of each request, so runtime config updates reach HTTP clients
+// too, and honor its kill switches. Requests can override parts of that config for themselves with
+// x-mock-* headers (see mockOverrideKeys). Listener, auth, Azure and CORS settings are taken from the
+// config at construction.
+func NewLiveHTTPMux(svc *MockLlmService, grpcWeb http.Handler, limits *Limits, ready *Readiness) http.Handler {
+	live := svc.Config()
+	cfg := live.Load()
+	if ready == nil {
+		ready = NewReadiness(0)
+	}
+	mux := http.NewServeMux()
+	if grpcWeb != nil {
+		mux.Handle("POST /"+llmv1.LlmService_ServiceDesc.ServiceName+"/", grpcWeb)
+	}
+	mux.Handle("GET /healthz", HealthzHandler(ready))
+	mux.Handle("GET /readyz", ReadyzHandler(ready))
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /stats", StatsHandler())
+
+	guard := newHTTPGuard(cfg, limits)
+	mux.Handle("POST /stats/reset", guard.protect(StatsResetHandler(), openAIHTTPError))
+	route := func(pattern string, build func(config.Config) http.HandlerFunc, writeErr httpErrorWriter) {
+		mux.Handle(pattern, instrumentHTTP(recoverHTTP(guard.protect(ready.track(svc.kill.protect(svc.tenants.protect(liveHandler(live, build), writeErr), writeErr)), writeErr), writeErr)))
+	}
+	route("GET /v1/chat/completions", ChatCompletionSSEHandler, openAIHTTPError)
+	route("POST /v1/chat/completions", ChatCompletionSSEHandler, openAIHTTPError)
+	route("GET /v1/chat/ws", WSChatHandler, openAIHTTPError)
+	route("POST /v1/responses", ResponsesHandler, openAIHTTPError)
+	route("POST /v1/moderations", ModerationsHandler, openAIHTTPError)
+	route("POST /v1/messages", AnthropicMessagesHandler, anthropicHTTPError)
+	route("POST /v1beta/models/{modelAction}", GeminiHandler, geminiHTTPError)
+	route("POST /api/chat", OllamaChatHandler, ollamaHTTPError)
+	route("POST /api/generate", OllamaGenerateHandler, ollamaHTTPError)
+	if cfg.AzureCompat {
+		// The Azure handler checks api-key itself so its error ordering matches Azure.
+		mux.Handle("POST /openai/deployments/{deployment}/chat/completions",
+			instrumentHTTP(recoverHTTP(guard.limit(ready.track(svc.kill.protect(svc.tenants.protect(liveHandler(live, AzureChatCompletionsHandler), azureHTTPError), azureHTTPError)), azureHTTPError), azureHTTPError)))
+	}
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		return withCORS(cfg.CORSAllowedOrigins, mux)
+	}
+	return mux
+}
+
+// liveHandler builds the handler for the config current when each request arrives, with the active
+// FAULT_SCHEDULE faults and the request's x-mock-* headers applied, and echoes its client request ID
+// (see withHTTPClientRequestID). The handler constructors only capture cfg, so this is cheap.
+func liveHandler(live *config.Holder, build func(config.Config) http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := applyFaults(requestTenant(r.Context()).apply(live.Load()))
+		var o *mock.Overrides
+		var invalid []string
+		if overridesAllowed(cfg) {
+			o, invalid = headerOverrides(r)
+			cfg = applyOverrides(cfg, o)
+		}
+		r = withHTTPClientRequestID(w, withDebug(withPriority(r), cfg, live), cfg)
+		recordOverrides(requestDebug(r.Context()), o, invalid)
+		build(cfg).ServeHTTP(w, withHeaderOverrides(r, o))
+	})
+}
+
+// SetReadiness makes Run report the listener to ready once accepting. It must be called before Run.
+func (s *HTTPServer) SetReadiness(ready *Readiness) {
+	s.ready = ready
+}
+
+// SetUnixSocketMode sets the permissions of the socket file when the address is a unix socket
+// ("unix:///path/to.sock"). It must be called before Listen/Run.
+func (s *HTTPServer) SetUnixSocketMode(mode os.FileMode) {
+	s.sockMode = mode
+}
+
+// Listen binds the configured address without serving yet, so the bound address (e.g. the free
+// port picked for HTTP_PORT=0) is available from Addr before Run. Run calls it when needed.
+func (s *HTTPServer) Listen() error {
+	lis, err := listen(s.addr, s.sockMode)
+	if err != nil {
+		logger.Log.Errorw("[http] failed to listen", "addr", s.addr, "err", err)
+		return err
+	}
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+	return nil
+}
+
+// Addr returns the address the server is bound to, or nil before Listen/Run.
+func (s *HTTPServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Run serves HTTP requests on the configured address, listening first unless Listen was already
+// called. This call blocks until the server stops or returns an error.
+func (s *HTTPServer) Run() error {
+	if s.Addr() == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	lis := s.lis
+	s.mu.Unlock()
+
+	logger.Log.Infow("[http] starting server", "addr", lis.Addr().String())
+	if s.ready != nil {
+		s.ready.ListenerReady()
+	}
+	if err := s.httpServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Errorw("[http] server stopped with error", "err", err)
+		return err
+	}
+
+	logger.Log.Info("[http] server stopped gracefully")
+	return nil
+}
+
+// Shutdown gracefully stops the underlying HTTP server, waiting for active requests until ctx is
+// done. After that the remaining connections are closed, logging how many streams were cut off.
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	logger.Log.Infow("[http] graceful stop", "addr", s.addr)
+	if s.ready != nil {
+		s.ready.Drain()
+	}
+	if s.grpc != nil {
+		// Release the gRPC server once every stream served through it is done or cut off.
+		defer s.grpc.stop().Stop()
+	}
+	err := s.httpServer.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+	var forced int64
+	if s.ready != nil {
+		forced = s.ready.httpActive.Load()
+		if s.grpc != nil {
+			forced += s.ready.grpcActive.Load()
+		}
+	}
+	logger.Log.Warnw("[http] drain deadline exceeded, forcing close", "addr", s.addr, "forcedStreams", forced)
+	_ = s.httpServer.Close()
+	return err
+}
+
+// writeJSON writes v as a JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeOpenAIError writes an OpenAI-style error body with type/code derived from the status.
+func writeOpenAIError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, openAIError(code, message))
+}
+
+func openAIError(code int, message string) mock.ErrorResponse {
+	var e mock.ErrorResponse
+	e.Error.Message = message
+	e.Error.Type, e.Error.Code = openAIErrorType(code)
+	return e
+}
+
+func openAIErrorType(code int) (string, *string) {
+	str := func(s string) *string { return &s }
+	switch code {
+	case http.StatusBadRequest:
+		return "invalid_request_error", nil
+	case http.StatusUnauthorized:
+		return "invalid_request_error", str("invalid_api_key")
+	case http.StatusTooManyRequests:
+		return "requests", str("rate_limit_exceeded")
+	default:
+		return "server_error", nil
+	}
+}
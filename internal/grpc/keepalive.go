@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// streamKeepalive sends the keep-alive chunks of a gRPC stream (GRPC_KEEPALIVE_CHUNK_MS): while the
+// stream sleeps, in its pre-delay, a pacing gap or a stall fault, it sends one each time the stream
+// has gone the interval without sending anything, the way real backends keep idle streams open
+// through proxies during long pauses. It rides on the stream's context, where sleepWithContext
+// finds it. Keep-alive chunks carry no text and are counted apart from the stream's chunks sent.
+type streamKeepalive struct {
+	interval time.Duration
+	start    time.Time     // idle time counts from here until the first chunk
+	sent     *sendCounters // the stream's, for when it last sent a chunk and to count keep-alives
+	ping     func() error  // sends one keep-alive chunk
+	off      bool          // a send failed: sleep without keep-alives
+}
+
+type keepaliveCtxKey struct{}
+
+// withKeepalive returns ctx making sleepWithContext send k's keep-alive chunks.
+func withKeepalive(ctx context.Context, k *streamKeepalive) context.Context {
+	return context.WithValue(ctx, keepaliveCtxKey{}, k)
+}
+
+// streamKeepaliveOf returns the keep-alive of a stream's context, nil without one.
+func streamKeepaliveOf(ctx context.Context) *streamKeepalive {
+	k, _ := ctx.Value(keepaliveCtxKey{}).(*streamKeepalive)
+	return k
+}
+
+// sleep sleeps for d or until ctx is done, sending a keep-alive chunk whenever the stream reaches
+// the interval without sending one.
+func (k *streamKeepalive) sleep(ctx context.Context, d time.Duration) {
+	end := time.Now().Add(d)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	for {
+		wake, ping := end, false
+		if next := k.idleSince().Add(k.interval); !k.off && next.Before(end) {
+			wake, ping = next, true
+		}
+		t.Reset(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if !ping {
+			return
+		}
+		if err := k.ping(); err != nil {
+			k.off = true
+			continue
+		}
+		k.sent.keepalive()
+	}
+}
+
+// idleSince returns when the stream last sent a chunk, or when k started if it hasn't since.
+func (k *streamKeepalive) idleSince() time.Time {
+	if k.sent.last.After(k.start) {
+		return k.sent.last
+	}
+	return k.start
+}
+
+// keepaliveChunk returns a keep-alive chunk: of type "ping", or with GRPC_KEEPALIVE_CHUNK_TYPE=delta
+// an empty delta of type delta.
+func keepaliveChunk(cfg config.Config, delta events.Type) *llmv1.ChatCompletionChunkResponse {
+	typ := events.Ping
+	if cfg.GRPCKeepaliveChunkType == "delta" {
+		typ = delta
+	}
+	return &llmv1.ChatCompletionChunkResponse{Type: string(typ), EmittedAtUnixMs: emittedAt(cfg)}
+}
@@ -0,0 +1,122 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/codes"
+)
+
+func keepaliveConfig(chunkType string) config.Config {
+	return config.Config{
+		TTFTMinMs:              config.Int(120),
+		TTFTMaxMs:              config.Int(120),
+		ChunkSize:              config.Int(8),
+		StrictTokenMode:        config.Bool(true),
+		MaxOutputChars:         config.Int(64),
+		GRPCKeepaliveChunkMs:   30,
+		GRPCKeepaliveChunkType: chunkType,
+	}
+}
+
+// keepaliveStream runs a stream on cfg and returns its chunks, with the keep-alive chunks sent
+// before the first delta counted apart.
+func keepaliveStream(t *testing.T, cfg config.Config, keepalive func(*llmv1.ChatCompletionChunkResponse) bool) ([]*llmv1.ChatCompletionChunkResponse, int) {
+	t.Helper()
+	fs := llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "keep me alive", MaxTokens: 16}, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	chunks := fs.Chunks()
+	before := 0
+	for _, c := range chunks {
+		if !keepalive(c) {
+			break
+		}
+		before++
+	}
+	if len(fs.Header().Get(requestIDKey)) != 1 {
+		t.Fatalf("response header missing: %v", fs.Header())
+	}
+	return chunks, before
+}
+
+func TestKeepaliveChunks(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	isPing := func(c *llmv1.ChatCompletionChunkResponse) bool { return c.GetType() == string(events.Ping) }
+	chunks, before := keepaliveStream(t, keepaliveConfig(""), isPing)
+	if before < 2 {
+		t.Fatalf("%d keep-alive chunks before the first delta in a 120ms TTFT, want at least 2: %v", before, chunks)
+	}
+	pings := 0
+	for _, c := range chunks {
+		if isPing(c) {
+			pings++
+			if c.GetText() != "" || c.GetId() != chunks[len(chunks)-1].GetId() {
+				t.Fatalf("keep-alive chunk %v", c)
+			}
+		}
+	}
+
+	// The done chunk counts the deltas only; the termination line counts the keep-alives apart.
+	done := llmtest.RequireDone(t, chunks, events.FinishStop)
+	if want := int32(mock.ApproxTokens(llmtest.Text(chunks))); done.GetCompletionTokens() != want {
+		t.Fatalf("done chunk counts %d completion tokens, the deltas hold %d", done.GetCompletionTokens(), want)
+	}
+	entries := logs.FilterMessage("[grpc][ChatCompletionStream] done").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one done line, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["chunksSent"] != int64(len(chunks)-pings) || fields["keepalivesSent"] != int64(pings) {
+		t.Fatalf("done line counts %v chunks and %v keep-alives, the stream sent %d and %d", fields["chunksSent"], fields["keepalivesSent"], len(chunks)-pings, pings)
+	}
+}
+
+// TestKeepaliveFailedChunkCounts checks the failed chunk's ChunksSent leaves the keep-alives out.
+func TestKeepaliveFailedChunkCounts(t *testing.T) {
+	cfg := keepaliveConfig("")
+	cfg.MemBytesPerToken, cfg.MemLimitBytes = 1000, 12_000 // runs out after a few deltas
+	fs := llmtest.NewServerStream(context.Background())
+	_ = NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "keep me alive", MaxTokens: 16}, fs)
+	chunks := fs.Chunks()
+	failed := llmtest.RequireFailed(t, chunks, codes.ResourceExhausted)
+	pings := 0
+	for _, c := range chunks {
+		if c.GetType() == string(events.Ping) {
+			pings++
+		}
+	}
+	if pings == 0 || int(failed.GetChunksSent()) != len(chunks)-1-pings {
+		t.Fatalf("failed chunk counts %d chunks; the stream sent %d and %d keep-alives", failed.GetChunksSent(), len(chunks)-1-pings, pings)
+	}
+}
+
+func TestKeepaliveEmptyDeltas(t *testing.T) {
+	isEmpty := func(c *llmv1.ChatCompletionChunkResponse) bool {
+		return c.GetType() == string(events.OutputTextDelta) && c.GetText() == ""
+	}
+	chunks, before := keepaliveStream(t, keepaliveConfig("delta"), isEmpty)
+	if before < 2 {
+		t.Fatalf("%d empty deltas before the first delta, want at least 2: %v", before, chunks)
+	}
+	llmtest.RequireDone(t, chunks, events.FinishStop)
+
+	// Off by default, and nothing for delays shorter than the interval.
+	isKeepalive := func(c *llmv1.ChatCompletionChunkResponse) bool { return c.GetText() == "" && c.GetFinishReason() == "" }
+	for _, cfg := range []config.Config{{TTFTMinMs: config.Int(120)}, {TTFTMinMs: config.Int(20), GRPCKeepaliveChunkMs: 30}} {
+		cfg.StrictTokenMode, cfg.MaxOutputChars = config.Bool(true), config.Int(64)
+		if _, n := keepaliveStream(t, cfg, isKeepalive); n != 0 {
+			t.Fatalf("%d keep-alive chunks with GRPC_KEEPALIVE_CHUNK_MS=%d and a %dms TTFT", n, cfg.GRPCKeepaliveChunkMs, *cfg.TTFTMinMs)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kill switch modes (see AdminService FailAll/PauseAll).
+const (
+	killFail  = "fail"
+	killPause = "pause"
+)
+
+// killSwitch is the FailAll/PauseAll state of a MockLlmService. Handlers call wait on entry, which
+// checks the expiry timestamp itself; a timer additionally lifts the switch on expiry so paused
+// requests resume and readiness recovers without further traffic.
+type killSwitch struct {
+	mu       sync.Mutex
+	mode     string     // killFail|killPause, empty when lifted
+	code     codes.Code // killFail: gRPC status
+	httpCode int        // killFail: HTTP status
+	until    time.Time
+	lifted   chan struct{} // closed when the current switch is replaced, lifted or expires
+	timer    *time.Timer
+	ready    *Readiness // reports StateDown while active; may be nil
+}
+
+func newKillSwitch() *killSwitch {
+	return &killSwitch{lifted: make(chan struct{})}
+}
+
+func (k *killSwitch) setReadiness(ready *Readiness) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.ready = ready
+}
+
+// set replaces the current switch with mode for d; d <= 0 lifts it. It returns the resulting mode
+// and expiry.
+func (k *killSwitch) set(mode string, code codes.Code, httpCode int, d time.Duration) (string, time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.liftLocked()
+	if d > 0 {
+		k.mode, k.code, k.httpCode, k.until = mode, code, httpCode, time.Now().Add(d)
+		gen := k.lifted
+		k.timer = time.AfterFunc(d, func() { k.expire(gen) })
+	}
+	if k.ready != nil {
+		k.ready.SetDown(k.mode != "")
+	}
+	logger.Log.Warnw("[admin] kill switch", "mode", k.mode, "code", k.code, "durationMs", d.Milliseconds())
+	return k.mode, k.until
+}
+
+// expire lifts the switch generation gen unless it was replaced meanwhile.
+func (k *killSwitch) expire(gen chan struct{}) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.lifted != gen {
+		return
+	}
+	k.liftLocked()
+	if k.ready != nil {
+		k.ready.SetDown(false)
+	}
+	logger.Log.Infow("[admin] kill switch expired")
+}
+
+func (k *killSwitch) liftLocked() {
+	if k.timer != nil {
+		k.timer.Stop()
+		k.timer = nil
+	}
+	k.mode, k.until = "", time.Time{}
+	close(k.lifted)
+	k.lifted = make(chan struct{})
+}
+
+// wait blocks while a pause is active (until it lifts or ctx is done) and returns the FailAll
+// status, with its HTTP equivalent, while failing. It returns nil when no switch is active.
+func (k *killSwitch) wait(ctx context.Context) (int, error) {
+	for {
+		k.mu.Lock()
+		mode, code, httpCode, until, lifted := k.mode, k.code, k.httpCode, k.until, k.lifted
+		k.mu.Unlock()
+		if mode == "" || !time.Now().Before(until) {
+			return 0, nil
+		}
+		if mode == killFail {
+			return httpCode, status.Error(code, "simulated outage (admin FailAll)")
+		}
+		t := time.NewTimer(time.Until(until))
+		select {
+		case <-lifted:
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return 0, status.FromContextError(ctx.Err()).Err()
+		}
+		t.Stop()
+	}
+}
+
+// protect applies the switch to an HTTP route: failing requests get the FailAll status through
+// writeErr, paused ones block before reaching h.
+func (k *killSwitch) protect(h http.Handler, writeErr httpErrorWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httpCode, err := k.wait(r.Context()); err != nil {
+			if httpCode != 0 {
+				writeErr(w, httpCode, status.Convert(err).Message())
+			}
+			return // otherwise the client is gone
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ---- AdminService ----
+
+func (a *AdminService) FailAll(_ context.Context, req *llmv1.FailAllRequest) (*llmv1.KillSwitchResponse, error) {
+	var code codes.Code
+	var httpCode int
+	switch strings.ToUpper(req.GetCode()) {
+	case "", "UNAVAILABLE":
+		code, httpCode = codes.Unavailable, http.StatusServiceUnavailable
+	case "RESOURCE_EXHAUSTED":
+		code, httpCode = codes.ResourceExhausted, http.StatusTooManyRequests
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "code must be UNAVAILABLE or RESOURCE_EXHAUSTED, got %q", req.GetCode())
+	}
+	if req.GetDurationMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration_ms must not be negative")
+	}
+	return killSwitchResponse(a.svc.kill.set(killFail, code, httpCode, time.Duration(req.GetDurationMs())*time.Millisecond)), nil
+}
+
+func (a *AdminService) PauseAll(_ context.Context, req *llmv1.PauseAllRequest) (*llmv1.KillSwitchResponse, error) {
+	if req.GetDurationMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration_ms must not be negative")
+	}
+	return killSwitchResponse(a.svc.kill.set(killPause, codes.OK, 0, time.Duration(req.GetDurationMs())*time.Millisecond)), nil
+}
+
+func killSwitchResponse(mode string, until time.Time) *llmv1.KillSwitchResponse {
+	resp := &llmv1.KillSwitchResponse{Mode: mode}
+	if !until.IsZero() {
+		resp.ExpiresAtMs = until.UnixMilli()
+	}
+	return resp
+}
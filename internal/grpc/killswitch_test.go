@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func TestFailAll(t *testing.T) {
+	svc := NewMockLlmService(config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)})
+	ready := NewReadiness(0)
+	svc.SetReadiness(ready)
+	client, admin := startAdminServer(t, svc)
+	mux := NewLiveHTTPMux(svc, nil, nil, ready)
+	health := &healthServer{ready: ready}
+	ctx := context.Background()
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8}
+
+	resp, err := admin.FailAll(ctx, &llmv1.FailAllRequest{Code: "resource_exhausted", DurationMs: 300})
+	if err != nil || resp.GetMode() != "fail" || resp.GetExpiresAtMs() == 0 {
+		t.Fatalf("FailAll: %v, %v", resp, err)
+	}
+	if _, err := client.ChatCompletion(ctx, req); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted while failing, got %v", err)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m","max_tokens":8,"messages":[{"role":"user","content":"hello"}]}`)))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over HTTP while failing, got %d", rr.Code)
+	}
+	if st, _ := health.Check(ctx, &healthpb.HealthCheckRequest{}); st.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected NOT_SERVING while failing, got %v", st.GetStatus())
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), StateDown) {
+		t.Fatalf("expected /readyz to report down, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// The switch expires on its own.
+	time.Sleep(400 * time.Millisecond)
+	if _, err := client.ChatCompletion(ctx, req); err != nil {
+		t.Fatalf("expected success after expiry, got %v", err)
+	}
+	if ready.State() != StateReady {
+		t.Fatalf("expected ready after expiry, got %s", ready.State())
+	}
+
+	if _, err := admin.FailAll(ctx, &llmv1.FailAllRequest{Code: "INTERNAL", DurationMs: 100}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unsupported code, got %v", err)
+	}
+}
+
+func TestPauseAll(t *testing.T) {
+	svc := NewMockLlmService(config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)})
+	ready := NewReadiness(0)
+	svc.SetReadiness(ready)
+	client, admin := startAdminServer(t, svc)
+	ctx := context.Background()
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8}
+
+	if _, err := admin.PauseAll(ctx, &llmv1.PauseAllRequest{DurationMs: 300}); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	if ready.State() != StateDown {
+		t.Fatalf("expected down while paused, got %s", ready.State())
+	}
+
+	// Paused requests respect their deadlines...
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ChatCompletion(short, req); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded while paused, got %v", err)
+	}
+
+	// ...and otherwise resume once the pause lifts.
+	start := time.Now()
+	if _, err := client.ChatCompletion(ctx, req); err != nil {
+		t.Fatalf("expected success after the pause, got %v", err)
+	}
+	if waited := time.Since(start); waited < 150*time.Millisecond {
+		t.Fatalf("request was not held by the pause (waited %v)", waited)
+	}
+	if ready.State() != StateReady {
+		t.Fatalf("expected ready after the pause, got %s", ready.State())
+	}
+
+	// A zero duration lifts an active pause right away.
+	if _, err := admin.PauseAll(ctx, &llmv1.PauseAllRequest{DurationMs: 60_000}); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ChatCompletion(ctx, req)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if resp, err := admin.PauseAll(ctx, &llmv1.PauseAllRequest{}); err != nil || resp.GetMode() != "" {
+		t.Fatalf("lifting the pause: %v, %v", resp, err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected the paused request to succeed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("paused request still blocked after the pause was lifted")
+	}
+	if ready.State() != StateReady {
+		t.Fatalf("expected ready after lifting, got %s", ready.State())
+	}
+}
@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"slices"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pageSize is the stride kvCache writes at so every page of its buffer is resident.
+const pageSize = 4096
+
+// kvCache simulates the KV-cache memory of one stream (MEM_BYTES_PER_TOKEN): a buffer that grows
+// with the prompt and every emitted chunk and is released when the stream ends. All streams share
+// the MEM_LIMIT_BYTES budget; growing past it fails like a server running out of KV cache. The zero
+// config (MEM_BYTES_PER_TOKEN=0) holds nothing.
+type kvCache struct {
+	perToken int64
+	limit    int64
+	buf      []byte
+}
+
+func newKVCache(cfg config.Config) *kvCache {
+	return &kvCache{perToken: int64(cfg.MemBytesPerToken), limit: int64(cfg.MemLimitBytes)}
+}
+
+// grow holds toks more tokens, or fails with ResourceExhausted when that would take the memory of
+// all streams past MEM_LIMIT_BYTES.
+func (k *kvCache) grow(toks int) error {
+	n := int64(toks) * k.perToken
+	if n <= 0 {
+		return nil
+	}
+	if !stats.Default.ReserveMemory(n, k.limit) {
+		return status.Errorf(codes.ResourceExhausted, "simulated KV cache exhausted: %d more bytes would exceed MEM_LIMIT_BYTES=%d", n, k.limit)
+	}
+	metrics.SimulatedMemory.Add(float64(n))
+	old := len(k.buf)
+	k.buf = slices.Grow(k.buf, int(n))[:old+int(n)]
+	for i := old; i < len(k.buf); i += pageSize {
+		k.buf[i] = 1
+	}
+	return nil
+}
+
+// release gives back everything the stream holds. It must run on every exit path (defer it).
+func (k *kvCache) release() {
+	n := int64(len(k.buf))
+	if n == 0 {
+		return
+	}
+	stats.Default.ReleaseMemory(n)
+	metrics.SimulatedMemory.Sub(float64(n))
+	k.buf = nil
+}
@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// kvConfig holds 1KB per token with room for about two 64-token streams at a time.
+func kvConfig() config.Config {
+	return config.Config{
+		ChunkSize:        config.Int(16),
+		StreamDelayMinMs: config.Int(5),
+		StreamDelayMaxMs: config.Int(5),
+		StrictTokenMode:  config.Bool(true),
+		MemBytesPerToken: 1000,
+		MemLimitBytes:    150_000,
+	}
+}
+
+// assertMemoryReleased fails unless every stream gave its simulated memory back.
+func assertMemoryReleased(t *testing.T) {
+	t.Helper()
+	if held := stats.Default.Snapshot(false).SimulatedMemoryBytes; held != 0 {
+		t.Fatalf("%d bytes still held after all streams ended", held)
+	}
+	if g := testutil.ToFloat64(metrics.SimulatedMemory); g != 0 {
+		t.Fatalf("simulated memory gauge is %v after all streams ended", g)
+	}
+}
+
+// TestKVCacheLimitGRPC runs more concurrent streams than MEM_LIMIT_BYTES has room for: some fail
+// with ResourceExhausted, memory is held while streaming and all of it is released afterwards.
+func TestKVCacheLimitGRPC(t *testing.T) {
+	svc := NewMockLlmService(kvConfig())
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		ok, fails int
+		held      bool
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fs := llmtest.NewServerStream(context.Background())
+			fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+				if stats.Default.Snapshot(false).SimulatedMemoryBytes > 0 {
+					mu.Lock()
+					held = true
+					mu.Unlock()
+				}
+			}
+			err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "kv cache", MaxTokens: 64}, fs)
+			mu.Lock()
+			defer mu.Unlock()
+			switch status.Code(err) {
+			case codes.OK:
+				ok++
+			case codes.ResourceExhausted:
+				fails++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fails == 0 || !held {
+		t.Fatalf("expected KV cache exhaustion while memory was held: %d ok, %d failed, held=%t", ok, fails, held)
+	}
+	assertMemoryReleased(t)
+}
+
+// TestKVCacheLimitSSE is TestKVCacheLimitGRPC for the SSE stream: a 429 or an error event.
+func TestKVCacheLimitSSE(t *testing.T) {
+	cfg := kvConfig()
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		fails int
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "kv cache"), 64, cfg, 16, nil)
+			if strings.Contains(rr.Body.String(), "simulated KV cache exhausted") {
+				mu.Lock()
+				fails++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fails == 0 {
+		t.Fatal("expected KV cache exhaustion")
+	}
+	assertMemoryReleased(t)
+}
@@ -0,0 +1,302 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/internal/ratelimit"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Limits holds the request limiters shared by the gRPC and HTTP servers, so a key has one budget
+// across both. A nil *Limits, or a nil limiter inside it, disables that limit.
+type Limits struct {
+	Keys  *ratelimit.Limiter // per API key (KEY_RPM / KEY_TPM)
+	Peers *ratelimit.Limiter // per client IP (PEER_RPM), no auth required
+}
+
+// NewLimits builds the limiters configured in cfg.
+func NewLimits(cfg config.Config) *Limits {
+	return &Limits{
+		Keys:  ratelimit.New(cfg.KeyRPM, cfg.KeyTPM),
+		Peers: ratelimit.New(cfg.PeerRPM, 0),
+	}
+}
+
+func (l *Limits) keys() *ratelimit.Limiter {
+	if l == nil {
+		return nil
+	}
+	return l.Keys
+}
+
+func (l *Limits) peers() *ratelimit.Limiter {
+	if l == nil {
+		return nil
+	}
+	return l.Peers
+}
+
+// ---- gRPC ----
+
+// authOptions returns interceptors enforcing the per-peer limit, API_KEYS and the per-key limits on
+// gRPC calls. Keys are read from the `authorization: Bearer <key>` or `x-api-key` metadata.
+func authOptions(cfg config.Config, limits *Limits) []grpc.ServerOption {
+	g := &grpcGuard{apiKeys: cfg.APIKeys, keys: limits.keys(), peers: limits.peers()}
+	if len(g.apiKeys) == 0 && g.keys == nil && g.peers == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(g.unary),
+		WithStreamInterceptors(g.stream),
+	}
+}
+
+type grpcGuard struct {
+	apiKeys []string
+	keys    *ratelimit.Limiter
+	peers   *ratelimit.Limiter
+}
+
+// admit applies the per-peer limit, authenticates the call and applies the per-key request limit,
+// returning the caller's key.
+func (g *grpcGuard) admit(ctx context.Context) (string, error) {
+	if g.peers != nil {
+		if d := g.peers.Allow(grpcPeerIP(ctx)); !d.Allowed {
+			logger.Log.Infow("[grpc] peer rate limited", "peer", grpcPeerIP(ctx), "retryAfterMs", d.RetryAfter.Milliseconds())
+			return "", rateLimitStatus(d)
+		}
+	}
+
+	key := grpcAPIKey(ctx)
+	if len(g.apiKeys) > 0 && !slices.Contains(g.apiKeys, key) {
+		return "", status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if key == "" {
+		return "", nil
+	}
+	if d := g.keys.Allow(key); !d.Allowed {
+		logger.Log.Infow("[grpc] rate limited", "limit", d.Reason, "retryAfterMs", d.RetryAfter.Milliseconds())
+		return "", rateLimitStatus(d)
+	}
+	return key, nil
+}
+
+func (g *grpcGuard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	key, err := g.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	if r, ok := resp.(*llmv1.ChatCompletionResponse); ok && key != "" {
+		g.keys.Charge(key, int(r.GetTotalTokens()))
+	}
+	return resp, err
+}
+
+func (g *grpcGuard) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	key, err := g.admit(ss.Context())
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return handler(srv, ss)
+	}
+	return handler(srv, &chargingStream{ServerStream: ss, charge: func(n int) { g.keys.Charge(key, n) }})
+}
+
+// chargingStream charges the total tokens reported on the done chunk.
+type chargingStream struct {
+	grpc.ServerStream
+	charge func(tokens int)
+}
+
+func (s *chargingStream) SendMsg(m any) error {
+	if ch, ok := m.(*llmv1.ChatCompletionChunkResponse); ok && isDoneChunk(ch) {
+		s.charge(int(ch.GetTotalTokens()))
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// grpcPeerIP returns the client IP (without port) of a gRPC call.
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	return hostOnly(p.Addr.String())
+}
+
+// hostOnly strips the port from a host:port address.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func grpcAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		return strings.TrimSpace(strings.TrimPrefix(v[0], "Bearer "))
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// rateLimitStatus is ResourceExhausted with a RetryInfo detail.
+func rateLimitStatus(d ratelimit.Decision) error {
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded: %s per minute", d.Reason))
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(d.RetryAfter)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// ---- HTTP ----
+
+// httpErrorWriter writes an error in a route's provider shape.
+type httpErrorWriter func(w http.ResponseWriter, code int, message string)
+
+// httpGuard enforces the per-peer limit, API_KEYS and the per-key limits on HTTP routes.
+type httpGuard struct {
+	apiKeys []string
+	keys    *ratelimit.Limiter
+	peers   *ratelimit.Limiter
+}
+
+func newHTTPGuard(cfg config.Config, limits *Limits) *httpGuard {
+	return &httpGuard{apiKeys: cfg.APIKeys, keys: limits.keys(), peers: limits.peers()}
+}
+
+// protect wraps h with per-peer limiting, API key auth and per-key limiting. Errors use writeErr so
+// each route keeps its provider's error shape.
+func (g *httpGuard) protect(h http.Handler, writeErr httpErrorWriter) http.Handler {
+	return g.wrap(h, true, writeErr)
+}
+
+// limit wraps h with per-peer and per-key limiting only, for handlers that authenticate themselves.
+func (g *httpGuard) limit(h http.Handler, writeErr httpErrorWriter) http.Handler {
+	return g.wrap(h, false, writeErr)
+}
+
+func (g *httpGuard) wrap(h http.Handler, auth bool, writeErr httpErrorWriter) http.Handler {
+	if (!auth || len(g.apiKeys) == 0) && g.keys == nil && g.peers == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.peers != nil {
+			ip := hostOnly(r.RemoteAddr)
+			if d := g.peers.Allow(ip); !d.Allowed {
+				logger.Log.Infow("[http] peer rate limited", "path", r.URL.Path, "peer", ip, "retryAfterMs", d.RetryAfter.Milliseconds())
+				setRateLimitHeaders(w.Header(), d)
+				rejectRateLimited(w, d, writeErr)
+				return
+			}
+		}
+
+		key := httpAPIKey(r)
+		if auth && len(g.apiKeys) > 0 && !slices.Contains(g.apiKeys, key) {
+			writeErr(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		if key == "" || g.keys == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		d := g.keys.Allow(key)
+		setRateLimitHeaders(w.Header(), d)
+		if !d.Allowed {
+			logger.Log.Infow("[http] rate limited", "path", r.URL.Path, "limit", d.Reason, "retryAfterMs", d.RetryAfter.Milliseconds())
+			rejectRateLimited(w, d, writeErr)
+			return
+		}
+		ctx := context.WithValue(r.Context(), usageReporterKey{}, func(n int) { g.keys.Charge(key, n) })
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rejectRateLimited answers a request over a limit with a 429 and Retry-After.
+func rejectRateLimited(w http.ResponseWriter, d ratelimit.Decision, writeErr httpErrorWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	writeErr(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded: %s per minute", d.Reason))
+}
+
+// setRateLimitHeaders sets OpenAI-style x-ratelimit-* headers for the enabled limits.
+func setRateLimitHeaders(h http.Header, d ratelimit.Decision) {
+	if d.RequestLimit > 0 {
+		h.Set("x-ratelimit-limit-requests", strconv.Itoa(d.RequestLimit))
+		h.Set("x-ratelimit-remaining-requests", strconv.Itoa(d.RequestsRemaining))
+		h.Set("x-ratelimit-reset-requests", d.RequestsReset.Round(time.Millisecond).String())
+	}
+	if d.TokenLimit > 0 {
+		h.Set("x-ratelimit-limit-tokens", strconv.Itoa(d.TokenLimit))
+		h.Set("x-ratelimit-remaining-tokens", strconv.Itoa(d.TokensRemaining))
+		h.Set("x-ratelimit-reset-tokens", d.TokensReset.Round(time.Millisecond).String())
+	}
+}
+
+// httpAPIKey reads the caller's key from the headers used by the supported providers.
+func httpAPIKey(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); v != "" {
+		return strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+	}
+	for _, h := range []string{"x-api-key", "api-key", "x-goog-api-key"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return r.URL.Query().Get("key")
+}
+
+type usageReporterKey struct{}
+
+// reportUsage charges the total tokens of a completed HTTP request to the caller's budget and
+// records them for the per-model/per-key usage counters.
+func reportUsage(ctx context.Context, promptTokens, completionTokens int) {
+	setRequestUsage(ctx, promptTokens, completionTokens)
+	if charge, ok := ctx.Value(usageReporterKey{}).(func(int)); ok {
+		charge(promptTokens + completionTokens)
+	}
+}
+
+// Error writers for each provider shape.
+var (
+	openAIHTTPError    httpErrorWriter = writeOpenAIError
+	anthropicHTTPError httpErrorWriter = writeAnthropicError
+	geminiHTTPError    httpErrorWriter = writeGeminiError
+	ollamaHTTPError    httpErrorWriter = func(w http.ResponseWriter, code int, message string) {
+		writeJSON(w, code, mock.OllamaError{Error: message})
+	}
+	azureHTTPError httpErrorWriter = func(w http.ResponseWriter, code int, message string) {
+		writeAzureError(w, code, strconv.Itoa(code), message)
+	}
+)
@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestKeyRPMGRPC(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), APIKeys: []string{"k1", "k2"}, KeyRPM: 3}
+	client := startTestServer(t, cfg)
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.ChatCompletion(withKey("k1"), req); err != nil {
+			t.Fatalf("request %d failed: %v", i+1, err)
+		}
+	}
+	_, err := client.ChatCompletion(withKey("k1"), req)
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() <= 0 {
+		t.Fatalf("missing RetryInfo detail: %v", st.Details())
+	}
+
+	// Streams share the key's budget; a second key is unaffected.
+	stream, err := client.ChatCompletionStream(withKey("k1"), req)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected stream to be rate limited, got %v", err)
+	}
+	if _, err := client.ChatCompletion(withKey("k2"), req); err != nil {
+		t.Fatalf("second key should be unaffected: %v", err)
+	}
+
+	if _, err := client.ChatCompletion(withKey("bogus"), req); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for an unknown key, got %v", err)
+	}
+}
+
+func TestKeyTPMChargesCompletedTokens(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), KeyTPM: 20}
+	client := startTestServer(t, cfg)
+
+	// Each response is 16 completion tokens plus the prompt, so the second call exhausts the budget.
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 16}
+	resp, err := client.ChatCompletion(withKey("k1"), req)
+	if err != nil || resp.GetTotalTokens() < 16 {
+		t.Fatalf("first request failed: %v", err)
+	}
+	if _, err := client.ChatCompletion(withKey("k1"), req); err != nil {
+		t.Fatalf("second request should still fit: %v", err)
+	}
+	if _, err := client.ChatCompletion(withKey("k1"), req); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted after exceeding TPM, got %v", err)
+	}
+}
+
+func TestKeyRPMHTTP(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), KeyRPM: 2}
+	mux := NewHTTPMux(cfg, nil, NewLimits(cfg), nil)
+	post := func(key string) *httptest.ResponseRecorder {
+		body := `{"max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := post("k1"); rr.Code != http.StatusOK {
+			t.Fatalf("request %d failed: %d", i+1, rr.Code)
+		}
+	}
+	rr := post("k1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" || rr.Header().Get("x-ratelimit-remaining-requests") != "0" {
+		t.Fatalf("missing rate limit headers: %v", rr.Header())
+	}
+	if !strings.Contains(rr.Body.String(), "rate_limit_exceeded") {
+		t.Fatalf("expected OpenAI rate limit error body, got %s", rr.Body.String())
+	}
+	if rr := post("k2"); rr.Code != http.StatusOK || rr.Header().Get("x-ratelimit-limit-requests") != "2" {
+		t.Fatalf("second key should be unaffected: %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestPeerRPMIndependentBudgets(t *testing.T) {
+	cfg := config.Config{PeerRPM: 2}
+	g := &grpcGuard{peers: NewLimits(cfg).Peers}
+	peerCtx := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.admit(peerCtx("10.0.0.1")); err != nil {
+			t.Fatalf("request %d from peer A rejected: %v", i+1, err)
+		}
+	}
+	if _, err := g.admit(peerCtx("10.0.0.1")); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected peer A to be limited, got %v", err)
+	}
+	if _, err := g.admit(peerCtx("10.0.0.2")); err != nil {
+		t.Fatalf("peer B should have its own budget: %v", err)
+	}
+
+	// HTTP keys on the remote IP, ignoring the port.
+	mux := NewHTTPMux(config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}, nil, NewLimits(cfg), nil)
+	get := func(remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/chat/completions?prompt=hi&max_tokens=4", nil)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	for _, remote := range []string{"10.0.0.1:1000", "10.0.0.1:1001"} {
+		if code := get(remote); code != http.StatusOK {
+			t.Fatalf("request from %s rejected: %d", remote, code)
+		}
+	}
+	if code := get("10.0.0.1:1002"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for peer A, got %d", code)
+	}
+	if code := get("10.0.0.2:1000"); code != http.StatusOK {
+		t.Fatalf("peer B should have its own budget, got %d", code)
+	}
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// staleSocket leaves a socket file nobody listens on at path, like a crashed previous run.
+func staleSocket(t *testing.T, path string) {
+	t.Helper()
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	lis.Close()
+}
+
+func TestUnixSocketStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm-sim.sock")
+	staleSocket(t, path)
+
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	srv := NewGRPCServer("unix://"+path, NewMockLlmService(cfg))
+	srv.SetUnixSocketMode(0o600)
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected socket file: %v %v", fi, err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run() }()
+
+	conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := llmv1.NewLlmServiceClient(conn).ChatCompletionStream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "uds", MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	chunks := 0
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		chunks++
+	}
+	if chunks < 2 {
+		t.Fatalf("expected deltas and a done chunk, got %d chunks", chunks)
+	}
+
+	srv.Stop()
+	<-runErr
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file should be removed on shutdown, stat err=%v", err)
+	}
+}
+
+func TestUnixSocketHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm-sim-http.sock")
+	srv := NewHTTPServer("unix:"+path, NewHTTPMux(config.Config{}, nil, nil, nil))
+	go func() { _ = srv.Run() }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = client.Get("http://sim/healthz"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("healthz over unix socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+func TestUnixSocketRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(path, []byte("keep me"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := listen("unix://"+path, 0); err == nil {
+		t.Fatalf("expected an error for a regular file")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "keep me" {
+		t.Fatalf("regular file must not be removed")
+	}
+}
@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+// errMaxDuration is the cause of a request context ended by MAX_REQUEST_DURATION_MS.
+var errMaxDuration = errors.New("max request duration reached")
+
+// withMaxDuration returns ctx bounded to MAX_REQUEST_DURATION_MS from start, or ctx itself when the
+// cap is off. A request whose context the cap ends stops generating and completes with what it has
+// (see capped), like providers capping generation time, instead of failing as a client deadline does.
+func withMaxDuration(ctx context.Context, cfg config.Config, start time.Time) (context.Context, context.CancelFunc) {
+	if cfg.MaxRequestDurationMs <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithDeadlineCause(ctx, start.Add(time.Duration(cfg.MaxRequestDurationMs)*time.Millisecond), errMaxDuration)
+}
+
+// capped reports whether ctx was ended by MAX_REQUEST_DURATION_MS rather than by the client.
+func capped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errMaxDuration)
+}
+
+// cappedFinishReason returns the finish reason of a response cut by MAX_REQUEST_DURATION_MS.
+func cappedFinishReason(cfg config.Config) events.FinishReason {
+	if cfg.MaxRequestDurationFinishReason == string(events.FinishTimeout) {
+		return events.FinishTimeout
+	}
+	return events.FinishLength
+}
+
+// generatedShare returns the share of a generation planned to take total that ran in took.
+func generatedShare(took, total time.Duration) float64 {
+	if total <= 0 {
+		return 1
+	}
+	return min(took.Seconds()/total.Seconds(), 1)
+}
+
+// truncateOutput returns the start of a unary output cut by MAX_REQUEST_DURATION_MS after share of
+// its generation, ending on a whole rune.
+func truncateOutput(out string, share float64) string {
+	n := int(float64(len(out)) * share)
+	for n > 0 && n < len(out) && !utf8.RuneStart(out[n]) {
+		n--
+	}
+	return out[:n]
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// slowCapped is a config whose output takes seconds to stream or generate, capped at 50ms.
+func slowCapped(reason string) config.Config {
+	return config.Config{
+		ChunkSize:                      config.Int(4),
+		StrictTokenMode:                config.Bool(true),
+		StreamDelayMinMs:               config.Int(20),
+		StreamDelayMaxMs:               config.Int(20),
+		TokensPerSec:                   config.Float(50),
+		MaxRequestDurationMs:           50,
+		MaxRequestDurationFinishReason: reason,
+	}
+}
+
+func TestStreamMaxRequestDuration(t *testing.T) {
+	for _, reason := range []events.FinishReason{events.FinishLength, events.FinishTimeout} {
+		svc := NewMockLlmService(slowCapped(string(reason)))
+		fs := llmtest.NewServerStream(context.Background())
+		start := time.Now()
+		if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "capped", MaxTokens: 200}, fs); err != nil {
+			t.Fatalf("%s: expected a truncated stream, got %v", reason, err)
+		}
+		if took := time.Since(start); took > 300*time.Millisecond {
+			t.Fatalf("%s: the stream ran for %v despite the 50ms cap", reason, took)
+		}
+
+		var text strings.Builder
+		for _, c := range fs.Chunks()[:len(fs.Chunks())-1] {
+			text.WriteString(c.GetText())
+		}
+		done := fs.Chunks()[len(fs.Chunks())-1]
+		if done.GetFinishReason() != string(reason) || !isDoneChunk(done) {
+			t.Fatalf("%s: last chunk is not a truncated done chunk: %+v", reason, done)
+		}
+		if text.Len() == 0 || text.Len() >= 200*4 {
+			t.Fatalf("%s: expected part of the output, got %d bytes", reason, text.Len())
+		}
+		sum := sha256.Sum256([]byte(text.String()))
+		if done.GetCompletionTokens() != int32(mock.ApproxTokens(text.String())) || done.GetOutputSha256() != hex.EncodeToString(sum[:]) {
+			t.Fatalf("%s: done chunk doesn't describe what was sent: %d tokens, %d bytes", reason, done.GetCompletionTokens(), done.GetOutputBytes())
+		}
+	}
+}
+
+func TestUnaryMaxRequestDuration(t *testing.T) {
+	svc := NewMockLlmService(slowCapped(""))
+	resp, err := svc.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "capped", MaxTokens: 200})
+	if err != nil {
+		t.Fatalf("expected a truncated response, got %v", err)
+	}
+	if resp.GetFinishReason() != string(events.FinishLength) || len(resp.GetOutputText()) >= 200*4 {
+		t.Fatalf("expected a truncated response, got %q with %d bytes", resp.GetFinishReason(), len(resp.GetOutputText()))
+	}
+	if resp.GetCompletionTokens() != int32(mock.ApproxTokens(resp.GetOutputText())) {
+		t.Fatalf("usage counts %d tokens for %d bytes", resp.GetCompletionTokens(), len(resp.GetOutputText()))
+	}
+
+	// A client deadline shorter than the cap still fails the request.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := svc.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "capped", MaxTokens: 200}); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded for the client deadline, got %v", err)
+	}
+}
+
+func TestHTTPMaxRequestDuration(t *testing.T) {
+	cfg := slowCapped("timeout")
+	cfg.DefaultTokens = 200
+
+	rr := httptest.NewRecorder()
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/?prompt=capped", nil))
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	last := chunks[len(chunks)-1]
+	if last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != string(events.FinishTimeout) {
+		t.Fatalf("expected a done chunk with finish_reason timeout: %+v", last)
+	}
+	var text strings.Builder
+	for _, ch := range chunks[1 : len(chunks)-1] {
+		text.WriteString(ch.Choices[0].Delta.Content)
+	}
+	if text.Len() == 0 || last.Output == nil || last.Output.Bytes != text.Len() {
+		t.Fatalf("expected the digest of the %d bytes sent, got %+v", text.Len(), last.Output)
+	}
+
+	rr = httptest.NewRecorder()
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(`{"messages":[{"role":"user","content":"capped"}]}`)))
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || rr.Code != 200 {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	content := resp.Choices[0].Message.Content
+	if resp.Choices[0].FinishReason != string(events.FinishTimeout) || len(content) >= 200*4 || resp.Usage.CompletionTokens != mock.ApproxTokens(content) {
+		t.Fatalf("expected a truncated response, got %q with %d bytes and %d tokens", resp.Choices[0].FinishReason, len(content), resp.Usage.CompletionTokens)
+	}
+}
@@ -0,0 +1,268 @@
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ---- gRPC ----
+
+// metricsOptions returns interceptors recording request counts, latency, in-flight streams and
+// output tokens (in Prometheus and stats.Default) for every RPC except the health service and
+// GetStats. TTFT and chunk gaps are observed by the stream handler itself.
+func metricsOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(metricsUnaryInterceptor),
+		WithStreamInterceptors(metricsStreamInterceptor),
+	}
+}
+
+func metricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthMethod(info.FullMethod) || isControlMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	rpc := path.Base(info.FullMethod)
+	model := ""
+	if r, ok := req.(*llmv1.ChatCompletionRequest); ok {
+		model = r.GetModel()
+	}
+	code := status.Code(err)
+	observeRequest(rpc, model, code.String(), code == codes.OK, start)
+	if r, ok := resp.(*llmv1.ChatCompletionResponse); ok && err == nil {
+		observeOutputTokens(rpc, int(r.GetCompletionTokens()))
+		observeUsage(model, grpcAPIKey(ctx), int(r.GetPromptTokens()), int(r.GetCompletionTokens()))
+	}
+	return resp, err
+}
+
+func metricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) || isControlMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	start := time.Now()
+	rpc := path.Base(info.FullMethod)
+	defer trackInflight(rpc)()
+
+	ms := &metricsStream{ServerStream: ss, rpc: rpc}
+	err := handler(srv, ms)
+	code := status.Code(err)
+	observeRequest(rpc, ms.model, code.String(), code == codes.OK, start)
+	if ms.done != nil {
+		observeUsage(ms.model, grpcAPIKey(ss.Context()), int(ms.done.GetPromptTokens()), int(ms.done.GetCompletionTokens()))
+	}
+	return err
+}
+
+// metricsStream captures the request model and the done chunk, and observes its output tokens.
+type metricsStream struct {
+	grpc.ServerStream
+	rpc   string
+	model string
+	done  *llmv1.ChatCompletionChunkResponse
+}
+
+func (s *metricsStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if r, ok := m.(*llmv1.ChatCompletionRequest); ok && err == nil {
+		s.model = r.GetModel()
+	}
+	return err
+}
+
+func (s *metricsStream) SendMsg(m any) error {
+	if ch, ok := m.(*llmv1.ChatCompletionChunkResponse); ok && isDoneChunk(ch) {
+		s.done = ch
+		observeOutputTokens(s.rpc, int(ch.GetCompletionTokens()))
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// countInjectedGRPCError records an injected gRPC error in the metrics and the access log.
+func countInjectedGRPCError(ctx context.Context, mode string, code codes.Code) {
+	metrics.InjectedErrors.WithLabelValues(mode, code.String()).Inc()
+	stats.Default.Injected(mode)
+	markInjected(ctx)
+}
+
+// isControlMethod reports whether fullMethod is GetStats or an AdminService method, which are not
+// counted as simulated traffic in the metrics and stats.
+func isControlMethod(fullMethod string) bool {
+	return fullMethod == llmv1.LlmService_GetStats_FullMethodName ||
+		strings.HasPrefix(fullMethod, "/"+llmv1.AdminService_ServiceDesc.ServiceName+"/")
+}
+
+// ---- shared ----
+
+// observeRequest records a finished request in the metrics and stats.Default. Models beyond the
+// usage cardinality cap are labeled stats.Other, so clients can't create series at will.
+func observeRequest(rpc, model, code string, ok bool, start time.Time) {
+	metrics.Requests.WithLabelValues(rpc, stats.Default.ModelLabel(model), code).Inc()
+	metrics.RequestDuration.WithLabelValues(rpc).Observe(metrics.Since(start))
+	stats.Default.Request(rpc, code, ok, time.Since(start))
+}
+
+// observeTTFT records the simulated time to first token (queue + prefill) of a stream.
+func observeTTFT(rpc string, d time.Duration) {
+	metrics.TTFT.WithLabelValues(rpc).Observe(d.Seconds())
+	stats.Default.TTFT(d)
+}
+
+// observeOutputTokens records the completion tokens of a successful request.
+func observeOutputTokens(rpc string, n int) {
+	metrics.OutputTokens.WithLabelValues(rpc).Observe(float64(n))
+	stats.Default.Tokens(n)
+}
+
+// observeUsage records the token usage of a completed request by model and API key.
+func observeUsage(model, key string, promptTokens, completionTokens int) {
+	model, keyHash := stats.Default.Usage(model, key, promptTokens, completionTokens)
+	metrics.UsageRequests.WithLabelValues(model, keyHash).Inc()
+	metrics.UsagePromptTokens.WithLabelValues(model, keyHash).Add(float64(promptTokens))
+	metrics.UsageCompletionTokens.WithLabelValues(model, keyHash).Add(float64(completionTokens))
+}
+
+// observeTenant records a finished request of the tenant profile tenant, "" without TENANT_PROFILES.
+func observeTenant(tenant, code string, ok bool) {
+	if tenant != "" {
+		stats.Default.Tenant(tenant, code, ok)
+	}
+}
+
+// trackInflight counts a stream as in flight until the returned function is called.
+func trackInflight(rpc string) func() {
+	inflight := metrics.InflightStreams.WithLabelValues(rpc)
+	inflight.Inc()
+	done := stats.Default.StreamStarted()
+	return func() {
+		inflight.Dec()
+		done()
+	}
+}
+
+// ---- HTTP ----
+
+// httpRequestMetrics carries per-request labels and usage that only the handler knows.
+type httpRequestMetrics struct {
+	model                          string
+	tenant                         string // the TENANT_PROFILES profile, "" without them
+	usage                          bool
+	promptTokens, completionTokens int
+}
+
+type httpMetricsKey struct{}
+
+// setRequestModel records the model of an HTTP request for llmsim_requests_total.
+func setRequestModel(ctx context.Context, model string) {
+	if m, ok := ctx.Value(httpMetricsKey{}).(*httpRequestMetrics); ok {
+		m.model = model
+	}
+}
+
+// setRequestTenant records the tenant profile of an HTTP request for the per-tenant stats.
+func setRequestTenant(ctx context.Context, tenant string) {
+	if m, ok := ctx.Value(httpMetricsKey{}).(*httpRequestMetrics); ok {
+		m.tenant = tenant
+	}
+}
+
+// setRequestUsage records the token usage of a completed HTTP request; see reportUsage.
+func setRequestUsage(ctx context.Context, promptTokens, completionTokens int) {
+	if m, ok := ctx.Value(httpMetricsKey{}).(*httpRequestMetrics); ok {
+		m.usage = true
+		m.promptTokens, m.completionTokens = promptTokens, completionTokens
+	}
+}
+
+// instrumentHTTP records request counts, latency and in-flight streams for h, labeled with the
+// matched route pattern. It wraps the guard so rejected requests are counted too.
+func instrumentHTTP(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer trackInflight(r.Pattern)()
+
+		m := &httpRequestMetrics{}
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), httpMetricsKey{}, m)))
+
+		code := sw.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		observeRequest(r.Pattern, m.model, strconv.Itoa(code), code < http.StatusBadRequest, start)
+		observeTenant(m.tenant, strconv.Itoa(code), code < http.StatusBadRequest)
+		if m.usage {
+			observeUsage(m.model, httpAPIKey(r), m.promptTokens, m.completionTokens)
+		}
+	})
+}
+
+// statusWriter records the response status while keeping streaming (Flush) and WebSocket upgrades
+// (Hijack) working.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	if w.code == 0 {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// countInjectedHTTPError records an injected HTTP error.
+func countInjectedHTTPError(mode string, code int) {
+	metrics.InjectedErrors.WithLabelValues(mode, strconv.Itoa(code)).Inc()
+	stats.Default.Injected(mode)
+}
+
+// countInjectedReset records an HTTP stream dropped by HTTP_RESET_RATE, which has no status code.
+func countInjectedReset() {
+	metrics.InjectedErrors.WithLabelValues("connection_reset", "reset").Inc()
+	stats.Default.Injected("connection_reset")
+}
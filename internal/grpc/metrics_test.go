@@ -0,0 +1,150 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// histogramCount returns the number of observations of a histogram child.
+func histogramCount(t *testing.T, h *prometheus.HistogramVec, label string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := h.WithLabelValues(label).(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestGRPCMetrics(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	client := startTestServer(t, cfg)
+	ctx := context.Background()
+
+	okUnary := metrics.Requests.WithLabelValues("ChatCompletion", "m-metrics", "OK")
+	okStream := metrics.Requests.WithLabelValues("ChatCompletionStream", "m-metrics", "OK")
+	unaryBefore, streamBefore := testutil.ToFloat64(okUnary), testutil.ToFloat64(okStream)
+	ttftBefore := histogramCount(t, metrics.TTFT, "ChatCompletionStream")
+	gapBefore := histogramCount(t, metrics.ChunkGap, "ChatCompletionStream")
+	tokensBefore := histogramCount(t, metrics.OutputTokens, "ChatCompletionStream")
+
+	req := &llmv1.ChatCompletionRequest{Model: "m-metrics", UserPrompt: "count me", MaxTokens: 32}
+	for i := 0; i < 2; i++ {
+		if _, err := client.ChatCompletion(ctx, req); err != nil {
+			t.Fatalf("unary failed: %v", err)
+		}
+	}
+	stream, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+	}
+
+	if d := testutil.ToFloat64(okUnary) - unaryBefore; d != 2 {
+		t.Fatalf("expected 2 unary requests counted, got %v", d)
+	}
+	if d := testutil.ToFloat64(okStream) - streamBefore; d != 1 {
+		t.Fatalf("expected 1 stream counted, got %v", d)
+	}
+	if histogramCount(t, metrics.TTFT, "ChatCompletionStream") != ttftBefore+1 {
+		t.Fatalf("expected one TTFT observation")
+	}
+	if histogramCount(t, metrics.ChunkGap, "ChatCompletionStream") <= gapBefore {
+		t.Fatalf("expected chunk gap observations")
+	}
+	if histogramCount(t, metrics.OutputTokens, "ChatCompletionStream") != tokensBefore+1 {
+		t.Fatalf("expected one output token observation")
+	}
+	if v := testutil.ToFloat64(metrics.InflightStreams.WithLabelValues("ChatCompletionStream")); v != 0 {
+		t.Fatalf("expected no in-flight streams after completion, got %v", v)
+	}
+}
+
+func TestInjectedErrorMetrics(t *testing.T) {
+	cfg := config.Config{ErrorRate: 1, ErrorMode: "500", StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	client := startTestServer(t, cfg)
+
+	grpcInjected := metrics.InjectedErrors.WithLabelValues("500", "Internal")
+	failed := metrics.Requests.WithLabelValues("ChatCompletion", "", "Internal")
+	injectedBefore, failedBefore := testutil.ToFloat64(grpcInjected), testutil.ToFloat64(failed)
+	if _, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "x"}); err == nil {
+		t.Fatalf("expected an injected error")
+	}
+	if testutil.ToFloat64(grpcInjected) != injectedBefore+1 || testutil.ToFloat64(failed) != failedBefore+1 {
+		t.Fatalf("injected gRPC error not counted")
+	}
+
+	httpInjected := metrics.InjectedErrors.WithLabelValues("500", "500")
+	before := testutil.ToFloat64(httpInjected)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"claude-x","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected injected 500, got %d", rr.Code)
+	}
+	if testutil.ToFloat64(httpInjected) != before+1 {
+		t.Fatalf("injected HTTP error not counted")
+	}
+}
+
+func TestSSEMetricsAndScrape(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	mux := NewHTTPMux(cfg, nil, nil, nil)
+	const route = "POST /v1/chat/completions"
+
+	ok := metrics.Requests.WithLabelValues(route, "m-sse-metrics", "200")
+	okBefore := testutil.ToFloat64(ok)
+	ttftBefore := histogramCount(t, metrics.TTFT, route)
+	tokensBefore := histogramCount(t, metrics.OutputTokens, route)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m-sse-metrics","messages":[{"role":"user","content":"hi"}],"max_tokens":16,"stream":true}`)))
+	if !parseSSE(t, strings.TrimSpace(rr.Body.String())).done {
+		t.Fatalf("incomplete SSE stream")
+	}
+
+	if testutil.ToFloat64(ok)-okBefore != 1 {
+		t.Fatalf("SSE request not counted")
+	}
+	if histogramCount(t, metrics.TTFT, route) != ttftBefore+1 || histogramCount(t, metrics.OutputTokens, route) != tokensBefore+1 {
+		t.Fatalf("SSE TTFT/output tokens not observed")
+	}
+
+	scrape := httptest.NewRecorder()
+	mux.ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := scrape.Body.String()
+	for _, name := range []string{
+		"llmsim_requests_total",
+		"llmsim_request_duration_seconds_bucket",
+		"llmsim_ttft_seconds_bucket",
+		"llmsim_chunk_gap_seconds_bucket",
+		"llmsim_output_tokens_bucket",
+		"llmsim_inflight_streams",
+		`model="m-sse-metrics"`,
+	} {
+		if !strings.Contains(body, name) {
+			t.Fatalf("scrape is missing %s", name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+// moderationMarker matches the "[[flag:<category>]]" markers of a moderation input.
+var moderationMarker = regexp.MustCompile(`\[\[flag:([a-z/-]+)\]\]`)
+
+// ModerationsHandler serves POST /v1/moderations in the OpenAI shape, with one result per input in
+// input order. It only sleeps the base delay and jitter: there is no generation to pace.
+//
+// An input containing "[[flag:<category>]]" markers is flagged in exactly those categories, and one
+// containing "[[flag:none]]" is never flagged, so tests can assert both branches. Other inputs are
+// flagged in a random category at MODERATION_FLAG_RATE. Scores depend only on the input and the
+// verdict: flagged categories score high, the others low.
+func ModerationsHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req mock.ModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		inputs, err := mock.ModerationInputs(req.Input)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Model == "" {
+			req.Model = "omni-moderation-latest"
+		}
+		setRequestModel(r.Context(), req.Model)
+
+		results := make([]mock.ModerationResult, 0, len(inputs))
+		for _, in := range inputs {
+			res, err := moderate(cfg, in)
+			if err != nil {
+				writeOpenAIError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			results = append(results, res)
+		}
+
+		if code := injectHTTPError(cfg); code != 0 {
+			logger.Log.Infow("[http][Moderations] injected error", "mode", cfg.ErrorMode, "status", code)
+			e := openAIError(code, "mock error")
+			e.Error.Injected = true
+			writeJSON(w, code, e)
+			return
+		}
+
+		svc := requestService(r.Context(), cfg)
+		sleepWithContext(r.Context(), time.Duration(svc.queueMs())*time.Millisecond)
+		if r.Context().Err() != nil {
+			return
+		}
+		writeJSON(w, http.StatusOK, mock.ModerationResponse{
+			ID:      "modr_mock_" + mock.RandID(),
+			Model:   req.Model,
+			Results: results,
+		})
+	}
+}
+
+// moderate returns the verdict on one input. It fails on markers naming an unknown category.
+func moderate(cfg config.Config, input string) (mock.ModerationResult, error) {
+	flagged := map[string]bool{}
+	markers := moderationMarker.FindAllStringSubmatch(input, -1)
+	for _, m := range markers {
+		switch {
+		case m[1] == "none":
+		case slices.Contains(mock.ModerationCategories, m[1]):
+			flagged[m[1]] = true
+		default:
+			return mock.ModerationResult{}, fmt.Errorf("unknown moderation category %q in [[flag:...]]", m[1])
+		}
+	}
+	if len(markers) == 0 && mock.Chance(cfg.ModerationFlagRate) {
+		cats := mock.ModerationCategories
+		flagged[cats[mock.RandIntn(len(cats))]] = true
+	}
+
+	res := mock.ModerationResult{
+		Flagged:        len(flagged) > 0,
+		Categories:     make(map[string]bool, len(mock.ModerationCategories)),
+		CategoryScores: make(map[string]float64, len(mock.ModerationCategories)),
+	}
+	for _, c := range mock.ModerationCategories {
+		h := fnv.New64a()
+		h.Write([]byte(c + "\x00" + input))
+		frac := float64(h.Sum64()%1000) / 1000
+		res.Categories[c] = flagged[c]
+		if flagged[c] {
+			res.CategoryScores[c] = 0.9 + frac*0.09
+		} else {
+			res.CategoryScores[c] = frac * 0.01
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+func postModerations(t *testing.T, cfg config.Config, body string) (*httptest.ResponseRecorder, mock.ModerationResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/moderations", strings.NewReader(body)))
+	var resp mock.ModerationResponse
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("bad response: %v", err)
+		}
+	}
+	return rr, resp
+}
+
+// TestModerationsBatch verifies a batch gets one result per input in input order, with the flags
+// the markers ask for, and that a single string input works too.
+func TestModerationsBatch(t *testing.T) {
+	body := `{"input":["hello there","[[flag:violence]] a threat","fine [[flag:hate]] and [[flag:self-harm/intent]]","[[flag:none]] clean"]}`
+	rr, resp := postModerations(t, config.Config{}, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	if resp.Model != "omni-moderation-latest" || !strings.HasPrefix(resp.ID, "modr_") {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	want := [][]string{nil, {"violence"}, {"hate", "self-harm/intent"}, nil}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(resp.Results))
+	}
+	for i, res := range resp.Results {
+		if res.Flagged != (len(want[i]) > 0) {
+			t.Fatalf("result %d: flagged = %t, want categories %v", i, res.Flagged, want[i])
+		}
+		if len(res.Categories) != len(mock.ModerationCategories) || len(res.CategoryScores) != len(mock.ModerationCategories) {
+			t.Fatalf("result %d: expected every category, got %+v", i, res)
+		}
+		for _, c := range mock.ModerationCategories {
+			flagged := slices.Contains(want[i], c)
+			if res.Categories[c] != flagged {
+				t.Fatalf("result %d: category %s = %t", i, c, res.Categories[c])
+			}
+			if score := res.CategoryScores[c]; flagged != (score >= 0.9) || score < 0 || score >= 1 {
+				t.Fatalf("result %d: category %s scored %v", i, c, score)
+			}
+		}
+	}
+
+	// The same input gets the same scores.
+	_, again := postModerations(t, config.Config{}, body)
+	for i := range resp.Results {
+		for c, score := range resp.Results[i].CategoryScores {
+			if again.Results[i].CategoryScores[c] != score {
+				t.Fatalf("result %d: category %s scored %v, then %v", i, c, score, again.Results[i].CategoryScores[c])
+			}
+		}
+	}
+
+	rr, resp = postModerations(t, config.Config{}, `{"model":"text-moderation-stable","input":"[[flag:sexual]]"}`)
+	if rr.Code != http.StatusOK || len(resp.Results) != 1 || !resp.Results[0].Categories["sexual"] || resp.Model != "text-moderation-stable" {
+		t.Fatalf("unexpected single input response: %d %+v", rr.Code, resp)
+	}
+}
+
+// TestModerationFlagRate verifies MODERATION_FLAG_RATE flags inputs without markers, and that
+// markers decide regardless of it.
+func TestModerationFlagRate(t *testing.T) {
+	_, resp := postModerations(t, config.Config{ModerationFlagRate: 1}, `{"input":["anything","[[flag:none]] anything"]}`)
+	if len(resp.Results) != 2 || !resp.Results[0].Flagged || resp.Results[1].Flagged {
+		t.Fatalf("unexpected results: %+v", resp.Results)
+	}
+	n := 0
+	for _, v := range resp.Results[0].Categories {
+		if v {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("expected one random category, got %+v", resp.Results[0].Categories)
+	}
+}
+
+func TestModerationsBadRequest(t *testing.T) {
+	for _, body := range []string{
+		`{}`,
+		`{"input":[]}`,
+		`{"input":42}`,
+		`{"input":"[[flag:spam]]"}`,
+	} {
+		if rr, _ := postModerations(t, config.Config{}, body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
+)
+
+// OllamaChatHandler serves POST /api/chat in Ollama's shape.
+func OllamaChatHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		var req mock.OllamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, mock.OllamaError{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+
+		chatReq := &llmv1.ChatCompletionRequest{Model: req.Model}
+		msgs := req.Messages
+		if n := len(msgs); n > 0 && msgs[n-1].Role == "user" {
+			chatReq.UserPrompt = msgs[n-1].Content
+			msgs = msgs[:n-1]
+		}
+		for _, m := range msgs {
+			if m.Role == "system" && chatReq.SystemPrompt == "" && len(chatReq.Context) == 0 {
+				chatReq.SystemPrompt = m.Content
+				continue
+			}
+			chatReq.Context = append(chatReq.Context, &llmv1.ChatMessage{Role: m.Role, Content: m.Content})
+		}
+
+		if err := checkMessages(cfg, chatReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, mock.OllamaError{Error: status.Convert(err).Message()})
+			return
+		}
+		serveOllama(w, r, start, true, req.Model, newChatPrompt(cfg, chatReq), req.Options.NumPredict, req.Stream, cfg)
+	}
+}
+
+// OllamaGenerateHandler serves POST /api/generate in Ollama's shape.
+func OllamaGenerateHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		var req mock.OllamaGenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, mock.OllamaError{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+
+		chatReq := &llmv1.ChatCompletionRequest{
+			Model:        req.Model,
+			SystemPrompt: req.System,
+			UserPrompt:   req.Prompt,
+		}
+		if err := checkMessages(cfg, chatReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, mock.OllamaError{Error: status.Convert(err).Message()})
+			return
+		}
+		prompt := newChatPrompt(cfg, chatReq)
+		serveOllama(w, r, start, false, req.Model, prompt, req.Options.NumPredict, req.Stream, cfg)
+	}
+}
+
+// serveOllama generates and writes an Ollama response. Streams are newline-delimited JSON with a final
+// done:true line; stream:false returns that final object with the full text. Timing fields are measured
+// from the delays actually slept: prompt_eval_duration covers base+jitter+TTFT, eval_duration covers decode.
+func serveOllama(w http.ResponseWriter, r *http.Request, start time.Time, chat bool, model string, prompt chatPrompt, maxTokens int, stream *bool, cfg config.Config) {
+	ctx := r.Context()
+	if model == "" {
+		model = "mock-ollama"
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultInt(cfg.DefaultTokens, 128)
+	}
+
+	setRequestModel(ctx, model)
+
+	// Error injection (before any headers are written).
+	if code := injectHTTPError(cfg); code != 0 {
+		logger.Log.Infow("[http][Ollama] injected error", "mode", cfg.ErrorMode, "status", code)
+		writeJSON(w, code, mock.OllamaError{Error: "mock error", Injected: true})
+		return
+	}
+
+	content := buildOutput(cfg, prompt.text, maxTokens)
+	pt := prompt.tokens
+	ct := mock.ApproxTokens(content)
+	svc := requestService(r.Context(), cfg)
+
+	message := func(text string) mock.OllamaResponse {
+		resp := mock.OllamaResponse{Model: model, CreatedAt: time.Now().UTC().Format(time.RFC3339Nano)}
+		if chat {
+			resp.Message = &mock.OllamaMessage{Role: "assistant", Content: text}
+		} else {
+			resp.Response = &text
+		}
+		return resp
+	}
+
+	loadDuration := time.Since(start)
+	evalStart := time.Now()
+	sleepWithContext(ctx, time.Duration(svc.preDelayMs())*time.Millisecond)
+	if ctx.Err() != nil {
+		return
+	}
+	promptEvalDuration := time.Since(evalStart)
+
+	final := func(text string, decodeStart time.Time) mock.OllamaResponse {
+		resp := message(text)
+		resp.Done = true
+		resp.DoneReason = "stop"
+		resp.TotalDuration = time.Since(start).Nanoseconds()
+		resp.LoadDuration = loadDuration.Nanoseconds()
+		resp.PromptEvalCount = pt
+		resp.PromptEvalDuration = promptEvalDuration.Nanoseconds()
+		resp.EvalCount = ct
+		resp.EvalDuration = time.Since(decodeStart).Nanoseconds()
+		return resp
+	}
+
+	if stream != nil && !*stream {
+		decodeStart := time.Now()
+		generate(ctx, cfg, ct, time.Duration(svc.generationMs(ct))*time.Millisecond)
+		if ctx.Err() != nil {
+			return
+		}
+		writeJSON(w, http.StatusOK, final(content, decodeStart))
+		reportUsage(ctx, pt, ct)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, mock.OllamaError{Error: "streaming unsupported"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	chunkSize := sseChunkSize(cfg, 0)
+	bw := bufio.NewWriter(w)
+	send := func(v mock.OllamaResponse) bool {
+		if err := writeNDJSON(bw, v); err != nil {
+			return false
+		}
+		if err := bw.Flush(); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	decodeStart := time.Now()
+	pace := newStreamPacer(cfg)
+	for i := 0; i < len(content); i += chunkSize {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		part := content[i:end]
+
+		if !send(message(part)) {
+			return
+		}
+		pace.wait(ctx, part)
+	}
+
+	if send(final("", decodeStart)) {
+		reportUsage(ctx, pt, ct)
+	}
+}
@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func TestOllamaChatStream(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(8),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+		TTFTMinMs:       config.Int(20),
+		TTFTMaxMs:       config.Int(20),
+	}
+
+	body := `{"model":"llama-mock","messages":[{"role":"system","content":"be brief"},` +
+		`{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"joke"}],` +
+		`"options":{"num_predict":10}}`
+	rr := httptest.NewRecorder()
+	OllamaChatHandler(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+
+	lines := parseOllamaLines(t, rr.Body.String())
+	last := lines[len(lines)-1]
+	if !last.Done || last.DoneReason != "stop" {
+		t.Fatalf("final line not done: %+v", last)
+	}
+
+	var assembled strings.Builder
+	for i, l := range lines[:len(lines)-1] {
+		if l.Done || l.Message == nil || l.Message.Role != "assistant" {
+			t.Fatalf("line %d is not an assistant delta: %+v", i, l)
+		}
+		assembled.WriteString(l.Message.Content)
+	}
+
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{
+		SystemPrompt: "be brief",
+		UserPrompt:   "joke",
+		Context: []*llmv1.ChatMessage{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+	})
+	expected := buildOutput(cfg, prompt, 10)
+	if assembled.String() != expected {
+		t.Fatalf("reassembled content mismatch: got len=%d expected len=%d", assembled.Len(), len(expected))
+	}
+
+	if last.PromptEvalCount != mock.ApproxTokens(prompt) || last.EvalCount != mock.ApproxTokens(expected) {
+		t.Fatalf("unexpected counts: %+v", last)
+	}
+	if last.PromptEvalDuration < (20 * time.Millisecond).Nanoseconds() {
+		t.Fatalf("prompt_eval_duration should include the simulated TTFT: %d", last.PromptEvalDuration)
+	}
+	if last.EvalDuration <= 0 || last.TotalDuration < last.PromptEvalDuration+last.EvalDuration {
+		t.Fatalf("inconsistent durations: %+v", last)
+	}
+}
+
+func TestOllamaGenerateNonStream(t *testing.T) {
+	cfg := config.Config{
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(128),
+		TokensPerSec:    config.Float(1000),
+	}
+
+	body := `{"model":"llama-mock","prompt":"why is the sky blue","stream":false,"options":{"num_predict":16}}`
+	rr := httptest.NewRecorder()
+	OllamaGenerateHandler(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	var resp mock.OllamaResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if !resp.Done || resp.Response == nil || resp.Message != nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.EvalCount != mock.ApproxTokens(*resp.Response) {
+		t.Fatalf("eval_count mismatch: %+v", resp)
+	}
+	// 16 tokens at 1000 tok/s is 16ms of decode.
+	if resp.EvalDuration < (16 * time.Millisecond).Nanoseconds() {
+		t.Fatalf("eval_duration should reflect simulated decode time: %d", resp.EvalDuration)
+	}
+}
+
+func TestOllamaInjectedError(t *testing.T) {
+	cfg := config.Config{ErrorRate: 1, ErrorMode: "429"}
+	rr := httptest.NewRecorder()
+	OllamaGenerateHandler(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(`{"prompt":"x"}`)))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	var e mock.OllamaError
+	if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil || e.Error == "" {
+		t.Fatalf("unexpected error body: %s", rr.Body.String())
+	}
+}
+
+func parseOllamaLines(t *testing.T, body string) []mock.OllamaResponse {
+	t.Helper()
+
+	var out []mock.OllamaResponse
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		var resp mock.OllamaResponse
+		if err := json.Unmarshal(sc.Bytes(), &resp); err != nil {
+			t.Fatalf("bad NDJSON line %q: %v", sc.Text(), err)
+		}
+		out = append(out, resp)
+	}
+	if len(out) < 2 {
+		t.Fatalf("expected deltas and a final line, got %d lines", len(out))
+	}
+	return out
+}
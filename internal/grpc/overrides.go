@@ -0,0 +1,210 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// mockOverrideKeys are the x-mock-* keys a request can set to override the config for itself, as
+// gRPC metadata or HTTP headers (the same keys either way). Each sets one field of mock.Overrides,
+// like the "mock" block of an HTTP body, and takes precedence over that block; the mock_overrides of
+// a gRPC request take precedence over them.
+var mockOverrideKeys = []struct {
+	key string
+	set func(o *mock.Overrides, v string) error
+}{
+	{"x-mock-base-delay-ms", func(o *mock.Overrides, v string) error { return overrideInt(&o.BaseDelayMs, v) }},
+	{"x-mock-jitter-ms", func(o *mock.Overrides, v string) error { return overrideInt(&o.JitterMs, v) }},
+	{"x-mock-per-token-delay-ms", func(o *mock.Overrides, v string) error { return overrideInt(&o.PerTokenDelayMs, v) }},
+	{"x-mock-ttft-min-ms", func(o *mock.Overrides, v string) error { return overrideInt(&o.TTFTMinMs, v) }},
+	{"x-mock-ttft-max-ms", func(o *mock.Overrides, v string) error { return overrideInt(&o.TTFTMaxMs, v) }},
+	{"x-mock-chunk-size", func(o *mock.Overrides, v string) error { return overrideInt(&o.ChunkSize, v) }},
+	{"x-mock-tokens-per-sec", func(o *mock.Overrides, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f >= 0) || math.IsInf(f, 1) {
+			return fmt.Errorf("want a finite number >= 0")
+		}
+		o.TokensPerSec = &f
+		return nil
+	}},
+	{"x-mock-error-rate", func(o *mock.Overrides, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f >= 0 && f <= 1) {
+			return fmt.Errorf("want a rate within [0, 1]")
+		}
+		o.ErrorRate = &f
+		return nil
+	}},
+	{"x-mock-error-mode", func(o *mock.Overrides, v string) error {
+		return overrideEnum(&o.ErrorMode, v, config.ErrorModes()...)
+	}},
+	{"x-mock-error-timing", func(o *mock.Overrides, v string) error {
+		return overrideEnum(&o.ErrorTiming, v, "pre", "mid", "mixed")
+	}},
+}
+
+func overrideInt(dst **int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want an integer >= 0")
+	}
+	*dst = &n
+	return nil
+}
+
+func overrideEnum(dst **string, v string, allowed ...string) error {
+	v = strings.ToLower(v)
+	if !slices.Contains(allowed, v) {
+		return fmt.Errorf("want one of %s", strings.Join(allowed, ", "))
+	}
+	*dst = &v
+	return nil
+}
+
+// parseMockOverrides reads the x-mock-* keys with get, which returns "" for a key that isn't set. It
+// returns the overrides, nil when no valid key is set, and the keys whose values it ignored, each as
+// "key=value", after logging them.
+func parseMockOverrides(get func(key string) string) (*mock.Overrides, []string) {
+	var o mock.Overrides
+	var set bool
+	var invalid []string
+	for _, k := range mockOverrideKeys {
+		v := strings.TrimSpace(get(k.key))
+		if v == "" {
+			continue
+		}
+		if err := k.set(&o, v); err != nil {
+			logger.Log.Warnw("[overrides] ignoring invalid override", "key", k.key, "value", v, "err", err)
+			invalid = append(invalid, k.key+"="+v)
+			continue
+		}
+		set = true
+	}
+	if !set {
+		return nil, invalid
+	}
+	return &o, invalid
+}
+
+// overridesAllowed reports whether requests may override cfg for themselves (ALLOW_REQUEST_OVERRIDES).
+func overridesAllowed(cfg config.Config) bool {
+	return config.Or(cfg.AllowRequestOverrides, true)
+}
+
+// protoOverrides returns the mock_overrides of a gRPC request as mock.Overrides, nil when it has none.
+func protoOverrides(m *llmv1.MockOverrides) *mock.Overrides {
+	if m == nil {
+		return nil
+	}
+	optInt := func(v *int32) *int {
+		if v == nil {
+			return nil
+		}
+		return config.Int(int(*v))
+	}
+	return &mock.Overrides{
+		BaseDelayMs:     optInt(m.BaseDelayMs),
+		JitterMs:        optInt(m.JitterMs),
+		PerTokenDelayMs: optInt(m.PerTokenDelayMs),
+		ErrorRate:       m.ErrorRate,
+		ErrorMode:       m.ErrorMode,
+		ChunkSize:       optInt(m.ChunkSize),
+		TTFTMinMs:       optInt(m.TtftMinMs),
+		TTFTMaxMs:       optInt(m.TtftMaxMs),
+		TokensPerSec:    m.TokensPerSec,
+	}
+}
+
+// applyRequestOverrides applies the overrides of an RPC to s.cfg before anything is drawn: its
+// x-mock-* metadata, then its mock_overrides, which win, and gives it its own source of draws when
+// mock_overrides sets a seed. Both are recorded in s.debug. Metadata values out of range are ignored
+// (see parseMockOverrides); mock_overrides ones fail the RPC with InvalidArgument, checked like the
+// config settings they replace. With ALLOW_REQUEST_OVERRIDES off, s is left as is.
+func (s *MockLlmService) applyRequestOverrides(ctx context.Context, req *llmv1.ChatCompletionRequest) error {
+	if !overridesAllowed(s.cfg) {
+		return nil
+	}
+	o, invalid := metadataOverrides(ctx)
+	s.cfg = applyOverrides(s.cfg, o)
+	recordOverrides(s.debug, o, invalid)
+
+	if m := req.GetMockOverrides(); m != nil {
+		po := protoOverrides(m)
+		if errs := config.Validate(applyOverrides(config.Config{}, po)); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid mock_overrides: %v", errors.Join(errs...))
+		}
+		s.cfg = applyOverrides(s.cfg, po)
+		if m.Seed != nil {
+			s.rng = mock.NewRand(m.GetSeed())
+		}
+		if s.debug != nil {
+			s.debug.Overrides = append(s.debug.Overrides, overrideRequest)
+		}
+	}
+	if s.debug != nil {
+		s.debug.BaseDelayMs = s.cfg.BaseDelayMs
+	}
+	return nil
+}
+
+// metadataOverrides returns the x-mock-* overrides of an RPC (see parseMockOverrides).
+func metadataOverrides(ctx context.Context) (*mock.Overrides, []string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return parseMockOverrides(func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	})
+}
+
+// headerOverrides returns the x-mock-* overrides of an HTTP request (see parseMockOverrides).
+func headerOverrides(r *http.Request) (*mock.Overrides, []string) {
+	return parseMockOverrides(r.Header.Get)
+}
+
+// recordOverrides adds the x-mock-* overrides of a request, and the values it ignored, to its debug
+// record.
+func recordOverrides(d *mock.Debug, o *mock.Overrides, invalid []string) {
+	if d == nil {
+		return
+	}
+	if o != nil {
+		d.Overrides = append(d.Overrides, overrideHeader)
+	}
+	d.InvalidOverrides = append(d.InvalidOverrides, invalid...)
+}
+
+type overridesCtxKey struct{}
+
+// withHeaderOverrides returns r carrying its x-mock-* overrides, for handlers that apply the "mock"
+// block of a body after them (see requestOverrides).
+func withHeaderOverrides(r *http.Request, o *mock.Overrides) *http.Request {
+	if o == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), overridesCtxKey{}, o))
+}
+
+// requestOverrides returns the x-mock-* overrides of an HTTP request, nil when it set none. Handlers
+// re-apply them after the body's "mock" block, so the headers win.
+func requestOverrides(ctx context.Context) *mock.Overrides {
+	o, _ := ctx.Value(overridesCtxKey{}).(*mock.Overrides)
+	return o
+}
@@ -0,0 +1,283 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func overridesConfig() config.Config {
+	return config.Config{
+		ChunkSize:       config.Int(16),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+	}
+}
+
+// serveWithHeaders sends a streaming chat.completions POST with body through the live mux, with
+// the given headers, and returns the recorder and how long the request took.
+func serveWithHeaders(t *testing.T, body string, headers map[string]string) (*httptest.ResponseRecorder, time.Duration) {
+	t.Helper()
+	mux := NewLiveHTTPMux(NewMockLlmService(overridesConfig()), nil, nil, nil)
+	r := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	mux.ServeHTTP(rr, r)
+	return rr, time.Since(start)
+}
+
+const overridesBody = `{"stream":true,"max_tokens":20,"messages":[{"role":"user","content":"override me"}]}`
+
+// maxDelta returns the size of the largest content delta of an SSE body.
+func maxDelta(t *testing.T, body string) int {
+	t.Helper()
+	n := 0
+	for _, ch := range parseSSE(t, strings.TrimSpace(body)).chunks {
+		if len(ch.Choices) > 0 {
+			n = max(n, len(ch.Choices[0].Delta.Content))
+		}
+	}
+	return n
+}
+
+func TestMockOverrideHeaders(t *testing.T) {
+	t.Run("error-rate", func(t *testing.T) {
+		rr, _ := serveWithHeaders(t, overridesBody, map[string]string{"x-mock-error-rate": "1"})
+		if rr.Code < 400 || !strings.Contains(rr.Body.String(), `"injected"`) {
+			t.Fatalf("expected an injected error, got %d\n%s", rr.Code, rr.Body.String())
+		}
+	})
+	t.Run("error-mode", func(t *testing.T) {
+		for mode, code := range map[string]int{"429": http.StatusTooManyRequests, "500": http.StatusInternalServerError, "rate limit": http.StatusTooManyRequests, "server_error": http.StatusInternalServerError} {
+			rr, _ := serveWithHeaders(t, overridesBody, map[string]string{"x-mock-error-rate": "1", "x-mock-error-mode": mode})
+			if rr.Code != code {
+				t.Fatalf("x-mock-error-mode %s: expected %d, got %d\n%s", mode, code, rr.Code, rr.Body.String())
+			}
+		}
+	})
+	t.Run("chunk-size", func(t *testing.T) {
+		rr, _ := serveWithHeaders(t, overridesBody, map[string]string{"x-mock-chunk-size": "3"})
+		if n := maxDelta(t, rr.Body.String()); n != 3 {
+			t.Fatalf("largest delta has %d chars, want 3", n)
+		}
+	})
+	t.Run("ttft-min-ms", func(t *testing.T) {
+		if _, took := serveWithHeaders(t, overridesBody, nil); took >= 80*time.Millisecond {
+			t.Fatalf("baseline took %v, too slow to measure the override", took)
+		}
+		if _, took := serveWithHeaders(t, overridesBody, map[string]string{"x-mock-ttft-min-ms": "80"}); took < 80*time.Millisecond {
+			t.Fatalf("x-mock-ttft-min-ms 80: request took %v", took)
+		}
+	})
+	t.Run("tokens-per-sec", func(t *testing.T) {
+		// 20 tokens at 100/s take about 200ms.
+		if _, took := serveWithHeaders(t, overridesBody, map[string]string{"x-mock-tokens-per-sec": "100"}); took < 150*time.Millisecond {
+			t.Fatalf("x-mock-tokens-per-sec 100: request took %v", took)
+		}
+	})
+}
+
+// TestMockOverridePrecedence checks the x-mock-* headers win over the body's mock block, field by
+// field: the block still sets what the headers leave alone.
+func TestMockOverridePrecedence(t *testing.T) {
+	body := `{"stream":true,"max_tokens":20,"messages":[{"role":"user","content":"override me"}],` +
+		`"mock":{"chunk_size":5,"ttft_min_ms":80}}`
+	rr, took := serveWithHeaders(t, body, map[string]string{"x-mock-chunk-size": "2"})
+	if n := maxDelta(t, rr.Body.String()); n != 2 {
+		t.Fatalf("largest delta has %d chars, want the header's 2", n)
+	}
+	if took < 80*time.Millisecond {
+		t.Fatalf("the mock block's ttft_min_ms was dropped: request took %v", took)
+	}
+
+	body = `{"stream":true,"max_tokens":8,"messages":[{"role":"user","content":"hi"}],"mock":{"error_rate":1,"error_mode":"429"}}`
+	rr, _ = serveWithHeaders(t, body, map[string]string{"x-mock-error-mode": "500"})
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the header's 500 over the block's 429, got %d", rr.Code)
+	}
+}
+
+// TestMockOverridesNotAllowed checks ALLOW_REQUEST_OVERRIDES=false ignores both the headers and the
+// body's mock block.
+func TestMockOverridesNotAllowed(t *testing.T) {
+	cfg := overridesConfig()
+	cfg.AllowRequestOverrides = config.Bool(false)
+	mux := NewLiveHTTPMux(NewMockLlmService(cfg), nil, nil, nil)
+	body := `{"stream":true,"max_tokens":20,"messages":[{"role":"user","content":"override me"}],"mock":{"error_rate":1}}`
+	r := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	r.Header.Set("x-mock-chunk-size", "3")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("the mock block was applied: %d\n%s", rr.Code, rr.Body.String())
+	}
+	if n := maxDelta(t, rr.Body.String()); n != 16 {
+		t.Fatalf("largest delta has %d chars, want the config's 16", n)
+	}
+}
+
+func TestMockOverrideInvalid(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.WarnLevel)
+	rr, _ := serveWithHeaders(t, `{"max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`, map[string]string{
+		debugKey:               "true",
+		"x-mock-error-rate":    "2",
+		"x-mock-chunk-size":    "-1",
+		"x-mock-error-mode":    "teapot",
+		"x-mock-base-delay-ms": "1",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("invalid overrides should be ignored, got %d\n%s", rr.Code, rr.Body.String())
+	}
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Debug == nil {
+		t.Fatalf("expected a debug field: %v\n%s", err, rr.Body.String())
+	}
+	want := []string{"x-mock-chunk-size=-1", "x-mock-error-rate=2", "x-mock-error-mode=teapot"}
+	if d := resp.Debug; !slices.Equal(d.InvalidOverrides, want) || !slices.Equal(d.Overrides, []string{overrideHeader}) || d.BaseDelayMs != 1 {
+		t.Fatalf("unexpected debug field: %+v", d)
+	}
+	if n := logs.FilterMessage("[overrides] ignoring invalid override").Len(); n != 3 {
+		t.Fatalf("logged %d invalid overrides, want 3", n)
+	}
+
+	// Without debug the invalid values are only logged.
+	rr, _ = serveWithHeaders(t, `{"max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`, map[string]string{"x-mock-error-rate": "nan"})
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "invalid_overrides") {
+		t.Fatalf("unexpected response %d\n%s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestMockOverrideMetadata checks gRPC reads the same keys from metadata, and reports them in the
+// debug trailer.
+func TestMockOverrideMetadata(t *testing.T) {
+	md := metadata.Pairs(debugKey, "true", "x-mock-chunk-size", "3", "x-mock-ttft-max-ms", "soon")
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), md))
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "override me", MaxTokens: 20}
+	if err := NewMockLlmService(overridesConfig()).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	for _, c := range fs.Chunks() {
+		if len(c.GetText()) > 3 {
+			t.Fatalf("delta %q exceeds x-mock-chunk-size", c.GetText())
+		}
+	}
+	d := parseDebug(t, fs.Trailer())
+	if d.ChunkSize != 3 || !slices.Equal(d.Overrides, []string{overrideHeader}) || !slices.Equal(d.InvalidOverrides, []string{"x-mock-ttft-max-ms=soon"}) {
+		t.Fatalf("unexpected debug trailer: %+v", d)
+	}
+}
+
+// TestRequestOverridesPrecedence checks each layer of a gRPC request's config wins over the ones
+// below it: mock_overrides over x-mock-* metadata over the config, where PRESET fills what the
+// environment leaves unset; and that ALLOW_REQUEST_OVERRIDES=false turns the top two off.
+func TestRequestOverridesPrecedence(t *testing.T) {
+	base := config.Config{
+		Preset:           "vllm", // chunk size 48, TTFT 30-200ms
+		BaseDelayMs:      1,
+		TokensPerSec:     config.Float(0),
+		StreamDelayMaxMs: config.Int(0),
+	}
+	config.ApplyPresetOverrides(&base)
+	md := metadata.Pairs("x-mock-chunk-size", "8", "x-mock-ttft-min-ms", "5", "x-mock-ttft-max-ms", "5")
+	po := &llmv1.MockOverrides{ChunkSize: proto.Int32(4), BaseDelayMs: proto.Int32(3)}
+
+	for _, tc := range []struct {
+		name      string
+		allow     *bool
+		md        metadata.MD
+		overrides *llmv1.MockOverrides
+		chunkSize int
+		baseDelay int
+		ttft      [2]int
+		sources   []string
+	}{
+		{"config", nil, nil, nil, 48, 1, [2]int{30, 200}, nil},
+		{"metadata", nil, md, nil, 8, 1, [2]int{5, 5}, []string{overrideHeader}},
+		{"mock_overrides", nil, nil, po, 4, 3, [2]int{30, 200}, []string{overrideRequest}},
+		{"mock_overrides over metadata", nil, md, po, 4, 3, [2]int{5, 5}, []string{overrideHeader, overrideRequest}},
+		{"not allowed", config.Bool(false), md, po, 48, 1, [2]int{30, 200}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := base
+			cfg.AllowRequestOverrides = tc.allow
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Join(metadata.Pairs(debugKey, "true"), tc.md))
+			fs := llmtest.NewServerStream(ctx)
+			req := &llmv1.ChatCompletionRequest{UserPrompt: "layers", MaxTokens: 8, MockOverrides: tc.overrides}
+			if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+			d := parseDebug(t, fs.Trailer())
+			if d.ChunkSize != tc.chunkSize || d.BaseDelayMs != tc.baseDelay || d.TTFTMs < tc.ttft[0] || d.TTFTMs > tc.ttft[1] {
+				t.Fatalf("chunk size %d, base delay %d, TTFT %d; want %d, %d, within %v", d.ChunkSize, d.BaseDelayMs, d.TTFTMs, tc.chunkSize, tc.baseDelay, tc.ttft)
+			}
+			if !slices.Equal(d.Overrides, tc.sources) {
+				t.Fatalf("override sources %v, want %v", d.Overrides, tc.sources)
+			}
+		})
+	}
+}
+
+func TestRequestOverridesInvalid(t *testing.T) {
+	svc := NewMockLlmService(overridesConfig())
+	for _, o := range []*llmv1.MockOverrides{
+		{ErrorRate: proto.Float64(2)},
+		{ErrorMode: proto.String("teapot")},
+		{ChunkSize: proto.Int32(-1)},
+	} {
+		req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8, MockOverrides: o}
+		_, err := svc.ChatCompletion(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "mock_overrides") {
+			t.Fatalf("%v: expected InvalidArgument, got %v", o, err)
+		}
+		if err := svc.ChatCompletionStream(req, llmtest.NewServerStream(context.Background())); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument from the stream, got %v", o, err)
+		}
+	}
+}
+
+// TestRequestOverridesSeed checks a seeded request draws the same values whatever the shared source
+// holds, and leaves the shared source alone.
+func TestRequestOverridesSeed(t *testing.T) {
+	run := func(sharedSeed int64) mock.Debug {
+		mock.Seed(sharedSeed)
+		fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs(debugKey, "true")))
+		req := &llmv1.ChatCompletionRequest{UserPrompt: "seeded", MaxTokens: 200, MockOverrides: &llmv1.MockOverrides{Seed: proto.Int64(42)}}
+		if err := NewMockLlmService(debugConfig()).ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		return parseDebug(t, fs.Trailer())
+	}
+	first := run(1)
+	for shared := int64(2); shared < 6; shared++ {
+		if d := run(shared); !equalDebug(d, first) {
+			t.Fatalf("shared seed %d changed the seeded request: %+v, want %+v", shared, d, first)
+		}
+	}
+
+	mock.Seed(7)
+	want := mock.RandIntn(1 << 30)
+	run(7)
+	if got := mock.RandIntn(1 << 30); got != want {
+		t.Fatalf("a seeded request drew from the shared source")
+	}
+}
@@ -0,0 +1,116 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// priorityKey is the metadata (or HTTP header) setting the priority of a request: high, normal or
+// low, or an integer, where above 0 is high and below 0 low. The priority scales the simulated queue
+// delay (base + jitter) by PRIORITY_HIGH_QUEUE_FACTOR or PRIORITY_LOW_QUEUE_FACTOR; without a queue
+// delay configured it changes nothing.
+const priorityKey = "x-priority"
+
+// priority is the class of a request, normal when it didn't set one.
+type priority int
+
+const (
+	priorityNormal priority = iota
+	priorityHigh
+	priorityLow
+)
+
+func (p priority) String() string {
+	switch p {
+	case priorityHigh:
+		return "high"
+	case priorityLow:
+		return "low"
+	}
+	return "normal"
+}
+
+// parsePriority parses an x-priority value; "" is normal.
+func parsePriority(v string) (priority, error) {
+	switch v = strings.ToLower(strings.TrimSpace(v)); v {
+	case "", "normal":
+		return priorityNormal, nil
+	case "high":
+		return priorityHigh, nil
+	case "low":
+		return priorityLow, nil
+	}
+	n, err := strconv.Atoi(v)
+	switch {
+	case err != nil:
+		return priorityNormal, fmt.Errorf("want high, normal, low or an integer")
+	case n > 0:
+		return priorityHigh, nil
+	case n < 0:
+		return priorityLow, nil
+	}
+	return priorityNormal, nil
+}
+
+// priorityOf parses v, logging an invalid value and running the request at normal priority.
+func priorityOf(v string) priority {
+	p, err := parsePriority(v)
+	if err != nil {
+		logger.Log.Warnw("[priority] ignoring invalid priority", "key", priorityKey, "value", v, "err", err)
+	}
+	return p
+}
+
+// queueMs scales ms, a queue delay, by the factor cfg gives p.
+func (p priority) queueMs(cfg config.Config, ms int) int {
+	f := 1.0
+	switch p {
+	case priorityHigh:
+		f = cfg.PriorityHighQueueFactor
+	case priorityLow:
+		f = config.Or(cfg.PriorityLowQueueFactor, 2)
+	}
+	return int(math.Round(float64(ms) * f))
+}
+
+// grpcPriority returns the priority of an RPC from its x-priority metadata.
+func grpcPriority(ctx context.Context) priority {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(priorityKey); len(v) > 0 {
+		return priorityOf(v[0])
+	}
+	return priorityNormal
+}
+
+type priorityCtxKey struct{}
+
+// withPriority returns r carrying the priority of its x-priority header. liveHandler sets it up.
+func withPriority(r *http.Request) *http.Request {
+	v := r.Header.Get(priorityKey)
+	if v == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), priorityCtxKey{}, priorityOf(v)))
+}
+
+// requestPriority returns the priority of an HTTP request.
+func requestPriority(ctx context.Context) priority {
+	p, _ := ctx.Value(priorityCtxKey{}).(priority)
+	return p
+}
+
+// requestService returns a service simulating an HTTP request on cfg, at the request's priority.
+func requestService(ctx context.Context, cfg config.Config) *MockLlmService {
+	s := NewMockLlmService(cfg)
+	s.priority = requestPriority(ctx)
+	return s
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func priorityConfig(baseDelayMs int) config.Config {
+	return config.Config{
+		BaseDelayMs:     baseDelayMs,
+		ChunkSize:       config.Int(16),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(64),
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for v, want := range map[string]priority{
+		"":       priorityNormal,
+		"high":   priorityHigh,
+		" LOW ":  priorityLow,
+		"normal": priorityNormal,
+		"5":      priorityHigh,
+		"0":      priorityNormal,
+		"-1":     priorityLow,
+	} {
+		if p, err := parsePriority(v); err != nil || p != want {
+			t.Fatalf("parsePriority(%q) = %v, %v; want %v", v, p, err, want)
+		}
+	}
+	if _, err := parsePriority("urgent"); err == nil {
+		t.Fatalf("expected an error for an unknown priority")
+	}
+}
+
+// streamTTFT runs a stream at priority p on svc and returns how long its first chunk took, and its
+// debug trailer.
+func streamTTFT(t *testing.T, svc *MockLlmService, p string) (time.Duration, metadata.MD) {
+	t.Helper()
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs(priorityKey, p, debugKey, "true")))
+	var first time.Time
+	fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+		if first.IsZero() {
+			first = time.Now()
+		}
+	}
+	start := time.Now()
+	if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "queue me", MaxTokens: 8}, fs); err != nil {
+		t.Errorf("ChatCompletionStream err: %v", err)
+	}
+	return first.Sub(start), fs.Trailer()
+}
+
+// TestPriorityQueueDelay starts a high and a low priority stream together under a 60ms queue delay:
+// the high one skips the queue and the low one waits twice as long, with the default factors.
+func TestPriorityQueueDelay(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	svc := NewMockLlmService(priorityConfig(60))
+	ttft := map[string]time.Duration{}
+	trailers := map[string]metadata.MD{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range []string{"high", "low"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, tr := streamTTFT(t, svc, p)
+			mu.Lock()
+			ttft[p], trailers[p] = d, tr
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if ttft["high"] >= 40*time.Millisecond || ttft["low"] < 120*time.Millisecond {
+		t.Fatalf("TTFT high %v, low %v; want under 40ms and at least 120ms", ttft["high"], ttft["low"])
+	}
+	for p, tr := range trailers {
+		if d := parseDebug(t, tr); d.Priority != p {
+			t.Fatalf("debug trailer of the %s stream has priority %q", p, d.Priority)
+		}
+	}
+	var logged []any
+	for _, e := range logs.FilterMessage("[grpc][ChatCompletionStream] start").All() {
+		logged = append(logged, e.ContextMap()["priority"])
+	}
+	if len(logged) != 2 || logged[0] == logged[1] {
+		t.Fatalf("start lines logged priorities %v", logged)
+	}
+
+	// The factors are configurable.
+	cfg := priorityConfig(60)
+	cfg.PriorityHighQueueFactor, cfg.PriorityLowQueueFactor = 0.5, config.Float(1)
+	if d, _ := streamTTFT(t, NewMockLlmService(cfg), "1"); d < 30*time.Millisecond || d >= 60*time.Millisecond {
+		t.Fatalf("high priority at factor 0.5 took %v, want about 30ms", d)
+	}
+	if d, _ := streamTTFT(t, NewMockLlmService(cfg), "-1"); d < 60*time.Millisecond {
+		t.Fatalf("low priority at factor 1 took %v, want at least 60ms", d)
+	}
+}
+
+// TestPriorityInert checks a priority changes nothing without a queue delay, and that an invalid one
+// runs the request at normal priority.
+func TestPriorityInert(t *testing.T) {
+	svc := NewMockLlmService(priorityConfig(0))
+	if d, tr := streamTTFT(t, svc, "low"); d >= 40*time.Millisecond || parseDebug(t, tr).Priority != "low" {
+		t.Fatalf("low priority without a queue delay took %v", d)
+	}
+	logs := observeLogsAt(t, zapcore.WarnLevel)
+	if _, tr := streamTTFT(t, svc, "urgent"); parseDebug(t, tr).Priority != "normal" {
+		t.Fatalf("an invalid priority wasn't run as normal")
+	}
+	if logs.FilterMessage("[priority] ignoring invalid priority").Len() != 1 {
+		t.Fatalf("the invalid priority wasn't logged")
+	}
+}
+
+func TestPriorityHTTP(t *testing.T) {
+	mux := NewLiveHTTPMux(NewMockLlmService(priorityConfig(60)), nil, nil, nil)
+	r := httptest.NewRequest("GET", "/v1/chat/completions?prompt=hi&max_tokens=8&debug=1", nil)
+	r.Header.Set(priorityKey, "high")
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	mux.ServeHTTP(rr, r)
+	if took := time.Since(start); took >= 40*time.Millisecond || !strings.Contains(rr.Body.String(), `"priority":"high"`) {
+		t.Fatalf("high priority SSE took %v:\n%s", took, rr.Body.String())
+	}
+}
@@ -0,0 +1,208 @@
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
+)
+
+// promptSummaryRunes is how much of the prompt a record keeps without RECORD_PROMPTS.
+const promptSummaryRunes = 80
+
+// maxRecordChunkTimes bounds the chunk timestamps a stream's record keeps, so an ENDLESS_STREAM
+// doesn't grow its record without limit. Chunks still counts every chunk.
+const maxRecordChunkTimes = 1024
+
+// Record is one line of RECORD_FILE: what the simulator saw and did for a completed gRPC chat
+// request (ChatCompletion and ChatCompletionStream; the HTTP endpoints aren't recorded). Durations
+// are in milliseconds; phases a request never reached are 0.
+type Record struct {
+	Time         time.Time `json:"ts"`
+	RequestID    string    `json:"request_id"`
+	Method       string    `json:"method"`
+	Model        string    `json:"model"`
+	Prompt       string    `json:"prompt"` // the first promptSummaryRunes runes, all of it with RECORD_PROMPTS, a summary with REDACT_PROMPTS
+	PromptChars  int       `json:"prompt_chars"`
+	MaxTokens    int       `json:"max_tokens"`
+	Code         string    `json:"code"`
+	Error        string    `json:"error,omitempty"`
+	Injected     bool      `json:"injected"`
+	OutputChars  int       `json:"output_chars"`
+	PromptTokens int       `json:"prompt_tokens"`
+	OutputTokens int       `json:"completion_tokens"`
+	QueueMs      int64     `json:"queue_ms"`
+	PromptEvalMs int64     `json:"prompt_eval_ms"`
+	TTFTMs       int64     `json:"ttft_ms"`
+	GenerationMs int64     `json:"generation_ms"`
+	LatencyMs    int64     `json:"latency_ms"`
+
+	// Streams only: the content chunks sent and when the first maxRecordChunkTimes of them went out.
+	Chunks       int     `json:"chunks,omitempty"`
+	ChunkSentAts []int64 `json:"chunk_sent_at_unix_ms,omitempty"`
+}
+
+// Recorder appends Records to RECORD_FILE as JSON lines. Add never blocks: records wait in a
+// buffered channel for a single writer goroutine, and are dropped (and counted) when it is full.
+type Recorder struct {
+	ch      chan *Record
+	done    chan struct{}
+	f       *os.File
+	dropped atomic.Int64
+
+	mu     sync.RWMutex // guards closed against Add racing Close
+	closed bool
+}
+
+// NewRecorder opens path for appending and starts the writer, queueing up to buffer records.
+func NewRecorder(path string, buffer int) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{ch: make(chan *Record, buffer), done: make(chan struct{}), f: f}
+	go r.write()
+	return r, nil
+}
+
+func (r *Recorder) write() {
+	defer close(r.done)
+	w := bufio.NewWriter(r.f)
+	enc := json.NewEncoder(w)
+	for rec := range r.ch {
+		if err := enc.Encode(rec); err != nil {
+			logger.Log.Warnw("[record] failed to write record", "err", err)
+		}
+		// Flush whenever the queue is drained, so the file stays current without a write per record.
+		if len(r.ch) == 0 {
+			_ = w.Flush()
+		}
+	}
+	_ = w.Flush()
+}
+
+// Add queues rec for writing. A nil Recorder records nothing.
+func (r *Recorder) Add(rec *Record) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return // a request that outlived shutdown
+	}
+	select {
+	case r.ch <- rec:
+	default:
+		r.dropped.Add(1)
+		metrics.RecordsDropped.Inc()
+	}
+}
+
+// Dropped returns how many records were dropped because the buffer was full.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close writes the queued records and closes the file. Records added afterwards are ignored.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.ch)
+	r.mu.Unlock()
+
+	<-r.done
+	logger.Log.Infow("[record] closed", "path", r.f.Name(), "dropped", r.Dropped())
+	return r.f.Close()
+}
+
+// SetRecorder makes the service record every completed gRPC chat request to rec (nil disables);
+// requests to the HTTP endpoints aren't recorded. It must be called before serving.
+func (s *MockLlmService) SetRecorder(rec *Recorder) {
+	s.rec = rec
+}
+
+// newRecord starts the record of a request, or returns nil when the service doesn't record.
+func (s *MockLlmService) newRecord(ctx context.Context, method string, req *llmv1.ChatCompletionRequest, start time.Time) *Record {
+	if s.rec == nil {
+		return nil
+	}
+	// A request over the message limits is rejected before its prompt is built; so is its record's.
+	var prompt string
+	if checkMessages(s.cfg, req) == nil {
+		prompt = buildPromptForTokens(req)
+	}
+	return &Record{
+		Time:        start.UTC(),
+		RequestID:   s.requestID,
+		Method:      method,
+		Model:       req.GetModel(),
+		Prompt:      summarizePrompt(s.cfg, prompt),
+		PromptChars: len([]rune(prompt)),
+		MaxTokens:   int(req.GetMaxTokens()),
+	}
+}
+
+// finishRecord completes rec with the outcome of the request and queues it.
+func (s *MockLlmService) finishRecord(rec *Record, start time.Time, err error) {
+	if rec == nil {
+		return
+	}
+	st := status.Convert(err)
+	rec.Code = st.Code().String()
+	if err != nil {
+		rec.Error = st.Message()
+		rec.Injected = isInjected(st)
+	}
+	rec.LatencyMs = time.Since(start).Milliseconds()
+	s.rec.Add(rec)
+}
+
+// The setters below do nothing on a nil Record, so the handlers can call them unconditionally.
+
+// output sets the token counts and output length of the request.
+func (r *Record) output(promptTokens, completionTokens, outputChars int) {
+	if r != nil {
+		r.PromptTokens, r.OutputTokens, r.OutputChars = promptTokens, completionTokens, outputChars
+	}
+}
+
+// phases sets the measured latency phases of the request.
+func (r *Record) phases(queue, prefill, ttft, generation time.Duration) {
+	if r != nil {
+		r.QueueMs, r.PromptEvalMs = queue.Milliseconds(), prefill.Milliseconds()
+		r.TTFTMs, r.GenerationMs = ttft.Milliseconds(), generation.Milliseconds()
+	}
+}
+
+// chunkSent counts a content chunk sent at t, keeping its time while under maxRecordChunkTimes.
+func (r *Record) chunkSent(t time.Time) {
+	if r != nil {
+		r.Chunks++
+		if len(r.ChunkSentAts) < maxRecordChunkTimes {
+			r.ChunkSentAts = append(r.ChunkSentAts, t.UnixMilli())
+		}
+	}
+}
+
+func summarizePrompt(cfg config.Config, prompt string) string {
+	if cfg.RecordPrompts {
+		return logger.Text(prompt, 0, cfg.RedactPrompts)
+	}
+	return logger.Text(prompt, promptSummaryRunes, cfg.RedactPrompts)
+}
@@ -0,0 +1,208 @@
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// readRecords decodes every line of a RECORD_FILE both as a Record and as a plain object, so the
+// test sees the field names clients parse.
+func readRecords(t *testing.T, path string) ([]Record, []map[string]any) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []Record
+	var raw []map[string]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Record
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("bad record %q: %v", sc.Text(), err)
+		}
+		_ = json.Unmarshal(sc.Bytes(), &m)
+		recs, raw = append(recs, r), append(raw, m)
+	}
+	return recs, raw
+}
+
+func TestRecordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	rec, err := NewRecorder(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), ChunkTimestamps: true}
+	svc := NewMockLlmService(cfg)
+	svc.SetRecorder(rec)
+
+	long := strings.Repeat("long prompt ", 20)
+	req := &llmv1.ChatCompletionRequest{Model: "m-record", UserPrompt: long, MaxTokens: 16}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-unary"))
+	resp, err := svc.ChatCompletion(ctx, req)
+	if err != nil {
+		t.Fatalf("unary failed: %v", err)
+	}
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-stream")))
+	if err := svc.ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	svc.Config().Update(func(c *config.Config) { c.ErrorRate, c.ErrorMode, c.RecordPrompts = 1, "429", true })
+	if _, err := svc.ChatCompletion(context.Background(), req); err == nil {
+		t.Fatal("expected an injected error")
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	rec.Add(&Record{}) // after Close: ignored
+
+	recs, raw := readRecords(t, path)
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(recs))
+	}
+	for i, m := range raw {
+		for _, k := range []string{"ts", "request_id", "method", "model", "prompt", "prompt_chars", "max_tokens", "code", "injected",
+			"output_chars", "prompt_tokens", "completion_tokens", "queue_ms", "prompt_eval_ms", "ttft_ms", "generation_ms", "latency_ms"} {
+			if _, ok := m[k]; !ok {
+				t.Fatalf("record %d misses %q: %v", i, k, m)
+			}
+		}
+	}
+
+	unary, stream, failed := recs[0], recs[1], recs[2]
+	if unary.RequestID != "req-unary" || unary.Method != "ChatCompletion" || unary.Model != "m-record" || unary.Code != "OK" || unary.Injected {
+		t.Fatalf("unexpected unary record: %+v", unary)
+	}
+	if unary.PromptTokens != int(resp.GetPromptTokens()) || unary.OutputTokens != int(resp.GetCompletionTokens()) || unary.OutputChars != len(resp.GetOutputText()) || unary.MaxTokens != 16 {
+		t.Fatalf("unary record doesn't match the response: %+v", unary)
+	}
+	if unary.PromptChars != len(buildPromptForTokens(req)) || len([]rune(unary.Prompt)) != promptSummaryRunes+1 || unary.Chunks != 0 {
+		t.Fatalf("unary prompt should be summarized: %+v", unary)
+	}
+
+	done := fs.Chunks()[len(fs.Chunks())-1]
+	if stream.RequestID != "req-stream" || stream.Method != "ChatCompletionStream" || stream.Code != "OK" || stream.OutputTokens != int(done.GetCompletionTokens()) {
+		t.Fatalf("unexpected stream record: %+v", stream)
+	}
+	var sentAt []int64
+	for _, c := range fs.Chunks()[:len(fs.Chunks())-1] {
+		sentAt = append(sentAt, c.GetEmittedAtUnixMs())
+	}
+	if stream.Chunks != len(sentAt) || len(stream.ChunkSentAts) != stream.Chunks || !slices.IsSorted(stream.ChunkSentAts) {
+		t.Fatalf("expected %d chunk timestamps, got %+v", len(sentAt), stream)
+	}
+	if d := stream.ChunkSentAts[0] - sentAt[0]; d < -1 || d > 1 {
+		t.Fatalf("first chunk recorded at %d, emitted at %d", stream.ChunkSentAts[0], sentAt[0])
+	}
+
+	if failed.Code != "ResourceExhausted" || !failed.Injected || failed.Error == "" || failed.Prompt != buildPromptForTokens(req) {
+		t.Fatalf("unexpected failed record: %+v", failed)
+	}
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	// No writer: the buffer fills up and stays full.
+	r := &Recorder{ch: make(chan *Record, 2)}
+	for range 5 {
+		r.Add(&Record{})
+	}
+	if r.Dropped() != 3 {
+		t.Fatalf("expected 3 drops, got %d", r.Dropped())
+	}
+	var nilRecorder *Recorder
+	nilRecorder.Add(&Record{}) // no-op
+}
+
+func TestRecordChunkTimesCapped(t *testing.T) {
+	r := &Record{}
+	now := time.Now()
+	for i := 0; i < maxRecordChunkTimes+10; i++ {
+		r.chunkSent(now)
+	}
+	if r.Chunks != maxRecordChunkTimes+10 || len(r.ChunkSentAts) != maxRecordChunkTimes {
+		t.Fatalf("expected %d chunks and %d times, got %d and %d", maxRecordChunkTimes+10, maxRecordChunkTimes, r.Chunks, len(r.ChunkSentAts))
+	}
+}
+
+func TestRedactPrompts(t *testing.T) {
+	const secret = "my card number is 4111-1111"
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	rec, err := NewRecorder(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{ChunkSize: config.Int(16), MaxOutputChars: config.Int(64), RedactPrompts: true, RecordPrompts: true}
+	svc := NewMockLlmService(cfg)
+	svc.SetRecorder(rec)
+
+	req := &llmv1.ChatCompletionRequest{UserPrompt: secret}
+	if _, err := svc.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("unary failed: %v", err)
+	}
+	if err := svc.ChatCompletionStream(req, llmtest.NewServerStream(context.Background())); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	mux := NewLiveHTTPMux(svc, nil, nil, nil)
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/chat/completions?prompt="+url.QueryEscape(secret), nil),
+		httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"`+secret+`"}]}`)),
+		httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true,"messages":[{"role":"user","content":"`+secret+`"}]}`)),
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d", r.Method, r.URL, rr.Code)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	starts := 0
+	for _, e := range logs.All() {
+		for k, v := range e.ContextMap() {
+			if s := fmt.Sprint(v); strings.Contains(s, "4111") {
+				t.Fatalf("%q logs the prompt in %s: %s", e.Message, k, s)
+			}
+		}
+		if strings.HasSuffix(e.Message, "] start") {
+			starts++
+			if p, ok := e.ContextMap()["prompt"]; ok {
+				t.Fatalf("%q: expected no prompt field, got %v", e.Message, p)
+			}
+		}
+	}
+	if starts != 5 {
+		t.Fatalf("expected a start line per request, got %d", starts)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "4111") || strings.Count(string(data), "[redacted ") != 2 {
+		t.Fatalf("the recording isn't redacted: %s", data)
+	}
+}
@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"runtime/debug"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errPanic is returned to clients instead of the panic value, which may leak internals.
+var errPanic = status.Error(codes.Internal, "internal error")
+
+// recoveryOptions returns interceptors that turn a handler panic into codes.Internal instead of
+// crashing the process. They are installed first so they also cover the other interceptors.
+func recoveryOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		WithUnaryInterceptors(recoveryUnaryInterceptor),
+		WithStreamInterceptors(recoveryStreamInterceptor),
+	}
+}
+
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(path.Base(info.FullMethod), grpcRequestID(ctx), p)
+			resp, err = nil, errPanic
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(path.Base(info.FullMethod), grpcRequestID(ss.Context()), p)
+			err = errPanic
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// recoverHTTP turns a handler panic into a 500 in the route's error format. When the response has
+// already started (e.g. mid-stream) the connection is aborted instead.
+func recoverHTTP(h http.Handler, writeErr httpErrorWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			logPanic(r.Pattern, httpRequestID(r), p)
+			if sw, ok := w.(*statusWriter); ok && sw.code != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			writeErr(w, http.StatusInternalServerError, "internal error")
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func logPanic(rpc, requestID string, p any) {
+	metrics.Panics.WithLabelValues(rpc).Inc()
+	logger.Log.Errorw("[grpc][recovery] handler panic",
+		"rpc", rpc,
+		"requestId", requestID,
+		"panic", p,
+		"stack", string(debug.Stack()),
+	)
+}
+
+// httpRequestID returns the caller's X-Request-Id header, or a generated ID.
+func httpRequestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return "req_" + mock.RandID()
+}
@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// panicService panics on requests whose prompt is "panic" and otherwise serves the mock.
+type panicService struct {
+	*MockLlmService
+}
+
+func (s panicService) ChatCompletion(ctx context.Context, req *llmv1.ChatCompletionRequest) (*llmv1.ChatCompletionResponse, error) {
+	if req.GetUserPrompt() == "panic" {
+		panic("fixture exploded")
+	}
+	return s.MockLlmService.ChatCompletion(ctx, req)
+}
+
+func (s panicService) ChatCompletionStream(req *llmv1.ChatCompletionRequest, stream llmv1.LlmService_ChatCompletionStreamServer) error {
+	if req.GetUserPrompt() == "panic" {
+		_ = stream.Send(&llmv1.ChatCompletionChunkResponse{Type: string(events.OutputTextDelta), Text: "partial"})
+		panic("fixture exploded mid-stream")
+	}
+	return s.MockLlmService.ChatCompletionStream(req, stream)
+}
+
+func TestRecoveryReturnsInternalAndKeepsServing(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	opts, err := ServerOptions(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), panicService{NewMockLlmService(cfg)}, opts...)
+	served := make(chan struct{})
+	go func() {
+		_ = srv.RunWithListener(lis)
+		close(served)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		<-served
+	})
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := llmv1.NewLlmServiceClient(conn)
+	ctx := context.Background()
+
+	unaryPanics := metrics.Panics.WithLabelValues("ChatCompletion")
+	before := testutil.ToFloat64(unaryPanics)
+
+	_, err = client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+	if got := testutil.ToFloat64(unaryPanics) - before; got != 1 {
+		t.Fatalf("expected 1 recorded panic, got %v", got)
+	}
+
+	stream, err := client.ChatCompletionStream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "panic"})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected stream to end with Internal, got %v", err)
+	}
+
+	// The server is still up.
+	if _, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8}); err != nil {
+		t.Fatalf("request after panic failed: %v", err)
+	}
+	stream, err = client.ChatCompletionStream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8})
+	if err != nil {
+		t.Fatalf("stream after panic failed: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("recv after panic failed: %v", err)
+		}
+	}
+}
+
+func TestRecoverHTTP(t *testing.T) {
+	boom := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("panic") != "" {
+			panic("fixture exploded")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("POST /boom", instrumentHTTP(recoverHTTP(boom, openAIHTTPError)))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	panics := metrics.Panics.WithLabelValues("POST /boom")
+	before := testutil.ToFloat64(panics)
+
+	resp, err := http.Post(ts.URL+"/boom?panic=1", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.StatusCode)
+	}
+	if got := testutil.ToFloat64(panics) - before; got != 1 {
+		t.Fatalf("expected 1 recorded panic, got %v", got)
+	}
+
+	resp, err = http.Post(ts.URL+"/boom", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post after panic failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 after panic, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,215 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+)
+
+// Trace is the recorded shape of one real stream: when each content chunk arrived and how big it
+// was. A JSONL trace file holds one per line:
+//
+//	{"model":"gpt-4o","chunks":[{"offset_ms":412,"chars":9},{"offset_ms":431,"chars":14}]}
+//
+// A CSV trace file (.csv) holds one chunk per row, grouped into traces by the trace column:
+//
+//	trace,model,offset_ms,chars
+//	t1,gpt-4o,412,9
+//	t1,gpt-4o,431,14
+type Trace struct {
+	Model  string       `json:"model"`
+	Chunks []TraceChunk `json:"chunks"`
+}
+
+// TraceChunk is one chunk of a Trace. OffsetMs is measured from the start of the request, so the
+// first chunk's offset is the TTFT.
+type TraceChunk struct {
+	OffsetMs int64 `json:"offset_ms"`
+	Chars    int   `json:"chars"`
+}
+
+// chars returns the output length of the trace.
+func (t *Trace) chars() int {
+	n := 0
+	for _, c := range t.Chunks {
+		n += c.Chars
+	}
+	return n
+}
+
+// offset returns when chunk i of the trace is due, relative to the start of the request.
+func (t *Trace) offset(i int) time.Duration {
+	return time.Duration(t.Chunks[i].OffsetMs) * time.Millisecond
+}
+
+func (t *Trace) validate() error {
+	if len(t.Chunks) == 0 {
+		return errors.New("trace has no chunks")
+	}
+	var last int64
+	for i, c := range t.Chunks {
+		if c.Chars <= 0 {
+			return fmt.Errorf("chunk %d: chars must be positive, got %d", i, c.Chars)
+		}
+		if c.OffsetMs < last {
+			return fmt.Errorf("chunk %d: offset_ms %d is before the previous chunk's %d", i, c.OffsetMs, last)
+		}
+		last = c.OffsetMs
+	}
+	return nil
+}
+
+// Replayer hands out the traces of REPLAY_FILE to streams, in turn.
+type Replayer struct {
+	traces  []*Trace
+	byModel map[string][]*Trace
+	next    atomic.Uint64
+}
+
+// LoadReplay reads the traces of path: CSV when it ends in .csv, JSONL otherwise.
+func LoadReplay(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var traces []*Trace
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		traces, err = parseTracesCSV(f)
+	} else {
+		traces, err = parseTracesJSONL(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewReplayer(traces)
+}
+
+// NewReplayer returns a Replayer of traces, which must not be empty.
+func NewReplayer(traces []*Trace) (*Replayer, error) {
+	if len(traces) == 0 {
+		return nil, errors.New("no traces")
+	}
+	r := &Replayer{traces: traces, byModel: map[string][]*Trace{}}
+	for _, t := range traces {
+		r.byModel[t.Model] = append(r.byModel[t.Model], t)
+	}
+	return r, nil
+}
+
+// Len returns the number of traces.
+func (r *Replayer) Len() int {
+	return len(r.traces)
+}
+
+// pick returns the trace for the next stream, or nil without a Replayer. REPLAY_SELECT=model keeps
+// to the traces recorded for model, falling back to all of them when there are none.
+func (r *Replayer) pick(cfg config.Config, model string) *Trace {
+	if r == nil {
+		return nil
+	}
+	traces := r.traces
+	if m := r.byModel[model]; cfg.ReplaySelect == "model" && len(m) > 0 {
+		traces = m
+	}
+	return traces[(r.next.Add(1)-1)%uint64(len(traces))]
+}
+
+// SetReplayer makes ChatCompletionStream follow the traces of r instead of the delay and chunk
+// size knobs (nil disables). It must be called before serving.
+func (s *MockLlmService) SetReplayer(r *Replayer) {
+	s.replay = r
+}
+
+// newReplayOutput returns an output stream exactly as long as trace, so it ends with its last chunk.
+func newReplayOutput(cfg config.Config, prompt string, trace *Trace) *mock.OutputStream {
+	n := trace.chars()
+	return mock.NewOutputStream(prompt, 0, cfg.EchoPrompt, false, n, n)
+}
+
+func parseTracesJSONL(r io.Reader) ([]*Trace, error) {
+	var traces []*Trace
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		t := &Trace{}
+		if err := json.Unmarshal(sc.Bytes(), t); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		traces = append(traces, t)
+	}
+	return traces, sc.Err()
+}
+
+func parseTracesCSV(r io.Reader) ([]*Trace, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	col := map[string]int{}
+	for i, name := range rows[0] {
+		col[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range []string{"trace", "offset_ms", "chars"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("header: missing column %q", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var (
+		traces []*Trace
+		ids    []string
+	)
+	byID := map[string]*Trace{}
+	for n, row := range rows[1:] {
+		offset, err := strconv.ParseInt(field(row, "offset_ms"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: offset_ms: %w", n+2, err)
+		}
+		chars, err := strconv.Atoi(field(row, "chars"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: chars: %w", n+2, err)
+		}
+		id := field(row, "trace")
+		t, ok := byID[id]
+		if !ok {
+			t = &Trace{Model: field(row, "model")}
+			byID[id] = t
+			traces, ids = append(traces, t), append(ids, id)
+		}
+		t.Chunks = append(t.Chunks, TraceChunk{OffsetMs: offset, Chars: chars})
+	}
+	for i, t := range traces {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("trace %q: %w", ids[i], err)
+		}
+	}
+	return traces, nil
+}
@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// testTraces are two recorded streams: a slow one of three chunks and a fast one of four.
+const testTraces = `{"model":"slow","chunks":[{"offset_ms":60,"chars":5},{"offset_ms":90,"chars":11},{"offset_ms":150,"chars":3}]}
+
+{"model":"fast","chunks":[{"offset_ms":10,"chars":20},{"offset_ms":12,"chars":20},{"offset_ms":14,"chars":20},{"offset_ms":40,"chars":7}]}
+`
+
+func loadTestReplay(t *testing.T, name, content string) *Replayer {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReplay(path)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return r
+}
+
+func TestReplayStream(t *testing.T) {
+	// The delay and chunk size knobs would give a very different stream; the trace wins.
+	cfg := config.Config{ChunkSize: config.Int(4), TTFTMinMs: config.Int(500), TTFTMaxMs: config.Int(500), BaseDelayMs: 300, ReplaySelect: "model"}
+	svc := NewMockLlmService(cfg)
+	svc.SetReplayer(loadTestReplay(t, "traces.jsonl", testTraces))
+
+	start := time.Now()
+	var sentAt []time.Duration
+	fs := llmtest.NewServerStream(context.Background())
+	fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) { sentAt = append(sentAt, time.Since(start)) }
+	if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{Model: "slow", UserPrompt: "hello", MaxTokens: 512}, fs); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+
+	deltas := fs.Chunks()[:len(fs.Chunks())-1]
+	if len(deltas) != 3 || !isDoneChunk(fs.Chunks()[len(fs.Chunks())-1]) {
+		t.Fatalf("expected 3 deltas and a done chunk, got %d chunks", len(fs.Chunks()))
+	}
+	var text strings.Builder
+	for i, want := range []int{5, 11, 3} {
+		if got := len(deltas[i].GetText()); got != want {
+			t.Fatalf("delta %d has %d chars, want %d", i, got, want)
+		}
+		text.WriteString(deltas[i].GetText())
+	}
+	if done := fs.Chunks()[len(fs.Chunks())-1]; int(done.GetOutputBytes()) != text.Len() {
+		t.Fatalf("done chunk covers %d bytes, deltas %d", done.GetOutputBytes(), text.Len())
+	}
+	for i, want := range []time.Duration{60, 90, 150} {
+		want *= time.Millisecond
+		if got := sentAt[i]; got < want-5*time.Millisecond || got > want+40*time.Millisecond {
+			t.Fatalf("delta %d sent at %v, want about %v", i, got, want)
+		}
+	}
+
+	// REPLAY_SELECT=model falls back to every trace for an unknown model.
+	fs = llmtest.NewServerStream(context.Background())
+	if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{Model: "other", UserPrompt: "hello"}, fs); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if n := len(fs.Chunks()) - 1; n != 3 && n != 4 {
+		t.Fatalf("expected one of the traces, got %d deltas", n)
+	}
+}
+
+func TestReplayPick(t *testing.T) {
+	r := loadTestReplay(t, "traces.csv", "trace,model,offset_ms,chars\n"+
+		"a,slow,60,5\nb,fast,10,20\na,slow,90,11\nb,fast,12,20\nc,fast,30,1\n")
+	if r.Len() != 3 {
+		t.Fatalf("expected 3 traces, got %d", r.Len())
+	}
+
+	roundRobin := config.Config{ReplaySelect: "round_robin"}
+	var models []string
+	for range 4 {
+		models = append(models, r.pick(roundRobin, "fast").Model)
+	}
+	if strings.Join(models, ",") != "slow,fast,fast,slow" {
+		t.Fatalf("round_robin picked %v", models)
+	}
+	byModel := config.Config{ReplaySelect: "model"}
+	for range 3 {
+		if tr := r.pick(byModel, "fast"); tr.Model != "fast" {
+			t.Fatalf("model picked a %q trace", tr.Model)
+		}
+	}
+	if tr := r.pick(byModel, "slow"); len(tr.Chunks) != 2 || tr.offset(1) != 90*time.Millisecond || tr.chars() != 16 {
+		t.Fatalf("unexpected slow trace: %+v", tr)
+	}
+	var none *Replayer
+	if none.pick(byModel, "fast") != nil {
+		t.Fatal("a nil Replayer picked a trace")
+	}
+}
+
+func TestLoadReplayErrors(t *testing.T) {
+	for _, tc := range []struct{ name, content, want string }{
+		{"bad.jsonl", "{\"chunks\":[]}\n", "line 1: trace has no chunks"},
+		{"zero.jsonl", "{\"chunks\":[{\"offset_ms\":5,\"chars\":0}]}\n", "chars must be positive"},
+		{"order.jsonl", "\n{\"chunks\":[{\"offset_ms\":50,\"chars\":1},{\"offset_ms\":20,\"chars\":1}]}\n", "line 2: chunk 1: offset_ms 20"},
+		{"empty.jsonl", "\n", "no traces"},
+		{"header.csv", "model,offset_ms,chars\nm,1,1\n", `missing column "trace"`},
+		{"row.csv", "trace,offset_ms,chars\na,soon,1\n", "row 2: offset_ms"},
+	} {
+		path := filepath.Join(t.TempDir(), tc.name)
+		_ = os.WriteFile(path, []byte(tc.content), 0o644)
+		if _, err := LoadReplay(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
+)
+
+// ResponsesHandler serves POST /v1/responses in the OpenAI Responses API shape.
+//
+// With "stream": true the response is an SSE stream of typed events (each with an `event:` line and a
+// monotonically increasing sequence_number): response.created, response.in_progress,
+// response.output_item.added, response.content_part.added, repeated response.output_text.delta,
+// response.output_text.done, response.content_part.done, response.output_item.done and
+// response.completed. Injected errors surface as a response.failed event on streams and as an
+// OpenAI error body otherwise.
+func ResponsesHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req mock.ResponsesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		chatReq, err := responsesToChatRequest(req)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := checkMessages(cfg, chatReq); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, status.Convert(err).Message())
+			return
+		}
+		if req.Model == "" {
+			req.Model = "mock-responses"
+		}
+		maxTokens := req.MaxOutputTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultInt(cfg.DefaultTokens, 128)
+		}
+
+		prompt := buildPromptForTokens(chatReq)
+		resp := &mock.ResponseObject{
+			ID:        "resp_mock_" + mock.RandID(),
+			Object:    "response",
+			CreatedAt: time.Now().Unix(),
+			Status:    "in_progress",
+			Model:     req.Model,
+			Output:    []mock.ResponseOutputItem{},
+		}
+
+		setRequestModel(r.Context(), req.Model)
+		errCode := injectHTTPError(cfg)
+		if errCode != 0 && !req.Stream {
+			logger.Log.Infow("[http][Responses] injected error", "mode", cfg.ErrorMode, "status", errCode)
+			e := openAIError(errCode, "mock error")
+			e.Error.Injected = true
+			writeJSON(w, errCode, e)
+			return
+		}
+
+		content := buildOutput(cfg, prompt, maxTokens)
+		pt := promptTokens(cfg, chatReq)
+		ct := mock.ApproxTokens(content)
+		usage := &mock.ResponseUsage{InputTokens: pt, OutputTokens: ct, TotalTokens: pt + ct}
+		item := mock.ResponseOutputItem{
+			ID:      "msg_mock_" + mock.RandID(),
+			Type:    "message",
+			Status:  "in_progress",
+			Role:    "assistant",
+			Content: []mock.ResponseContentPart{},
+		}
+
+		if !req.Stream {
+			requestService(r.Context(), cfg).simulateUnary(r.Context(), ct)
+			if r.Context().Err() != nil {
+				return
+			}
+			item.Status = "completed"
+			item.Content = append(item.Content, mock.ResponseContentPart{Type: "output_text", Text: content, Annotations: []any{}})
+			resp.Status = "completed"
+			resp.Output = append(resp.Output, item)
+			resp.Usage = usage
+			writeJSON(w, http.StatusOK, resp)
+			reportUsage(r.Context(), usage.InputTokens, usage.OutputTokens)
+			return
+		}
+
+		serveResponsesSSE(w, r, resp, item, content, usage, errCode, cfg)
+	}
+}
+
+func serveResponsesSSE(w http.ResponseWriter, r *http.Request, resp *mock.ResponseObject, item mock.ResponseOutputItem, content string, usage *mock.ResponseUsage, errCode int, cfg config.Config) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	bw := bufio.NewWriter(w)
+	seq := 0
+	send := func(ev mock.ResponsesEvent) bool {
+		ev.SequenceNumber = seq
+		seq++
+		if err := writeSSEEvent(bw, ev.Type, ev); err != nil {
+			return false
+		}
+		if err := bw.Flush(); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	snapshot := func() *mock.ResponseObject {
+		c := *resp
+		return &c
+	}
+	zero := 0
+
+	if !send(mock.ResponsesEvent{Type: "response.created", Response: snapshot()}) {
+		return
+	}
+
+	if errCode != 0 {
+		logger.Log.Infow("[http][Responses] injected error", "mode", cfg.ErrorMode, "status", errCode)
+		code := "server_error"
+		if errCode == http.StatusTooManyRequests {
+			code = "rate_limit_exceeded"
+		}
+		resp.Status = "failed"
+		resp.Error = &mock.ResponseError{Code: code, Message: "mock error", Injected: true}
+		send(mock.ResponsesEvent{Type: "response.failed", Response: snapshot()})
+		return
+	}
+
+	if !send(mock.ResponsesEvent{Type: "response.in_progress", Response: snapshot()}) {
+		return
+	}
+	added := item
+	if !send(mock.ResponsesEvent{Type: "response.output_item.added", OutputIndex: &zero, Item: &added}) {
+		return
+	}
+	part := mock.ResponseContentPart{Type: "output_text", Text: "", Annotations: []any{}}
+	if !send(mock.ResponsesEvent{Type: "response.content_part.added", ItemID: item.ID, OutputIndex: &zero, ContentIndex: &zero, Part: &part}) {
+		return
+	}
+
+	chunkSize := sseChunkSize(cfg, 0)
+	pace := newStreamPacer(cfg)
+	for i := 0; i < len(content); i += chunkSize {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		delta := content[i:end]
+
+		if !send(mock.ResponsesEvent{Type: "response.output_text.delta", ItemID: item.ID, OutputIndex: &zero, ContentIndex: &zero, Delta: &delta}) {
+			return
+		}
+		pace.wait(r.Context(), delta)
+	}
+
+	if !send(mock.ResponsesEvent{Type: "response.output_text.done", ItemID: item.ID, OutputIndex: &zero, ContentIndex: &zero, Text: &content}) {
+		return
+	}
+	part.Text = content
+	if !send(mock.ResponsesEvent{Type: "response.content_part.done", ItemID: item.ID, OutputIndex: &zero, ContentIndex: &zero, Part: &part}) {
+		return
+	}
+	item.Status = "completed"
+	item.Content = []mock.ResponseContentPart{part}
+	if !send(mock.ResponsesEvent{Type: "response.output_item.done", OutputIndex: &zero, Item: &item}) {
+		return
+	}
+
+	resp.Status = "completed"
+	resp.Output = []mock.ResponseOutputItem{item}
+	resp.Usage = usage
+	if send(mock.ResponsesEvent{Type: "response.completed", Response: snapshot()}) {
+		reportUsage(r.Context(), usage.InputTokens, usage.OutputTokens)
+	}
+}
+
+// responsesToChatRequest maps Responses API input onto the gRPC request shape so prompt construction
+// matches buildPromptForTokens. Instructions become the system prompt; a string input is the user prompt.
+func responsesToChatRequest(req mock.ResponsesRequest) (*llmv1.ChatCompletionRequest, error) {
+	out := &llmv1.ChatCompletionRequest{
+		Model:        req.Model,
+		SystemPrompt: req.Instructions,
+		MaxTokens:    int32(req.MaxOutputTokens),
+	}
+	if len(req.Input) == 0 {
+		return nil, errMissingInput
+	}
+
+	var s string
+	if err := json.Unmarshal(req.Input, &s); err == nil {
+		out.UserPrompt = s
+		return out, nil
+	}
+
+	var msgs []mock.ResponsesInputMessage
+	if err := json.Unmarshal(req.Input, &msgs); err != nil {
+		return nil, errMissingInput
+	}
+	if n := len(msgs); n > 0 && msgs[n-1].Role == "user" {
+		out.UserPrompt = mock.ResponsesContentText(msgs[n-1].Content)
+		msgs = msgs[:n-1]
+	}
+	for _, m := range msgs {
+		out.Context = append(out.Context, &llmv1.ChatMessage{Role: m.Role, Content: mock.ResponsesContentText(m.Content)})
+	}
+	return out, nil
+}
+
+var errMissingInput = errors.New("input must be a string or a list of messages")
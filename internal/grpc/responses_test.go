@@ -0,0 +1,141 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func serveResponses(cfg config.Config, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	ResponsesHandler(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body)))
+	return rr
+}
+
+func TestResponsesStreamEventSequence(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(10), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(256)}
+
+	body := `{"model":"gpt-mock","instructions":"be brief","stream":true,"max_output_tokens":12,` +
+		`"input":[{"role":"user","content":"hi"},{"role":"assistant","content":[{"type":"output_text","text":"hello"}]},` +
+		`{"role":"user","content":[{"type":"input_text","text":"tell me a joke"}]}]}`
+	rr := serveResponses(cfg, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	events := parseNamedSSE(t, rr.Body.String())
+	wantHead := []string{"response.created", "response.in_progress", "response.output_item.added", "response.content_part.added"}
+	wantTail := []string{"response.output_text.done", "response.content_part.done", "response.output_item.done", "response.completed"}
+	if len(events) <= len(wantHead)+len(wantTail) {
+		t.Fatalf("too few events: %d", len(events))
+	}
+
+	var assembled strings.Builder
+	var last mock.ResponsesEvent
+	for i, e := range events {
+		var ev mock.ResponsesEvent
+		if err := json.Unmarshal([]byte(e.data), &ev); err != nil {
+			t.Fatalf("bad event %d: %v", i, err)
+		}
+		if ev.Type != e.name {
+			t.Fatalf("event line %q does not match payload type %q", e.name, ev.Type)
+		}
+		if ev.SequenceNumber != i {
+			t.Fatalf("event %d has sequence_number %d", i, ev.SequenceNumber)
+		}
+
+		switch {
+		case i < len(wantHead):
+			if e.name != wantHead[i] {
+				t.Fatalf("event %d: expected %q, got %q", i, wantHead[i], e.name)
+			}
+		case i >= len(events)-len(wantTail):
+			if want := wantTail[i-(len(events)-len(wantTail))]; e.name != want {
+				t.Fatalf("event %d: expected %q, got %q", i, want, e.name)
+			}
+		default:
+			if e.name != "response.output_text.delta" || ev.Delta == nil {
+				t.Fatalf("event %d: expected output_text.delta, got %q", i, e.name)
+			}
+			assembled.WriteString(*ev.Delta)
+		}
+		last = ev
+	}
+
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{
+		SystemPrompt: "be brief",
+		UserPrompt:   "tell me a joke",
+		Context: []*llmv1.ChatMessage{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+	})
+	expected := buildOutput(cfg, prompt, 12)
+	if assembled.String() != expected {
+		t.Fatalf("reassembled content mismatch: got len=%d expected len=%d", assembled.Len(), len(expected))
+	}
+
+	if last.Response == nil || last.Response.Status != "completed" || last.Response.Usage == nil {
+		t.Fatalf("completed event missing response/usage: %+v", last)
+	}
+	u := last.Response.Usage
+	if u.InputTokens != mock.ApproxTokens(prompt) || u.OutputTokens != mock.ApproxTokens(expected) || u.TotalTokens != u.InputTokens+u.OutputTokens {
+		t.Fatalf("usage mismatch: %+v", u)
+	}
+	if len(last.Response.Output) != 1 || last.Response.Output[0].Content[0].Text != expected {
+		t.Fatalf("completed response output mismatch")
+	}
+}
+
+func TestResponsesNonStream(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	rr := serveResponses(cfg, `{"model":"gpt-mock","input":"hello","max_output_tokens":8}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rr.Code, rr.Body.String())
+	}
+	var resp mock.ResponseObject
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if resp.Object != "response" || resp.Status != "completed" || resp.Usage == nil || len(resp.Output) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestResponsesInjectedErrors(t *testing.T) {
+	cfg := config.Config{ErrorRate: 1, ErrorMode: "429"}
+
+	t.Run("stream", func(t *testing.T) {
+		rr := serveResponses(cfg, `{"input":"hello","stream":true}`)
+		events := parseNamedSSE(t, rr.Body.String())
+		if len(events) != 2 || events[0].name != "response.created" || events[1].name != "response.failed" {
+			t.Fatalf("expected created+failed, got %+v", events)
+		}
+		var ev mock.ResponsesEvent
+		if err := json.Unmarshal([]byte(events[1].data), &ev); err != nil {
+			t.Fatalf("bad failed event: %v", err)
+		}
+		if ev.Response == nil || ev.Response.Status != "failed" || ev.Response.Error == nil || ev.Response.Error.Code != "rate_limit_exceeded" {
+			t.Fatalf("unexpected failed event: %+v", ev)
+		}
+	})
+
+	t.Run("non-stream", func(t *testing.T) {
+		rr := serveResponses(cfg, `{"input":"hello"}`)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", rr.Code)
+		}
+		var e mock.ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil || e.Error.Code == nil || *e.Error.Code != "rate_limit_exceeded" {
+			t.Fatalf("unexpected error body: %s", rr.Body.String())
+		}
+	})
+}
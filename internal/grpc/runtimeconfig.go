@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AdminService implements llm.v1.AdminService: it controls the runtime state of svc (its config
+// holder and kill switches), which the HTTP endpoints built by NewLiveHTTPMux share.
+type AdminService struct {
+	llmv1.UnimplementedAdminServiceServer
+	svc  *MockLlmService
+	live *config.Holder
+}
+
+func NewAdminService(svc *MockLlmService) *AdminService {
+	return &AdminService{svc: svc, live: svc.Config()}
+}
+
+// RegisterAdmin registers the AdminService controlling svc. Like every other RPC it requires an
+// API key when API_KEYS is set. It must be called before Run.
+func (s *Server) RegisterAdmin(svc *MockLlmService) {
+	admin := NewAdminService(svc)
+	s.register(func(g *grpc.Server) { llmv1.RegisterAdminServiceServer(g, admin) })
+}
+
+func (a *AdminService) GetConfig(context.Context, *llmv1.GetConfigRequest) (*llmv1.RuntimeConfig, error) {
+	return runtimeConfig(a.live.Load()), nil
+}
+
+func (a *AdminService) UpdateConfig(_ context.Context, req *llmv1.RuntimeConfig) (*llmv1.RuntimeConfig, error) {
+	cfg, err := a.live.Update(func(c *config.Config) { applyRuntimeConfig(c, req) })
+	if err != nil {
+		logger.Log.Warnw("[admin] rejected config update", "update", req.String(), "err", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	logger.Log.Infow("[admin] config updated", "update", req.String())
+	return runtimeConfig(cfg), nil
+}
+
+// runtimeConfig returns the runtime-adjustable part of c. Unset optional fields are left unset.
+func runtimeConfig(c config.Config) *llmv1.RuntimeConfig {
+	optInt := func(v *int) *int32 {
+		if v == nil {
+			return nil
+		}
+		return proto.Int32(int32(*v))
+	}
+	return &llmv1.RuntimeConfig{
+		BaseDelayMs:      proto.Int32(int32(c.BaseDelayMs)),
+		JitterMs:         proto.Int32(int32(c.JitterMs)),
+		PerTokenDelayMs:  proto.Int32(int32(c.PerTokenDelayMs)),
+		ErrorRate:        proto.Float64(c.ErrorRate),
+		ErrorMode:        proto.String(c.ErrorMode),
+		ErrorTiming:      proto.String(c.ErrorTiming),
+		DefaultTokens:    proto.Int32(int32(c.DefaultTokens)),
+		ChunkSize:        optInt(c.ChunkSize),
+		StreamDelayMinMs: optInt(c.StreamDelayMinMs),
+		StreamDelayMaxMs: optInt(c.StreamDelayMaxMs),
+		EchoPrompt:       proto.Bool(c.EchoPrompt),
+		Randomize:        proto.Bool(c.Randomize),
+		TtftMinMs:        optInt(c.TTFTMinMs),
+		TtftMaxMs:        optInt(c.TTFTMaxMs),
+		TokensPerSec:     c.TokensPerSec,
+		DebugOutputChars: proto.Int32(int32(c.DebugOutputChars)),
+		MaxOutputChars:   optInt(c.MaxOutputChars),
+		StrictTokenMode:  c.StrictTokenMode,
+	}
+}
+
+// applyRuntimeConfig copies the fields set in u into c.
+func applyRuntimeConfig(c *config.Config, u *llmv1.RuntimeConfig) {
+	setInt := func(dst *int, v *int32) {
+		if v != nil {
+			*dst = int(*v)
+		}
+	}
+	setInt(&c.BaseDelayMs, u.BaseDelayMs)
+	setInt(&c.JitterMs, u.JitterMs)
+	setInt(&c.PerTokenDelayMs, u.PerTokenDelayMs)
+	setInt(&c.DefaultTokens, u.DefaultTokens)
+	setInt(&c.DebugOutputChars, u.DebugOutputChars)
+	// Optional fields get fresh pointers: other Config values may share the current ones.
+	setOptInt := func(dst **int, v *int32) {
+		if v != nil {
+			*dst = config.Int(int(*v))
+		}
+	}
+	setOptInt(&c.ChunkSize, u.ChunkSize)
+	setOptInt(&c.StreamDelayMinMs, u.StreamDelayMinMs)
+	setOptInt(&c.StreamDelayMaxMs, u.StreamDelayMaxMs)
+	setOptInt(&c.TTFTMinMs, u.TtftMinMs)
+	setOptInt(&c.TTFTMaxMs, u.TtftMaxMs)
+	setOptInt(&c.MaxOutputChars, u.MaxOutputChars)
+	if u.ErrorRate != nil {
+		c.ErrorRate = *u.ErrorRate
+	}
+	if u.TokensPerSec != nil {
+		c.TokensPerSec = config.Float(*u.TokensPerSec)
+	}
+	if u.ErrorMode != nil {
+		c.ErrorMode = strings.ToLower(*u.ErrorMode)
+	}
+	if u.ErrorTiming != nil {
+		c.ErrorTiming = strings.ToLower(*u.ErrorTiming)
+	}
+	if u.EchoPrompt != nil {
+		c.EchoPrompt = *u.EchoPrompt
+	}
+	if u.Randomize != nil {
+		c.Randomize = *u.Randomize
+	}
+	if u.StrictTokenMode != nil {
+		c.StrictTokenMode = config.Bool(*u.StrictTokenMode)
+	}
+}
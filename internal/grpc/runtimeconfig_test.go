@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// startAdminServer serves svc with the AdminService registered on its config holder.
+func startAdminServer(t *testing.T, svc *MockLlmService) (llmv1.LlmServiceClient, llmv1.AdminServiceClient) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	cfg := svc.Config().Load()
+	opts, err := ServerOptions(cfg, NewLimits(cfg), nil)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), svc, opts...)
+	srv.RegisterAdmin(svc)
+	served := make(chan struct{})
+	go func() {
+		_ = srv.RunWithListener(lis)
+		close(served)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		<-served
+	})
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return llmv1.NewLlmServiceClient(conn), llmv1.NewAdminServiceClient(conn)
+}
+
+func TestUpdateConfigAppliesToLaterRequests(t *testing.T) {
+	svc := NewMockLlmService(config.Config{ErrorMode: "mixed", ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)})
+	client, admin := startAdminServer(t, svc)
+	ctx := context.Background()
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8}
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.ChatCompletion(ctx, req); err != nil {
+			t.Fatalf("request %d before the update failed: %v", i, err)
+		}
+	}
+
+	got, err := admin.UpdateConfig(ctx, &llmv1.RuntimeConfig{ErrorRate: proto.Float64(1), ErrorMode: proto.String("INTERNAL")})
+	if err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if got.GetErrorRate() != 1 || got.GetErrorMode() != "internal" || got.GetChunkSize() != 8 {
+		t.Fatalf("unexpected config after update: %v", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.ChatCompletion(ctx, req); status.Code(err) != codes.Internal {
+			t.Fatalf("request %d after the update: expected Internal, got %v", i, err)
+		}
+	}
+	stream, err := client.ChatCompletionStream(ctx, req)
+	for err == nil {
+		_, err = stream.Recv() // the failed chunk precedes the status
+	}
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("stream after the update: expected Internal, got %v", err)
+	}
+
+	// The HTTP endpoints share the holder.
+	rr := httptest.NewRecorder()
+	NewLiveHTTPMux(svc, nil, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m","max_tokens":8,"messages":[{"role":"user","content":"hello"}]}`)))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("HTTP after the update: expected 500, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if cur, err := admin.GetConfig(ctx, &llmv1.GetConfigRequest{}); err != nil || !proto.Equal(cur, got) {
+		t.Fatalf("GetConfig returned %v (%v), expected %v", cur, err, got)
+	}
+}
+
+func TestUpdateConfigRejectsInvalidValues(t *testing.T) {
+	svc := NewMockLlmService(config.Config{TTFTMinMs: config.Int(10), TTFTMaxMs: config.Int(20)})
+	_, admin := startAdminServer(t, svc)
+	ctx := context.Background()
+
+	for _, u := range []*llmv1.RuntimeConfig{
+		{ErrorRate: proto.Float64(-0.5)},
+		{ErrorRate: proto.Float64(2)},
+		{TtftMinMs: proto.Int32(30)},
+		{StreamDelayMinMs: proto.Int32(50), StreamDelayMaxMs: proto.Int32(10)},
+		{ChunkSize: proto.Int32(-1)},
+		{ErrorRate: proto.Float64(0.5), TokensPerSec: proto.Float64(-3)},
+	} {
+		if _, err := admin.UpdateConfig(ctx, u); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("update %v: expected InvalidArgument, got %v", u, err)
+		}
+	}
+	if cfg := svc.Config().Load(); cfg.ErrorRate != 0 || *cfg.TTFTMinMs != 10 || cfg.TokensPerSec != nil {
+		t.Fatalf("rejected updates leaked into the config: %+v", cfg)
+	}
+}
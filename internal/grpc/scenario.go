@@ -0,0 +1,141 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a SCENARIO_FILE: phases run one after the other, each for its duration, with its
+// overrides applied to the base config it was loaded with. The overrides are the fields of
+// AdminService.UpdateConfig (see RuntimeConfig in llm.proto); a phase without any runs the base
+// config.
+//
+//	phases:
+//	  - name: nominal
+//	    duration: 10m
+//	  - name: slow
+//	    duration: 5m
+//	    config: {ttft_min_ms: 2000, ttft_max_ms: 4000}
+//	  - name: errors
+//	    duration: 2m
+//	    config: {error_rate: 0.5, error_mode: "500"}
+type Scenario struct {
+	phases []scenarioPhase
+	cfgs   []config.Config // the config of each phase, checked against the base by LoadScenario
+}
+
+type scenarioPhase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// LoadScenario reads the scenario at path and checks that every phase gives a valid config on top
+// of base.
+func LoadScenario(path string, base config.Config) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := parseScenario(data, base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+func parseScenario(data []byte, base config.Config) (*Scenario, error) {
+	var doc struct {
+		Phases []struct {
+			Name     string         `yaml:"name"`
+			Duration time.Duration  `yaml:"duration"`
+			Config   map[string]any `yaml:"config"`
+		} `yaml:"phases"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Phases) == 0 {
+		return nil, errors.New("scenario has no phases")
+	}
+	sc := &Scenario{}
+	for i, p := range doc.Phases {
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if p.Duration <= 0 {
+			return nil, fmt.Errorf("phase %q: duration must be positive", p.Name)
+		}
+		// The overrides go through protojson so they take the same names and types as UpdateConfig.
+		override := &llmv1.RuntimeConfig{}
+		raw, err := json.Marshal(p.Config)
+		if err == nil && p.Config != nil {
+			err = protojson.Unmarshal(raw, override)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("phase %q: config: %w", p.Name, err)
+		}
+		cfg := base
+		applyRuntimeConfig(&cfg, override)
+		if errs := config.Validate(cfg); len(errs) > 0 {
+			return nil, fmt.Errorf("phase %q: %w", p.Name, errors.Join(errs...))
+		}
+		sc.phases = append(sc.phases, scenarioPhase{Name: p.Name, Duration: p.Duration})
+		sc.cfgs = append(sc.cfgs, cfg)
+	}
+	return sc, nil
+}
+
+// Run steps live through the phases until ctx is done or the last phase ends; with loop it starts
+// over instead. Each phase change swaps the whole config at once, so requests in flight keep the
+// phase they started in. The last phase stays in effect after the scenario ends, and admin or
+// reload changes last until the next phase starts.
+func (sc *Scenario) Run(ctx context.Context, live *config.Holder, loop bool) {
+	prev := ""
+	for {
+		for i, p := range sc.phases {
+			before := live.Load()
+			if _, err := live.Update(func(c *config.Config) { *c = sc.cfgs[i] }); err != nil {
+				// Unreachable: LoadScenario validated every phase config.
+				logger.Log.Errorw("[scenario] phase rejected, stopping", "phase", p.Name, "err", err)
+				return
+			}
+			changes := config.Diff(before, sc.cfgs[i])
+			diff := make([]string, len(changes))
+			for j, c := range changes {
+				diff[j] = c.String()
+			}
+			logger.Log.Infow("[scenario] phase", "phase", p.Name, "index", i+1, "phases", len(sc.phases), "duration", p.Duration.String(), "changed", diff)
+			stats.Default.SetScenarioPhase(p.Name)
+			if prev != "" {
+				metrics.ScenarioPhase.WithLabelValues(prev).Set(0)
+			}
+			metrics.ScenarioPhase.WithLabelValues(p.Name).Set(1)
+			prev = p.Name
+
+			t := time.NewTimer(p.Duration)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		if !loop {
+			logger.Log.Infow("[scenario] finished, keeping the last phase", "phase", prev)
+			return
+		}
+	}
+}
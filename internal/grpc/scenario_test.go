@@ -0,0 +1,116 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+const testScenario = `
+phases:
+  - name: nominal
+    duration: 300ms
+  - name: outage
+    duration: 300ms
+    config:
+      error_rate: 1
+      error_mode: "429"
+`
+
+func TestScenarioPhases(t *testing.T) {
+	t.Cleanup(func() { stats.Default.SetScenarioPhase("") })
+	base := config.Config{ChunkSize: config.Int(16), TTFTMinMs: config.Int(400)}
+	sc, err := parseScenario([]byte(testScenario), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewMockLlmService(base)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc.Run(ctx, svc.Config(), false)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if got := stats.Default.ScenarioPhase(); got != "nominal" {
+		t.Fatalf("expected the nominal phase, got %q", got)
+	}
+	if _, st := getHealth(t, NewHTTPMux(config.Config{}, nil, nil, NewReadiness(0)), "/readyz"); st.ScenarioPhase != "nominal" {
+		t.Fatalf("/readyz reports phase %q", st.ScenarioPhase)
+	}
+
+	// A stream started in the nominal phase keeps its config after the outage begins (TTFT 400ms).
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hello"}, llmtest.NewServerStream(context.Background()))
+	}()
+
+	time.Sleep(400 * time.Millisecond)
+	if got := stats.Default.ScenarioPhase(); got != "outage" {
+		t.Fatalf("expected the outage phase, got %q", got)
+	}
+	if _, err := svc.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the outage to inject 429s, got %v", err)
+	}
+	if testutil.ToFloat64(metrics.ScenarioPhase.WithLabelValues("outage")) != 1 || testutil.ToFloat64(metrics.ScenarioPhase.WithLabelValues("nominal")) != 0 {
+		t.Fatal("the scenario_phase gauge doesn't follow the phases")
+	}
+	if err := <-streamErr; err != nil {
+		t.Fatalf("a stream started before the outage failed: %v", err)
+	}
+
+	// Without loop the scenario ends in its last phase.
+	<-done
+	if got := stats.Default.ScenarioPhase(); got != "outage" || svc.Config().Load().ErrorRate != 1 {
+		t.Fatalf("expected the last phase to stay, got %q", got)
+	}
+}
+
+func TestScenarioLoop(t *testing.T) {
+	t.Cleanup(func() { stats.Default.SetScenarioPhase("") })
+	sc, err := parseScenario([]byte("phases:\n  - {duration: 20ms, config: {jitter_ms: 1}}\n  - {duration: 20ms, config: {jitter_ms: 2}}\n"), config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := config.NewHolder(config.Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 130*time.Millisecond)
+	defer cancel()
+	seen := map[int]bool{}
+	go sc.Run(ctx, live, true)
+	for ctx.Err() == nil {
+		seen[live.Load().JitterMs] = true
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 130ms covers at least three phases, so the first one came back.
+	if !seen[1] || !seen[2] || stats.Default.ScenarioPhase() == "" {
+		t.Fatalf("expected the loop to cycle both phases, saw %v", seen)
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	for _, tc := range []struct{ name, doc, want string }{
+		{"empty", "phases: []\n", "no phases"},
+		{"no duration", "phases:\n  - name: a\n", `phase "a": duration must be positive`},
+		{"unknown field", "phases:\n  - {duration: 1s, config: {error_rat: 1}}\n", `phase "phase-1": config`},
+		{"invalid value", "phases:\n  - {name: bad, duration: 1s, config: {error_rate: 2}}\n", "ERROR_RATE"},
+		{"bad yaml", "phases: [", "yaml"},
+	} {
+		if _, err := parseScenario([]byte(tc.doc), config.Config{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
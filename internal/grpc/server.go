@@ -1,12 +1,21 @@
 package grpc
 
 import (
+	"context"
 	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 
 	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
 	"github.com/yungtweek/llm-simulator/internal/logger"
 
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -15,52 +24,376 @@ import (
 // It is intentionally small/simple because this project is a benchmark/mock tool,
 // not a production service framework.
 type Server struct {
-	addr       string
+	addr         string
+	reflection   bool
+	ready        *Readiness
+	sockMode     os.FileMode   // unix socket permissions (0 keeps the umask default)
+	restartEvery time.Duration // FORCED_RESTART_INTERVAL_S (0 disables)
+
+	// A forced restart replaces grpcServer with a fresh one built from opts and services.
+	opts     []grpc.ServerOption
+	services []func(*grpc.Server)
+
+	// The interceptors of WithUnaryInterceptors and WithStreamInterceptors, outermost first.
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+
+	mu         sync.Mutex
 	grpcServer *grpc.Server
+	lis        net.Listener // set by Listen or RunWithListener
+	web        *grpcweb.WrappedGrpcServer
+	webFor     *grpc.Server // the server web wraps
+	restarting bool         // grpcServer was replaced by a forced restart
+	stopped    bool         // Stop/Shutdown was called; no more restarts
 }
 
 // NewGRPCServer creates a new gRPC server for the LlmService at the given address.
-// Example addr: ":50051".
-func NewGRPCServer(addr string, svc llmv1.LlmServiceServer) *Server {
-	s := &Server{
-		addr:       addr,
-		grpcServer: grpc.NewServer(),
-	}
+// Example addr: ":50051" or "unix:///tmp/llm-sim.sock". opts are passed to grpc.NewServer (see
+// ServerOptions), except the serverSettings among them, which configure s itself.
+//
+// The interceptors of WithUnaryInterceptors and WithStreamInterceptors options are chained in the
+// order of opts, the first outermost. ServerOptions installs the built-in ones that way: recovery,
+// access log, metrics, compression, readiness, then auth and rate limiting. Interceptors given
+// after them run innermost, on admitted calls only, with their panics recovered.
+func NewGRPCServer(addr string, svc llmv1.LlmServiceServer, opts ...grpc.ServerOption) *Server {
+	s := &Server{addr: addr, reflection: true}
+	s.apply(opts)
+	s.opts = append(s.opts, grpc.ChainUnaryInterceptor(s.unary...), grpc.ChainStreamInterceptor(s.stream...))
+	s.grpcServer = grpc.NewServer(s.opts...)
 
-	llmv1.RegisterLlmServiceServer(s.grpcServer, svc)
-	// Handy during local development; harmless for a mock server.
-	reflection.Register(s.grpcServer)
+	s.register(func(g *grpc.Server) {
+		llmv1.RegisterLlmServiceServer(g, svc)
+		// Handy during local development; GRPC_REFLECTION=false leaves it out for shared clusters.
+		if s.reflection {
+			reflection.Register(g)
+		}
+	})
 
 	return s
 }
 
-// Run starts listening on the configured address and serves the gRPC server.
-// This call blocks until the server stops or returns an error.
-func (s *Server) Run() error {
-	lis, err := net.Listen("tcp", s.addr)
+// serverSetting is a grpc.ServerOption that grpc.NewServer ignores and NewGRPCServer applies to the
+// Server, so server features travel with the other ServerOptions instead of growing the constructor.
+type serverSetting struct {
+	grpc.EmptyServerOption
+	apply func(*Server)
+}
+
+// apply applies the serverSettings among opts to s and keeps the others for grpc.NewServer.
+func (s *Server) apply(opts []grpc.ServerOption) {
+	for _, o := range opts {
+		if set, ok := o.(serverSetting); ok {
+			set.apply(s)
+		} else {
+			s.opts = append(s.opts, o)
+		}
+	}
+}
+
+// WithoutReflection leaves the reflection service out of the server.
+func WithoutReflection() grpc.ServerOption {
+	return serverSetting{apply: func(s *Server) { s.reflection = false }}
+}
+
+// WithUnaryInterceptors adds interceptors to the chain of unary RPCs, after (inside) those of the
+// options before it; see NewGRPCServer for the order of the built-in ones.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.ServerOption {
+	return serverSetting{apply: func(s *Server) { s.unary = append(s.unary, interceptors...) }}
+}
+
+// WithStreamInterceptors adds interceptors to the chain of streaming RPCs, after (inside) those of
+// the options before it; see NewGRPCServer for the order of the built-in ones.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.ServerOption {
+	return serverSetting{apply: func(s *Server) { s.stream = append(s.stream, interceptors...) }}
+}
+
+// WithServerOptions groups opts into one option, so callers can hand grpc.ServerOptions and the
+// settings above around as a unit. Interceptor options of grpc itself (grpc.ChainUnaryInterceptor)
+// among them run outside all of WithUnaryInterceptors'; use those to keep the documented order.
+func WithServerOptions(opts ...grpc.ServerOption) grpc.ServerOption {
+	return serverSetting{apply: func(s *Server) { s.apply(opts) }}
+}
+
+// register registers services on the current gRPC server and on those built by forced restarts.
+func (s *Server) register(fn func(*grpc.Server)) {
+	s.services = append(s.services, fn)
+	fn(s.grpcServer)
+}
+
+// current returns the gRPC server in use (it changes on forced restarts).
+func (s *Server) current() *grpc.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.grpcServer
+}
+
+// ServerOptions returns the grpc.ServerOptions derived from cfg, to be passed to NewGRPCServer:
+// TLS/mTLS credentials, message size and concurrent stream limits (0 keeps the gRPC default),
+// keepalive, panic recovery, the access log, Prometheus metrics, compression handling, active stream accounting
+// on ready (may be nil), API key auth with the per-key limits in limits (nil disables limiting),
+// and WithoutReflection with GRPC_REFLECTION=false. The interceptors come in that order, through
+// WithUnaryInterceptors and WithStreamInterceptors. It fails when the configured certificates
+// cannot be loaded.
+func ServerOptions(cfg config.Config, limits *Limits, ready *Readiness) ([]grpc.ServerOption, error) {
+	opts, err := tlsOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.GRPCMaxRecvMB > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.GRPCMaxRecvMB*1024*1024))
+	}
+	if cfg.GRPCMaxSendMB > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.GRPCMaxSendMB*1024*1024))
+	}
+	if cfg.GRPCMaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(cfg.GRPCMaxConcurrentStreams)))
+	}
+	if sp, ep := keepaliveParams(cfg); sp != (keepalive.ServerParameters{}) || ep != (keepalive.EnforcementPolicy{}) {
+		opts = append(opts, grpc.KeepaliveParams(sp), grpc.KeepaliveEnforcementPolicy(ep))
+	}
+	opts = append(opts, recoveryOptions()...)
+	opts = append(opts, accessLogOptions(cfg)...)
+	opts = append(opts, metricsOptions()...)
+	opts = append(opts, compressionOptions(cfg)...)
+	if ready != nil {
+		opts = append(opts, readinessOptions(ready)...)
+	}
+	opts = append(opts, authOptions(cfg, limits)...)
+	if !config.Or(cfg.GRPCReflection, true) {
+		opts = append(opts, WithoutReflection())
+	}
+	return opts, nil
+}
+
+// keepaliveParams builds the keepalive server parameters and enforcement policy from cfg.
+// Zero fields keep the gRPC defaults. CONN_MAX_AGE_S is the max connection age in seconds unless
+// GRPC_KEEPALIVE_MAX_AGE_MS is set.
+func keepaliveParams(cfg config.Config) (keepalive.ServerParameters, keepalive.EnforcementPolicy) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	if cfg.KeepaliveMaxAgeMs == 0 {
+		cfg.KeepaliveMaxAgeMs = cfg.ConnMaxAgeS * 1000
+	}
+	sp := keepalive.ServerParameters{
+		MaxConnectionIdle:     ms(cfg.KeepaliveMaxIdleMs),
+		MaxConnectionAge:      ms(cfg.KeepaliveMaxAgeMs),
+		MaxConnectionAgeGrace: ms(cfg.KeepaliveMaxAgeGraceMs),
+		Time:                  ms(cfg.KeepaliveTimeMs),
+		Timeout:               ms(cfg.KeepaliveTimeoutMs),
+	}
+	ep := keepalive.EnforcementPolicy{
+		MinTime:             ms(cfg.KeepaliveMinTimeMs),
+		PermitWithoutStream: cfg.KeepalivePermitWithoutStream,
+	}
+	return sp, ep
+}
+
+// SetReadiness registers the grpc.health.v1 service backed by ready and makes Run report the
+// listener to it once accepting. It must be called before Run.
+func (s *Server) SetReadiness(ready *Readiness) {
+	s.ready = ready
+	s.register(func(g *grpc.Server) { healthpb.RegisterHealthServer(g, &healthServer{ready: ready}) })
+}
+
+// SetForcedRestartInterval makes Run stop the gRPC server every d, dropping every connection and
+// in-flight RPC without a done chunk, and immediately listen again on the same address with a
+// fresh server, simulating a crashing upstream. 0 disables it. It must be called before Run.
+func (s *Server) SetForcedRestartInterval(d time.Duration) {
+	s.restartEvery = d
+}
+
+// SetUnixSocketMode sets the permissions of the socket file when the address is a unix socket
+// ("unix:///path/to.sock"). It must be called before Listen/Run.
+func (s *Server) SetUnixSocketMode(mode os.FileMode) {
+	s.sockMode = mode
+}
+
+// Listen binds the configured address without serving yet, so the bound address (e.g. the free
+// port picked for PORT=0) is available from Addr before Run. Run calls it when needed.
+func (s *Server) Listen() error {
+	lis, err := listen(s.addr, s.sockMode)
 	if err != nil {
 		logger.Log.Errorw("[grpc] failed to listen", "addr", s.addr, "err", err)
 		return err
 	}
+	s.setListener(lis)
+	return nil
+}
 
-	logger.Log.Infow("[grpc] starting server", "addr", s.addr)
-	if err := s.grpcServer.Serve(lis); err != nil {
-		logger.Log.Errorw("[grpc] server stopped with error", "err", err)
-		return err
+// Addr returns the address the server is bound to, or nil before Listen/Run.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
 	}
+	return s.lis.Addr()
+}
 
-	logger.Log.Info("[grpc] server stopped gracefully")
-	return nil
+func (s *Server) setListener(lis net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lis = lis
+}
+
+// Run serves the gRPC server on the configured address, listening first unless Listen was already
+// called. This call blocks until the server stops or returns an error.
+func (s *Server) Run() error {
+	if s.Addr() == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	lis := s.lis
+	s.mu.Unlock()
+	return s.RunWithListener(lis)
+}
+
+// RunWithListener serves the gRPC server on lis (e.g. a bufconn listener in tests) instead of
+// listening on the configured address. This call blocks until the server stops or returns an error.
+// With a forced restart interval it keeps serving across restarts, re-listening on lis's address.
+func (s *Server) RunWithListener(lis net.Listener) error {
+	s.setListener(lis)
+	logger.Log.Infow("[grpc] starting server", "addr", lis.Addr().String())
+	if s.ready != nil {
+		s.ready.ListenerReady()
+	}
+	for restarts := 1; ; restarts++ {
+		var timer *time.Timer
+		if s.restartEvery > 0 {
+			timer = time.AfterFunc(s.restartEvery, func() { s.restart(restarts) })
+		}
+		err := s.current().Serve(lis)
+		if timer != nil {
+			timer.Stop()
+		}
+
+		s.mu.Lock()
+		restarting, stopped := s.restarting, s.stopped
+		s.restarting = false
+		s.mu.Unlock()
+		if stopped || !restarting {
+			if err != nil && !stopped {
+				logger.Log.Errorw("[grpc] server stopped with error", "err", err)
+				return err
+			}
+			logger.Log.Info("[grpc] server stopped gracefully")
+			return nil
+		}
+
+		if lis, err = listen(rebindAddr(lis.Addr()), s.sockMode); err != nil {
+			logger.Log.Errorw("[grpc] forced restart: failed to listen again", "addr", s.addr, "err", err)
+			return err
+		}
+		s.setListener(lis)
+		logger.Log.Infow("[grpc] forced restart: listening again", "addr", lis.Addr().String(), "restart", restarts)
+	}
+}
+
+// restart is the forced restart: it swaps in a fresh gRPC server and stops the current one, which
+// closes the listener and every connection at once and makes RunWithListener listen again.
+func (s *Server) restart(n int) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	old := s.grpcServer
+	s.grpcServer = grpc.NewServer(s.opts...)
+	for _, fn := range s.services {
+		fn(s.grpcServer)
+	}
+	s.restarting = true
+	s.mu.Unlock()
+
+	var active int64
+	if s.ready != nil {
+		active = s.ready.grpcActive.Load()
+	}
+	logger.Log.Warnw("[grpc] forced restart: stopping server and dropping all connections",
+		"addr", s.addr, "restart", n, "droppedStreams", active)
+	old.Stop()
+}
+
+// rebindAddr returns the listen address (see listen) that binds addr again: the same port, even
+// when it was picked for port 0, or the same unix socket path.
+func rebindAddr(addr net.Addr) string {
+	if addr.Network() == "unix" {
+		return "unix://" + addr.String()
+	}
+	return addr.String()
+}
+
+// stop marks the server as stopped, so no forced restart replaces it anymore, and returns the
+// gRPC server to stop.
+func (s *Server) stop() *grpc.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	return s.grpcServer
+}
+
+// GRPCWebHandler returns an http.Handler serving the registered services over gRPC-Web
+// (binary and grpc-web-text), so browser clients can call the simulator without a proxy.
+// Origin checks are left to the HTTP CORS middleware.
+func (s *Server) GRPCWebHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.webServer().ServeHTTP(w, r)
+	})
+}
+
+// webServer returns the gRPC-Web wrapper of the current gRPC server.
+func (s *Server) webServer() *grpcweb.WrappedGrpcServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.webFor != s.grpcServer {
+		s.web = grpcweb.WrapServer(s.grpcServer,
+			grpcweb.WithOriginFunc(func(string) bool { return true }),
+			grpcweb.WithAllowNonRootResource(true),
+		)
+		s.webFor = s.grpcServer
+	}
+	return s.web
+}
+
+// Shutdown drains the server: readiness (if set) switches to draining so new RPCs get Unavailable,
+// and in-flight RPCs may finish until ctx is done. After that the server is stopped forcibly,
+// logging how many streams were cut off, and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	logger.Log.Infow("[grpc] graceful stop", "addr", s.addr)
+	if s.ready != nil {
+		s.ready.Drain()
+	}
+	g := s.stop()
+	done := make(chan struct{})
+	go func() {
+		g.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	var forced int64
+	if s.ready != nil {
+		forced = s.ready.grpcActive.Load()
+	}
+	logger.Log.Warnw("[grpc] drain deadline exceeded, forcing stop", "addr", s.addr, "forcedStreams", forced)
+	g.Stop()
+	<-done
+	return ctx.Err()
 }
 
 // GracefulStop gracefully stops the underlying gRPC server.
 func (s *Server) GracefulStop() {
 	logger.Log.Infow("[grpc] graceful stop", "addr", s.addr)
-	s.grpcServer.GracefulStop()
+	s.stop().GracefulStop()
 }
 
 // Stop immediately stops the underlying gRPC server.
 func (s *Server) Stop() {
 	logger.Log.Infow("[grpc] stop", "addr", s.addr)
-	s.grpcServer.Stop()
+	s.stop().Stop()
 }
@@ -0,0 +1,565 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// grpcWebCall posts a single gRPC-Web request and returns the message frames and trailer frame.
+func grpcWebCall(t *testing.T, url, contentType string, msg proto.Message) (frames [][]byte, trailer string) {
+	t.Helper()
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	body := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(b)))
+	body = append(body, b...)
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Grpc-Web", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if text {
+		// grpc-web-text responses are base64 chunks, one per flushed frame (each may carry padding).
+		var decoded []byte
+		for len(raw) > 0 {
+			n := len(raw)
+			if i := bytes.IndexByte(raw, '='); i >= 0 {
+				n = i
+				for n < len(raw) && raw[n] == '=' {
+					n++
+				}
+			}
+			d, err := base64.StdEncoding.DecodeString(string(raw[:n]))
+			if err != nil {
+				t.Fatalf("bad base64 body: %v", err)
+			}
+			decoded = append(decoded, d...)
+			raw = raw[n:]
+		}
+		raw = decoded
+	}
+
+	for len(raw) >= 5 {
+		flag, n := raw[0], binary.BigEndian.Uint32(raw[1:5])
+		payload := raw[5 : 5+n]
+		raw = raw[5+n:]
+		if flag&0x80 != 0 {
+			trailer = string(payload)
+			continue
+		}
+		frames = append(frames, payload)
+	}
+	return frames, trailer
+}
+
+func TestGRPCWeb(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(6), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(256)}
+	srv := NewGRPCServer(":0", NewMockLlmService(cfg))
+	ts := httptest.NewServer(NewHTTPMux(cfg, srv.GRPCWebHandler(), nil, nil))
+	defer ts.Close()
+
+	req := &llmv1.ChatCompletionRequest{Model: "web", UserPrompt: "hello web", MaxTokens: 12}
+	expected := buildOutput(cfg, buildPromptForTokens(req), 12)
+
+	// Unary over binary gRPC-Web.
+	frames, trailer := grpcWebCall(t, ts.URL+llmv1.LlmService_ChatCompletion_FullMethodName, "application/grpc-web+proto", req)
+	if len(frames) != 1 || !strings.Contains(trailer, "grpc-status: 0") {
+		t.Fatalf("unexpected unary response: %d frames, trailer %q", len(frames), trailer)
+	}
+	var resp llmv1.ChatCompletionResponse
+	if err := proto.Unmarshal(frames[0], &resp); err != nil {
+		t.Fatalf("bad unary message: %v", err)
+	}
+	if resp.GetOutputText() != expected {
+		t.Fatalf("unary output mismatch: %q", resp.GetOutputText())
+	}
+
+	// Server streaming over grpc-web-text.
+	frames, trailer = grpcWebCall(t, ts.URL+llmv1.LlmService_ChatCompletionStream_FullMethodName, "application/grpc-web-text", req)
+	if !strings.Contains(trailer, "grpc-status: 0") {
+		t.Fatalf("unexpected stream trailer: %q", trailer)
+	}
+	var assembled strings.Builder
+	var done bool
+	for _, f := range frames {
+		var ch llmv1.ChatCompletionChunkResponse
+		if err := proto.Unmarshal(f, &ch); err != nil {
+			t.Fatalf("bad stream message: %v", err)
+		}
+		switch ch.GetType() {
+		case string(events.OutputTextDelta):
+			assembled.WriteString(ch.GetText())
+		case string(events.OutputTextDone):
+			done = true
+		}
+	}
+	if !done || assembled.String() != expected {
+		t.Fatalf("stream mismatch: done=%v got %q", done, assembled.String())
+	}
+}
+
+func TestMaxRecvMsgSize(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64), GRPCMaxRecvMB: 1}
+	client := startTestServer(t, cfg)
+	const limit = 1024 * 1024
+
+	under := &llmv1.ChatCompletionRequest{UserPrompt: strings.Repeat("a", limit-64), MaxTokens: 4}
+	if _, err := client.ChatCompletion(context.Background(), under); err != nil {
+		t.Fatalf("request under the limit failed: %v", err)
+	}
+
+	over := &llmv1.ChatCompletionRequest{UserPrompt: strings.Repeat("a", limit+1), MaxTokens: 4}
+	if _, err := client.ChatCompletion(context.Background(), over); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted over the limit, got %v", err)
+	}
+}
+
+func TestKeepaliveParamsFromConfig(t *testing.T) {
+	cfg := config.Config{
+		KeepaliveMaxIdleMs:           1000,
+		KeepaliveMaxAgeMs:            2000,
+		KeepaliveMaxAgeGraceMs:       3000,
+		KeepaliveTimeMs:              4000,
+		KeepaliveTimeoutMs:           5000,
+		KeepaliveMinTimeMs:           6000,
+		KeepalivePermitWithoutStream: true,
+	}
+	sp, ep := keepaliveParams(cfg)
+	wantSP := keepalive.ServerParameters{
+		MaxConnectionIdle:     time.Second,
+		MaxConnectionAge:      2 * time.Second,
+		MaxConnectionAgeGrace: 3 * time.Second,
+		Time:                  4 * time.Second,
+		Timeout:               5 * time.Second,
+	}
+	if sp != wantSP {
+		t.Fatalf("server parameters mismatch: %+v", sp)
+	}
+	if ep != (keepalive.EnforcementPolicy{MinTime: 6 * time.Second, PermitWithoutStream: true}) {
+		t.Fatalf("enforcement policy mismatch: %+v", ep)
+	}
+
+	// CONN_MAX_AGE_S applies unless GRPC_KEEPALIVE_MAX_AGE_MS is set.
+	if sp, _ := keepaliveParams(config.Config{ConnMaxAgeS: 30}); sp.MaxConnectionAge != 30*time.Second {
+		t.Fatalf("expected CONN_MAX_AGE_S to set the max age, got %v", sp.MaxConnectionAge)
+	}
+	if sp, _ = keepaliveParams(config.Config{ConnMaxAgeS: 30, KeepaliveMaxAgeMs: 500}); sp.MaxConnectionAge != 500*time.Millisecond {
+		t.Fatalf("expected GRPC_KEEPALIVE_MAX_AGE_MS to win, got %v", sp.MaxConnectionAge)
+	}
+}
+
+func TestStreamSurvivesKeepaliveAndMaxAge(t *testing.T) {
+	// Server pings after 1s of inactivity (the gRPC minimum) and sends GOAWAY after 300ms of connection
+	// age; the in-flight stream must complete within the grace period.
+	cfg := config.Config{
+		ChunkSize:              config.Int(16),
+		StreamDelayMinMs:       config.Int(350),
+		StreamDelayMaxMs:       config.Int(350),
+		DebugOutputChars:       64,
+		KeepaliveTimeMs:        1000,
+		KeepaliveTimeoutMs:     1000,
+		KeepaliveMaxAgeMs:      300,
+		KeepaliveMaxAgeGraceMs: 5000,
+	}
+	client := startTestServer(t, cfg)
+
+	stream, err := client.ChatCompletionStream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "keepalive"})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	var done bool
+	for {
+		ch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream broke during keepalive/GOAWAY: %v", err)
+		}
+		done = done || ch.GetType() == string(events.OutputTextDone)
+	}
+	if !done {
+		t.Fatalf("stream ended without a done chunk")
+	}
+}
+
+func TestDrainRejectsNewRPCs(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	ready := NewReadiness(0)
+	_, conn := startReadyServer(t, cfg, ready)
+	client := llmv1.NewLlmServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8}
+	if _, err := client.ChatCompletion(ctx, req); err != nil {
+		t.Fatalf("unexpected error before drain: %v", err)
+	}
+	ready.Drain()
+	if _, err := client.ChatCompletion(ctx, req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable while draining, got %v", err)
+	}
+	stream, err := client.ChatCompletionStream(ctx, req)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable stream while draining, got %v", err)
+	}
+}
+
+func TestShutdownForcesStopAtDeadline(t *testing.T) {
+	// 512 chars in 8-char chunks, 100ms apart: the stream would take ~6s.
+	cfg := config.Config{ChunkSize: config.Int(8), DebugOutputChars: 512, MaxOutputChars: config.Int(512), StreamDelayMinMs: config.Int(100), StreamDelayMaxMs: config.Int(100)}
+	ready := NewReadiness(0)
+	srv, conn := startReadyServer(t, cfg, ready)
+	client := llmv1.NewLlmServiceClient(conn)
+
+	stream, err := client.ChatCompletionStream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "slow", MaxTokens: 512})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first chunk failed: %v", err)
+	}
+	if n := ready.ActiveStreams(); n != 1 {
+		t.Fatalf("expected 1 active stream, got %d", n)
+	}
+
+	const grace = 300 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	start := time.Now()
+	err = srv.Shutdown(ctx)
+	elapsed := time.Since(start)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected forced stop at the deadline, got %v", err)
+	}
+	if elapsed < grace || elapsed > 3*time.Second {
+		t.Fatalf("forced stop should fire at the %v deadline, took %v", grace, elapsed)
+	}
+	if ready.State() != StateDraining {
+		t.Fatalf("expected draining state, got %s", ready.State())
+	}
+
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		t.Fatalf("in-flight stream should be cut off, not completed")
+	}
+}
+
+func TestShutdownWithoutStreamsReturnsImmediately(t *testing.T) {
+	srv, _ := startReadyServer(t, config.Config{}, NewReadiness(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("idle shutdown should not wait for the deadline, took %v", elapsed)
+	}
+}
+
+func TestHTTPShutdownForcesCloseAtDeadline(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), DebugOutputChars: 512, MaxOutputChars: config.Int(512), StreamDelayMinMs: config.Int(100), StreamDelayMaxMs: config.Int(100)}
+	ready := NewReadiness(0)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	srv := NewHTTPServer(lis.Addr().String(), NewHTTPMux(cfg, nil, nil, ready))
+	srv.SetReadiness(ready)
+	go func() { _ = srv.httpServer.Serve(lis) }()
+
+	resp, err := http.Post("http://"+lis.Addr().String()+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"messages":[{"role":"user","content":"slow"}],"max_tokens":512,"stream":true}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := resp.Body.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("first byte failed: %v", err)
+	}
+
+	const grace = 300 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected forced close at the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < grace || elapsed > 3*time.Second {
+		t.Fatalf("forced close should fire at the %v deadline, took %v", grace, elapsed)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatalf("in-flight SSE stream should be cut off")
+	}
+}
+
+func TestPortZeroExposesBoundAddr(t *testing.T) {
+	t.Setenv("PORT", "0")
+	t.Setenv("HTTP_PORT", "0")
+	cfg := config.LoadConfig()
+	cfg.MaxOutputChars = config.Int(64)
+
+	srv := NewGRPCServer(fmt.Sprintf("127.0.0.1:%d", cfg.Port), NewMockLlmService(cfg))
+	if srv.Addr() != nil {
+		t.Fatalf("Addr should be nil before Listen")
+	}
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	addr := srv.Addr().(*net.TCPAddr)
+	if addr.Port == 0 {
+		t.Fatalf("expected a resolved port, got %v", addr)
+	}
+	go func() { _ = srv.Run() }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := llmv1.NewLlmServiceClient(conn).ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8})
+	if err != nil || resp.GetOutputText() == "" {
+		t.Fatalf("chat completion over %v failed: %v", addr, err)
+	}
+
+	httpSrv := NewHTTPServer(fmt.Sprintf("127.0.0.1:%d", cfg.HTTPPort), NewHTTPMux(cfg, nil, nil, nil))
+	if err := httpSrv.Listen(); err != nil {
+		t.Fatalf("http listen failed: %v", err)
+	}
+	go func() { _ = httpSrv.Run() }()
+	t.Cleanup(func() { _ = httpSrv.Shutdown(context.Background()) })
+	res, err := http.Get("http://" + httpSrv.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("healthz over %v failed: %v", httpSrv.Addr(), err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected healthz status: %d", res.StatusCode)
+	}
+}
+
+func TestForcedRestartDropsStreamsAndRelistens(t *testing.T) {
+	// 512 chars in 8-char chunks, 50ms apart: the stream would take ~3s, the restart comes first.
+	cfg := config.Config{ChunkSize: config.Int(8), DebugOutputChars: 512, MaxOutputChars: config.Int(512), StreamDelayMinMs: config.Int(50), StreamDelayMaxMs: config.Int(50)}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	ready := NewReadiness(0)
+	opts, err := ServerOptions(cfg, nil, ready)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), NewMockLlmService(cfg), opts...)
+	srv.SetReadiness(ready)
+	srv.SetForcedRestartInterval(400 * time.Millisecond)
+	served := make(chan error, 1)
+	go func() { served <- srv.RunWithListener(lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := llmv1.NewLlmServiceClient(conn)
+
+	stream, err := client.ChatCompletionStream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "churn", MaxTokens: 512})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		ch, err := stream.Recv()
+		if err == nil {
+			if ch.GetType() == string(events.OutputTextDone) {
+				t.Fatalf("stream completed before the forced restart")
+			}
+			continue
+		}
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected the restart to cut the stream with Unavailable, got %v", err)
+		}
+		break
+	}
+
+	// The server is back on the same address with every service registered.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "again", MaxTokens: 8}, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("reconnect after the restart failed: %v", err)
+	}
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("health service missing after the restart: %v", err)
+	}
+
+	srv.Stop()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("expected a clean exit after Stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunWithListener did not return after Stop")
+	}
+}
+
+// TestGRPCReflection checks the reflection service is served by default and left out with
+// GRPC_REFLECTION=false.
+func TestGRPCReflection(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		reflection *bool
+		want       bool
+	}{
+		{"default", nil, true},
+		{"enabled", config.Bool(true), true},
+		{"disabled", config.Bool(false), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Config{GRPCReflection: tc.reflection}
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen failed: %v", err)
+			}
+			opts, err := ServerOptions(cfg, nil, nil)
+			if err != nil {
+				t.Fatalf("server options: %v", err)
+			}
+			srv := NewGRPCServer(lis.Addr().String(), NewMockLlmService(cfg), opts...)
+			go func() { _ = srv.RunWithListener(lis) }()
+			t.Cleanup(srv.Stop)
+
+			conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatalf("dial failed: %v", err)
+			}
+			defer conn.Close()
+			stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+			if err != nil {
+				t.Fatalf("reflection stream failed: %v", err)
+			}
+			err = stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+			if err == nil {
+				var resp *reflectionpb.ServerReflectionResponse
+				if resp, err = stream.Recv(); err == nil {
+					var names []string
+					for _, s := range resp.GetListServicesResponse().GetService() {
+						names = append(names, s.GetName())
+					}
+					if !slices.Contains(names, llmv1.LlmService_ServiceDesc.ServiceName) {
+						t.Fatalf("reflection doesn't list LlmService: %v", names)
+					}
+				}
+			}
+			if tc.want && err != nil {
+				t.Fatalf("reflection failed: %v", err)
+			}
+			if !tc.want && status.Code(err) != codes.Unimplemented {
+				t.Fatalf("expected Unimplemented without reflection, got %v", err)
+			}
+
+			// LlmService is served either way.
+			if _, err := llmv1.NewLlmServiceClient(conn).ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 4}); err != nil {
+				t.Fatalf("ChatCompletion failed: %v", err)
+			}
+		})
+	}
+}
+
+// TestInterceptorOrder checks that interceptors given after ServerOptions run inside the built-in
+// ones: only admitted calls reach them, and their panics are recovered.
+func TestInterceptorOrder(t *testing.T) {
+	cfg := config.Config{APIKeys: []string{"k1"}}
+	opts, err := ServerOptions(cfg, NewLimits(cfg), nil)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	var reached atomic.Int32
+	opts = append(opts, WithServerOptions(WithUnaryInterceptors(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		reached.Add(1)
+		if req.(*llmv1.ChatCompletionRequest).GetUserPrompt() == "panic" {
+			panic("interceptor bug")
+		}
+		return handler(ctx, req)
+	})))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	srv := NewGRPCServer(lis.Addr().String(), NewMockLlmService(cfg), opts...)
+	go func() { _ = srv.RunWithListener(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := llmv1.NewLlmServiceClient(conn)
+	authed := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer k1")
+
+	if _, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 4}); status.Code(err) != codes.Unauthenticated || reached.Load() != 0 {
+		t.Fatalf("an unauthenticated call reached the interceptor (%d) or passed: %v", reached.Load(), err)
+	}
+	if _, err := client.ChatCompletion(authed, &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 4}); err != nil || reached.Load() != 1 {
+		t.Fatalf("an authenticated call failed or skipped the interceptor (%d): %v", reached.Load(), err)
+	}
+	if _, err := client.ChatCompletion(authed, &llmv1.ChatCompletionRequest{UserPrompt: "panic", MaxTokens: 4}); status.Code(err) != codes.Internal {
+		t.Fatalf("expected the interceptor's panic to be recovered as Internal, got %v", err)
+	}
+}
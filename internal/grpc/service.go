@@ -2,16 +2,24 @@ package grpc
 
 import (
 	"context"
-	"errors"
 	"github.com/yungtweek/llm-simulator/internal/config"
 	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
 	"github.com/yungtweek/llm-simulator/internal/mock"
+	"math"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/yungtweek/llm-simulator/events"
 	llmv1 "github.com/yungtweek/llm-simulator/gen"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
@@ -25,25 +33,102 @@ import (
 //   - server-streaming chunked deltas
 //
 // This is a mock/benchmark tool, so behavior is intentionally deterministic-ish
-// and configurable via Config.
+// and configurable via Config. Each RPC works on the config loaded from live when it started
+// (see Config), so runtime updates only affect later requests.
 type MockLlmService struct {
 	llmv1.UnimplementedLlmServiceServer
-	cfg config.Config
+	cfg     config.Config  // snapshot used by the helpers
+	live    *config.Holder // source of the per-request snapshots
+	kill    *killSwitch    // admin FailAll/PauseAll
+	rec     *Recorder      // RECORD_FILE, nil when off
+	replay  *Replayer      // REPLAY_FILE, nil when off
+	tenants *Tenants       // TENANT_PROFILES, nil when off
+	debug   *mock.Debug    // the parameters drawn for the request, when it asked for them (x-debug)
+	rng     *mock.Rand     // the request's own source of draws (mock_overrides.seed), nil for the shared one
+
+	priority priority // x-priority, scaling the queue delay
+	tenant   string   // the TENANT_PROFILES profile the request runs on (see admitTenant)
+
+	requestID, clientID string // the RPC's request ID and client request ID (see identify)
 }
 
 func NewMockLlmService(cfg config.Config) *MockLlmService {
-	return &MockLlmService{cfg: cfg}
+	return &MockLlmService{cfg: cfg, live: config.NewHolder(cfg), kill: newKillSwitch()}
+}
+
+// SetReadiness makes ready report StateDown while a kill switch (AdminService FailAll/PauseAll) is
+// active.
+func (s *MockLlmService) SetReadiness(ready *Readiness) {
+	s.kill.setReadiness(ready)
+}
+
+// Config returns the holder the service reads its config from. Updates to it (see AdminService)
+// apply to every request started afterwards.
+func (s *MockLlmService) Config() *config.Holder {
+	return s.live
+}
+
+// snapshot returns a copy of s bound to the current config for the duration of one request. The
+// handlers apply the request's tenant profile, then the active FAULT_SCHEDULE faults, on top (see
+// resolveConfig).
+func (s *MockLlmService) snapshot() *MockLlmService {
+	return &MockLlmService{cfg: s.live.Load(), live: s.live, kill: s.kill, rec: s.rec, replay: s.replay, tenants: s.tenants}
+}
+
+// resolveConfig applies the tenant profile of an RPC (see admitTenant), then the active
+// FAULT_SCHEDULE faults, to s.cfg.
+func (s *MockLlmService) resolveConfig(ctx context.Context) error {
+	if err := s.admitTenant(ctx); err != nil {
+		return err
+	}
+	s.cfg = applyFaults(s.cfg)
+	return nil
 }
 
-func (s *MockLlmService) ChatCompletion(ctx context.Context, req *llmv1.ChatCompletionRequest) (*llmv1.ChatCompletionResponse, error) {
+func (s *MockLlmService) ChatCompletion(ctx context.Context, req *llmv1.ChatCompletionRequest) (resp *llmv1.ChatCompletionResponse, err error) {
+	s = s.snapshot()
+	s.identify(ctx, req)
+	s.priority = grpcPriority(ctx)
 	start := time.Now()
-	logger.Log.Infow("[grpc][ChatCompletion] start", "model", req.GetModel(), "maxTokens", req.GetMaxTokens())
+	rec := s.newRecord(ctx, "ChatCompletion", req, start)
+	defer func() { s.finishRecord(rec, start, err) }()
+	defer func() { observeTenant(s.tenant, status.Code(err).String(), err == nil) }()
+	if err := s.resolveConfig(ctx); err != nil {
+		return nil, err
+	}
+	if s.debug = s.grpcDebug(ctx); s.debug != nil {
+		defer func() { _ = grpc.SetTrailer(ctx, debugTrailer(s.debug)) }()
+	}
+	if err := s.applyRequestOverrides(ctx, req); err != nil {
+		return nil, err
+	}
+	// Per-phase lines go to a sampled logger (LOG_SAMPLE_RATE) at debug; errors always log, at warn or
+	// error, to Log. Both carry the client request ID.
+	log, errLog := withClientRequestID(logger.Sample(s.cfg.LogSampleRate), s.clientID), withClientRequestID(logger.Log, s.clientID)
+	log.Debugw("[grpc][ChatCompletion] start", "model", req.GetModel(), "maxTokens", req.GetMaxTokens(), "client", peerIdentity(ctx), "priority", s.priority)
+
+	if _, err := s.kill.wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.checkDeadline(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkSampling(s.cfg, req); err != nil {
+		return nil, err
+	}
+	if err := checkMessages(s.cfg, req); err != nil {
+		return nil, err
+	}
 
 	// Error injection (before any work).
-	if shouldFail(s.cfg.ErrorRate) {
-		logger.Log.Infow("[grpc][ChatCompletion] injected error", "mode", s.cfg.ErrorMode)
-		return nil, status.Error(pickGrpcErrorCode(s.cfg.ErrorMode), "mock error")
+	if s.rng.Chance(s.cfg.ErrorRate) {
+		st := injectedStatus(s.rng, s.cfg.ErrorMode)
+		errLog.Warnw("[grpc][ChatCompletion] injected error", "mode", s.cfg.ErrorMode, "code", st.Code())
+		countInjectedGRPCError(ctx, s.cfg.ErrorMode, st.Code())
+		return nil, st.Err()
 	}
+	ctx, cancel := withMaxDuration(ctx, s.cfg, start)
+	defer cancel()
 
 	maxTokens := req.GetMaxTokens()
 	if maxTokens <= 0 {
@@ -56,40 +141,71 @@ func (s *MockLlmService) ChatCompletion(ctx context.Context, req *llmv1.ChatComp
 	// Simulate compute latency.
 	prompt := buildPromptForTokens(req)
 	if s.cfg.Randomize {
-		effectiveMaxTokens = pickTargetTokens(maxTokens, len([]rune(prompt)))
+		effectiveMaxTokens = int32(s.rng.PickTargetTokens(int(maxTokens), len([]rune(prompt))))
 	}
-	out := mock.BuildOutput(prompt, int(effectiveMaxTokens), s.cfg.EchoPrompt, s.cfg.StrictTokenMode, s.cfg.DebugOutputChars, s.cfg.MaxOutputChars)
+	recordTokens(s.debug, int(maxTokens), int(effectiveMaxTokens))
+	out := buildOutput(s.cfg, prompt, int(effectiveMaxTokens))
 
-	pt := int32(mock.ApproxTokens(prompt))
+	pt := int32(promptTokens(s.cfg, req))
 	ct := int32(mock.ApproxTokens(out))
+	rec.output(int(pt), int(ct), len(out))
 
-	// Simulate total latency (roughly): base+jitter + TTFT + generation time.
-	computeMs := s.baseDelayMs() + s.jitterMs() + s.ttftMs()
-	// Optional per-token overhead (e.g., server-side processing).
-	computeMs += s.perTokenDelayMs(int(ct)) * int(ct)
-	// Token generation time from TokensPerSec.
-	if tps := s.tokensPerSec(); tps > 0 {
-		computeMs += int((ct * 1000) / int32(tps))
+	// Simulate total latency as queue (base+jitter) -> prefill (TTFT) -> decode, measuring each phase.
+	queue := sleepMeasured(ctx, time.Duration(s.queueMs())*time.Millisecond)
+	var prefill, generation time.Duration
+	if ctx.Err() == nil {
+		prefill = sleepMeasured(ctx, time.Duration(s.ttftMs())*time.Millisecond)
 	}
-	sleepWithContext(ctx, time.Duration(computeMs)*time.Millisecond)
-	if err := ctx.Err(); err != nil {
-		return nil, err
+	firstToken := time.Now()
+	var share float64 // of the output generated
+	if ctx.Err() == nil {
+		planned := time.Duration(s.generationMs(int(ct))) * time.Millisecond
+		generation = generate(ctx, s.cfg, int(ct), planned)
+		share = generatedShare(generation, planned)
+	}
+	rec.phases(queue, prefill, firstToken.Sub(start), generation)
+	if err := ctx.Err(); err != nil && !capped(ctx) {
+		return nil, status.FromContextError(err).Err()
+	}
+	// Cut by MAX_REQUEST_DURATION_MS: the response holds what was generated by then.
+	finish := events.FinishStop
+	if capped(ctx) {
+		out = truncateOutput(out, share)
+		ct = int32(mock.ApproxTokens(out))
+		rec.output(int(pt), int(ct), len(out))
+		finish = cappedFinishReason(s.cfg)
+		log.Debugw("[grpc][ChatCompletion] truncated", "maxRequestDurationMs", s.cfg.MaxRequestDurationMs, "tokens", ct)
 	}
 
-	resp := &llmv1.ChatCompletionResponse{
-		OutputText:       out,
-		FinishReason:     "stop",
-		PromptTokens:     pt,
-		CompletionTokens: ct,
-		TotalTokens:      pt + ct,
-		LatencyMs:        time.Since(start).Milliseconds(),
+	_ = grpc.SetHeader(ctx, s.responseHeader(req))
+	resp = &llmv1.ChatCompletionResponse{
+		OutputText:        out,
+		FinishReason:      string(finish),
+		PromptTokens:      pt,
+		CompletionTokens:  ct,
+		TotalTokens:       pt + ct,
+		LatencyMs:         time.Since(start).Milliseconds(),
+		QueueMs:           queue.Milliseconds(),
+		PromptEvalMs:      prefill.Milliseconds(),
+		TtftMs:            firstToken.Sub(start).Milliseconds(),
+		GenerationMs:      generation.Milliseconds(),
+		SimulatedTtftMs:   (queue + prefill).Milliseconds(),
+		Sampling:          requestSampling(req),
+		Id:                responseID(),
+		Created:           start.Unix(),
+		SystemFingerprint: systemFingerprint(s.cfg),
+		ClientRequestId:   s.clientID,
 	}
-	logger.Log.Infow("[grpc][ChatCompletion] completed", "latencyMs", resp.LatencyMs, "tokens", resp.TotalTokens)
+	_ = grpc.SetTrailer(ctx, usageTrailer(int(pt), int(ct), resp.LatencyMs))
+	log.Debugw("[grpc][ChatCompletion] completed", "latencyMs", resp.LatencyMs, "tokens", resp.TotalTokens)
 	return resp, nil
 }
 
 func (s *MockLlmService) ChatCompletionStream(req *llmv1.ChatCompletionRequest, stream llmv1.LlmService_ChatCompletionStreamServer) (err error) {
+	s = s.snapshot()
 	ctx := stream.Context()
+	s.identify(ctx, req)
+	s.priority = grpcPriority(ctx)
 	start := time.Now()
 	var peerAddr string
 	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
@@ -97,63 +213,229 @@ func (s *MockLlmService) ChatCompletionStream(req *llmv1.ChatCompletionRequest,
 	} else {
 		peerAddr = "unknown"
 	}
-	logger.Log.Infow("[grpc][ChatCompletionStream] start", "peer", peerAddr, "model", req.GetModel(), "maxTokens", req.GetMaxTokens())
+	// Per-phase lines go to a sampled logger (LOG_SAMPLE_RATE) at debug; failures always log, at info
+	// (canceled) to error, to Log. Both carry the client request ID.
+	log, errLog := withClientRequestID(logger.Sample(s.cfg.LogSampleRate), s.clientID), withClientRequestID(logger.Log, s.clientID)
+	log.Debugw("[grpc][ChatCompletionStream] start", "peer", peerAddr, "client", peerIdentity(ctx), "model", req.GetModel(), "maxTokens", req.GetMaxTokens(), "priority", s.priority)
+	rec := s.newRecord(ctx, "ChatCompletionStream", req, start)
+	defer func() { s.finishRecord(rec, start, err) }()
+	defer func() { observeTenant(s.tenant, status.Code(err).String(), err == nil) }()
+	if err := s.resolveConfig(ctx); err != nil {
+		return err
+	}
+	if s.debug = s.grpcDebug(ctx); s.debug != nil {
+		defer func() { stream.SetTrailer(debugTrailer(s.debug)) }()
+	}
+	if err := s.applyRequestOverrides(ctx, req); err != nil {
+		return err
+	}
+	types := events.For(events.Naming(s.cfg.EventNaming))
+
+	// Every chunk of the stream carries the same id, created time, fingerprint and client request ID.
+	id, created, fingerprint := responseID(), start.Unix(), systemFingerprint(s.cfg)
+	stamp := func(c *llmv1.ChatCompletionChunkResponse) *llmv1.ChatCompletionChunkResponse {
+		c.Id, c.Created, c.SystemFingerprint, c.ClientRequestId = id, created, fingerprint, s.clientID
+		return c
+	}
 
+	// sendFailed marks a broken stream (or one cut off for reading too slowly); doneSent a completed
+	// one. Neither gets a failed chunk. blocked is the time spent in Send, waiting on the client's
+	// flow control rather than on pacing.
+	var sendFailed, doneSent bool
+	var sent sendCounters
+	var keepalive *streamKeepalive // GRPC_KEEPALIVE_CHUNK_MS, nil when off
+	var blocked time.Duration
+	sendChunk := stream.Send
+	if ms := s.cfg.SlowConsumerAbortMs; ms > 0 {
+		w := newSendWatchdog(stream, time.Duration(ms)*time.Millisecond)
+		defer w.close()
+		sendChunk = w.send
+	}
+	transmit := func(c *llmv1.ChatCompletionChunkResponse) error {
+		t0 := time.Now()
+		err := sendChunk(stamp(c))
+		blocked += time.Since(t0)
+		if err != nil {
+			sendFailed = true
+		}
+		return err
+	}
+	send := func(c *llmv1.ChatCompletionChunkResponse) error {
+		if err := transmit(c); err != nil {
+			return err
+		}
+		sent.add(c.GetText())
+		return nil
+	}
+
+	// The prompt tokens and the choices, once known, for the usage of a stream that fails.
+	var pt int32
+	var choices []*streamChoice
 	defer func() {
-		// Log termination exactly once for all outcomes.
-		switch {
-		case err == nil:
-			logger.Log.Infow("[grpc][ChatCompletionStream] done", "peer", peerAddr)
-		case errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled:
-			logger.Log.Infow("[grpc][ChatCompletionStream] canceled", "peer", peerAddr, "err", err)
-		case errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded:
-			logger.Log.Warnw("[grpc][ChatCompletionStream] deadline_exceeded", "peer", peerAddr, "err", err)
+		// Log termination exactly once for all outcomes, with what was sent before it.
+		// Context errors are returned as statuses (status.FromContextError), so the code is enough.
+		metrics.SendBlocked.WithLabelValues("ChatCompletionStream").Observe(blocked.Seconds())
+		fields := append([]any{"peer", peerAddr}, sent.fields()...)
+		fields = append(fields, "sendBlockedMs", blocked.Milliseconds())
+		if keepalive != nil {
+			fields = append(fields, "keepalivesSent", sent.keepalives)
+		}
+		switch status.Code(err) {
+		case codes.OK:
+			log.Debugw("[grpc][ChatCompletionStream] done", fields...)
+		case codes.Canceled:
+			errLog.Infow("[grpc][ChatCompletionStream] canceled", append(fields, "err", err)...)
+		case codes.DeadlineExceeded:
+			errLog.Warnw("[grpc][ChatCompletionStream] deadline_exceeded", append(fields, "err", err)...)
 		default:
-			logger.Log.Errorw("[grpc][ChatCompletionStream] error", "peer", peerAddr, "err", err)
+			errLog.Errorw("[grpc][ChatCompletionStream] error", append(fields, "err", err)...)
 		}
 
-		// Best-effort: emit a final failed chunk so workers can finalize state.
-		if err != nil {
-			reason := err.Error()
-			_ = stream.Send(&llmv1.ChatCompletionChunkResponse{
-				Type:         "failed",
-				Text:         "",
-				Index:        0,
-				FinishReason: reason,
-			})
+		if err == nil || status.Code(err) == codes.Canceled {
+			return
+		}
+		// The usage of a failed stream is what it delivered: the prompt and the deltas sent. Once
+		// deltas went out, the status carries it too, for clients that stop at the error.
+		ct := int32(choicesSentTokens(choices))
+		latency := time.Since(start).Milliseconds()
+		if sent.chunks > 0 {
+			err = withPartialUsage(err, int(pt), int(ct), latency)
+		}
+
+		// Best-effort: emit a final failed chunk so workers can finalize state. Skipped when the
+		// client canceled, its deadline passed or the stream broke, since nobody would read it.
+		if config.Or(s.cfg.EmitFailedChunk, true) && status.Code(err) != codes.DeadlineExceeded && !sendFailed && !doneSent {
+			c := failedChunk(err, types.Failed)
+			c.EmittedAtUnixMs = emittedAt(s.cfg)
+			c.ChunksSent, c.BytesSent, c.TokensSent = int32(sent.chunks), sent.bytes, int32(sent.tokens)
+			c.PromptTokens, c.CompletionTokens, c.TotalTokens = pt, ct, pt+ct
+			c.LatencyMs = latency
+			_ = stream.Send(stamp(c))
 		}
 	}()
 
+	if _, err := s.kill.wait(ctx); err != nil {
+		return err
+	}
+	if err := s.checkDeadline(ctx); err != nil {
+		return err
+	}
+	if err := checkSampling(s.cfg, req); err != nil {
+		return err
+	}
+	if err := checkMessages(s.cfg, req); err != nil {
+		return err
+	}
+	if n := req.GetN(); n < 0 || n > maxChoices {
+		return status.Errorf(codes.InvalidArgument, "n must be within [0, %d], got %d", maxChoices, n)
+	}
+
 	// Error injection (before sending any chunks).
-	if shouldFail(s.cfg.ErrorRate) {
-		logger.Log.Infow("[grpc][ChatCompletionStream] injected error", "mode", s.cfg.ErrorMode)
-		return status.Error(pickGrpcErrorCode(s.cfg.ErrorMode), "mock error")
+	if s.rng.Chance(s.cfg.ErrorRate) {
+		st := injectedStatus(s.rng, s.cfg.ErrorMode)
+		errLog.Warnw("[grpc][ChatCompletionStream] injected error", "mode", s.cfg.ErrorMode, "code", st.Code())
+		countInjectedGRPCError(ctx, s.cfg.ErrorMode, st.Code())
+		return st.Err()
 	}
+	ctx, cancel := withMaxDuration(ctx, s.cfg, start)
+	defer cancel()
 
 	maxTokens := req.GetMaxTokens()
 	if maxTokens <= 0 {
 		maxTokens = int32(defaultInt(s.cfg.DefaultTokens, 128))
 	}
 
-	// Randomize output length in a chat-like distribution (short is common, long is rare).
-	effectiveMaxTokens := maxTokens
+	// Simulated KV cache: the prompt's share is taken up front, each delta's after it is sent.
+	prompt := buildPromptForTokens(req)
+	pt = int32(promptTokens(s.cfg, req))
+	kv := newKVCache(s.cfg)
+	defer kv.release()
+	if err = kv.grow(int(pt)); err != nil {
+		return err
+	}
+
+	// Response headers go out right after the pre-delay, just before the first chunk, or with
+	// GRPC_HEADERS_FIRST before it, so clients can tell time to headers from time to first token.
+	// A keep-alive chunk sent during the pre-delay takes them out with it.
+	var headerSent bool
+	sendHeader := func() error {
+		if headerSent {
+			return nil
+		}
+		if err := stream.SendHeader(s.responseHeader(req)); err != nil {
+			sendFailed = true
+			return err
+		}
+		headerSent = true
+		return nil
+	}
+	if s.cfg.GRPCHeadersFirst {
+		if err = sendHeader(); err != nil {
+			return err
+		}
+	}
 
-	// Delay before the first token.
+	// Delay before the first token: queue (base+jitter) then prefill (TTFT), each measured.
 	// IMPORTANT: keep this small so clients with short deadlines still receive the first chunk.
-	pre := time.Duration(s.baseDelayMs()+s.jitterMs()+s.ttftMs()) * time.Millisecond
-	logger.Log.Infow("[grpc][ChatCompletionStream] pre_delay", "peer", peerAddr, "delayMs", pre.Milliseconds())
+	// A replayed trace has no queue: its first chunk's offset is the whole TTFT.
+	queueDelay := time.Duration(s.queueMs()) * time.Millisecond
+	prefillDelay := time.Duration(s.ttftMs()) * time.Millisecond
+	// An endless stream generates filler instead of following a trace.
+	endless := wantsEndless(ctx, s.cfg, req)
+	var trace *Trace
+	if !endless {
+		trace = s.replay.pick(s.cfg, req.GetModel())
+	}
+	if trace != nil {
+		queueDelay, prefillDelay = 0, trace.offset(0)
+		if s.debug != nil {
+			s.debug.BaseDelayMs, s.debug.JitterMs, s.debug.TTFTMs = 0, 0, int(prefillDelay.Milliseconds())
+			s.debug.Overrides = append(s.debug.Overrides, overrideReplay)
+		}
+	}
+	// From here every sleep of the stream sends keep-alive chunks when it runs past the interval.
+	if ms := s.cfg.GRPCKeepaliveChunkMs; ms > 0 {
+		keepalive = &streamKeepalive{interval: time.Duration(ms) * time.Millisecond, start: time.Now(), sent: &sent}
+		keepalive.ping = func() error {
+			if err := sendHeader(); err != nil {
+				return err
+			}
+			return transmit(keepaliveChunk(s.cfg, types.Delta))
+		}
+		ctx = withKeepalive(ctx, keepalive)
+	}
+	pre := queueDelay + prefillDelay
+	var queue, prefill time.Duration
+	log.Debugw("[grpc][ChatCompletionStream] pre_delay", "peer", peerAddr, "delayMs", pre.Milliseconds())
 	if pre > 0 {
-		sleepWithContext(ctx, pre)
-		logger.Log.Infow("[grpc][ChatCompletionStream] pre_delay_done", "peer", peerAddr)
-		if err = ctx.Err(); err != nil {
-			logger.Log.Warnw("[grpc][ChatCompletionStream] context error during pre_delay", "peer", peerAddr, "err", err)
-			return err
+		queue = sleepMeasured(ctx, queueDelay)
+		if ctx.Err() == nil {
+			prefill = sleepMeasured(ctx, prefillDelay)
+		}
+		log.Debugw("[grpc][ChatCompletionStream] pre_delay_done", "peer", peerAddr)
+		if err := ctx.Err(); err != nil && !capped(ctx) {
+			errLog.Warnw("[grpc][ChatCompletionStream] context error during pre_delay", "peer", peerAddr, "err", err)
+			return status.FromContextError(err).Err()
 		}
 	}
+	observeTTFT("ChatCompletionStream", queue+prefill)
+	rec.phases(queue, prefill, 0, 0)
+	if err = sendHeader(); err != nil {
+		return err
+	}
 
-	prompt := buildPromptForTokens(req)
-	if s.cfg.Randomize {
-		effectiveMaxTokens = pickTargetTokens(maxTokens, len([]rune(prompt)))
+	// n > 1 streams several choices of the prompt, each sized on its own. Randomize output length in
+	// a chat-like distribution (short is common, long is rare). A replayed trace is a single choice.
+	n := 1
+	if trace == nil {
+		n = max(int(req.GetN()), 1)
+	}
+	targets := make([]int, n)
+	for i := range targets {
+		targets[i] = int(maxTokens)
+		if s.cfg.Randomize {
+			targets[i] = s.rng.PickTargetTokens(int(maxTokens), len([]rune(prompt)))
+		}
 	}
 
 	chunkSize := s.chunkSize()
@@ -162,77 +444,196 @@ func (s *MockLlmService) ChatCompletionStream(req *llmv1.ChatCompletionRequest,
 	}
 	if s.cfg.Randomize {
 		// Randomize chunk size a bit (+/- 33%) to vary stream shape.
-		if chunkSize > 1 {
-			j := chunkSize / 3
-			if j < 1 {
-				j = 1
-			}
-			chunkSize = (chunkSize - j) + mock.RandIntn(j*2+1)
-			if chunkSize < 1 {
-				chunkSize = 1
-			}
-		}
+		chunkSize = s.rng.JitterChunkSize(chunkSize)
 	}
+	recordTokens(s.debug, int(maxTokens), targets[0])
+	recordChunkSize(s.debug, chunkSize)
 
-	out := mock.BuildOutput(prompt, int(effectiveMaxTokens), s.cfg.EchoPrompt, s.cfg.StrictTokenMode, s.cfg.DebugOutputChars, s.cfg.MaxOutputChars)
-	logger.Log.Infow("[grpc][ChatCompletionStream] generated output", "peer", peerAddr, "outputLen", len(out), "chunkSize", chunkSize)
+	// The output is generated chunk by chunk (see mock.OutputStream), so long streams don't hold it.
+	// A replayed trace sizes it to the trace instead.
+	choices = make([]*streamChoice, n)
+	for i, target := range targets {
+		out := newOutputStream(s.cfg, prompt, target)
+		switch {
+		case endless:
+			out = mock.NewEndlessOutputStream(prompt, s.cfg.EchoPrompt)
+		case trace != nil:
+			out = newReplayOutput(s.cfg, prompt, trace)
+		}
+		choices[i] = &streamChoice{index: int32(i), out: out}
+	}
+	var ct, outLen int // an endless output is only measured once it stops
+	if endless {
+		log.Debugw("[grpc][ChatCompletionStream] endless output", "peer", peerAddr, "choices", n, "chunkSize", chunkSize)
+	} else {
+		ct, outLen = choicesOutput(choices)
+		log.Debugw("[grpc][ChatCompletionStream] generated output", "peer", peerAddr, "choices", n, "outputLen", outLen, "chunkSize", chunkSize)
+		rec.output(int(pt), ct, outLen)
+	}
 
-	pt := int32(mock.ApproxTokens(prompt))
-	ct := int32(mock.ApproxTokens(out))
+	// Each choice ends with its own done event (no full text; worker assembles from deltas and can
+	// check the digest).
+	sendDone := func(c *streamChoice, finish events.FinishReason) error {
+		if c.first.IsZero() { // cut before its first delta
+			c.first = time.Now()
+		}
+		c.done = true
+		latency := time.Since(start).Milliseconds()
+		ct := int32(c.out.Tokens())
+		digest := c.out.Digest()
+		log.Debugw("[grpc][ChatCompletionStream] sending done chunk", "peer", peerAddr, "index", c.index, "latencyMs", latency, "totalTokens", pt+ct)
+		return send(&llmv1.ChatCompletionChunkResponse{
+			Type:               string(types.Done),
+			Text:               "",
+			Index:              c.index,
+			FinishReason:       string(finish),
+			PromptTokens:       pt,
+			CompletionTokens:   ct,
+			TotalTokens:        pt + ct,
+			LatencyMs:          latency,
+			QueueMs:            queue.Milliseconds(),
+			PromptEvalMs:       prefill.Milliseconds(),
+			TtftMs:             c.first.Sub(start).Milliseconds(),
+			GenerationMs:       time.Since(c.first).Milliseconds(),
+			OutputSha256:       digest.SHA256,
+			OutputBytes:        int64(digest.Bytes),
+			EmittedAtUnixMs:    emittedAt(s.cfg),
+			FirstDeltaAtUnixMs: c.firstEmitted,
+			Sampling:           requestSampling(req),
+		})
+	}
 
-	// Stream content deltas.
+	// Stream content deltas, interleaving the choices by CHOICE_INTERLEAVE. They share one pacer, as
+	// if a single backend generated them together. Gzip clients get a simulated compression cost per
+	// chunk.
+	sched := &choiceScheduler{mode: s.cfg.ChoiceInterleave, choices: choices, last: -1, rng: s.rng}
+	pace := newStreamPacer(s.cfg)
+	pace.rng = s.rng
+	if isGzipRequest(ctx) {
+		pace.extra = time.Duration(s.cfg.GzipChunkDelayMs) * time.Millisecond
+	}
+	var firstSent, lastSent time.Time
+	var stalled time.Duration // replay: time held by stall faults, which moves the trace back
 	loggedFirstChunk := false
-	for i := 0; i < len(out); i += chunkSize {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	for i := 0; ctx.Err() == nil; i++ {
+		// A choice's done event follows the pacing of its last delta.
+		for _, c := range choices {
+			if !c.done && c.out.Remaining() == 0 {
+				if err = sendDone(c, events.FinishStop); err != nil {
+					return err
+				}
+			}
 		}
-
-		end := i + chunkSize
-		if end > len(out) {
-			end = len(out)
+		c := sched.next()
+		if c == nil {
+			break
+		}
+		size := chunkSize
+		if trace != nil {
+			if i == len(trace.Chunks) {
+				break
+			}
+			size = trace.Chunks[i].Chars
+		}
+		delta, ok := c.out.Next(size)
+		if !ok {
+			break
 		}
-		delta := out[i:end]
 
 		if !loggedFirstChunk {
-			logger.Log.Infow("[grpc][ChatCompletionStream] sending first chunk", "peer", peerAddr, "size", len(delta))
+			log.Debugw("[grpc][ChatCompletionStream] sending first chunk", "peer", peerAddr, "size", len(delta))
 			loggedFirstChunk = true
 		}
 
-		if err = stream.Send(&llmv1.ChatCompletionChunkResponse{
-			Type:  "output_text.delta",
-			Text:  delta,
-			Index: 0,
-		}); err != nil {
+		chunk := &llmv1.ChatCompletionChunkResponse{
+			Type:            string(types.Delta),
+			Text:            delta,
+			Index:           c.index,
+			EmittedAtUnixMs: emittedAt(s.cfg),
+		}
+		if err = send(chunk); err != nil {
 			return err
 		}
+		c.sent += len(delta)
+		now := time.Now()
+		rec.chunkSent(now)
+		if c.first.IsZero() {
+			c.first = now
+			c.firstEmitted = chunk.EmittedAtUnixMs
+		}
+		if firstSent.IsZero() {
+			firstSent = now
+		} else {
+			metrics.ChunkGap.WithLabelValues("ChatCompletionStream").Observe(now.Sub(lastSent).Seconds())
+		}
+		lastSent = now
+		if !endless { // an endless stream would fill any KV cache: it only holds the prompt's share
+			if err = kv.grow(mock.ApproxTokens(delta)); err != nil {
+				return err
+			}
+		}
 
-		// Optional chunk pacing.
-		s.sleepStreamGap(ctx, delta)
-		if err = ctx.Err(); err != nil {
-			return err
+		// Optional chunk pacing; a replayed trace sends each chunk at its recorded offset.
+		if trace == nil {
+			pace.wait(ctx, delta)
+		} else if i+1 < len(trace.Chunks) {
+			stalled += waitStall(ctx)
+			sleepWithContext(ctx, time.Until(start.Add(trace.offset(i+1)+stalled)))
 		}
 	}
+	// An endless stream never completes: whether the client or MAX_REQUEST_DURATION_MS stopped it,
+	// it ends canceled, without a done chunk.
+	if endless {
+		for _, c := range choices {
+			c.out.Truncate()
+		}
+		ct, outLen = choicesOutput(choices)
+		rec.output(int(pt), ct, outLen)
+		if !firstSent.IsZero() {
+			rec.phases(queue, prefill, firstSent.Sub(start), time.Since(firstSent))
+		}
+		if capped(ctx) {
+			return status.Error(codes.Canceled, "endless stream stopped by MAX_REQUEST_DURATION_MS")
+		}
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if err := ctx.Err(); err != nil && !capped(ctx) {
+		return status.FromContextError(err).Err()
+	}
 
-	// Emit a separate done event (no full text; worker assembles from deltas).
-	logger.Log.Infow(
-		"[grpc][ChatCompletionStream] sending done chunk",
-		"peer", peerAddr,
-		"latencyMs", time.Since(start).Milliseconds(),
-		"totalTokens", pt+ct,
-	)
-	if err = stream.Send(&llmv1.ChatCompletionChunkResponse{
-		Type:             "output_text.done",
-		Text:             "",
-		Index:            0,
-		FinishReason:     "stop",
-		PromptTokens:     pt,
-		CompletionTokens: ct,
-		TotalTokens:      pt + ct,
-		LatencyMs:        time.Since(start).Milliseconds(),
-	}); err != nil {
-		return err
+	// Cut by MAX_REQUEST_DURATION_MS: the choices still generating end with what was sent.
+	if capped(ctx) {
+		for _, c := range choices {
+			if !c.done && c.out.Remaining() > 0 {
+				c.out.Truncate()
+				c.truncated = true
+			}
+		}
+		ct, outLen = choicesOutput(choices)
+		rec.output(int(pt), ct, outLen)
+		if firstSent.IsZero() { // cut before the first delta
+			firstSent = time.Now()
+		}
+		log.Debugw("[grpc][ChatCompletionStream] truncated", "peer", peerAddr, "maxRequestDurationMs", s.cfg.MaxRequestDurationMs, "chunksSent", sent.chunks)
+	}
+	latency := time.Since(start).Milliseconds()
+	rec.phases(queue, prefill, firstSent.Sub(start), time.Since(firstSent))
+	stream.SetTrailer(usageTrailer(int(pt), ct, latency))
+	for _, c := range choices {
+		if c.done {
+			continue
+		}
+		finish := events.FinishStop
+		if c.truncated {
+			finish = cappedFinishReason(s.cfg)
+		}
+		if err = sendDone(c, finish); err != nil {
+			return err
+		}
+	}
+	doneSent = true
+	if types.Stop != "" {
+		return send(&llmv1.ChatCompletionChunkResponse{Type: string(types.Stop), EmittedAtUnixMs: emittedAt(s.cfg)})
 	}
 
 	return nil
@@ -240,100 +641,112 @@ func (s *MockLlmService) ChatCompletionStream(req *llmv1.ChatCompletionRequest,
 
 // ---- helpers ----
 
-// pickTargetTokens chooses a target token budget that feels like real chat:
-// short answers are common, long answers are rare.
-// It returns a value in [1, maxTokens]. If maxTokens <= 0, it uses 128.
-func pickTargetTokens(maxTokens int32, promptRunes int) int32 {
-	if maxTokens <= 0 {
-		maxTokens = 128
+// Response header keys: the request ID (the caller's x-request-id, or a generated one), the model and
+// the preset the request ran with. The client request ID (clientRequestIDKey) joins them when set.
+const (
+	requestIDKey = "x-request-id"
+	modelKey     = "x-model"
+	presetKey    = "x-preset"
+)
+
+// responseHeader returns the header metadata of an RPC.
+func (s *MockLlmService) responseHeader(req *llmv1.ChatCompletionRequest) metadata.MD {
+	model := req.GetModel()
+	if model == "" {
+		model = "mock-grpc"
+	}
+	md := metadata.Pairs(requestIDKey, s.requestID, modelKey, model, presetKey, s.cfg.Preset)
+	if s.clientID != "" {
+		md.Set(clientRequestIDKey, s.clientID)
 	}
+	return md
+}
 
-	// Base probabilities.
-	pShort := 0.58
-	pNormal := 0.34
-	pLong := 0.07
-	pMaxed := 0.01
+// Usage and latency metadata keys, mirroring the response or done chunk for middleware that only sees
+// headers and trailers. gRPC sends them as trailers; HTTP as trailers or headers (see setUsageHeaders).
+const (
+	usagePromptTokensKey     = "x-usage-prompt-tokens"
+	usageCompletionTokensKey = "x-usage-completion-tokens"
+	latencyMsKey             = "x-latency-ms"
+)
 
-	// Bias slightly toward longer outputs when the prompt is long.
-	// (This keeps "tell me everything" prompts from always returning short replies.)
-	if promptRunes > 1200 {
-		pShort -= 0.10
-		pNormal += 0.06
-		pLong += 0.03
-		pMaxed += 0.01
-	} else if promptRunes > 600 {
-		pShort -= 0.06
-		pNormal += 0.04
-		pLong += 0.02
-	}
+// usageTrailer returns the usage and latency trailer of a completed RPC.
+func usageTrailer(promptTokens, completionTokens int, latencyMs int64) metadata.MD {
+	return metadata.Pairs(
+		usagePromptTokensKey, strconv.Itoa(promptTokens),
+		usageCompletionTokensKey, strconv.Itoa(completionTokens),
+		latencyMsKey, strconv.FormatInt(latencyMs, 10),
+	)
+}
 
-	// Clamp (defensive).
-	if pShort < 0.10 {
-		pShort = 0.10
+// emittedAt returns the send timestamp for a stream chunk, or 0 (left out) without CHUNK_TIMESTAMPS.
+func emittedAt(cfg config.Config) int64 {
+	if !cfg.ChunkTimestamps {
+		return 0
 	}
-	if pMaxed < 0.0 {
-		pMaxed = 0.0
+	return time.Now().UnixMilli()
+}
+
+// simulateUnary sleeps out the simulated latency of a non-streaming HTTP completion of ct tokens:
+// base+jitter + TTFT, then the generation time with its CPU burn (see generate). It returns the share
+// of the output generated before ctx ended, 1 when it didn't.
+func (s *MockLlmService) simulateUnary(ctx context.Context, ct int) float64 {
+	sleepWithContext(ctx, time.Duration(s.preDelayMs())*time.Millisecond)
+	if ctx.Err() != nil {
+		return 0
 	}
+	planned := time.Duration(s.generationMs(ct)) * time.Millisecond
+	return generatedShare(generate(ctx, s.cfg, ct, planned), planned)
+}
 
-	r := mock.RandFloat64()
+// preDelayMs draws the delay before the first token (queue + TTFT).
+func (s *MockLlmService) preDelayMs() int {
+	return s.queueMs() + s.ttftMs()
+}
 
-	// Helper: pick an integer token count from a fractional range of maxTokens.
-	pickFrac := func(minF, maxF float64) int32 {
-		minT := int32(float64(maxTokens) * minF)
-		maxT := int32(float64(maxTokens) * maxF)
-		if minT < 1 {
-			minT = 1
-		}
-		if maxT < minT {
-			maxT = minT
-		}
-		if maxT == minT {
-			return minT
-		}
-		return minT + int32(mock.RandIntn(int(maxT-minT+1)))
-	}
+// queueMs draws the queue delay (base + jitter), scaled for the request's priority.
+func (s *MockLlmService) queueMs() int {
+	return s.priority.queueMs(s.cfg, s.baseDelayMs()+s.jitterMs())
+}
 
-	switch {
-	case r < pShort:
-		// 1-3 sentences
-		return pickFrac(0.05, 0.22)
-	case r < pShort+pNormal:
-		// a few short paragraphs
-		return pickFrac(0.22, 0.62)
-	case r < pShort+pNormal+pLong:
-		// long-ish explanation
-		return pickFrac(0.62, 0.92)
-	default:
-		// rare: push to the cap (simulates verbose answers / near-length outputs)
-		_ = pMaxed // kept for readability
-		return pickFrac(0.92, 1.00)
+// generationMs returns the decode time for ct tokens.
+func (s *MockLlmService) generationMs(ct int) int {
+	// Optional per-token overhead (e.g., server-side processing).
+	ms := s.perTokenDelayMs(ct) * ct
+	// Token generation time from TokensPerSec.
+	if tps := config.Or(s.cfg.TokensPerSec, 0); tps > 0 {
+		ms += int(curveDuration(s.cfg, 0, ct, tps).Round(time.Millisecond) / time.Millisecond)
 	}
+	return ms
 }
 
 func (s *MockLlmService) baseDelayMs() int {
-	return defaultInt(s.cfg.BaseDelayMs, 0)
+	return s.cfg.BaseDelayMs
 }
 
 func (s *MockLlmService) jitterMs() int {
-	j := defaultInt(s.cfg.JitterMs, 0)
+	j := s.cfg.JitterMs
 	if j <= 0 {
 		return 0
 	}
-	// rng is expected to be initialized at package scope (see mock.go)
-	return mock.RandIntn(j + 1)
+	j = s.rng.Intn(j + 1)
+	if s.debug != nil {
+		s.debug.JitterMs = j
+	}
+	return j
 }
 
 func (s *MockLlmService) perTokenDelayMs(maxTokens int) int {
-	return defaultInt(s.cfg.PerTokenDelayMs, 0)
+	return s.cfg.PerTokenDelayMs
 }
 
 func (s *MockLlmService) chunkSize() int {
-	return defaultInt(s.cfg.ChunkSize, 12)
+	return streamChunkSize(s.cfg)
 }
 
 func (s *MockLlmService) ttftMs() int {
-	min := defaultInt(s.cfg.TTFTMinMs, 0)
-	max := defaultInt(s.cfg.TTFTMaxMs, 0)
+	min := config.Or(s.cfg.TTFTMinMs, 0)
+	max := config.Or(s.cfg.TTFTMaxMs, 0)
 	if min <= 0 && max <= 0 {
 		return 0
 	}
@@ -343,60 +756,179 @@ func (s *MockLlmService) ttftMs() int {
 	if max <= 0 {
 		max = min
 	}
-	if max < min {
+	if max < min { // rejected by config.Validate; kept as a safety net
 		max = min
 	}
-	if max == min {
-		return min
+	ttft := min
+	if max > min {
+		ttft += s.rng.Intn(max - min + 1)
 	}
-	return min + mock.RandIntn(max-min+1)
+	if s.debug != nil {
+		s.debug.TTFTMs = ttft
+	}
+	return ttft
 }
 
-func (s *MockLlmService) tokensPerSec() int {
-	return defaultInt(s.cfg.TokensPerSec, 0)
+// streamPacer spaces the chunks of one stream on an absolute schedule: the gap after each chunk is
+// drawn from the stream delay window, TokensPerSec and PerTokenDelayMs as before, but instead of
+// sleeping for it, wait sleeps until the first chunk's time plus every gap so far, with the token
+// part computed from the running token count. Timer slack, send time and rounding then can't add up
+// over a long stream.
+type streamPacer struct {
+	cfg   config.Config
+	extra time.Duration // added to every gap, e.g. the simulated gzip cost
+	rng   *mock.Rand    // source of the stream delay draws, nil for the shared one
+
+	start time.Time     // schedule origin, set by the first wait (right after the first chunk)
+	toks  int           // tokens sent so far
+	fixed time.Duration // stream delay and per-token delay drawn so far
+	gen   time.Duration // generation time of the tokens sent so far, at TokensPerSec on the TPS_CURVE
+
+	chunks int // chunks sent so far, for BURST_MODE
 }
 
-func (s *MockLlmService) sleepStreamGap(ctx context.Context, delta string) {
-	ms := 0
+func newStreamPacer(cfg config.Config) *streamPacer {
+	return &streamPacer{cfg: cfg}
+}
 
-	// Base gap jitter (existing knobs).
-	min := defaultInt(s.cfg.StreamDelayMinMs, 0)
-	max := defaultInt(s.cfg.StreamDelayMaxMs, 0)
-	if max > 0 {
-		if max < min {
-			max = min
-		}
-		ms += min
-		if max > min {
-			ms += mock.RandIntn(max - min + 1)
+// wait burns the CPU for delta (CPU_BURN_US_PER_TOKEN), then sleeps until the next chunk is due.
+// In BURST_MODE it only sleeps after the last chunk of each burst: the schedule still counts every
+// chunk, so the gap pays for the whole burst's tokens and the throughput stays the same. A gap
+// shorter than BURST_GAP_MS is stretched to it and the rest of the schedule moved back with it.
+func (p *streamPacer) wait(ctx context.Context, delta string) {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	// A stall fault holds the stream; the schedule moves back with it.
+	p.start = p.start.Add(waitStall(ctx))
+	// Rough: 1 token ~= 4 runes.
+	toks := max(mock.ApproxTokens(delta), 1)
+	burnCPU(ctx, cpuBurn(p.cfg, toks))
+	if tps := config.Or(p.cfg.TokensPerSec, 0); tps > 0 {
+		p.gen += curveDuration(p.cfg, p.toks, toks, tps)
+	}
+	p.toks += toks
+	p.fixed += streamDelay(p.cfg, p.rng) + p.extra
+	if per := p.cfg.PerTokenDelayMs; per > 0 {
+		p.fixed += time.Duration(per*toks) * time.Millisecond
+	}
+	p.chunks++
+	if !p.cfg.BurstMode {
+		sleepWithContext(ctx, time.Until(p.start.Add(p.fixed+p.gen)))
+		return
+	}
+	if p.chunks%max(p.cfg.BurstSize, 1) != 0 {
+		return
+	}
+	gap := time.Until(p.start.Add(p.fixed + p.gen))
+	if floor := time.Duration(p.cfg.BurstGapMs) * time.Millisecond; gap < floor {
+		p.start = p.start.Add(floor - gap)
+		gap = floor
+	}
+	sleepWithContext(ctx, gap)
+}
+
+// generate simulates generating toks tokens in d for a non-streaming completion: it burns the CPU
+// for them like a stream does, then sleeps out the rest of d. It returns how long it took.
+func generate(ctx context.Context, cfg config.Config, toks int, d time.Duration) time.Duration {
+	t0 := time.Now()
+	burnCPU(ctx, cpuBurn(cfg, toks))
+	sleepWithContext(ctx, d-time.Since(t0))
+	return time.Since(t0)
+}
+
+// cpuBurn returns the CPU time to spend on toks generated tokens.
+func cpuBurn(cfg config.Config, toks int) time.Duration {
+	return time.Duration(cfg.CPUBurnUsPerToken*toks) * time.Microsecond
+}
+
+// burnSink keeps the result of burnCPU's loop alive so the compiler can't drop it.
+var burnSink atomic.Uint64
+
+// burnCPU spins for about d, giving up early when ctx is done.
+func burnCPU(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	deadline := time.Now().Add(d)
+	x := burnSink.Load() | 1
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		for range 1000 {
+			x = x*6364136223846793005 + 1442695040888963407
 		}
 	}
+	burnSink.Store(x)
+}
 
-	// Approx generation pacing from tokens/sec.
-	if tps := s.tokensPerSec(); tps > 0 {
-		// Rough: 1 token ~= 4 runes.
-		toks := mock.ApproxTokens(delta)
-		if toks < 1 {
-			toks = 1
+// streamDelay draws the base gap between chunks from STREAM_DELAY_DISTRIBUTION, clamped to
+// STREAM_DELAY_CAP_MS, with rng. Only this jitter is random; the TokensPerSec part of a gap is not.
+func streamDelay(cfg config.Config, rng *mock.Rand) time.Duration {
+	var ms float64
+	switch cfg.StreamDelayDistribution {
+	case "normal":
+		mean, stddev := streamDelayCenter(cfg)
+		if cfg.StreamDelayStddevMs > 0 {
+			stddev = float64(cfg.StreamDelayStddevMs)
 		}
-		msPerTok := 1000 / tps
-		if msPerTok < 1 {
-			msPerTok = 1
+		ms = max(mean+stddev*rng.NormFloat64(), 0)
+	case "lognormal":
+		median, _ := streamDelayCenter(cfg)
+		ms = median * math.Exp(cfg.StreamDelayLogSigma*rng.NormFloat64())
+	default:
+		min := config.Or(cfg.StreamDelayMinMs, 0)
+		max := config.Or(cfg.StreamDelayMaxMs, 0)
+		if max <= 0 {
+			return 0
 		}
-		ms += toks * msPerTok
+		if max < min { // rejected by config.Validate; kept as a safety net
+			max = min
+		}
+		n := min
+		if max > min {
+			n += rng.Intn(max - min + 1)
+		}
+		ms = float64(n)
+	}
+	if limit := cfg.StreamDelayCapMs; limit > 0 {
+		ms = min(ms, float64(limit))
 	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
 
-	// Optional per-token overhead.
-	per := defaultInt(s.cfg.PerTokenDelayMs, 0)
-	if per > 0 {
-		toks := mock.ApproxTokens(delta)
-		if toks < 1 {
-			toks = 1
-		}
-		ms += per * toks
+// streamDelayCenter returns the center of a normal or lognormal stream delay: STREAM_DELAY_MEAN_MS,
+// or the middle of the stream delay window when it is 0, and a quarter of that window as the
+// default standard deviation.
+func streamDelayCenter(cfg config.Config) (center, spread float64) {
+	lo := float64(config.Or(cfg.StreamDelayMinMs, 0))
+	hi := max(float64(config.Or(cfg.StreamDelayMaxMs, 0)), lo)
+	center = (lo + hi) / 2
+	if cfg.StreamDelayMeanMs > 0 {
+		center = float64(cfg.StreamDelayMeanMs)
 	}
+	return center, (hi - lo) / 4
+}
 
-	sleepWithContext(ctx, time.Duration(ms)*time.Millisecond)
+// tokensDuration is the time to generate toks tokens at tps tokens per second.
+func tokensDuration(toks int, tps float64) time.Duration {
+	return time.Duration(float64(toks) * float64(time.Second) / tps)
+}
+
+// buildOutput generates the completion text for prompt under the output sizing knobs of cfg.
+func buildOutput(cfg config.Config, prompt string, maxTokens int) string {
+	return mock.BuildOutput(prompt, maxTokens, cfg.EchoPrompt, config.Or(cfg.StrictTokenMode, false), cfg.DebugOutputChars, config.Or(cfg.MaxOutputChars, 0))
+}
+
+// newOutputStream returns the stream of the output buildOutput would build.
+func newOutputStream(cfg config.Config, prompt string, maxTokens int) *mock.OutputStream {
+	return mock.NewOutputStream(prompt, maxTokens, cfg.EchoPrompt, config.Or(cfg.StrictTokenMode, false), cfg.DebugOutputChars, config.Or(cfg.MaxOutputChars, 0))
+}
+
+// streamChunkSize returns the configured stream chunk size; unset or 0 falls back to 12 chars.
+func streamChunkSize(cfg config.Config) int {
+	if n := config.Or(cfg.ChunkSize, 0); n > 0 {
+		return n
+	}
+	return 12
 }
 
 func defaultInt(v int, def int) int {
@@ -406,35 +938,295 @@ func defaultInt(v int, def int) int {
 	return v
 }
 
-func shouldFail(rate float64) bool {
-	if rate <= 0 {
-		return false
+// ErrorInfo marker carried by every injected gRPC error, so clients can tell deliberate failures
+// from genuine ones.
+const (
+	injectedErrorDomain = "llm-simulator"
+	injectedErrorReason = "INJECTED"
+)
+
+// errorDomain is the ErrorInfo domain of the simulator's genuine errors, e.g. a rejected request.
+const errorDomain = "errors.llm-simulator"
+
+// sendWatchdog sends the chunks of a stream from one goroutine, so that a Send still blocked after
+// limit (the client isn't reading) fails the stream with ResourceExhausted, like providers
+// disconnecting slow readers. Its one timer is armed for each Send. The stuck Send returns once the
+// handler ends and the stream is closed, so nothing else may be sent after a timeout.
+type sendWatchdog struct {
+	stream llmv1.LlmService_ChatCompletionStreamServer
+	limit  time.Duration
+	chunks chan *llmv1.ChatCompletionChunkResponse
+	errs   chan error // buffered, so the goroutine can finish a Send nobody waits for anymore
+	timer  *time.Timer
+}
+
+func newSendWatchdog(stream llmv1.LlmService_ChatCompletionStreamServer, limit time.Duration) *sendWatchdog {
+	w := &sendWatchdog{
+		stream: stream,
+		limit:  limit,
+		chunks: make(chan *llmv1.ChatCompletionChunkResponse),
+		errs:   make(chan error, 1),
+		timer:  time.NewTimer(limit),
 	}
-	if rate >= 1 {
-		return true
+	w.timer.Stop()
+	go func() {
+		for c := range w.chunks {
+			w.errs <- w.stream.Send(c)
+		}
+	}()
+	return w
+}
+
+// send sends c, failing once the Send has been blocked for the limit.
+func (w *sendWatchdog) send(c *llmv1.ChatCompletionChunkResponse) error {
+	w.chunks <- c
+	w.timer.Reset(w.limit)
+	defer w.timer.Stop()
+	select {
+	case err := <-w.errs:
+		return err
+	case <-w.timer.C:
+		return status.Errorf(codes.ResourceExhausted, "slow consumer: a chunk send blocked for over %dms", w.limit.Milliseconds())
+	}
+}
+
+// close stops the goroutine once its last Send returns. No send may follow.
+func (w *sendWatchdog) close() {
+	close(w.chunks)
+}
+
+// sendCounters is what a stream has sent so far. Its termination log carries them, so a client's
+// partial response can be matched against what actually left the server. Keep-alive chunks are
+// counted apart: they carry no content.
+type sendCounters struct {
+	chunks, bytes, tokens int64     // int64: an endless stream may run for days
+	keepalives            int64     // GRPC_KEEPALIVE_CHUNK_MS chunks, not in chunks
+	last                  time.Time // when the last chunk, keep-alives included, was sent
+}
+
+// add counts a chunk sent with text.
+func (c *sendCounters) add(text string) {
+	c.chunks++
+	c.bytes += int64(len(text))
+	c.tokens += int64(mock.ApproxTokens(text))
+	c.last = time.Now()
+}
+
+// keepalive counts a keep-alive chunk sent.
+func (c *sendCounters) keepalive() {
+	c.keepalives++
+	c.last = time.Now()
+}
+
+// fields returns the counters as log fields.
+func (c *sendCounters) fields() []any {
+	return []any{"chunksSent", c.chunks, "bytesSent", c.bytes, "tokensSent", c.tokens, "lastSendAt", c.last}
+}
+
+// failedChunk builds the terminal "failed" chunk for err, of type typ.
+func failedChunk(err error, typ events.Type) *llmv1.ChatCompletionChunkResponse {
+	st := status.Convert(err)
+	return &llmv1.ChatCompletionChunkResponse{
+		Type:               string(typ),
+		Index:              0,
+		FinishReason:       string(events.FinishError),
+		ErrorCode:          st.Code().String(),
+		ErrorMessage:       st.Message(),
+		Injected:           isInjected(st),
+		LegacyFinishReason: err.Error(),
 	}
-	return mock.RandFloat64() < rate
 }
 
-func pickGrpcErrorCode(mode string) codes.Code {
-	switch strings.ToLower(strings.TrimSpace(mode)) {
-	case "429", "resource_exhausted", "rate_limit", "rate limit":
+// isDoneChunk reports whether ch is the done chunk of a stream under any EVENT_NAMING: the one with a
+// finish reason other than the failed chunk's.
+func isDoneChunk(ch *llmv1.ChatCompletionChunkResponse) bool {
+	return ch.GetFinishReason() != "" && ch.GetFinishReason() != string(events.FinishError)
+}
+
+// isDeltaChunk reports whether ch is a content delta of a stream under any EVENT_NAMING.
+func isDeltaChunk(ch *llmv1.ChatCompletionChunkResponse) bool {
+	return ch.GetFinishReason() == "" && ch.GetText() != ""
+}
+
+// ErrorInfo of the usage a failed stream delivered. Its domain is its own, so it never passes for
+// the marker of an injected error.
+const (
+	partialUsageDomain = "usage.llm-simulator"
+	partialUsageReason = "PARTIAL_USAGE"
+)
+
+// withPartialUsage adds to the status of err, a stream failing after some deltas, an ErrorInfo with
+// the usage it delivered, as on its failed chunk.
+func withPartialUsage(err error, promptTokens, completionTokens int, latencyMs int64) error {
+	info := &errdetails.ErrorInfo{
+		Domain: partialUsageDomain,
+		Reason: partialUsageReason,
+		Metadata: map[string]string{
+			"prompt_tokens":     strconv.Itoa(promptTokens),
+			"completion_tokens": strconv.Itoa(completionTokens),
+			"total_tokens":      strconv.Itoa(promptTokens + completionTokens),
+			"latency_ms":        strconv.FormatInt(latencyMs, 10),
+		},
+	}
+	detailed, derr := status.Convert(err).WithDetails(info)
+	if derr != nil {
+		return err
+	}
+	return detailed.Err()
+}
+
+// isInjected reports whether st carries the ErrorInfo marker of an injected error.
+func isInjected(st *status.Status) bool {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == injectedErrorDomain && info.GetReason() == injectedErrorReason {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSampling rejects, with STRICT_VALIDATION, sampling params outside the ranges providers accept:
+// temperature [0, 2], top_p [0, 1] and the penalties [-2, 2].
+func checkSampling(cfg config.Config, req *llmv1.ChatCompletionRequest) error {
+	if !cfg.StrictValidation {
+		return nil
+	}
+	for _, p := range []struct {
+		name      string
+		v, lo, hi float64
+	}{
+		{"temperature", req.GetTemperature(), 0, 2},
+		{"top_p", req.GetTopP(), 0, 1},
+		{"presence_penalty", req.GetPresencePenalty(), -2, 2},
+		{"frequency_penalty", req.GetFrequencyPenalty(), -2, 2},
+	} {
+		if !(p.v >= p.lo && p.v <= p.hi) { // NaN fails too
+			return status.Errorf(codes.InvalidArgument, "%s must be within [%v, %v], got %v", p.name, p.lo, p.hi, p.v)
+		}
+	}
+	return nil
+}
+
+// checkMessages rejects, before its prompt is built, a request with more context messages than
+// MAX_CONTEXT_MESSAGES or a message longer than MAX_MESSAGE_CHARS (zero leaves either unlimited).
+func checkMessages(cfg config.Config, req *llmv1.ChatCompletionRequest) error {
+	if n := len(req.GetContext()); cfg.MaxContextMessages > 0 && n > cfg.MaxContextMessages {
+		return status.Errorf(codes.InvalidArgument, "context has %d messages, more than MAX_CONTEXT_MESSAGES (%d)", n, cfg.MaxContextMessages)
+	}
+	if cfg.MaxMessageChars <= 0 {
+		return nil
+	}
+	tooLong := func(s string) int {
+		if len(s) <= cfg.MaxMessageChars { // no more runes than bytes
+			return 0
+		}
+		if n := utf8.RuneCountInString(s); n > cfg.MaxMessageChars {
+			return n
+		}
+		return 0
+	}
+	if n := tooLong(req.GetSystemPrompt()); n > 0 {
+		return status.Errorf(codes.InvalidArgument, "system prompt has %d chars, more than MAX_MESSAGE_CHARS (%d)", n, cfg.MaxMessageChars)
+	}
+	for i, m := range req.GetContext() {
+		if n := tooLong(m.GetContent()); n > 0 {
+			return status.Errorf(codes.InvalidArgument, "context message %d has %d chars, more than MAX_MESSAGE_CHARS (%d)", i, n, cfg.MaxMessageChars)
+		}
+	}
+	if n := tooLong(req.GetUserPrompt()); n > 0 {
+		return status.Errorf(codes.InvalidArgument, "user prompt has %d chars, more than MAX_MESSAGE_CHARS (%d)", n, cfg.MaxMessageChars)
+	}
+	return nil
+}
+
+// requestSampling returns the sampling params of req, echoed on the response and the done chunk.
+func requestSampling(req *llmv1.ChatCompletionRequest) *llmv1.Sampling {
+	return &llmv1.Sampling{
+		Temperature:      req.GetTemperature(),
+		TopP:             req.GetTopP(),
+		PresencePenalty:  req.GetPresencePenalty(),
+		FrequencyPenalty: req.GetFrequencyPenalty(),
+	}
+}
+
+// deadlineTooShortReason is the ErrorInfo reason of a request rejected by checkDeadline.
+const deadlineTooShortReason = "DEADLINE_TOO_SHORT"
+
+// checkDeadline fails the request with FailedPrecondition when RejectShortDeadlines is on and its
+// deadline leaves less time than the least latency the config can produce (BASE_DELAY_MS plus the
+// shortest TTFT), so clients get an immediate answer instead of a DeadlineExceeded race. The status
+// carries an ErrorInfo with the required and remaining milliseconds.
+func (s *MockLlmService) checkDeadline(ctx context.Context) error {
+	if !s.cfg.RejectShortDeadlines {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	ttft := config.Or(s.cfg.TTFTMinMs, 0)
+	if ttft <= 0 {
+		ttft = config.Or(s.cfg.TTFTMaxMs, 0) // ttftMs uses max when min is unset
+	}
+	need := time.Duration(s.priority.queueMs(s.cfg, s.baseDelayMs())+ttft) * time.Millisecond
+	left := time.Until(deadline)
+	if left >= need {
+		return nil
+	}
+	st := status.Newf(codes.FailedPrecondition, "deadline too short: %dms left, the simulated latency is at least %dms (BASE_DELAY_MS + TTFT_MIN_MS)", left.Milliseconds(), need.Milliseconds())
+	info := &errdetails.ErrorInfo{
+		Domain: errorDomain,
+		Reason: deadlineTooShortReason,
+		Metadata: map[string]string{
+			"min_latency_ms": strconv.FormatInt(need.Milliseconds(), 10),
+			"remaining_ms":   strconv.FormatInt(left.Milliseconds(), 10),
+		},
+	}
+	if detailed, err := st.WithDetails(info); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// injectedStatus builds the status of an injected error for ERROR_MODE mode, marked with an
+// ErrorInfo detail; mixed draws the code from rng. All gRPC injection sites must go through it.
+func injectedStatus(rng *mock.Rand, mode string) *status.Status {
+	st := status.New(pickGrpcErrorCode(rng, mode), "mock error")
+	info := &errdetails.ErrorInfo{
+		Domain:   injectedErrorDomain,
+		Reason:   injectedErrorReason,
+		Metadata: map[string]string{"mode": mode},
+	}
+	if marked, err := st.WithDetails(info); err == nil {
+		st = marked
+	}
+	return st
+}
+
+func pickGrpcErrorCode(rng *mock.Rand, mode string) codes.Code {
+	switch config.ErrorModeKind(mode) {
+	case config.ErrorKindRateLimit:
 		return codes.ResourceExhausted
-	case "500", "internal", "server_error":
+	case config.ErrorKindInternal:
 		return codes.Internal
 	default:
-		// mixed
-		if mock.RandIntn(2) == 0 {
+		if rng.Intn(2) == 0 {
 			return codes.ResourceExhausted
 		}
 		return codes.Internal
 	}
 }
 
+// sleepWithContext sleeps for d or until ctx is done. On a gRPC stream with
+// GRPC_KEEPALIVE_CHUNK_MS it sends keep-alive chunks meanwhile (see streamKeepalive).
 func sleepWithContext(ctx context.Context, d time.Duration) {
 	if d <= 0 {
 		return
 	}
+	if k := streamKeepaliveOf(ctx); k != nil {
+		k.sleep(ctx, d)
+		return
+	}
 	t := time.NewTimer(d)
 	defer t.Stop()
 	select {
@@ -445,9 +1237,78 @@ func sleepWithContext(ctx context.Context, d time.Duration) {
 	}
 }
 
+// sleepMeasured sleeps like sleepWithContext and returns how long it actually slept.
+func sleepMeasured(ctx context.Context, d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	t0 := time.Now()
+	sleepWithContext(ctx, d)
+	return time.Since(t0)
+}
+
+// promptTokens counts the prompt tokens of req. With PROMPT_TOKEN_ACCOUNTING=formatted (the default)
+// it counts the prompt buildPromptForTokens renders, role markers included; with content_only it
+// counts each message's content plus PROMPT_TOKENS_PER_MESSAGE, the way providers bill chat messages.
+// The count doesn't depend on the text output is generated from.
+func promptTokens(cfg config.Config, req *llmv1.ChatCompletionRequest) int {
+	if cfg.PromptTokenAccounting != "content_only" {
+		return mock.ApproxTokens(buildPromptForTokens(req))
+	}
+	perMessage := max(cfg.PromptTokensPerMessage, 0)
+	n := 0
+	// The same messages buildPromptForTokens renders.
+	if sp := strings.TrimSpace(req.GetSystemPrompt()); sp != "" {
+		n += mock.ApproxTokens(sp) + perMessage
+	}
+	for _, m := range req.GetContext() {
+		role := strings.TrimSpace(m.GetRole())
+		content := strings.TrimSpace(m.GetContent())
+		if role == "" && content == "" {
+			continue
+		}
+		n += mock.ApproxTokens(content) + perMessage
+	}
+	if up := strings.TrimSpace(req.GetUserPrompt()); up != "" {
+		n += mock.ApproxTokens(up) + perMessage
+	}
+	return n
+}
+
+// chatPrompt is a prompt handed to a shared response writer: the text output is generated from and
+// its token count (see promptTokens).
+type chatPrompt struct {
+	text   string
+	tokens int
+}
+
+// newChatPrompt builds the prompt of req.
+func newChatPrompt(cfg config.Config, req *llmv1.ChatCompletionRequest) chatPrompt {
+	return chatPrompt{text: buildPromptForTokens(req), tokens: promptTokens(cfg, req)}
+}
+
+// rawPrompt is a prompt given as plain text (GET /v1/chat/completions): output is generated from the
+// text as is, and it counts as a single user message.
+func rawPrompt(cfg config.Config, text string) chatPrompt {
+	return chatPrompt{text: text, tokens: promptTokens(cfg, &llmv1.ChatCompletionRequest{UserPrompt: text})}
+}
+
 func buildPromptForTokens(req *llmv1.ChatCompletionRequest) string {
+	sp := strings.TrimSpace(req.GetSystemPrompt())
+	up := strings.TrimSpace(req.GetUserPrompt())
+
+	// Measure first so the builder allocates once.
+	size := len(up) + len("[user]\n")
+	if sp != "" {
+		size += len(sp) + len("[system]\n\n\n")
+	}
+	for _, m := range req.GetContext() {
+		size += len(m.GetRole()) + len(m.GetContent()) + len("[unknown]\n\n\n")
+	}
+
 	var b strings.Builder
-	if sp := strings.TrimSpace(req.GetSystemPrompt()); sp != "" {
+	b.Grow(size)
+	if sp != "" {
 		b.WriteString("[system]\n")
 		b.WriteString(sp)
 		b.WriteString("\n\n")
@@ -470,7 +1331,7 @@ func buildPromptForTokens(req *llmv1.ChatCompletionRequest) string {
 		b.WriteString("\n\n")
 	}
 
-	if up := strings.TrimSpace(req.GetUserPrompt()); up != "" {
+	if up != "" {
 		b.WriteString("[user]\n")
 		b.WriteString(up)
 	}
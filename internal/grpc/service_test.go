@@ -2,19 +2,30 @@ package grpc
 
 import (
 	"context"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/yungtweek/llm-simulator/events"
 	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
 	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
 
 	llmv1 "github.com/yungtweek/llm-simulator/gen"
 
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // TestChatCompletionSuccess verifies the unary RPC returns deterministic output, finish reason, and token accounting
@@ -27,9 +38,9 @@ func TestChatCompletionSuccess(t *testing.T) {
 		ErrorRate:        0,
 		ErrorMode:        "mixed",
 		DefaultTokens:    0,
-		ChunkSize:        16,
-		StreamDelayMinMs: 0,
-		StreamDelayMaxMs: 0,
+		ChunkSize:        config.Int(16),
+		StreamDelayMinMs: config.Int(0),
+		StreamDelayMaxMs: config.Int(0),
 	}
 
 	svc := NewMockLlmService(cfg)
@@ -54,19 +65,12 @@ func TestChatCompletionSuccess(t *testing.T) {
 	}
 
 	prompt := buildPromptForTokens(req)
-	expected := mock.BuildOutput(
-		prompt,
-		int(req.GetMaxTokens()),
-		cfg.EchoPrompt,
-		cfg.StrictTokenMode,
-		cfg.DebugOutputChars,
-		cfg.MaxOutputChars,
-	)
+	expected := buildOutput(cfg, prompt, int(req.GetMaxTokens()))
 
 	if resp.OutputText != expected {
 		t.Fatalf("output mismatch")
 	}
-	if resp.FinishReason != "stop" {
+	if resp.FinishReason != string(events.FinishStop) {
 		t.Fatalf("finish reason mismatch: %q", resp.FinishReason)
 	}
 
@@ -128,9 +132,9 @@ func TestChatCompletionStream(t *testing.T) {
 		ErrorRate:        0,
 		ErrorMode:        "mixed",
 		DefaultTokens:    0,
-		ChunkSize:        7,
-		StreamDelayMinMs: 0,
-		StreamDelayMaxMs: 0,
+		ChunkSize:        config.Int(7),
+		StreamDelayMinMs: config.Int(0),
+		StreamDelayMaxMs: config.Int(0),
 	}
 
 	svc := NewMockLlmService(cfg)
@@ -142,34 +146,27 @@ func TestChatCompletionStream(t *testing.T) {
 		MaxTokens:    10,
 	}
 
-	fs := &fakeStream{ctx: context.Background()}
+	fs := llmtest.NewServerStream(context.Background())
 	if err := svc.ChatCompletionStream(req, fs); err != nil {
 		t.Fatalf("ChatCompletionStream err: %v", err)
 	}
 
 	prompt := buildPromptForTokens(req)
-	out := mock.BuildOutput(
-		prompt,
-		int(req.GetMaxTokens()),
-		cfg.EchoPrompt,
-		cfg.StrictTokenMode,
-		cfg.DebugOutputChars,
-		cfg.MaxOutputChars,
-	)
-	expectedChunks := (len(out) + cfg.ChunkSize - 1) / cfg.ChunkSize
+	out := buildOutput(cfg, prompt, int(req.GetMaxTokens()))
+	expectedChunks := (len(out) + *cfg.ChunkSize - 1) / *cfg.ChunkSize
 
-	if len(fs.sent) != expectedChunks+1 { // +1 final chunk
-		t.Fatalf("expected %d chunks, got %d", expectedChunks+1, len(fs.sent))
+	if len(fs.Chunks()) != expectedChunks+1 { // +1 final chunk
+		t.Fatalf("expected %d chunks, got %d", expectedChunks+1, len(fs.Chunks()))
 	}
 
 	var assembled strings.Builder
 	for i := 0; i < expectedChunks; i++ {
-		part := fs.sent[i].GetText()
-		if len(part) == 0 || len(part) > cfg.ChunkSize {
+		part := fs.Chunks()[i].GetText()
+		if len(part) == 0 || len(part) > *cfg.ChunkSize {
 			t.Fatalf("chunk %d size invalid: %d", i, len(part))
 		}
 		assembled.WriteString(part)
-		if fs.sent[i].FinishReason != "" {
+		if fs.Chunks()[i].FinishReason != "" {
 			t.Fatalf("finish reason should be empty on intermediate chunks")
 		}
 	}
@@ -177,8 +174,8 @@ func TestChatCompletionStream(t *testing.T) {
 		t.Fatalf("reassembled stream mismatch")
 	}
 
-	last := fs.sent[len(fs.sent)-1]
-	if last.FinishReason != "stop" {
+	last := fs.Chunks()[len(fs.Chunks())-1]
+	if last.FinishReason != string(events.FinishStop) {
 		t.Fatalf("unexpected finish reason: %q", last.FinishReason)
 	}
 	pt := int32(mock.ApproxTokens(prompt))
@@ -189,6 +186,10 @@ func TestChatCompletionStream(t *testing.T) {
 	if last.LatencyMs < 0 {
 		t.Fatalf("latency should be non-negative")
 	}
+	sum := sha256.Sum256([]byte(assembled.String()))
+	if last.OutputSha256 != hex.EncodeToString(sum[:]) || last.OutputBytes != int64(assembled.Len()) {
+		t.Fatalf("done chunk digest does not match the deltas: %s/%d", last.OutputSha256, last.OutputBytes)
+	}
 }
 
 // TestChatCompletionStreamError verifies that when error injection triggers before streaming starts, the RPC
@@ -200,7 +201,7 @@ func TestChatCompletionStreamError(t *testing.T) {
 	}
 
 	svc := NewMockLlmService(cfg)
-	fs := &fakeStream{ctx: context.Background()}
+	fs := llmtest.NewServerStream(context.Background())
 	err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{}, fs)
 	if err == nil {
 		t.Fatalf("expected error")
@@ -208,59 +209,456 @@ func TestChatCompletionStreamError(t *testing.T) {
 	if status.Code(err) != codes.Internal {
 		t.Fatalf("expected Internal, got %v", status.Code(err))
 	}
-	if len(fs.sent) != 1 {
-		t.Fatalf("expected one failed chunk on error, got %d", len(fs.sent))
+	if len(fs.Chunks()) != 1 {
+		t.Fatalf("expected one failed chunk on error, got %d", len(fs.Chunks()))
+	}
+	if fs.Chunks()[0].Type != string(events.Failed) || fs.Chunks()[0].FinishReason != string(events.FinishError) {
+		t.Fatalf("expected failed chunk with finish reason \"error\", got %+v", fs.Chunks()[0])
+	}
+	if fs.Chunks()[0].ErrorCode != "Internal" || fs.Chunks()[0].ErrorMessage != "mock error" || !fs.Chunks()[0].Injected {
+		t.Fatalf("expected an injected Internal error on the failed chunk, got %+v", fs.Chunks()[0])
 	}
-	if fs.sent[0].Type != "failed" || fs.sent[0].FinishReason == "" {
-		t.Fatalf("expected failed chunk with finish reason, got %+v", fs.sent[0])
+	if got := fs.Chunks()[0].GetLegacyFinishReason(); got != err.Error() {
+		t.Fatalf("expected the legacy finish reason %q, got %q", err.Error(), got)
 	}
 }
 
-// fakeStream satisfies llmv1.LlmService_ChatCompletionStreamServer for testing.
-type fakeStream struct {
-	ctx     context.Context
-	sent    []*llmv1.ChatCompletionChunkResponse
-	header  metadata.MD
-	trailer metadata.MD
-	onSend  func(res *llmv1.ChatCompletionChunkResponse)
+// TestEventNaming runs a stream and a failing one under each EVENT_NAMING and checks the full
+// sequence of chunk types, and that the done chunk is the one the interceptors take for it.
+func TestEventNaming(t *testing.T) {
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 12}
+	for _, tt := range []struct {
+		naming      events.Naming
+		delta, done events.Type
+		tail        []events.Type // after the done chunk
+		failed      events.Type
+	}{
+		{events.OpenAIResponses, events.OutputTextDelta, events.OutputTextDone, nil, events.Failed},
+		{events.OpenAIChat, events.Chunk, events.Chunk, nil, events.Chunk},
+		{events.Anthropic, events.ContentBlockDelta, events.MessageDelta, []events.Type{events.MessageStop}, events.Error},
+	} {
+		t.Run(string(tt.naming), func(t *testing.T) {
+			cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), EventNaming: string(tt.naming)}
+			fs := llmtest.NewServerStream(context.Background())
+			if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+			var want, got []events.Type
+			for range len(fs.Chunks()) - 1 - len(tt.tail) {
+				want = append(want, tt.delta)
+			}
+			want = append(append(want, tt.done), tt.tail...)
+			doneAt := -1
+			for i, c := range fs.Chunks() {
+				got = append(got, events.Type(c.GetType()))
+				if isDoneChunk(c) {
+					doneAt = i
+				}
+			}
+			if len(got) < 3+len(tt.tail) || !slices.Equal(got, want) {
+				t.Fatalf("unexpected chunk types %v", got)
+			}
+			if doneAt != len(fs.Chunks())-1-len(tt.tail) || fs.Chunks()[doneAt].GetCompletionTokens() == 0 {
+				t.Fatalf("expected the usage on chunk %d, done chunk found at %d", len(fs.Chunks())-1-len(tt.tail), doneAt)
+			}
+
+			cfg.ErrorRate, cfg.ErrorMode = 1, "500"
+			fs = llmtest.NewServerStream(context.Background())
+			_ = NewMockLlmService(cfg).ChatCompletionStream(req, fs)
+			if len(fs.Chunks()) != 1 || events.Type(fs.Chunks()[0].GetType()) != tt.failed || isDoneChunk(fs.Chunks()[0]) {
+				t.Fatalf("expected one %q failed chunk, got %+v", tt.failed, fs.Chunks())
+			}
+		})
+	}
 }
 
-func (f *fakeStream) Send(res *llmv1.ChatCompletionChunkResponse) error {
-	f.sent = append(f.sent, res)
-	if f.onSend != nil {
-		f.onSend(res)
+// TestSamplingParams sends sampling params over a real connection and checks they come back on the
+// response and the done chunk, and that STRICT_VALIDATION rejects the ones out of range.
+func TestSamplingParams(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true)}
+	want := &llmv1.Sampling{Temperature: 0.7, TopP: 0.9, PresencePenalty: 0.5, FrequencyPenalty: -0.25}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8, Temperature: 0.7, TopP: 0.9, PresencePenalty: 0.5, FrequencyPenalty: -0.25}
+	ctx := context.Background()
+
+	client := startTestServer(t, cfg)
+	resp, err := client.ChatCompletion(ctx, req)
+	if err != nil {
+		t.Fatalf("ChatCompletion err: %v", err)
+	}
+	if !proto.Equal(resp.GetSampling(), want) {
+		t.Fatalf("expected %v echoed on the response, got %v", want, resp.GetSampling())
+	}
+	stream, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	var done *llmv1.ChatCompletionChunkResponse
+	for {
+		ch, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		if isDoneChunk(ch) {
+			done = ch
+		}
+	}
+	if done == nil || !proto.Equal(done.GetSampling(), want) {
+		t.Fatalf("expected %v echoed on the done chunk, got %v", want, done)
+	}
+
+	cfg.StrictValidation = true
+	strict := startTestServer(t, cfg)
+	for _, bad := range []*llmv1.ChatCompletionRequest{
+		{UserPrompt: "hi", Temperature: 2.5},
+		{UserPrompt: "hi", TopP: 1.5},
+		{UserPrompt: "hi", PresencePenalty: -3},
+	} {
+		_, err := strict.ChatCompletion(ctx, bad)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument for %v, got %v", bad, err)
+		}
+		stream, err := strict.ChatCompletionStream(ctx, bad)
+		for err == nil { // the failed chunk comes first
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument on the stream for %v, got %v", bad, err)
+		}
+	}
+	if _, err := strict.ChatCompletion(ctx, req); err != nil {
+		t.Fatalf("valid sampling params rejected: %v", err)
+	}
+	if _, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hi", Temperature: 2.5}); err != nil {
+		t.Fatalf("out-of-range temperature rejected without STRICT_VALIDATION: %v", err)
 	}
-	return nil
 }
 
-func (f *fakeStream) SetHeader(md metadata.MD) error {
-	f.header = md
-	return nil
+// TestResponseIdentity verifies every chunk of a stream carries the same id, created time and
+// system_fingerprint, that ids differ between streams and responses, and that the fingerprint
+// follows the preset.
+func TestResponseIdentity(t *testing.T) {
+	cfg := config.Config{Preset: "openai", ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true)}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 16}
+
+	ids := map[string]bool{}
+	for range 2 {
+		fs := llmtest.NewServerStream(context.Background())
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		first := fs.Chunks()[0]
+		if !strings.HasPrefix(first.GetId(), "chatcmpl_mock_") || first.GetCreated() == 0 || !strings.HasPrefix(first.GetSystemFingerprint(), "fp_") {
+			t.Fatalf("missing response identity on %+v", first)
+		}
+		for _, c := range fs.Chunks() {
+			if c.GetId() != first.GetId() || c.GetCreated() != first.GetCreated() || c.GetSystemFingerprint() != first.GetSystemFingerprint() {
+				t.Fatalf("chunk identity differs within a stream: %+v vs %+v", c, first)
+			}
+		}
+		ids[first.GetId()] = true
+	}
+	resp, err := NewMockLlmService(cfg).ChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletion err: %v", err)
+	}
+	ids[resp.GetId()] = true
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 distinct ids, got %v", ids)
+	}
+
+	if resp.GetSystemFingerprint() != systemFingerprint(cfg) || resp.GetCreated() == 0 {
+		t.Fatalf("unexpected response identity: %+v", resp)
+	}
+	vllm := cfg
+	vllm.Preset = "vllm"
+	if systemFingerprint(vllm) == systemFingerprint(cfg) {
+		t.Fatal("expected the fingerprint to change with the preset")
+	}
 }
 
-func (f *fakeStream) SendHeader(md metadata.MD) error {
-	f.header = md
-	return nil
+// TestFailedChunk verifies when the terminal "failed" chunk is sent: on server-side errors unless
+// EMIT_FAILED_CHUNK is off, and never after a client cancellation, an expired deadline or a broken
+// stream.
+func TestFailedChunk(t *testing.T) {
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 32}
+	base := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := base
+		cfg.ErrorRate, cfg.ErrorMode, cfg.EmitFailedChunk = 1, "429", config.Bool(false)
+		fs := llmtest.NewServerStream(context.Background())
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected ResourceExhausted, got %v", err)
+		}
+		if len(fs.Chunks()) != 0 {
+			t.Fatalf("expected no chunks with EMIT_FAILED_CHUNK=false, got %+v", fs.Chunks())
+		}
+	})
+
+	t.Run("genuine error", func(t *testing.T) {
+		cfg := base
+		cfg.RejectShortDeadlines, cfg.TTFTMinMs, cfg.TTFTMaxMs = true, config.Int(500), config.Int(500)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		fs := llmtest.NewServerStream(ctx)
+		_ = NewMockLlmService(cfg).ChatCompletionStream(req, fs)
+		if len(fs.Chunks()) != 1 || fs.Chunks()[0].ErrorCode != "FailedPrecondition" || fs.Chunks()[0].Injected ||
+			!strings.HasPrefix(fs.Chunks()[0].ErrorMessage, "deadline too short") {
+			t.Fatalf("expected a non-injected FailedPrecondition failed chunk, got %+v", fs.Chunks())
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		fs := llmtest.NewServerStream(ctx)
+		fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) { cancel() }
+		cfg := base
+		cfg.StreamDelayMinMs, cfg.StreamDelayMaxMs = config.Int(20), config.Int(20)
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); status.Code(err) != codes.Canceled {
+			t.Fatalf("expected Canceled, got %v", err)
+		}
+		for _, c := range fs.Chunks() {
+			if c.Type == string(events.Failed) {
+				t.Fatalf("failed chunk sent after cancellation: %+v", fs.Chunks())
+			}
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		fs := llmtest.NewServerStream(ctx)
+		cfg := base
+		cfg.StreamDelayMinMs, cfg.StreamDelayMaxMs = config.Int(20), config.Int(20)
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+		for _, c := range fs.Chunks() {
+			if c.Type == string(events.Failed) {
+				t.Fatalf("failed chunk sent after the deadline: %+v", fs.Chunks())
+			}
+		}
+	})
+
+	t.Run("send failure", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		fs.FailAfter = 2
+		err := NewMockLlmService(base).ChatCompletionStream(req, fs)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected the Send error, got %v", err)
+		}
+		if len(fs.Chunks()) != 2 || fs.Attempts() != 3 {
+			t.Fatalf("expected no send after the broken one, got %d sent in %d attempts", len(fs.Chunks()), fs.Attempts())
+		}
+	})
 }
 
-func (f *fakeStream) SetTrailer(md metadata.MD) {
-	f.trailer = md
+// TestChatCompletionContextErrors verifies the unary RPC reports a canceled or expired context as a
+// Canceled or DeadlineExceeded status rather than a bare context error.
+func TestChatCompletionContextErrors(t *testing.T) {
+	svc := NewMockLlmService(config.Config{TTFTMinMs: config.Int(200), TTFTMaxMs: config.Int(200)})
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "hi"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := svc.ChatCompletion(ctx, req)
+	if _, ok := status.FromError(err); !ok || status.Code(err) != codes.Canceled {
+		t.Fatalf("expected a Canceled status, got %#v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = svc.ChatCompletion(ctx, req)
+	if _, ok := status.FromError(err); !ok || status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected a DeadlineExceeded status, got %#v", err)
+	}
 }
 
-func (f *fakeStream) Context() context.Context {
-	return f.ctx
+// TestStreamSendCounters verifies the termination log and the failed chunk report what the stream
+// actually sent before it ended.
+func TestStreamSendCounters(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	sentBytes := func(fs *llmtest.ServerStream) (n int) {
+		for _, c := range fs.Chunks() {
+			n += len(c.GetText())
+		}
+		return n
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		logs.TakeAll()
+		svc := NewMockLlmService(config.Config{ChunkSize: config.Int(4), StrictTokenMode: config.Bool(true)})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		fs := llmtest.NewServerStream(ctx)
+		fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+			if len(fs.Chunks()) == 3 {
+				cancel()
+			}
+		}
+		if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "cancel me", MaxTokens: 64}, fs); status.Code(err) != codes.Canceled {
+			t.Fatalf("expected Canceled, got %v", err)
+		}
+		entries := logs.FilterMessage("[grpc][ChatCompletionStream] canceled").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one canceled line, got %d", len(entries))
+		}
+		f := entries[0].ContextMap()
+		if f["chunksSent"] != int64(len(fs.Chunks())) || f["bytesSent"] != int64(sentBytes(fs)) || f["tokensSent"].(int64) <= 0 {
+			t.Fatalf("counters %v don't match %d sends of %d bytes", f, len(fs.Chunks()), sentBytes(fs))
+		}
+		if last, ok := f["lastSendAt"].(time.Time); !ok || last.IsZero() {
+			t.Fatalf("missing last send time: %v", f["lastSendAt"])
+		}
+	})
+
+	t.Run("failed chunk", func(t *testing.T) {
+		logs.TakeAll()
+		// The simulated KV cache runs out after a few deltas.
+		svc := NewMockLlmService(config.Config{ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true), MemBytesPerToken: 1000, MemLimitBytes: 40_000})
+		fs := llmtest.NewServerStream(context.Background())
+		if err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 64}, fs); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected ResourceExhausted, got %v", err)
+		}
+		failed := fs.Chunks()[len(fs.Chunks())-1]
+		deltas := fs.Chunks()[:len(fs.Chunks())-1]
+		if failed.GetErrorCode() == "" || len(deltas) == 0 {
+			t.Fatalf("expected deltas then a failed chunk, got %v", fs.Chunks())
+		}
+		if int(failed.GetChunksSent()) != len(deltas) || int(failed.GetBytesSent()) != sentBytes(fs) || failed.GetTokensSent() <= 0 {
+			t.Fatalf("failed chunk reports %d chunks / %d bytes, stream sent %d / %d", failed.GetChunksSent(), failed.GetBytesSent(), len(deltas), sentBytes(fs))
+		}
+		entries := logs.FilterMessage("[grpc][ChatCompletionStream] error").All()
+		if len(entries) != 1 || entries[0].ContextMap()["chunksSent"] != int64(len(deltas)) {
+			t.Fatalf("expected one error line with %d chunks, got %v", len(deltas), entries)
+		}
+	})
 }
 
-func (f *fakeStream) SendMsg(m interface{}) error {
-	_, ok := m.(*llmv1.ChatCompletionChunkResponse)
-	if !ok {
-		return fmt.Errorf("unexpected message type %T", m)
+// TestStreamPartialUsage fails streams after some deltas and checks the failed chunk and the status
+// report the usage delivered: the completion tokens of the concatenated deltas, not their sum per
+// chunk (the chunks split the runes of the echoed prompt) nor the planned output.
+func TestStreamPartialUsage(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(5), StrictTokenMode: config.Bool(true), EchoPrompt: true}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "héllo wörld ünïcode", MaxTokens: 64}
+	pt := promptTokens(cfg, req)
+	partialUsage := func(err error) map[string]string {
+		for _, d := range status.Convert(err).Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == partialUsageDomain && info.GetReason() == partialUsageReason {
+				return info.GetMetadata()
+			}
+		}
+		return nil
 	}
-	return nil
+
+	t.Run("failed chunk", func(t *testing.T) {
+		// The simulated KV cache runs out after a few deltas.
+		cfg := cfg
+		cfg.MemBytesPerToken, cfg.MemLimitBytes = 1000, 30_000
+		fs := llmtest.NewServerStream(context.Background())
+		err := NewMockLlmService(cfg).ChatCompletionStream(req, fs)
+		failed := llmtest.RequireFailed(t, fs.Chunks(), codes.ResourceExhausted)
+		text := llmtest.Text(fs.Chunks())
+		ct := mock.ApproxTokens(text)
+		if text == "" || ct == int(failed.GetTokensSent()) {
+			t.Fatalf("the deltas %q should split runes, so their tokens differ from the per-chunk sum", text)
+		}
+		if int(failed.GetPromptTokens()) != pt || int(failed.GetCompletionTokens()) != ct || int(failed.GetTotalTokens()) != pt+ct || failed.GetLatencyMs() < 0 {
+			t.Fatalf("failed chunk usage %d+%d=%d, want %d+%d", failed.GetPromptTokens(), failed.GetCompletionTokens(), failed.GetTotalTokens(), pt, ct)
+		}
+		if md := partialUsage(err); md["completion_tokens"] != fmt.Sprint(ct) || md["prompt_tokens"] != fmt.Sprint(pt) || md["total_tokens"] != fmt.Sprint(pt+ct) {
+			t.Fatalf("status usage %v, want %d+%d", md, pt, ct)
+		}
+		// A genuine failure carries nothing under the domain of the injected marker.
+		for _, d := range status.Convert(err).Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == injectedErrorDomain {
+				t.Fatalf("genuine failure has an ErrorInfo in the injected domain: %v", info)
+			}
+		}
+	})
+
+	t.Run("broken stream", func(t *testing.T) {
+		// No failed chunk reaches a broken stream; the status still has the usage of what did.
+		fs := llmtest.NewServerStream(context.Background())
+		fs.FailAfter = 4
+		err := NewMockLlmService(cfg).ChatCompletionStream(req, fs)
+		if status.Code(err) != codes.Unavailable || len(fs.Chunks()) != 4 {
+			t.Fatalf("expected Unavailable after 4 chunks, got %v after %d", err, len(fs.Chunks()))
+		}
+		if md := partialUsage(err); md["completion_tokens"] != fmt.Sprint(mock.ApproxTokens(llmtest.Text(fs.Chunks()))) {
+			t.Fatalf("status usage %v, want the tokens of %q", md, llmtest.Text(fs.Chunks()))
+		}
+	})
+
+	t.Run("before the first delta", func(t *testing.T) {
+		cfg := cfg
+		cfg.MemBytesPerToken, cfg.MemLimitBytes = 1000, 1000 // not even the prompt fits
+		err := NewMockLlmService(cfg).ChatCompletionStream(req, llmtest.NewServerStream(context.Background()))
+		if status.Code(err) != codes.ResourceExhausted || partialUsage(err) != nil {
+			t.Fatalf("expected ResourceExhausted without usage details, got %v", err)
+		}
+	})
 }
 
-func (f *fakeStream) RecvMsg(interface{}) error {
-	return nil
+// TestStreamSlowConsumer verifies the time spent blocked in Send is measured, and that
+// SLOW_CONSUMER_ABORT_MS cuts off a client that stops reading.
+func TestStreamSlowConsumer(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	// slowReader blocks the third Send for d, like a client whose flow-control window is full.
+	slowReader := func(d time.Duration) (*llmtest.ServerStream, chan struct{}) {
+		fs := llmtest.NewServerStream(context.Background())
+		unblocked := make(chan struct{})
+		fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+			if len(fs.Chunks()) == 3 {
+				time.Sleep(d)
+				close(unblocked)
+			}
+		}
+		return fs, unblocked
+	}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "slow reader", MaxTokens: 64}
+
+	t.Run("measured", func(t *testing.T) {
+		logs.TakeAll()
+		before := histogramCount(t, metrics.SendBlocked, "ChatCompletionStream")
+		svc := NewMockLlmService(config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true)})
+		fs, _ := slowReader(60 * time.Millisecond)
+		if err := svc.ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		entries := logs.FilterMessage("[grpc][ChatCompletionStream] done").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one done line, got %d", len(entries))
+		}
+		if ms := entries[0].ContextMap()["sendBlockedMs"].(int64); ms < 60 || ms > 200 {
+			t.Fatalf("expected about 60ms blocked in Send, got %dms", ms)
+		}
+		if got := histogramCount(t, metrics.SendBlocked, "ChatCompletionStream"); got != before+1 {
+			t.Fatalf("expected one send_blocked_seconds observation, got %d", got-before)
+		}
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		logs.TakeAll()
+		svc := NewMockLlmService(config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), SlowConsumerAbortMs: 30})
+		fs, unblocked := slowReader(200 * time.Millisecond)
+		start := time.Now()
+		err := svc.ChatCompletionStream(req, fs)
+		if status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "slow consumer") {
+			t.Fatalf("expected a slow consumer ResourceExhausted, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Fatalf("the stream waited %v for the blocked Send instead of aborting", elapsed)
+		}
+		<-unblocked
+		// Nothing, not even a failed chunk, was sent after the stuck chunk.
+		if len(fs.Chunks()) != 3 || fs.Attempts() != 3 {
+			t.Fatalf("expected the stream to stop at the blocked chunk, got %d sends", fs.Attempts())
+		}
+		if logs.FilterMessage("[grpc][ChatCompletionStream] error").Len() != 1 {
+			t.Fatal("expected the abort to be logged")
+		}
+	})
 }
 
 // TestChatCompletionStreamContextCanceled verifies the streaming RPC stops promptly when the client context
@@ -273,9 +671,9 @@ func TestChatCompletionStreamContextCanceled(t *testing.T) {
 		ErrorRate:        0,
 		ErrorMode:        "mixed",
 		DefaultTokens:    0,
-		ChunkSize:        4,
-		StreamDelayMinMs: 0,
-		StreamDelayMaxMs: 0,
+		ChunkSize:        config.Int(4),
+		StreamDelayMinMs: config.Int(0),
+		StreamDelayMaxMs: config.Int(0),
 	}
 
 	svc := NewMockLlmService(cfg)
@@ -289,8 +687,8 @@ func TestChatCompletionStreamContextCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	fs := &fakeStream{ctx: ctx}
-	fs.onSend = func(res *llmv1.ChatCompletionChunkResponse) {
+	fs := llmtest.NewServerStream(ctx)
+	fs.OnSend = func(res *llmv1.ChatCompletionChunkResponse) {
 		// Cancel after receiving the first non-empty delta chunk.
 		if res.GetText() != "" {
 			cancel()
@@ -302,18 +700,576 @@ func TestChatCompletionStreamContextCanceled(t *testing.T) {
 		t.Fatalf("expected cancellation error")
 	}
 
-	// Depending on where cancellation is observed, it may surface as context.Canceled (possibly wrapped) or a gRPC Canceled status.
-	if !errors.Is(err, context.Canceled) && status.Code(err) != codes.Canceled {
-		t.Fatalf("expected canceled, got %v", err)
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("expected a Canceled status, got %v", err)
 	}
 
-	if len(fs.sent) == 0 {
+	if len(fs.Chunks()) == 0 {
 		t.Fatalf("expected at least one chunk before cancellation")
 	}
 
 	// Ensure we did not send the final finish chunk.
-	last := fs.sent[len(fs.sent)-1]
-	if last.GetFinishReason() == "stop" {
+	last := fs.Chunks()[len(fs.Chunks())-1]
+	if last.GetFinishReason() == string(events.FinishStop) {
 		t.Fatalf("should not send final finish chunk when canceled")
 	}
 }
+
+// TestTimingBreakdown verifies both RPCs report the measured queue/prefill/TTFT/generation phases, and that
+// the phases are consistent with the configured delays and the total latency.
+func TestTimingBreakdown(t *testing.T) {
+	cfg := config.Config{
+		BaseDelayMs:  10,
+		TTFTMinMs:    config.Int(20),
+		TTFTMaxMs:    config.Int(20),
+		TokensPerSec: config.Float(1000),
+		ChunkSize:    config.Int(32),
+	}
+	svc := NewMockLlmService(cfg)
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "timing", MaxTokens: 32}
+
+	t.Run("unary", func(t *testing.T) {
+		resp, err := svc.ChatCompletion(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ChatCompletion err: %v", err)
+		}
+		if resp.QueueMs < 10 || resp.PromptEvalMs < 20 {
+			t.Fatalf("pre-delay phases too short: %+v", resp)
+		}
+		if resp.TtftMs < resp.QueueMs+resp.PromptEvalMs {
+			t.Fatalf("ttft should cover queue+prefill: %+v", resp)
+		}
+		if resp.SimulatedTtftMs < 30 || resp.SimulatedTtftMs > resp.TtftMs {
+			t.Fatalf("simulated ttft should be the slept queue+prefill: %+v", resp)
+		}
+		if resp.GenerationMs <= 0 || resp.LatencyMs < resp.TtftMs+resp.GenerationMs {
+			t.Fatalf("inconsistent generation/latency: %+v", resp)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		if err := svc.ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		done := fs.Chunks()[len(fs.Chunks())-1]
+		if done.QueueMs < 10 || done.PromptEvalMs < 20 {
+			t.Fatalf("pre-delay phases too short: %+v", done)
+		}
+		if done.TtftMs < done.QueueMs+done.PromptEvalMs {
+			t.Fatalf("ttft should cover queue+prefill: %+v", done)
+		}
+		if done.GenerationMs <= 0 || done.LatencyMs < done.TtftMs+done.GenerationMs {
+			t.Fatalf("inconsistent generation/latency: %+v", done)
+		}
+		for _, ch := range fs.Chunks()[:len(fs.Chunks())-1] {
+			if ch.TtftMs != 0 || ch.GenerationMs != 0 {
+				t.Fatalf("timing should only be set on the done chunk: %+v", ch)
+			}
+		}
+	})
+}
+
+// TestTimingWithoutDelays verifies the measured TTFT is about zero, and still reported, when nothing
+// is slept before the first token.
+func TestTimingWithoutDelays(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true)}
+	svc := NewMockLlmService(cfg)
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "no delays", MaxTokens: 32}
+	const slack = 5 // ms
+
+	t.Run("unary", func(t *testing.T) {
+		resp, err := svc.ChatCompletion(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ChatCompletion err: %v", err)
+		}
+		if resp.SimulatedTtftMs != 0 || resp.TtftMs > slack || resp.GenerationMs > slack {
+			t.Fatalf("expected ~0ms timings: %+v", resp)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		if err := svc.ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		done := fs.Chunks()[len(fs.Chunks())-1]
+		if done.Type != string(events.OutputTextDone) || done.TtftMs > slack || done.GenerationMs > slack || done.LatencyMs < done.TtftMs+done.GenerationMs {
+			t.Fatalf("expected ~0ms timings on the done chunk: %+v", done)
+		}
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "no delays"), 32, cfg, *cfg.ChunkSize, nil)
+		chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+		timing := chunks[len(chunks)-1].Timing
+		if timing == nil || timing.TTFTMs > slack || timing.GenerationMs > slack {
+			t.Fatalf("expected ~0ms timings on the final chunk: %+v", timing)
+		}
+	})
+}
+
+// TestFractionalTokensPerSec verifies pacing above 1000 tok/s and fractional rates: gaps are kept
+// below a millisecond instead of being rounded up per chunk.
+func TestFractionalTokensPerSec(t *testing.T) {
+	cfg := config.Config{TokensPerSec: config.Float(2000), ChunkSize: config.Int(12), StrictTokenMode: config.Bool(true)}
+	svc := NewMockLlmService(cfg)
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "pacing", MaxTokens: 200}
+
+	t.Run("stream", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		start := time.Now()
+		if err := svc.ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		elapsed := time.Since(start)
+		if ct := fs.Chunks()[len(fs.Chunks())-1].CompletionTokens; ct != 200 {
+			t.Fatalf("expected 200 completion tokens, got %d", ct)
+		}
+		// 200 tokens at 2000 tok/s is 100ms; rounding each 1.5ms gap up to whole milliseconds would take 200ms.
+		if elapsed < 80*time.Millisecond || elapsed > 160*time.Millisecond {
+			t.Fatalf("expected a ~100ms stream, took %v", elapsed)
+		}
+	})
+
+	t.Run("unary", func(t *testing.T) {
+		if ms := svc.generationMs(200); ms != 100 {
+			t.Fatalf("expected 100ms for 200 tokens at 2000 tok/s, got %d", ms)
+		}
+		svc := NewMockLlmService(config.Config{TokensPerSec: config.Float(45.5)})
+		if ms := svc.generationMs(91); ms != 2000 {
+			t.Fatalf("expected 2000ms for 91 tokens at 45.5 tok/s, got %d", ms)
+		}
+	})
+}
+
+// TestStreamPacingThroughput verifies a long stream keeps to TokensPerSec: 500 tokens at 250 tok/s
+// take 2s, without per-chunk sleep overhead adding up over the 125 chunks.
+func TestStreamPacingThroughput(t *testing.T) {
+	cfg := config.Config{TokensPerSec: config.Float(250), ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(4096)}
+	fs := llmtest.NewServerStream(context.Background())
+	start := time.Now()
+	if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "throughput", MaxTokens: 500}, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	elapsed := time.Since(start)
+	if ct := fs.Chunks()[len(fs.Chunks())-1].CompletionTokens; ct != 500 {
+		t.Fatalf("expected 500 completion tokens, got %d", ct)
+	}
+	// ±25%: generous enough for -race and loaded CI machines, still far from unpaced or doubled.
+	if elapsed < 1500*time.Millisecond || elapsed > 2500*time.Millisecond {
+		t.Fatalf("expected ~2s for 500 tokens at 250 tok/s, took %v", elapsed)
+	}
+}
+
+// TestStreamTPSCurve verifies TPS_CURVE shapes the speed within one stream: rampup spends longer on
+// the first half of the tokens than on the second, decay the other way round, and the stream still
+// takes the time curveDuration predicts for the whole response.
+func TestStreamTPSCurve(t *testing.T) {
+	for _, tt := range []struct {
+		curve      string
+		slowerHalf int // 0: first, 1: second
+	}{
+		{"rampup", 0},
+		{"decay", 1},
+	} {
+		t.Run(tt.curve, func(t *testing.T) {
+			cfg := config.Config{
+				TokensPerSec:    config.Float(400),
+				TPSCurve:        tt.curve,
+				TPSCurveTokens:  200,
+				TPSCurveDepth:   0.5,
+				ChunkSize:       config.Int(16),
+				StrictTokenMode: config.Bool(true),
+			}
+			mock.Seed(7)
+
+			var (
+				toks       int
+				first, mid time.Time
+				fs         = llmtest.NewServerStream(context.Background())
+			)
+			fs.OnSend = func(res *llmv1.ChatCompletionChunkResponse) {
+				if first.IsZero() {
+					first = time.Now()
+				}
+				toks += mock.ApproxTokens(res.GetText())
+				if mid.IsZero() && toks >= 100 {
+					mid = time.Now()
+				}
+			}
+			if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "curve", MaxTokens: 200}, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+			end := time.Now()
+
+			halves := [2]time.Duration{mid.Sub(first), end.Sub(mid)}
+			slow, fast := halves[tt.slowerHalf], halves[1-tt.slowerHalf]
+			if slow < fast*6/5 {
+				t.Fatalf("expected the %s half to be clearly slower: first %v, second %v", []string{"first", "second"}[tt.slowerHalf], halves[0], halves[1])
+			}
+			want := curveDuration(cfg, 0, toks, 400)
+			if total := end.Sub(first); total < want-40*time.Millisecond || total > want+80*time.Millisecond {
+				t.Fatalf("expected ~%v for %d tokens on the %s curve, took %v", want, toks, tt.curve, total)
+			}
+		})
+	}
+}
+
+// TestBurstMode verifies BURST_MODE makes chunk arrival bimodal on the gRPC stream and SSE: bursts
+// of BurstSize chunks back to back, then a gap paying for the burst's 16 tokens at 200 tok/s (80ms),
+// so the stream as a whole keeps to TokensPerSec. BURST_GAP_MS stretches shorter gaps.
+func TestBurstMode(t *testing.T) {
+	cfg := config.Config{
+		TokensPerSec:    config.Float(200),
+		ChunkSize:       config.Int(16),
+		StrictTokenMode: config.Bool(true),
+		ChunkTimestamps: true,
+		BurstMode:       true,
+		BurstSize:       4,
+	}
+	mock.Seed(7)
+
+	// checkGaps checks the 16 deltas of a 64-token stream come in groups of 4: the gaps within a
+	// burst are well under want and the 3 between bursts about want. The bounds are loose enough
+	// for -race and loaded CI machines; it is the grouping that matters.
+	checkGaps := func(t *testing.T, sends []time.Time, want time.Duration) {
+		t.Helper()
+		if len(sends) != 16 {
+			t.Fatalf("expected 16 deltas, got %d", len(sends))
+		}
+		for i := 1; i < len(sends); i++ {
+			gap := sends[i].Sub(sends[i-1])
+			if i%4 != 0 && gap >= want/4 {
+				t.Fatalf("gap %d within a burst is %v, want under %v", i, gap, want/4)
+			}
+			if i%4 == 0 && (gap < want*3/4 || gap > want*3/2) {
+				t.Fatalf("gap %d between bursts is %v, want about %v", i, gap, want)
+			}
+		}
+	}
+
+	// grpcSends runs a gRPC stream and returns when each delta was sent and how long it all took.
+	grpcSends := func(t *testing.T, cfg config.Config) ([]time.Time, time.Duration) {
+		t.Helper()
+		var sends []time.Time
+		fs := llmtest.NewServerStream(context.Background())
+		fs.OnSend = func(res *llmv1.ChatCompletionChunkResponse) {
+			if res.GetType() == string(events.OutputTextDelta) {
+				sends = append(sends, time.Now())
+			}
+		}
+		start := time.Now()
+		if err := NewMockLlmService(cfg).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "burst", MaxTokens: 64}, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		return sends, time.Since(start)
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		sends, elapsed := grpcSends(t, cfg)
+		checkGaps(t, sends, 80*time.Millisecond)
+		// ±25%, as for the pacing throughput test.
+		if elapsed < 240*time.Millisecond || elapsed > 400*time.Millisecond {
+			t.Fatalf("expected ~320ms for 64 tokens at 200 tok/s, took %v", elapsed)
+		}
+	})
+
+	t.Run("gap floor", func(t *testing.T) {
+		floor := cfg
+		floor.BurstGapMs = 150
+		sends, _ := grpcSends(t, floor)
+		checkGaps(t, sends, 150*time.Millisecond)
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "burst"), 64, cfg, 16, nil)
+		chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+		var sends []time.Time
+		for _, ch := range chunks[1 : len(chunks)-1] {
+			sends = append(sends, time.UnixMilli(ch.EmittedAtUnixMs))
+		}
+		checkGaps(t, sends, 80*time.Millisecond)
+	})
+}
+
+// TestStreamDelayDistribution draws seeded stream delays around the same 30ms center and checks the
+// tail each STREAM_DELAY_DISTRIBUTION gives: normal is tighter than uniform, lognormal much fatter,
+// and STREAM_DELAY_CAP_MS bounds every draw.
+func TestStreamDelayDistribution(t *testing.T) {
+	// quantiles returns the p50 and p99 of 20000 draws, and the largest one.
+	quantiles := func(cfg config.Config) (p50, p99, top time.Duration) {
+		mock.Seed(42)
+		d := make([]time.Duration, 20000)
+		for i := range d {
+			d[i] = streamDelay(cfg, nil)
+		}
+		slices.Sort(d)
+		return d[len(d)/2], d[len(d)*99/100], d[len(d)-1]
+	}
+	base := config.Config{StreamDelayMinMs: config.Int(10), StreamDelayMaxMs: config.Int(50), StreamDelayCapMs: 1000}
+	ratio := map[string]float64{}
+	for _, tt := range []struct {
+		dist   string
+		stddev int
+		sigma  float64
+	}{
+		{"uniform", 0, 0},
+		{"normal", 5, 0},
+		{"lognormal", 0, 1},
+	} {
+		cfg := base
+		cfg.StreamDelayDistribution, cfg.StreamDelayStddevMs, cfg.StreamDelayLogSigma = tt.dist, tt.stddev, tt.sigma
+		p50, p99, _ := quantiles(cfg)
+		if p50 < 25*time.Millisecond || p50 > 35*time.Millisecond {
+			t.Fatalf("%s: expected a p50 near 30ms, got %v", tt.dist, p50)
+		}
+		ratio[tt.dist] = float64(p99) / float64(p50)
+	}
+	if !(ratio["normal"] < ratio["uniform"] && ratio["uniform"]*3 < ratio["lognormal"]) {
+		t.Fatalf("unexpected p99/p50 ratios: %v", ratio)
+	}
+
+	capped := base
+	capped.StreamDelayDistribution, capped.StreamDelayLogSigma, capped.StreamDelayCapMs = "lognormal", 3, 200
+	if _, p99, top := quantiles(capped); p99 != 200*time.Millisecond || top != 200*time.Millisecond {
+		t.Fatalf("expected the tail clamped to 200ms, got p99 %v, max %v", p99, top)
+	}
+}
+
+// TestRejectShortDeadlines verifies a deadline below BASE_DELAY_MS + TTFT_MIN_MS is rejected right
+// away with a structured FailedPrecondition when REJECT_SHORT_DEADLINES is on, and runs into the
+// deadline as before when it is off.
+func TestRejectShortDeadlines(t *testing.T) {
+	cfg := config.Config{BaseDelayMs: 20, TTFTMinMs: config.Int(300), TTFTMaxMs: config.Int(800), RejectShortDeadlines: true}
+	calls := map[string]func(ctx context.Context, svc *MockLlmService) error{
+		"unary": func(ctx context.Context, svc *MockLlmService) error {
+			_, err := svc.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hi"})
+			return err
+		},
+		"stream": func(ctx context.Context, svc *MockLlmService) error {
+			return svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hi"}, llmtest.NewServerStream(ctx))
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := call(ctx, NewMockLlmService(cfg))
+			if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+				t.Fatalf("rejection took %v", elapsed)
+			}
+			st := status.Convert(err)
+			if st.Code() != codes.FailedPrecondition || !strings.Contains(st.Message(), "320ms") {
+				t.Fatalf("expected FailedPrecondition naming the 320ms minimum, got %v", err)
+			}
+			var info *errdetails.ErrorInfo
+			for _, d := range st.Details() {
+				if i, ok := d.(*errdetails.ErrorInfo); ok {
+					info = i
+				}
+			}
+			if info == nil || info.GetDomain() != errorDomain || info.GetReason() != deadlineTooShortReason || info.GetMetadata()["min_latency_ms"] != "320" {
+				t.Fatalf("unexpected error details: %v", st.Details())
+			}
+
+			// Off (the default): the request waits until its deadline expires.
+			off := cfg
+			off.RejectShortDeadlines = false
+			ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err = call(ctx, NewMockLlmService(off))
+			if status.Code(err) != codes.DeadlineExceeded {
+				t.Fatalf("expected DeadlineExceeded with the flag off, got %v", err)
+			}
+
+			// A deadline long enough is served.
+			fast := cfg
+			fast.TTFTMinMs, fast.TTFTMaxMs = config.Int(5), config.Int(5)
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := call(ctx, NewMockLlmService(fast)); err != nil {
+				t.Fatalf("expected a sufficient deadline to pass, got %v", err)
+			}
+		})
+	}
+}
+
+// TestInjectedErrorsCarryMarker verifies injected gRPC errors carry the INJECTED ErrorInfo detail
+// and genuine errors do not.
+func TestInjectedErrorsCarryMarker(t *testing.T) {
+	injectedInfo := func(err error) *errdetails.ErrorInfo {
+		for _, d := range status.Convert(err).Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetReason() == "INJECTED" {
+				return info
+			}
+		}
+		return nil
+	}
+
+	svc := NewMockLlmService(config.Config{ErrorRate: 1, ErrorMode: "429"})
+	_, err := svc.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{MaxTokens: 1})
+	info := injectedInfo(err)
+	if info == nil || info.GetDomain() != "llm-simulator" || info.GetMetadata()["mode"] != "429" {
+		t.Fatalf("unary: expected INJECTED ErrorInfo, got %v", status.Convert(err).Details())
+	}
+	err = svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{}, llmtest.NewServerStream(context.Background()))
+	if injectedInfo(err) == nil {
+		t.Fatalf("stream: expected INJECTED ErrorInfo, got %v", status.Convert(err).Details())
+	}
+
+	// Genuine failures: a canceled client and a rejected API key.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = NewMockLlmService(config.Config{BaseDelayMs: 50}).ChatCompletionStream(&llmv1.ChatCompletionRequest{}, llmtest.NewServerStream(ctx))
+	if err == nil || injectedInfo(err) != nil {
+		t.Fatalf("canceled stream: expected an unmarked error, got %v", err)
+	}
+	client := startTestServer(t, config.Config{APIKeys: []string{"sk-test"}})
+	_, err = client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{MaxTokens: 1})
+	if status.Code(err) != codes.Unauthenticated || injectedInfo(err) != nil {
+		t.Fatalf("auth failure: expected an unmarked Unauthenticated, got %v", err)
+	}
+}
+
+// TestChunkTimestamps verifies CHUNK_TIMESTAMPS stamps every chunk with a non-decreasing send time,
+// and that the first-delta timestamp on the done chunk is as far from its own as the pacing implies.
+func TestChunkTimestamps(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(10), StreamDelayMinMs: config.Int(20), StreamDelayMaxMs: config.Int(20), StrictTokenMode: config.Bool(true), ChunkTimestamps: true}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "timestamps", MaxTokens: 20}
+
+	fs := llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	var prev int64
+	for i, c := range fs.Chunks() {
+		if c.EmittedAtUnixMs == 0 || c.EmittedAtUnixMs < prev {
+			t.Fatalf("chunk %d: emitted_at_unix_ms %d after %d", i, c.EmittedAtUnixMs, prev)
+		}
+		prev = c.EmittedAtUnixMs
+	}
+	done := fs.Chunks()[len(fs.Chunks())-1]
+	if done.FirstDeltaAtUnixMs != fs.Chunks()[0].EmittedAtUnixMs {
+		t.Fatalf("done chunk first delta at %d, first delta emitted at %d", done.FirstDeltaAtUnixMs, fs.Chunks()[0].EmittedAtUnixMs)
+	}
+	// Every delta is followed by a 20ms gap.
+	want := int64(len(fs.Chunks())-1) * 20
+	if spread := done.EmittedAtUnixMs - done.FirstDeltaAtUnixMs; spread < want || spread > 2*want {
+		t.Fatalf("expected ~%dms from the first delta to done, got %dms", want, spread)
+	}
+
+	cfg.ChunkTimestamps = false
+	fs = llmtest.NewServerStream(context.Background())
+	if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+		t.Fatalf("ChatCompletionStream err: %v", err)
+	}
+	for i, c := range fs.Chunks() {
+		if c.EmittedAtUnixMs != 0 || c.FirstDeltaAtUnixMs != 0 {
+			t.Fatalf("chunk %d stamped without CHUNK_TIMESTAMPS: %+v", i, c)
+		}
+	}
+}
+
+// TestUsageTrailers verifies both RPCs mirror the usage and latency they report in trailer metadata.
+func TestUsageTrailers(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(16), StrictTokenMode: config.Bool(true)}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "trailers", MaxTokens: 24}
+	check := func(t *testing.T, md metadata.MD, pt, ct int32, latencyMs int64) {
+		t.Helper()
+		want := map[string]string{
+			"x-usage-prompt-tokens":     fmt.Sprint(pt),
+			"x-usage-completion-tokens": fmt.Sprint(ct),
+			"x-latency-ms":              fmt.Sprint(latencyMs),
+		}
+		for k, v := range want {
+			if got := md.Get(k); len(got) != 1 || got[0] != v {
+				t.Fatalf("trailer %s: got %v, want %s (trailer %v)", k, got, v, md)
+			}
+		}
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		client := startTestServer(t, cfg)
+		var trailer metadata.MD
+		resp, err := client.ChatCompletion(context.Background(), req, grpc.Trailer(&trailer))
+		if err != nil {
+			t.Fatalf("ChatCompletion err: %v", err)
+		}
+		check(t, trailer, resp.PromptTokens, resp.CompletionTokens, resp.LatencyMs)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		done := fs.Chunks()[len(fs.Chunks())-1]
+		check(t, fs.Trailer(), done.PromptTokens, done.CompletionTokens, done.LatencyMs)
+	})
+}
+
+// TestResponseHeader verifies both RPCs send the request ID, model and preset as header metadata, and
+// that the stream sends it after the pre-delay and before the first chunk, or before the pre-delay
+// with GRPC_HEADERS_FIRST.
+func TestResponseHeader(t *testing.T) {
+	cfg := config.Config{Preset: "vllm", TTFTMinMs: config.Int(40), TTFTMaxMs: config.Int(40), ChunkSize: config.Int(16)}
+	req := &llmv1.ChatCompletionRequest{Model: "m-header", UserPrompt: "headers", MaxTokens: 16}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-header-test"))
+	check := func(t *testing.T, md metadata.MD) {
+		t.Helper()
+		want := map[string]string{"x-request-id": "req-header-test", "x-model": "m-header", "x-preset": "vllm"}
+		for k, v := range want {
+			if got := md.Get(k); len(got) != 1 || got[0] != v {
+				t.Fatalf("header %s: got %v, want %s (header %v)", k, got, v, md)
+			}
+		}
+	}
+
+	for _, first := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream headersFirst=%t", first), func(t *testing.T) {
+			cfg := cfg
+			cfg.GRPCHeadersFirst = first
+			fs := llmtest.NewServerStream(ctx)
+			fs.OnSend = func(*llmv1.ChatCompletionChunkResponse) {
+				if fs.Header() == nil {
+					t.Errorf("chunk sent before the header")
+				}
+			}
+			start := time.Now()
+			if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("ChatCompletionStream err: %v", err)
+			}
+			check(t, fs.Header())
+			if afterDelay := fs.HeaderSentAt().Sub(start) >= 40*time.Millisecond; afterDelay == first {
+				t.Fatalf("header sent %v after start with GRPC_HEADERS_FIRST=%t", fs.HeaderSentAt().Sub(start), first)
+			}
+		})
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		client := startTestServer(t, cfg)
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-header-test")
+		if _, err := client.ChatCompletion(ctx, req, grpc.Header(&header)); err != nil {
+			t.Fatalf("ChatCompletion err: %v", err)
+		}
+		check(t, header)
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		fs := llmtest.NewServerStream(context.Background())
+		if err := NewMockLlmService(config.Config{}).ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "hi"}, fs); err != nil {
+			t.Fatalf("ChatCompletionStream err: %v", err)
+		}
+		if id := fs.Header().Get("x-request-id"); len(id) != 1 || !strings.HasPrefix(id[0], "req_") {
+			t.Fatalf("expected a generated request id, got %v", fs.Header())
+		}
+		if m := fs.Header().Get("x-model"); len(m) != 1 || m[0] != "mock-grpc" {
+			t.Fatalf("expected the default model, got %v", fs.Header())
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NewSinglePortServer creates an HTTP server for SINGLE_PORT mode: gRPC calls (HTTP/2 requests with
+// an application/grpc content type) are served by grpcSrv through grpc.Server.ServeHTTP, everything
+// else by h (see NewHTTPMux). gRPC clients connect with HTTP/2 prior knowledge (h2c). Shutdown drains
+// both protocols; grpcSrv itself is never Run, and its TLS credentials do not apply.
+func NewSinglePortServer(addr string, grpcSrv *Server, h http.Handler) *HTTPServer {
+	s := NewHTTPServer(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPCRequest(r) {
+			grpcSrv.current().ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	s.grpc = grpcSrv
+	return s
+}
+
+// isGRPCRequest reports whether r is a native gRPC call (gRPC-Web requests go to the HTTP mux).
+func isGRPCRequest(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return r.ProtoMajor == 2 && strings.HasPrefix(ct, "application/grpc") && !strings.HasPrefix(ct, "application/grpc-web")
+}
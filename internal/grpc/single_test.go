@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// startSinglePort serves gRPC and the HTTP mux for cfg on one loopback port and returns the server,
+// its address and a gRPC client dialed to it.
+func startSinglePort(t *testing.T, cfg config.Config) (*HTTPServer, string, llmv1.LlmServiceClient) {
+	t.Helper()
+	ready := NewReadiness(1)
+	opts, err := ServerOptions(cfg, nil, ready)
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	grpcSrv := NewGRPCServer("", NewMockLlmService(cfg), opts...)
+	grpcSrv.SetReadiness(ready)
+	srv := NewSinglePortServer("127.0.0.1:0", grpcSrv, NewHTTPMux(cfg, nil, nil, ready))
+	srv.SetReadiness(ready)
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go func() { _ = srv.Run() }()
+	t.Cleanup(func() { _ = srv.httpServer.Close() })
+
+	addr := srv.Addr().String()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, addr, llmv1.NewLlmServiceClient(conn)
+}
+
+// singlePortStreams runs one gRPC stream and one SSE request against addr concurrently and returns
+// the gRPC text and the SSE body.
+func singlePortStreams(t *testing.T, addr string, client llmv1.LlmServiceClient, started *sync.WaitGroup) (string, string) {
+	t.Helper()
+	var wg sync.WaitGroup
+	var grpcText strings.Builder
+	var sseBody string
+	var grpcErr, sseErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		stream, err := client.ChatCompletionStream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "grpc", MaxTokens: 32})
+		if err != nil {
+			grpcErr = err
+			started.Done()
+			return
+		}
+		first := true
+		for {
+			chunk, err := stream.Recv()
+			if first {
+				started.Done()
+				first = false
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				grpcErr = err
+				return
+			}
+			grpcText.WriteString(chunk.GetText())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		resp, err := http.Post("http://"+addr+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"messages":[{"role":"user","content":"sse"}],"max_tokens":32,"stream":true}`))
+		started.Done()
+		if err != nil {
+			sseErr = err
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		sseBody, sseErr = string(b), err
+	}()
+	wg.Wait()
+
+	if grpcErr != nil || sseErr != nil {
+		t.Fatalf("streams failed: grpc=%v sse=%v", grpcErr, sseErr)
+	}
+	return grpcText.String(), sseBody
+}
+
+func TestSinglePortServesBothProtocols(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+	_, addr, client := startSinglePort(t, cfg)
+
+	var started sync.WaitGroup
+	started.Add(2)
+	text, body := singlePortStreams(t, addr, client, &started)
+	if text == "" {
+		t.Fatalf("expected gRPC deltas")
+	}
+	if !parseSSE(t, strings.TrimSpace(body)).done {
+		t.Fatalf("expected a complete SSE stream, got:\n%s", body)
+	}
+
+	resp, err := http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		t.Fatalf("readyz failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected ready, got %d", resp.StatusCode)
+	}
+}
+
+func TestSinglePortShutdownDrainsBothProtocols(t *testing.T) {
+	// ~10 chunks 40ms apart per stream: both are still running when shutdown starts.
+	cfg := config.Config{ChunkSize: config.Int(8), DebugOutputChars: 80, MaxOutputChars: config.Int(80), StreamDelayMinMs: config.Int(40), StreamDelayMaxMs: config.Int(40)}
+	srv, addr, client := startSinglePort(t, cfg)
+
+	var started sync.WaitGroup
+	started.Add(2)
+	shutdownErr := make(chan error, 1)
+	go func() {
+		started.Wait()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
+	text, body := singlePortStreams(t, addr, client, &started)
+	if len(text) != 80 {
+		t.Fatalf("gRPC stream should finish during drain, got %d chars", len(text))
+	}
+	if !parseSSE(t, strings.TrimSpace(body)).done {
+		t.Fatalf("SSE stream should finish during drain, got:\n%s", body)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("shutdown should drain within the deadline: %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		t.Fatalf("listener should be closed after shutdown")
+	}
+}
@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+// GetStats returns the runtime counters in stats.Default, zeroing them when req.ResetCounters is set.
+func (s *MockLlmService) GetStats(_ context.Context, req *llmv1.GetStatsRequest) (*llmv1.GetStatsResponse, error) {
+	snap := stats.Default.Snapshot(req.GetResetCounters())
+	resp := &llmv1.GetStatsResponse{
+		SinceMs:              snap.SinceMs,
+		Rpcs:                 make(map[string]*llmv1.RpcStats, len(snap.RPCs)),
+		InjectedErrors:       snap.InjectedErrors,
+		ActiveStreams:        snap.ActiveStreams,
+		SimulatedMemoryBytes: snap.SimulatedMemoryBytes,
+		ScenarioPhase:        snap.ScenarioPhase,
+		TotalTokens:          snap.TotalTokens,
+		P50Ms:                snap.Latency.P50Ms,
+		P90Ms:                snap.Latency.P90Ms,
+		P99Ms:                snap.Latency.P99Ms,
+		LatencySamples:       int32(snap.Latency.Samples),
+		Usage:                make(map[string]*llmv1.ModelUsage, len(snap.Usage)),
+	}
+	if fs := faultScheduleState(); fs != nil {
+		resp.FaultUptimeMs = fs.UptimeMs
+		for _, f := range fs.Faults {
+			resp.Faults = append(resp.Faults, &llmv1.FaultState{FaultType: f.Type, OffsetMs: f.OffsetMs, DurationMs: f.DurationMs, State: f.State})
+		}
+	}
+	for model, keys := range snap.Usage {
+		mu := &llmv1.ModelUsage{Keys: make(map[string]*llmv1.KeyUsage, len(keys))}
+		for k, u := range keys {
+			mu.Keys[k] = &llmv1.KeyUsage{
+				Requests:         u.Requests,
+				PromptTokens:     u.PromptTokens,
+				CompletionTokens: u.CompletionTokens,
+			}
+		}
+		resp.Usage[model] = mu
+	}
+	for name, r := range snap.RPCs {
+		resp.Rpcs[name] = rpcStatsProto(r)
+	}
+	if len(snap.Tenants) > 0 {
+		resp.Tenants = make(map[string]*llmv1.RpcStats, len(snap.Tenants))
+		for name, r := range snap.Tenants {
+			resp.Tenants[name] = rpcStatsProto(r)
+		}
+	}
+	return resp, nil
+}
+
+func rpcStatsProto(r stats.RPCStats) *llmv1.RpcStats {
+	return &llmv1.RpcStats{
+		Requests:  r.Requests,
+		Successes: r.Successes,
+		Failures:  r.Failures,
+		Codes:     r.Codes,
+	}
+}
+
+// StatsHandler serves GET /stats: the same snapshot as GetStats as JSON. It never changes state, so
+// ?reset=true is refused; resets go through StatsResetHandler.
+func StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reset, _ := strconv.ParseBool(r.URL.Query().Get("reset")); reset {
+			writeOpenAIError(w, http.StatusBadRequest, "GET /stats doesn't reset; use POST /stats/reset")
+			return
+		}
+		writeStats(w, false)
+	}
+}
+
+// StatsResetHandler serves POST /stats/reset: the snapshot of GET /stats, taken as the counters
+// are zeroed.
+func StatsResetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeStats(w, true)
+	}
+}
+
+func writeStats(w http.ResponseWriter, reset bool) {
+	writeJSON(w, http.StatusOK, struct {
+		stats.Snapshot
+		FaultSchedule *FaultScheduleState `json:"fault_schedule,omitempty"`
+	}{stats.Default.Snapshot(reset), faultScheduleState()})
+}
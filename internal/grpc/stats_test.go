@@ -0,0 +1,244 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/metrics"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func TestStatsSnapshotAddsUp(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	client := startTestServer(t, cfg)
+	failing := startTestServer(t, config.Config{ErrorRate: 1, ErrorMode: "internal", MaxOutputChars: config.Int(64)})
+	ctx := context.Background()
+
+	if _, err := client.GetStats(ctx, &llmv1.GetStatsRequest{ResetCounters: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "count me", MaxTokens: 16}
+	var tokens int64
+	for i := 0; i < 2; i++ {
+		resp, err := client.ChatCompletion(ctx, req)
+		if err != nil {
+			t.Fatalf("unary failed: %v", err)
+		}
+		tokens += int64(resp.GetCompletionTokens())
+	}
+	stream, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		ch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		tokens += int64(ch.GetCompletionTokens())
+	}
+	if _, err := failing.ChatCompletion(ctx, req); status.Code(err) != codes.Internal {
+		t.Fatalf("expected injected Internal, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	body := `{"model":"m","stream":true,"max_tokens":16,"messages":[{"role":"user","content":"count me"}]}`
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("SSE request failed: %d", rr.Code)
+	}
+	var content strings.Builder
+	for _, c := range parseSSE(t, rr.Body.String()).chunks {
+		for _, ch := range c.Choices {
+			content.WriteString(ch.Delta.Content)
+		}
+	}
+	tokens += int64(mock.ApproxTokens(content.String()))
+
+	snap, err := client.GetStats(ctx, &llmv1.GetStatsRequest{ResetCounters: true})
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	unary := snap.GetRpcs()["ChatCompletion"]
+	if unary.GetRequests() != 3 || unary.GetSuccesses() != 2 || unary.GetFailures() != 1 ||
+		unary.GetCodes()["OK"] != 2 || unary.GetCodes()["Internal"] != 1 {
+		t.Fatalf("unexpected ChatCompletion stats: %v", unary)
+	}
+	if s := snap.GetRpcs()["ChatCompletionStream"]; s.GetRequests() != 1 || s.GetSuccesses() != 1 {
+		t.Fatalf("unexpected ChatCompletionStream stats: %v", s)
+	}
+	if s := snap.GetRpcs()["POST /v1/chat/completions"]; s.GetRequests() != 1 || s.GetCodes()["200"] != 1 {
+		t.Fatalf("unexpected SSE stats: %v", s)
+	}
+	if _, ok := snap.GetRpcs()["GetStats"]; ok {
+		t.Fatalf("GetStats should not count itself: %v", snap.GetRpcs())
+	}
+	if snap.GetInjectedErrors() != 1 {
+		t.Fatalf("expected 1 injected error, got %d", snap.GetInjectedErrors())
+	}
+	if snap.GetTotalTokens() != tokens {
+		t.Fatalf("expected %d tokens, got %d", tokens, snap.GetTotalTokens())
+	}
+	if snap.GetLatencySamples() != 5 || snap.GetP50Ms() > snap.GetP90Ms() || snap.GetP90Ms() > snap.GetP99Ms() {
+		t.Fatalf("unexpected latency: samples=%d p50=%v p90=%v p99=%v",
+			snap.GetLatencySamples(), snap.GetP50Ms(), snap.GetP90Ms(), snap.GetP99Ms())
+	}
+
+	// The reset above zeroed everything.
+	rr = httptest.NewRecorder()
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var after stats.Snapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &after); err != nil {
+		t.Fatalf("decode /stats: %v", err)
+	}
+	if len(after.RPCs) != 0 || after.InjectedErrors != 0 || after.TotalTokens != 0 || after.Latency.Samples != 0 {
+		t.Fatalf("expected zeroed stats after reset, got %+v", after)
+	}
+}
+
+// TestStatsHTTPReset checks GET /stats never resets and POST /stats/reset takes an API key.
+func TestStatsHTTPReset(t *testing.T) {
+	stats.Default.Snapshot(true)
+	stats.Default.Tokens(7)
+	mux := NewHTTPMux(config.Config{APIKeys: []string{"k1"}}, nil, nil, nil)
+	serve := func(method, target, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/stats?reset=true", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("GET /stats?reset=true: expected 400, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/stats/reset", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /stats/reset without a key: expected 401, got %d", rr.Code)
+	}
+	if n := stats.Default.Snapshot(false).TotalTokens; n != 7 {
+		t.Fatalf("stats reset by a rejected request: %d tokens", n)
+	}
+	rr := serve(http.MethodPost, "/stats/reset", "k1")
+	var snap stats.Snapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil || rr.Code != http.StatusOK || snap.TotalTokens != 7 {
+		t.Fatalf("POST /stats/reset: %d %s", rr.Code, rr.Body.String())
+	}
+	if n := stats.Default.Snapshot(false).TotalTokens; n != 0 {
+		t.Fatalf("expected zeroed stats after POST /stats/reset, got %d tokens", n)
+	}
+}
+
+func TestUsageByModelAndKey(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	client := startTestServer(t, cfg)
+	if _, err := client.GetStats(context.Background(), &llmv1.GetStatsRequest{ResetCounters: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	type usage struct{ requests, prompt, completion int64 }
+	want := map[string]map[string]*usage{}
+	add := func(model, key string, pt, ct int32) {
+		if want[model] == nil {
+			want[model] = map[string]*usage{}
+		}
+		u := want[model][stats.KeyHash(key)]
+		if u == nil {
+			u = &usage{}
+			want[model][stats.KeyHash(key)] = u
+		}
+		u.requests++
+		u.prompt += int64(pt)
+		u.completion += int64(ct)
+	}
+
+	for _, c := range []struct{ model, key string }{{"alpha", "k1"}, {"alpha", "k1"}, {"alpha", "k2"}} {
+		resp, err := client.ChatCompletion(withKey(c.key), &llmv1.ChatCompletionRequest{Model: c.model, UserPrompt: "bill me", MaxTokens: 16})
+		if err != nil {
+			t.Fatalf("unary failed: %v", err)
+		}
+		add(c.model, c.key, resp.GetPromptTokens(), resp.GetCompletionTokens())
+	}
+	stream, err := client.ChatCompletionStream(withKey("k1"), &llmv1.ChatCompletionRequest{Model: "beta", UserPrompt: "bill me", MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		ch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		if ch.GetType() == string(events.OutputTextDone) {
+			add("beta", "k1", ch.GetPromptTokens(), ch.GetCompletionTokens())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"beta","max_tokens":16,"messages":[{"role":"user","content":"bill me"}]}`))
+	req.Header.Set("Authorization", "Bearer k2")
+	NewHTTPMux(cfg, nil, nil, nil).ServeHTTP(rr, req)
+	var chat mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &chat); err != nil {
+		t.Fatalf("decode chat response: %v (%s)", err, rr.Body.String())
+	}
+	add("beta", "k2", int32(chat.Usage.PromptTokens), int32(chat.Usage.CompletionTokens))
+
+	snap, err := client.GetStats(context.Background(), &llmv1.GetStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if len(snap.GetUsage()) != len(want) {
+		t.Fatalf("expected models %v, got %v", want, snap.GetUsage())
+	}
+	for model, keys := range want {
+		got := snap.GetUsage()[model].GetKeys()
+		if len(got) != len(keys) {
+			t.Fatalf("%s: expected %d keys, got %v", model, len(keys), got)
+		}
+		for hash, u := range keys {
+			g := got[hash]
+			if g.GetRequests() != u.requests || g.GetPromptTokens() != u.prompt || g.GetCompletionTokens() != u.completion {
+				t.Fatalf("%s/%s: expected %+v, got %v", model, hash, *u, g)
+			}
+		}
+	}
+	for _, keys := range snap.GetUsage() {
+		for hash := range keys.GetKeys() {
+			if strings.Contains(hash, "k1") || strings.Contains(hash, "k2") {
+				t.Fatalf("key leaked into stats: %q", hash)
+			}
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.UsageCompletionTokens.WithLabelValues("beta", stats.KeyHash("k2"))); got < float64(chat.Usage.CompletionTokens) {
+		t.Fatalf("expected the Prometheus counter to include %d completion tokens, got %v", chat.Usage.CompletionTokens, got)
+	}
+}
@@ -6,23 +6,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
 	"github.com/yungtweek/llm-simulator/internal/mock"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/status"
 )
 
 // ChatCompletionSSEHandler exposes an HTTP handler that streams chat-style SSE responses using the same
-// behavior as the gRPC mock. Query params:
+// behavior as the gRPC mock.
+//
+// POST accepts a mock.ChatRequest JSON body (OpenAI chat.completions shape): the prompt is built from
+// the messages the same way buildPromptForTokens does, "stream" selects SSE vs a single JSON body, and
+// the optional "mock" block overrides config for that request.
+//
+// GET is kept for quick curl tests. Query params:
 // - prompt: required (text to echo/generate from)
 // - model: optional model name (default "mock-sse")
 // - max_tokens: optional, defaults to cfg.DefaultTokens
 // - chunk_size: optional, defaults to cfg.ChunkSize
 //
-// NOTE: This project currently does not mount an HTTP server; to use SSE in production or demos,
-// wire this handler into your own http.Server (TODO: add first-class HTTP entrypoint if needed).
+// Streams are SSE unless the request asks for newline-delimited JSON with ?stream_format=ndjson or
+// "Accept: application/x-ndjson": the same chunks, one per line, the last one carrying the usage.
+//
+// It is mounted at /v1/chat/completions by NewHTTPMux when HTTP_ENABLED is set.
 func ChatCompletionSSEHandler(cfg config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			serveChatCompletionPost(w, r, cfg)
+			return
+		}
+
 		q := r.URL.Query()
 
 		model := q.Get("model")
@@ -43,143 +64,479 @@ func ChatCompletionSSEHandler(cfg config.Config) http.HandlerFunc {
 			}
 		}
 
-		chunkSize := cfg.ChunkSize
+		chunkSize := streamChunkSize(cfg)
 		if v := q.Get("chunk_size"); v != "" {
 			if n, err := strconv.Atoi(v); err == nil {
 				chunkSize = n
+				if d := requestDebug(r.Context()); d != nil {
+					d.Overrides = append(d.Overrides, overrideRequest)
+				}
 			}
 		}
 
-		serveChatCompletionSSE(w, r, model, prompt, maxTokens, cfg, chunkSize)
+		setRequestModel(r.Context(), model)
+		serveChatCompletionStream(w, r, chatStreamFormatFor(r, cfg), model, rawPrompt(cfg, prompt), maxTokens, cfg, chunkSize, nil)
 	}
 }
 
-func serveChatCompletionSSE(w http.ResponseWriter, r *http.Request, model, prompt string, maxTokens int, cfg config.Config, chunkSize int) {
+// serveChatCompletionPost handles a POSTed chat.completions body.
+func serveChatCompletionPost(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	var req mock.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "messages is required")
+		return
+	}
+
+	if !overridesAllowed(cfg) {
+		req.Mock = nil
+	}
+	// The x-mock-* headers, already in cfg, win over the body's mock block.
+	cfg = applyOverrides(applyOverrides(cfg, req.Mock), requestOverrides(r.Context()))
+	dbg := requestDebug(r.Context())
+	if dbg != nil && req.Mock != nil {
+		dbg.Overrides = append(dbg.Overrides, overrideRequest)
+		dbg.BaseDelayMs = cfg.BaseDelayMs
+	}
+	model := req.Model
+	if model == "" {
+		model = "mock-sse"
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = cfg.DefaultTokens
+	}
+	setRequestModel(r.Context(), model)
+	preq := chatRequestToProto(req)
+	if err := checkSampling(cfg, preq); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, status.Convert(err).Message())
+		return
+	}
+	if err := checkMessages(cfg, preq); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, status.Convert(err).Message())
+		return
+	}
+	prompt := newChatPrompt(cfg, preq)
+
+	if !req.Stream {
+		requestLog(r.Context(), logger.Sample(cfg.LogSampleRate)).Debugw("[http][ChatCompletion] start", "model", model, "maxTokens", maxTokens, "priority", requestPriority(r.Context()))
+		if code := injectHTTPError(cfg); code != 0 {
+			requestLog(r.Context(), logger.Log).Infow("[http][ChatCompletion] injected error", "mode", cfg.ErrorMode, "status", code)
+			e := openAIError(code, "mock error")
+			e.Error.Injected = true
+			writeJSON(w, code, e)
+			return
+		}
+		start := time.Now()
+		ctx, cancel := withMaxDuration(r.Context(), cfg, start)
+		defer cancel()
+		recordTokens(dbg, maxTokens, maxTokens)
+		content := buildOutput(cfg, prompt.text, maxTokens)
+		pt, ct := prompt.tokens, mock.ApproxTokens(content)
+		svc := requestService(ctx, cfg)
+		svc.debug = dbg
+		share := svc.simulateUnary(ctx, ct)
+		if r.Context().Err() != nil {
+			return
+		}
+		// Cut by MAX_REQUEST_DURATION_MS: the response holds what was generated by then.
+		finish := events.FinishStop
+		if capped(ctx) {
+			content = truncateOutput(content, share)
+			ct = mock.ApproxTokens(content)
+			finish = cappedFinishReason(cfg)
+		}
+		setUsageHeaders(w.Header(), pt, ct)
+		w.Header().Set(latencyMsKey, strconv.FormatInt(time.Since(start).Milliseconds(), 10))
+		resp := buildChatResponse("chatcmpl_mock_"+mock.RandID(), model, content, pt, ct, req.Sampling)
+		resp.Choices[0].FinishReason = string(finish)
+		resp.Debug = dbg
+		resp.ClientRequestID = requestClientID(r.Context())
+		writeJSON(w, http.StatusOK, resp)
+		observeOutputTokens(r.Pattern, ct)
+		reportUsage(r.Context(), pt, ct)
+		return
+	}
+
+	serveChatCompletionStream(w, r, chatStreamFormatFor(r, cfg), model, prompt, maxTokens, cfg, streamChunkSize(cfg), samplingEcho(req.Sampling))
+}
+
+// injectHTTPError rolls error injection for an HTTP request and returns the status to fail with,
+// or 0 when the request should succeed.
+func injectHTTPError(cfg config.Config) int {
+	if !mock.Chance(cfg.ErrorRate) {
+		return 0
+	}
+	code := mock.PickErrorStatus(cfg.ErrorMode)
+	countInjectedHTTPError(cfg.ErrorMode, code)
+	return code
+}
+
+// pickMidStream reports whether an injected stream error should happen after some deltas
+// rather than before the stream starts.
+func pickMidStream(timing string) bool {
+	switch timing {
+	case "mid":
+		return true
+	case "mixed":
+		return mock.RandIntn(2) == 0
+	default:
+		return false
+	}
+}
+
+// applyOverrides returns cfg with the per-request mock overrides applied.
+func applyOverrides(cfg config.Config, o *mock.Overrides) config.Config {
+	if o == nil {
+		return cfg
+	}
+	if o.BaseDelayMs != nil {
+		cfg.BaseDelayMs = *o.BaseDelayMs
+	}
+	if o.JitterMs != nil {
+		cfg.JitterMs = *o.JitterMs
+	}
+	if o.PerTokenDelayMs != nil {
+		cfg.PerTokenDelayMs = *o.PerTokenDelayMs
+	}
+	if o.ErrorRate != nil {
+		cfg.ErrorRate = *o.ErrorRate
+	}
+	if o.ErrorMode != nil {
+		cfg.ErrorMode = *o.ErrorMode
+	}
+	if o.ErrorTiming != nil {
+		cfg.ErrorTiming = *o.ErrorTiming
+	}
+	if o.ChunkSize != nil {
+		cfg.ChunkSize = o.ChunkSize
+	}
+	if o.TTFTMinMs != nil {
+		cfg.TTFTMinMs = o.TTFTMinMs
+	}
+	if o.TTFTMaxMs != nil {
+		cfg.TTFTMaxMs = o.TTFTMaxMs
+	}
+	if o.TokensPerSec != nil {
+		cfg.TokensPerSec = o.TokensPerSec
+	}
+	return cfg
+}
+
+// serveChatCompletionSSE streams a chat completion as SSE. sampling, when set, is echoed on the final
+// chunk.
+func serveChatCompletionSSE(w http.ResponseWriter, r *http.Request, model string, prompt chatPrompt, maxTokens int, cfg config.Config, chunkSize int, sampling *mock.Sampling) {
+	serveChatCompletionStream(w, r, sseFormat(cfg), model, prompt, maxTokens, cfg, chunkSize, sampling)
+}
+
+// serveChatCompletionStream streams a chat completion in format. The events come from a
+// chatStream, so output, pacing and error injection don't depend on the framing.
+func serveChatCompletionStream(w http.ResponseWriter, r *http.Request, format chatStreamFormat, model string, prompt chatPrompt, maxTokens int, cfg config.Config, chunkSize int, sampling *mock.Sampling) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 
-	// SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	requestLog(r.Context(), logger.Sample(cfg.LogSampleRate)).Debugw("[http][ChatCompletionSSE] start", "model", model, "maxTokens", maxTokens, "format", format.name, "priority", requestPriority(r.Context()))
 
-	id := "chatcmpl_mock_" + mock.RandID()
-	created := time.Now().Unix()
+	// Error injection: either before the stream starts (plain HTTP error) or after some deltas.
+	errCode := injectHTTPError(cfg)
+	midStream := errCode != 0 && pickMidStream(cfg.ErrorTiming)
+	if errCode != 0 && !midStream {
+		requestLog(r.Context(), logger.Log).Infow("[http][ChatCompletionSSE] injected error", "mode", cfg.ErrorMode, "status", errCode)
+		e := openAIError(errCode, "mock error")
+		e.Error.Injected = true
+		writeJSON(w, errCode, e)
+		return
+	}
+	if !midStream {
+		errCode = 0
+	}
 
-	chunkSize = defaultInt(chunkSize, defaultInt(cfg.ChunkSize, 12))
-	if cfg.Randomize && chunkSize > 1 {
-		j := chunkSize / 3
-		if j < 1 {
-			j = 1
-		}
-		chunkSize = (chunkSize - j) + mock.RandIntn(j*2+1)
-		if chunkSize < 1 {
-			chunkSize = 1
-		}
+	// Output length and chunk size are drawn in the same order as the gRPC stream.
+	dbg := requestDebug(r.Context())
+	target := maxTokens
+	if cfg.Randomize {
+		target = mock.PickTargetTokens(maxTokens, len([]rune(prompt.text)))
 	}
+	recordTokens(dbg, maxTokens, target)
+	maxTokens = target
+	chunkSize = sseChunkSize(cfg, chunkSize)
+	recordChunkSize(dbg, chunkSize)
+	gen := newOutputStream(cfg, prompt.text, maxTokens)
+	pt, ct := prompt.tokens, gen.Tokens()
 
-	content := mock.BuildOutput(prompt, maxTokens, cfg.EchoPrompt, cfg.StrictTokenMode, cfg.DebugOutputChars, cfg.MaxOutputChars)
-	bw := bufio.NewWriter(w)
-
-	// First chunk: role
-	first := mock.StreamChunk{
-		ID:      id,
-		Object:  "chat.completion.chunk",
-		Created: created,
-		Model:   model,
-	}
-	firstChoice := struct {
-		Index int `json:"index"`
-		Delta struct {
-			Content string `json:"content,omitempty"`
-			Role    string `json:"role,omitempty"`
-		} `json:"delta"`
-		FinishReason *string `json:"finish_reason"`
-	}{Index: 0}
-	firstChoice.Delta.Role = "assistant"
-	first.Choices = append(first.Choices, firstChoice)
-
-	if err := writeSSE(bw, first); err != nil {
-		return
+	// HTTP_RESET_RATE: the connection is dropped after the role chunk and some deltas, before the
+	// done event, like a TCP connection dying mid-body. The client's read fails without a final event.
+	resetAfter := -1
+	if mock.Chance(cfg.HTTPResetRate) {
+		resetAfter = 1 + mock.RandIntn((gen.Len()+chunkSize-1)/chunkSize)
 	}
-	if err := bw.Flush(); err != nil {
+
+	// Simulated KV cache, as on the gRPC stream: running out fails the request with 429, or the
+	// stream with an error event once it has started.
+	kv := newKVCache(cfg)
+	defer kv.release()
+	if err := kv.grow(pt); err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, status.Convert(err).Message())
 		return
 	}
+
+	// Stream headers. Usage and latency go in trailers when the client takes them; otherwise the
+	// usage is known up front and sent as headers, unless the stream is going to fail or be reset,
+	// or MAX_REQUEST_DURATION_MS may cut it.
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	trailers := acceptsTrailers(r)
+	if trailers {
+		w.Header().Set("Trailer", strings.Join([]string{usagePromptTokensKey, usageCompletionTokensKey, latencyMsKey}, ", "))
+	} else if !midStream && resetAfter < 0 && cfg.MaxRequestDurationMs <= 0 {
+		setUsageHeaders(w.Header(), pt, ct)
+	}
+
+	w.WriteHeader(http.StatusOK)
 	flusher.Flush()
 
-	// Content chunks
-	for i := 0; i < len(content); i += chunkSize {
-		select {
-		case <-r.Context().Done():
-			return
-		default:
+	start := time.Now()
+	var keepalive time.Duration
+	if format.keepalive {
+		keepalive = time.Duration(cfg.SSEKeepaliveMs) * time.Millisecond
+	}
+	out := newSSEWriter(r.Context(), w, flusher, keepalive)
+	defer out.stop()
+	// The cap ends the events, not the writes: the done event still goes out after it.
+	ctx, cancel := withMaxDuration(r.Context(), cfg, start)
+	defer cancel()
+
+	cs := &chatStream{
+		cfg:       cfg,
+		pattern:   r.Pattern,
+		id:        "chatcmpl_mock_" + mock.RandID(),
+		model:     model,
+		created:   start.Unix(),
+		start:     start,
+		gen:       gen,
+		chunkSize: chunkSize,
+		kv:        kv,
+		errCode:   errCode,
+		pt:        pt,
+		ct:        ct,
+		sampling:  sampling,
+		usage:     format.usage,
+		debug:     dbg,
+		clientID:  requestClientID(r.Context()),
+	}
+
+	// A client that goes away mid-stream gets a line with what it was sent.
+	var counters sendCounters
+	done, completed := false, false
+	defer func() {
+		if !completed && (out.broken() || r.Context().Err() != nil) {
+			requestLog(r.Context(), logger.Log).Debugw("[http][ChatCompletionSSE] client disconnected", counters.fields()...)
 		}
+	}()
 
-		end := i + chunkSize
-		if end > len(content) {
-			end = len(content)
+	for ev := range cs.events(ctx) {
+		if ev.kind == chatEventDone {
+			// No keepalive ping may follow the final event.
+			out.stop()
+			done = true
 		}
-		part := content[i:end]
+		if !out.send(func(bw *bufio.Writer) error { return format.write(bw, ev) }) {
+			break
+		}
+		switch ev.kind {
+		case chatEventRole, chatEventDelta:
+			counters.add(ev.chunk.Choices[0].Delta.Content)
+			if counters.chunks == int64(resetAfter) {
+				requestLog(r.Context(), logger.Log).Infow("[http][ChatCompletionSSE] injected connection reset", counters.fields()...)
+				countInjectedReset()
+				// The server closes the connection without ending the body (recoverHTTP lets it through).
+				panic(http.ErrAbortHandler)
+			}
+		case chatEventDone:
+			completed = true
+		}
+	}
+	if !done {
+		return
+	}
+	if trailers {
+		setUsageHeaders(w.Header(), pt, cs.ct)
+		w.Header().Set(latencyMsKey, strconv.FormatInt(time.Since(start).Milliseconds(), 10))
+	}
+	observeOutputTokens(r.Pattern, cs.ct)
+	reportUsage(r.Context(), pt, cs.ct)
+}
 
-		ch := mock.StreamChunk{
-			ID:      id,
-			Object:  "chat.completion.chunk",
-			Created: created,
-			Model:   model,
+// acceptsTrailers reports whether the client advertised trailer support with "TE: trailers".
+func acceptsTrailers(r *http.Request) bool {
+	for _, v := range r.Header.Values("TE") {
+		for _, te := range strings.Split(v, ",") {
+			te, _, _ = strings.Cut(te, ";")
+			if strings.EqualFold(strings.TrimSpace(te), "trailers") {
+				return true
+			}
 		}
-		choice := struct {
-			Index int `json:"index"`
-			Delta struct {
-				Content string `json:"content,omitempty"`
-				Role    string `json:"role,omitempty"`
-			} `json:"delta"`
-			FinishReason *string `json:"finish_reason"`
-		}{Index: 0}
-		choice.Delta.Content = part
-		ch.Choices = append(ch.Choices, choice)
-
-		if err := writeSSE(bw, ch); err != nil {
+	}
+	return false
+}
+
+// setUsageHeaders sets the usage keys of usageTrailer on h, as headers or (once declared) trailers.
+func setUsageHeaders(h http.Header, promptTokens, completionTokens int) {
+	h.Set(usagePromptTokensKey, strconv.Itoa(promptTokens))
+	h.Set(usageCompletionTokensKey, strconv.Itoa(completionTokens))
+}
+
+// sseWriter serializes writes to an SSE response and, when keepalive is set, writes `: ping` comment
+// lines whenever no event has been written for that long. Comments are ignored by SSE consumers, so
+// they only keep proxies and clients from timing out idle connections during long gaps.
+type sseWriter struct {
+	mu      sync.Mutex
+	bw      *bufio.Writer
+	flusher http.Flusher
+	last    time.Time
+	failed  bool // a send failed: the client is gone
+
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSSEWriter(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, keepalive time.Duration) *sseWriter {
+	s := &sseWriter{
+		bw:      bufio.NewWriter(w),
+		flusher: flusher,
+		last:    time.Now(),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if keepalive <= 0 {
+		close(s.done)
+		return s
+	}
+	go s.keepalive(ctx, keepalive)
+	return s
+}
+
+func (s *sseWriter) keepalive(ctx context.Context, interval time.Duration) {
+	defer close(s.done)
+	t := time.NewTimer(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
 			return
-		}
-		if err := bw.Flush(); err != nil {
+		case <-s.quit:
 			return
+		case <-t.C:
 		}
-		flusher.Flush()
-
-		sleepSSEStreamGap(r.Context(), cfg, part)
-	}
-
-	// Done
-	doneReason := "stop"
-	last := mock.StreamChunk{
-		ID:      id,
-		Object:  "chat.completion.chunk",
-		Created: created,
-		Model:   model,
-	}
-	lastChoice := struct {
-		Index int `json:"index"`
-		Delta struct {
-			Content string `json:"content,omitempty"`
-			Role    string `json:"role,omitempty"`
-		} `json:"delta"`
-		FinishReason *string `json:"finish_reason"`
-	}{Index: 0, FinishReason: &doneReason}
-	last.Choices = append(last.Choices, lastChoice)
-
-	if err := writeSSE(bw, last); err != nil {
-		return
+
+		s.mu.Lock()
+		idle := time.Since(s.last)
+		if idle >= interval {
+			if _, err := fmt.Fprint(s.bw, ": ping\n\n"); err == nil && s.bw.Flush() == nil {
+				s.flusher.Flush()
+			}
+			s.last = time.Now()
+			idle = 0
+		}
+		s.mu.Unlock()
+		t.Reset(interval - idle)
 	}
-	if _, err := fmt.Fprint(bw, "data: [DONE]\n\n"); err != nil {
-		return
+}
+
+// send runs write against the buffered writer and flushes it to the client.
+func (s *sseWriter) send(write func(bw *bufio.Writer) error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := write(s.bw); err != nil {
+		s.failed = true
+		return false
 	}
-	if err := bw.Flush(); err != nil {
-		return
+	if err := s.bw.Flush(); err != nil {
+		s.failed = true
+		return false
 	}
-	flusher.Flush()
+	s.flusher.Flush()
+	s.last = time.Now()
+	return true
+}
+
+// broken reports whether a send failed.
+func (s *sseWriter) broken() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// stop ends keepalive pings and waits for the ping goroutine to exit. It is safe to call more than once.
+func (s *sseWriter) stop() {
+	s.stopOnce.Do(func() { close(s.quit) })
+	<-s.done
+}
+
+// chatRequestToProto maps an OpenAI-style chat request onto the gRPC request shape so prompt
+// construction matches buildPromptForTokens. A trailing user message becomes the user prompt;
+// every earlier message (system messages included) is rendered as context with its role marker.
+func chatRequestToProto(req mock.ChatRequest) *llmv1.ChatCompletionRequest {
+	out := &llmv1.ChatCompletionRequest{
+		Model:            req.Model,
+		MaxTokens:        int32(req.MaxTokens),
+		Temperature:      config.Or(req.Temperature, 0),
+		TopP:             config.Or(req.TopP, 0),
+		PresencePenalty:  config.Or(req.PresencePenalty, 0),
+		FrequencyPenalty: config.Or(req.FrequencyPenalty, 0),
+	}
+	msgs := req.Messages
+	if n := len(msgs); n > 0 && msgs[n-1].Role == "user" {
+		out.UserPrompt = msgs[n-1].Content
+		msgs = msgs[:n-1]
+	}
+	for _, m := range msgs {
+		out.Context = append(out.Context, &llmv1.ChatMessage{Role: m.Role, Content: m.Content})
+	}
+	return out
+}
+
+// buildChatResponse assembles a non-streaming chat.completion body, echoing the sampling params sent.
+func buildChatResponse(id, model, content string, pt, ct int, sampling mock.Sampling) mock.ChatResponse {
+	resp := mock.ChatResponse{
+		ID:       id,
+		Object:   "chat.completion",
+		Created:  time.Now().Unix(),
+		Model:    model,
+		Sampling: samplingEcho(sampling),
+	}
+	resp.Choices = make([]struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Role = "assistant"
+	resp.Choices[0].Message.Content = content
+	resp.Choices[0].FinishReason = string(events.FinishStop)
+	resp.Usage.PromptTokens = pt
+	resp.Usage.CompletionTokens = ct
+	resp.Usage.TotalTokens = pt + ct
+	return resp
+}
+
+// samplingEcho returns the sampling block of a response to a request with sampling params s: nil when
+// the request sent none.
+func samplingEcho(s mock.Sampling) *mock.Sampling {
+	if s == (mock.Sampling{}) {
+		return nil
+	}
+	return &s
 }
 
 func writeSSE(w *bufio.Writer, v any) error {
@@ -193,42 +550,59 @@ func writeSSE(w *bufio.Writer, v any) error {
 	return nil
 }
 
-// sleepSSEStreamGap applies the same stream pacing knobs used by the gRPC stream path.
-func sleepSSEStreamGap(ctx context.Context, cfg config.Config, delta string) {
-	ms := 0
+// sseEventNames are the event names of the chunks of an SSE chat stream; "" writes a plain data line.
+type sseEventNames struct {
+	role, delta, done, stop, failed events.Type
+}
 
-	min := defaultInt(cfg.StreamDelayMinMs, 0)
-	max := defaultInt(cfg.StreamDelayMaxMs, 0)
-	if max > 0 {
-		if max < min {
-			max = min
-		}
-		ms += min
-		if max > min {
-			ms += mock.RandIntn(max - min + 1)
-		}
+// sseNames returns the SSE event names for cfg.EventNaming. The stream stays in the Chat Completions
+// format; only the anthropic naming adds event lines, since Anthropic names every SSE event.
+func sseNames(cfg config.Config) sseEventNames {
+	if events.Naming(cfg.EventNaming) != events.Anthropic {
+		return sseEventNames{}
 	}
+	t := events.For(events.Anthropic)
+	return sseEventNames{role: events.MessageStart, delta: t.Delta, done: t.Done, stop: t.Stop, failed: t.Failed}
+}
 
-	if tps := defaultInt(cfg.TokensPerSec, 0); tps > 0 {
-		toks := mock.ApproxTokens(delta)
-		if toks < 1 {
-			toks = 1
-		}
-		msPerTok := 1000 / tps
-		if msPerTok < 1 {
-			msPerTok = 1
-		}
-		ms += toks * msPerTok
+// writeSSENamed writes v as an SSE event named name, or as a plain data line when name is "".
+func writeSSENamed(w *bufio.Writer, name events.Type, v any) error {
+	if name == "" {
+		return writeSSE(w, v)
+	}
+	return writeSSEEvent(w, string(name), v)
+}
+
+// writeNDJSON writes v as a single line of newline-delimited JSON.
+func writeNDJSON(w *bufio.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
+	if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+		return err
+	}
+	return nil
+}
 
-	per := defaultInt(cfg.PerTokenDelayMs, 0)
-	if per > 0 {
-		toks := mock.ApproxTokens(delta)
-		if toks < 1 {
-			toks = 1
-		}
-		ms += per * toks
+// writeSSEEvent writes a named SSE event (an `event:` line followed by the JSON `data:` line).
+func writeSSEEvent(w *bufio.Writer, event string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	return nil
+}
 
-	sleepWithContext(ctx, time.Duration(ms)*time.Millisecond)
+// sseChunkSize resolves the chunk size for an HTTP stream (requested > cfg > 12),
+// applying the same +/- 33% jitter as the gRPC stream when Randomize is on.
+func sseChunkSize(cfg config.Config, chunkSize int) int {
+	chunkSize = defaultInt(chunkSize, streamChunkSize(cfg))
+	if cfg.Randomize {
+		chunkSize = mock.JitterChunkSize(chunkSize)
+	}
+	return chunkSize
 }
@@ -1,32 +1,49 @@
 package grpc
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"go.uber.org/zap/zapcore"
+
+	"github.com/yungtweek/llm-simulator/events"
 	"github.com/yungtweek/llm-simulator/internal/config"
 	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
 )
 
 func TestStreamSSEAlignsWithGrpcOutput(t *testing.T) {
 	cfg := config.Config{
-		ChunkSize:       7,
-		StrictTokenMode: true,
-		MaxOutputChars:  256,
+		ChunkSize:       config.Int(7),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
 		// Keep randomization off so chunking is deterministic.
 	}
 
 	prompt := "sse prompt"
 	maxTokens := 10
-	expected := mock.BuildOutput(prompt, maxTokens, cfg.EchoPrompt, cfg.StrictTokenMode, cfg.DebugOutputChars, cfg.MaxOutputChars)
-	expectedChunks := (len(expected) + cfg.ChunkSize - 1) / cfg.ChunkSize
+	expected := buildOutput(cfg, prompt, maxTokens)
+	expectedChunks := (len(expected) + *cfg.ChunkSize - 1) / *cfg.ChunkSize
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/", nil)
 
-	serveChatCompletionSSE(rr, req, "mock-model", prompt, maxTokens, cfg, cfg.ChunkSize)
+	serveChatCompletionSSE(rr, req, "mock-model", rawPrompt(cfg, prompt), maxTokens, cfg, *cfg.ChunkSize, nil)
 
 	body := strings.TrimSpace(rr.Body.String())
 	if !strings.Contains(rr.Header().Get("Content-Type"), "text/event-stream") {
@@ -44,7 +61,7 @@ func TestStreamSSEAlignsWithGrpcOutput(t *testing.T) {
 
 	// Last chunk should carry finish_reason stop.
 	last := chunks[len(chunks)-1]
-	if len(last.Choices) != 1 || last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != "stop" {
+	if len(last.Choices) != 1 || last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != string(events.FinishStop) {
 		t.Fatalf("final chunk missing finish_reason stop: %+v", last)
 	}
 
@@ -54,8 +71,8 @@ func TestStreamSSEAlignsWithGrpcOutput(t *testing.T) {
 		if delta == "" {
 			t.Fatalf("chunk %d has empty content", i)
 		}
-		if len(delta) > cfg.ChunkSize {
-			t.Fatalf("chunk %d exceeds chunk size: %d > %d", i, len(delta), cfg.ChunkSize)
+		if len(delta) > *cfg.ChunkSize {
+			t.Fatalf("chunk %d exceeds chunk size: %d > %d", i, len(delta), *cfg.ChunkSize)
 		}
 		assembled.WriteString(delta)
 	}
@@ -67,14 +84,27 @@ func TestStreamSSEAlignsWithGrpcOutput(t *testing.T) {
 	if gotChunks := len(chunks) - 2; gotChunks != expectedChunks {
 		t.Fatalf("delta chunk count mismatch: got %d, expected %d", gotChunks, expectedChunks)
 	}
+
+	if last.Timing == nil {
+		t.Fatalf("final chunk missing timing extension")
+	}
+	sum := sha256.Sum256([]byte(assembled.String()))
+	if last.Output == nil || last.Output.SHA256 != hex.EncodeToString(sum[:]) || last.Output.Bytes != assembled.Len() {
+		t.Fatalf("final chunk digest does not match the deltas: %+v", last.Output)
+	}
+	for i := 0; i < len(chunks)-1; i++ {
+		if chunks[i].Timing != nil {
+			t.Fatalf("chunk %d should not carry timing", i)
+		}
+	}
 }
 
 func TestNewSSEHandlerUsesQueryParams(t *testing.T) {
 	cfg := config.Config{
-		ChunkSize:       6,
+		ChunkSize:       config.Int(6),
 		DefaultTokens:   5,
-		StrictTokenMode: true,
-		MaxOutputChars:  200,
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(200),
 	}
 
 	handler := ChatCompletionSSEHandler(cfg)
@@ -97,7 +127,7 @@ func TestNewSSEHandlerUsesQueryParams(t *testing.T) {
 	}
 
 	prompt := "handler prompt"
-	expected := mock.BuildOutput(prompt, 6, cfg.EchoPrompt, cfg.StrictTokenMode, cfg.DebugOutputChars, cfg.MaxOutputChars)
+	expected := buildOutput(cfg, prompt, 6)
 	expectedChunks := (len(expected) + 4 - 1) / 4 // chunk_size override=4
 
 	var assembled strings.Builder
@@ -117,6 +147,391 @@ func TestNewSSEHandlerUsesQueryParams(t *testing.T) {
 	}
 }
 
+func TestSSEPostMultiMessageBody(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(7),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+	}
+
+	body := `{"model":"post-model","stream":true,"max_tokens":12,"messages":[` +
+		`{"role":"system","content":"you are\nhelpful"},` +
+		`{"role":"user","content":"hi"},` +
+		`{"role":"assistant","content":"hello"},` +
+		`{"role":"user","content":"tell me\na joke"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("handler returned non-200: %d body=%s", rr.Code, rr.Body.String())
+	}
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	if chunks[0].Model != "post-model" {
+		t.Fatalf("model not honored: %q", chunks[0].Model)
+	}
+
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{
+		UserPrompt: "tell me\na joke",
+		Context: []*llmv1.ChatMessage{
+			{Role: "system", Content: "you are\nhelpful"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+	})
+	if !strings.HasPrefix(prompt, "[system]\nyou are\nhelpful\n\n") {
+		t.Fatalf("system message not rendered as in buildPromptForTokens: %q", prompt)
+	}
+	expected := buildOutput(cfg, prompt, 12)
+	expectedChunks := (len(expected) + *cfg.ChunkSize - 1) / *cfg.ChunkSize
+
+	var assembled strings.Builder
+	for i := 1; i < len(chunks)-1; i++ {
+		assembled.WriteString(chunks[i].Choices[0].Delta.Content)
+	}
+	if got := assembled.String(); got != expected {
+		t.Fatalf("reassembled content mismatch: len got=%d expected=%d", len(got), len(expected))
+	}
+	if gotChunks := len(chunks) - 2; gotChunks != expectedChunks {
+		t.Fatalf("delta chunk count mismatch: got %d, expected %d", gotChunks, expectedChunks)
+	}
+}
+
+func TestSSEPostOverrides(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(7),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(256),
+	}
+
+	body := `{"stream":true,"max_tokens":10,"messages":[{"role":"user","content":"override me"}],` +
+		`"mock":{"chunk_size":3}}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{UserPrompt: "override me"})
+	expected := buildOutput(cfg, prompt, 10)
+	if gotChunks, want := len(chunks)-2, (len(expected)+2)/3; gotChunks != want {
+		t.Fatalf("chunk_size override not applied: got %d chunks, expected %d", gotChunks, want)
+	}
+	for i := 1; i < len(chunks)-1; i++ {
+		if n := len(chunks[i].Choices[0].Delta.Content); n > 3 {
+			t.Fatalf("chunk %d exceeds overridden size: %d", i, n)
+		}
+	}
+
+	o := &mock.Overrides{}
+	rate, mode := 0.25, "429"
+	o.ErrorRate, o.ErrorMode = &rate, &mode
+	got := applyOverrides(cfg, o)
+	if got.ErrorRate != 0.25 || got.ErrorMode != "429" || got.ChunkSize != cfg.ChunkSize {
+		t.Fatalf("applyOverrides mismatch: %+v", got)
+	}
+}
+
+func TestSSEPostNonStream(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	body := `{"model":"json-model","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v\n%s", err, rr.Body.String())
+	}
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{UserPrompt: "hi"})
+	expected := buildOutput(cfg, prompt, 8)
+	if resp.Model != "json-model" || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != expected {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.PromptTokens != mock.ApproxTokens(prompt) || resp.Usage.CompletionTokens != mock.ApproxTokens(expected) {
+		t.Fatalf("usage mismatch: %+v", resp.Usage)
+	}
+}
+
+// TestSSEPostSampling verifies the sampling params of a POSTed chat request are echoed on the JSON
+// response and the final SSE chunk, only when sent, and rejected with a 400 under STRICT_VALIDATION.
+func TestSSEPostSampling(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), ChunkSize: config.Int(8)}
+	post := func(cfg config.Config, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+		return rr
+	}
+	const msgs = `"max_tokens":8,"messages":[{"role":"user","content":"hi"}]`
+
+	var resp mock.ChatResponse
+	if err := json.Unmarshal(post(cfg, `{"temperature":0.3,"top_p":1,`+msgs+`}`).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response: %v", err)
+	}
+	if s := resp.Sampling; s == nil || config.Or(s.Temperature, -1) != 0.3 || config.Or(s.TopP, -1) != 1 || s.PresencePenalty != nil || s.FrequencyPenalty != nil {
+		t.Fatalf("expected temperature and top_p echoed, got %+v", resp.Sampling)
+	}
+	var plain mock.ChatResponse
+	if err := json.Unmarshal(post(cfg, `{`+msgs+`}`).Body.Bytes(), &plain); err != nil || plain.Sampling != nil {
+		t.Fatalf("expected no sampling block without sampling params, got %+v (%v)", plain.Sampling, err)
+	}
+
+	chunks := parseSSE(t, post(cfg, `{"stream":true,"frequency_penalty":1.5,`+msgs+`}`).Body.String()).chunks
+	if s := chunks[len(chunks)-1].Sampling; s == nil || config.Or(s.FrequencyPenalty, 0) != 1.5 {
+		t.Fatalf("expected frequency_penalty echoed on the final chunk, got %+v", s)
+	}
+	for _, ch := range chunks[:len(chunks)-1] {
+		if ch.Sampling != nil {
+			t.Fatalf("sampling echoed before the final chunk: %+v", ch)
+		}
+	}
+
+	cfg.StrictValidation = true
+	for _, stream := range []string{"false", "true"} {
+		rr := post(cfg, `{"stream":`+stream+`,"top_p":1.2,`+msgs+`}`)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "top_p must be within [0, 1]") {
+			t.Fatalf("stream=%s: expected a 400 naming top_p, got %d %s", stream, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestSSEInjectedErrorPreStream(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		code int
+		typ  string
+	}{
+		{"429", 429, "requests"},
+		{"500", 500, "server_error"},
+	} {
+		cfg := config.Config{ErrorRate: 1, ErrorMode: tc.mode, StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+		req := httptest.NewRequest("GET", "/?prompt=hi&max_tokens=8", nil)
+		rr := httptest.NewRecorder()
+
+		ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+		if rr.Code != tc.code {
+			t.Fatalf("mode %s: expected status %d, got %d", tc.mode, tc.code, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/event-stream") {
+			t.Fatalf("mode %s: error response sent as SSE", tc.mode)
+		}
+		var e mock.ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+			t.Fatalf("mode %s: bad error body: %v\n%s", tc.mode, err, rr.Body.String())
+		}
+		if e.Error.Type != tc.typ || e.Error.Message == "" || !e.Error.Injected {
+			t.Fatalf("mode %s: unexpected error body: %+v", tc.mode, e)
+		}
+	}
+}
+
+func TestSSEInjectedErrorMidStream(t *testing.T) {
+	cfg := config.Config{
+		ErrorRate:       1,
+		ErrorMode:       "500",
+		ErrorTiming:     "mid",
+		ChunkSize:       config.Int(4),
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(128),
+		EchoPrompt:      true, // chunks split the runes of the echoed prompt
+	}
+	req := httptest.NewRequest("GET", "/?prompt=h%C3%A9ll%C3%B6&max_tokens=16", nil)
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("mid-stream failure must keep status 200, got %d", rr.Code)
+	}
+	body := strings.TrimSpace(rr.Body.String())
+	if strings.Contains(body, "[DONE]") {
+		t.Fatalf("mid-stream failure must not send [DONE]\n%s", body)
+	}
+
+	events := strings.Split(body, "\n\n")
+	deltas := 0
+	var text strings.Builder
+	for _, evt := range events[:len(events)-1] {
+		var ch mock.StreamChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(evt, "data: ")), &ch); err != nil {
+			t.Fatalf("bad chunk: %v\n%s", err, evt)
+		}
+		if len(ch.Choices) > 0 && ch.Choices[0].Delta.Content != "" {
+			deltas++
+			text.WriteString(ch.Choices[0].Delta.Content)
+		}
+	}
+	if deltas == 0 {
+		t.Fatalf("expected partial deltas before the error\n%s", body)
+	}
+
+	var e mock.ErrorResponse
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[len(events)-1], "data: ")), &e); err != nil {
+		t.Fatalf("bad error event: %v\n%s", err, body)
+	}
+	if e.Error.Type != "server_error" || !e.Error.Injected {
+		t.Fatalf("unexpected error event: %+v", e)
+	}
+	// The error event carries the usage of what was delivered.
+	pt := promptTokens(cfg, &llmv1.ChatCompletionRequest{UserPrompt: "héllö"})
+	if u := e.Usage; u == nil || u.PromptTokens != pt || u.CompletionTokens != mock.ApproxTokens(text.String()) || u.TotalTokens != pt+u.CompletionTokens || e.LatencyMs < 0 {
+		t.Fatalf("error event usage %+v, want %d prompt and %d completion tokens for %q", e.Usage, pt, mock.ApproxTokens(text.String()), text.String())
+	}
+}
+
+func TestGenuineHTTPErrorsAreNotMarkedInjected(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"messages":`))
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(config.Config{}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), `"injected"`) {
+		t.Fatalf("genuine error carries the injected marker: %s", rr.Body.String())
+	}
+}
+
+func TestSSEPostInjectedErrorOverride(t *testing.T) {
+	cfg := config.Config{StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(128)}
+
+	body := `{"stream":true,"max_tokens":8,"messages":[{"role":"user","content":"hi"}],` +
+		`"mock":{"error_rate":1,"error_mode":"429"}}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != 429 {
+		t.Fatalf("expected 429 from per-request override, got %d\n%s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "data: ") {
+		t.Fatalf("pre-stream failure must not send SSE events\n%s", rr.Body.String())
+	}
+}
+
+func TestSSETTFTDelay(t *testing.T) {
+	const ttft = 80 * time.Millisecond
+
+	for _, roleFirst := range []bool{false, true} {
+		cfg := config.Config{
+			TTFTMinMs:       config.Int(int(ttft / time.Millisecond)),
+			TTFTMaxMs:       config.Int(int(ttft / time.Millisecond)),
+			StrictTokenMode: config.Bool(true),
+			MaxOutputChars:  config.Int(64),
+			SSERoleFirst:    roleFirst,
+		}
+		srv := httptest.NewServer(ChatCompletionSSEHandler(cfg))
+
+		start := time.Now()
+		resp, err := http.Get(srv.URL + "/?prompt=hi&max_tokens=8")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		// Elapsed time to the first data event and to the first content delta.
+		var firstEvent, firstDelta time.Duration
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() && firstDelta == 0 {
+			line := sc.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			if firstEvent == 0 {
+				firstEvent = time.Since(start)
+			}
+			var ch mock.StreamChunk
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ch); err != nil {
+				t.Fatalf("bad chunk: %v\n%s", err, line)
+			}
+			if len(ch.Choices) > 0 && ch.Choices[0].Delta.Content != "" {
+				firstDelta = time.Since(start)
+			}
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		if firstDelta < ttft {
+			t.Fatalf("roleFirst=%v: first delta after %v, expected at least %v", roleFirst, firstDelta, ttft)
+		}
+		if !roleFirst && firstEvent < ttft {
+			t.Fatalf("role chunk sent after %v, expected at least %v", firstEvent, ttft)
+		}
+		if roleFirst && firstEvent >= ttft {
+			t.Fatalf("SSE_ROLE_FIRST: role chunk delayed %v, expected it before the TTFT delay", firstEvent)
+		}
+	}
+}
+
+func TestSSERandomizedLengthMatchesGrpc(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:       config.Int(9),
+		Randomize:       true,
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(4096),
+	}
+	req := &llmv1.ChatCompletionRequest{UserPrompt: "randomized prompt", MaxTokens: 200}
+
+	for _, seed := range []int64{1, 7, 42} {
+		mock.Seed(seed)
+		fs := llmtest.NewServerStream(context.Background())
+		if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+			t.Fatalf("grpc stream failed: %v", err)
+		}
+		var grpcDeltas []string
+		for _, ch := range fs.Chunks() {
+			if ch.GetType() == string(events.OutputTextDelta) {
+				grpcDeltas = append(grpcDeltas, ch.GetText())
+			}
+		}
+
+		mock.Seed(seed)
+		rr := httptest.NewRecorder()
+		serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", newChatPrompt(cfg, req), int(req.MaxTokens), cfg, 0, nil)
+		chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+		var sseDeltas []string
+		for _, ch := range chunks[1 : len(chunks)-1] {
+			sseDeltas = append(sseDeltas, ch.Choices[0].Delta.Content)
+		}
+
+		if strings.Join(sseDeltas, "|") != strings.Join(grpcDeltas, "|") {
+			t.Fatalf("seed %d: SSE and gRPC diverged\nsse:  %d chunks\ngrpc: %d chunks", seed, len(sseDeltas), len(grpcDeltas))
+		}
+	}
+}
+
+func TestSSEKeepaliveDuringPreDelay(t *testing.T) {
+	cfg := config.Config{
+		TTFTMinMs:       config.Int(150),
+		TTFTMaxMs:       config.Int(150),
+		SSEKeepaliveMs:  30,
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(64),
+	}
+	req := httptest.NewRequest("GET", "/?prompt=hi&max_tokens=8", nil)
+	rr := httptest.NewRecorder()
+
+	ChatCompletionSSEHandler(cfg).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	ping, data := strings.Index(body, ": ping\n\n"), strings.Index(body, "data: ")
+	if ping < 0 || data < 0 || ping > data {
+		t.Fatalf("expected a keepalive comment before the first data event\n%s", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("keepalive must not be written after [DONE]\n%s", body)
+	}
+	// Comments are ignored by SSE consumers.
+	if chunks := parseSSE(t, strings.TrimSpace(body)).chunks; len(chunks) < 3 {
+		t.Fatalf("unexpected chunks: %d", len(chunks))
+	}
+}
+
 // parseSSE extracts chunks and verifies presence of [DONE].
 func parseSSE(t *testing.T, body string) (result struct {
 	chunks []mock.StreamChunk
@@ -131,6 +546,9 @@ func parseSSE(t *testing.T, body string) (result struct {
 
 	for _, evt := range rawEvents {
 		evt = strings.TrimSpace(evt)
+		if strings.HasPrefix(evt, "event: ") { // named event (EVENT_NAMING=anthropic)
+			_, evt, _ = strings.Cut(evt, "\n")
+		}
 		if !strings.HasPrefix(evt, "data: ") {
 			continue
 		}
@@ -152,3 +570,204 @@ func parseSSE(t *testing.T, body string) (result struct {
 	}
 	return result
 }
+
+// TestStreamSSEChunkTimestamps verifies CHUNK_TIMESTAMPS on the SSE stream: every chunk carries
+// emitted_at_unix_ms in order, and the final chunk the first delta's, spread by the pacing.
+func TestStreamSSEChunkTimestamps(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(10), StreamDelayMinMs: config.Int(20), StreamDelayMaxMs: config.Int(20), StrictTokenMode: config.Bool(true), ChunkTimestamps: true}
+	rr := httptest.NewRecorder()
+	serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "timestamps"), 20, cfg, *cfg.ChunkSize, nil)
+
+	chunks := parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks
+	var prev int64
+	for i, c := range chunks {
+		if c.EmittedAtUnixMs == 0 || c.EmittedAtUnixMs < prev {
+			t.Fatalf("chunk %d: emitted_at_unix_ms %d after %d", i, c.EmittedAtUnixMs, prev)
+		}
+		prev = c.EmittedAtUnixMs
+	}
+	last := chunks[len(chunks)-1]
+	if last.FirstDeltaAtUnixMs != chunks[1].EmittedAtUnixMs {
+		t.Fatalf("final chunk first delta at %d, first delta emitted at %d", last.FirstDeltaAtUnixMs, chunks[1].EmittedAtUnixMs)
+	}
+	want := int64(len(chunks)-2) * 20
+	if spread := last.EmittedAtUnixMs - last.FirstDeltaAtUnixMs; spread < want || spread > 2*want {
+		t.Fatalf("expected ~%dms from the first delta to the final chunk, got %dms", want, spread)
+	}
+}
+
+// TestHTTPUsageTrailers verifies the usage and latency metadata over a real HTTP round trip: as
+// trailers on a stream when the client sends "TE: trailers", and as headers otherwise.
+func TestHTTPUsageTrailers(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), DefaultTokens: 16}
+	srv := httptest.NewServer(NewHTTPMux(cfg, nil, nil, nil))
+	t.Cleanup(srv.Close)
+
+	post := func(t *testing.T, stream bool, te string) (*http.Response, string) {
+		t.Helper()
+		body := fmt.Sprintf(`{"messages":[{"role":"user","content":"trailers"}],"max_tokens":16,"stream":%t}`, stream)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+		if te != "" {
+			req.Header.Set("TE", te)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body) // trailers are only set once the body is read
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		return resp, string(b)
+	}
+
+	// The non-stream response body reports the usage every variant should carry.
+	resp, body := post(t, false, "")
+	var out mock.ChatResponse
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	pt, ct := strconv.Itoa(out.Usage.PromptTokens), strconv.Itoa(out.Usage.CompletionTokens)
+	if resp.Header.Get("X-Usage-Prompt-Tokens") != pt || resp.Header.Get("X-Usage-Completion-Tokens") != ct {
+		t.Fatalf("expected usage %s/%s in headers, got %v", pt, ct, resp.Header)
+	}
+	if _, err := strconv.Atoi(resp.Header.Get("X-Latency-Ms")); err != nil {
+		t.Fatalf("missing latency header: %v", resp.Header)
+	}
+
+	t.Run("stream trailers", func(t *testing.T) {
+		resp, body := post(t, true, "trailers")
+		if !parseSSE(t, strings.TrimSpace(body)).done {
+			t.Fatalf("incomplete SSE stream")
+		}
+		if resp.Header.Get("X-Usage-Completion-Tokens") != "" {
+			t.Fatalf("usage sent as headers as well: %v", resp.Header)
+		}
+		if resp.Trailer.Get("X-Usage-Prompt-Tokens") != pt || resp.Trailer.Get("X-Usage-Completion-Tokens") != ct {
+			t.Fatalf("expected usage %s/%s in trailers, got %v", pt, ct, resp.Trailer)
+		}
+		if _, err := strconv.Atoi(resp.Trailer.Get("X-Latency-Ms")); err != nil {
+			t.Fatalf("missing latency trailer: %v", resp.Trailer)
+		}
+	})
+
+	t.Run("stream headers", func(t *testing.T) {
+		resp, _ := post(t, true, "")
+		if resp.Header.Get("X-Usage-Prompt-Tokens") != pt || resp.Header.Get("X-Usage-Completion-Tokens") != ct {
+			t.Fatalf("expected usage %s/%s in headers, got %v", pt, ct, resp.Header)
+		}
+		if len(resp.Trailer) != 0 {
+			t.Fatalf("unexpected trailers without TE: %v", resp.Trailer)
+		}
+	})
+}
+
+// TestSSEEventNaming verifies the SSE chat stream names its events under EVENT_NAMING=anthropic,
+// ending with message_stop before [DONE], and keeps plain data lines under the other namings.
+func TestSSEEventNaming(t *testing.T) {
+	// eventNames returns the event: names of body, one per event ("" for a plain data line).
+	eventNames := func(body string) (names []events.Type) {
+		for _, evt := range strings.Split(strings.TrimSpace(body), "\n\n") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(evt, "event: "), "\n")
+			if !strings.HasPrefix(evt, "event: ") {
+				name = ""
+			}
+			names = append(names, events.Type(name))
+		}
+		return names
+	}
+	for _, naming := range events.Namings {
+		t.Run(string(naming), func(t *testing.T) {
+			cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), EventNaming: string(naming)}
+			rr := httptest.NewRecorder()
+			serveChatCompletionSSE(rr, httptest.NewRequest("GET", "/", nil), "mock-model", rawPrompt(cfg, "hi"), 12, cfg, 0, nil)
+			var deltas int
+			for _, ch := range parseSSE(t, strings.TrimSpace(rr.Body.String())).chunks {
+				if len(ch.Choices) > 0 && ch.Choices[0].Delta.Content != "" {
+					deltas++
+				}
+			}
+
+			// role chunk, deltas, finish chunk, [DONE]
+			want := []events.Type{""}
+			want = append(want, slices.Repeat([]events.Type{""}, deltas)...)
+			want = append(want, "", "")
+			if naming == events.Anthropic {
+				want = []events.Type{events.MessageStart}
+				want = append(want, slices.Repeat([]events.Type{events.ContentBlockDelta}, deltas)...)
+				want = append(want, events.MessageDelta, events.MessageStop, "")
+			}
+			if got := eventNames(rr.Body.String()); !slices.Equal(got, want) {
+				t.Fatalf("expected events %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+// TestSSEDisconnectLogsCounters verifies a client leaving mid-stream is logged with what it was sent.
+func TestSSEDisconnectLogsCounters(t *testing.T) {
+	logs := observeLogsAt(t, zapcore.DebugLevel)
+	cfg := config.Config{ChunkSize: config.Int(8), StrictTokenMode: config.Bool(true), StreamDelayMinMs: config.Int(20), StreamDelayMaxMs: config.Int(20)}
+	srv := httptest.NewServer(NewHTTPMux(cfg, nil, nil, nil))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/v1/chat/completions?prompt=disconnect&max_tokens=256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	read := 0
+	for read < 3 && sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "data: ") {
+			read++
+		}
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("[http][ChatCompletionSSE] client disconnected").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no disconnect line logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f := logs.FilterMessage("[http][ChatCompletionSSE] client disconnected").All()[0].ContextMap()
+	if f["chunksSent"].(int64) < int64(read) || f["bytesSent"].(int64) <= 0 || f["chunksSent"].(int64) >= 256/8 {
+		t.Fatalf("unexpected counters after reading %d events: %v", read, f)
+	}
+}
+
+// TestHTTPResetRate verifies HTTP_RESET_RATE against a real server: SSE and ND-JSON streams are cut
+// after some events, so the client's read fails with no final event, while non-streaming requests
+// are never reset.
+func TestHTTPResetRate(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(8), DefaultTokens: 64, StrictTokenMode: config.Bool(true), HTTPResetRate: 1}
+	srv := httptest.NewServer(NewHTTPMux(cfg, nil, nil, nil))
+	t.Cleanup(srv.Close)
+
+	for _, query := range []string{"prompt=reset", "prompt=reset&stream_format=ndjson"} {
+		resp, err := http.Get(srv.URL + "/v1/chat/completions?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%s: expected the read to fail mid-stream, got %v\n%s", query, err, body)
+		}
+		if !strings.Contains(string(body), `"role":"assistant"`) || strings.Contains(string(body), "finish_reason\":\"stop") || strings.Contains(string(body), "[DONE]") {
+			t.Fatalf("%s: expected some events and no final one before the reset:\n%s", query, body)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"reset"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out mock.ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != http.StatusOK || out.Choices[0].FinishReason != string(events.FinishStop) {
+		t.Fatalf("a non-streaming request was affected: %d %v", resp.StatusCode, err)
+	}
+}
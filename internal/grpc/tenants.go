@@ -0,0 +1,229 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/ratelimit"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// tenantIDKey is the metadata (or HTTP header) naming the tenant a request is made for (see Tenants).
+const tenantIDKey = "x-tenant-id"
+
+// Profiles of the requests of tenants without one of their own, as counted in the stats.
+const (
+	defaultTenant = "default" // run on the "default" profile
+	unknownTenant = "unknown" // rejected with TENANT_UNKNOWN=reject
+)
+
+// Tenants are the TENANT_PROFILES: per tenant ID, a partial config override and an optional limit
+// of requests per minute. A request's profile goes below everything else that changes its config
+// (faults, x-mock-* overrides), so its preset and settings take the place of the server's. A JSON
+// object, or a JSON or YAML file holding one:
+//
+//	{
+//	  "acme":    {"preset": "vllm", "rpm": 600},
+//	  "globex":  {"preset": "llamacpp", "error_rate": 0.05, "error_mode": "429"},
+//	  "default": {"rpm": 60}
+//	}
+//
+// Besides preset and rpm a profile takes the fields of AdminService.UpdateConfig (see RuntimeConfig
+// in llm.proto). Tenants without a profile, and requests without x-tenant-id, run on the "default"
+// profile (the server's config when there is none) or, with TENANT_UNKNOWN=reject, fail with
+// PermissionDenied / 403. A profile's rpm is shared by all its requests: the "default" one limits
+// every tenant without a profile together, so a new x-tenant-id does not get a fresh allowance.
+type Tenants struct {
+	profiles map[string]*tenantProfile
+	reject   bool // TENANT_UNKNOWN=reject
+}
+
+type tenantProfile struct {
+	name     string // the tenant ID, or defaultTenant
+	preset   string // "" keeps the server's
+	override *llmv1.RuntimeConfig
+	limiter  *ratelimit.Limiter // rpm of the profile, nil without a limit
+}
+
+// LoadTenants reads cfg.TenantProfiles, inline or from its file, and checks that every profile
+// gives a valid config on top of cfg.
+func LoadTenants(cfg config.Config) (*Tenants, error) {
+	data := []byte(cfg.TenantProfiles)
+	if path := cfg.TenantProfilesFile(); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		t, err := parseTenants(data, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return t, nil
+	}
+	return parseTenants(data, cfg)
+}
+
+func parseTenants(data []byte, base config.Config) (*Tenants, error) {
+	var doc map[string]map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, errors.New("no tenant profiles")
+	}
+	t := &Tenants{profiles: map[string]*tenantProfile{}, reject: base.TenantUnknown == "reject"}
+	for id, fields := range doc {
+		p := &tenantProfile{name: id, override: &llmv1.RuntimeConfig{}}
+		if v, ok := fields["preset"]; ok {
+			if p.preset, ok = v.(string); !ok {
+				return nil, fmt.Errorf("tenant %q: preset must be a string", id)
+			}
+		}
+		if v, ok := fields["rpm"]; ok {
+			rpm, ok := v.(int)
+			if !ok || rpm < 0 {
+				return nil, fmt.Errorf("tenant %q: rpm must be an integer >= 0", id)
+			}
+			p.limiter = ratelimit.New(rpm, 0)
+		}
+		// The rest goes through protojson so it takes the same names and types as UpdateConfig.
+		rest := make(map[string]any, len(fields))
+		for k, v := range fields {
+			if k != "preset" && k != "rpm" {
+				rest[k] = v
+			}
+		}
+		raw, err := json.Marshal(rest)
+		if err == nil {
+			err = protojson.Unmarshal(raw, p.override)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", id, err)
+		}
+		if errs := config.Validate(p.apply(base)); len(errs) > 0 {
+			return nil, fmt.Errorf("tenant %q: %w", id, errors.Join(errs...))
+		}
+		t.profiles[id] = p
+	}
+	if t.profiles[defaultTenant] == nil {
+		t.profiles[defaultTenant] = &tenantProfile{name: defaultTenant, override: &llmv1.RuntimeConfig{}}
+	}
+	return t, nil
+}
+
+// SetTenants makes requests run on the TENANT_PROFILES profile of their x-tenant-id (nil disables).
+// It must be called before serving.
+func (s *MockLlmService) SetTenants(t *Tenants) {
+	s.tenants = t
+}
+
+// Names returns the tenant IDs with a profile, sorted.
+func (t *Tenants) Names() []string {
+	var names []string
+	for id := range t.profiles {
+		if id != defaultTenant {
+			names = append(names, id)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// profile returns the profile of the tenant id, failing for a tenant without one that
+// TENANT_UNKNOWN rejects.
+func (t *Tenants) profile(id string) (*tenantProfile, error) {
+	if p, ok := t.profiles[id]; ok {
+		return p, nil
+	}
+	if t.reject {
+		return nil, fmt.Errorf("unknown tenant %q", id)
+	}
+	return t.profiles[defaultTenant], nil
+}
+
+// apply returns cfg with the profile applied: its preset, then its settings. A nil profile returns
+// cfg.
+func (p *tenantProfile) apply(cfg config.Config) config.Config {
+	if p == nil {
+		return cfg
+	}
+	if p.preset != "" {
+		config.UsePreset(&cfg, p.preset)
+	}
+	applyRuntimeConfig(&cfg, p.override)
+	return cfg
+}
+
+// admitTenant applies the profile of the tenant an RPC names in its x-tenant-id metadata to s.cfg,
+// and records it in s.tenant. The RPC fails with PermissionDenied when TENANT_UNKNOWN rejects the
+// tenant, and with ResourceExhausted past the profile's rpm. Without TENANT_PROFILES it does
+// nothing.
+func (s *MockLlmService) admitTenant(ctx context.Context) error {
+	if s.tenants == nil {
+		return nil
+	}
+	var id string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(tenantIDKey); len(v) > 0 {
+		id = v[0]
+	}
+	p, err := s.tenants.profile(id)
+	if err != nil {
+		s.tenant = unknownTenant
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	s.tenant = p.name
+	if d := p.limiter.Allow(p.name); !d.Allowed {
+		logger.Log.Infow("[grpc] tenant rate limited", "tenant", id, "profile", p.name, "retryAfterMs", d.RetryAfter.Milliseconds())
+		return rateLimitStatus(d)
+	}
+	s.cfg = p.apply(s.cfg)
+	return nil
+}
+
+type tenantCtxKey struct{}
+
+// protect wraps h resolving the tenant profile of each request from its x-tenant-id header, for
+// liveHandler to apply (see requestTenant). Tenants TENANT_UNKNOWN rejects get a 403 and requests
+// past the profile's rpm a 429, both written with writeErr. A nil *Tenants returns h.
+func (t *Tenants) protect(h http.Handler, writeErr httpErrorWriter) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(tenantIDKey)
+		p, err := t.profile(id)
+		if err != nil {
+			setRequestTenant(r.Context(), unknownTenant)
+			writeErr(w, http.StatusForbidden, err.Error())
+			return
+		}
+		setRequestTenant(r.Context(), p.name)
+		if d := p.limiter.Allow(p.name); !d.Allowed {
+			logger.Log.Infow("[http] tenant rate limited", "path", r.URL.Path, "tenant", id, "profile", p.name, "retryAfterMs", d.RetryAfter.Milliseconds())
+			setRateLimitHeaders(w.Header(), d)
+			rejectRateLimited(w, d, writeErr)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantCtxKey{}, p)))
+	})
+}
+
+// requestTenant returns the tenant profile of an HTTP request, nil without TENANT_PROFILES.
+func requestTenant(ctx context.Context) *tenantProfile {
+	p, _ := ctx.Value(tenantCtxKey{}).(*tenantProfile)
+	return p
+}
@@ -0,0 +1,188 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/internal/stats"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+const testTenantProfiles = `{
+  "fast": {"ttft_min_ms": 0, "ttft_max_ms": 5},
+  "slow": {"preset": "vllm", "ttft_min_ms": 60, "ttft_max_ms": 80},
+  "capped": {"rpm": 1}
+}`
+
+func tenantService(t *testing.T, unknown string) *MockLlmService {
+	t.Helper()
+	cfg := debugConfig()
+	cfg.Preset = ""
+	cfg.TenantProfiles, cfg.TenantUnknown = testTenantProfiles, unknown
+	tenants, err := LoadTenants(cfg)
+	if err != nil {
+		t.Fatalf("LoadTenants: %v", err)
+	}
+	svc := NewMockLlmService(cfg)
+	svc.SetTenants(tenants)
+	return svc
+}
+
+// tenantStream runs a debug stream for tenant (no x-tenant-id when "") and returns its debug
+// trailer and error.
+func tenantStream(svc *MockLlmService, tenant string) (metadata.MD, error) {
+	md := metadata.Pairs(debugKey, "true")
+	if tenant != "" {
+		md.Set(tenantIDKey, tenant)
+	}
+	fs := llmtest.NewServerStream(metadata.NewIncomingContext(context.Background(), md))
+	err := svc.ChatCompletionStream(&llmv1.ChatCompletionRequest{UserPrompt: "whose am I", MaxTokens: 8}, fs)
+	return fs.Trailer(), err
+}
+
+func tenantDebug(t *testing.T, svc *MockLlmService, tenant string) mock.Debug {
+	t.Helper()
+	tr, err := tenantStream(svc, tenant)
+	if err != nil {
+		t.Fatalf("stream for tenant %q: %v", tenant, err)
+	}
+	return parseDebug(t, tr)
+}
+
+func TestTenantProfiles(t *testing.T) {
+	svc := tenantService(t, "")
+	before := stats.Default.Snapshot(false).Tenants
+
+	if d := tenantDebug(t, svc, "fast"); d.Tenant != "fast" || d.TTFTMs > 5 || d.Preset != "" {
+		t.Fatalf("fast tenant ran with %+v", d)
+	}
+	if d := tenantDebug(t, svc, "slow"); d.Tenant != "slow" || d.TTFTMs < 60 || d.TTFTMs > 80 || d.Preset != "vllm" {
+		t.Fatalf("slow tenant ran with %+v", d)
+	}
+	// Unknown tenants, and requests without one, run on the server's config.
+	for _, id := range []string{"initech", ""} {
+		if d := tenantDebug(t, svc, id); d.Tenant != defaultTenant || d.TTFTMs < 5 || d.TTFTMs > 30 {
+			t.Fatalf("tenant %q ran with %+v", id, d)
+		}
+	}
+
+	// Each profile has an rpm of its own.
+	if _, err := tenantStream(svc, "capped"); err != nil {
+		t.Fatalf("first capped request: %v", err)
+	}
+	if _, err := tenantStream(svc, "capped"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second capped request: %v, want ResourceExhausted", err)
+	}
+	if _, err := tenantStream(svc, "fast"); err != nil {
+		t.Fatalf("fast tenant limited by another's rpm: %v", err)
+	}
+
+	after := stats.Default.Snapshot(false).Tenants
+	for tenant, want := range map[string]int64{"fast": 2, "slow": 1, defaultTenant: 2, "capped": 2} {
+		if got := after[tenant].Requests - before[tenant].Requests; got != want {
+			t.Fatalf("stats count %d requests for %s, want %d", got, tenant, want)
+		}
+	}
+	if got := after["capped"].Failures - before["capped"].Failures; got != 1 {
+		t.Fatalf("stats count %d errors for capped, want 1", got)
+	}
+}
+
+// TestTenantDefaultRPMShared checks tenants without a profile share the "default" profile's rpm
+// rather than each getting their own.
+func TestTenantDefaultRPMShared(t *testing.T) {
+	cfg := debugConfig()
+	cfg.Preset, cfg.TenantProfiles = "", `{"default": {"rpm": 1}}`
+	tenants, err := LoadTenants(cfg)
+	if err != nil {
+		t.Fatalf("LoadTenants: %v", err)
+	}
+	svc := NewMockLlmService(cfg)
+	svc.SetTenants(tenants)
+
+	if _, err := tenantStream(svc, "initech"); err != nil {
+		t.Fatalf("first unknown tenant: %v", err)
+	}
+	if _, err := tenantStream(svc, "hooli"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second unknown tenant: %v, want ResourceExhausted", err)
+	}
+}
+
+func TestTenantUnknownReject(t *testing.T) {
+	svc := tenantService(t, "reject")
+	before := stats.Default.Snapshot(false).Tenants[unknownTenant]
+	for _, id := range []string{"initech", ""} {
+		if _, err := tenantStream(svc, id); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("tenant %q: %v, want PermissionDenied", id, err)
+		}
+	}
+	if got := stats.Default.Snapshot(false).Tenants[unknownTenant].Requests - before.Requests; got != 2 {
+		t.Fatalf("stats count %d unknown tenant requests, want 2", got)
+	}
+	if d := tenantDebug(t, svc, "fast"); d.Tenant != "fast" {
+		t.Fatalf("known tenant ran with %+v", d)
+	}
+}
+
+func TestTenantProfilesHTTP(t *testing.T) {
+	mux := NewLiveHTTPMux(tenantService(t, "reject"), nil, nil, nil)
+	get := func(tenant string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/v1/chat/completions?prompt=hi&max_tokens=8&debug=1", nil)
+		r.Header.Set(tenantIDKey, tenant)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		return rr
+	}
+
+	if rr := get("slow"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"tenant":"slow"`) || !strings.Contains(rr.Body.String(), `"preset":"vllm"`) {
+		t.Fatalf("slow tenant: %d\n%s", rr.Code, rr.Body.String())
+	}
+	if rr := get("initech"); rr.Code != http.StatusForbidden {
+		t.Fatalf("unknown tenant: %d, want 403", rr.Code)
+	}
+	if rr := get("capped"); rr.Code != http.StatusOK {
+		t.Fatalf("first capped request: %d", rr.Code)
+	}
+	if rr := get("capped"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("second capped request: %d %v, want 429", rr.Code, rr.Header())
+	}
+}
+
+func TestLoadTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	if err := os.WriteFile(path, []byte("acme:\n  preset: llamacpp\n  rpm: 60\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := LoadTenants(config.Config{TenantProfiles: path})
+	if err != nil {
+		t.Fatalf("LoadTenants(%s): %v", path, err)
+	}
+	if names := tenants.Names(); len(names) != 1 || names[0] != "acme" {
+		t.Fatalf("Names() = %v", names)
+	}
+
+	for _, bad := range []string{
+		`{}`,
+		`{"acme": {"preset": "nope"}}`,
+		`{"acme": {"rpm": -1}}`,
+		`{"acme": {"error_rate": 2}}`,
+		`{"acme": {"no_such_field": 1}}`,
+	} {
+		if _, err := LoadTenants(config.Config{TenantProfiles: bad}); err == nil {
+			t.Fatalf("LoadTenants(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, cn string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a leaf certificate and key (PEM) signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return p
+}
+
+func TestMTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	serverCert, serverKey := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+
+	cfg := config.Config{
+		StrictTokenMode: config.Bool(true),
+		MaxOutputChars:  config.Int(64),
+		TLSCertFile:     writeFile(t, dir, "server.crt", serverCert),
+		TLSKeyFile:      writeFile(t, dir, "server.key", serverKey),
+		TLSClientCAFile: writeFile(t, dir, "ca.crt", ca.pem),
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	call := func(certs ...tls.Certificate) error {
+		creds := credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs})
+		client := startTestServer(t, cfg, grpc.WithTransportCredentials(creds))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.ChatCompletion(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hi"})
+		return err
+	}
+
+	// Accepted: client certificate signed by the configured CA.
+	certPEM, keyPEM := ca.issue(t, "load-client-1", x509.ExtKeyUsageClientAuth)
+	good, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("client key pair: %v", err)
+	}
+	if err := call(good); err != nil {
+		t.Fatalf("valid client certificate rejected: %v", err)
+	}
+
+	// Rejected: no client certificate.
+	if err := call(); err == nil {
+		t.Fatalf("connection without a client certificate was accepted")
+	}
+
+	// Rejected: client certificate from another CA.
+	other := newTestCA(t, "other-ca")
+	certPEM, keyPEM = other.issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+	bad, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("client key pair: %v", err)
+	}
+	if err := call(bad); err == nil {
+		t.Fatalf("client certificate from an unknown CA was accepted")
+	}
+}
+
+func TestClientCARequiresServerCert(t *testing.T) {
+	if _, err := ServerOptions(config.Config{TLSClientCAFile: "ca.crt"}, nil, nil); err == nil {
+		t.Fatalf("expected an error for TLS_CLIENT_CA_FILE without server certificates")
+	}
+}
@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+	"github.com/yungtweek/llm-simulator/llmtest"
+)
+
+// TestPromptTokenAccounting pins the prompt tokens of a four-message conversation under each
+// PROMPT_TOKEN_ACCOUNTING, and checks the gRPC calls and the HTTP endpoints all report them:
+//
+//	formatted:    "[system]\nYou are terse.\n\n[user]\nWhat is 2+2?\n\n[assistant]\n4\n\n[user]\nAnd 3+3?" is 76 runes, 19 tokens
+//	content_only: 4+3+1+2 content tokens plus the per-message overhead of 4 messages
+func TestPromptTokenAccounting(t *testing.T) {
+	req := &llmv1.ChatCompletionRequest{
+		SystemPrompt: "You are terse.",
+		Context: []*llmv1.ChatMessage{
+			{Role: "user", Content: "What is 2+2?"},
+			{Role: "assistant", Content: "4"},
+		},
+		UserPrompt: "And 3+3?",
+		MaxTokens:  8,
+	}
+	const (
+		chatBody      = `{"max_tokens":8,"messages":[{"role":"system","content":"You are terse."},{"role":"user","content":"What is 2+2?"},{"role":"assistant","content":"4"},{"role":"user","content":"And 3+3?"}]%s}`
+		anthropicBody = `{"max_tokens":8,"system":"You are terse.","messages":[{"role":"user","content":"What is 2+2?"},{"role":"assistant","content":"4"},{"role":"user","content":"And 3+3?"}]}`
+	)
+
+	for _, tc := range []struct {
+		name       string
+		accounting string
+		perMessage int
+		want       int
+	}{
+		{"formatted", "formatted", 4, 19},
+		{"default", "", 4, 19},
+		{"content only", "content_only", 4, 26},
+		{"content only without overhead", "content_only", 0, 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Config{
+				StrictTokenMode:        config.Bool(true),
+				ChunkSize:              config.Int(8),
+				PromptTokenAccounting:  tc.accounting,
+				PromptTokensPerMessage: tc.perMessage,
+			}
+			if got := promptTokens(cfg, req); got != tc.want {
+				t.Fatalf("promptTokens = %d, want %d", got, tc.want)
+			}
+
+			resp, err := NewMockLlmService(cfg).ChatCompletion(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unary failed: %v", err)
+			}
+			if resp.GetPromptTokens() != int32(tc.want) {
+				t.Fatalf("unary prompt tokens = %d, want %d", resp.GetPromptTokens(), tc.want)
+			}
+			// The output is generated from the same text in every mode.
+			if resp.GetOutputText() != buildOutput(cfg, buildPromptForTokens(req), 8) {
+				t.Fatalf("accounting changed the output: %q", resp.GetOutputText())
+			}
+
+			fs := llmtest.NewServerStream(context.Background())
+			if err := NewMockLlmService(cfg).ChatCompletionStream(req, fs); err != nil {
+				t.Fatalf("stream failed: %v", err)
+			}
+			if last := fs.Chunks()[len(fs.Chunks())-1]; last.GetPromptTokens() != int32(tc.want) {
+				t.Fatalf("stream prompt tokens = %d, want %d", last.GetPromptTokens(), tc.want)
+			}
+
+			for _, stream := range []bool{false, true} {
+				extra := ""
+				if stream {
+					extra = `,"stream":true`
+				}
+				rr := httptest.NewRecorder()
+				ChatCompletionSSEHandler(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(strings.Replace(chatBody, "%s", extra, 1))))
+				if got := rr.Header().Get("X-Usage-Prompt-Tokens"); got != strconv.Itoa(tc.want) {
+					t.Fatalf("chat completions (stream %t) prompt tokens = %s, want %d", stream, got, tc.want)
+				}
+			}
+
+			areq := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(anthropicBody))
+			areq.Header.Set("anthropic-version", "2023-06-01")
+			rr := httptest.NewRecorder()
+			AnthropicMessagesHandler(cfg).ServeHTTP(rr, areq)
+			var msg mock.AnthropicMessageResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &msg); err != nil {
+				t.Fatalf("bad anthropic response: %v", err)
+			}
+			if msg.Usage.InputTokens != tc.want {
+				t.Fatalf("anthropic input tokens = %d, want %d", msg.Usage.InputTokens, tc.want)
+			}
+		})
+	}
+}
+
+// TestMessageLimits checks MAX_CONTEXT_MESSAGES and MAX_MESSAGE_CHARS reject a request on every path
+// with an error naming the limit, and that zero leaves either unlimited.
+func TestMessageLimits(t *testing.T) {
+	longReq := &llmv1.ChatCompletionRequest{UserPrompt: strings.Repeat("é", 11), MaxTokens: 8}
+	manyReq := &llmv1.ChatCompletionRequest{UserPrompt: "hi", MaxTokens: 8}
+	for range 4 {
+		manyReq.Context = append(manyReq.Context, &llmv1.ChatMessage{Role: "user", Content: "earlier"})
+	}
+	const (
+		longBody = `{"max_tokens":8,"messages":[{"role":"user","content":"ééééééééééé"}]}`
+		manyBody = `{"max_tokens":8,"messages":[{"role":"user","content":"a"},{"role":"user","content":"b"},{"role":"user","content":"c"},{"role":"user","content":"d"},{"role":"user","content":"hi"}]}`
+	)
+
+	for _, tc := range []struct {
+		name  string
+		cfg   config.Config
+		req   *llmv1.ChatCompletionRequest
+		body  string
+		limit string
+	}{
+		{"too many messages", config.Config{MaxContextMessages: 3}, manyReq, manyBody, "MAX_CONTEXT_MESSAGES (3)"},
+		{"message too long", config.Config{MaxMessageChars: 10}, longReq, longBody, "MAX_MESSAGE_CHARS (10)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewMockLlmService(tc.cfg)
+			if _, err := svc.ChatCompletion(context.Background(), tc.req); status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), tc.limit) {
+				t.Fatalf("unary: expected InvalidArgument naming %s, got %v", tc.limit, err)
+			}
+			fs := llmtest.NewServerStream(context.Background())
+			if err := svc.ChatCompletionStream(tc.req, fs); status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), tc.limit) {
+				t.Fatalf("stream: expected InvalidArgument naming %s, got %v", tc.limit, err)
+			}
+			if c := llmtest.RequireFailed(t, fs.Chunks(), codes.InvalidArgument); fs.Attempts() != 1 {
+				t.Fatalf("stream sent more than the failed chunk: %v", c)
+			}
+
+			mux := NewLiveHTTPMux(svc, nil, nil, nil)
+			for _, path := range []string{"/v1/chat/completions", "/api/chat"} {
+				rr := httptest.NewRecorder()
+				mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(tc.body)))
+				if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tc.limit) {
+					t.Fatalf("%s: expected 400 naming %s, got %d %s", path, tc.limit, rr.Code, rr.Body.String())
+				}
+			}
+			areq := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tc.body))
+			areq.Header.Set("anthropic-version", "2023-06-01")
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, areq)
+			if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tc.limit) {
+				t.Fatalf("anthropic: expected 400 naming %s, got %d %s", tc.limit, rr.Code, rr.Body.String())
+			}
+
+			// Zero is unlimited.
+			if _, err := NewMockLlmService(config.Config{}).ChatCompletion(context.Background(), tc.req); err != nil {
+				t.Fatalf("unlimited config rejected the request: %v", err)
+			}
+		})
+	}
+
+	// The char limit counts runes: eleven two-byte runes fit in 11 chars but not in 21 bytes.
+	if err := checkMessages(config.Config{MaxMessageChars: 11}, longReq); err != nil {
+		t.Fatalf("11 runes rejected at MAX_MESSAGE_CHARS=11: %v", err)
+	}
+}
+
+// TestBuildPromptForTokensAllocs checks the prompt builder sizes its buffer up front: rendering a
+// conversation takes one allocation however many messages it has.
+func TestBuildPromptForTokensAllocs(t *testing.T) {
+	req := longConversation(200)
+	if allocs := testing.AllocsPerRun(20, func() { buildPromptForTokens(req) }); allocs != 1 {
+		t.Fatalf("buildPromptForTokens allocated %v times, want 1", allocs)
+	}
+}
+
+func BenchmarkBuildPromptForTokens(b *testing.B) {
+	req := longConversation(200)
+	b.ReportAllocs()
+	for b.Loop() {
+		buildPromptForTokens(req)
+	}
+}
+
+func longConversation(n int) *llmv1.ChatCompletionRequest {
+	req := &llmv1.ChatCompletionRequest{SystemPrompt: "You are terse.", UserPrompt: "And now?"}
+	for i := range n {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		req.Context = append(req.Context, &llmv1.ChatMessage{Role: role, Content: strings.Repeat("word ", i%40+1)})
+	}
+	return req
+}
@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/logger"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/status"
+)
+
+// wsCloseInjectedBase is added to the injected HTTP status to form the application close code
+// (e.g. 4429, 4500) sent after an injected error.
+const wsCloseInjectedBase = 4000
+
+// WSChatHandler serves GET /v1/chat/ws: after the upgrade the client sends one mock.ChatRequest JSON
+// message and the server streams mock.WSFrame messages ("delta" frames with the usual pacing, then a
+// "done" frame carrying usage) before closing normally.
+//
+// The client may send {"type":"cancel"} at any time to abort generation; it is handled like context
+// cancellation and answered with a "canceled" frame. Injected errors send an "error" frame followed by
+// a close frame with code 4000+status.
+func WSChatHandler(cfg config.Config) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			// Browser demos run on other origins; honor the CORS allowlist when one is configured.
+			origin := r.Header.Get("Origin")
+			if origin == "" || len(cfg.CORSAllowedOrigins) == 0 {
+				return true
+			}
+			return slices.Contains(cfg.CORSAllowedOrigins, "*") || slices.Contains(cfg.CORSAllowedOrigins, origin)
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade already wrote an HTTP error.
+			return
+		}
+		defer conn.Close()
+
+		var req mock.ChatRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			closeWS(conn, websocket.CloseUnsupportedData, "invalid ChatRequest: "+err.Error())
+			return
+		}
+		if err := checkMessages(cfg, chatRequestToProto(req)); err != nil {
+			closeWS(conn, websocket.ClosePolicyViolation, status.Convert(err).Message())
+			return
+		}
+		if !overridesAllowed(cfg) {
+			req.Mock = nil
+		}
+		serveWSChat(r.Context(), conn, req, applyOverrides(applyOverrides(cfg, req.Mock), requestOverrides(r.Context())))
+	}
+}
+
+func serveWSChat(parent context.Context, conn *websocket.Conn, req mock.ChatRequest, cfg config.Config) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// Reader: a cancel message (or the client going away) stops generation.
+	canceled := make(chan struct{})
+	go func() {
+		for {
+			var msg mock.WSClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				cancel()
+				return
+			}
+			if msg.Type == "cancel" {
+				close(canceled)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	seq := 0
+	send := func(f mock.WSFrame) bool {
+		f.Seq = seq
+		seq++
+		return conn.WriteJSON(f) == nil
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "mock-ws"
+	}
+	setRequestModel(parent, model)
+
+	if code := injectHTTPError(cfg); code != 0 {
+		requestLog(parent, logger.Log).Infow("[http][WSChat] injected error", "mode", cfg.ErrorMode, "status", code)
+		if send(mock.WSFrame{Type: "error", Error: &mock.WSError{Code: code, Message: "mock error", Injected: true}}) {
+			closeWS(conn, wsCloseInjectedBase+code, "mock error")
+		}
+		return
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultInt(cfg.DefaultTokens, 128)
+	}
+	prompt := newChatPrompt(cfg, chatRequestToProto(req))
+	if cfg.Randomize {
+		maxTokens = mock.PickTargetTokens(maxTokens, len([]rune(prompt.text)))
+	}
+	chunkSize := sseChunkSize(cfg, 0)
+	content := buildOutput(cfg, prompt.text, maxTokens)
+	requestLog(parent, logger.Sample(cfg.LogSampleRate)).Infow("[http][WSChat] start", "model", model, "outputLen", len(content), "chunkSize", chunkSize, "priority", requestPriority(parent))
+
+	stopped := func() bool {
+		if ctx.Err() == nil {
+			return false
+		}
+		select {
+		case <-canceled:
+			requestLog(parent, logger.Log).Infow("[http][WSChat] canceled by client", "sentFrames", seq)
+			if send(mock.WSFrame{Type: "canceled"}) {
+				closeWS(conn, websocket.CloseNormalClosure, "canceled")
+			}
+		default:
+		}
+		return true
+	}
+
+	start := time.Now()
+	svc := requestService(parent, cfg)
+	queue := sleepMeasured(ctx, time.Duration(svc.queueMs())*time.Millisecond)
+	var prefill time.Duration
+	if ctx.Err() == nil {
+		prefill = sleepMeasured(ctx, time.Duration(svc.ttftMs())*time.Millisecond)
+	}
+	if stopped() {
+		return
+	}
+
+	pace := newStreamPacer(cfg)
+	var firstDelta time.Time
+	for i := 0; i < len(content); i += chunkSize {
+		end := min(i+chunkSize, len(content))
+		part := content[i:end]
+		if !send(mock.WSFrame{Type: "delta", Text: part}) {
+			return
+		}
+		if firstDelta.IsZero() {
+			firstDelta = time.Now()
+		}
+		pace.wait(ctx, part)
+		if stopped() {
+			return
+		}
+	}
+
+	pt, ct := prompt.tokens, mock.ApproxTokens(content)
+	done := mock.WSFrame{
+		Type:         "done",
+		FinishReason: string(events.FinishStop),
+		Usage:        &mock.WSUsage{PromptTokens: pt, CompletionTokens: ct, TotalTokens: pt + ct},
+		Timing: &mock.StreamTiming{
+			QueueMs:      queue.Milliseconds(),
+			PromptEvalMs: prefill.Milliseconds(),
+			TTFTMs:       firstDelta.Sub(start).Milliseconds(),
+			GenerationMs: time.Since(firstDelta).Milliseconds(),
+		},
+	}
+	if send(done) {
+		reportUsage(parent, pt, ct)
+		closeWS(conn, websocket.CloseNormalClosure, "")
+	}
+}
+
+// closeWS sends a close frame with the given code and reason.
+func closeWS(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/yungtweek/llm-simulator/events"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+)
+
+func dialWS(t *testing.T, cfg config.Config) (*websocket.Conn, func()) {
+	t.Helper()
+	srv := httptest.NewServer(NewHTTPMux(cfg, nil, nil, nil))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/chat/ws", nil)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("dial failed: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestWSChatReassembly(t *testing.T) {
+	cfg := config.Config{ChunkSize: config.Int(5), StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(256)}
+	conn, cleanup := dialWS(t, cfg)
+	defer cleanup()
+
+	body := `{"model":"ws-model","max_tokens":12,"messages":[{"role":"user","content":"hello ws"}]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(body)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var assembled strings.Builder
+	var done mock.WSFrame
+	for seq := 0; ; seq++ {
+		var f mock.WSFrame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if f.Seq != seq {
+			t.Fatalf("unexpected seq: got %d, expected %d", f.Seq, seq)
+		}
+		if f.Type == "delta" {
+			assembled.WriteString(f.Text)
+			continue
+		}
+		done = f
+		break
+	}
+
+	prompt := buildPromptForTokens(&llmv1.ChatCompletionRequest{UserPrompt: "hello ws"})
+	expected := buildOutput(cfg, prompt, 12)
+	if assembled.String() != expected {
+		t.Fatalf("reassembled content mismatch\nexpected %q\ngot      %q", expected, assembled.String())
+	}
+	if done.Type != "done" || done.FinishReason != string(events.FinishStop) || done.Usage == nil {
+		t.Fatalf("unexpected final frame: %+v", done)
+	}
+	if done.Usage.CompletionTokens != mock.ApproxTokens(expected) || done.Usage.PromptTokens != mock.ApproxTokens(prompt) {
+		t.Fatalf("usage mismatch: %+v", done.Usage)
+	}
+
+	_, _, err := conn.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != websocket.CloseNormalClosure {
+		t.Fatalf("expected normal closure, got %v", err)
+	}
+}
+
+func TestWSChatCancel(t *testing.T) {
+	cfg := config.Config{
+		ChunkSize:        config.Int(4),
+		StreamDelayMinMs: config.Int(20),
+		StreamDelayMaxMs: config.Int(20),
+		StrictTokenMode:  config.Bool(true),
+		MaxOutputChars:   config.Int(1024),
+	}
+	conn, cleanup := dialWS(t, cfg)
+	defer cleanup()
+
+	body := `{"max_tokens":200,"messages":[{"role":"user","content":"cancel me"}]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(body)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var f mock.WSFrame
+	if err := conn.ReadJSON(&f); err != nil || f.Type != "delta" {
+		t.Fatalf("expected a first delta, got %+v err=%v", f, err)
+	}
+	if err := conn.WriteJSON(mock.WSClientMessage{Type: "cancel"}); err != nil {
+		t.Fatalf("write cancel failed: %v", err)
+	}
+
+	deltas := 1
+	for {
+		var f mock.WSFrame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("stream ended without a canceled frame: %v", err)
+		}
+		if f.Type == "delta" {
+			deltas++
+			continue
+		}
+		if f.Type != "canceled" {
+			t.Fatalf("expected canceled frame, got %+v", f)
+		}
+		break
+	}
+	if deltas > 5 {
+		t.Fatalf("generation kept going after cancel: %d deltas", deltas)
+	}
+}
+
+func TestWSChatInjectedError(t *testing.T) {
+	cfg := config.Config{ErrorRate: 1, ErrorMode: "429", StrictTokenMode: config.Bool(true), MaxOutputChars: config.Int(64)}
+	conn, cleanup := dialWS(t, cfg)
+	defer cleanup()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"messages":[{"role":"user","content":"hi"}]}`)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var f mock.WSFrame
+	if err := conn.ReadJSON(&f); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if f.Type != "error" || f.Error == nil || f.Error.Code != 429 {
+		t.Fatalf("unexpected error frame: %+v", f)
+	}
+
+	_, _, err := conn.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != 4429 {
+		t.Fatalf("expected close code 4429, got %v", err)
+	}
+}
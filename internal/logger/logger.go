@@ -1,31 +1,125 @@
 package logger
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var Log *zap.SugaredLogger = zap.NewNop().Sugar()
 
-func Init(env string) {
+// Level is the level of the Log built by Init. It can be changed while running (the admin listener
+// serves it as /admin/loglevel), and tests can use it as the level of an observer core.
+var Level = zap.NewAtomicLevel()
+
+// Options configure Init. Empty fields keep the defaults of Env's profile.
+type Options struct {
+	Env             string // "prod" logs JSON at info, anything else colored text at debug
+	Level           string // debug|info|warn|error
+	Format          string // json|console, whatever the profile
+	TimestampFormat string // iso8601|rfc3339|rfc3339nano|epoch|millis|nanos; the format's own by default
+	Output          string // stdout, stderr or a file path the log is appended to; stdout by default
+}
+
+// Init builds Log from o. It fails on an unknown level, format or timestamp format, or an output
+// file that cannot be opened, leaving Log as it was.
+func Init(o Options) error {
 	var cfg zap.Config
 
-	if env == "prod" {
+	if o.Env == "prod" {
 		cfg = zap.NewProductionConfig()
 	} else {
 		cfg = zap.NewDevelopmentConfig()
 		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
+	switch o.Format {
+	case "":
+	case "json":
+		cfg.Encoding, cfg.EncoderConfig = "json", zap.NewProductionEncoderConfig()
+	case "console":
+		cfg.Encoding, cfg.EncoderConfig = "console", zap.NewDevelopmentEncoderConfig()
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+	if o.TimestampFormat != "" {
+		enc, ok := timeEncoders[o.TimestampFormat]
+		if !ok {
+			return fmt.Errorf("unknown log timestamp format %q", o.TimestampFormat)
+		}
+		cfg.EncoderConfig.EncodeTime = enc
+	}
+	lvl := cfg.Level.Level()
+	if o.Level != "" {
+		var err error
+		if lvl, err = zapcore.ParseLevel(o.Level); err != nil {
+			return err
+		}
+	}
 
 	cfg.OutputPaths = []string{"stdout"}
+	if o.Output != "" {
+		cfg.OutputPaths = []string{o.Output}
+	}
 	cfg.ErrorOutputPaths = []string{"stderr"}
+	cfg.Level = Level
 
 	l, err := cfg.Build()
 	if err != nil {
-		panic(err)
+		return err
 	}
-
+	Level.SetLevel(lvl)
 	Log = l.Sugar()
+	return nil
+}
+
+// timeEncoders are the LOG_TIMESTAMP_FORMAT values.
+var timeEncoders = map[string]zapcore.TimeEncoder{
+	"iso8601":     zapcore.ISO8601TimeEncoder,
+	"rfc3339":     zapcore.RFC3339TimeEncoder,
+	"rfc3339nano": zapcore.RFC3339NanoTimeEncoder,
+	"epoch":       zapcore.EpochTimeEncoder,
+	"millis":      zapcore.EpochMillisTimeEncoder,
+	"nanos":       zapcore.EpochNanosTimeEncoder,
+}
+
+var (
+	nop       = zap.NewNop().Sugar()
+	sampleSeq atomic.Uint64
+)
+
+// Sample returns Log for one call in every n and a logger that drops everything for the others
+// (n <= 1 returns Log every time). Handlers take one per request for their per-phase lines, so a
+// sampled request logs all of them and the others none; errors should still go to Log.
+func Sample(n int) *zap.SugaredLogger {
+	if n <= 1 || sampleSeq.Add(1)%uint64(n) == 1 {
+		return Log
+	}
+	return nop
+}
+
+// Redact summarizes user text (a prompt or an output) as its length and the start of its SHA-256,
+// for logs and recordings under REDACT_PROMPTS: equal texts still match across lines, but none of
+// the text is written.
+func Redact(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("[redacted %d chars sha256:%x]", utf8.RuneCountInString(s), sum[:6])
+}
+
+// Text returns user text for a log field or a record: its first max runes (all of it when max <= 0),
+// or its Redact summary when redact is set.
+func Text(s string, max int, redact bool) string {
+	if redact {
+		return Redact(s)
+	}
+	if max > 0 && utf8.RuneCountInString(s) > max {
+		return string([]rune(s)[:max]) + "…"
+	}
+	return s
 }
 
 func Sync() {
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// logLine builds Log from o with a file output, logs one info line and returns it.
+func logLine(t *testing.T, o Options) string {
+	t.Helper()
+	prev, prevLevel := Log, Level.Level()
+	t.Cleanup(func() { Log = prev; Level.SetLevel(prevLevel) })
+
+	o.Output = filepath.Join(t.TempDir(), "sim.log")
+	if err := Init(o); err != nil {
+		t.Fatalf("Init(%+v): %v", o, err)
+	}
+	Log.Infow("round trip", "key", "value")
+	_ = Log.Sync()
+	b, err := os.ReadFile(o.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestInitEncoders(t *testing.T) {
+	for _, tc := range []struct {
+		env, format, timestamp string
+		json                   bool
+		checkTime              func(any) bool // for JSON lines
+	}{
+		{env: "dev", json: false},
+		{env: "prod", json: true, checkTime: isNumber},
+		{env: "dev", format: "json", json: true, checkTime: isNumber},
+		{env: "prod", format: "console", json: false},
+		{env: "dev", format: "json", timestamp: "rfc3339", json: true, checkTime: isTime(time.RFC3339)},
+		{env: "prod", timestamp: "iso8601", json: true, checkTime: isTime("2006-01-02T15:04:05.000Z0700")},
+		{env: "prod", timestamp: "millis", json: true, checkTime: isNumber},
+		{env: "dev", format: "console", timestamp: "rfc3339nano", json: false},
+	} {
+		line := logLine(t, Options{Env: tc.env, Format: tc.format, TimestampFormat: tc.timestamp})
+		var fields map[string]any
+		isJSON := json.Unmarshal([]byte(line), &fields) == nil
+		if isJSON != tc.json {
+			t.Fatalf("%+v: expected JSON %v, got %q", tc, tc.json, line)
+		}
+		if !tc.json {
+			if !strings.Contains(line, "round trip") || !strings.Contains(line, `{"key": "value"}`) {
+				t.Fatalf("%+v: unexpected console line %q", tc, line)
+			}
+			continue
+		}
+		if fields["msg"] != "round trip" || fields["key"] != "value" || !tc.checkTime(fields["ts"]) {
+			t.Fatalf("%+v: unexpected JSON line %q", tc, line)
+		}
+	}
+}
+
+func isNumber(v any) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+func isTime(layout string) func(any) bool {
+	return func(v any) bool {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse(layout, s)
+		return err == nil
+	}
+}
+
+func TestInitLevel(t *testing.T) {
+	line := logLine(t, Options{Env: "prod", Level: "warn"})
+	if line != "" || Level.Level() != zapcore.WarnLevel {
+		t.Fatalf("expected info to be dropped at warn, got %q at %v", line, Level.Level())
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	prev := Log
+	for _, o := range []Options{
+		{Level: "loud"},
+		{Format: "logfmt"},
+		{TimestampFormat: "unix"},
+		{Output: filepath.Join(t.TempDir(), "missing", "sim.log")},
+	} {
+		if err := Init(o); err == nil {
+			t.Fatalf("Init(%+v) should fail", o)
+		}
+		if Log != prev {
+			t.Fatalf("a failed Init(%+v) replaced Log", o)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package metrics holds the simulator's Prometheus metrics, served at /metrics.
+//
+// Metric names are part of the simulator's interface and must stay stable:
+//
+//	llmsim_requests_total{rpc,model,code}      requests by RPC/route, model and status code
+//	llmsim_request_duration_seconds{rpc}       total request latency
+//	llmsim_ttft_seconds{rpc}                   simulated time to first token (queue + prefill)
+//	llmsim_chunk_gap_seconds{rpc}              simulated gap between streamed chunks
+//	llmsim_output_tokens{rpc}                  completion tokens per successful request
+//	llmsim_inflight_streams{rpc}               streams currently being served
+//	llmsim_injected_errors_total{mode,code}    injected errors by ERROR_MODE and status code
+//	llmsim_panics_total{rpc}                   handler panics recovered instead of crashing the server
+//	llmsim_records_dropped_total               request records dropped because the RECORD_FILE buffer was full
+//	llmsim_scenario_phase{phase}               1 for the running SCENARIO_FILE phase, 0 for the phases before it
+//	llmsim_usage_requests_total{model,key_hash}           completed requests by model and API key
+//	llmsim_usage_prompt_tokens_total{model,key_hash}      prompt tokens by model and API key
+//	llmsim_usage_completion_tokens_total{model,key_hash}  completion tokens by model and API key
+//
+// rpc is the gRPC method name (e.g. "ChatCompletionStream") or the HTTP route pattern
+// (e.g. "POST /v1/chat/completions"). code is the gRPC code name (e.g. "OK", "Internal") for gRPC
+// and the numeric status (e.g. "200", "429") for HTTP. key_hash is stats.KeyHash of the caller's
+// API key; model and key_hash fall back to "other" past the cardinality caps in package stats.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "llmsim"
+
+// latencyBuckets spans fast mock responses up to long streams (5ms .. ~82s).
+var latencyBuckets = prometheus.ExponentialBuckets(0.005, 2, 15)
+
+// Registry holds every simulator metric plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Requests by RPC or HTTP route, model (\"other\" past the model cap) and status code.",
+	}, []string{"rpc", "model", "code"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Total request latency.",
+		Buckets:   latencyBuckets,
+	}, []string{"rpc"})
+
+	TTFT = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ttft_seconds",
+		Help:      "Simulated time to first token (queue + prefill).",
+		Buckets:   latencyBuckets,
+	}, []string{"rpc"})
+
+	ChunkGap = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunk_gap_seconds",
+		Help:      "Simulated gap between streamed chunks.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+	}, []string{"rpc"})
+
+	SendBlocked = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "send_blocked_seconds",
+		Help:      "Time a stream spent blocked sending chunks to a slow reader (flow control), per stream.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"rpc"})
+
+	OutputTokens = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "output_tokens",
+		Help:      "Completion tokens per successful request.",
+		Buckets:   prometheus.ExponentialBuckets(8, 2, 12),
+	}, []string{"rpc"})
+
+	InflightStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inflight_streams",
+		Help:      "Streams currently being served.",
+	}, []string{"rpc"})
+
+	SimulatedMemory = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "simulated_memory_bytes",
+		Help:      "Memory held by streams for MEM_BYTES_PER_TOKEN (simulated KV cache).",
+	})
+
+	InjectedErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "injected_errors_total",
+		Help:      "Injected errors by ERROR_MODE and status code.",
+	}, []string{"mode", "code"})
+
+	Panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "panics_total",
+		Help:      "Handler panics recovered instead of crashing the server.",
+	}, []string{"rpc"})
+
+	RecordsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_dropped_total",
+		Help:      "Request records dropped because the RECORD_FILE buffer was full.",
+	})
+
+	ScenarioPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scenario_phase",
+		Help:      "1 for the running SCENARIO_FILE phase, 0 for the phases that ran before it.",
+	}, []string{"phase"})
+
+	UsageRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "usage_requests_total",
+		Help:      "Completed requests by model and API key hash.",
+	}, []string{"model", "key_hash"})
+
+	UsagePromptTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "usage_prompt_tokens_total",
+		Help:      "Prompt tokens by model and API key hash.",
+	}, []string{"model", "key_hash"})
+
+	UsageCompletionTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "usage_completion_tokens_total",
+		Help:      "Completion tokens by model and API key hash.",
+	}, []string{"model", "key_hash"})
+)
+
+func init() {
+	Registry.MustRegister(
+		Requests, RequestDuration, TTFT, ChunkGap, SendBlocked, OutputTokens, InflightStreams, SimulatedMemory, InjectedErrors, Panics, RecordsDropped, ScenarioPhase,
+		UsageRequests, UsagePromptTokens, UsageCompletionTokens,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Since returns the seconds elapsed since t, for histogram observations.
+func Since(t time.Time) float64 {
+	return time.Since(t).Seconds()
+}
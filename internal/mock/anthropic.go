@@ -0,0 +1,117 @@
+package mock
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AnthropicRequest is the subset of the Anthropic Messages API request the simulator understands.
+// System and message content may be either a plain string or a list of content blocks.
+type AnthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	Stream    bool               `json:"stream"`
+	System    json.RawMessage    `json:"system,omitempty"`
+	Messages  []AnthropicMessage `json:"messages"`
+}
+
+type AnthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// AnthropicContentBlock is a single content block. Only "text" blocks carry text.
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicMessageResponse is the non-streaming response body (also nested in message_start).
+type AnthropicMessageResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Content      []AnthropicContentBlock `json:"content"`
+	Model        string                  `json:"model"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicMessageStart struct {
+	Type    string                   `json:"type"`
+	Message AnthropicMessageResponse `json:"message"`
+}
+
+type AnthropicContentBlockStart struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	ContentBlock AnthropicContentBlock `json:"content_block"`
+}
+
+type AnthropicContentBlockDelta struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+type AnthropicContentBlockStop struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+type AnthropicMessageDelta struct {
+	Type  string `json:"type"`
+	Delta struct {
+		StopReason   string  `json:"stop_reason"`
+		StopSequence *string `json:"stop_sequence"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type AnthropicMessageStop struct {
+	Type string `json:"type"`
+}
+
+// AnthropicError is the error envelope used for both HTTP error bodies and `error` stream events.
+type AnthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type     string `json:"type"`
+		Message  string `json:"message"`
+		Injected bool   `json:"injected,omitempty"` // simulator extension, see ErrorResponse
+	} `json:"error"`
+}
+
+// AnthropicText flattens a string-or-blocks content value into plain text.
+// Non-text blocks are ignored; multiple text blocks are joined with newlines.
+func AnthropicText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,61 @@
+package mock
+
+import "strings"
+
+// GeminiRequest is the subset of the Gemini generateContent request the simulator understands.
+type GeminiRequest struct {
+	Contents          []GeminiContent `json:"contents"`
+	SystemInstruction *GeminiContent  `json:"systemInstruction,omitempty"`
+	GenerationConfig  struct {
+		MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	} `json:"generationConfig"`
+}
+
+// GeminiContent is a role ("user" | "model") plus its parts.
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// GeminiResponse is both the generateContent body and a single streamGenerateContent chunk.
+// Usage is only attached to the final chunk of a stream.
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion"`
+}
+
+// GeminiError is the Google API error envelope.
+type GeminiError struct {
+	Error struct {
+		Code     int    `json:"code"`
+		Message  string `json:"message"`
+		Status   string `json:"status"`
+		Injected bool   `json:"injected,omitempty"` // simulator extension, see ErrorResponse
+	} `json:"error"`
+}
+
+// GeminiText joins the text of all parts.
+func GeminiText(c GeminiContent) string {
+	parts := make([]string, 0, len(c.Parts))
+	for _, p := range c.Parts {
+		parts = append(parts, p.Text)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,98 @@
+package mock
+
+// PickTargetTokens chooses a target token budget that feels like real chat:
+// short answers are common, long answers are rare.
+// It returns a value in [1, maxTokens]. If maxTokens <= 0, it uses 128.
+func PickTargetTokens(maxTokens, promptRunes int) int {
+	return (*Rand)(nil).PickTargetTokens(maxTokens, promptRunes)
+}
+
+// PickTargetTokens is the package PickTargetTokens drawing from r.
+func (r *Rand) PickTargetTokens(maxTokens, promptRunes int) int {
+	if maxTokens <= 0 {
+		maxTokens = 128
+	}
+
+	// Base probabilities.
+	pShort := 0.58
+	pNormal := 0.34
+	pLong := 0.07
+	pMaxed := 0.01
+
+	// Bias slightly toward longer outputs when the prompt is long.
+	// (This keeps "tell me everything" prompts from always returning short replies.)
+	if promptRunes > 1200 {
+		pShort -= 0.10
+		pNormal += 0.06
+		pLong += 0.03
+		pMaxed += 0.01
+	} else if promptRunes > 600 {
+		pShort -= 0.06
+		pNormal += 0.04
+		pLong += 0.02
+	}
+
+	// Clamp (defensive).
+	if pShort < 0.10 {
+		pShort = 0.10
+	}
+	if pMaxed < 0.0 {
+		pMaxed = 0.0
+	}
+
+	p := r.Float64()
+
+	// Helper: pick an integer token count from a fractional range of maxTokens.
+	pickFrac := func(minF, maxF float64) int {
+		minT := int(float64(maxTokens) * minF)
+		maxT := int(float64(maxTokens) * maxF)
+		if minT < 1 {
+			minT = 1
+		}
+		if maxT < minT {
+			maxT = minT
+		}
+		if maxT == minT {
+			return minT
+		}
+		return minT + r.Intn(maxT-minT+1)
+	}
+
+	switch {
+	case p < pShort:
+		// 1-3 sentences
+		return pickFrac(0.05, 0.22)
+	case p < pShort+pNormal:
+		// a few short paragraphs
+		return pickFrac(0.22, 0.62)
+	case p < pShort+pNormal+pLong:
+		// long-ish explanation
+		return pickFrac(0.62, 0.92)
+	default:
+		// rare: push to the cap (simulates verbose answers / near-length outputs)
+		_ = pMaxed // kept for readability
+		return pickFrac(0.92, 1.00)
+	}
+}
+
+// JitterChunkSize varies a stream chunk size by up to +/- 33% (at least 1) so stream shapes differ
+// between requests. Sizes of 1 or less are returned unchanged.
+func JitterChunkSize(chunkSize int) int {
+	return (*Rand)(nil).JitterChunkSize(chunkSize)
+}
+
+// JitterChunkSize is the package JitterChunkSize drawing from r.
+func (r *Rand) JitterChunkSize(chunkSize int) int {
+	if chunkSize <= 1 {
+		return chunkSize
+	}
+	j := chunkSize / 3
+	if j < 1 {
+		j = 1
+	}
+	chunkSize = (chunkSize - j) + r.Intn(j*2+1)
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	return chunkSize
+}
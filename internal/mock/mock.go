@@ -1,38 +1,130 @@
 package mock
 
 import (
+	"fmt"
 	"math/rand"
+	randv2 "math/rand/v2"
 	"sync"
-	"time"
+	"sync/atomic"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
 )
 
-var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+// Draws go to math/rand/v2's top-level functions, which need no lock, until Seed is called; from
+// then on they come from the seeded source, one at a time under rngMu, so runs can be reproduced.
+var (
+	seeded atomic.Pointer[rand.Rand]
+	rngMu  sync.Mutex
+)
 
-var rngMu sync.Mutex
+// Seed switches every later draw to a source seeded with seed so runs can be reproduced. Seeded
+// draws are serialized, which costs throughput under high concurrency.
+func Seed(seed int64) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	seeded.Store(rand.New(rand.NewSource(seed)))
+}
 
 func RandIntn(n int) int {
 	if n <= 0 {
 		return 0
 	}
-	rngMu.Lock()
-	defer rngMu.Unlock()
-	return rng.Intn(n)
+	if seeded.Load() != nil {
+		rngMu.Lock()
+		defer rngMu.Unlock()
+		return seeded.Load().Intn(n)
+	}
+	return randv2.IntN(n)
 }
 
 func RandFloat64() float64 {
-	rngMu.Lock()
-	defer rngMu.Unlock()
-	return rng.Float64()
+	if seeded.Load() != nil {
+		rngMu.Lock()
+		defer rngMu.Unlock()
+		return seeded.Load().Float64()
+	}
+	return randv2.Float64()
+}
+
+// RandNormFloat64 returns a standard normal draw (mean 0, stddev 1).
+func RandNormFloat64() float64 {
+	if seeded.Load() != nil {
+		rngMu.Lock()
+		defer rngMu.Unlock()
+		return seeded.Load().NormFloat64()
+	}
+	return randv2.NormFloat64()
+}
+
+// Rand is the source of draws of one request that asked for its own seed. A nil *Rand draws from
+// the shared source (the functions above), so code can take one whether or not the request set one.
+type Rand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// NewRand returns a source seeded with seed, independent of the shared one and of Seed.
+func NewRand(seed int64) *Rand {
+	return &Rand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (r *Rand) Intn(n int) int {
+	if r == nil {
+		return RandIntn(n)
+	}
+	if n <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Intn(n)
+}
+
+func (r *Rand) Float64() float64 {
+	if r == nil {
+		return RandFloat64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
+
+// NormFloat64 returns a standard normal draw (mean 0, stddev 1).
+func (r *Rand) NormFloat64() float64 {
+	if r == nil {
+		return RandNormFloat64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.NormFloat64()
 }
 
-func pickErrorStatus(mode string) int {
-	switch mode {
-	case "429":
+// Chance reports whether an event of probability p happens: never when p <= 0, always when p >= 1,
+// without drawing in either case.
+func (r *Rand) Chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	if p >= 1 {
+		return true
+	}
+	return r.Float64() < p
+}
+
+// Chance is Rand.Chance on the shared source.
+func Chance(p float64) bool {
+	return (*Rand)(nil).Chance(p)
+}
+
+// PickErrorStatus maps an ERROR_MODE (any alias config accepts) to the HTTP status code to inject;
+// mixed draws 429 or 500.
+func PickErrorStatus(mode string) int {
+	switch config.ErrorModeKind(mode) {
+	case config.ErrorKindRateLimit:
 		return 429
-	case "500":
+	case config.ErrorKindInternal:
 		return 500
 	default:
-		// mixed
 		if RandIntn(2) == 0 {
 			return 429
 		}
@@ -64,3 +156,14 @@ func RandID() string {
 	}
 	return string(b)
 }
+
+// RandUUID returns a random RFC 4122 version 4 UUID string.
+func RandUUID() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(RandIntn(256))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,132 @@
+package mock
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/internal/config"
+)
+
+// unseed restores the lock-free source after a test or benchmark that called Seed.
+func unseed(tb testing.TB) {
+	tb.Cleanup(func() { seeded.Store(nil) })
+}
+
+func draws(n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = RandIntn(1000)
+	}
+	return out
+}
+
+func TestSeedReproducible(t *testing.T) {
+	unseed(t)
+	Seed(42)
+	first := draws(32)
+	id := RandID()
+	Seed(42)
+	if again := draws(32); !slices.Equal(first, again) || RandID() != id {
+		t.Fatalf("seeded draws differ: %v vs %v", first, again)
+	}
+}
+
+// TestNewRand checks a request's source repeats with its seed and leaves the shared one alone, and
+// that a nil one draws from the shared source.
+func TestNewRand(t *testing.T) {
+	unseed(t)
+	draw := func(r *Rand) []int {
+		out := []int{r.Intn(1000), r.PickTargetTokens(200, 10), r.JitterChunkSize(30)}
+		if r.Chance(0.5) {
+			out = append(out, 1)
+		}
+		return out
+	}
+	Seed(1)
+	first, shared := draw(NewRand(42)), draws(8)
+	Seed(1)
+	if again := draw(NewRand(42)); !slices.Equal(first, again) {
+		t.Fatalf("seeded draws differ: %v vs %v", first, again)
+	}
+	if again := draws(8); !slices.Equal(shared, again) {
+		t.Fatalf("NewRand drew from the shared source")
+	}
+
+	Seed(3)
+	want := draw(nil)
+	Seed(3)
+	if got := []int{RandIntn(1000), PickTargetTokens(200, 10), JitterChunkSize(30)}; !slices.Equal(got, want[:3]) {
+		t.Fatalf("a nil Rand drew %v, the shared source %v", want, got)
+	}
+	if Chance(0) || !Chance(1) {
+		t.Fatalf("Chance(0) must be false and Chance(1) true")
+	}
+}
+
+// TestRandConcurrent draws from many goroutines, unseeded and while Seed is called, for the race
+// detector (go test -race).
+func TestRandConcurrent(t *testing.T) {
+	unseed(t)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if n := RandIntn(10); n < 0 || n >= 10 {
+					t.Errorf("RandIntn(10) = %d", n)
+					return
+				}
+				if f := RandFloat64(); f < 0 || f >= 1 {
+					t.Errorf("RandFloat64() = %v", f)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		Seed(int64(i))
+	}
+	wg.Wait()
+}
+
+// BenchmarkRandIntn compares parallel draws from the lock-free default source with the seeded one,
+// which serializes on rngMu like every draw did before. Run with -cpu to see the contention grow.
+func BenchmarkRandIntn(b *testing.B) {
+	b.Run("unseeded", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				RandIntn(100)
+			}
+		})
+	})
+	b.Run("seeded", func(b *testing.B) {
+		unseed(b)
+		Seed(1)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				RandIntn(100)
+			}
+		})
+	})
+}
+
+// TestPickErrorStatusAliases checks every ERROR_MODE alias config accepts maps to a fixed status.
+func TestPickErrorStatusAliases(t *testing.T) {
+	for _, mode := range config.ErrorModes() {
+		want := map[config.ErrorKind]int{config.ErrorKindRateLimit: 429, config.ErrorKindInternal: 500}[config.ErrorModeKind(mode)]
+		if want == 0 {
+			continue // mixed
+		}
+		for _, m := range []string{mode, strings.ToUpper(mode)} {
+			for i := 0; i < 16; i++ {
+				if got := PickErrorStatus(m); got != want {
+					t.Fatalf("PickErrorStatus(%q) = %d, want %d", m, got, want)
+				}
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ type ChatRequest struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"messages"`
+	Sampling
 
 	// Optional overrides (편의)
 	Mock *Overrides `json:"mock,omitempty"`
@@ -18,8 +19,21 @@ type Overrides struct {
 	JitterMs        *int     `json:"jitter_ms,omitempty"`
 	PerTokenDelayMs *int     `json:"per_token_delay_ms,omitempty"`
 	ErrorRate       *float64 `json:"error_rate,omitempty"`
-	ErrorMode       *string  `json:"error_mode,omitempty"` // "429" | "500" | "mixed"
-	ChunkSize       *int     `json:"chunk_size,omitempty"` // chars per chunk
+	ErrorMode       *string  `json:"error_mode,omitempty"`   // "429" | "500" | "mixed"
+	ErrorTiming     *string  `json:"error_timing,omitempty"` // "pre" | "mid" | "mixed"
+	ChunkSize       *int     `json:"chunk_size,omitempty"`   // chars per chunk
+	TTFTMinMs       *int     `json:"ttft_min_ms,omitempty"`
+	TTFTMaxMs       *int     `json:"ttft_max_ms,omitempty"`
+	TokensPerSec    *float64 `json:"tokens_per_sec,omitempty"`
+}
+
+// Sampling holds the sampling params of a chat request, nil when not sent. Responses echo them in a
+// "sampling" block.
+type Sampling struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
 }
 
 type ChatResponse struct {
@@ -40,6 +54,11 @@ type ChatResponse struct {
 		CompletionTokens int `json:"completion_tokens"`
 		TotalTokens      int `json:"total_tokens"`
 	} `json:"usage"`
+	Sampling *Sampling `json:"sampling,omitempty"`
+	Debug    *Debug    `json:"debug,omitempty"`
+
+	// ClientRequestID echoes the request's x-client-request-id header (a simulator extension).
+	ClientRequestID string `json:"client_request_id,omitempty"`
 }
 
 // StreamChunk SSE chunk (OpenAI-ish)
@@ -56,4 +75,82 @@ type StreamChunk struct {
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+
+	// Timing and Output are simulator extensions set on the final chunk only.
+	Timing *StreamTiming `json:"timing,omitempty"`
+	Output *OutputDigest `json:"output,omitempty"`
+
+	// Send timestamps, with CHUNK_TIMESTAMPS only. FirstDeltaAtUnixMs is set on the final chunk.
+	EmittedAtUnixMs    int64 `json:"emitted_at_unix_ms,omitempty"`
+	FirstDeltaAtUnixMs int64 `json:"first_delta_at_unix_ms,omitempty"`
+
+	// The request's sampling params, on the final chunk when any was sent.
+	Sampling *Sampling `json:"sampling,omitempty"`
+
+	// Usage is set on the final chunk of ND-JSON streams, which have no headers to carry it.
+	Usage *StreamUsage `json:"usage,omitempty"`
+
+	// Debug is set on the final chunk of streams requested with debug.
+	Debug *Debug `json:"debug,omitempty"`
+
+	// ClientRequestID echoes the request's x-client-request-id header on every chunk.
+	ClientRequestID string `json:"client_request_id,omitempty"`
+}
+
+// Debug echoes the simulation parameters resolved for one request that asked for them (x-debug
+// metadata on gRPC, ?debug=1 on HTTP): the preset, the delays drawn, the chunk size used and the
+// output length picked, and which sources changed the config it ran with.
+type Debug struct {
+	Preset       string   `json:"preset"`
+	BaseDelayMs  int      `json:"base_delay_ms"`
+	JitterMs     int      `json:"jitter_ms"`            // drawn from [0, JITTER_MS]
+	TTFTMs       int      `json:"ttft_ms"`              // drawn from [TTFT_MIN_MS, TTFT_MAX_MS]
+	ChunkSize    int      `json:"chunk_size,omitempty"` // streams only
+	MaxTokens    int      `json:"max_tokens"`
+	TargetTokens int      `json:"target_tokens"`       // PickTargetTokens with RANDOMIZE, else MaxTokens
+	Overrides    []string `json:"overrides,omitempty"` // admin, fault, header, request, replay
+	Priority     string   `json:"priority,omitempty"`  // x-priority as applied: high, normal or low
+	Tenant       string   `json:"tenant,omitempty"`    // the TENANT_PROFILES profile applied
+
+	InvalidOverrides []string `json:"invalid_overrides,omitempty"` // x-mock-* values ignored, as "key=value"
+}
+
+// StreamUsage is the token usage of a stream, on its final chunk.
+type StreamUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// StreamTiming is the measured per-phase timing breakdown of a stream, mirroring the gRPC done chunk.
+type StreamTiming struct {
+	QueueMs      int64 `json:"queue_ms"`
+	PromptEvalMs int64 `json:"prompt_eval_ms"`
+	TTFTMs       int64 `json:"ttft_ms"`
+	GenerationMs int64 `json:"generation_ms"`
+}
+
+// OutputDigest describes the full streamed content, so a consumer can verify its reassembly of the
+// deltas without the server logging the output.
+type OutputDigest struct {
+	SHA256 string `json:"sha256"` // hex SHA-256 of the concatenated content deltas
+	Bytes  int    `json:"bytes"`
+}
+
+// ErrorResponse is the OpenAI-style error body.
+type ErrorResponse struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Param   *string `json:"param"`
+		Code    *string `json:"code"`
+
+		// Injected marks errors the simulator injected on purpose (simulator extension).
+		Injected bool `json:"injected,omitempty"`
+	} `json:"error"`
+
+	// Usage and LatencyMs are what a stream that failed mid-way delivered, with the completion tokens
+	// counted from the deltas sent (simulator extension; streams only).
+	Usage     *StreamUsage `json:"usage,omitempty"`
+	LatencyMs int64        `json:"latency_ms,omitempty"`
 }
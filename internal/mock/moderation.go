@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ModerationCategories lists the categories of the OpenAI moderation models, in the order
+// their results report them.
+var ModerationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/intent",
+	"self-harm/instructions",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+// ModerationRequest is the OpenAI moderations request. Input may be a string, a list of strings or
+// a list of {"type":"text","text":...} parts.
+type ModerationRequest struct {
+	Model string          `json:"model,omitempty"`
+	Input json.RawMessage `json:"input"`
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+// ModerationResult is the verdict on one input; Categories and CategoryScores have an entry for
+// every category in ModerationCategories.
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// ModerationInputs returns the inputs of a moderations request in order. Non-text parts are skipped.
+func ModerationInputs(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("input is required")
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []string{s}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		if len(list) == 0 {
+			return nil, errors.New("input must not be empty")
+		}
+		return list, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, errors.New("input must be a string, a list of strings or a list of input parts")
+	}
+	var inputs []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			inputs = append(inputs, p.Text)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("input has no text parts")
+	}
+	return inputs, nil
+}
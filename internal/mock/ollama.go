@@ -0,0 +1,53 @@
+package mock
+
+// OllamaOptions is the subset of Ollama model options the simulator honors.
+type OllamaOptions struct {
+	NumPredict int `json:"num_predict,omitempty"`
+}
+
+// OllamaChatRequest is the /api/chat request body. Stream defaults to true when omitted.
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   *bool           `json:"stream,omitempty"`
+	Options  OllamaOptions   `json:"options"`
+}
+
+// OllamaGenerateRequest is the /api/generate request body. Stream defaults to true when omitted.
+type OllamaGenerateRequest struct {
+	Model   string        `json:"model"`
+	Prompt  string        `json:"prompt"`
+	System  string        `json:"system,omitempty"`
+	Stream  *bool         `json:"stream,omitempty"`
+	Options OllamaOptions `json:"options"`
+}
+
+type OllamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OllamaResponse is a single NDJSON line for both endpoints: /api/chat fills Message,
+// /api/generate fills Response. The final (done) line carries the timing and count fields,
+// with durations in nanoseconds.
+type OllamaResponse struct {
+	Model     string         `json:"model"`
+	CreatedAt string         `json:"created_at"`
+	Message   *OllamaMessage `json:"message,omitempty"`
+	Response  *string        `json:"response,omitempty"`
+	Done      bool           `json:"done"`
+
+	DoneReason         string `json:"done_reason,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"`
+	LoadDuration       int64  `json:"load_duration,omitempty"`
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+}
+
+// OllamaError is the error body Ollama returns with non-2xx statuses.
+type OllamaError struct {
+	Error    string `json:"error"`
+	Injected bool   `json:"injected,omitempty"` // simulator extension, see ErrorResponse
+}
@@ -1,12 +1,37 @@
 package mock
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
 
 // BuildOutput generates a mock completion string using the same sizing rules as the gRPC simulator.
 // - If strictTokenMode is true, length is based on maxTokens (~4 chars per token).
 // - debugChars can force a fixed size when non-zero.
 // - maxChars caps the output length when positive.
 func BuildOutput(prompt string, maxTokens int, echoPrompt bool, strictTokenMode bool, debugChars int, maxChars int) string {
+	target := outputTarget(maxTokens, strictTokenMode, debugChars, maxChars)
+	head := outputHead(prompt, echoPrompt)
+	if len(head) >= target {
+		return head[:target]
+	}
+
+	var b strings.Builder
+	b.Grow(target + len(outputFiller))
+	b.WriteString(head)
+	for b.Len() < target {
+		b.WriteString(outputFiller)
+	}
+	return b.String()[:target]
+}
+
+// outputFiller is repeated after the head of the output up to its target length.
+const outputFiller = "[mock-token] "
+
+// outputTarget returns the output length in bytes under the BuildOutput sizing rules.
+func outputTarget(maxTokens int, strictTokenMode bool, debugChars int, maxChars int) int {
 	target := debugChars
 	if target == 0 {
 		target = 512
@@ -20,27 +45,26 @@ func BuildOutput(prompt string, maxTokens int, echoPrompt bool, strictTokenMode
 	if target < 64 {
 		target = 64
 	}
-	cap := maxChars
-	if cap == 0 {
-		cap = 4096
+	limit := maxChars
+	if limit == 0 {
+		limit = 4096
 	}
-	if cap > 0 && target > cap {
-		target = cap
+	if limit > 0 && target > limit {
+		target = limit
 	}
+	return target
+}
 
+// outputHead returns the fixed start of the output: the echoed prompt, if any, and the intro lines.
+func outputHead(prompt string, echoPrompt bool) string {
 	prefix := ""
 	if echoPrompt {
 		p := trim(prompt, 140)
 		prefix = fmt.Sprintf("Mock answer for: %q\n\n", p)
 	}
-	s := prefix +
+	return prefix +
 		"This is a server-streaming mock response for benchmarking Kafka/worker throughput. \n" +
 		"It simulates latency, errors, and chunked deltas. \n"
-
-	for len(s) < target {
-		s += "[mock-token] "
-	}
-	return s[:target]
 }
 
 // ApproxTokens provides a rough token estimate (4 runes ~= 1 token).
@@ -51,3 +75,9 @@ func ApproxTokens(s string) int {
 	r := len([]rune(s))
 	return (r + 3) / 4
 }
+
+// Digest returns the OutputDigest of content, the concatenation of a stream's content deltas.
+func Digest(content string) *OutputDigest {
+	sum := sha256.Sum256([]byte(content))
+	return &OutputDigest{SHA256: hex.EncodeToString(sum[:]), Bytes: len(content)}
+}
@@ -0,0 +1,67 @@
+package mock
+
+import "testing"
+
+// buildOutputConcat is BuildOutput as it was, growing the string one filler at a time; kept as the
+// baseline of BenchmarkBuildOutput.
+func buildOutputConcat(prompt string, maxTokens int, echoPrompt bool, strictTokenMode bool, debugChars int, maxChars int) string {
+	target := outputTarget(maxTokens, strictTokenMode, debugChars, maxChars)
+	s := outputHead(prompt, echoPrompt)
+	for len(s) < target {
+		s += outputFiller
+	}
+	return s[:target]
+}
+
+// TestBuildOutputGolden pins BuildOutput byte for byte: length and SHA-256 of a few sizings.
+func TestBuildOutputGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		prompt     string
+		maxTokens  int
+		echo       bool
+		strict     bool
+		debugChars int
+		maxChars   int
+		wantLen    int
+		wantSHA256 string
+	}{
+		{"default size", "hi", 0, false, false, 0, 0, 512, "2e90d700f78c31277c35d35de095cd6fc57395b3f7fd7a1e7240333ff1b7af7d"},
+		{"minimum size", "hi", 10, false, true, 0, 0, 64, "b3495adb99782b48ce8c317bd5a5381dc4241a8b83a4bf94322599717e965839"},
+		{"capped", "hi", 5000, false, true, 0, 16384, 16384, "22e7a9f70251d7553dfc85e30562f15f638deda33627b5dbd125e7c29ef44328"},
+		{"uncapped", "hi", 5000, false, true, 0, -1, 20000, "e9233f1910585fd445a0c3a7b27d0bf62c0ef1ae5684d74c84963309d4a23f87"},
+		{"echoed prompt", "héllo \"wörld\"", 50, true, true, 0, 0, 200, "7c375241b31634d96a999fdda3b16ec5e4323df7a6166f030e8d285b1f3bf3d5"},
+		{"debug size", "x", 0, true, false, 100, 0, 100, "b19cbaf90968c2e128d281e2ee29613bd4b106a030a8a1a7b87746d36ad47cc8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildOutput(tt.prompt, tt.maxTokens, tt.echo, tt.strict, tt.debugChars, tt.maxChars)
+			if d := Digest(got); d.Bytes != tt.wantLen || d.SHA256 != tt.wantSHA256 {
+				t.Fatalf("got %d bytes %s, want %d bytes %s", d.Bytes, d.SHA256, tt.wantLen, tt.wantSHA256)
+			}
+			if want := buildOutputConcat(tt.prompt, tt.maxTokens, tt.echo, tt.strict, tt.debugChars, tt.maxChars); got != want {
+				t.Fatalf("differs from the concatenating build")
+			}
+		})
+	}
+
+	const want = "This is a server-streaming mock response for benchmarking Kafka/"
+	if got := BuildOutput("hi", 10, false, true, 0, 0); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func BenchmarkBuildOutput(b *testing.B) {
+	b.Run("concat", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			buildOutputConcat("bench", 4096, false, true, 0, 16384)
+		}
+	})
+	b.Run("builder", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			BuildOutput("bench", 4096, false, true, 0, 16384)
+		}
+	})
+}
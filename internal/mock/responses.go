@@ -0,0 +1,96 @@
+package mock
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ResponsesRequest is the subset of the OpenAI Responses API request the simulator understands.
+// Input may be a plain string or a list of role/content messages.
+type ResponsesRequest struct {
+	Model           string          `json:"model"`
+	Input           json.RawMessage `json:"input"`
+	Instructions    string          `json:"instructions,omitempty"`
+	MaxOutputTokens int             `json:"max_output_tokens,omitempty"`
+	Stream          bool            `json:"stream"`
+}
+
+// ResponsesInputMessage is one entry of a list-form input. Content may be a string or a list of parts.
+type ResponsesInputMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type ResponseObject struct {
+	ID        string               `json:"id"`
+	Object    string               `json:"object"`
+	CreatedAt int64                `json:"created_at"`
+	Status    string               `json:"status"`
+	Model     string               `json:"model"`
+	Output    []ResponseOutputItem `json:"output"`
+	Usage     *ResponseUsage       `json:"usage"`
+	Error     *ResponseError       `json:"error"`
+}
+
+type ResponseOutputItem struct {
+	ID      string                `json:"id"`
+	Type    string                `json:"type"`
+	Status  string                `json:"status"`
+	Role    string                `json:"role"`
+	Content []ResponseContentPart `json:"content"`
+}
+
+type ResponseContentPart struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	Annotations []any  `json:"annotations"`
+}
+
+type ResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type ResponseError struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Injected bool   `json:"injected,omitempty"` // simulator extension, see ErrorResponse
+}
+
+// ResponsesEvent is a single typed event of a Responses API stream. Which fields are set depends on
+// Type; index fields are pointers so they are omitted from events that do not carry them.
+type ResponsesEvent struct {
+	Type           string               `json:"type"`
+	SequenceNumber int                  `json:"sequence_number"`
+	Response       *ResponseObject      `json:"response,omitempty"`
+	OutputIndex    *int                 `json:"output_index,omitempty"`
+	ContentIndex   *int                 `json:"content_index,omitempty"`
+	ItemID         string               `json:"item_id,omitempty"`
+	Item           *ResponseOutputItem  `json:"item,omitempty"`
+	Part           *ResponseContentPart `json:"part,omitempty"`
+	Delta          *string              `json:"delta,omitempty"`
+	Text           *string              `json:"text,omitempty"`
+}
+
+// ResponsesContentText flattens a string-or-parts content value into plain text.
+func ResponsesContentText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		texts = append(texts, p.Text)
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,104 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputStream yields the text BuildOutput would return in chunks, generating each on demand, so a
+// stream holds its head and one chunk's worth of filler instead of the whole output. The digest of
+// the chunks is accumulated as they are taken.
+type OutputStream struct {
+	head   string
+	target int
+	pos    int
+	run    string // outputFiller repeated, long enough for one chunk at any phase
+	sum    hash.Hash
+	buf    []byte // reused to feed sum
+}
+
+// NewOutputStream returns a stream of the output BuildOutput builds for the same arguments.
+func NewOutputStream(prompt string, maxTokens int, echoPrompt bool, strictTokenMode bool, debugChars int, maxChars int) *OutputStream {
+	return &OutputStream{
+		head:   outputHead(prompt, echoPrompt),
+		target: outputTarget(maxTokens, strictTokenMode, debugChars, maxChars),
+		sum:    sha256.New(),
+	}
+}
+
+// NewEndlessOutputStream returns a stream of the output head followed by filler that never runs out,
+// for streams that only end when the client cancels. Truncate it before reading its Len or Tokens.
+func NewEndlessOutputStream(prompt string, echoPrompt bool) *OutputStream {
+	return &OutputStream{
+		head:   outputHead(prompt, echoPrompt),
+		target: math.MaxInt,
+		sum:    sha256.New(),
+	}
+}
+
+// Len returns the length of the whole output in bytes.
+func (o *OutputStream) Len() int { return o.target }
+
+// Tokens returns ApproxTokens of the whole output, computed from its length: the filler is ASCII, so
+// only the head needs counting.
+func (o *OutputStream) Tokens() int {
+	return o.TokensOf(o.target)
+}
+
+// TokensOf returns ApproxTokens of the first n bytes of the output, e.g. of the chunks a failed
+// stream delivered.
+func (o *OutputStream) TokensOf(n int) int {
+	n = min(n, o.target)
+	runes := utf8.RuneCountInString(o.head[:min(len(o.head), n)]) + max(n-len(o.head), 0)
+	return (runes + 3) / 4
+}
+
+// Remaining returns the bytes of the output not returned yet.
+func (o *OutputStream) Remaining() int { return o.target - o.pos }
+
+// Next returns the next n bytes of the output (fewer at the end), or false once it is exhausted. Like
+// slicing the built output, a chunk boundary can split a multi-byte rune of an echoed prompt.
+func (o *OutputStream) Next(n int) (string, bool) {
+	if o.pos >= o.target || n <= 0 {
+		return "", false
+	}
+	end := min(o.pos+n, o.target)
+	var s string
+	switch {
+	case end <= len(o.head):
+		s = o.head[o.pos:end]
+	case o.pos < len(o.head):
+		s = o.head[o.pos:] + o.filler(len(o.head), end)
+	default:
+		s = o.filler(o.pos, end)
+	}
+	o.pos = end
+	o.buf = append(o.buf[:0], s...)
+	o.sum.Write(o.buf)
+	return s, true
+}
+
+// filler returns output bytes [from, to), both past the head.
+func (o *OutputStream) filler(from, to int) string {
+	off := (from - len(o.head)) % len(outputFiller)
+	if need := off + to - from; len(o.run) < need {
+		o.run = strings.Repeat(outputFiller, need/len(outputFiller)+1)
+	}
+	return o.run[off : off+to-from]
+}
+
+// Truncate ends the output at the chunks returned so far, for a stream cut short: Len, Tokens and
+// Digest then describe what was sent.
+func (o *OutputStream) Truncate() {
+	o.target = o.pos
+}
+
+// Digest returns the OutputDigest of the chunks returned so far; the same as Digest of the whole
+// output once the stream is exhausted.
+func (o *OutputStream) Digest() *OutputDigest {
+	return &OutputDigest{SHA256: hex.EncodeToString(o.sum.Sum(nil)), Bytes: o.pos}
+}
@@ -0,0 +1,98 @@
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestOutputStreamMatchesBuildOutput verifies the chunks of an OutputStream add up to BuildOutput,
+// with the same token count and digest, for chunk sizes that split the head, the filler and runes.
+func TestOutputStreamMatchesBuildOutput(t *testing.T) {
+	sizings := []struct {
+		name      string
+		prompt    string
+		maxTokens int
+		echo      bool
+		maxChars  int
+	}{
+		{"short", "hi", 10, false, 0},
+		{"long", "hi", 5000, false, -1},
+		{"echoed prompt", "héllo \"wörld\"", 50, true, 0},
+		{"head only", strings.Repeat("é", 200), 20, true, 0},
+	}
+	for _, sz := range sizings {
+		want := BuildOutput(sz.prompt, sz.maxTokens, sz.echo, true, 0, sz.maxChars)
+		for _, n := range []int{1, 7, 13, 64, 5000} {
+			t.Run(fmt.Sprintf("%s/chunk=%d", sz.name, n), func(t *testing.T) {
+				o := NewOutputStream(sz.prompt, sz.maxTokens, sz.echo, true, 0, sz.maxChars)
+				if o.Len() != len(want) || o.Tokens() != ApproxTokens(want) {
+					t.Fatalf("len %d tokens %d, want %d and %d", o.Len(), o.Tokens(), len(want), ApproxTokens(want))
+				}
+				var b strings.Builder
+				for {
+					s, ok := o.Next(n)
+					if !ok {
+						break
+					}
+					if len(s) > n {
+						t.Fatalf("chunk of %d bytes exceeds %d", len(s), n)
+					}
+					b.WriteString(s)
+				}
+				if b.String() != want {
+					t.Fatalf("reassembled output differs\ngot  %q\nwant %q", b.String(), want)
+				}
+				if got, wantDigest := o.Digest(), Digest(want); *got != *wantDigest {
+					t.Fatalf("digest %+v, want %+v", got, wantDigest)
+				}
+			})
+		}
+	}
+}
+
+func TestOutputStreamTruncate(t *testing.T) {
+	o := NewOutputStream("hi", 100, false, true, 0, 0)
+	head, _ := o.Next(10)
+	o.Truncate()
+	if _, ok := o.Next(10); ok {
+		t.Fatal("a truncated stream returned another chunk")
+	}
+	if o.Len() != len(head) || o.Tokens() != ApproxTokens(head) || *o.Digest() != *Digest(head) {
+		t.Fatalf("truncated stream describes %d bytes, %d tokens, want %q", o.Len(), o.Tokens(), head)
+	}
+}
+
+func TestOutputStreamTokensOf(t *testing.T) {
+	full := BuildOutput("héllo wörld", 64, true, true, 0, 0)
+	o := NewOutputStream("héllo wörld", 64, true, true, 0, 0)
+	for _, n := range []int{0, 1, 5, 17, len(full) / 2, len(full)} {
+		if got, want := o.TokensOf(n), ApproxTokens(full[:n]); got != want {
+			t.Fatalf("TokensOf(%d) = %d, want %d", n, got, want)
+		}
+	}
+	if o.TokensOf(len(full)+10) != o.Tokens() {
+		t.Fatalf("TokensOf past the end = %d, want Tokens %d", o.TokensOf(len(full)+10), o.Tokens())
+	}
+}
+
+// BenchmarkOutputMemory compares the memory a stream needs for its output: BuildOutput grows with
+// the output length, an OutputStream drained in chunks does not.
+func BenchmarkOutputMemory(b *testing.B) {
+	for _, tokens := range []int{1024, 32768} {
+		b.Run(fmt.Sprintf("BuildOutput/tokens=%d", tokens), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				BuildOutput("bench", tokens, false, true, 0, -1)
+			}
+		})
+		b.Run(fmt.Sprintf("OutputStream/tokens=%d", tokens), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				o := NewOutputStream("bench", tokens, false, true, 0, -1)
+				for _, ok := o.Next(16); ok; _, ok = o.Next(16) {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package mock
+
+// WSFrame is a server message on the /v1/chat/ws stream. Type is "delta" (Text set), "done"
+// (FinishReason and Usage set), "canceled" or "error" (Error set). Seq increases by one per frame.
+type WSFrame struct {
+	Type         string        `json:"type"`
+	Seq          int           `json:"seq"`
+	Text         string        `json:"text,omitempty"`
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Usage        *WSUsage      `json:"usage,omitempty"`
+	Timing       *StreamTiming `json:"timing,omitempty"`
+	Error        *WSError      `json:"error,omitempty"`
+}
+
+type WSUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type WSError struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Injected bool   `json:"injected,omitempty"` // simulator extension, see ErrorResponse
+}
+
+// WSClientMessage is a control message sent by the client after the initial ChatRequest.
+type WSClientMessage struct {
+	Type string `json:"type"` // "cancel"
+}
@@ -0,0 +1,195 @@
+// Package ratelimit provides the in-memory sliding-window limiter used for per-key and per-peer
+// request/token quotas.
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Window is the sliding window both limits are measured over.
+const Window = time.Minute
+
+// Limiter enforces requests-per-minute and tokens-per-minute budgets per key over a sliding window.
+// It is safe for concurrent use. A nil *Limiter allows everything.
+type Limiter struct {
+	rpm int
+	tpm int
+	now func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*usage
+	lastSweep time.Time
+
+	allowed  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+type tokenEvent struct {
+	at time.Time
+	n  int
+}
+
+// usage is the in-window history of one key.
+type usage struct {
+	requests []time.Time
+	tokens   []tokenEvent
+	tokenSum int
+	lastSeen time.Time
+}
+
+// Decision is the outcome of Allow, including the values reported in rate limit headers.
+type Decision struct {
+	Allowed bool
+	// Reason is "requests" or "tokens" when the request was rejected.
+	Reason string
+	// RetryAfter is how long until the exhausted budget frees up (0 when allowed).
+	RetryAfter time.Duration
+
+	RequestLimit      int
+	RequestsRemaining int
+	RequestsReset     time.Duration
+	TokenLimit        int
+	TokensRemaining   int
+	TokensReset       time.Duration
+}
+
+// Stats are cumulative counters for the stats/metrics endpoints.
+type Stats struct {
+	Keys     int
+	Allowed  uint64
+	Rejected uint64
+}
+
+// New returns a limiter allowing rpm requests and tpm tokens per key per minute. A zero limit disables
+// that dimension; New returns nil when both are zero.
+func New(rpm, tpm int) *Limiter {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	return &Limiter{rpm: rpm, tpm: tpm, now: time.Now, keys: make(map[string]*usage)}
+}
+
+// Allow records a request for key and reports whether it fits the budgets. Rejected requests are
+// not recorded. Tokens are charged separately with Charge once the request completes.
+func (l *Limiter) Allow(key string) Decision {
+	if l == nil {
+		return Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	u := l.keys[key]
+	if u == nil {
+		u = &usage{}
+		l.keys[key] = u
+	}
+	u.lastSeen = now
+	u.prune(now)
+
+	d := Decision{Allowed: true, RequestLimit: l.rpm, TokenLimit: l.tpm}
+	if l.rpm > 0 {
+		if len(u.requests) > 0 {
+			d.RequestsReset = u.requests[0].Add(Window).Sub(now)
+		}
+		if len(u.requests) >= l.rpm {
+			// Free once enough old requests leave the window to make room for one more.
+			d.Allowed, d.Reason = false, "requests"
+			d.RetryAfter = u.requests[len(u.requests)-l.rpm].Add(Window).Sub(now)
+		}
+	}
+	if l.tpm > 0 {
+		if len(u.tokens) > 0 {
+			d.TokensReset = u.tokens[0].at.Add(Window).Sub(now)
+		}
+		d.TokensRemaining = max(l.tpm-u.tokenSum, 0)
+		if d.Allowed && u.tokenSum >= l.tpm {
+			d.Allowed, d.Reason = false, "tokens"
+			// Free once the window sum drops below the limit.
+			sum := u.tokenSum
+			for _, ev := range u.tokens {
+				sum -= ev.n
+				if sum < l.tpm {
+					d.RetryAfter = ev.at.Add(Window).Sub(now)
+					break
+				}
+			}
+		}
+	}
+
+	if !d.Allowed {
+		l.rejected.Add(1)
+		d.RequestsRemaining = max(l.rpm-len(u.requests), 0)
+		return d
+	}
+	l.allowed.Add(1)
+	u.requests = append(u.requests, now)
+	d.RequestsRemaining = max(l.rpm-len(u.requests), 0)
+	return d
+}
+
+// Charge records tokens consumed by a completed request for key.
+func (l *Limiter) Charge(key string, tokens int) {
+	if l == nil || l.tpm <= 0 || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	u := l.keys[key]
+	if u == nil {
+		u = &usage{}
+		l.keys[key] = u
+	}
+	u.lastSeen = now
+	u.prune(now)
+	u.tokens = append(u.tokens, tokenEvent{at: now, n: tokens})
+	u.tokenSum += tokens
+}
+
+// Stats returns cumulative allowed/rejected counts and the number of tracked keys.
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	l.mu.Lock()
+	keys := len(l.keys)
+	l.mu.Unlock()
+	return Stats{Keys: keys, Allowed: l.allowed.Load(), Rejected: l.rejected.Load()}
+}
+
+// sweep forgets keys that have not been seen for a full window (their history is empty by then), so
+// peers or keys that go quiet do not accumulate over a long soak. It runs at most once per window.
+// Callers must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < Window {
+		return
+	}
+	l.lastSweep = now
+	for k, u := range l.keys {
+		if now.Sub(u.lastSeen) >= Window {
+			delete(l.keys, k)
+		}
+	}
+}
+
+// prune drops events that fell out of the window.
+func (u *usage) prune(now time.Time) {
+	cutoff := now.Add(-Window)
+	i := 0
+	for i < len(u.requests) && !u.requests[i].After(cutoff) {
+		i++
+	}
+	u.requests = u.requests[i:]
+
+	i = 0
+	for i < len(u.tokens) && !u.tokens[i].at.After(cutoff) {
+		u.tokenSum -= u.tokens[i].n
+		i++
+	}
+	u.tokens = u.tokens[i:]
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose clock is advanced manually through the returned pointer.
+func newTestLimiter(rpm, tpm int) (*Limiter, *time.Time) {
+	l := New(rpm, tpm)
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	l, now := newTestLimiter(3, 0)
+
+	for i := 0; i < 3; i++ {
+		if d := l.Allow("a"); !d.Allowed || d.RequestsRemaining != 2-i {
+			t.Fatalf("request %d rejected or wrong remaining: %+v", i+1, d)
+		}
+		*now = now.Add(10 * time.Second)
+	}
+	d := l.Allow("a")
+	if d.Allowed || d.Reason != "requests" {
+		t.Fatalf("4th request should be rejected: %+v", d)
+	}
+	// The first request (30s ago) leaves the window in 30s.
+	if d.RetryAfter != 30*time.Second {
+		t.Fatalf("unexpected retry-after: %v", d.RetryAfter)
+	}
+	if d := l.Allow("b"); !d.Allowed {
+		t.Fatalf("second key should be unaffected: %+v", d)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if d := l.Allow("a"); !d.Allowed {
+		t.Fatalf("request should be allowed once the window slides: %+v", d)
+	}
+	if s := l.Stats(); s.Keys != 2 || s.Allowed != 5 || s.Rejected != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	l, now := newTestLimiter(0, 100)
+
+	if d := l.Allow("a"); !d.Allowed {
+		t.Fatalf("first request rejected: %+v", d)
+	}
+	l.Charge("a", 60)
+	*now = now.Add(20 * time.Second)
+	if d := l.Allow("a"); !d.Allowed || d.TokensRemaining != 40 {
+		t.Fatalf("second request rejected or wrong remaining: %+v", d)
+	}
+	l.Charge("a", 50)
+
+	d := l.Allow("a")
+	if d.Allowed || d.Reason != "tokens" {
+		t.Fatalf("request over TPM should be rejected: %+v", d)
+	}
+	// Dropping the first 60-token charge (in 40s) brings the sum below the limit.
+	if d.RetryAfter != 40*time.Second {
+		t.Fatalf("unexpected retry-after: %v", d.RetryAfter)
+	}
+	if d := l.Allow("b"); !d.Allowed {
+		t.Fatalf("second key should be unaffected: %+v", d)
+	}
+}
+
+func TestNilLimiterAllows(t *testing.T) {
+	var l *Limiter
+	if New(0, 0) != nil {
+		t.Fatalf("New with no limits should return nil")
+	}
+	if d := l.Allow("a"); !d.Allowed {
+		t.Fatalf("nil limiter should allow")
+	}
+	l.Charge("a", 10)
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	l := New(50, 0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Allow("hot").Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 50 {
+		t.Fatalf("expected exactly 50 allowed, got %d", allowed)
+	}
+}
+
+func TestQuietKeysAreForgotten(t *testing.T) {
+	l, now := newTestLimiter(5, 0)
+	for _, k := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		l.Allow(k)
+	}
+	if s := l.Stats(); s.Keys != 3 {
+		t.Fatalf("expected 3 tracked keys, got %d", s.Keys)
+	}
+
+	*now = now.Add(Window + time.Second)
+	l.Allow("10.0.0.4")
+	if s := l.Stats(); s.Keys != 1 {
+		t.Fatalf("quiet keys should be dropped after a window, still tracking %d", s.Keys)
+	}
+}
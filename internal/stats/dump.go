@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Dumper turns snapshots into the key/value pairs of the SIGUSR1 log line. It remembers the
+// previous dump so it can report token throughput since then.
+type Dumper struct {
+	start time.Time
+
+	mu         sync.Mutex
+	last       time.Time
+	lastTokens int64
+}
+
+// NewDumper returns a Dumper measuring uptime and the first throughput window from start.
+func NewDumper(start time.Time) *Dumper {
+	return &Dumper{start: start, last: start}
+}
+
+// Fields returns structured log fields for s taken at now: uptime, total requests, counts by status
+// code across all RPCs, active streams, injected errors, and tokens/s since the previous call.
+func (d *Dumper) Fields(s Snapshot, now time.Time) []any {
+	var requests int64
+	codes := map[string]int64{}
+	for _, r := range s.RPCs {
+		requests += r.Requests
+		for code, n := range r.Codes {
+			codes[code] += n
+		}
+	}
+
+	d.mu.Lock()
+	tokens := s.TotalTokens - d.lastTokens
+	if tokens < 0 {
+		// The counters were reset since the last dump.
+		tokens = s.TotalTokens
+	}
+	var tokensPerSec float64
+	if elapsed := now.Sub(d.last).Seconds(); elapsed > 0 {
+		tokensPerSec = float64(tokens) / elapsed
+	}
+	d.last, d.lastTokens = now, s.TotalTokens
+	d.mu.Unlock()
+
+	return []any{
+		"uptimeMs", now.Sub(d.start).Milliseconds(),
+		"statsSinceMs", s.SinceMs,
+		"requests", requests,
+		"codes", codes,
+		"activeStreams", s.ActiveStreams,
+		"injectedErrors", s.InjectedErrors,
+		"totalTokens", s.TotalTokens,
+		"tokensPerSec", tokensPerSec,
+		"p50Ms", s.Latency.P50Ms,
+		"p99Ms", s.Latency.P99Ms,
+	}
+}
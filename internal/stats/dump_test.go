@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+// fieldMap turns Dumper.Fields output into a map for assertions.
+func fieldMap(t *testing.T, kv []any) map[string]any {
+	t.Helper()
+	if len(kv)%2 != 0 {
+		t.Fatalf("odd number of fields: %v", kv)
+	}
+	m := make(map[string]any, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		m[kv[i].(string)] = kv[i+1]
+	}
+	return m
+}
+
+func TestDumperFields(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	d := NewDumper(start)
+	s := Snapshot{
+		RPCs: map[string]RPCStats{
+			"ChatCompletion":            {Requests: 3, Codes: map[string]int64{"OK": 2, "Internal": 1}},
+			"POST /v1/chat/completions": {Requests: 2, Codes: map[string]int64{"200": 2}},
+		},
+		ActiveStreams: 4,
+		TotalTokens:   100,
+	}
+
+	f := fieldMap(t, d.Fields(s, start.Add(10*time.Second)))
+	codes := f["codes"].(map[string]int64)
+	if f["uptimeMs"] != int64(10_000) || f["requests"] != int64(5) || f["activeStreams"] != int64(4) ||
+		codes["OK"] != 2 || codes["Internal"] != 1 || codes["200"] != 2 {
+		t.Fatalf("unexpected fields: %v", f)
+	}
+	if f["tokensPerSec"] != 10.0 {
+		t.Fatalf("expected 10 tokens/s, got %v", f["tokensPerSec"])
+	}
+
+	// Throughput only covers the window since the previous dump.
+	s.TotalTokens = 160
+	if f = fieldMap(t, d.Fields(s, start.Add(40*time.Second))); f["tokensPerSec"] != 2.0 {
+		t.Fatalf("expected 2 tokens/s, got %v", f["tokensPerSec"])
+	}
+
+	// After a reset the counter restarts from zero.
+	s.TotalTokens = 50
+	if f = fieldMap(t, d.Fields(s, start.Add(50*time.Second))); f["tokensPerSec"] != 5.0 {
+		t.Fatalf("expected 5 tokens/s after reset, got %v", f["tokensPerSec"])
+	}
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+// window counts the requests of the current interval, in the same reservoirs as the totals.
+type window struct {
+	since            time.Time
+	requests, errors int64
+	latency, ttft    *reservoir
+}
+
+func newWindow() window {
+	return window{since: time.Now(), latency: newReservoir(reservoirSize), ttft: newReservoir(reservoirSize)}
+}
+
+// Interval is the traffic between two TakeInterval calls. Snapshot resets don't affect it.
+type Interval struct {
+	Duration time.Duration
+	Requests int64
+	Errors   int64
+	Latency  Latency
+	TTFT     Latency
+}
+
+// TakeInterval returns the traffic since the previous call (or New) and starts a new interval.
+func (c *Collector) TakeInterval() Interval {
+	c.mu.Lock()
+	w := c.window
+	c.window = newWindow()
+	c.mu.Unlock()
+	return Interval{
+		Duration: time.Since(w.since),
+		Requests: w.requests,
+		Errors:   w.errors,
+		Latency:  w.latency.percentiles(),
+		TTFT:     w.ttft.percentiles(),
+	}
+}
+
+// Fields returns structured log fields for the interval.
+func (iv Interval) Fields() []any {
+	return []any{
+		"intervalMs", iv.Duration.Milliseconds(),
+		"requests", iv.Requests,
+		"errors", iv.Errors,
+		"latencyP50Ms", iv.Latency.P50Ms,
+		"latencyP95Ms", iv.Latency.P95Ms,
+		"latencyP99Ms", iv.Latency.P99Ms,
+		"ttftP50Ms", iv.TTFT.P50Ms,
+		"ttftP95Ms", iv.TTFT.P95Ms,
+		"ttftP99Ms", iv.TTFT.P99Ms,
+	}
+}
+
+// LogIntervals logs a summary of every interval of length every until ctx is done
+// (STATS_LOG_INTERVAL_S), for environments that don't scrape /metrics.
+func (c *Collector) LogIntervals(ctx context.Context, every time.Duration) {
+	c.TakeInterval() // the first interval starts now
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.logInterval()
+		}
+	}
+}
+
+// logInterval logs the interval since the previous call, unless it had no traffic.
+func (c *Collector) logInterval() {
+	iv := c.TakeInterval()
+	if iv.Requests == 0 && iv.TTFT.Samples == 0 {
+		return
+	}
+	logger.Log.Infow("[stats] interval", iv.Fields()...)
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yungtweek/llm-simulator/internal/logger"
+)
+
+func TestLogInterval(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	prev := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	t.Cleanup(func() { logger.Log = prev })
+
+	c := New()
+	c.logInterval()
+	if logs.Len() != 0 {
+		t.Fatal("logged an interval without traffic")
+	}
+
+	for i := 1; i <= 20; i++ {
+		c.Request("ChatCompletionStream", "OK", i != 20, time.Duration(i)*10*time.Millisecond)
+		c.TTFT(time.Duration(i) * time.Millisecond)
+	}
+	c.Snapshot(true) // resets don't touch the interval
+	c.logInterval()
+	if logs.Len() != 1 {
+		t.Fatalf("expected one interval line, got %d", logs.Len())
+	}
+	f := logs.All()[0].ContextMap()
+	if f["requests"] != int64(20) || f["errors"] != int64(1) {
+		t.Fatalf("unexpected counts: %v", f)
+	}
+	if f["latencyP50Ms"] != 100.0 || f["latencyP95Ms"] != 190.0 || f["latencyP99Ms"] != 200.0 ||
+		f["ttftP50Ms"] != 10.0 || f["ttftP95Ms"] != 19.0 || f["ttftP99Ms"] != 20.0 {
+		t.Fatalf("unexpected percentiles: %v", f)
+	}
+
+	// The next interval starts empty.
+	c.logInterval()
+	if logs.Len() != 1 {
+		t.Fatal("logged the same traffic twice")
+	}
+	c.Request("ChatCompletion", "OK", true, 5*time.Millisecond)
+	if iv := c.TakeInterval(); iv.Requests != 1 || iv.Latency.P99Ms != 5 || iv.TTFT.Samples != 0 {
+		t.Fatalf("unexpected interval: %+v", iv)
+	}
+	// The totals still have everything.
+	if s := c.Snapshot(false); s.Latency.Samples != 1 {
+		t.Fatalf("expected the totals to keep counting after the reset, got %+v", s.Latency)
+	}
+}
@@ -0,0 +1,403 @@
+// Package stats keeps in-memory runtime counters for harnesses that cannot scrape Prometheus. They
+// are served by the GetStats RPC and GET /stats and can be reset between test phases.
+package stats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reservoirSize bounds the samples kept for percentiles (the most recent ones win).
+const reservoirSize = 4096
+
+// Cardinality caps: further models, or keys of one model, are counted under Other so a fuzzing
+// client cannot grow the maps (and the Prometheus series) without bound. The labels admitted under
+// them are kept across resets, since their series outlive the reset.
+const (
+	maxUsageModels       = 64
+	maxUsageKeysPerModel = 64
+)
+
+// Other is the usage bucket for models and keys beyond the cardinality caps.
+const Other = "other"
+
+// Default is the collector fed by the gRPC interceptors and the HTTP routes.
+var Default = New()
+
+// Collector counts requests by RPC/route and status code, injected errors, active streams and
+// emitted tokens, and keeps bounded windows of request latencies and TTFTs. It is safe for
+// concurrent use.
+type Collector struct {
+	active atomic.Int64
+	peak   atomic.Int64
+	tokens atomic.Int64
+	memory atomic.Int64 // simulated KV-cache bytes held by streams
+	phase  atomic.Pointer[string]
+
+	mu       sync.Mutex
+	since    time.Time
+	rpcs     map[string]*rpcCounters
+	tenants  map[string]*rpcCounters // by TENANT_PROFILES profile
+	injected map[string]int64        // by ERROR_MODE
+	usage    map[string]map[string]*Usage
+	models   *labels            // model labels admitted under maxUsageModels; never reset
+	keys     map[string]*labels // by admitted model, key hashes admitted under maxUsageKeysPerModel; never reset
+	latency  *reservoir
+	ttft     *reservoir
+	window   window // since the last TakeInterval
+}
+
+type rpcCounters struct {
+	requests, successes, failures int64
+	codes                         map[string]int64
+}
+
+// RPCStats are the counters of one RPC or HTTP route.
+type RPCStats struct {
+	Requests  int64            `json:"requests"`
+	Successes int64            `json:"successes"`
+	Failures  int64            `json:"failures"`
+	Codes     map[string]int64 `json:"codes"`
+}
+
+// Usage is the token consumption of one model and API key.
+type Usage struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Latency holds latency percentiles over the most recent samples.
+type Latency struct {
+	P50Ms   float64 `json:"p50_ms"`
+	P90Ms   float64 `json:"p90_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	Samples int     `json:"samples"`
+}
+
+// Snapshot is a point-in-time copy of the counters.
+type Snapshot struct {
+	SinceMs              int64               `json:"since_ms"` // time since start or the last reset
+	RPCs                 map[string]RPCStats `json:"rpcs"`
+	InjectedErrors       int64               `json:"injected_errors"`
+	InjectedByMode       map[string]int64    `json:"injected_by_mode"`
+	ActiveStreams        int64               `json:"active_streams"`
+	PeakActiveStreams    int64               `json:"peak_active_streams"`
+	SimulatedMemoryBytes int64               `json:"simulated_memory_bytes"`
+	TotalTokens          int64               `json:"total_tokens"`
+	Latency              Latency             `json:"latency"`
+	TTFT                 Latency             `json:"ttft"`
+	ScenarioPhase        string              `json:"scenario_phase,omitempty"` // the running SCENARIO_FILE phase
+
+	// Usage is keyed by model, then by KeyHash of the caller's API key.
+	Usage map[string]map[string]Usage `json:"usage"`
+
+	// Tenants are the request counters of each TENANT_PROFILES profile, empty without tenant profiles.
+	Tenants map[string]RPCStats `json:"tenants,omitempty"`
+}
+
+// New returns an empty collector.
+func New() *Collector {
+	return &Collector{
+		since:    time.Now(),
+		rpcs:     map[string]*rpcCounters{},
+		tenants:  map[string]*rpcCounters{},
+		injected: map[string]int64{},
+		usage:    map[string]map[string]*Usage{},
+		models:   newLabels(maxUsageModels),
+		keys:     map[string]*labels{},
+		latency:  newReservoir(reservoirSize),
+		ttft:     newReservoir(reservoirSize),
+		window:   newWindow(),
+	}
+}
+
+// Request records a finished request: its RPC or route, status code (gRPC code name or HTTP
+// status), whether it succeeded and how long it took.
+func (c *Collector) Request(rpc, code string, ok bool, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count(c.rpcs, rpc, code, ok)
+	c.latency.add(d)
+	c.window.requests++
+	if !ok {
+		c.window.errors++
+	}
+	c.window.latency.add(d)
+}
+
+// Tenant records a finished request of the tenant profile tenant (a tenant ID, or the profile unknown
+// tenants fall back to), like Request. Tenants only come from the configured profiles, so their
+// number is bounded.
+func (c *Collector) Tenant(tenant, code string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count(c.tenants, tenant, code, ok)
+}
+
+// count adds a request with code to the counters of name in m.
+func count(m map[string]*rpcCounters, name, code string, ok bool) {
+	r := m[name]
+	if r == nil {
+		r = &rpcCounters{codes: map[string]int64{}}
+		m[name] = r
+	}
+	r.requests++
+	if ok {
+		r.successes++
+	} else {
+		r.failures++
+	}
+	r.codes[code]++
+}
+
+// TTFT records the simulated time to first token of a stream.
+func (c *Collector) TTFT(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttft.add(d)
+	c.window.ttft.add(d)
+}
+
+// Injected records an error injected under ERROR_MODE mode.
+func (c *Collector) Injected(mode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.injected[mode]++
+}
+
+// SetScenarioPhase records the name of the running SCENARIO_FILE phase. Resets keep it.
+func (c *Collector) SetScenarioPhase(name string) { c.phase.Store(&name) }
+
+// ScenarioPhase returns the name of the running SCENARIO_FILE phase, or "" without a scenario.
+func (c *Collector) ScenarioPhase() string {
+	if p := c.phase.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// Tokens records n emitted completion tokens.
+func (c *Collector) Tokens(n int) { c.tokens.Add(int64(n)) }
+
+// Usage records a completed request of model by the caller with API key (empty when
+// unauthenticated) and returns the model and key hash it was counted under, which are Other once
+// the cardinality caps are reached.
+func (c *Collector) Usage(model, key string, promptTokens, completionTokens int) (string, string) {
+	if model == "" {
+		model = "unknown"
+	}
+	keyHash := KeyHash(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	model = c.models.admit(model)
+	kl := c.keys[model]
+	if kl == nil {
+		kl = newLabels(maxUsageKeysPerModel)
+		c.keys[model] = kl
+	}
+	keyHash = kl.admit(keyHash)
+
+	keys := c.usage[model]
+	if keys == nil {
+		keys = map[string]*Usage{}
+		c.usage[model] = keys
+	}
+	u := keys[keyHash]
+	if u == nil {
+		u = &Usage{}
+		keys[keyHash] = u
+	}
+	u.Requests++
+	u.PromptTokens += int64(promptTokens)
+	u.CompletionTokens += int64(completionTokens)
+	return model, keyHash
+}
+
+// ModelLabel returns the model label a request of model is counted under in the metrics: model
+// itself, or Other once maxUsageModels models have been seen. It shares its allowlist with Usage;
+// "" (a request without a model) stays "".
+func (c *Collector) ModelLabel(model string) string {
+	if model == "" {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.models.admit(model)
+}
+
+// labels is an allowlist of up to max label values.
+type labels struct {
+	max  int
+	seen map[string]bool
+}
+
+func newLabels(max int) *labels {
+	return &labels{max: max, seen: map[string]bool{}}
+}
+
+// admit returns v when it is allowed already or there is room to allow it, and Other otherwise.
+func (l *labels) admit(v string) string {
+	if l.seen[v] {
+		return v
+	}
+	if len(l.seen) >= l.max {
+		return Other
+	}
+	l.seen[v] = true
+	return v
+}
+
+// KeyHash identifies an API key in stats and metrics without revealing it: the first 12 hex
+// digits of its SHA-256, or "anonymous" for requests without a key.
+func KeyHash(key string) string {
+	if key == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// StreamStarted counts an active stream until the returned function is called.
+func (c *Collector) StreamStarted() func() {
+	n := c.active.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	return func() { c.active.Add(-1) }
+}
+
+// ReserveMemory adds n bytes to the simulated memory held by streams unless that would take it past
+// limit (0 = no limit), and reports whether it did.
+func (c *Collector) ReserveMemory(n, limit int64) bool {
+	for {
+		cur := c.memory.Load()
+		if limit > 0 && cur+n > limit {
+			return false
+		}
+		if c.memory.CompareAndSwap(cur, cur+n) {
+			return true
+		}
+	}
+}
+
+// ReleaseMemory gives back n bytes taken with ReserveMemory.
+func (c *Collector) ReleaseMemory(n int64) { c.memory.Add(-n) }
+
+// Snapshot returns the current counters and, when reset is set, zeroes them atomically with the
+// read. Active streams and the simulated memory are gauges and are never reset; the peak restarts
+// from the current value. The labels admitted under the cardinality caps are kept.
+func (c *Collector) Snapshot(reset bool) Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Snapshot{
+		SinceMs:              time.Since(c.since).Milliseconds(),
+		RPCs:                 make(map[string]RPCStats, len(c.rpcs)),
+		InjectedByMode:       maps.Clone(c.injected),
+		ActiveStreams:        c.active.Load(),
+		SimulatedMemoryBytes: c.memory.Load(),
+		Latency:              c.latency.percentiles(),
+		TTFT:                 c.ttft.percentiles(),
+		ScenarioPhase:        c.ScenarioPhase(),
+		Usage:                make(map[string]map[string]Usage, len(c.usage)),
+	}
+	for model, keys := range c.usage {
+		m := make(map[string]Usage, len(keys))
+		for k, u := range keys {
+			m[k] = *u
+		}
+		s.Usage[model] = m
+	}
+	for name, r := range c.rpcs {
+		s.RPCs[name] = r.stats()
+	}
+	if len(c.tenants) > 0 {
+		s.Tenants = make(map[string]RPCStats, len(c.tenants))
+		for name, r := range c.tenants {
+			s.Tenants[name] = r.stats()
+		}
+	}
+	for _, n := range c.injected {
+		s.InjectedErrors += n
+	}
+
+	if reset {
+		s.TotalTokens = c.tokens.Swap(0)
+		s.PeakActiveStreams = c.peak.Swap(s.ActiveStreams)
+		c.since = time.Now()
+		c.rpcs = map[string]*rpcCounters{}
+		c.tenants = map[string]*rpcCounters{}
+		c.injected = map[string]int64{}
+		c.usage = map[string]map[string]*Usage{}
+		c.latency = newReservoir(reservoirSize)
+		c.ttft = newReservoir(reservoirSize)
+	} else {
+		s.TotalTokens = c.tokens.Load()
+		s.PeakActiveStreams = c.peak.Load()
+	}
+	return s
+}
+
+func (r *rpcCounters) stats() RPCStats {
+	return RPCStats{
+		Requests:  r.requests,
+		Successes: r.successes,
+		Failures:  r.failures,
+		Codes:     maps.Clone(r.codes),
+	}
+}
+
+// reservoir is a ring buffer of the most recent durations, in milliseconds.
+type reservoir struct {
+	samples []float64
+	next    int
+	full    bool
+}
+
+func newReservoir(size int) *reservoir {
+	return &reservoir{samples: make([]float64, size)}
+}
+
+func (r *reservoir) add(d time.Duration) {
+	r.samples[r.next] = float64(d) / float64(time.Millisecond)
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *reservoir) percentiles() Latency {
+	if r.full {
+		return Percentiles(r.samples)
+	}
+	return Percentiles(r.samples[:r.next])
+}
+
+// Percentiles returns the percentiles of samples (in milliseconds) by the nearest-rank method over
+// a sorted copy.
+func Percentiles(samples []float64) Latency {
+	l := Latency{Samples: len(samples)}
+	if len(samples) == 0 {
+		return l
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	l.P50Ms, l.P90Ms, l.P95Ms, l.P99Ms = rank(0.50), rank(0.90), rank(0.95), rank(0.99)
+	return l
+}
@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentilesOverRecentSamples(t *testing.T) {
+	c := New()
+	for i := 1; i <= 100; i++ {
+		c.Request("rpc", "OK", true, time.Duration(i)*time.Millisecond)
+	}
+	l := c.Snapshot(false).Latency
+	if l.Samples != 100 || l.P50Ms != 50 || l.P90Ms != 90 || l.P99Ms != 99 {
+		t.Fatalf("unexpected percentiles: %+v", l)
+	}
+
+	// Once the reservoir is full the oldest samples are overwritten.
+	for i := 0; i < reservoirSize; i++ {
+		c.Request("rpc", "OK", true, time.Second)
+	}
+	l = c.Snapshot(false).Latency
+	if l.Samples != reservoirSize || l.P50Ms != 1000 || l.P99Ms != 1000 {
+		t.Fatalf("expected only the recent 1s samples: %+v", l)
+	}
+}
+
+func TestSnapshotReset(t *testing.T) {
+	c := New()
+	done := c.StreamStarted()
+	c.Request("rpc", "OK", true, time.Millisecond)
+	c.Request("rpc", "Internal", false, time.Millisecond)
+	c.Injected("internal")
+	c.Tokens(42)
+	c.Tenant("acme", "PermissionDenied", false)
+
+	s := c.Snapshot(true)
+	r := s.RPCs["rpc"]
+	if r.Requests != 2 || r.Successes != 1 || r.Failures != 1 || r.Codes["Internal"] != 1 ||
+		s.InjectedErrors != 1 || s.TotalTokens != 42 || s.ActiveStreams != 1 || s.Tenants["acme"].Codes["PermissionDenied"] != 1 {
+		t.Fatalf("unexpected snapshot: %+v", s)
+	}
+
+	s = c.Snapshot(false)
+	if len(s.RPCs) != 0 || len(s.Tenants) != 0 || s.InjectedErrors != 0 || s.TotalTokens != 0 || s.Latency.Samples != 0 {
+		t.Fatalf("expected zeroed counters: %+v", s)
+	}
+	if s.ActiveStreams != 1 {
+		t.Fatalf("active streams must survive a reset, got %d", s.ActiveStreams)
+	}
+	done()
+	if n := c.Snapshot(false).ActiveStreams; n != 0 {
+		t.Fatalf("expected 0 active streams, got %d", n)
+	}
+}
+
+func TestUsageCardinalityCap(t *testing.T) {
+	c := New()
+	for i := 0; i < maxUsageModels+5; i++ {
+		c.Usage(fmt.Sprintf("model-%d", i), "k", 1, 2)
+	}
+	for i := 0; i < maxUsageKeysPerModel+5; i++ {
+		c.Usage("model-0", fmt.Sprintf("key-%d", i), 1, 2)
+	}
+
+	s := c.Snapshot(false)
+	if len(s.Usage) != maxUsageModels+1 {
+		t.Fatalf("expected %d model buckets, got %d", maxUsageModels+1, len(s.Usage))
+	}
+	if u := s.Usage[Other][KeyHash("k")]; u.Requests != 5 || u.PromptTokens != 5 || u.CompletionTokens != 10 {
+		t.Fatalf("unexpected other-model usage: %+v", u)
+	}
+	if n := len(s.Usage["model-0"]); n != maxUsageKeysPerModel+1 {
+		t.Fatalf("expected %d key buckets, got %d", maxUsageKeysPerModel+1, n)
+	}
+	// model-0 already held "k", so only the last 6 new keys overflow.
+	if u := s.Usage["model-0"][Other]; u.Requests != 6 {
+		t.Fatalf("unexpected other-key usage: %+v", u)
+	}
+
+	// Known models and keys keep their own buckets.
+	if m, k := c.Usage("model-1", "k", 1, 1); m != "model-1" || k != KeyHash("k") {
+		t.Fatalf("existing bucket relabeled: %s/%s", m, k)
+	}
+	if m, k := c.Usage("brand-new", "k", 1, 1); m != Other || k != KeyHash("k") {
+		t.Fatalf("new model should be capped: %s/%s", m, k)
+	}
+}
+
+// TestUsageCapSurvivesReset checks a reset doesn't make room for new labels: their Prometheus
+// series would never go away.
+func TestUsageCapSurvivesReset(t *testing.T) {
+	c := New()
+	for i := 0; i < maxUsageModels; i++ {
+		c.Usage(fmt.Sprintf("model-%d", i), "k", 1, 1)
+	}
+	c.Snapshot(true)
+	if m, _ := c.Usage("brand-new", "k", 1, 1); m != Other {
+		t.Fatalf("new model admitted after a reset: %s", m)
+	}
+	if m, _ := c.Usage("model-3", "k", 1, 1); m != "model-3" {
+		t.Fatalf("admitted model relabeled after a reset: %s", m)
+	}
+}
+
+func TestModelLabel(t *testing.T) {
+	c := New()
+	c.Usage("used", "k", 1, 1)
+	for i := 0; i < maxUsageModels; i++ {
+		c.ModelLabel(fmt.Sprintf("model-%d", i))
+	}
+	if got := c.ModelLabel("used"); got != "used" {
+		t.Fatalf("a model of the usage stats relabeled: %s", got)
+	}
+	if got := c.ModelLabel("model-62"); got != "model-62" {
+		t.Fatalf("an admitted model relabeled: %s", got)
+	}
+	if got := c.ModelLabel("model-63"); got != Other {
+		t.Fatalf("a model past the cap should be %s, got %s", Other, got)
+	}
+	if m, _ := c.Usage("model-63", "k", 1, 1); m != Other {
+		t.Fatalf("usage and requests disagree on model-63: %s", m)
+	}
+	if got := c.ModelLabel(""); got != "" {
+		t.Fatalf("an empty model should stay empty, got %q", got)
+	}
+}
+
+func TestKeyHashDoesNotLeakKey(t *testing.T) {
+	if h := KeyHash("sk-secret"); len(h) != 12 || strings.Contains(h, "secret") || h != KeyHash("sk-secret") {
+		t.Fatalf("unexpected key hash %q", h)
+	}
+	if KeyHash("") != "anonymous" {
+		t.Fatalf("empty key should hash to anonymous, got %q", KeyHash(""))
+	}
+}
+
+func TestReserveMemory(t *testing.T) {
+	c := New()
+	if !c.ReserveMemory(60, 100) || !c.ReserveMemory(40, 100) {
+		t.Fatal("reservations within the limit were refused")
+	}
+	if c.ReserveMemory(1, 100) {
+		t.Fatal("reservation past the limit was granted")
+	}
+	c.ReleaseMemory(60)
+	if !c.ReserveMemory(50, 0) {
+		t.Fatal("reservation without a limit was refused")
+	}
+	if s := c.Snapshot(true); s.SimulatedMemoryBytes != 90 {
+		t.Fatalf("expected 90 bytes held, got %d", s.SimulatedMemoryBytes)
+	}
+	if s := c.Snapshot(false); s.SimulatedMemoryBytes != 90 {
+		t.Fatalf("reset should keep the simulated memory, got %d", s.SimulatedMemoryBytes)
+	}
+}
@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Summary is the end-of-run report logged on graceful shutdown and written to SUMMARY_FILE.
+type Summary struct {
+	StartedAt         time.Time        `json:"started_at"`
+	DurationMs        int64            `json:"duration_ms"`
+	Requests          int64            `json:"requests"`
+	Failures          int64            `json:"failures"`
+	Codes             map[string]int64 `json:"codes"`
+	InjectedErrors    int64            `json:"injected_errors"`
+	InjectedByMode    map[string]int64 `json:"injected_by_mode"`
+	Latency           Latency          `json:"latency"`
+	TTFT              Latency          `json:"ttft"`
+	TotalTokens       int64            `json:"total_tokens"`
+	PeakActiveStreams int64            `json:"peak_active_streams"`
+}
+
+// NewSummary builds the report for a run that started at start and ended at end.
+func NewSummary(s Snapshot, start, end time.Time) Summary {
+	sum := Summary{
+		StartedAt:         start.UTC(),
+		DurationMs:        end.Sub(start).Milliseconds(),
+		Codes:             map[string]int64{},
+		InjectedErrors:    s.InjectedErrors,
+		InjectedByMode:    s.InjectedByMode,
+		Latency:           s.Latency,
+		TTFT:              s.TTFT,
+		TotalTokens:       s.TotalTokens,
+		PeakActiveStreams: s.PeakActiveStreams,
+	}
+	if sum.InjectedByMode == nil {
+		sum.InjectedByMode = map[string]int64{}
+	}
+	for _, r := range s.RPCs {
+		sum.Requests += r.Requests
+		sum.Failures += r.Failures
+		for code, n := range r.Codes {
+			sum.Codes[code] += n
+		}
+	}
+	return sum
+}
+
+// Fields returns the summary as structured log fields.
+func (s Summary) Fields() []any {
+	return []any{
+		"durationMs", s.DurationMs,
+		"requests", s.Requests,
+		"failures", s.Failures,
+		"codes", s.Codes,
+		"injectedByMode", s.InjectedByMode,
+		"latencyP50Ms", s.Latency.P50Ms,
+		"latencyP90Ms", s.Latency.P90Ms,
+		"latencyP99Ms", s.Latency.P99Ms,
+		"ttftP50Ms", s.TTFT.P50Ms,
+		"ttftP99Ms", s.TTFT.P99Ms,
+		"totalTokens", s.TotalTokens,
+		"peakActiveStreams", s.PeakActiveStreams,
+	}
+}
+
+// WriteFile writes the summary as indented JSON to path.
+func (s Summary) WriteFile(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
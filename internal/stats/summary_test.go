@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSummaryWriteFile(t *testing.T) {
+	c := New()
+	for _, d := range []time.Duration{10, 20, 30} {
+		c.Request("ChatCompletionStream", "OK", true, d*time.Millisecond)
+		c.TTFT(d * time.Millisecond / 2)
+	}
+	c.Request("ChatCompletion", "Unavailable", false, time.Millisecond)
+	c.Injected("unavailable")
+	c.Tokens(300)
+	done1, done2 := c.StreamStarted(), c.StreamStarted()
+	done1()
+	done2()
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := NewSummary(c.Snapshot(false), start, start.Add(90*time.Second)).WriteFile(path); err != nil {
+		t.Fatalf("write summary: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read summary: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("summary is not JSON: %v", err)
+	}
+	for _, k := range []string{
+		"started_at", "duration_ms", "requests", "failures", "codes", "injected_errors",
+		"injected_by_mode", "latency", "ttft", "total_tokens", "peak_active_streams",
+	} {
+		if _, ok := got[k]; !ok {
+			t.Fatalf("summary is missing %q: %s", k, b)
+		}
+	}
+
+	var sum Summary
+	if err := json.Unmarshal(b, &sum); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if sum.DurationMs != 90_000 || sum.Requests != 4 || sum.Failures != 1 || sum.Codes["OK"] != 3 ||
+		sum.InjectedByMode["unavailable"] != 1 || sum.TotalTokens != 300 || sum.PeakActiveStreams != 2 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if sum.Latency.Samples != 4 || sum.Latency.P99Ms != 30 || sum.TTFT.Samples != 3 || sum.TTFT.P50Ms != 10 {
+		t.Fatalf("unexpected percentiles: latency=%+v ttft=%+v", sum.Latency, sum.TTFT)
+	}
+	if !sum.StartedAt.Equal(start) {
+		t.Fatalf("unexpected start: %v", sum.StartedAt)
+	}
+}
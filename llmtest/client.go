@@ -0,0 +1,145 @@
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Step is one step of a ClientStream script: Recv waits Delay, then returns Chunk, or Err when set.
+type Step struct {
+	Delay time.Duration
+	Chunk *llmv1.ChatCompletionChunkResponse
+	Err   error
+}
+
+// ClientStream is a fake llmv1.LlmService_ChatCompletionStreamClient that plays a script: each Recv
+// returns the next Step, then io.EOF once the script is done. Once its context is done Recv returns
+// the Canceled or DeadlineExceeded status a real stream would, also while waiting out a Delay.
+type ClientStream struct {
+	// HeaderMD is returned by Header; TrailerMD by Trailer once the script is done, as gRPC only has
+	// the trailer at the end of the stream.
+	HeaderMD, TrailerMD metadata.MD
+
+	ctx context.Context
+
+	mu     sync.Mutex
+	script []Step
+	pos    int
+	ended  bool
+}
+
+// NewClientStream returns a stream with context ctx that plays script.
+func NewClientStream(ctx context.Context, script ...Step) *ClientStream {
+	return &ClientStream{ctx: ctx, script: script}
+}
+
+// Recv returns the next chunk of the script.
+func (s *ClientStream) Recv() (*llmv1.ChatCompletionChunkResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if s.pos == len(s.script) {
+		s.ended = true
+		return nil, io.EOF
+	}
+	step := s.script[s.pos]
+	s.pos++
+	if step.Delay > 0 {
+		t := time.NewTimer(step.Delay)
+		defer t.Stop()
+		select {
+		case <-s.ctx.Done():
+			return nil, status.FromContextError(s.ctx.Err()).Err()
+		case <-t.C:
+		}
+	}
+	if step.Err != nil {
+		s.ended = true
+		return nil, step.Err
+	}
+	return step.Chunk, nil
+}
+
+// Header returns HeaderMD.
+func (s *ClientStream) Header() (metadata.MD, error) {
+	return s.HeaderMD, nil
+}
+
+// Trailer returns TrailerMD once Recv has returned io.EOF or a scripted error, nil before.
+func (s *ClientStream) Trailer() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		return nil
+	}
+	return s.TrailerMD
+}
+
+// CloseSend does nothing: the request of a server stream is sent when it is opened.
+func (s *ClientStream) CloseSend() error {
+	return nil
+}
+
+// Context returns the context the stream was created with.
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg fails: a server stream takes no messages after its request.
+func (s *ClientStream) SendMsg(m any) error {
+	return fmt.Errorf("llmtest: SendMsg(%T) on a server stream", m)
+}
+
+// RecvMsg receives the next chunk into m, a *llmv1.ChatCompletionChunkResponse.
+func (s *ClientStream) RecvMsg(m any) error {
+	dst, ok := m.(*llmv1.ChatCompletionChunkResponse)
+	if !ok {
+		return fmt.Errorf("llmtest: unexpected message type %T", m)
+	}
+	c, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Reset(dst)
+	proto.Merge(dst, c)
+	return nil
+}
+
+// TextScript returns the script of a completed simulator stream of text under the default
+// EVENT_NAMING: deltas of up to chunkSize bytes (split between runes), gap apart, then a done chunk with finish reason
+// stop, the completion tokens and the digest of text.
+func TextScript(text string, chunkSize int, gap time.Duration) []Step {
+	var script []Step
+	for rest := text; rest != ""; {
+		n := min(chunkSize, len(rest))
+		for n < len(rest) && n > 1 && !utf8.RuneStart(rest[n]) {
+			n--
+		}
+		script = append(script, Step{Delay: gap, Chunk: &llmv1.ChatCompletionChunkResponse{Type: string(events.OutputTextDelta), Text: rest[:n]}})
+		rest = rest[n:]
+	}
+	digest := mock.Digest(text)
+	ct := int32(mock.ApproxTokens(text))
+	return append(script, Step{Delay: gap, Chunk: &llmv1.ChatCompletionChunkResponse{
+		Type:             string(events.OutputTextDone),
+		FinishReason:     string(events.FinishStop),
+		CompletionTokens: ct,
+		TotalTokens:      ct,
+		OutputSha256:     digest.SHA256,
+		OutputBytes:      int64(digest.Bytes),
+	}})
+}
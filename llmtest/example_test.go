@@ -0,0 +1,153 @@
+package llmtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/llmtest"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// relay forwards every chunk of in to out, the kind of proxy handler ServerStream and ClientStream
+// are meant to test.
+func relay(in llmv1.LlmService_ChatCompletionStreamClient, out llmv1.LlmService_ChatCompletionStreamServer) error {
+	for {
+		c, err := in.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := out.Send(c); err != nil {
+			return err
+		}
+	}
+}
+
+func ExampleServerStream() {
+	out := llmtest.NewServerStream(context.Background())
+	out.FailAfter = 3 // the client goes away after three chunks
+
+	in := llmtest.NewClientStream(context.Background(), llmtest.TextScript("hello from the fake", 4, 0)...)
+	err := relay(in, out)
+	fmt.Println(status.Code(err))
+	fmt.Printf("%q\n", llmtest.Text(out.Chunks()))
+	// Output:
+	// Unavailable
+	// "hello from t"
+}
+
+func ExampleClientStream() {
+	in := llmtest.NewClientStream(context.Background(), llmtest.TextScript("scripted reply", 8, time.Millisecond)...)
+	for {
+		c, err := in.Recv()
+		if err != nil {
+			fmt.Println(err == io.EOF)
+			break
+		}
+		fmt.Printf("%s %q %q\n", c.GetType(), c.GetText(), c.GetFinishReason())
+	}
+	// Output:
+	// output_text.delta "scripted" ""
+	// output_text.delta " reply" ""
+	// output_text.done "" "stop"
+	// true
+}
+
+func TestRelay(t *testing.T) {
+	const text = "the quick brown fox jumps over the lazy dog"
+	in := llmtest.NewClientStream(context.Background(), llmtest.TextScript(text, 8, 0)...)
+	in.TrailerMD = metadata.Pairs("x-usage-completion-tokens", "11")
+	out := llmtest.NewServerStream(context.Background())
+	if in.Trailer() != nil {
+		t.Fatalf("trailer available before the stream ended: %v", in.Trailer())
+	}
+
+	if err := relay(in, out); err != nil {
+		t.Fatalf("relay failed: %v", err)
+	}
+	if got := llmtest.Text(out.Chunks()); got != text {
+		t.Fatalf("relayed %q, want %q", got, text)
+	}
+	if done := llmtest.RequireDone(t, out.Chunks(), events.FinishStop); done.GetCompletionTokens() == 0 {
+		t.Fatalf("done chunk should report usage: %+v", done)
+	}
+	if in.Trailer().Get("x-usage-completion-tokens") == nil {
+		t.Fatalf("trailer missing once the stream ended")
+	}
+}
+
+func TestServerStreamFailAfter(t *testing.T) {
+	in := llmtest.NewClientStream(context.Background(), llmtest.TextScript("a stream cut short", 4, 0)...)
+	out := llmtest.NewServerStream(context.Background())
+	out.FailAfter = 2
+
+	if err := relay(in, out); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if len(out.Chunks()) != 2 || out.Attempts() != 3 {
+		t.Fatalf("recorded %d chunks in %d sends, want 2 in 3", len(out.Chunks()), out.Attempts())
+	}
+
+	reset := errors.New("connection reset")
+	out = llmtest.NewServerStream(context.Background())
+	out.FailAfter, out.SendErr = 1, reset
+	if err := relay(llmtest.NewClientStream(context.Background(), llmtest.TextScript("again", 1, 0)...), out); !errors.Is(err, reset) {
+		t.Fatalf("expected SendErr, got %v", err)
+	}
+}
+
+func TestClientStreamErrors(t *testing.T) {
+	failed := &llmv1.ChatCompletionChunkResponse{
+		Type:         string(events.Failed),
+		FinishReason: string(events.FinishError),
+		ErrorCode:    codes.ResourceExhausted.String(),
+	}
+	in := llmtest.NewClientStream(context.Background(),
+		llmtest.Step{Chunk: &llmv1.ChatCompletionChunkResponse{Type: string(events.OutputTextDelta), Text: "partial"}},
+		llmtest.Step{Chunk: failed},
+		llmtest.Step{Err: status.Error(codes.ResourceExhausted, "rate limited")},
+	)
+	out := llmtest.NewServerStream(context.Background())
+	if err := relay(in, out); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the scripted error, got %v", err)
+	}
+	llmtest.RequireFailed(t, out.Chunks(), codes.ResourceExhausted)
+
+	// A cancelled context interrupts a Delay like it would a real stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := llmtest.NewClientStream(ctx, llmtest.Step{Delay: time.Minute}).Recv()
+	if status.Code(err) != codes.DeadlineExceeded || time.Since(start) > time.Second {
+		t.Fatalf("expected DeadlineExceeded at the deadline, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestTextScript(t *testing.T) {
+	script := llmtest.TextScript("héllo wörld", 4, time.Millisecond)
+	var chunks []*llmv1.ChatCompletionChunkResponse
+	for _, s := range script {
+		chunks = append(chunks, s.Chunk)
+	}
+	if got := llmtest.Text(chunks); got != "héllo wörld" {
+		t.Fatalf("script reassembles to %q", got)
+	}
+	for _, c := range chunks {
+		if !utf8.ValidString(c.GetText()) {
+			t.Fatalf("delta %q splits a rune", c.GetText())
+		}
+	}
+	llmtest.RequireDone(t, chunks, events.FinishStop)
+}
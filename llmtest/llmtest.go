@@ -0,0 +1,109 @@
+// Package llmtest provides test doubles for code built on the llm.v1 LlmService streams, so it can be
+// unit-tested without a server:
+//
+//   - ServerStream is an llmv1.LlmService_ChatCompletionStreamServer that records what a handler
+//     sends, for code implementing the service (or wrapping it).
+//   - ClientStream is an llmv1.LlmService_ChatCompletionStreamClient that plays a scripted chunk
+//     sequence, for code consuming a stream.
+//   - Deltas, Text, Terminal, RequireDone and RequireFailed reassemble and check the chunks of a
+//     stream.
+//
+// To run the simulator itself in-process, see package simulatortest.
+package llmtest
+
+import (
+	"testing"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/mock"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Deltas reassembles the text of each choice of a stream, keyed by chunk Index: the concatenated
+// Text of its chunks without a finish reason, in order. Done, stop and failed chunks carry no text.
+func Deltas(chunks []*llmv1.ChatCompletionChunkResponse) map[int32]string {
+	texts := map[int32]string{}
+	for _, c := range chunks {
+		if c.GetFinishReason() == "" && c.GetText() != "" {
+			texts[c.GetIndex()] += c.GetText()
+		}
+	}
+	return texts
+}
+
+// Text reassembles the text of a single-choice stream (choice 0).
+func Text(chunks []*llmv1.ChatCompletionChunkResponse) string {
+	return Deltas(chunks)[0]
+}
+
+// Terminal returns the last chunk with a finish reason: the done chunk of a completed stream (of its
+// last choice, for n > 1) or the failed chunk of a failed one. It is nil when the stream has neither.
+// A stop chunk following the done chunk (EVENT_NAMING=anthropic) is not terminal in this sense.
+func Terminal(chunks []*llmv1.ChatCompletionChunkResponse) *llmv1.ChatCompletionChunkResponse {
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if chunks[i].GetFinishReason() != "" {
+			return chunks[i]
+		}
+	}
+	return nil
+}
+
+// RequireDone fails t unless every choice of the stream ended with a done chunk with finish reason
+// want, sent after its last delta, whose output digest and byte count (when set) describe the
+// reassembled text of the choice. It returns the terminal chunk.
+func RequireDone(t testing.TB, chunks []*llmv1.ChatCompletionChunkResponse, want events.FinishReason) *llmv1.ChatCompletionChunkResponse {
+	t.Helper()
+	done := map[int32]*llmv1.ChatCompletionChunkResponse{}
+	texts := map[int32]string{}
+	for _, c := range chunks {
+		i := c.GetIndex()
+		switch {
+		case c.GetFinishReason() == string(events.FinishError):
+			t.Fatalf("llmtest: stream failed: %s %s", c.GetErrorCode(), c.GetErrorMessage())
+		case c.GetFinishReason() != "":
+			done[i] = c
+		case c.GetText() != "":
+			if done[i] != nil {
+				t.Fatalf("llmtest: choice %d sent a delta after its done chunk", i)
+			}
+			texts[i] += c.GetText()
+		}
+	}
+	if len(done) == 0 {
+		t.Fatalf("llmtest: stream has no done chunk (%d chunks)", len(chunks))
+	}
+	for i := range texts {
+		if done[i] == nil {
+			t.Fatalf("llmtest: choice %d has no done chunk", i)
+		}
+	}
+	for i, d := range done {
+		if got := events.FinishReason(d.GetFinishReason()); got != want {
+			t.Fatalf("llmtest: choice %d finished with %q, want %q", i, got, want)
+		}
+		if d.GetOutputSha256() == "" {
+			continue
+		}
+		if digest := mock.Digest(texts[i]); d.GetOutputSha256() != digest.SHA256 || d.GetOutputBytes() != int64(digest.Bytes) {
+			t.Fatalf("llmtest: choice %d done chunk describes %d bytes (%s), the deltas hold %d (%s)",
+				i, d.GetOutputBytes(), d.GetOutputSha256(), digest.Bytes, digest.SHA256)
+		}
+	}
+	return Terminal(chunks)
+}
+
+// RequireFailed fails t unless the stream ended with a failed chunk for an error with code. It
+// returns the failed chunk.
+func RequireFailed(t testing.TB, chunks []*llmv1.ChatCompletionChunkResponse, code codes.Code) *llmv1.ChatCompletionChunkResponse {
+	t.Helper()
+	c := Terminal(chunks)
+	if c == nil || c.GetFinishReason() != string(events.FinishError) {
+		t.Fatalf("llmtest: stream did not end with a failed chunk: %v", c)
+	}
+	if c.GetErrorCode() != code.String() {
+		t.Fatalf("llmtest: failed chunk has code %s, want %s", c.GetErrorCode(), code)
+	}
+	return c
+}
@@ -0,0 +1,159 @@
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Sent is a chunk a ServerStream accepted, with when it was sent.
+type Sent struct {
+	Chunk *llmv1.ChatCompletionChunkResponse
+	At    time.Time
+}
+
+// ServerStream is a fake llmv1.LlmService_ChatCompletionStreamServer: it records the chunks, header
+// and trailer a handler sends, and can fail Send like a broken connection. Set the exported fields
+// before the handler runs; read what was sent with the methods once it returns (they are safe to
+// call while it runs, outside OnSend).
+type ServerStream struct {
+	// OnSend, when set, is called from Send after the chunk is recorded, before Send returns. Blocking
+	// in it blocks Send, like a client whose flow-control window is full.
+	OnSend func(*llmv1.ChatCompletionChunkResponse)
+
+	// FailAfter, when > 0, makes every Send after this many chunks fail with SendErr.
+	FailAfter int
+	// SendErr is the error of a failed Send; nil means Unavailable "transport is closing", what gRPC
+	// returns once the connection is gone.
+	SendErr error
+
+	ctx context.Context
+
+	mu       sync.Mutex
+	sent     []Sent
+	attempts int
+	header   metadata.MD
+	headerAt time.Time
+	trailer  metadata.MD
+}
+
+// NewServerStream returns a stream whose Context is ctx: put incoming metadata
+// (metadata.NewIncomingContext) or a deadline on it to test how a handler reads them.
+func NewServerStream(ctx context.Context) *ServerStream {
+	return &ServerStream{ctx: ctx}
+}
+
+// Send records c, or fails once FailAfter chunks were sent.
+func (s *ServerStream) Send(c *llmv1.ChatCompletionChunkResponse) error {
+	s.mu.Lock()
+	s.attempts++
+	if s.FailAfter > 0 && len(s.sent) >= s.FailAfter {
+		s.mu.Unlock()
+		if s.SendErr != nil {
+			return s.SendErr
+		}
+		return status.Error(codes.Unavailable, "transport is closing")
+	}
+	s.sent = append(s.sent, Sent{Chunk: c, At: time.Now()})
+	s.mu.Unlock()
+	if s.OnSend != nil {
+		s.OnSend(c)
+	}
+	return nil
+}
+
+// Chunks returns the chunks sent so far, in order.
+func (s *ServerStream) Chunks() []*llmv1.ChatCompletionChunkResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chunks := make([]*llmv1.ChatCompletionChunkResponse, len(s.sent))
+	for i, sent := range s.sent {
+		chunks[i] = sent.Chunk
+	}
+	return chunks
+}
+
+// Sends returns the chunks sent so far with their send times, in order.
+func (s *ServerStream) Sends() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// Attempts returns the number of Send calls, the failed ones included.
+func (s *ServerStream) Attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
+
+// Header returns the header metadata set or sent so far.
+func (s *ServerStream) Header() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.header
+}
+
+// HeaderSentAt returns when SendHeader was called, zero when it wasn't.
+func (s *ServerStream) HeaderSentAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headerAt
+}
+
+// Trailer returns the trailer metadata set so far. Like gRPC, every SetTrailer adds to it.
+func (s *ServerStream) Trailer() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trailer
+}
+
+// SetHeader adds md to the header.
+func (s *ServerStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// SendHeader adds md to the header and records when it was sent.
+func (s *ServerStream) SendHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	s.headerAt = time.Now()
+	return nil
+}
+
+// SetTrailer adds md to the trailer.
+func (s *ServerStream) SetTrailer(md metadata.MD) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+// Context returns the context the stream was created with.
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg sends a *llmv1.ChatCompletionChunkResponse like Send.
+func (s *ServerStream) SendMsg(m any) error {
+	c, ok := m.(*llmv1.ChatCompletionChunkResponse)
+	if !ok {
+		return fmt.Errorf("llmtest: unexpected message type %T", m)
+	}
+	return s.Send(c)
+}
+
+// RecvMsg returns nil: the request of a server stream is passed to the handler directly.
+func (s *ServerStream) RecvMsg(any) error {
+	return nil
+}
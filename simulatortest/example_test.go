@@ -0,0 +1,98 @@
+package simulatortest_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yungtweek/llm-simulator/events"
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/simulatortest"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChatCompletionStream(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.ChunkSize = simulatortest.Int(8)
+	client := simulatortest.NewClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.ChatCompletionStream(ctx, &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+
+	var text strings.Builder
+	var done *llmv1.ChatCompletionChunkResponse
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		if chunk.GetType() == string(events.OutputTextDone) {
+			done = chunk
+			continue
+		}
+		text.WriteString(chunk.GetText())
+	}
+	if done == nil || text.Len() == 0 {
+		t.Fatalf("expected deltas and a done chunk, got %q done=%v", text.String(), done)
+	}
+	if done.GetCompletionTokens() == 0 {
+		t.Fatalf("done chunk should report usage: %+v", done)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	cfg := simulatortest.DefaultConfig()
+	cfg.ErrorRate = 1
+	cfg.ErrorMode = "429"
+	client := simulatortest.NewClient(t, cfg)
+
+	_, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestCustomInterceptors(t *testing.T) {
+	var unary, streams atomic.Int32
+	client := simulatortest.NewClient(t, simulatortest.DefaultConfig(),
+		simulatortest.WithUnaryInterceptors(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			unary.Add(1)
+			return handler(ctx, req)
+		}),
+		simulatortest.WithStreamInterceptors(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			streams.Add(1)
+			return handler(srv, ss)
+		}),
+	)
+
+	if _, err := client.ChatCompletion(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8}); err != nil {
+		t.Fatalf("unary call failed: %v", err)
+	}
+	stream, err := client.ChatCompletionStream(context.Background(), &llmv1.ChatCompletionRequest{UserPrompt: "hello", MaxTokens: 8})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+	}
+	if unary.Load() != 1 || streams.Load() != 1 {
+		t.Fatalf("interceptors saw %d unary and %d streaming calls, want 1 and 1", unary.Load(), streams.Load())
+	}
+}
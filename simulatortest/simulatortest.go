@@ -0,0 +1,84 @@
+// Package simulatortest runs the simulator's gRPC service in-process over bufconn, so integration
+// tests (retry and streaming logic in client code) can talk to it without binding a TCP port or
+// starting a container. To unit-test stream handling without a server, see package llmtest.
+package simulatortest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	llmv1 "github.com/yungtweek/llm-simulator/gen"
+	"github.com/yungtweek/llm-simulator/internal/config"
+	simgrpc "github.com/yungtweek/llm-simulator/internal/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Config is the simulator configuration (the same fields LoadConfig reads from the environment).
+type Config = config.Config
+
+// Int, Float and Bool return pointers for the optional Config fields (ChunkSize, TTFTMinMs,
+// TokensPerSec, ...), where nil means unset.
+var (
+	Int   = config.Int
+	Float = config.Float
+	Bool  = config.Bool
+)
+
+// Server options for NewClient, to run your own middleware in the simulator: interceptors are
+// chained inside the built-in ones (recovery, access log, metrics, auth), in the order given.
+var (
+	WithUnaryInterceptors  = simgrpc.WithUnaryInterceptors
+	WithStreamInterceptors = simgrpc.WithStreamInterceptors
+	WithServerOptions      = simgrpc.WithServerOptions
+)
+
+const bufSize = 1 << 20
+
+// DefaultConfig returns the LoadConfig defaults without any artificial latency, so tests run fast.
+// Adjust fields (ErrorRate, TTFTMinMs, ...) to exercise specific behavior.
+func DefaultConfig() Config {
+	return Config{
+		ErrorMode:       "mixed",
+		ErrorTiming:     "pre",
+		DefaultTokens:   128,
+		ChunkSize:       Int(12),
+		MaxOutputChars:  Int(16384),
+		StrictTokenMode: Bool(true),
+		GRPCCompression: "gzip",
+	}
+}
+
+// NewClient starts the full LlmService (interceptors included) for cfg on a bufconn listener and
+// returns a client connected to it. serverOpts are added to the server's own options (see
+// WithUnaryInterceptors). The server and connection are torn down on t.Cleanup. TLS settings in cfg
+// are ignored; the in-memory connection is always insecure.
+func NewClient(t testing.TB, cfg Config, serverOpts ...grpc.ServerOption) llmv1.LlmServiceClient {
+	t.Helper()
+	cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile = "", "", ""
+
+	opts, err := simgrpc.ServerOptions(cfg, simgrpc.NewLimits(cfg), nil)
+	if err != nil {
+		t.Fatalf("simulatortest: server options: %v", err)
+	}
+	lis := bufconn.Listen(bufSize)
+	srv := simgrpc.NewGRPCServer("bufconn", simgrpc.NewMockLlmService(cfg), append(opts, serverOpts...)...)
+	go func() { _ = srv.RunWithListener(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		srv.Stop()
+		t.Fatalf("simulatortest: dial: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+	return llmv1.NewLlmServiceClient(conn)
+}